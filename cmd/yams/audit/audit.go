@@ -1,27 +1,46 @@
 package audit
 
 import (
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"runtime/debug"
 	"runtime/pprof"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/nsiow/yams/cmd/yams/cli"
 	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/distributed"
 	"github.com/nsiow/yams/pkg/entities"
-	"github.com/nsiow/yams/pkg/server"
 	"github.com/nsiow/yams/pkg/sim"
 )
 
-// ConfigEntry defines a resource type and the actions to audit against it
+// Values of the result column, matching those of simulation results
+const (
+	resultAllow = "ALLOW"
+	resultDeny  = "DENY"
+)
+
+// ConfigEntry defines a resource type and the actions to audit against it, along with optional
+// filters and simulation settings which apply only to this entry
 type ConfigEntry struct {
 	ResourceType string   `json:"resource_type"`
 	Actions      []string `json:"actions"`
+
+	// Principals and Resources restrict which entities are audited
+	Principals PrincipalFilter `json:"principals"`
+	Resources  ResourceFilter  `json:"resources"`
+
+	// Context holds request context for this entry, taking precedence over -context
+	Context map[string]string `json:"context"`
+
+	// Overlays lists overlay files applied for this entry, after those provided with -overlay
+	Overlays []string `json:"overlays"`
 }
 
 // Run executes the audit subcommand
@@ -58,7 +77,32 @@ func Run(opts *cli.Flags) {
 		cli.Fail("error loading config: %v", err)
 	}
 
-	simulator, err := buildSimulator(opts.Sources)
+	baseline, err := loadBaseline(opts.Baselines)
+	if err != nil {
+		cli.Fail("error loading baseline: %v", err)
+	}
+
+	columns, err := validateColumns(opts.Columns)
+	if err != nil {
+		cli.Fail("error: %v", err)
+	}
+
+	shard, err := parseShard(opts.Shard)
+	if err != nil {
+		cli.Fail("error: %v", err)
+	}
+
+	// Products are simulated by the workers of a coordinator if one is provided
+	var client *distributed.Client
+	if opts.Coordinator != "" {
+		err := validateRemote(config, opts.Explain, columns, opts.OverlayFiles)
+		if err != nil {
+			cli.Fail("error: %v", err)
+		}
+		client = distributed.NewClient(opts.Coordinator)
+	}
+
+	simulator, sourcesHash, err := distributed.LoadSources(opts.Sources)
 	if err != nil {
 		cli.Fail("error building simulator: %v", err)
 	}
@@ -68,53 +112,252 @@ func Run(opts *cli.Flags) {
 	if len(opts.Context) > 0 {
 		simOpts = append(simOpts, sim.WithAdditionalProperties(opts.Context))
 	}
+	if opts.Explain || slices.Contains(columns, columnAllowingPolicy) {
+		simOpts = append(simOpts, sim.WithTracing())
+	}
 	sopts := sim.NewOptions(simOpts...)
 
 	if len(opts.OverlayFiles) > 0 {
-		overlay, err := cli.LoadOverlays(opts.OverlayFiles)
+		overlays, err := cli.LoadOverlays(opts.OverlayFiles)
 		if err != nil {
 			cli.Fail("error loading overlays: %v", err)
 		}
-		sopts.Overlay = overlay.Universe()
+		for _, overlay := range overlays {
+			sopts.Overlays = append(sopts.Overlays, overlay.Universe())
+		}
 	}
 
-	// Pre-freeze all principals once (reused across all config entries)
+	// Pre-freeze all principals once (reused across all config entries). Principals are sorted so
+	// that every run divides them into the same partitions, which checkpoints refer to
 	allPrincipalArns := simulator.Universe.PrincipalArns()
-	slog.Info("freezing principals", "count", len(allPrincipalArns))
+	slices.Sort(allPrincipalArns)
+	allPrincipalArns = shard.filter(allPrincipalArns)
+	slog.Info("freezing principals", "count", len(allPrincipalArns), "shard", shard)
 
 	frozenPrincipals, err := simulator.FreezePrincipals(allPrincipalArns, sopts)
 	if err != nil {
 		cli.Fail("error freezing principals: %v", err)
 	}
 
-	// Open output
-	w, err := smartrw.NewWriter(opts.Out)
-	if err != nil {
-		cli.Fail("error opening output: %v", err)
+	var rowOpts []cli.RowOption
+	if len(opts.Sources) > 0 {
+		rowOpts = append(rowOpts, cli.WithArtifact(opts.Sources[0]))
 	}
-	defer w.Close()
 
-	cw := csv.NewWriter(w)
-	defer cw.Flush()
+	headers := []string{"resource", "action", "principal"}
+	if opts.Denied {
+		headers = append(headers, "result")
+	}
+	if opts.Explain {
+		headers = append(headers, "reason")
+	}
+	headers = append(headers, columns...)
 
-	if err := cw.Write([]string{"resource", "action", "principal"}); err != nil {
-		cli.Fail("error writing CSV header: %v", err)
+	// Open output, along with a checkpoint of progress if requested
+	format := cli.OutputFormat(opts.Out, opts.Format)
+
+	var w io.WriteCloser
+	var ckpt *checkpointer
+	switch {
+	case (opts.Checkpoint || opts.Resume) && checkpointable(opts.Out, format):
+		fp, err := fingerprint(config, opts, headers, sourcesHash)
+		if err != nil {
+			cli.Fail("error fingerprinting audit: %v", err)
+		}
+		ckpt, err = openCheckpointed(opts.Out, fp, opts.Resume)
+		if err != nil {
+			cli.Fail("error opening output: %v", err)
+		}
+		w = ckpt.file
+	case opts.Checkpoint || opts.Resume:
+		cli.Fail("error: -checkpoint and -resume require -out to be a local, uncompressed file in "+
+			"one of: %s", strings.Join(resumableFormats, ", "))
+	default:
+		// the output is replaced, so any checkpoint left beside it by an earlier run is stale
+		if checkpointable(opts.Out, format) {
+			if err := removeCheckpoint(opts.Out); err != nil {
+				cli.Fail("error removing stale checkpoint: %v", err)
+			}
+		}
+		w, err = smartrw.NewWriter(opts.Out)
+		if err != nil {
+			cli.Fail("error opening output: %v", err)
+		}
+	}
+	defer w.Close()
+
+	if ckpt != nil && ckpt.resumed {
+		rowOpts = append(rowOpts, cli.WithoutHeader())
+	}
+	rw, err := cli.NewRowWriter(w, format, headers, rowOpts...)
+	if err != nil {
+		cli.Fail("error opening output: %v", err)
+	}
+	out := &output{
+		rw:                rw,
+		baseline:          baseline,
+		denied:            opts.Denied,
+		explain:           opts.Explain,
+		columns:           columns,
+		principalAccounts: map[string]string{},
+	}
+	for _, p := range frozenPrincipals {
+		out.principalAccounts[p.Arn] = p.AccountId
 	}
 
 	slog.Info("audit starting",
 		"principals", len(frozenPrincipals),
 		"entries", len(config))
 
-	var totalRows int
-	for i, entry := range config {
-		n, err := processEntry(simulator, frozenPrincipals, entry, sopts, cw)
+	products := func(entry ConfigEntry) productFunc {
+		if client != nil {
+			return remoteProduct(
+				client, sourcesHash, entryContext(entry, opts.Context), opts.Denied)
+		}
+		return localProduct(simulator, opts.Denied)
+	}
+
+	a := &auditor{
+		simulator:     simulator,
+		config:        config,
+		principalArns: allPrincipalArns,
+		principals:    frozenPrincipals,
+		context:       opts.Context,
+		opts:          sopts,
+		products:      products,
+	}
+	if err := a.run(out, ckpt); err != nil {
+		cli.Fail("%v", err)
+	}
+
+	if err := rw.Close(); err != nil {
+		cli.Fail("error writing output: %v", err)
+	}
+	if ckpt != nil {
+		if err := ckpt.remove(); err != nil {
+			cli.Fail("error removing checkpoint: %v", err)
+		}
+	}
+
+	slog.Info("audit complete", "rows", out.rows, "suppressed", out.suppressed)
+}
+
+// auditor runs the entries of an audit config against a simulator
+type auditor struct {
+	simulator *sim.Simulator
+	config    []ConfigEntry
+
+	// principalArns and principals are the (sorted and sharded) principals to audit, frozen with
+	// the options which apply to all entries
+	principalArns []string
+	principals    []*entities.FrozenPrincipal
+
+	context map[string]string
+	opts    sim.Options
+
+	// products returns the function used to simulate the products of an entry
+	products func(entry ConfigEntry) productFunc
+}
+
+// run audits every entry in turn, writing results to the output. When checkpointing, a resumed
+// audit continues from the next partition recorded in the checkpoint, while a new one records an
+// initial checkpoint so that it can be resumed from the very start
+func (a *auditor) run(out *output, ckpt *checkpointer) error {
+	var resumeEntry, resumePartition int
+	switch {
+	case ckpt != nil && ckpt.resumed:
+		resumeEntry, resumePartition = ckpt.state.Entry, ckpt.state.Partition
+		out.rows, out.suppressed = ckpt.state.Rows, ckpt.state.Suppressed
+		slog.Info("resuming audit",
+			"entry", resumeEntry,
+			"partition", resumePartition,
+			"rows", out.rows)
+	case ckpt != nil:
+		if err := out.checkpoint(ckpt, 0, 0); err != nil {
+			return fmt.Errorf("error writing checkpoint: %w", err)
+		}
+	}
+
+	for i, entry := range a.config {
+		if i < resumeEntry {
+			continue
+		}
+		start := 0
+		if i == resumeEntry {
+			start = resumePartition
+		}
+
+		entryOpts, err := entryOptions(entry, a.context, a.opts)
 		if err != nil {
-			cli.Fail("error processing entry %d (%s): %v", i, entry.ResourceType, err)
+			return fmt.Errorf("error processing entry %d (%s): %w", i, entry.ResourceType, err)
 		}
-		totalRows += n
+
+		// Overlays may change principals, in which case they are frozen again for this entry
+		principals := a.principals
+		if len(entry.Overlays) > 0 {
+			principals, err = a.simulator.FreezePrincipals(a.principalArns, entryOpts)
+			if err != nil {
+				return fmt.Errorf("error freezing principals for entry %d (%s): %w",
+					i, entry.ResourceType, err)
+			}
+		}
+
+		product := a.products(entry)
+		err = processEntry(a.simulator, product, principals, entry, entryOpts, out, i, start, ckpt)
+		if err != nil {
+			return fmt.Errorf("error processing entry %d (%s): %w", i, entry.ResourceType, err)
+		}
+
+		if ckpt != nil {
+			if err := out.checkpoint(ckpt, i+1, 0); err != nil {
+				return fmt.Errorf("error writing checkpoint: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// entryOptions returns the simulation options for a config entry, adding its context and overlays
+// to those which apply to all entries
+func entryOptions(
+	entry ConfigEntry,
+	context map[string]string,
+	opts sim.Options,
+) (sim.Options, error) {
+	if len(entry.Context) > 0 {
+		sim.WithAdditionalProperties(entryContext(entry, context))(&opts)
+	}
+
+	if len(entry.Overlays) > 0 {
+		overlays, err := cli.LoadOverlays(entry.Overlays)
+		if err != nil {
+			return opts, fmt.Errorf("unable to load overlays: %w", err)
+		}
+
+		// cloned so that the overlays of other entries are unaffected
+		opts.Overlays = slices.Clone(opts.Overlays)
+		for _, overlay := range overlays {
+			opts.Overlays = append(opts.Overlays, overlay.Universe())
+		}
+	}
+
+	return opts, nil
+}
+
+// entryContext returns the request context for a config entry, whose own context takes precedence
+// over that which applies to all entries
+func entryContext(entry ConfigEntry, context map[string]string) map[string]string {
+	if len(entry.Context) == 0 {
+		return context
 	}
 
-	slog.Info("audit complete", "rows", totalRows)
+	merged := maps.Clone(context)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, entry.Context)
+	return merged
 }
 
 // loadConfig reads and parses the audit config JSON file
@@ -147,112 +390,179 @@ func loadConfig(path string) ([]ConfigEntry, error) {
 	return config, nil
 }
 
-// buildSimulator creates a Simulator with data loaded from the specified sources
-func buildSimulator(sources []string) (*sim.Simulator, error) {
-	simulator, err := sim.NewSimulator()
-	if err != nil {
-		return nil, fmt.Errorf("unable to create simulator: %w", err)
+// output writes audit results, excluding those accepted by the baseline
+type output struct {
+	rw       cli.RowWriter
+	baseline *baseline
+	denied   bool
+	explain  bool
+
+	// columns lists the optional columns, whose values are looked up by account ID maps of ARNs;
+	// resource accounts are set for each entry
+	columns           []string
+	principalAccounts map[string]string
+	resourceAccounts  map[string]string
+
+	rows       int
+	suppressed int
+}
+
+// write writes the row for a single result, unless it is excluded
+func (o *output) write(resource string, t sim.AccessTuple) error {
+	result := resultAllow
+	if !t.Result.IsAllowed {
+		result = resultDeny
 	}
 
-	for _, src := range sources {
-		reader, err := smartrw.NewReader(src)
-		if err != nil {
-			return nil, fmt.Errorf("unable to open source '%s': %w", src, err)
-		}
+	if o.baseline.contains(resource, t.Action, t.Principal, result) {
+		o.suppressed++
+		return nil
+	}
 
-		source := server.Source{Reader: *reader}
-		uv, err := source.Universe()
-		if err != nil {
-			return nil, fmt.Errorf("unable to load source '%s': %w", src, err)
+	row := []string{resource, t.Action, t.Principal}
+	if o.denied {
+		row = append(row, result)
+	}
+	if o.explain {
+		var reason string
+		if t.Result.Trace != nil {
+			reason = strings.Join(t.Result.Trace.Explain(), "; ")
 		}
+		row = append(row, reason)
+	}
+	for _, column := range o.columns {
+		row = append(row, o.columnValue(column, resource, t))
+	}
 
-		simulator.Universe.Merge(uv)
-		slog.Info("loaded source", "source", src, "size", simulator.Universe.Size())
+	if err := o.rw.Write(row); err != nil {
+		return fmt.Errorf("error writing row: %w", err)
 	}
+	o.rows++
+	return nil
+}
 
-	return simulator, nil
+// checkpoint flushes the rows written so far and records that everything before the provided
+// partition of the entry is complete
+func (o *output) checkpoint(ckpt *checkpointer, entry, partition int) error {
+	if f, ok := o.rw.(cli.RowFlusher); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+	}
+	return ckpt.save(entry, partition, o)
 }
 
-// processEntry runs the audit for a single config entry, streaming results directly to CSV
+// processEntry runs the audit for a single config entry, streaming the results of the provided
+// product function directly to output. When checkpointing, principals are simulated in
+// partitions, starting from the provided one, and a checkpoint is recorded after each
 func processEntry(
 	simulator *sim.Simulator,
+	product productFunc,
 	frozenPrincipals []*entities.FrozenPrincipal,
 	entry ConfigEntry,
 	opts sim.Options,
-	cw *csv.Writer,
-) (int, error) {
-	// Filter resources by type
+	out *output,
+	index int,
+	start int,
+	ckpt *checkpointer,
+) error {
+	// Filter principals and resources
+	var principals []*entities.FrozenPrincipal
+	for _, p := range frozenPrincipals {
+		if entry.Principals.matches(p) {
+			principals = append(principals, p)
+		}
+	}
+
 	var resourceArns []string
+	out.resourceAccounts = map[string]string{}
 	for r := range simulator.Universe.Resources() {
-		if r.Type == entry.ResourceType {
+		if r.Type == entry.ResourceType && entry.Resources.matches(r) {
 			resourceArns = append(resourceArns, r.Arn)
+			out.resourceAccounts[r.Arn] = r.AccountId
 		}
 	}
 
-	if len(resourceArns) == 0 {
-		slog.Info("no resources found for type", "type", entry.ResourceType)
-		return 0, nil
+	if len(principals) == 0 || len(resourceArns) == 0 {
+		slog.Info("no principals or resources found for entry",
+			"type", entry.ResourceType,
+			"principals", len(principals),
+			"resources", len(resourceArns))
+		return nil
 	}
 
 	// Expand resources (e.g. S3 bucket -> object)
 	expanded, err := simulator.ExpandResources(resourceArns, opts)
 	if err != nil {
-		return 0, fmt.Errorf("unable to expand resources: %w", err)
+		return fmt.Errorf("unable to expand resources: %w", err)
 	}
 
 	// Freeze resources for this entry
 	frozenResources, err := simulator.FreezeResources(expanded, opts)
 	if err != nil {
-		return 0, fmt.Errorf("unable to freeze resources: %w", err)
+		return fmt.Errorf("unable to freeze resources: %w", err)
 	}
 
 	slog.Info("processing entry",
 		"type", entry.ResourceType,
 		"resources", len(frozenResources),
 		"actions", len(entry.Actions),
-		"principals", len(frozenPrincipals))
-
-	// Stream results: dedup and write CSV inline instead of collecting all in memory
-	seen := make(map[string]struct{})
-	var rows int
-	var writeErr error
-
-	err = simulator.ProductFrozenStreaming(
-		frozenPrincipals,
-		entry.Actions,
-		frozenResources,
-		opts,
-		func(t sim.AccessTuple) {
-			if writeErr != nil {
-				return
-			}
+		"principals", len(principals))
 
-			resource := collapseS3Arn(t.Resource)
-			key := resource + "\x00" + t.Action + "\x00" + t.Principal
-			if _, ok := seen[key]; ok {
-				return
-			}
-			seen[key] = struct{}{}
+	size := len(principals)
+	if ckpt != nil {
+		size = partitionSize
+	}
+	partitions := (len(principals) + size - 1) / size
+	before := out.rows
+
+	for p := start; p < partitions; p++ {
+		// Stream results: dedup and write rows inline instead of collecting all in memory. Rows
+		// include the principal, so duplicates never span partitions. Results are part of the key,
+		// so that a bucket with both allowed and denied objects has a row for each
+		seen := make(map[string]struct{})
+		var writeErr error
+
+		err = product(
+			principals[p*size:min((p+1)*size, len(principals))],
+			entry.Actions,
+			frozenResources,
+			opts,
+			func(t sim.AccessTuple) {
+				if writeErr != nil {
+					return
+				}
+
+				resource := collapseS3Arn(t.Resource)
+				key := resource + "\x00" + t.Action + "\x00" + t.Principal + "\x00" +
+					strconv.FormatBool(t.Result.IsAllowed)
+				if _, ok := seen[key]; ok {
+					return
+				}
+				seen[key] = struct{}{}
+
+				writeErr = out.write(resource, t)
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("simulation error: %w", err)
+		}
+		if writeErr != nil {
+			return writeErr
+		}
 
-			if err := cw.Write([]string{resource, t.Action, t.Principal}); err != nil {
-				writeErr = fmt.Errorf("error writing CSV row: %w", err)
-				return
+		if ckpt != nil {
+			if err := out.checkpoint(ckpt, index, p+1); err != nil {
+				return fmt.Errorf("error writing checkpoint: %w", err)
 			}
-			rows++
-		},
-	)
-	if err != nil {
-		return 0, fmt.Errorf("simulation error: %w", err)
-	}
-	if writeErr != nil {
-		return 0, writeErr
+		}
 	}
 
 	slog.Info("entry complete",
 		"type", entry.ResourceType,
-		"allowed", rows)
+		"rows", out.rows-before)
 
-	return rows, nil
+	return nil
 }
 
 // collapseS3Arn strips the object path from S3 object ARNs back to the bucket
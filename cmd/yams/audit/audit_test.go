@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// TestAuditor_DeniedObjects checks that a bucket with both allowed and denied objects has a row for
+// each result, whichever of them is simulated first
+func TestAuditor_DeniedObjects(t *testing.T) {
+	const principal = "arn:aws:iam::111111111111:role/role"
+
+	b := entities.NewBuilder()
+	b.WithPrincipals(entities.Principal{
+		Type:      "AWS::IAM::Role",
+		AccountId: "111111111111",
+		Arn:       principal,
+	})
+	b.WithResources(entities.Resource{
+		Type:      "AWS::S3::Bucket",
+		AccountId: "111111111111",
+		Arn:       "arn:aws:s3:::bucket",
+	})
+
+	simulator, err := sim.NewSimulator()
+	if err != nil {
+		t.Fatalf("error creating simulator: %v", err)
+	}
+	simulator.Universe = b.Build()
+
+	opts := sim.NewOptions()
+	principals, err := simulator.FreezePrincipals([]string{principal}, opts)
+	if err != nil {
+		t.Fatalf("error freezing principals: %v", err)
+	}
+
+	objects := []sim.AccessTuple{
+		{Resource: "arn:aws:s3:::bucket/allowed", Result: &sim.SimResult{IsAllowed: true}},
+		{Resource: "arn:aws:s3:::bucket/denied", Result: &sim.SimResult{IsAllowed: false}},
+		{Resource: "arn:aws:s3:::bucket/also-allowed", Result: &sim.SimResult{IsAllowed: true}},
+	}
+
+	want := "resource,action,principal,result\n" +
+		"arn:aws:s3:::bucket,s3:GetObject," + principal + "," + resultAllow + "\n" +
+		"arn:aws:s3:::bucket,s3:GetObject," + principal + "," + resultDeny + "\n"
+
+	bl, err := loadBaseline(nil)
+	if err != nil {
+		t.Fatalf("loadBaseline() error = %v", err)
+	}
+
+	for _, reversed := range []bool{false, true} {
+		tuples := slices.Clone(objects)
+		if reversed {
+			slices.Reverse(tuples)
+		}
+
+		product := func(
+			_ []*entities.FrozenPrincipal,
+			_ []string,
+			_ []*entities.FrozenResource,
+			_ sim.Options,
+			fn func(sim.AccessTuple),
+		) error {
+			for _, tuple := range tuples {
+				tuple.Principal = principal
+				tuple.Action = "s3:GetObject"
+				fn(tuple)
+			}
+			return nil
+		}
+
+		a := &auditor{
+			simulator: simulator,
+			config: []ConfigEntry{
+				{ResourceType: "AWS::S3::Bucket", Actions: []string{"s3:GetObject"}},
+			},
+			principalArns: []string{principal},
+			principals:    principals,
+			opts:          opts,
+			products:      func(ConfigEntry) productFunc { return product },
+		}
+
+		var buf bytes.Buffer
+		headers := append(slices.Clone(testAuditHeaders), "result")
+		rw, err := cli.NewRowWriter(&buf, "csv", headers)
+		if err != nil {
+			t.Fatalf("NewRowWriter() error = %v", err)
+		}
+		if err := a.run(&output{rw: rw, baseline: bl, denied: true}, nil); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		if err := rw.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		// rows are written in the order they are simulated, so compare them sorted
+		lines := strings.SplitAfter(buf.String(), "\n")
+		slices.Sort(lines[1:])
+		if got := strings.Join(lines, ""); got != want {
+			t.Errorf("reversed = %v: wanted:\n%s\ngot:\n%s", reversed, want, got)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/sim/wildcard"
+)
+
+// baseline holds known-accepted access, which is excluded from audit output. Baselines are CSV
+// files with resource, action and principal columns, such as the output of a previous audit; rows
+// may use * and ? wildcards, and lines starting with # are comments
+type baseline struct {
+	// exact holds entries without wildcards, keyed by baselineKey
+	exact map[string]struct{}
+
+	// patterns holds entries with wildcards, which must be matched one by one
+	patterns []baselineEntry
+}
+
+type baselineEntry struct {
+	resource, action, principal, result string
+}
+
+// baselineKey returns the lookup key for an entry; actions are case-insensitive
+func baselineKey(resource, action, principal, result string) string {
+	return strings.Join([]string{resource, strings.ToLower(action), principal, result}, "\x00")
+}
+
+// loadBaseline reads and combines the baseline files at the provided paths
+func loadBaseline(paths []string) (*baseline, error) {
+	b := &baseline{exact: map[string]struct{}{}}
+	for _, path := range paths {
+		if err := b.load(path); err != nil {
+			return nil, fmt.Errorf("unable to load baseline '%s': %w", path, err)
+		}
+	}
+	return b, nil
+}
+
+func (b *baseline) load(path string) error {
+	reader, err := smartrw.NewReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	r := csv.NewReader(reader)
+	r.Comment = '#'
+
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("unable to read header: %w", err)
+	}
+
+	// an optional result column allows denied access to be baselined; without one, every row is
+	// accepted allowed access
+	columns := map[string]int{"resource": -1, "action": -1, "principal": -1, "result": -1}
+	for i, h := range header {
+		if _, ok := columns[strings.ToLower(h)]; ok {
+			columns[strings.ToLower(h)] = i
+		}
+	}
+	for _, name := range []string{"resource", "action", "principal"} {
+		if columns[name] < 0 {
+			return fmt.Errorf("missing '%s' column", name)
+		}
+	}
+
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		e := baselineEntry{
+			resource:  row[columns["resource"]],
+			action:    row[columns["action"]],
+			principal: row[columns["principal"]],
+			result:    resultAllow,
+		}
+		if i := columns["result"]; i >= 0 {
+			e.result = strings.ToUpper(row[i])
+		}
+
+		if strings.ContainsAny(e.resource+e.action+e.principal, "*?") {
+			b.patterns = append(b.patterns, e)
+		} else {
+			b.exact[baselineKey(e.resource, e.action, e.principal, e.result)] = struct{}{}
+		}
+	}
+}
+
+// contains determines whether the access is accepted by the baseline
+func (b *baseline) contains(resource, action, principal, result string) bool {
+	if _, ok := b.exact[baselineKey(resource, action, principal, result)]; ok {
+		return true
+	}
+
+	for _, e := range b.patterns {
+		if e.result == result &&
+			wildcard.MatchString(e.resource, resource) &&
+			wildcard.MatchSegmentsIgnoreCase(e.action, action) &&
+			wildcard.MatchString(e.principal, principal) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBaseline_Contains(t *testing.T) {
+	type access struct {
+		resource, action, principal, result string
+	}
+
+	tests := []struct {
+		name     string
+		files    []string
+		accepted []access
+		rejected []access
+	}{
+		{
+			name: "exact",
+			files: []string{`Resource,Action,Principal
+arn:aws:s3:::logs,s3:GetObject,arn:aws:iam::1:role/reader
+`},
+			accepted: []access{
+				{"arn:aws:s3:::logs", "s3:GetObject", "arn:aws:iam::1:role/reader", "ALLOW"},
+				{"arn:aws:s3:::logs", "S3:GETOBJECT", "arn:aws:iam::1:role/reader", "ALLOW"},
+			},
+			rejected: []access{
+				{"arn:aws:s3:::logs", "s3:GetObject", "arn:aws:iam::1:role/reader", "DENY"},
+				{"arn:aws:s3:::logs", "s3:PutObject", "arn:aws:iam::1:role/reader", "ALLOW"},
+				{"arn:aws:s3:::LOGS", "s3:GetObject", "arn:aws:iam::1:role/reader", "ALLOW"},
+			},
+		},
+		{
+			name: "columns_in_any_order_with_extras",
+			files: []string{`principal,Account,action,RESOURCE
+arn:aws:iam::1:role/reader,1,s3:GetObject,arn:aws:s3:::logs
+`},
+			accepted: []access{
+				{"arn:aws:s3:::logs", "s3:GetObject", "arn:aws:iam::1:role/reader", "ALLOW"},
+			},
+		},
+		{
+			name: "wildcards",
+			files: []string{`Resource,Action,Principal
+arn:aws:s3:::logs-*,s3:Get*,arn:aws:iam::*:role/reader
+*,sqs:SendMessage,arn:aws:iam::1:role/?riter
+`},
+			accepted: []access{
+				{"arn:aws:s3:::logs-2024", "s3:getobject", "arn:aws:iam::2:role/reader", "ALLOW"},
+				{"arn:aws:sqs:::q", "sqs:SendMessage", "arn:aws:iam::1:role/writer", "ALLOW"},
+			},
+			rejected: []access{
+				{"arn:aws:s3:::other", "s3:GetObject", "arn:aws:iam::2:role/reader", "ALLOW"},
+				{"arn:aws:s3:::logs-2024", "s3:PutObject", "arn:aws:iam::2:role/reader", "ALLOW"},
+				{"arn:aws:s3:::logs-2024", "s3:GetObject", "arn:aws:iam::2:role/reader", "DENY"},
+			},
+		},
+		{
+			name: "result_column",
+			files: []string{`Resource,Action,Principal,Result
+arn:aws:s3:::logs,s3:GetObject,arn:aws:iam::1:role/reader,deny
+arn:aws:s3:::logs,s3:ListBucket,arn:aws:iam::1:role/*,ALLOW
+`},
+			accepted: []access{
+				{"arn:aws:s3:::logs", "s3:GetObject", "arn:aws:iam::1:role/reader", "DENY"},
+				{"arn:aws:s3:::logs", "s3:ListBucket", "arn:aws:iam::1:role/x", "ALLOW"},
+			},
+			rejected: []access{
+				{"arn:aws:s3:::logs", "s3:GetObject", "arn:aws:iam::1:role/reader", "ALLOW"},
+				{"arn:aws:s3:::logs", "s3:ListBucket", "arn:aws:iam::1:role/x", "DENY"},
+			},
+		},
+		{
+			name: "comments",
+			files: []string{`# accepted access, reviewed 2024-01-01
+Resource,Action,Principal
+# arn:aws:s3:::secret,s3:GetObject,arn:aws:iam::1:role/reader
+arn:aws:s3:::logs,s3:GetObject,arn:aws:iam::1:role/reader
+`},
+			accepted: []access{
+				{"arn:aws:s3:::logs", "s3:GetObject", "arn:aws:iam::1:role/reader", "ALLOW"},
+			},
+			rejected: []access{
+				{"arn:aws:s3:::secret", "s3:GetObject", "arn:aws:iam::1:role/reader", "ALLOW"},
+			},
+		},
+		{
+			name: "multiple_files",
+			files: []string{
+				"Resource,Action,Principal\narn:aws:s3:::a,s3:GetObject,arn:aws:iam::1:role/r\n",
+				"Resource,Action,Principal\narn:aws:s3:::b,s3:GetObject,arn:aws:iam::1:role/r\n",
+			},
+			accepted: []access{
+				{"arn:aws:s3:::a", "s3:GetObject", "arn:aws:iam::1:role/r", "ALLOW"},
+				{"arn:aws:s3:::b", "s3:GetObject", "arn:aws:iam::1:role/r", "ALLOW"},
+			},
+		},
+		{
+			name:  "header_only",
+			files: []string{"Resource,Action,Principal\n"},
+			rejected: []access{
+				{"arn:aws:s3:::logs", "s3:GetObject", "arn:aws:iam::1:role/reader", "ALLOW"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var paths []string
+			for i, content := range tc.files {
+				name := fmt.Sprintf("baseline-%d.csv", i)
+				paths = append(paths, writeFile(t, name, content))
+			}
+
+			b, err := loadBaseline(paths)
+			if err != nil {
+				t.Fatalf("loadBaseline() error = %v", err)
+			}
+			for _, a := range tc.accepted {
+				if !b.contains(a.resource, a.action, a.principal, a.result) {
+					t.Errorf("expected baseline to accept %+v", a)
+				}
+			}
+			for _, a := range tc.rejected {
+				if b.contains(a.resource, a.action, a.principal, a.result) {
+					t.Errorf("expected baseline not to accept %+v", a)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBaseline_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "empty", content: "", wantErr: "unable to read header"},
+		{name: "only_comments", content: "# nothing here\n", wantErr: "unable to read header"},
+		{
+			name:    "missing_principal",
+			content: "Resource,Action\narn:aws:s3:::logs,s3:GetObject\n",
+			wantErr: "missing 'principal' column",
+		},
+		{
+			name:    "missing_resource",
+			content: "Action,Principal,Result\n",
+			wantErr: "missing 'resource' column",
+		},
+		{
+			name:    "ragged_row",
+			content: "Resource,Action,Principal\narn:aws:s3:::logs,s3:GetObject\n",
+			wantErr: "wrong number of fields",
+		},
+		{
+			name:    "bad_quoting",
+			content: "Resource,Action,Principal\n\"arn:aws:s3:::logs,s3:GetObject,x\n",
+			wantErr: "extraneous or missing \" in quoted-field",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeFile(t, "baseline.csv", tc.content)
+			_, err := loadBaseline([]string{path})
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+			if !strings.Contains(err.Error(), path) {
+				t.Errorf("expected error to name the baseline file, got %v", err)
+			}
+		})
+	}
+
+	t.Run("missing_file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing.csv")
+		if _, err := loadBaseline([]string{path}); err == nil {
+			t.Fatal("expected error for missing baseline file")
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/smartrw"
+)
+
+// checkpointSuffix is appended to the output path to name its checkpoint file
+const checkpointSuffix = ".checkpoint"
+
+// partitionSize is the number of principals simulated between checkpoints
+var partitionSize = 500
+
+// resumableFormats lists the formats whose output is complete after each row, and can therefore
+// be appended to when resuming
+var resumableFormats = []string{cli.FormatCSV, cli.FormatNDJSON, cli.FormatMarkdown}
+
+// checkpoint records the progress of an audit so that an interrupted run can be resumed. Each
+// config entry's principals are divided into partitions, and a checkpoint is saved once the rows of
+// a partition have been flushed to the output
+type checkpoint struct {
+	// Fingerprint identifies the config and settings of the audit; a checkpoint may only be resumed
+	// by an identical audit
+	Fingerprint string `json:"fingerprint"`
+
+	// Entry and Partition identify the next partition to simulate
+	Entry     int `json:"entry"`
+	Partition int `json:"partition"`
+
+	// Offset is the length of the output as of the checkpoint; anything written afterwards is
+	// discarded when resuming
+	Offset int64 `json:"offset"`
+
+	// Rows and Suppressed are the counts of the output as of the checkpoint
+	Rows       int `json:"rows"`
+	Suppressed int `json:"suppressed"`
+}
+
+// checkpointer writes audit output to a local file, recording progress beside it
+type checkpointer struct {
+	file  *os.File
+	path  string
+	state checkpoint
+
+	// resumed is set when continuing the output of an earlier run
+	resumed bool
+}
+
+// checkpointable determines whether output to the destination can be checkpointed, which requires
+// a local, uncompressed file in a format that can be appended to
+func checkpointable(dest, format string) bool {
+	if dest == "" || strings.HasSuffix(dest, ".gz") {
+		return false
+	}
+	if strings.Contains(dest, "://") && !strings.HasPrefix(dest, "file://") {
+		return false
+	}
+	return slices.Contains(resumableFormats, format)
+}
+
+// fingerprint hashes the settings and inputs which determine an audit's output, so that a
+// checkpoint is not resumed by a different audit. Sources are identified by the hash of their
+// content, as computed when loading them, and baselines and overlays are hashed here, so that
+// inputs refreshed in place also prevent resuming
+func fingerprint(
+	config []ConfigEntry,
+	opts *cli.Flags,
+	headers []string,
+	sourcesHash string,
+) (string, error) {
+	baselinesHash, err := hashContents(opts.Baselines)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash baselines: %w", err)
+	}
+
+	overlays := slices.Clone(opts.OverlayFiles)
+	for _, entry := range config {
+		overlays = append(overlays, entry.Overlays...)
+	}
+	overlaysHash, err := hashContents(overlays)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash overlays: %w", err)
+	}
+
+	b, err := json.Marshal(struct {
+		Config        []ConfigEntry
+		Sources       []string
+		SourcesHash   string
+		Baselines     []string
+		BaselinesHash string
+		Overlays      []string
+		OverlaysHash  string
+		Context       map[string]string
+		Headers       []string
+		Shard         string
+	}{
+		config,
+		opts.Sources, sourcesHash,
+		opts.Baselines, baselinesHash,
+		opts.OverlayFiles, overlaysHash,
+		opts.Context, headers, opts.Shard,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// hashContents hashes the decompressed content of each of the files, in order
+func hashContents(paths []string) (string, error) {
+	combined := sha256.New()
+	for _, path := range paths {
+		reader, err := smartrw.NewReader(path)
+		if err != nil {
+			return "", fmt.Errorf("unable to open '%s': %w", path, err)
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(h, reader)
+		reader.Close()
+		if err != nil {
+			return "", fmt.Errorf("unable to read '%s': %w", path, err)
+		}
+		combined.Write(h.Sum(nil))
+	}
+
+	return hex.EncodeToString(combined.Sum(nil)), nil
+}
+
+// openCheckpointed opens a local output file along with its checkpoint. When resuming, the output
+// is truncated to the length recorded in the checkpoint, discarding any rows written after it
+func openCheckpointed(dest, fingerprint string, resume bool) (*checkpointer, error) {
+	path := strings.TrimPrefix(dest, "file://")
+	c := &checkpointer{
+		path:  checkpointPath(dest),
+		state: checkpoint{Fingerprint: fingerprint},
+	}
+
+	if !resume {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open file: %w", err)
+		}
+		c.file = file
+		return c, nil
+	}
+
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no checkpoint found at '%s'", c.path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		return nil, fmt.Errorf("unable to parse checkpoint '%s': %w", c.path, err)
+	}
+	if c.state.Fingerprint != fingerprint {
+		return nil, fmt.Errorf("checkpoint '%s' was created by an audit with different settings",
+			c.path)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %w", err)
+	}
+	if err := file.Truncate(c.state.Offset); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to truncate output: %w", err)
+	}
+	if _, err := file.Seek(c.state.Offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to seek output: %w", err)
+	}
+
+	c.file = file
+	c.resumed = true
+	return c, nil
+}
+
+// save records that everything before the provided partition is complete. The output must have
+// been flushed beforehand; it is synced so that the checkpoint never refers to lost data
+func (c *checkpointer) save(entry, partition int, out *output) error {
+	if err := c.file.Sync(); err != nil {
+		return fmt.Errorf("unable to sync output: %w", err)
+	}
+	offset, err := c.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("unable to determine output length: %w", err)
+	}
+
+	c.state.Entry = entry
+	c.state.Partition = partition
+	c.state.Offset = offset
+	c.state.Rows = out.rows
+	c.state.Suppressed = out.suppressed
+
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+
+	// written to a temporary file first, so that an interruption cannot corrupt the checkpoint
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the checkpoint once the audit is complete
+func (c *checkpointer) remove() error {
+	err := os.Remove(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// checkpointPath returns the path of the checkpoint beside a local output
+func checkpointPath(dest string) string {
+	return strings.TrimPrefix(dest, "file://") + checkpointSuffix
+}
+
+// removeCheckpoint deletes the checkpoint beside a local output, if one exists
+func removeCheckpoint(dest string) error {
+	return (&checkpointer{path: checkpointPath(dest)}).remove()
+}
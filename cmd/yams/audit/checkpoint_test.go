@@ -0,0 +1,506 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/pkg/distributed"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// errInterrupted is returned by the product of an interrupted audit
+var errInterrupted = errors.New("interrupted")
+
+// testAuditHeaders are the headers of an audit without optional columns
+var testAuditHeaders = []string{"resource", "action", "principal"}
+
+// newTestAuditor returns an auditor of two entries over the provided number of principals, whose
+// products are simulated deterministically. The product fails on its failAt'th call, counting from
+// 1, or never if failAt is 0
+func newTestAuditor(t *testing.T, numPrincipals, failAt int) *auditor {
+	t.Helper()
+
+	b := entities.NewBuilder()
+	var arns []string
+	for i := range numPrincipals {
+		arn := fmt.Sprintf("arn:aws:iam::111111111111:role/role-%02d", i)
+		arns = append(arns, arn)
+		b.WithPrincipals(entities.Principal{
+			Type:      "AWS::IAM::Role",
+			AccountId: "111111111111",
+			Arn:       arn,
+		})
+	}
+	b.WithResources(
+		entities.Resource{
+			Type:      "AWS::SQS::Queue",
+			AccountId: "111111111111",
+			Arn:       "arn:aws:sqs:us-east-1:111111111111:queue",
+		},
+		entities.Resource{
+			Type:      "AWS::SNS::Topic",
+			AccountId: "111111111111",
+			Arn:       "arn:aws:sns:us-east-1:111111111111:topic",
+		},
+	)
+
+	simulator, err := sim.NewSimulator()
+	if err != nil {
+		t.Fatalf("error creating simulator: %v", err)
+	}
+	simulator.Universe = b.Build()
+
+	opts := sim.NewOptions()
+	principals, err := simulator.FreezePrincipals(arns, opts)
+	if err != nil {
+		t.Fatalf("error freezing principals: %v", err)
+	}
+
+	calls := 0
+	product := func(
+		principals []*entities.FrozenPrincipal,
+		actions []string,
+		resources []*entities.FrozenResource,
+		_ sim.Options,
+		fn func(sim.AccessTuple),
+	) error {
+		calls++
+		for i, p := range principals {
+			// an interrupted product has already written some of its rows
+			if calls == failAt && i == len(principals)/2 {
+				return errInterrupted
+			}
+			for _, a := range actions {
+				for _, r := range resources {
+					fn(sim.AccessTuple{
+						Principal: p.Arn,
+						Action:    a,
+						Resource:  r.Arn,
+						Result:    &sim.SimResult{IsAllowed: true},
+					})
+				}
+			}
+		}
+		return nil
+	}
+
+	return &auditor{
+		simulator: simulator,
+		config: []ConfigEntry{
+			{
+				ResourceType: "AWS::SQS::Queue",
+				Actions:      []string{"sqs:SendMessage", "sqs:GetQueueUrl"},
+			},
+			{ResourceType: "AWS::SNS::Topic", Actions: []string{"sns:Publish"}},
+		},
+		principalArns: arns,
+		principals:    principals,
+		opts:          opts,
+		products:      func(ConfigEntry) productFunc { return product },
+	}
+}
+
+// testBaseline suppresses the access of one principal, so that suppressed counts are checkpointed
+func testBaseline(t *testing.T) *baseline {
+	t.Helper()
+
+	path := writeFile(t, "baseline.csv",
+		"resource,action,principal\n*,*,arn:aws:iam::111111111111:role/role-03\n")
+	b, err := loadBaseline([]string{path})
+	if err != nil {
+		t.Fatalf("loadBaseline() error = %v", err)
+	}
+	return b
+}
+
+// runCheckpointed runs an audit in the same way as Run with -checkpoint or -resume. If the audit
+// fails, rows written since the last checkpoint are flushed to the output, as if the process had
+// been killed after writing them
+func runCheckpointed(
+	t *testing.T,
+	a *auditor,
+	dest, format string,
+	resume bool,
+	bl *baseline,
+) (*output, error) {
+	t.Helper()
+
+	ckpt, err := openCheckpointed(dest, "fingerprint", resume)
+	if err != nil {
+		return nil, err
+	}
+	defer ckpt.file.Close()
+
+	var rowOpts []cli.RowOption
+	if ckpt.resumed {
+		rowOpts = append(rowOpts, cli.WithoutHeader())
+	}
+	rw, err := cli.NewRowWriter(ckpt.file, format, testAuditHeaders, rowOpts...)
+	if err != nil {
+		t.Fatalf("NewRowWriter() error = %v", err)
+	}
+
+	out := &output{rw: rw, baseline: bl}
+	if err := a.run(out, ckpt); err != nil {
+		if f, ok := rw.(cli.RowFlusher); ok {
+			f.Flush()
+		}
+		return out, err
+	}
+
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return out, ckpt.remove()
+}
+
+func TestCheckpoint_ResumeIsIdentical(t *testing.T) {
+	defer func(old int) { partitionSize = old }(partitionSize)
+	partitionSize = 2
+
+	// with 5 principals and 2 entries, each entry has 3 partitions: calls 1-3 simulate the first
+	// entry and calls 4-6 the second
+	tests := []struct {
+		name    string
+		failAts []int
+	}{
+		{name: "first_partition", failAts: []int{1}},
+		{name: "middle_partition", failAts: []int{2}},
+		{name: "last_partition_of_entry", failAts: []int{3}},
+		{name: "first_partition_of_next_entry", failAts: []int{4}},
+		{name: "last_partition", failAts: []int{6}},
+		{name: "interrupted_twice", failAts: []int{2, 3}},
+		{name: "interrupted_on_every_partition", failAts: []int{1, 1, 1, 1, 1, 1}},
+	}
+
+	for _, format := range resumableFormats {
+		bl := testBaseline(t)
+
+		// the uninterrupted audit which every resumed audit must reproduce
+		want := filepath.Join(t.TempDir(), "want."+format)
+		wantOut, err := runCheckpointed(t, newTestAuditor(t, 5, 0), want, format, false, bl)
+		if err != nil {
+			t.Fatalf("uninterrupted audit error = %v", err)
+		}
+		wantBytes, err := os.ReadFile(want)
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		if wantOut.rows == 0 || wantOut.suppressed == 0 {
+			t.Fatalf("expected rows and suppressed rows, got %d and %d",
+				wantOut.rows, wantOut.suppressed)
+		}
+
+		for _, tc := range tests {
+			t.Run(format+"/"+tc.name, func(t *testing.T) {
+				dest := filepath.Join(t.TempDir(), "access."+format)
+
+				resume := false
+				for _, failAt := range tc.failAts {
+					a := newTestAuditor(t, 5, failAt)
+					_, err := runCheckpointed(t, a, dest, format, resume, bl)
+					if !errors.Is(err, errInterrupted) {
+						t.Fatalf("expected interrupted audit, got %v", err)
+					}
+					if _, err := os.Stat(checkpointPath(dest)); err != nil {
+						t.Fatalf("expected checkpoint after interruption: %v", err)
+					}
+					resume = true
+				}
+
+				out, err := runCheckpointed(t, newTestAuditor(t, 5, 0), dest, format, true, bl)
+				if err != nil {
+					t.Fatalf("resumed audit error = %v", err)
+				}
+
+				got, err := os.ReadFile(dest)
+				if err != nil {
+					t.Fatalf("failed to read output: %v", err)
+				}
+				if !bytes.Equal(got, wantBytes) {
+					t.Errorf("resumed output differs\n--- got ---\n%s\n--- want ---\n%s",
+						got, wantBytes)
+				}
+				if out.rows != wantOut.rows || out.suppressed != wantOut.suppressed {
+					t.Errorf("wanted %d rows and %d suppressed, got %d and %d",
+						wantOut.rows, wantOut.suppressed, out.rows, out.suppressed)
+				}
+				if _, err := os.Stat(checkpointPath(dest)); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("expected checkpoint to be removed after success, got %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestOpenCheckpointed_Truncates(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "access.csv")
+	if err := os.WriteFile(dest, []byte("complete\npartial"), 0644); err != nil {
+		t.Fatalf("failed to write output: %v", err)
+	}
+	state := `{"fingerprint": "fp", "entry": 1, "partition": 2, "offset": 9, "rows": 4}`
+	if err := os.WriteFile(checkpointPath(dest), []byte(state), 0644); err != nil {
+		t.Fatalf("failed to write checkpoint: %v", err)
+	}
+
+	ckpt, err := openCheckpointed("file://"+dest, "fp", true)
+	if err != nil {
+		t.Fatalf("openCheckpointed() error = %v", err)
+	}
+	if !ckpt.resumed || ckpt.state.Entry != 1 || ckpt.state.Partition != 2 || ckpt.state.Rows != 4 {
+		t.Errorf("unexpected checkpoint state: %+v", ckpt.state)
+	}
+	if _, err := ckpt.file.WriteString("appended\n"); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+	ckpt.file.Close()
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if want := "complete\nappended\n"; string(got) != want {
+		t.Fatalf("wanted output %q, got %q", want, got)
+	}
+}
+
+func TestOpenCheckpointed_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkpoint string
+		wantErr    string
+	}{
+		{name: "missing", wantErr: "no checkpoint found"},
+		{name: "corrupt", checkpoint: `{"fingerprint": `, wantErr: "unable to parse checkpoint"},
+		{
+			name:       "fingerprint_mismatch",
+			checkpoint: `{"fingerprint": "other", "offset": 0}`,
+			wantErr:    "different settings",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "access.csv")
+			if err := os.WriteFile(dest, []byte("rows\n"), 0644); err != nil {
+				t.Fatalf("failed to write output: %v", err)
+			}
+			if tc.checkpoint != "" {
+				err := os.WriteFile(checkpointPath(dest), []byte(tc.checkpoint), 0644)
+				if err != nil {
+					t.Fatalf("failed to write checkpoint: %v", err)
+				}
+			}
+
+			_, err := openCheckpointed(dest, "fp", true)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+
+			// the output is left untouched
+			if got, _ := os.ReadFile(dest); string(got) != "rows\n" {
+				t.Errorf("expected output to be unchanged, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRemoveCheckpoint(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "access.csv")
+	if err := removeCheckpoint(dest); err != nil {
+		t.Fatalf("removeCheckpoint() without checkpoint error = %v", err)
+	}
+
+	if err := os.WriteFile(checkpointPath(dest), []byte("{}"), 0644); err != nil {
+		t.Fatalf("failed to write checkpoint: %v", err)
+	}
+	if err := removeCheckpoint("file://" + dest); err != nil {
+		t.Fatalf("removeCheckpoint() error = %v", err)
+	}
+	if _, err := os.Stat(checkpointPath(dest)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected checkpoint to be removed, got %v", err)
+	}
+}
+
+func TestCheckpointable(t *testing.T) {
+	tests := []struct {
+		dest   string
+		format string
+		want   bool
+	}{
+		{dest: "access.csv", format: cli.FormatCSV, want: true},
+		{dest: "file:///tmp/access.ndjson", format: cli.FormatNDJSON, want: true},
+		{dest: "access.md", format: cli.FormatMarkdown, want: true},
+		{dest: "", format: cli.FormatCSV, want: false},
+		{dest: "access.csv.gz", format: cli.FormatCSV, want: false},
+		{dest: "s3://bucket/access.csv", format: cli.FormatCSV, want: false},
+		{dest: "access.json", format: cli.FormatJSON, want: false},
+		{dest: "access.sarif", format: cli.FormatSARIF, want: false},
+		{dest: "access.parquet", format: cli.FormatParquet, want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.dest+"/"+tc.format, func(t *testing.T) {
+			if got := checkpointable(tc.dest, tc.format); got != tc.want {
+				t.Fatalf("checkpointable(%q, %q) = %v, want %v", tc.dest, tc.format, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	config := []ConfigEntry{{ResourceType: "AWS::S3::Bucket", Actions: []string{"s3:GetObject"}}}
+	base := func() *cli.Flags {
+		return &cli.Flags{Sources: cli.MultiString{"a.jsonl"}, Shard: "1/2"}
+	}
+
+	want, err := fingerprint(config, base(), testAuditHeaders, "hash")
+	if err != nil {
+		t.Fatalf("fingerprint() error = %v", err)
+	}
+
+	// the destination and resume flags do not affect the fingerprint
+	same := base()
+	same.Out, same.Resume, same.Checkpoint = "other.csv", true, true
+	if got, _ := fingerprint(config, same, testAuditHeaders, "hash"); got != want {
+		t.Errorf("expected identical fingerprint, got %s and %s", got, want)
+	}
+
+	changes := map[string]func(*cli.Flags) ([]ConfigEntry, []string){
+		"shard": func(f *cli.Flags) ([]ConfigEntry, []string) {
+			f.Shard = "2/2"
+			return config, testAuditHeaders
+		},
+		"sources": func(f *cli.Flags) ([]ConfigEntry, []string) {
+			f.Sources = append(f.Sources, "b.jsonl")
+			return config, testAuditHeaders
+		},
+		"context": func(f *cli.Flags) ([]ConfigEntry, []string) {
+			f.Context = cli.MapString{"aws:SourceIp": "10.0.0.1"}
+			return config, testAuditHeaders
+		},
+		"config": func(f *cli.Flags) ([]ConfigEntry, []string) {
+			return []ConfigEntry{{ResourceType: "AWS::S3::Bucket", Actions: []string{"s3:*"}}},
+				testAuditHeaders
+		},
+		"headers": func(f *cli.Flags) ([]ConfigEntry, []string) {
+			return config, append(testAuditHeaders[:3:3], "result")
+		},
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			opts := base()
+			config, headers := change(opts)
+			if got, _ := fingerprint(config, opts, headers, "hash"); got == want {
+				t.Errorf("expected fingerprint to change with %s", name)
+			}
+		})
+	}
+
+	if got, _ := fingerprint(config, base(), testAuditHeaders, "other"); got == want {
+		t.Errorf("expected fingerprint to change with the content of the sources")
+	}
+}
+
+func TestFingerprint_Contents(t *testing.T) {
+	baselinePath := writeFile(t, "baseline.csv", "resource,action,principal\n*,*,a\n")
+	overlayPath := writeFile(t, "overlay.json", `{"principals": []}`)
+	entryOverlayPath := writeFile(t, "entry-overlay.json", `{"resources": []}`)
+
+	config := []ConfigEntry{{
+		ResourceType: "AWS::S3::Bucket",
+		Actions:      []string{"s3:GetObject"},
+		Overlays:     []string{entryOverlayPath},
+	}}
+	opts := &cli.Flags{
+		Baselines:    cli.MultiString{baselinePath},
+		OverlayFiles: cli.MultiString{overlayPath},
+	}
+
+	want, err := fingerprint(config, opts, testAuditHeaders, "hash")
+	if err != nil {
+		t.Fatalf("fingerprint() error = %v", err)
+	}
+
+	// rewriting any input in place, even with the same path, changes the fingerprint
+	for _, path := range []string{baselinePath, overlayPath, entryOverlayPath} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			original, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read input: %v", err)
+			}
+			defer os.WriteFile(path, original, 0644)
+
+			if err := os.WriteFile(path, append(original, '\n'), 0644); err != nil {
+				t.Fatalf("failed to rewrite input: %v", err)
+			}
+			got, err := fingerprint(config, opts, testAuditHeaders, "hash")
+			if err != nil {
+				t.Fatalf("fingerprint() error = %v", err)
+			}
+			if got == want {
+				t.Errorf("expected fingerprint to change with the content of %s", path)
+			}
+		})
+	}
+
+	missing := &cli.Flags{Baselines: cli.MultiString{filepath.Join(t.TempDir(), "missing.csv")}}
+	if _, err := fingerprint(config, missing, testAuditHeaders, "hash"); err == nil {
+		t.Errorf("expected error fingerprinting a missing baseline")
+	}
+}
+
+func TestCheckpoint_ResumeRefusedAfterSourceChange(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "awsconfig.json")
+	dest := filepath.Join(dir, "access.csv")
+	config := []ConfigEntry{{ResourceType: "AWS::S3::Bucket", Actions: []string{"s3:GetObject"}}}
+	opts := &cli.Flags{Sources: cli.MultiString{source}}
+
+	// fingerprintSource fingerprints the audit as Run does, with the current content of the source
+	fingerprintSource := func(content string) string {
+		t.Helper()
+
+		if err := os.WriteFile(source, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write source: %v", err)
+		}
+		_, hash, err := distributed.LoadSources(opts.Sources)
+		if err != nil {
+			t.Fatalf("LoadSources() error = %v", err)
+		}
+		fp, err := fingerprint(config, opts, testAuditHeaders, hash)
+		if err != nil {
+			t.Fatalf("fingerprint() error = %v", err)
+		}
+		return fp
+	}
+
+	ckpt, err := openCheckpointed(dest, fingerprintSource("[]"), false)
+	if err != nil {
+		t.Fatalf("openCheckpointed() error = %v", err)
+	}
+	if err := ckpt.save(0, 1, &output{}); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	ckpt.file.Close()
+
+	// the same path and settings, but refreshed content
+	_, err = openCheckpointed(dest, fingerprintSource("[ ]"), true)
+	if err == nil || !strings.Contains(err.Error(), "different settings") {
+		t.Fatalf("expected resume to be refused after the source changed, got %v", err)
+	}
+
+	// restoring the original content allows the audit to be resumed
+	ckpt, err = openCheckpointed(dest, fingerprintSource("[]"), true)
+	if err != nil {
+		t.Fatalf("expected resume with the original source to succeed, got %v", err)
+	}
+	ckpt.file.Close()
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nsiow/yams/pkg/aws/sar"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// Optional columns which may be added to audit output with -column
+const (
+	columnPrincipalAccount = "principal_account"
+	columnResourceAccount  = "resource_account"
+	columnAccessLevel      = "access_level"
+	columnAllowingPolicy   = "allowing_policy"
+)
+
+// extraColumns lists the optional columns, in the order they are written
+var extraColumns = []string{
+	columnPrincipalAccount,
+	columnResourceAccount,
+	columnAccessLevel,
+	columnAllowingPolicy,
+}
+
+// allowPrefix begins the trace messages of principal policies which allow access
+const allowPrefix = "allow in "
+
+// validateColumns checks that each requested column is known, returning them in output order
+func validateColumns(columns []string) ([]string, error) {
+	for _, c := range columns {
+		if !slices.Contains(extraColumns, c) {
+			return nil, fmt.Errorf("unknown column '%s', must be one of: %s",
+				c, strings.Join(extraColumns, ", "))
+		}
+	}
+
+	var ordered []string
+	for _, c := range extraColumns {
+		if slices.Contains(columns, c) {
+			ordered = append(ordered, c)
+		}
+	}
+	return ordered, nil
+}
+
+// columnValue returns the value of an optional column for a result
+func (o *output) columnValue(column, resource string, t sim.AccessTuple) string {
+	switch column {
+	case columnPrincipalAccount:
+		return o.principalAccounts[t.Principal]
+	case columnResourceAccount:
+		return o.resourceAccounts[resource]
+	case columnAccessLevel:
+		if action, ok := sar.LookupString(t.Action); ok {
+			return action.AccessLevel
+		}
+	case columnAllowingPolicy:
+		if t.Result.IsAllowed && t.Result.Trace != nil {
+			return allowingPolicies(t.Result.Trace.Explain())
+		}
+	}
+	return ""
+}
+
+// allowingPolicies extracts the policies which allowed access from the explanation of a decision,
+// such as "arn:aws:iam::aws:policy/ReadOnlyAccess"; policies are separated by commas, and access
+// granted by a resource policy is reported as "resource policy"
+func allowingPolicies(explain []string) string {
+	var policies []string
+	for _, msg := range explain {
+		if name, ok := strings.CutPrefix(msg, allowPrefix); ok {
+			if _, policy, ok := strings.Cut(name, ": "); ok && !slices.Contains(policies, policy) {
+				policies = append(policies, policy)
+			}
+		}
+	}
+
+	// the final message summarizes the decision, naming resource grants
+	if len(explain) > 0 && strings.Contains(explain[len(explain)-1], "resource") {
+		policies = append(policies, "resource policy")
+	}
+	return strings.Join(policies, ",")
+}
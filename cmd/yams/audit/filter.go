@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/sim/wildcard"
+)
+
+// PrincipalFilter restricts which principals are audited for an entry. Every field which is set
+// must match, while any one of the values within a field may match; values other than account IDs
+// may contain * and ? wildcards
+type PrincipalFilter struct {
+	// Accounts lists the account IDs in which principals reside
+	Accounts []string `json:"accounts"`
+
+	// Tags maps tag keys to the values which principals must be tagged with
+	Tags map[string]string `json:"tags"`
+
+	// Paths lists IAM paths, such as /service-role/
+	Paths []string `json:"paths"`
+
+	// Arns lists principal ARNs, such as arn:aws:iam::*:role/deploy-*
+	Arns []string `json:"arns"`
+}
+
+// matches determines whether the principal passes the filter
+func (f *PrincipalFilter) matches(p *entities.FrozenPrincipal) bool {
+	if len(f.Accounts) > 0 && !slices.Contains(f.Accounts, p.AccountId) {
+		return false
+	}
+	if !matchTags(f.Tags, p.Tags) {
+		return false
+	}
+	if len(f.Paths) > 0 && !matchAny(f.Paths, iamPath(p.Arn)) {
+		return false
+	}
+	if len(f.Arns) > 0 && !matchAny(f.Arns, p.Arn) {
+		return false
+	}
+	return true
+}
+
+// ResourceFilter restricts which resources of an entry's type are audited. Every field which is
+// set must match, while any one of the values within a field may match; values other than account
+// IDs may contain * and ? wildcards
+type ResourceFilter struct {
+	// Accounts lists the account IDs in which resources reside
+	Accounts []string `json:"accounts"`
+
+	// Tags maps tag keys to the values which resources must be tagged with
+	Tags map[string]string `json:"tags"`
+
+	// Names lists resource names, such as prod-*
+	Names []string `json:"names"`
+}
+
+// matches determines whether the resource passes the filter
+func (f *ResourceFilter) matches(r *entities.Resource) bool {
+	if len(f.Accounts) > 0 && !slices.Contains(f.Accounts, r.AccountId) {
+		return false
+	}
+	if !matchTags(f.Tags, r.Tags) {
+		return false
+	}
+	if len(f.Names) > 0 && !matchAny(f.Names, r.Name) {
+		return false
+	}
+	return true
+}
+
+// matchAny determines whether the value matches any of the wildcard patterns
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if wildcard.MatchString(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTags determines whether every wanted tag is present with a matching value
+func matchTags(want map[string]string, tags []entities.Tag) bool {
+	for key, pattern := range want {
+		i := slices.IndexFunc(tags, func(t entities.Tag) bool { return t.Key == key })
+		if i < 0 || !wildcard.MatchString(pattern, tags[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// iamPath extracts the IAM path from a principal ARN, such as /service-role/ for
+// arn:aws:iam::111122223333:role/service-role/MyRole
+func iamPath(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	resource := parts[len(parts)-1]
+
+	start, end := strings.Index(resource, "/"), strings.LastIndex(resource, "/")
+	if start < 0 || start == end {
+		return "/"
+	}
+	return resource[start : end+1]
+}
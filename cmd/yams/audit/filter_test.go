@@ -0,0 +1,253 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+// writeFile writes content to a file in a temporary directory, returning its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    []ConfigEntry
+		wantErr string
+	}{
+		{
+			name: "filters",
+			config: `[{
+				"resource_type": "AWS::S3::Bucket",
+				"actions": ["s3:GetObject"],
+				"principals": {
+					"accounts": ["111111111111"],
+					"tags": {"team": "data-*"},
+					"paths": ["/service-role/"],
+					"arns": ["arn:aws:iam::*:role/deploy-*"]
+				},
+				"resources": {
+					"accounts": ["222222222222"],
+					"tags": {"env": "prod"},
+					"names": ["prod-*", "logs"]
+				}
+			}]`,
+			want: []ConfigEntry{{
+				ResourceType: "AWS::S3::Bucket",
+				Actions:      []string{"s3:GetObject"},
+				Principals: PrincipalFilter{
+					Accounts: []string{"111111111111"},
+					Tags:     map[string]string{"team": "data-*"},
+					Paths:    []string{"/service-role/"},
+					Arns:     []string{"arn:aws:iam::*:role/deploy-*"},
+				},
+				Resources: ResourceFilter{
+					Accounts: []string{"222222222222"},
+					Tags:     map[string]string{"env": "prod"},
+					Names:    []string{"prod-*", "logs"},
+				},
+			}},
+		},
+		{
+			name:   "no_filters",
+			config: `[{"resource_type": "AWS::SQS::Queue", "actions": ["sqs:SendMessage"]}]`,
+			want: []ConfigEntry{{
+				ResourceType: "AWS::SQS::Queue",
+				Actions:      []string{"sqs:SendMessage"},
+			}},
+		},
+		{
+			name:    "missing_resource_type",
+			config:  `[{"actions": ["s3:GetObject"]}]`,
+			wantErr: "entry 0: missing resource_type",
+		},
+		{
+			name: "missing_actions",
+			config: `[{"resource_type": "AWS::S3::Bucket", "actions": ["s3:GetObject"]},
+				{"resource_type": "AWS::SQS::Queue"}]`,
+			wantErr: "entry 1 (AWS::SQS::Queue): missing actions",
+		},
+		{
+			name: "invalid_filter_type",
+			config: `[{
+				"resource_type": "AWS::S3::Bucket",
+				"actions": ["s3:GetObject"],
+				"principals": {"accounts": "111111111111"}
+			}]`,
+			wantErr: "unable to parse config",
+		},
+		{
+			name:    "malformed",
+			config:  `[{"resource_type": `,
+			wantErr: "unable to parse config",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadConfig(writeFile(t, "audit.json", tc.config))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("wanted %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPrincipalFilter_Matches(t *testing.T) {
+	principal := &entities.FrozenPrincipal{
+		AccountId: "111111111111",
+		Arn:       "arn:aws:iam::111111111111:role/service-role/deploy-app",
+		Tags: []entities.Tag{
+			{Key: "team", Value: "data-eng"},
+			{Key: "env", Value: "prod"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter PrincipalFilter
+		want   bool
+	}{
+		{name: "empty", filter: PrincipalFilter{}, want: true},
+		{
+			name:   "account",
+			filter: PrincipalFilter{Accounts: []string{"222222222222", "111111111111"}},
+			want:   true,
+		},
+		{
+			name:   "other_account",
+			filter: PrincipalFilter{Accounts: []string{"222222222222"}},
+			want:   false,
+		},
+		{
+			name:   "account_wildcard_not_supported",
+			filter: PrincipalFilter{Accounts: []string{"1111*"}},
+			want:   false,
+		},
+		{
+			name:   "tag_wildcard",
+			filter: PrincipalFilter{Tags: map[string]string{"team": "data-*", "env": "prod"}},
+			want:   true,
+		},
+		{
+			name:   "tag_mismatch",
+			filter: PrincipalFilter{Tags: map[string]string{"env": "dev"}},
+			want:   false,
+		},
+		{
+			name:   "tag_missing",
+			filter: PrincipalFilter{Tags: map[string]string{"owner": "*"}},
+			want:   false,
+		},
+		{
+			name:   "path",
+			filter: PrincipalFilter{Paths: []string{"/service-role/"}},
+			want:   true,
+		},
+		{
+			name:   "root_path",
+			filter: PrincipalFilter{Paths: []string{"/"}},
+			want:   false,
+		},
+		{
+			name:   "arn_wildcard",
+			filter: PrincipalFilter{Arns: []string{"arn:aws:iam::*:role/*/deploy-*"}},
+			want:   true,
+		},
+		{
+			name:   "arn_mismatch",
+			filter: PrincipalFilter{Arns: []string{"arn:aws:iam::*:user/*"}},
+			want:   false,
+		},
+		{
+			name: "all_fields_must_match",
+			filter: PrincipalFilter{
+				Accounts: []string{"111111111111"},
+				Tags:     map[string]string{"env": "prod"},
+				Arns:     []string{"arn:aws:iam::*:user/*"},
+			},
+			want: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.matches(principal); got != tc.want {
+				t.Fatalf("matches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResourceFilter_Matches(t *testing.T) {
+	resource := &entities.Resource{
+		AccountId: "222222222222",
+		Name:      "prod-logs",
+		Tags:      []entities.Tag{{Key: "env", Value: "prod"}},
+	}
+
+	tests := []struct {
+		name   string
+		filter ResourceFilter
+		want   bool
+	}{
+		{name: "empty", filter: ResourceFilter{}, want: true},
+		{name: "account", filter: ResourceFilter{Accounts: []string{"222222222222"}}, want: true},
+		{name: "other_account", filter: ResourceFilter{Accounts: []string{"1"}}, want: false},
+		{name: "tag", filter: ResourceFilter{Tags: map[string]string{"env": "p?od"}}, want: true},
+		{name: "tag_mismatch", filter: ResourceFilter{Tags: map[string]string{"env": "dev"}}},
+		{name: "names", filter: ResourceFilter{Names: []string{"dev-*", "prod-*"}}, want: true},
+		{name: "name_mismatch", filter: ResourceFilter{Names: []string{"prod"}}, want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.matches(resource); got != tc.want {
+				t.Fatalf("matches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIamPath(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{arn: "arn:aws:iam::111111111111:role/MyRole", want: "/"},
+		{arn: "arn:aws:iam::111111111111:role/service-role/MyRole", want: "/service-role/"},
+		{arn: "arn:aws:iam::111111111111:user/a/b/c/MyUser", want: "/a/b/c/"},
+		{arn: "arn:aws:iam::111111111111:root", want: "/"},
+		{arn: "not-an-arn", want: "/"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.arn, func(t *testing.T) {
+			if got := iamPath(tc.arn); got != tc.want {
+				t.Fatalf("iamPath(%q) = %q, want %q", tc.arn, got, tc.want)
+			}
+		})
+	}
+}
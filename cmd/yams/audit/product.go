@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/nsiow/yams/pkg/distributed"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// productFunc simulates every combination of the provided principals, actions and resources,
+// passing each result to the provided function
+type productFunc func(
+	principals []*entities.FrozenPrincipal,
+	actions []string,
+	resources []*entities.FrozenResource,
+	opts sim.Options,
+	fn func(sim.AccessTuple),
+) error
+
+// localProduct simulates products in-process, including denied results if requested
+func localProduct(simulator *sim.Simulator, denied bool) productFunc {
+	if denied {
+		return simulator.ProductFrozenStreamingAll
+	}
+	return simulator.ProductFrozenStreaming
+}
+
+// remoteProduct submits products to a coordinator, whose workers simulate them against sources
+// with the provided content hash. Workers build their own simulation options, so only the request
+// context is sent along with the product
+func remoteProduct(
+	client *distributed.Client,
+	sourcesHash string,
+	reqContext map[string]string,
+	denied bool,
+) productFunc {
+	return func(
+		principals []*entities.FrozenPrincipal,
+		actions []string,
+		resources []*entities.FrozenResource,
+		_ sim.Options,
+		fn func(sim.AccessTuple),
+	) error {
+		req := distributed.ProductRequest{
+			SourcesHash: sourcesHash,
+			Actions:     actions,
+			Context:     reqContext,
+			Denied:      denied,
+		}
+		for _, p := range principals {
+			req.Principals = append(req.Principals, p.Arn)
+		}
+		for _, r := range resources {
+			req.Resources = append(req.Resources, r.Arn)
+		}
+
+		return client.Product(context.Background(), req, func(r distributed.Result) {
+			fn(r.AccessTuple())
+		})
+	}
+}
+
+// validateRemote checks that the audit only uses features which workers support
+func validateRemote(config []ConfigEntry, explain bool, columns, overlays []string) error {
+	if explain {
+		return errors.New("-explain is not supported with -coordinator")
+	}
+	if slices.Contains(columns, columnAllowingPolicy) {
+		return errors.New("the allowing_policy column is not supported with -coordinator")
+	}
+	if len(overlays) > 0 {
+		return errors.New("-overlay is not supported with -coordinator")
+	}
+	for _, entry := range config {
+		if len(entry.Overlays) > 0 {
+			return errors.New("entry overlays are not supported with -coordinator")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// shard selects a deterministic subset of principals, so that an audit can be split across
+// machines whose results are combined afterwards. Principals are assigned by a hash of their ARN,
+// so every machine agrees on the assignment regardless of the order in which sources are loaded
+type shard struct {
+	// index is the 1-based position of this shard, out of count
+	index, count int
+}
+
+// parseShard parses a shard given as i/n, such as 3/10; an empty value selects every principal
+func parseShard(s string) (shard, error) {
+	if s == "" {
+		return shard{index: 1, count: 1}, nil
+	}
+
+	i, n, ok := strings.Cut(s, "/")
+	index, err1 := strconv.Atoi(i)
+	count, err2 := strconv.Atoi(n)
+	if !ok || err1 != nil || err2 != nil || count < 1 || index < 1 || index > count {
+		return shard{}, fmt.Errorf("invalid shard '%s', must be i/n with 1 <= i <= n", s)
+	}
+	return shard{index: index, count: count}, nil
+}
+
+// filter returns the ARNs which belong to the shard, preserving their order
+func (s shard) filter(arns []string) []string {
+	if s.count == 1 {
+		return arns
+	}
+
+	var selected []string
+	for _, arn := range arns {
+		h := fnv.New32a()
+		h.Write([]byte(arn))
+		if int(h.Sum32()%uint32(s.count)) == s.index-1 {
+			selected = append(selected, arn)
+		}
+	}
+	return selected
+}
+
+func (s shard) String() string {
+	return fmt.Sprintf("%d/%d", s.index, s.count)
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    shard
+		wantErr bool
+	}{
+		{input: "", want: shard{index: 1, count: 1}},
+		{input: "1/1", want: shard{index: 1, count: 1}},
+		{input: "3/10", want: shard{index: 3, count: 10}},
+		{input: "10/10", want: shard{index: 10, count: 10}},
+		{input: "0/3", wantErr: true},
+		{input: "4/3", wantErr: true},
+		{input: "-1/3", wantErr: true},
+		{input: "1/0", wantErr: true},
+		{input: "1/-2", wantErr: true},
+		{input: "3", wantErr: true},
+		{input: "a/b", wantErr: true},
+		{input: "1/", wantErr: true},
+		{input: "/2", wantErr: true},
+		{input: "1/2/3", wantErr: true},
+		{input: " 1/2", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := parseShard(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseShard() error = %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("wanted %v, got %v", tc.want, got)
+			}
+			if tc.input != "" && got.String() != tc.input {
+				t.Errorf("wanted String() %q, got %q", tc.input, got.String())
+			}
+		})
+	}
+}
+
+func TestShard_Filter(t *testing.T) {
+	var arns []string
+	for i := range 1000 {
+		arns = append(arns, fmt.Sprintf("arn:aws:iam::%012d:role/role-%d", i%7, i))
+	}
+
+	for _, count := range []int{1, 2, 3, 7, 16} {
+		t.Run(fmt.Sprintf("%d_shards", count), func(t *testing.T) {
+			seen := map[string]int{}
+			for index := 1; index <= count; index++ {
+				selected := shard{index: index, count: count}.filter(arns)
+				if count > 1 && len(selected) == 0 {
+					t.Errorf("shard %d/%d selected no principals", index, count)
+				}
+
+				// order is preserved
+				last := -1
+				for _, arn := range selected {
+					seen[arn]++
+					i := indexOf(arns, arn)
+					if i <= last {
+						t.Fatalf("shard %d/%d changed the order of principals", index, count)
+					}
+					last = i
+				}
+			}
+
+			// every principal is covered by exactly one shard
+			if len(seen) != len(arns) {
+				t.Fatalf("shards covered %d of %d principals", len(seen), len(arns))
+			}
+			for arn, n := range seen {
+				if n != 1 {
+					t.Fatalf("principal %s covered by %d shards", arn, n)
+				}
+			}
+		})
+	}
+}
+
+func TestShard_FilterIsIndependentOfOrder(t *testing.T) {
+	arns := []string{
+		"arn:aws:iam::111111111111:role/a",
+		"arn:aws:iam::111111111111:role/b",
+		"arn:aws:iam::111111111111:role/c",
+		"arn:aws:iam::222222222222:user/d",
+		"arn:aws:iam::222222222222:user/e",
+	}
+	reversed := make([]string, len(arns))
+	for i, arn := range arns {
+		reversed[len(arns)-1-i] = arn
+	}
+
+	s := shard{index: 2, count: 3}
+	got := s.filter(reversed)
+	want := s.filter(arns)
+	for i, j := 0, len(got)-1; i < j; i, j = i+1, j-1 {
+		got[i], got[j] = got[j], got[i]
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the same principals regardless of order, got %v and %v", got, want)
+	}
+}
+
+// indexOf returns the position of the value within the slice, or -1
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -5,12 +5,29 @@ import (
 	"os"
 )
 
+// COMPLETE_COMMAND is the hidden subcommand which the completion scripts run to complete the values
+// of flags dynamically, such as ARNs from the configured server or sources
+const COMPLETE_COMMAND = "__complete"
+
+// Kinds of values completed dynamically by COMPLETE_COMMAND
+const (
+	COMPLETE_PRINCIPAL = "principal"
+	COMPLETE_RESOURCE  = "resource"
+	COMPLETE_ACTION    = "action"
+)
+
 const bashCompletion = `# yams bash completion
+_yams_dynamic() {
+    local IFS=$'\n'
+    COMPREPLY=($(yams __complete "$1" "${cur}" "${words[@]:0:${cword}}" 2>/dev/null))
+    __ltrim_colon_completions "${cur}"
+}
+
 _yams() {
     local cur prev words cword
-    _init_completion || return
+    _init_completion -n : || return
 
-    local commands="status server dump sim audit principals resources actions accounts policies version completion"
+    local commands="status server dump sim shell audit overlay config coordinator worker watch principals resources actions accounts policies version completion"
 
     if [[ ${cword} -eq 1 ]]; then
         COMPREPLY=($(compgen -W "${commands}" -- "${cur}"))
@@ -20,10 +37,10 @@ _yams() {
     local cmd="${words[1]}"
     case "${cmd}" in
         status)
-            COMPREPLY=($(compgen -W "-s --server --format" -- "${cur}"))
+            COMPREPLY=($(compgen -W "--server --profile -s --source --cache --format" -- "${cur}"))
             ;;
         server)
-            COMPREPLY=($(compgen -W "-a --addr -s --source -r --refresh -e --env" -- "${cur}"))
+            COMPREPLY=($(compgen -W "-a --addr --grpc-addr -s --source -r --refresh --conditional --notify --cache-size --overlay-ttl -e --env" -- "${cur}"))
             ;;
         dump)
             COMPREPLY=($(compgen -W "-t --target -o --out -a --aggregator -r --rtype --dry-run" -- "${cur}"))
@@ -32,16 +49,51 @@ _yams() {
             fi
             ;;
         sim)
-            COMPREPLY=($(compgen -W "-s --server -p --principal -a --action -r --resource -c --context -o --overlay -x --exact -e --explain -t --trace" -- "${cur}"))
+            case "${prev}" in
+                -p|-principal|--principal) _yams_dynamic principal; return ;;
+                -r|-resource|--resource) _yams_dynamic resource; return ;;
+                -a|-action|--action) _yams_dynamic action; return ;;
+            esac
+            COMPREPLY=($(compgen -W "--server --profile -s --source --cache -p --principal -a --action -r --resource -c --context -o --overlay -i --overlay-id -x --exact -e --explain -t --trace --format" -- "${cur}"))
+            ;;
+        shell)
+            COMPREPLY=($(compgen -W "--server --profile -s --source --cache -c --context -o --overlay -i --overlay-id -x --exact --history" -- "${cur}"))
             ;;
         audit)
-            COMPREPLY=($(compgen -W "-s --source -f --config -o --out -c --context --overlay" -- "${cur}"))
+            COMPREPLY=($(compgen -W "-s --source --profile -f --config -o --out --format --denied --explain --baseline --column --checkpoint --resume --shard -c --context --overlay --coordinator" -- "${cur}"))
+            ;;
+        coordinator)
+            COMPREPLY=($(compgen -W "-a --addr --partition-size --lease-timeout" -- "${cur}"))
+            ;;
+        worker)
+            COMPREPLY=($(compgen -W "--coordinator -s --source" -- "${cur}"))
+            ;;
+        watch)
+            COMPREPLY=($(compgen -W "-s --source -r --refresh --conditional -f --config -o --out -c --context --profile" -- "${cur}"))
+            ;;
+        overlay)
+            if [[ ${cword} -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "export diff" -- "${cur}"))
+            elif [[ "${words[2]}" == "diff" ]]; then
+                COMPREPLY=($(compgen -W "-b --base -n --new --name -o --out --org-prefix" -- "${cur}"))
+            elif [[ "${prev}" == "-f" || "${prev}" == "--format" ]]; then
+                COMPREPLY=($(compgen -W "terraform cloudformation awsconfig" -- "${cur}"))
+            else
+                COMPREPLY=($(compgen -W "--server --profile -i --id -r --revision -f --format -o --out" -- "${cur}"))
+            fi
+            ;;
+        config)
+            if [[ ${cword} -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "list show set use delete" -- "${cur}"))
+            elif [[ "${words[2]}" == "set" ]]; then
+                COMPREPLY=($(compgen -W "--server --token --format -s --source -i --overlay-id -c --context" -- "${cur}"))
+            fi
             ;;
         principals|resources|actions|accounts|policies)
-            COMPREPLY=($(compgen -W "-s --server -q --query -k --key -f --freeze --format" -- "${cur}"))
+            COMPREPLY=($(compgen -W "--server --profile -s --source --cache -q --query -k --key -f --freeze --format" -- "${cur}"))
             ;;
         completion)
-            COMPREPLY=($(compgen -W "bash zsh" -- "${cur}"))
+            COMPREPLY=($(compgen -W "bash zsh fish" -- "${cur}"))
             ;;
     esac
 }
@@ -51,6 +103,12 @@ complete -F _yams yams
 
 const zshCompletion = `#compdef yams
 
+_yams_dynamic() {
+    local -a completions
+    completions=(${(f)"$(yams __complete $1 "$PREFIX" yams ${words[1,CURRENT-1]} 2>/dev/null)"})
+    compadd -U -Q -- $completions
+}
+
 _yams() {
     local -a commands
     commands=(
@@ -58,7 +116,13 @@ _yams() {
         'server:Start the yams API server'
         'dump:Export AWS organization or config data'
         'sim:Simulate IAM permission checks'
+        'shell:Run simulations and inspect entities interactively'
         'audit:Generate access summary CSV'
+        'overlay:Work with overlays'
+        'config:View and edit named profiles'
+        'coordinator:Distribute audit simulations across workers'
+        'worker:Simulate partitions leased from a coordinator'
+        'watch:Report changes in access as sources refresh'
         'principals:List or search IAM principals'
         'resources:List or search AWS resources'
         'actions:List or search IAM actions'
@@ -80,14 +144,22 @@ _yams() {
             case "${words[1]}" in
                 status)
                     _arguments \
-                        '(-s --server)'{-s,--server}'[Server address]:address:' \
+                        '--server[Server address]:address:' \
+                        '--profile[Config profile]:profile:' \
+                        '*'{-s,--source}'[Data source to load in-process]:source:_files' \
+                        '--cache[Cache loaded sources on disk]' \
                         '--format[Output format]:format:(json table)'
                     ;;
                 server)
                     _arguments \
                         '(-a --addr)'{-a,--addr}'[Listen address]:address:' \
+                        '--grpc-addr[gRPC listen address]:address:' \
                         '*'{-s,--source}'[Data source]:source:_files' \
                         '(-r --refresh)'{-r,--refresh}'[Refresh interval]:seconds:' \
+                        '--conditional[Only reload changed sources]' \
+                        '--notify[SQS queue URL for change notifications]:url:' \
+                        '--cache-size[Maximum number of cached simulation results]:size:' \
+                        '--overlay-ttl[Seconds to cache stored overlays]:seconds:' \
                         '*'{-e,--env}'[Environment variables]:var:'
                     ;;
                 dump)
@@ -100,34 +172,131 @@ _yams() {
                     ;;
                 sim)
                     _arguments \
-                        '(-s --server)'{-s,--server}'[Server address]:address:' \
-                        '(-p --principal)'{-p,--principal}'[Principal ARN]:arn:' \
-                        '(-a --action)'{-a,--action}'[AWS action]:action:' \
-                        '(-r --resource)'{-r,--resource}'[Resource ARN]:arn:' \
+                        '--server[Server address]:address:' \
+                        '--profile[Config profile]:profile:' \
+                        '*'{-s,--source}'[Data source to load in-process]:source:_files' \
+                        '--cache[Cache loaded sources on disk]' \
+                        '(-p --principal)'{-p,--principal}'[Principal ARN]:arn:_yams_dynamic principal' \
+                        '(-a --action)'{-a,--action}'[AWS action]:action:_yams_dynamic action' \
+                        '(-r --resource)'{-r,--resource}'[Resource ARN]:arn:_yams_dynamic resource' \
                         '*'{-c,--context}'[Context key=value]:context:' \
                         '*'{-o,--overlay}'[Overlay file]:file:_files' \
+                        '*'{-i,--overlay-id}'[Stored overlay ID]:id:' \
                         '(-x --exact)'{-x,--exact}'[Disable fuzzy matching]' \
                         '(-e --explain)'{-e,--explain}'[Show explanation]' \
-                        '(-t --trace)'{-t,--trace}'[Show trace]'
+                        '(-t --trace)'{-t,--trace}'[Show trace]' \
+                        '--format[Output format]:format:(json table csv ndjson markdown sarif)'
+                    ;;
+                shell)
+                    _arguments \
+                        '--server[Server address]:address:' \
+                        '--profile[Config profile]:profile:' \
+                        '*'{-s,--source}'[Data source to load in-process]:source:_files' \
+                        '--cache[Cache loaded sources on disk]' \
+                        '*'{-c,--context}'[Context key=value]:context:' \
+                        '*'{-o,--overlay}'[Overlay file]:file:_files' \
+                        '*'{-i,--overlay-id}'[Stored overlay ID]:id:' \
+                        '(-x --exact)'{-x,--exact}'[Disable fuzzy matching]' \
+                        '--history[History file]:file:_files'
                     ;;
                 audit)
                     _arguments \
                         '*'{-s,--source}'[Data source]:source:_files' \
+                        '--profile[Config profile]:profile:' \
                         '(-f --config)'{-f,--config}'[Audit config file]:config:_files' \
                         '(-o --out)'{-o,--out}'[Output destination]:destination:_files' \
+                        '--format[Output format]:format:(csv json ndjson markdown table sarif parquet)' \
+                        '--denied[Also report denied access]' \
+                        '--explain[Explain each decision]' \
+                        '*--baseline[Baseline of accepted access]:file:_files' \
+                        '*--column[Additional column]:column:(principal_account resource_account access_level allowing_policy)' \
+                        '--checkpoint[Record progress to allow resuming]' \
+                        '--resume[Resume from checkpoint]' \
+                        '--shard[Shard to audit, as i/n]:shard:' \
+                        '*'{-c,--context}'[Context key=value]:context:' \
+                        '*--overlay[Overlay file]:file:_files' \
+                        '--coordinator[Coordinator address]:address:'
+                    ;;
+                coordinator)
+                    _arguments \
+                        '(-a --addr)'{-a,--addr}'[Address to listen on]:address:' \
+                        '--partition-size[Principals per partition]:size:' \
+                        '--lease-timeout[Seconds before retrying a partition]:seconds:'
+                    ;;
+                worker)
+                    _arguments \
+                        '--coordinator[Coordinator address]:address:' \
+                        '*'{-s,--source}'[Data source]:source:_files'
+                    ;;
+                watch)
+                    _arguments \
+                        '*'{-s,--source}'[Data source]:source:_files' \
+                        '(-r --refresh)'{-r,--refresh}'[Refresh interval in seconds]:seconds:' \
+                        '--conditional[Only reload changed sources]' \
+                        '(-f --config)'{-f,--config}'[Checks file]:config:_files' \
+                        '(-o --out)'{-o,--out}'[Event destination]:destination:_files' \
                         '*'{-c,--context}'[Context key=value]:context:' \
-                        '*--overlay[Overlay file]:file:_files'
+                        '--profile[Config profile]:profile:'
+                    ;;
+                overlay)
+                    case "${words[2]}" in
+                        diff)
+                            _arguments \
+                                '*'{-b,--base}'[Source before the change]:source:_files' \
+                                '*'{-n,--new}'[Source after the change]:source:_files' \
+                                '--name[Overlay name]:name:' \
+                                '(-o --out)'{-o,--out}'[Output destination]:destination:_files' \
+                                '--org-prefix[Namespace prefix for org types]:prefix:'
+                            ;;
+                        export)
+                            _arguments \
+                                '--server[Server address]:address:' \
+                                '--profile[Config profile]:profile:' \
+                                '(-i --id)'{-i,--id}'[Stored overlay ID]:id:' \
+                                '(-r --revision)'{-r,--revision}'[Overlay revision]:revision:' \
+                                '(-f --format)'{-f,--format}'[Export format]:format:(terraform cloudformation awsconfig)' \
+                                '(-o --out)'{-o,--out}'[Output destination]:destination:_files'
+                            ;;
+                        *)
+                            _arguments '1:overlay command:(export diff)'
+                            ;;
+                    esac
+                    ;;
+                config)
+                    case "${words[2]}" in
+                        set)
+                            _arguments \
+                                '2:profile:' \
+                                '--server[Server address]:address:' \
+                                '--token[Bearer token]:token:' \
+                                '--format[Default output format]:format:(json table csv ndjson markdown sarif)' \
+                                '*'{-s,--source}'[Data source to load in-process]:source:_files' \
+                                '*'{-i,--overlay-id}'[Stored overlay ID]:id:' \
+                                '*'{-c,--context}'[Context key=value]:context:'
+                            ;;
+                        list)
+                            ;;
+                        show|use|delete)
+                            _arguments '2:profile:'
+                            ;;
+                        *)
+                            _arguments '1:config command:(list show set use delete)'
+                            ;;
+                    esac
                     ;;
                 principals|resources|actions|accounts|policies)
                     _arguments \
-                        '(-s --server)'{-s,--server}'[Server address]:address:' \
+                        '--server[Server address]:address:' \
+                        '--profile[Config profile]:profile:' \
+                        '*'{-s,--source}'[Data source to load in-process]:source:_files' \
+                        '--cache[Cache loaded sources on disk]' \
                         '(-q --query)'{-q,--query}'[Search query]:query:' \
                         '(-k --key)'{-k,--key}'[Primary key]:key:' \
                         '(-f --freeze)'{-f,--freeze}'[Freeze entity]' \
-                        '--format[Output format]:format:(json table)'
+                        '--format[Output format]:format:(json table csv ndjson markdown)'
                     ;;
                 completion)
-                    _arguments '1:shell:(bash zsh)'
+                    _arguments '1:shell:(bash zsh fish)'
                     ;;
             esac
             ;;
@@ -137,6 +306,154 @@ _yams() {
 _yams
 `
 
+const fishCompletion = `# yams fish completion
+function __yams_dynamic
+    yams __complete $argv[1] (commandline -ct) (commandline -opc) 2>/dev/null
+end
+
+# __yams_at succeeds if the argument at the provided position is one of the provided words, or if
+# there are exactly that many arguments when no words are provided
+function __yams_at
+    set -l tokens (commandline -opc)
+    if test (count $argv) -eq 1
+        test (count $tokens) -eq $argv[1]
+    else
+        test (count $tokens) -ge $argv[1]; and contains -- $tokens[$argv[1]] $argv[2..-1]
+    end
+end
+
+complete -c yams -f
+
+complete -c yams -n '__yams_at 1' -a status -d 'Show server status and loaded data sources'
+complete -c yams -n '__yams_at 1' -a server -d 'Start the yams API server'
+complete -c yams -n '__yams_at 1' -a dump -d 'Export AWS organization or config data'
+complete -c yams -n '__yams_at 1' -a sim -d 'Simulate IAM permission checks'
+complete -c yams -n '__yams_at 1' -a shell -d 'Run simulations and inspect entities interactively'
+complete -c yams -n '__yams_at 1' -a audit -d 'Generate access summary CSV'
+complete -c yams -n '__yams_at 1' -a overlay -d 'Work with overlays'
+complete -c yams -n '__yams_at 1' -a config -d 'View and edit named profiles'
+complete -c yams -n '__yams_at 1' -a coordinator -d 'Distribute audit simulations across workers'
+complete -c yams -n '__yams_at 1' -a worker -d 'Simulate partitions leased from a coordinator'
+complete -c yams -n '__yams_at 1' -a watch -d 'Report changes in access as sources refresh'
+complete -c yams -n '__yams_at 1' -a principals -d 'List or search IAM principals'
+complete -c yams -n '__yams_at 1' -a resources -d 'List or search AWS resources'
+complete -c yams -n '__yams_at 1' -a actions -d 'List or search IAM actions'
+complete -c yams -n '__yams_at 1' -a accounts -d 'List or search AWS accounts'
+complete -c yams -n '__yams_at 1' -a policies -d 'List or search IAM policies'
+complete -c yams -n '__yams_at 1' -a version -d 'Show version information'
+complete -c yams -n '__yams_at 1' -a completion -d 'Generate shell completion scripts'
+
+complete -c yams -n '__yams_at 2 status' -l server -x -d 'Server address'
+complete -c yams -n '__yams_at 2 status' -l profile -x -d 'Config profile'
+complete -c yams -n '__yams_at 2 status' -s s -l source -rF -d 'Data source'
+complete -c yams -n '__yams_at 2 status' -l cache -d 'Cache loaded sources on disk'
+complete -c yams -n '__yams_at 2 status' -l format -x -a 'json table' -d 'Output format'
+
+complete -c yams -n '__yams_at 2 server' -s a -l addr -x -d 'Listen address'
+complete -c yams -n '__yams_at 2 server' -l grpc-addr -x -d 'gRPC listen address'
+complete -c yams -n '__yams_at 2 server' -s s -l source -rF -d 'Data source'
+complete -c yams -n '__yams_at 2 server' -s r -l refresh -x -d 'Refresh interval in seconds'
+complete -c yams -n '__yams_at 2 server' -l conditional -d 'Only reload changed sources'
+complete -c yams -n '__yams_at 2 server' -l notify -x -d 'SQS queue URL for change notifications'
+complete -c yams -n '__yams_at 2 server' -l cache-size -x -d 'Maximum number of cached simulation results'
+complete -c yams -n '__yams_at 2 server' -l overlay-ttl -x -d 'Seconds to cache stored overlays'
+complete -c yams -n '__yams_at 2 server' -s e -l env -x -d 'Environment variables'
+
+complete -c yams -n '__yams_at 2 dump' -s t -l target -x -a 'config org' -d 'Dump target'
+complete -c yams -n '__yams_at 2 dump' -s o -l out -rF -d 'Output destination'
+complete -c yams -n '__yams_at 2 dump' -s a -l aggregator -x -d 'AWS Config aggregator'
+complete -c yams -n '__yams_at 2 dump' -s r -l rtype -x -d 'Resource types'
+complete -c yams -n '__yams_at 2 dump' -s n -l dry-run -d 'Show what would be done'
+
+complete -c yams -n '__yams_at 2 sim' -l server -x -d 'Server address'
+complete -c yams -n '__yams_at 2 sim' -l profile -x -d 'Config profile'
+complete -c yams -n '__yams_at 2 sim' -s s -l source -rF -d 'Data source'
+complete -c yams -n '__yams_at 2 sim' -l cache -d 'Cache loaded sources on disk'
+complete -c yams -n '__yams_at 2 sim' -s p -l principal -x -a '(__yams_dynamic principal)' -d 'Principal ARN'
+complete -c yams -n '__yams_at 2 sim' -s a -l action -x -a '(__yams_dynamic action)' -d 'AWS action'
+complete -c yams -n '__yams_at 2 sim' -s r -l resource -x -a '(__yams_dynamic resource)' -d 'Resource ARN'
+complete -c yams -n '__yams_at 2 sim' -s c -l context -x -d 'Context key=value'
+complete -c yams -n '__yams_at 2 sim' -s o -l overlay -rF -d 'Overlay file'
+complete -c yams -n '__yams_at 2 sim' -s i -l overlay-id -x -d 'Stored overlay ID'
+complete -c yams -n '__yams_at 2 sim' -s x -l exact -d 'Disable fuzzy matching'
+complete -c yams -n '__yams_at 2 sim' -s e -l explain -d 'Show explanation'
+complete -c yams -n '__yams_at 2 sim' -s t -l trace -d 'Show trace'
+complete -c yams -n '__yams_at 2 sim' -l format -x -a 'json table csv ndjson markdown sarif' -d 'Output format'
+
+complete -c yams -n '__yams_at 2 shell' -l server -x -d 'Server address'
+complete -c yams -n '__yams_at 2 shell' -l profile -x -d 'Config profile'
+complete -c yams -n '__yams_at 2 shell' -s s -l source -rF -d 'Data source'
+complete -c yams -n '__yams_at 2 shell' -l cache -d 'Cache loaded sources on disk'
+complete -c yams -n '__yams_at 2 shell' -s c -l context -x -d 'Context key=value'
+complete -c yams -n '__yams_at 2 shell' -s o -l overlay -rF -d 'Overlay file'
+complete -c yams -n '__yams_at 2 shell' -s i -l overlay-id -x -d 'Stored overlay ID'
+complete -c yams -n '__yams_at 2 shell' -s x -l exact -d 'Disable fuzzy matching'
+complete -c yams -n '__yams_at 2 shell' -l history -rF -d 'History file'
+
+complete -c yams -n '__yams_at 2 audit' -s s -l source -rF -d 'Data source'
+complete -c yams -n '__yams_at 2 audit' -l profile -x -d 'Config profile'
+complete -c yams -n '__yams_at 2 audit' -s f -l config -rF -d 'Audit config file'
+complete -c yams -n '__yams_at 2 audit' -s o -l out -rF -d 'Output destination'
+complete -c yams -n '__yams_at 2 audit' -l format -x -a 'csv json ndjson markdown table sarif parquet' -d 'Output format'
+complete -c yams -n '__yams_at 2 audit' -l denied -d 'Also report denied access'
+complete -c yams -n '__yams_at 2 audit' -l explain -d 'Explain each decision'
+complete -c yams -n '__yams_at 2 audit' -l baseline -rF -d 'Baseline of accepted access'
+complete -c yams -n '__yams_at 2 audit' -l column -x -a 'principal_account resource_account access_level allowing_policy' -d 'Additional column'
+complete -c yams -n '__yams_at 2 audit' -l checkpoint -d 'Record progress to allow resuming'
+complete -c yams -n '__yams_at 2 audit' -l resume -d 'Resume from checkpoint'
+complete -c yams -n '__yams_at 2 audit' -l shard -x -d 'Shard to audit, as i/n'
+complete -c yams -n '__yams_at 2 audit' -s c -l context -x -d 'Context key=value'
+complete -c yams -n '__yams_at 2 audit' -l overlay -rF -d 'Overlay file'
+complete -c yams -n '__yams_at 2 audit' -l coordinator -x -d 'Coordinator address'
+
+complete -c yams -n '__yams_at 2 coordinator' -s a -l addr -x -d 'Address to listen on'
+complete -c yams -n '__yams_at 2 coordinator' -l partition-size -x -d 'Principals per partition'
+complete -c yams -n '__yams_at 2 coordinator' -l lease-timeout -x -d 'Seconds before retrying a partition'
+
+complete -c yams -n '__yams_at 2 worker' -l coordinator -x -d 'Coordinator address'
+complete -c yams -n '__yams_at 2 worker' -s s -l source -rF -d 'Data source'
+
+complete -c yams -n '__yams_at 2 watch' -s s -l source -rF -d 'Data source'
+complete -c yams -n '__yams_at 2 watch' -s r -l refresh -x -d 'Refresh interval in seconds'
+complete -c yams -n '__yams_at 2 watch' -l conditional -d 'Only reload changed sources'
+complete -c yams -n '__yams_at 2 watch' -s f -l config -rF -d 'Checks file'
+complete -c yams -n '__yams_at 2 watch' -s o -l out -rF -d 'Event destination'
+complete -c yams -n '__yams_at 2 watch' -s c -l context -x -d 'Context key=value'
+complete -c yams -n '__yams_at 2 watch' -l profile -x -d 'Config profile'
+
+complete -c yams -n '__yams_at 2 principals actions resources accounts policies' -l server -x -d 'Server address'
+complete -c yams -n '__yams_at 2 principals actions resources accounts policies' -l profile -x -d 'Config profile'
+complete -c yams -n '__yams_at 2 principals actions resources accounts policies' -s s -l source -rF -d 'Data source'
+complete -c yams -n '__yams_at 2 principals actions resources accounts policies' -l cache -d 'Cache loaded sources on disk'
+complete -c yams -n '__yams_at 2 principals actions resources accounts policies' -s q -l query -x -d 'Search query'
+complete -c yams -n '__yams_at 2 principals actions resources accounts policies' -s k -l key -x -d 'Primary key'
+complete -c yams -n '__yams_at 2 principals actions resources accounts policies' -s f -l freeze -d 'Freeze entity'
+complete -c yams -n '__yams_at 2 principals actions resources accounts policies' -l format -x -a 'json table csv ndjson markdown' -d 'Output format'
+
+complete -c yams -n '__yams_at 2 overlay; and __yams_at 2' -a 'export diff'
+complete -c yams -n '__yams_at 3 export' -l server -x -d 'Server address'
+complete -c yams -n '__yams_at 3 export' -l profile -x -d 'Config profile'
+complete -c yams -n '__yams_at 3 export' -s i -l id -x -d 'Stored overlay ID'
+complete -c yams -n '__yams_at 3 export' -s r -l revision -x -d 'Overlay revision'
+complete -c yams -n '__yams_at 3 export' -s f -l format -x -a 'terraform cloudformation awsconfig' -d 'Export format'
+complete -c yams -n '__yams_at 3 export' -s o -l out -rF -d 'Output destination'
+complete -c yams -n '__yams_at 3 diff' -s b -l base -rF -d 'Source before the change'
+complete -c yams -n '__yams_at 3 diff' -s n -l new -rF -d 'Source after the change'
+complete -c yams -n '__yams_at 3 diff' -l name -x -d 'Overlay name'
+complete -c yams -n '__yams_at 3 diff' -s o -l out -rF -d 'Output destination'
+complete -c yams -n '__yams_at 3 diff' -l org-prefix -x -d 'Namespace prefix for org types'
+
+complete -c yams -n '__yams_at 2 config; and __yams_at 2' -a 'list show set use delete'
+complete -c yams -n '__yams_at 3 set' -l server -x -d 'Server address'
+complete -c yams -n '__yams_at 3 set' -l token -x -d 'Bearer token'
+complete -c yams -n '__yams_at 3 set' -l format -x -a 'json table csv ndjson markdown sarif' -d 'Default output format'
+complete -c yams -n '__yams_at 3 set' -s s -l source -rF -d 'Data source to load in-process'
+complete -c yams -n '__yams_at 3 set' -s i -l overlay-id -x -d 'Stored overlay ID'
+complete -c yams -n '__yams_at 3 set' -s c -l context -x -d 'Context key=value'
+
+complete -c yams -n '__yams_at 2 completion; and __yams_at 2' -a 'bash zsh fish'
+`
+
 // PrintCompletion outputs shell completion scripts
 func PrintCompletion(shell string) {
 	switch shell {
@@ -144,8 +461,10 @@ func PrintCompletion(shell string) {
 		fmt.Print(bashCompletion)
 	case "zsh":
 		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown shell: %s. Supported shells: bash, zsh\n", shell)
+		fmt.Fprintf(os.Stderr, "Unknown shell: %s. Supported shells: bash, zsh, fish\n", shell)
 		os.Exit(1)
 	}
 }
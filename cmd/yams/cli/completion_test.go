@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var completionScripts = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+func TestCompletion_Commands(t *testing.T) {
+	for shell, script := range completionScripts {
+		t.Run(shell, func(t *testing.T) {
+			for _, mode := range append(slices.Clone(RUN_MODES), "version", "completion") {
+				if !strings.Contains(script, mode) {
+					t.Errorf("expected %s completion to include command %s", shell, mode)
+				}
+			}
+
+			for _, kind := range []string{COMPLETE_PRINCIPAL, COMPLETE_RESOURCE, COMPLETE_ACTION} {
+				if !strings.Contains(script, "_yams_dynamic "+kind) {
+					t.Errorf("expected %s completion to complete %s values dynamically",
+						shell, kind)
+				}
+			}
+			if !strings.Contains(script, "yams "+COMPLETE_COMMAND+" ") {
+				t.Errorf("expected %s completion to run %s", shell, COMPLETE_COMMAND)
+			}
+		})
+	}
+}
+
+// TestCompletion_Syntax checks each script with its shell, where installed
+func TestCompletion_Syntax(t *testing.T) {
+	for shell, script := range completionScripts {
+		t.Run(shell, func(t *testing.T) {
+			path, err := exec.LookPath(shell)
+			if err != nil {
+				t.Skipf("%s is not installed", shell)
+			}
+
+			file := filepath.Join(t.TempDir(), "yams."+shell)
+			if err := os.WriteFile(file, []byte(script), 0644); err != nil {
+				t.Fatalf("failed to write script: %v", err)
+			}
+			out, err := exec.Command(path, "-n", file).CombinedOutput()
+			if err != nil {
+				t.Fatalf("invalid %s completion: %v\n%s", shell, err, out)
+			}
+		})
+	}
+}
+
+// TestCompletion_BashDynamic runs the bash completion with stubs for bash-completion and yams
+// itself, checking the arguments passed to the completion command and the completions offered
+func TestCompletion_BashDynamic(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	tests := []struct {
+		words string
+		want  []string
+	}{
+		{
+			words: "yams sim -s a.jsonl -p arn:aws:iam::1",
+			want: []string{
+				"[__complete]", "[principal]", "[arn:aws:iam::1]",
+				"[yams]", "[sim]", "[-s]", "[a.jsonl]", "[-p]",
+			},
+		},
+		{
+			words: "yams sim --resource ''",
+			want: []string{
+				"[__complete]", "[resource]", "[]", "[yams]", "[sim]", "[--resource]",
+			},
+		},
+		{
+			words: "yams sim -r x -action s3:Get",
+			want: []string{
+				"[__complete]", "[action]", "[s3:Get]",
+				"[yams]", "[sim]", "[-r]", "[x]", "[-action]",
+			},
+		},
+		{
+			words: "yams sim --exp",
+			want:  []string{"--explain"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.words, func(t *testing.T) {
+			// yams echoes each of its arguments as a completion, bracketed so that empty arguments
+			// are not dropped
+			program := `
+_init_completion() {
+    words=(` + tc.words + `)
+    cword=$((${#words[@]} - 1))
+    cur="${words[cword]}"
+    prev="${words[cword - 1]}"
+}
+__ltrim_colon_completions() { :; }
+yams() { printf '[%s]\n' "$@"; }
+` + bashCompletion + `
+_yams
+printf '%s\n' "${COMPREPLY[@]}"
+`
+			out, err := exec.Command(bash, "-c", program).Output()
+			if err != nil {
+				t.Fatalf("failed to run completion: %v", err)
+			}
+
+			got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("wanted %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -1,17 +1,38 @@
 package cli
 
 import (
+	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	json "github.com/bytedance/sonic"
 )
 
+// configJSON encodes config files with sorted keys, so that saving them produces stable output
+var configJSON = json.Config{SortMapKeys: true}.Froze()
+
 // Config represents the yams configuration file structure
 type Config struct {
-	Server string `json:"server"`
-	Format string `json:"format"`
+	Server string `json:"server,omitempty"`
+	Format string `json:"format,omitempty"`
+
+	// Profile is the name of the profile used when none is selected with -profile or YAMS_PROFILE
+	Profile  string              `json:"profile,omitempty"`
+	Profiles map[string]*Profile `json:"profiles,omitempty"`
+}
+
+// Profile is a named set of defaults, such as those for connecting to a particular environment
+type Profile struct {
+	Server     string            `json:"server,omitempty"`
+	Token      string            `json:"token,omitempty"`
+	Format     string            `json:"format,omitempty"`
+	Sources    []string          `json:"sources,omitempty"`
+	OverlayIDs []string          `json:"overlayIds,omitempty"`
+	Context    map[string]string `json:"context,omitempty"`
 }
 
 // ConfigPaths returns the list of paths to check for config files (in priority order)
@@ -46,6 +67,130 @@ func LoadConfig() *Config {
 	return nil
 }
 
+// ReadConfig reads the config file in the same way as LoadConfig, but returns an empty config if
+// none exists and any error encountered otherwise. It also returns the path to which changes
+// should be saved
+func ReadConfig() (*Config, string, error) {
+	paths := ConfigPaths()
+	if len(paths) == 0 {
+		return nil, "", fmt.Errorf("unable to determine config file location, set XDG_CONFIG_HOME")
+	}
+
+	for _, path := range paths {
+		cfg, err := loadConfigFile(path)
+		if err == nil {
+			return cfg, path, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("error reading config file '%s': %w", path, err)
+		}
+	}
+	return &Config{}, paths[0], nil
+}
+
+// Save writes the config to the provided path. As profiles may contain tokens, the file is only
+// readable by the current user
+func (c *Config) Save(path string) error {
+	b, err := configJSON.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("error writing config file '%s': %w", path, err)
+	}
+	return nil
+}
+
+// ProfileNames returns the names of all profiles, in sorted order
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// SelectProfile returns the named profile; if no name is provided, YAMS_PROFILE or else the
+// config's default profile is used. A nil profile is returned if none is selected
+func (c *Config) SelectProfile(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv("YAMS_PROFILE")
+	}
+	if name == "" {
+		name = c.Profile
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	slog.Debug("using profile", "name", name)
+	return c.GetProfile(name)
+}
+
+// GetProfile returns the named profile, or an error listing the available profiles if it does not
+// exist
+func (c *Config) GetProfile(name string) (*Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return nil, fmt.Errorf("profile '%s' not found, no profiles are configured", name)
+		}
+		return nil, fmt.Errorf("profile '%s' not found, must be one of: %s",
+			name, strings.Join(c.ProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// apply fills in the options which were not provided on the command line from the profile, for
+// the commands which accept them
+func (p *Profile) apply(opts *Flags) {
+	serverProvided := opts.provided("server")
+	if !serverProvided && p.Server != "" {
+		opts.Server = p.Server
+	}
+	if opts.Token == "" {
+		opts.Token = p.Token
+	}
+	if p.Format != "" && opts.defaultFormat() {
+		opts.Format = p.Format
+	}
+
+	switch opts.Mode {
+	case
+		RUN_MODE_STATUS,
+		RUN_MODE_ACCOUNTS,
+		RUN_MODE_ACTIONS,
+		RUN_MODE_POLICIES,
+		RUN_MODE_PRINCIPALS,
+		RUN_MODE_RESOURCES,
+		RUN_MODE_SIM,
+		RUN_MODE_SHELL,
+		RUN_MODE_AUDIT:
+
+		// an explicit server takes precedence over the profile's sources
+		if !opts.provided("s", "source") && !serverProvided {
+			opts.Sources = slices.Clone(p.Sources)
+		}
+	}
+
+	switch opts.Mode {
+	case RUN_MODE_SIM, RUN_MODE_SHELL:
+		if !opts.provided("i", "overlay-id") {
+			opts.OverlayIDs = slices.Clone(p.OverlayIDs)
+		}
+		fallthrough
+	case RUN_MODE_AUDIT:
+		for key, value := range p.Context {
+			if _, ok := opts.Context[key]; !ok {
+				if opts.Context == nil {
+					opts.Context = MapString{}
+				}
+				opts.Context[key] = value
+			}
+		}
+	}
+}
+
 func loadConfigFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
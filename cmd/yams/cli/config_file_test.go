@@ -0,0 +1,279 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeConfig writes a config file to the directory returned by isolateConfig
+func writeConfig(t *testing.T, dir, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, "yams", "config.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+// testConfig has top-level defaults along with a default profile and a second profile
+const testConfig = `{
+	"server": "config.example.com:8888",
+	"format": "csv",
+	"profile": "dev",
+	"profiles": {
+		"dev": {
+			"server": "dev.example.com:8888",
+			"token": "dev-token",
+			"format": "table",
+			"sources": ["dev.jsonl"],
+			"overlayIds": ["dev-overlay"],
+			"context": {"aws:SourceIp": "10.0.0.1", "aws:RequestedRegion": "us-east-1"}
+		},
+		"bare": {}
+	}
+}`
+
+func TestParseArgs_ProfilePrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		env    map[string]string
+		argv   []string
+
+		wantServer     string
+		wantToken      string
+		wantFormat     string
+		wantSources    []string
+		wantOverlayIDs []string
+		wantContext    MapString
+	}{
+		{
+			name:        "no_config",
+			argv:        []string{"yams", "status"},
+			wantServer:  ":8888",
+			wantFormat:  "json",
+			wantSources: nil,
+		},
+		{
+			name:        "profile_fills_unset_flags",
+			config:      testConfig,
+			argv:        []string{"yams", "status"},
+			wantServer:  "dev.example.com:8888",
+			wantToken:   "dev-token",
+			wantFormat:  "table",
+			wantSources: []string{"dev.jsonl"},
+		},
+		{
+			name:        "explicit_default_server_wins",
+			config:      testConfig,
+			argv:        []string{"yams", "status", "-server", ":8888"},
+			wantServer:  ":8888",
+			wantToken:   "dev-token",
+			wantFormat:  "table",
+			wantSources: nil,
+		},
+		{
+			name:        "explicit_default_format_wins",
+			config:      testConfig,
+			argv:        []string{"yams", "status", "-format", "json"},
+			wantServer:  "dev.example.com:8888",
+			wantToken:   "dev-token",
+			wantFormat:  "json",
+			wantSources: []string{"dev.jsonl"},
+		},
+		{
+			name:        "explicit_sources_win",
+			config:      testConfig,
+			argv:        []string{"yams", "principals", "-s", "mine.jsonl"},
+			wantServer:  "dev.example.com:8888",
+			wantToken:   "dev-token",
+			wantFormat:  "table",
+			wantSources: []string{"mine.jsonl"},
+		},
+		{
+			name:        "config_defaults_without_profile_values",
+			config:      testConfig,
+			argv:        []string{"yams", "status", "-profile", "bare"},
+			wantServer:  "config.example.com:8888",
+			wantFormat:  "csv",
+			wantSources: nil,
+		},
+		{
+			name:        "profile_from_environment",
+			config:      testConfig,
+			env:         map[string]string{"YAMS_PROFILE": "bare"},
+			argv:        []string{"yams", "status"},
+			wantServer:  "config.example.com:8888",
+			wantFormat:  "csv",
+			wantSources: nil,
+		},
+		{
+			name:   "environment_overrides_everything",
+			config: testConfig,
+			env: map[string]string{
+				"YAMS_SERVER_ADDRESS": "env:1",
+				"YAMS_TOKEN":          "env-token",
+			},
+			argv:        []string{"yams", "status", "-server", "flag:1"},
+			wantServer:  "env:1",
+			wantToken:   "env-token",
+			wantFormat:  "table",
+			wantSources: nil,
+		},
+		{
+			name:   "sim_profile_overlays_and_context",
+			config: testConfig,
+			argv:   []string{"yams", "sim", "-p", "x", "-a", "s3:getobject"},
+
+			wantServer:     "dev.example.com:8888",
+			wantToken:      "dev-token",
+			wantFormat:     "table",
+			wantSources:    []string{"dev.jsonl"},
+			wantOverlayIDs: []string{"dev-overlay"},
+			wantContext: MapString{
+				"aws:SourceIp":        "10.0.0.1",
+				"aws:RequestedRegion": "us-east-1",
+			},
+		},
+		{
+			name:   "sim_explicit_overlays_and_context_win",
+			config: testConfig,
+			argv: []string{"yams", "sim", "-p", "x", "-a", "s3:getobject",
+				"-i", "mine", "-c", "aws:SourceIp=192.168.0.1"},
+
+			wantServer:     "dev.example.com:8888",
+			wantToken:      "dev-token",
+			wantFormat:     "table",
+			wantSources:    []string{"dev.jsonl"},
+			wantOverlayIDs: []string{"mine"},
+			wantContext: MapString{
+				"aws:SourceIp":        "192.168.0.1",
+				"aws:RequestedRegion": "us-east-1",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := isolateConfig(t)
+			t.Setenv("YAMS_ORG_PREFIX", "")
+			if tc.config != "" {
+				writeConfig(t, dir, tc.config)
+			}
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			opts, err := ParseArgs(tc.argv)
+			if err != nil {
+				t.Fatalf("ParseArgs() error = %v", err)
+			}
+
+			if opts.Server != tc.wantServer {
+				t.Errorf("wanted server %q, got %q", tc.wantServer, opts.Server)
+			}
+			if opts.Token != tc.wantToken {
+				t.Errorf("wanted token %q, got %q", tc.wantToken, opts.Token)
+			}
+			if opts.Format != tc.wantFormat {
+				t.Errorf("wanted format %q, got %q", tc.wantFormat, opts.Format)
+			}
+			if !reflect.DeepEqual([]string(opts.Sources), tc.wantSources) {
+				t.Errorf("wanted sources %v, got %v", tc.wantSources, opts.Sources)
+			}
+			if !reflect.DeepEqual([]string(opts.OverlayIDs), tc.wantOverlayIDs) {
+				t.Errorf("wanted overlay IDs %v, got %v", tc.wantOverlayIDs, opts.OverlayIDs)
+			}
+			if !reflect.DeepEqual(opts.Context, tc.wantContext) {
+				t.Errorf("wanted context %v, got %v", tc.wantContext, opts.Context)
+			}
+		})
+	}
+}
+
+func TestParseArgs_ConfiguredFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		argv   []string
+
+		wantFormat string
+		wantOutput string
+	}{
+		{
+			name:       "sim_uses_config_format",
+			config:     `{"format": "table"}`,
+			argv:       []string{"yams", "sim", "-p", "x", "-a", "s3:getobject"},
+			wantFormat: "table",
+			wantOutput: "table",
+		},
+		{
+			name:       "sim_uses_profile_format",
+			config:     testConfig,
+			argv:       []string{"yams", "sim", "-p", "x", "-a", "s3:getobject"},
+			wantFormat: "table",
+			wantOutput: "table",
+		},
+		{
+			name:       "audit_ignores_config_format",
+			config:     `{"format": "table"}`,
+			argv:       []string{"yams", "audit", "-f", "audit.json", "-o", "out.csv"},
+			wantFormat: "csv",
+			wantOutput: "csv",
+		},
+		{
+			name:       "audit_ignores_profile_format",
+			config:     testConfig,
+			argv:       []string{"yams", "audit", "-f", "audit.json", "-o", "out.csv"},
+			wantFormat: "csv",
+			wantOutput: "csv",
+		},
+		{
+			name:       "audit_extension_wins",
+			config:     `{"format": "table"}`,
+			argv:       []string{"yams", "audit", "-f", "audit.json", "-o", "out.parquet"},
+			wantFormat: "csv",
+			wantOutput: "parquet",
+		},
+		{
+			name:       "audit_explicit_format_wins",
+			config:     `{"format": "table"}`,
+			argv:       []string{"yams", "audit", "-f", "audit.json", "-format", "ndjson"},
+			wantFormat: "ndjson",
+			wantOutput: "ndjson",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := isolateConfig(t)
+			t.Setenv("YAMS_ORG_PREFIX", "")
+			writeConfig(t, dir, tc.config)
+
+			opts, err := ParseArgs(tc.argv)
+			if err != nil {
+				t.Fatalf("ParseArgs() error = %v", err)
+			}
+			if opts.Format != tc.wantFormat {
+				t.Errorf("wanted format %q, got %q", tc.wantFormat, opts.Format)
+			}
+			if got := OutputFormat(opts.Out, opts.Format); got != tc.wantOutput {
+				t.Errorf("wanted output format %q, got %q", tc.wantOutput, got)
+			}
+		})
+	}
+}
+
+func TestParseArgs_UnknownProfile(t *testing.T) {
+	dir := isolateConfig(t)
+	writeConfig(t, dir, testConfig)
+
+	if _, err := ParseArgs([]string{"yams", "status", "-profile", "missing"}); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+}
@@ -11,7 +11,7 @@ import (
 // Common error hints based on error message patterns
 var errorHints = map[string]string{
 	"connection refused": "Is the yams server running? Start it with: yams server -s <source>",
-	"no such host":       "Check the server address. Use -s/--server or set YAMS_SERVER_ADDRESS",
+	"no such host":       "Check the server address. Use -server or set YAMS_SERVER_ADDRESS",
 	"timeout":            "Server may be overloaded or unreachable. Check network connectivity",
 	"unknown command":    "Run 'yams -h' to see available commands",
 	"unknown mode":       "Run 'yams -h' to see available commands",
@@ -4,24 +4,34 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/nsiow/yams/pkg/loaders/awsconfig"
+	"github.com/nsiow/yams/pkg/overlay"
 	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 )
 
 const (
-	RUN_MODE_STATUS     = "status"
-	RUN_MODE_DUMP       = "dump"
-	RUN_MODE_SERVER     = "server"
-	RUN_MODE_ACCOUNTS   = "accounts"
-	RUN_MODE_ACTIONS    = "actions"
-	RUN_MODE_RESOURCES  = "resources"
-	RUN_MODE_PRINCIPALS = "principals"
-	RUN_MODE_POLICIES   = "policies"
-	RUN_MODE_SIM        = "sim"
-	RUN_MODE_AUDIT      = "audit"
+	RUN_MODE_STATUS      = "status"
+	RUN_MODE_DUMP        = "dump"
+	RUN_MODE_SERVER      = "server"
+	RUN_MODE_ACCOUNTS    = "accounts"
+	RUN_MODE_ACTIONS     = "actions"
+	RUN_MODE_RESOURCES   = "resources"
+	RUN_MODE_PRINCIPALS  = "principals"
+	RUN_MODE_POLICIES    = "policies"
+	RUN_MODE_SIM         = "sim"
+	RUN_MODE_AUDIT       = "audit"
+	RUN_MODE_OVERLAY     = "overlay"
+	RUN_MODE_SHELL       = "shell"
+	RUN_MODE_CONFIG      = "config"
+	RUN_MODE_COORDINATOR = "coordinator"
+	RUN_MODE_WORKER      = "worker"
+	RUN_MODE_WATCH       = "watch"
 )
 
 var RUN_MODES = []string{
@@ -35,6 +45,38 @@ var RUN_MODES = []string{
 	RUN_MODE_POLICIES,
 	RUN_MODE_SIM,
 	RUN_MODE_AUDIT,
+	RUN_MODE_OVERLAY,
+	RUN_MODE_SHELL,
+	RUN_MODE_CONFIG,
+	RUN_MODE_COORDINATOR,
+	RUN_MODE_WORKER,
+	RUN_MODE_WATCH,
+}
+
+const (
+	OVERLAY_COMMAND_EXPORT = "export"
+	OVERLAY_COMMAND_DIFF   = "diff"
+)
+
+var OVERLAY_COMMANDS = []string{
+	OVERLAY_COMMAND_EXPORT,
+	OVERLAY_COMMAND_DIFF,
+}
+
+const (
+	CONFIG_COMMAND_LIST   = "list"
+	CONFIG_COMMAND_SHOW   = "show"
+	CONFIG_COMMAND_SET    = "set"
+	CONFIG_COMMAND_USE    = "use"
+	CONFIG_COMMAND_DELETE = "delete"
+)
+
+var CONFIG_COMMANDS = []string{
+	CONFIG_COMMAND_LIST,
+	CONFIG_COMMAND_SHOW,
+	CONFIG_COMMAND_SET,
+	CONFIG_COMMAND_USE,
+	CONFIG_COMMAND_DELETE,
 }
 
 // Flags is a struct containing all flags/options related to CLI behavior
@@ -50,11 +92,17 @@ type Flags struct {
 
 	// server
 	Addr          string
+	GrpcAddr      string
 	Sources       MultiString
 	Refresh       int
+	Conditional   bool
+	NotifyQueue   string
+	CacheSize     int
 	Debug         bool
 	Env           MultiString
 	OverlayStore  string
+	OverlayTTL    int
+	OverlaySweep  int
 	SharedContext MapString
 
 	// inventory
@@ -64,7 +112,27 @@ type Flags struct {
 	Format string
 
 	// audit
-	Config string
+	Config     string
+	Denied     bool
+	Baselines  MultiString
+	Columns    MultiString
+	Checkpoint bool
+	Resume     bool
+	Shard      string
+
+	// coordinator/worker
+	Coordinator   string
+	PartitionSize int
+	LeaseTimeout  int
+
+	// overlay
+	OverlayCommand string
+	OverlayID      string
+	OverlayName    string
+	Revision       int
+	ExportFormat   string
+	BaseSources    MultiString
+	NewSources     MultiString
 
 	// sim
 	Principal    string
@@ -74,30 +142,83 @@ type Flags struct {
 	Explain      bool
 	Trace        bool
 	OverlayFiles MultiString
-	Overlay      v1.Overlay
+	OverlayIDs   MultiString
+	Overlays     []v1.Overlay
 	Exact        bool
 
+	// shell
+	History string
+
+	// config
+	ConfigCommand string
+
 	// multiple
 	Server    string
+	Token     string
+	Profile   string
 	OrgPrefix string
+	Cache     bool
+
+	// passed holds the names of the flags provided on the command line
+	passed map[string]bool
+}
+
+// defaultFormat returns whether the output format may be taken from the config file or a profile:
+// only when -format was not provided, and only for commands whose format defaults to json. Audits
+// keep their own default, so that the format implied by the extension of -out is respected
+func (f *Flags) defaultFormat() bool {
+	if f.provided("format") {
+		return false
+	}
+
+	switch f.Mode {
+	case
+		RUN_MODE_STATUS,
+		RUN_MODE_ACCOUNTS,
+		RUN_MODE_ACTIONS,
+		RUN_MODE_POLICIES,
+		RUN_MODE_PRINCIPALS,
+		RUN_MODE_RESOURCES,
+		RUN_MODE_SIM:
+
+		return true
+	}
+	return false
+}
+
+// provided returns whether any of the named flags was provided on the command line
+func (f *Flags) provided(names ...string) bool {
+	for _, name := range names {
+		if f.passed[name] {
+			return true
+		}
+	}
+	return false
 }
 
 func Parse() (*Flags, error) {
+	return ParseArgs(os.Args)
+}
+
+// ParseArgs parses the provided command line, whose first element is the name of the program, in
+// the same way as the arguments of the running process
+func ParseArgs(argv []string) (*Flags, error) {
 	// Define empty run command; we'll aim to mostly use flag.*Var
 	opts := &Flags{}
+	var fs *flag.FlagSet
 	var args []string
 	var err error
 
 	// Handle version and completion commands early
-	if len(os.Args) >= 2 {
-		switch os.Args[1] {
+	if len(argv) >= 2 {
+		switch argv[1] {
 		case "version", "-v", "--version":
 			PrintVersion()
 			os.Exit(0)
 		case "completion":
 			shell := "bash"
-			if len(os.Args) >= 3 {
-				shell = os.Args[2]
+			if len(argv) >= 3 {
+				shell = argv[2]
 			}
 			PrintCompletion(shell)
 			os.Exit(0)
@@ -105,31 +226,40 @@ func Parse() (*Flags, error) {
 	}
 
 	// Check for subcommand
-	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" {
+	if len(argv) < 2 || argv[1] == "-h" || argv[1] == "--help" {
 		PrintHelp()
 		os.Exit(0)
 	}
 
 	// Resolve command alias
-	opts.Mode = ResolveAlias(os.Args[1])
+	opts.Mode = ResolveAlias(argv[1])
 	slog.Debug("parsed mode", "mode", opts.Mode)
 
 	// Parse options specific to subcommand
 	switch opts.Mode {
 
 	case RUN_MODE_STATUS:
-		fs := flag.NewFlagSet("status", flag.ExitOnError)
+		fs = flag.NewFlagSet("status", flag.ExitOnError)
 
-		fs.StringVar(&opts.Server, "s", "", "alias for -server")
 		fs.StringVar(&opts.Server, "server", ":8888", "address of yams server to use for connection")
 
+		fs.StringVar(&opts.Profile, "profile", "",
+			"named profile from the config file to use (default: $YAMS_PROFILE)")
+
+		fs.Var(&opts.Sources, "s", "alias for -source")
+		fs.Var(&opts.Sources, "source",
+			"source(s) to load and query in-process instead of a server (supports multiple)")
+
+		fs.BoolVar(&opts.Cache, "cache", false,
+			"cache sources loaded with -source on disk between invocations")
+
 		fs.StringVar(&opts.Format, "format", "json", "output format: json or table")
 
-		err = fs.Parse(os.Args[2:])
+		err = fs.Parse(argv[2:])
 		args = fs.Args()
 
 	case RUN_MODE_DUMP:
-		fs := flag.NewFlagSet("dump", flag.ExitOnError)
+		fs = flag.NewFlagSet("dump", flag.ExitOnError)
 
 		fs.StringVar(&opts.Target, "t", "", "alias for -target")
 		fs.StringVar(&opts.Target, "target", "", "which target to dump, one of: [config, org]")
@@ -150,15 +280,18 @@ func Parse() (*Flags, error) {
 		fs.StringVar(&opts.OrgPrefix, "org-prefix", "",
 			"namespace prefix for custom org types (default: Yams)")
 
-		err = fs.Parse(os.Args[2:])
+		err = fs.Parse(argv[2:])
 		args = fs.Args()
 
 	case RUN_MODE_SERVER:
-		fs := flag.NewFlagSet("server", flag.ExitOnError)
+		fs = flag.NewFlagSet("server", flag.ExitOnError)
 
 		fs.StringVar(&opts.Addr, "a", ":8888", "alias for -addr")
 		fs.StringVar(&opts.Addr, "addr", ":8888", "address for running server")
 
+		fs.StringVar(&opts.GrpcAddr, "grpc-addr", "",
+			"address for running the gRPC API (e.g. ':9999'); defaults to disabled")
+
 		fs.Var(&opts.Sources, "s", "alias for -source")
 		fs.Var(&opts.Sources, "source", "list of sources to use for server data (supports multiple)")
 
@@ -166,11 +299,27 @@ func Parse() (*Flags, error) {
 		fs.IntVar(&opts.Refresh, "refresh", 0,
 			"refresh rate (in seconds) for specified sources; defaults to no refresh")
 
+		fs.BoolVar(&opts.Conditional, "conditional", false,
+			"only reload sources on refresh when their S3 ETag or file modification time has changed")
+
+		fs.StringVar(&opts.NotifyQueue, "notify", "",
+			"URL of an SQS(-compatible) queue receiving S3 event notifications for source objects")
+
+		fs.IntVar(&opts.CacheSize, "cache-size", 10000,
+			"maximum number of simulation results to cache; 0 disables caching")
+
 		fs.Var(&opts.Env, "e", "alias for -env")
 		fs.Var(&opts.Env, "env", "environment variables to report in /status endpoint")
 
 		fs.StringVar(&opts.OverlayStore, "overlay", "",
-			"overlay store backend: 'memory' (default) or 'ddb://<table-name>'")
+			"overlay store backend: 'memory' (default), 'ddb://<table-name>', 'file://<dir>' or "+
+				"'sqlite://<path>'")
+
+		fs.IntVar(&opts.OverlayTTL, "overlay-ttl", int(overlay.DefaultCacheTTL.Seconds()),
+			"how long (in seconds) stored overlays used by simulations are cached; 0 disables caching")
+
+		fs.IntVar(&opts.OverlaySweep, "overlay-sweep", int(overlay.DefaultSweepInterval.Seconds()),
+			"how often (in seconds) expired overlays are deleted from the store; 0 disables deletion")
 
 		fs.Var(&opts.SharedContext, "c", "alias for -context")
 		fs.Var(&opts.SharedContext, "context", "shared request context key=value pairs")
@@ -178,7 +327,7 @@ func Parse() (*Flags, error) {
 		fs.StringVar(&opts.OrgPrefix, "org-prefix", "",
 			"namespace prefix for custom org types (default: Yams)")
 
-		err = fs.Parse(os.Args[2:])
+		err = fs.Parse(argv[2:])
 		args = fs.Args()
 
 	case
@@ -196,13 +345,21 @@ func Parse() (*Flags, error) {
 			RUN_MODE_RESOURCES,
 		}
 
-		var fs *flag.FlagSet
 		for _, subcommand := range subcommands {
 			fs = flag.NewFlagSet(subcommand, flag.ExitOnError)
 
-			fs.StringVar(&opts.Server, "s", "", "alias for -server")
 			fs.StringVar(&opts.Server, "server", ":8888", "address of yams server to use for connection")
 
+			fs.StringVar(&opts.Profile, "profile", "",
+				"named profile from the config file to use (default: $YAMS_PROFILE)")
+
+			fs.Var(&opts.Sources, "s", "alias for -source")
+			fs.Var(&opts.Sources, "source",
+				"source(s) to load and query in-process instead of a server (supports multiple)")
+
+			fs.BoolVar(&opts.Cache, "cache", false,
+				"cache sources loaded with -source on disk between invocations")
+
 			fs.StringVar(&opts.Query, "q", "", "alias for -query")
 			fs.StringVar(&opts.Query, "query", "", "case-insensitive search term")
 
@@ -213,18 +370,28 @@ func Parse() (*Flags, error) {
 			fs.BoolVar(&opts.Freeze, "freeze", false,
 				"freeze the entity if applicable, resolving all references to a snapshotted state")
 
-			fs.StringVar(&opts.Format, "format", "json", "output format: json or table")
+			fs.StringVar(&opts.Format, "format", "json",
+				"output format: json, table, csv, ndjson or markdown")
 		}
 
-		err = fs.Parse(os.Args[2:])
+		err = fs.Parse(argv[2:])
 		args = fs.Args()
 
 	case RUN_MODE_SIM:
-		fs := flag.NewFlagSet("sim", flag.ExitOnError)
+		fs = flag.NewFlagSet("sim", flag.ExitOnError)
 
-		fs.StringVar(&opts.Server, "s", "", "alias for -server")
 		fs.StringVar(&opts.Server, "server", ":8888", "address of yams server to use for connection")
 
+		fs.StringVar(&opts.Profile, "profile", "",
+			"named profile from the config file to use (default: $YAMS_PROFILE)")
+
+		fs.Var(&opts.Sources, "s", "alias for -source")
+		fs.Var(&opts.Sources, "source",
+			"source(s) to load and query in-process instead of a server (supports multiple)")
+
+		fs.BoolVar(&opts.Cache, "cache", false,
+			"cache sources loaded with -source on disk between invocations")
+
 		fs.StringVar(&opts.Principal, "p", "", "alias for -principal")
 		fs.StringVar(&opts.Principal, "principal", "", "ARN of the Principal to simulate")
 
@@ -238,7 +405,12 @@ func Parse() (*Flags, error) {
 		fs.Var(&opts.Context, "context", "Additional request-context property for simulation")
 
 		fs.Var(&opts.OverlayFiles, "o", "alias for -overlay")
-		fs.Var(&opts.OverlayFiles, "overlay", "Entity definition file for overrides")
+		fs.Var(&opts.OverlayFiles, "overlay",
+			"Entity definition file for overrides (supports multiple, later files take precedence)")
+
+		fs.Var(&opts.OverlayIDs, "i", "alias for -overlay-id")
+		fs.Var(&opts.OverlayIDs, "overlay-id",
+			"ID of a stored overlay to simulate against (supports multiple, stacked in order)")
 
 		fs.BoolVar(&opts.Exact, "x", false, "alias for -exact")
 		fs.BoolVar(&opts.Exact, "exact", false, "disable fuzzy-matching for ARNs")
@@ -251,11 +423,14 @@ func Parse() (*Flags, error) {
 		fs.BoolVar(&opts.Trace, "trace", false,
 			"provide full evaluation context on how the decision was reached")
 
-		err = fs.Parse(os.Args[2:])
+		fs.StringVar(&opts.Format, "format", "json",
+			"output format: json, table, csv, ndjson, markdown or sarif")
+
+		err = fs.Parse(argv[2:])
 		args = fs.Args()
 
 	case RUN_MODE_AUDIT:
-		fs := flag.NewFlagSet("audit", flag.ExitOnError)
+		fs = flag.NewFlagSet("audit", flag.ExitOnError)
 
 		fs.Var(&opts.Sources, "s", "alias for -source")
 		fs.Var(&opts.Sources, "source", "list of sources to use for data (supports multiple)")
@@ -264,14 +439,245 @@ func Parse() (*Flags, error) {
 		fs.StringVar(&opts.Config, "config", "", "path to audit config JSON file")
 
 		fs.StringVar(&opts.Out, "o", "", "alias for -out")
-		fs.StringVar(&opts.Out, "out", "", "destination for CSV output")
+		fs.StringVar(&opts.Out, "out", "", "destination for output")
+
+		fs.StringVar(&opts.Format, "format", "csv",
+			"output format: csv, json, ndjson, markdown, table, sarif or parquet "+
+				"(default for -out files ending in .parquet)")
+
+		fs.BoolVar(&opts.Denied, "denied", false,
+			"also report denied access, adding a result column")
+
+		fs.BoolVar(&opts.Explain, "explain", false,
+			"add a reason column explaining how each decision was reached")
+
+		fs.Var(&opts.Baselines, "baseline",
+			"CSV file of accepted access to exclude, such as previous output (supports multiple)")
+
+		fs.Var(&opts.Columns, "column",
+			"additional column: principal_account, resource_account, access_level or "+
+				"allowing_policy (supports multiple)")
+
+		fs.BoolVar(&opts.Checkpoint, "checkpoint", false,
+			"record progress in a checkpoint beside the -out file, so that the audit can be "+
+				"resumed")
+
+		fs.BoolVar(&opts.Resume, "resume", false,
+			"resume an interrupted audit from the checkpoint beside its -out file")
+
+		fs.StringVar(&opts.Shard, "shard", "",
+			"audit only shard i of n, given as i/n, to split an audit across machines")
 
 		fs.Var(&opts.Context, "c", "alias for -context")
 		fs.Var(&opts.Context, "context", "additional request-context key=value pairs")
 
-		fs.Var(&opts.OverlayFiles, "overlay", "entity definition file for overrides")
+		fs.Var(&opts.OverlayFiles, "overlay",
+			"entity definition file for overrides (supports multiple, later files take precedence)")
+
+		fs.StringVar(&opts.Coordinator, "coordinator", "",
+			"address of a coordinator whose workers simulate the audit, e.g. 'host:9000'")
+
+		fs.StringVar(&opts.Profile, "profile", "",
+			"named profile from the config file to use (default: $YAMS_PROFILE)")
+
+		err = fs.Parse(argv[2:])
+		args = fs.Args()
+
+	case RUN_MODE_COORDINATOR:
+		fs = flag.NewFlagSet("coordinator", flag.ExitOnError)
+
+		fs.StringVar(&opts.Addr, "a", ":9000", "alias for -addr")
+		fs.StringVar(&opts.Addr, "addr", ":9000", "address for running the coordinator")
+
+		fs.IntVar(&opts.PartitionSize, "partition-size", 100,
+			"number of principals in each partition leased to a worker")
+
+		fs.IntVar(&opts.LeaseTimeout, "lease-timeout", 60,
+			"seconds without a heartbeat after which a partition is retried on another worker")
 
-		err = fs.Parse(os.Args[2:])
+		err = fs.Parse(argv[2:])
+		args = fs.Args()
+
+	case RUN_MODE_WORKER:
+		fs = flag.NewFlagSet("worker", flag.ExitOnError)
+
+		fs.StringVar(&opts.Coordinator, "coordinator", "",
+			"address of the coordinator to lease partitions from, e.g. 'host:9000'")
+
+		fs.Var(&opts.Sources, "s", "alias for -source")
+		fs.Var(&opts.Sources, "source",
+			"source(s) to simulate against, matching those of the audit (supports multiple)")
+
+		err = fs.Parse(argv[2:])
+		args = fs.Args()
+
+	case RUN_MODE_WATCH:
+		fs = flag.NewFlagSet("watch", flag.ExitOnError)
+
+		fs.Var(&opts.Sources, "s", "alias for -source")
+		fs.Var(&opts.Sources, "source", "list of sources to watch (supports multiple)")
+
+		fs.IntVar(&opts.Refresh, "r", 300, "alias for -refresh")
+		fs.IntVar(&opts.Refresh, "refresh", 300,
+			"refresh rate (in seconds) for specified sources, after which checks are re-evaluated")
+
+		fs.BoolVar(&opts.Conditional, "conditional", false,
+			"only reload sources on refresh when their S3 ETag or file modification time has changed")
+
+		fs.StringVar(&opts.Config, "f", "", "alias for -config")
+		fs.StringVar(&opts.Config, "config", "", "path to JSON file of checks to evaluate")
+
+		fs.StringVar(&opts.Out, "o", "", "alias for -out")
+		fs.StringVar(&opts.Out, "out", "",
+			"destination for change events: a file to append to, or an http(s) webhook URL; "+
+				"defaults to stdout")
+
+		fs.Var(&opts.Context, "c", "alias for -context")
+		fs.Var(&opts.Context, "context", "additional request-context key=value pairs")
+
+		fs.StringVar(&opts.Profile, "profile", "",
+			"named profile from the config file to use (default: $YAMS_PROFILE)")
+
+		err = fs.Parse(argv[2:])
+		args = fs.Args()
+
+	case RUN_MODE_OVERLAY:
+		if len(argv) < 3 {
+			return nil, fmt.Errorf("missing overlay command, must be one of: %s",
+				strings.Join(OVERLAY_COMMANDS, ", "))
+		}
+		opts.OverlayCommand = argv[2]
+
+		switch opts.OverlayCommand {
+		case OVERLAY_COMMAND_EXPORT:
+			fs = flag.NewFlagSet("overlay export", flag.ExitOnError)
+
+			fs.StringVar(&opts.Server, "server", ":8888", "address of yams server to use for connection")
+
+			fs.StringVar(&opts.Profile, "profile", "",
+				"named profile from the config file to use (default: $YAMS_PROFILE)")
+
+			fs.StringVar(&opts.OverlayID, "i", "", "alias for -id")
+			fs.StringVar(&opts.OverlayID, "id", "", "ID of the stored overlay to export")
+
+			fs.IntVar(&opts.Revision, "r", 0, "alias for -revision")
+			fs.IntVar(&opts.Revision, "revision", 0,
+				"revision of the overlay to export; defaults to the current revision")
+
+			fs.StringVar(&opts.ExportFormat, "f", "terraform", "alias for -format")
+			fs.StringVar(&opts.ExportFormat, "format", "terraform",
+				"export format, one of: [terraform, cloudformation, awsconfig]")
+
+			fs.StringVar(&opts.Out, "o", "", "alias for -out")
+			fs.StringVar(&opts.Out, "out", "",
+				"destination target for writing, such as main.tf or file:///tmp/main.tf")
+
+			err = fs.Parse(argv[3:])
+			args = fs.Args()
+
+		case OVERLAY_COMMAND_DIFF:
+			fs = flag.NewFlagSet("overlay diff", flag.ExitOnError)
+
+			fs.Var(&opts.BaseSources, "b", "alias for -base")
+			fs.Var(&opts.BaseSources, "base",
+				"source(s) for the universe before the change, such as old.jsonl (supports multiple)")
+
+			fs.Var(&opts.NewSources, "n", "alias for -new")
+			fs.Var(&opts.NewSources, "new",
+				"source(s) for the universe after the change, such as new.jsonl (supports multiple)")
+
+			fs.StringVar(&opts.OverlayName, "name", "",
+				"name of the generated overlay; defaults to one derived from the sources")
+
+			fs.StringVar(&opts.Out, "o", "", "alias for -out")
+			fs.StringVar(&opts.Out, "out", "",
+				"destination target for writing, such as overlay.json or file:///tmp/overlay.json")
+
+			fs.StringVar(&opts.OrgPrefix, "org-prefix", "",
+				"namespace prefix for custom org types (default: Yams)")
+
+			err = fs.Parse(argv[3:])
+			args = fs.Args()
+
+		default:
+			return nil, fmt.Errorf("'%s' is not one of available overlay commands: %s",
+				opts.OverlayCommand, strings.Join(OVERLAY_COMMANDS, ", "))
+		}
+
+	case RUN_MODE_SHELL:
+		fs = flag.NewFlagSet("shell", flag.ExitOnError)
+
+		fs.StringVar(&opts.Server, "server", ":8888", "address of yams server to use for connection")
+
+		fs.StringVar(&opts.Profile, "profile", "",
+			"named profile from the config file to use (default: $YAMS_PROFILE)")
+
+		fs.Var(&opts.Sources, "s", "alias for -source")
+		fs.Var(&opts.Sources, "source",
+			"source(s) to load and query in-process instead of a server (supports multiple)")
+
+		fs.BoolVar(&opts.Cache, "cache", false,
+			"cache sources loaded with -source on disk between invocations")
+
+		fs.Var(&opts.Context, "c", "alias for -context")
+		fs.Var(&opts.Context, "context", "initial request-context key=value pairs for the session")
+
+		fs.Var(&opts.OverlayFiles, "o", "alias for -overlay")
+		fs.Var(&opts.OverlayFiles, "overlay",
+			"entity definition file for overrides (supports multiple, later files take precedence)")
+
+		fs.Var(&opts.OverlayIDs, "i", "alias for -overlay-id")
+		fs.Var(&opts.OverlayIDs, "overlay-id",
+			"ID of a stored overlay to simulate against (supports multiple, stacked in order)")
+
+		fs.BoolVar(&opts.Exact, "x", false, "alias for -exact")
+		fs.BoolVar(&opts.Exact, "exact", false, "disable fuzzy-matching for ARNs")
+
+		fs.StringVar(&opts.History, "history", "",
+			"file in which command history is kept (default: history within the config directory)")
+
+		err = fs.Parse(argv[2:])
+		args = fs.Args()
+
+	case RUN_MODE_CONFIG:
+		if len(argv) < 3 {
+			return nil, fmt.Errorf("missing config command, must be one of: %s",
+				strings.Join(CONFIG_COMMANDS, ", "))
+		}
+		opts.ConfigCommand = argv[2]
+		if !slices.Contains(CONFIG_COMMANDS, opts.ConfigCommand) {
+			return nil, fmt.Errorf("'%s' is not one of available config commands: %s",
+				opts.ConfigCommand, strings.Join(CONFIG_COMMANDS, ", "))
+		}
+
+		// all commands other than 'list' act on the profile named by the first argument
+		rest := argv[3:]
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			opts.Profile, rest = rest[0], rest[1:]
+		}
+
+		fs = flag.NewFlagSet("config "+opts.ConfigCommand, flag.ExitOnError)
+		if opts.ConfigCommand == CONFIG_COMMAND_SET {
+			fs.StringVar(&opts.Server, "server", "", "address of the yams server")
+
+			fs.StringVar(&opts.Token, "token", "",
+				"bearer token sent to the server, e.g. for an authenticating proxy")
+
+			fs.StringVar(&opts.Format, "format", "", "default output format")
+
+			fs.Var(&opts.Sources, "s", "alias for -source")
+			fs.Var(&opts.Sources, "source",
+				"source(s) to load in-process instead of connecting to a server (supports multiple)")
+
+			fs.Var(&opts.OverlayIDs, "i", "alias for -overlay-id")
+			fs.Var(&opts.OverlayIDs, "overlay-id",
+				"ID of a stored overlay to simulate against by default (supports multiple)")
+
+			fs.Var(&opts.Context, "c", "alias for -context")
+			fs.Var(&opts.Context, "context", "default request-context key=value pairs")
+		}
+
+		err = fs.Parse(rest)
 		args = fs.Args()
 
 	// unknown mode
@@ -281,29 +687,69 @@ func Parse() (*Flags, error) {
 	}
 	slog.Debug("opts after flag parsing", "opts", opts)
 
+	// Record the flags which were actually provided, so that defaults from the config file never
+	// override them, even when they were set to their default values
+	opts.passed = map[string]bool{}
+	if fs != nil {
+		fs.Visit(func(f *flag.Flag) {
+			opts.passed[f.Name] = true
+		})
+	}
+
 	if len(args) > 0 {
 		return nil, fmt.Errorf("unknown argument: %s", args[0])
 	}
 
-	// Apply config file defaults (only if values weren't explicitly set)
-	if cfg := LoadConfig(); cfg != nil {
-		if opts.Server == "" || opts.Server == ":8888" {
-			if cfg.Server != "" {
-				opts.Server = cfg.Server
-			}
-		}
-		if opts.Format == "" || opts.Format == "json" {
-			if cfg.Format != "" {
-				opts.Format = cfg.Format
-			}
+	// -s used to be an alias for -server in the commands which query a server, so catch addresses
+	// passed to it rather than failing later when they cannot be loaded as sources
+	switch opts.Mode {
+	case
+		RUN_MODE_STATUS,
+		RUN_MODE_ACCOUNTS,
+		RUN_MODE_ACTIONS,
+		RUN_MODE_POLICIES,
+		RUN_MODE_PRINCIPALS,
+		RUN_MODE_RESOURCES,
+		RUN_MODE_SIM:
+
+		if err := checkSourcesNotServers(opts.Sources); err != nil {
+			return nil, err
 		}
 	}
 
-	// Allow address override via environment (highest priority)
+	// The config command edits the config file, so its defaults are not applied
+	if opts.Mode == RUN_MODE_CONFIG {
+		return opts, err
+	}
+
+	// Apply config file defaults to the flags which were not provided, with those of the selected
+	// profile taking precedence
+	cfg := LoadConfig()
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	profile, err := cfg.SelectProfile(opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Server != "" && !opts.provided("server") {
+		opts.Server = cfg.Server
+	}
+	if cfg.Format != "" && opts.defaultFormat() {
+		opts.Format = cfg.Format
+	}
+	if profile != nil {
+		profile.apply(opts)
+	}
+
+	// Allow address and token override via environment (highest priority)
 	envserver := os.Getenv("YAMS_SERVER_ADDRESS")
 	if len(envserver) > 0 {
 		opts.Server = envserver
 	}
+	if envtoken := os.Getenv("YAMS_TOKEN"); envtoken != "" {
+		opts.Token = envtoken
+	}
 
 	// Apply org prefix: env var overrides flag, flag overrides ldflags default
 	if envPrefix := os.Getenv("YAMS_ORG_PREFIX"); envPrefix != "" {
@@ -316,3 +762,38 @@ func Parse() (*Flags, error) {
 
 	return opts, err
 }
+
+// checkSourcesNotServers returns an error if any of the provided sources looks like the address of
+// a server (a URL or host:port) rather than a file or S3 object
+func checkSourcesNotServers(sources []string) error {
+	for _, src := range sources {
+		if looksLikeServer(src) {
+			return fmt.Errorf("-s/-source now loads a data source in-process, but '%s' looks like "+
+				"a server address; use -server %s to connect to a server instead", src, src)
+		}
+	}
+	return nil
+}
+
+// looksLikeServer returns whether a source is an HTTP(S) URL or a host:port pair which does not
+// name an existing file
+func looksLikeServer(src string) bool {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		return true
+	}
+	if strings.Contains(src, "://") || strings.Contains(src, "/") {
+		return false
+	}
+
+	_, port, err := net.SplitHostPort(src)
+	if err != nil || port == "" {
+		return false
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return false
+	}
+	if _, err := os.Stat(src); err == nil {
+		return false
+	}
+	return true
+}
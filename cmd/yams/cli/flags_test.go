@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolateConfig points config file lookups and environment overrides at an empty directory
+func isolateConfig(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	for _, env := range []string{"YAMS_SERVER_ADDRESS", "YAMS_TOKEN", "YAMS_PROFILE"} {
+		t.Setenv(env, "")
+	}
+	return dir
+}
+
+func TestLooksLikeServer(t *testing.T) {
+	dir := t.TempDir()
+	colonFile := filepath.Join(dir, "data:1234")
+	if err := os.WriteFile(colonFile, nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	t.Chdir(dir)
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{source: ":8888", want: true},
+		{source: "localhost:8888", want: true},
+		{source: "10.0.0.1:443", want: true},
+		{source: "[::1]:8888", want: true},
+		{source: "http://localhost:8888", want: true},
+		{source: "https://yams.example.com", want: true},
+		{source: "awsconfig.jsonl", want: false},
+		{source: "testdata/real-world/awsconfig.jsonl", want: false},
+		{source: "s3://bucket/config.jsonl", want: false},
+		{source: "file:///tmp/config.json", want: false},
+		{source: "localhost:http", want: false},
+		{source: "data:1234", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.source, func(t *testing.T) {
+			if got := looksLikeServer(tc.source); got != tc.want {
+				t.Fatalf("looksLikeServer(%q) = %v, want %v", tc.source, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseArgs_SourceServerAddress(t *testing.T) {
+	isolateConfig(t)
+
+	tests := []struct {
+		name    string
+		argv    []string
+		wantErr bool
+	}{
+		{name: "status_address", argv: []string{"yams", "status", "-s", ":8888"}, wantErr: true},
+		{
+			name:    "sim_url",
+			argv:    []string{"yams", "sim", "-s", "http://localhost:8888", "-p", "x"},
+			wantErr: true,
+		},
+		{
+			name:    "principals_address",
+			argv:    []string{"yams", "principals", "-s", "host:80"},
+			wantErr: true,
+		},
+		{name: "status_source", argv: []string{"yams", "status", "-s", "config.jsonl"}},
+		{name: "status_server", argv: []string{"yams", "status", "-server", "host:80"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseArgs(tc.argv)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "-server") {
+					t.Fatalf("expected error pointing at -server, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -1,45 +1,30 @@
 package cli
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/nsiow/yams/pkg/client"
 )
 
-// CheckServerHealth verifies that the yams server is reachable
+// CheckServerHealth verifies that the yams server is reachable, authenticating with the token if
+// one is provided
 // Returns an error description if the server is not reachable
-func CheckServerHealth(server string) error {
-	url := ApiUrl(server, "status")
-	slog.Debug("checking server health", "url", url)
-
-	client := &http.Client{
+func CheckServerHealth(server, token string) error {
+	c := client.New(server, client.WithToken(token), client.WithHTTPClient(&http.Client{
 		Timeout: 5 * time.Second,
-	}
-
-	resp, err := client.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return &serverError{statusCode: resp.StatusCode}
-	}
-
-	return nil
-}
-
-type serverError struct {
-	statusCode int
-}
+	}))
+	slog.Debug("checking server health", "url", c.URL("status"))
 
-func (e *serverError) Error() string {
-	return "server returned non-200 status"
+	_, err := c.Status(context.Background())
+	return err
 }
 
 // RequireServer checks that the server is reachable, failing with a helpful message if not
-func RequireServer(server string) {
-	if err := CheckServerHealth(server); err != nil {
+func RequireServer(server, token string) {
+	if err := CheckServerHealth(server, token); err != nil {
 		Fail("cannot connect to yams server at '%s': %v", server, err)
 	}
 }
@@ -25,7 +25,13 @@ var Commands = []CommandInfo{
 	{Name: "server", Description: "Start the yams API server"},
 	{Name: "dump", Description: "Export AWS organization or config data"},
 	{Name: "sim", Description: "Simulate IAM permission checks"},
+	{Name: "shell", Description: "Run simulations and inspect entities interactively"},
 	{Name: "audit", Description: "Generate access summary CSV"},
+	{Name: "overlay", Description: "Export overlays, or generate them from the difference between sources"},
+	{Name: "config", Description: "View and edit named profiles in the config file"},
+	{Name: "coordinator", Description: "Distribute audit simulations across worker processes"},
+	{Name: "worker", Description: "Simulate partitions of audits leased from a coordinator"},
+	{Name: "watch", Description: "Report changes in access as sources refresh"},
 	{Name: "principals", Description: "List or search IAM principals (roles, users)", Aliases: []string{"p"}},
 	{Name: "resources", Description: "List or search AWS resources", Aliases: []string{"r"}},
 	{Name: "actions", Description: "List or search IAM actions", Aliases: []string{"a"}},
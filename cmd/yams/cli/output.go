@@ -2,15 +2,44 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
 
 const (
-	FormatJSON  = "json"
-	FormatTable = "table"
+	FormatJSON     = "json"
+	FormatTable    = "table"
+	FormatCSV      = "csv"
+	FormatNDJSON   = "ndjson"
+	FormatMarkdown = "markdown"
+	FormatSARIF    = "sarif"
+
+	// FormatParquet is binary and intended for files, so it is not listed in Formats; see
+	// [OutputFormat]
+	FormatParquet = "parquet"
 )
 
+// Formats lists the output formats supported for tabular results
+var Formats = []string{
+	FormatJSON,
+	FormatTable,
+	FormatCSV,
+	FormatNDJSON,
+	FormatMarkdown,
+	FormatSARIF,
+}
+
+// OutputFormat returns the format to use when writing to the provided destination. Destinations
+// with a .parquet extension are always written as Parquet, in the same way that a .gz extension
+// selects compression; otherwise the requested format is used
+func OutputFormat(dest, format string) string {
+	if strings.HasSuffix(dest, ".parquet") {
+		return FormatParquet
+	}
+	return format
+}
+
 // TableWriter formats data as an aligned table
 type TableWriter struct {
 	headers []string
@@ -51,34 +80,54 @@ func (t *TableWriter) AddRow(values ...string) {
 
 // Render outputs the table to stdout
 func (t *TableWriter) Render() {
+	t.RenderTo(os.Stdout)
+}
+
+// RenderTo outputs the table to the provided writer
+func (t *TableWriter) RenderTo(w io.Writer) {
 	// Print headers
 	for i, h := range t.headers {
 		if i > 0 {
-			fmt.Print("  ")
+			fmt.Fprint(w, "  ")
 		}
-		fmt.Printf("%-*s", t.widths[i], strings.ToUpper(h))
+		fmt.Fprintf(w, "%-*s", t.widths[i], strings.ToUpper(h))
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	// Print separator
-	for i, w := range t.widths {
+	for i, width := range t.widths {
 		if i > 0 {
-			fmt.Print("  ")
+			fmt.Fprint(w, "  ")
 		}
-		fmt.Print(strings.Repeat("-", w))
+		fmt.Fprint(w, strings.Repeat("-", width))
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	// Print rows
 	for _, row := range t.rows {
 		for i, v := range row {
 			if i > 0 {
-				fmt.Print("  ")
+				fmt.Fprint(w, "  ")
 			}
-			fmt.Printf("%-*s", t.widths[i], v)
+			fmt.Fprintf(w, "%-*s", t.widths[i], v)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// RenderFormat outputs the table to stdout in the provided format; see [NewRowWriter]
+func (t *TableWriter) RenderFormat(format string) error {
+	rw, err := NewRowWriter(os.Stdout, format, t.headers)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range t.rows {
+		if err := rw.Write(row); err != nil {
+			return err
 		}
-		fmt.Println()
 	}
+	return rw.Close()
 }
 
 // OutputJSON writes JSON data to stdout
@@ -11,76 +11,96 @@ import (
 	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 )
 
-// LoadOverlays reads and decodes overlay entity files into an Overlay struct
-func LoadOverlays(files []string) (*v1.Overlay, error) {
+// LoadOverlays reads and decodes overlay files into overlay layers, one per file, in the order
+// provided; later layers take precedence over earlier ones.
+//
+// Each file contains either a single entity (identified by its 'Type' field) or an overlay document
+// containing lists of accounts, groups, policies, principals, resources, tombstones and patches
+func LoadOverlays(files []string) ([]v1.Overlay, error) {
+	var layers []v1.Overlay
+	for _, fn := range files {
+		layer, err := loadOverlay(fn)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	return layers, nil
+}
+
+// loadOverlay reads and decodes a single overlay file into an overlay layer
+func loadOverlay(fn string) (v1.Overlay, error) {
 	type overlayItem struct {
 		Type string
 	}
 
 	overlay := v1.Overlay{}
 
-	for _, fn := range files {
-		file, err := os.Open(fn)
-		if err != nil {
-			return nil, fmt.Errorf("could not open overlay file '%s': %v", fn, err)
-		}
-		defer file.Close()
+	file, err := os.Open(fn)
+	if err != nil {
+		return overlay, fmt.Errorf("could not open overlay file '%s': %v", fn, err)
+	}
+	defer file.Close()
 
-		content, err := io.ReadAll(file)
-		if err != nil {
-			return nil, fmt.Errorf("could not read overlay file '%s': %v", fn, err)
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return overlay, fmt.Errorf("could not read overlay file '%s': %v", fn, err)
+	}
+
+	var item overlayItem
+	err = json.Unmarshal(content, &item)
+	if err != nil {
+		return overlay, fmt.Errorf("could not decode overlay file '%s': %v", fn, err)
+	}
+	if item.Type == "" {
+		err = json.Unmarshal(content, &overlay)
+		if err != nil || overlay.IsEmpty() {
+			return overlay, fmt.Errorf("could not decode overlay file '%s': missing field 'Type'", fn)
 		}
+		return overlay, nil
+	}
 
-		var item overlayItem
-		err = json.Unmarshal(content, &item)
+	switch item.Type {
+	case "AWS::IAM::Role", "AWS::IAM::User":
+		var principal entities.Principal
+		err = json.Unmarshal(content, &principal)
 		if err != nil {
-			return nil, fmt.Errorf("could not decode overlay file '%s': %v", fn, err)
+			return overlay, fmt.Errorf("could not decode principal from overlay file '%s': %v", fn, err)
 		}
-		if item.Type == "" {
-			return nil, fmt.Errorf("could not decode overlay file '%s': missing field 'Type'", fn)
+		overlay.Principals = append(overlay.Principals, principal)
+	case "AWS::IAM::Group":
+		var group entities.Group
+		err = json.Unmarshal(content, &group)
+		if err != nil {
+			return overlay, fmt.Errorf("could not decode group from overlay file '%s': %v", fn, err)
 		}
-
-		switch item.Type {
-		case "AWS::IAM::Role", "AWS::IAM::User":
-			var principal entities.Principal
-			err = json.Unmarshal(content, &principal)
-			if err != nil {
-				return nil, fmt.Errorf("could not decode principal from overlay file '%s': %v", fn, err)
-			}
-			overlay.Principals = append(overlay.Principals, principal)
-		case "AWS::IAM::Group":
-			var group entities.Group
-			err = json.Unmarshal(content, &group)
-			if err != nil {
-				return nil, fmt.Errorf("could not decode group from overlay file '%s': %v", fn, err)
-			}
-			overlay.Groups = append(overlay.Groups, group)
-		case
-			"AWS::IAM::Policy",
-			awsconfig.CONST_TYPE_YAMS_ORGANIZATIONS_SCP,
-			awsconfig.CONST_TYPE_YAMS_ORGANIZATIONS_RCP:
-			var policy entities.ManagedPolicy
-			err = json.Unmarshal(content, &policy)
-			if err != nil {
-				return nil, fmt.Errorf("could not decode policy from overlay file '%s': %v", fn, err)
-			}
-			overlay.Policies = append(overlay.Policies, policy)
-		case awsconfig.CONST_TYPE_YAMS_ORGANIZATIONS_ACCOUNT:
-			var account entities.Account
-			err = json.Unmarshal(content, &account)
-			if err != nil {
-				return nil, fmt.Errorf("could not decode account from overlay file '%s': %v", fn, err)
-			}
-			overlay.Accounts = append(overlay.Accounts, account)
+		overlay.Groups = append(overlay.Groups, group)
+	case
+		"AWS::IAM::Policy",
+		awsconfig.CONST_TYPE_YAMS_ORGANIZATIONS_SCP,
+		awsconfig.CONST_TYPE_YAMS_ORGANIZATIONS_RCP:
+		var policy entities.ManagedPolicy
+		err = json.Unmarshal(content, &policy)
+		if err != nil {
+			return overlay, fmt.Errorf("could not decode policy from overlay file '%s': %v", fn, err)
 		}
-
-		var resource entities.Resource
-		err = json.Unmarshal(content, &resource)
+		overlay.Policies = append(overlay.Policies, policy)
+	case awsconfig.CONST_TYPE_YAMS_ORGANIZATIONS_ACCOUNT:
+		var account entities.Account
+		err = json.Unmarshal(content, &account)
 		if err != nil {
-			return nil, fmt.Errorf("could not decode resource from overlay file '%s': %v", fn, err)
+			return overlay, fmt.Errorf("could not decode account from overlay file '%s': %v", fn, err)
 		}
-		overlay.Resources = append(overlay.Resources, resource)
+		overlay.Accounts = append(overlay.Accounts, account)
+	}
+
+	var resource entities.Resource
+	err = json.Unmarshal(content, &resource)
+	if err != nil {
+		return overlay, fmt.Errorf("could not decode resource from overlay file '%s': %v", fn, err)
 	}
+	overlay.Resources = append(overlay.Resources, resource)
 
-	return &overlay, nil
+	return overlay, nil
 }
@@ -1,52 +1,25 @@
 package cli
 
 import (
-	"bytes"
-	"io"
-	"log/slog"
-	"net/http"
 	"os"
 
 	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/client"
 )
 
-func GetReq(url string) {
-	slog.Debug("making request", "url", url)
-	resp, err := http.Get(url)
-	if err != nil {
-		Fail("error retrieving URL '%s': %v", url, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		Fail("received non-200 status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		Fail("error reading body of URL '%s': %v", url, err)
-	}
-
-	os.Stdout.Write(body)
+// NewClient creates an API client for the provided server address, authenticating with the token
+// if one is provided
+func NewClient(server, token string) *client.Client {
+	return client.New(server, client.WithToken(token))
 }
 
-func PostReq(url string, body any) {
-	var buf bytes.Buffer
-	err := json.ConfigDefault.NewEncoder(&buf).Encode(body)
-	if err != nil {
-		Fail("error encoding simulation input: %v", err)
-	}
-
-	resp, err := http.Post(url, "application/json", &buf)
-	if err != nil {
-		Fail("error hitting URL '%s': %v", url, err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
+// PrintJSON writes the provided object to stdout as indented JSON, matching the formatting used by
+// the server
+func PrintJSON(obj any) {
+	b, err := json.MarshalIndent(obj, "", "  ")
 	if err != nil {
-		Fail("error reading body of URL '%s': %v", url, err)
+		Fail("error encoding output: %v", err)
 	}
 
-	os.Stdout.Write(respBody)
+	os.Stdout.Write(append(b, '\n'))
 }
@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/internal/parquet"
+)
+
+// RowWriter writes the rows of a tabular result as they are produced
+type RowWriter interface {
+	// Write writes a single row, with one value per header
+	Write(row []string) error
+
+	// Close writes anything remaining, such as buffered rows or closing delimiters; it does not
+	// close the underlying writer
+	Close() error
+}
+
+// RowFlusher is implemented by RowWriters whose output is complete after each row, such that the
+// rows written so far can be flushed and later appended to
+type RowFlusher interface {
+	Flush() error
+}
+
+// rowOptions holds settings which only apply to some row formats
+type rowOptions struct {
+	artifact string
+	noHeader bool
+}
+
+// RowOption configures a RowWriter
+type RowOption func(*rowOptions)
+
+// WithArtifact sets the file which SARIF results are reported against, such as the data source
+// the results were computed from
+func WithArtifact(uri string) RowOption {
+	return func(o *rowOptions) {
+		o.artifact = uri
+	}
+}
+
+// WithoutHeader omits the header row of CSV and Markdown output, such as when appending to existing
+// output
+func WithoutHeader() RowOption {
+	return func(o *rowOptions) {
+		o.noHeader = true
+	}
+}
+
+// NewRowWriter creates a RowWriter for the provided format:
+//
+//   - table: an aligned table, written once all rows are known
+//   - csv: comma-separated values, with a header row
+//   - json: an array of objects, keyed by header
+//   - ndjson: one object per line, keyed by header
+//   - markdown: a GitHub-flavored Markdown table
+//   - sarif: a SARIF log of access findings; the headers must include principal, action and
+//     resource columns, plus an optional result column
+//   - parquet: a Parquet file with a dictionary-encoded string column per header
+func NewRowWriter(
+	w io.Writer,
+	format string,
+	headers []string,
+	opts ...RowOption,
+) (RowWriter, error) {
+	var o rowOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch format {
+	case FormatTable:
+		return &tableRows{w: w, table: NewTableWriter(headers...)}, nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if o.noHeader {
+			return &csvRows{w: cw}, nil
+		}
+		return &csvRows{w: cw}, cw.Write(headers)
+	case FormatJSON:
+		return &jsonRows{w: bufio.NewWriter(w), keys: jsonKeys(headers)}, nil
+	case FormatNDJSON:
+		return &jsonRows{w: bufio.NewWriter(w), keys: jsonKeys(headers), lines: true}, nil
+	case FormatMarkdown:
+		md := &markdownRows{w: bufio.NewWriter(w)}
+		if o.noHeader {
+			return md, nil
+		}
+		return md, md.header(headers)
+	case FormatSARIF:
+		return newSARIFRows(w, headers, o.artifact)
+	case FormatParquet:
+		return parquet.NewWriter(w, headers, parquet.WithCreatedBy("yams "+Version)), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format '%s', must be one of: %s",
+			format, strings.Join(Formats, ", "))
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// Table
+// -------------------------------------------------------------------------------------------------
+
+type tableRows struct {
+	w     io.Writer
+	table *TableWriter
+}
+
+func (t *tableRows) Write(row []string) error {
+	t.table.AddRow(row...)
+	return nil
+}
+
+func (t *tableRows) Close() error {
+	t.table.RenderTo(t.w)
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// CSV
+// -------------------------------------------------------------------------------------------------
+
+type csvRows struct {
+	w *csv.Writer
+}
+
+func (c *csvRows) Write(row []string) error {
+	return c.w.Write(row)
+}
+
+func (c *csvRows) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvRows) Close() error {
+	return c.Flush()
+}
+
+// -------------------------------------------------------------------------------------------------
+// JSON
+// -------------------------------------------------------------------------------------------------
+
+// jsonRows writes rows as JSON objects, either as an array or one per line. Objects are assembled
+// directly so that their keys follow the order of the headers
+type jsonRows struct {
+	w     *bufio.Writer
+	keys  [][]byte
+	lines bool
+	count int
+}
+
+// jsonKeys converts headers to encoded object keys, in the lower camel case used by the API
+func jsonKeys(headers []string) [][]byte {
+	keys := make([][]byte, len(headers))
+	for i, h := range headers {
+		r, size := utf8.DecodeRuneInString(h)
+		keys[i], _ = json.Marshal(string(unicode.ToLower(r)) + h[size:])
+	}
+	return keys
+}
+
+func (j *jsonRows) Write(row []string) error {
+	switch {
+	case j.lines:
+	case j.count == 0:
+		j.w.WriteString("[\n  ")
+	default:
+		j.w.WriteString(",\n  ")
+	}
+	j.count++
+
+	j.w.WriteByte('{')
+	for i, key := range j.keys {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		var cell string
+		if i < len(row) {
+			cell = row[i]
+		}
+		value, err := json.Marshal(cell)
+		if err != nil {
+			return err
+		}
+		j.w.Write(key)
+		j.w.WriteByte(':')
+		j.w.Write(value)
+	}
+	j.w.WriteByte('}')
+
+	if j.lines {
+		j.w.WriteByte('\n')
+	}
+	return nil
+}
+
+// Flush writes buffered rows; only newline-delimited output is complete between rows
+func (j *jsonRows) Flush() error {
+	return j.w.Flush()
+}
+
+func (j *jsonRows) Close() error {
+	switch {
+	case j.lines:
+	case j.count == 0:
+		j.w.WriteString("[]\n")
+	default:
+		j.w.WriteString("\n]\n")
+	}
+	return j.w.Flush()
+}
+
+// -------------------------------------------------------------------------------------------------
+// Markdown
+// -------------------------------------------------------------------------------------------------
+
+// markdownEscaper escapes values for use within Markdown table cells
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ")
+
+type markdownRows struct {
+	w *bufio.Writer
+}
+
+func (m *markdownRows) header(headers []string) error {
+	if err := m.Write(headers); err != nil {
+		return err
+	}
+
+	separators := make([]string, len(headers))
+	for i := range separators {
+		separators[i] = "---"
+	}
+	return m.line(separators)
+}
+
+func (m *markdownRows) Write(row []string) error {
+	cells := make([]string, len(row))
+	for i, v := range row {
+		cells[i] = markdownEscaper.Replace(v)
+	}
+	return m.line(cells)
+}
+
+func (m *markdownRows) line(cells []string) error {
+	_, err := m.w.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	return err
+}
+
+func (m *markdownRows) Flush() error {
+	return m.w.Flush()
+}
+
+func (m *markdownRows) Close() error {
+	return m.Flush()
+}
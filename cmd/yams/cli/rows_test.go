@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites golden files with the current output instead of comparing against them
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenDir holds the expected output of each row format
+var goldenDir = filepath.Join("..", "..", "..", "testdata", "row-writers")
+
+// simHeaders and simRows are a tabular simulation result, including values which must be quoted
+// or escaped in some formats
+var (
+	simHeaders = []string{"Principal", "Action", "Resource", "Result"}
+	simRows    = [][]string{
+		{"arn:aws:iam::111111111111:role/admin", "s3:GetObject", "arn:aws:s3:::logs/*", "ALLOW"},
+		{"arn:aws:iam::111111111111:role/reader", "s3:PutObject", "arn:aws:s3:::logs/a|b", "DENY"},
+		{"arn:aws:iam::111111111111:role/reader", "iam:ListRoles", "", "ALLOW"},
+		{`arn:aws:iam::111111111111:role/"quoted",comma`, "sqs:SendMessage",
+			"arn:aws:sqs:us-east-1:111111111111:queue", "DENY"},
+		{"arn:aws:iam::111111111111:role/multi", "s3:ListBucket", "line one\nline two", "ALLOW"},
+	}
+)
+
+// writeRows writes the headers and rows in the provided format
+func writeRows(t *testing.T, format string, headers []string, rows [][]string,
+	opts ...RowOption) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewRowWriter(&buf, format, headers, opts...)
+	if err != nil {
+		t.Fatalf("NewRowWriter(%s) error = %v", format, err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// checkGolden compares output against the named golden file, or rewrites it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join(goldenDir, name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+func TestRowWriter_Golden(t *testing.T) {
+	defer func(old string) { Version = old }(Version)
+	Version = "test"
+
+	tests := []struct {
+		name   string
+		format string
+		rows   [][]string
+		opts   []RowOption
+	}{
+		{name: "sim.csv", format: FormatCSV, rows: simRows},
+		{name: "sim_noheader.csv", format: FormatCSV, rows: simRows,
+			opts: []RowOption{WithoutHeader()}},
+		{name: "empty.csv", format: FormatCSV},
+		{name: "sim.ndjson", format: FormatNDJSON, rows: simRows},
+		{name: "empty.ndjson", format: FormatNDJSON},
+		{name: "sim.json", format: FormatJSON, rows: simRows},
+		{name: "empty.json", format: FormatJSON},
+		{name: "sim.md", format: FormatMarkdown, rows: simRows},
+		{name: "sim_noheader.md", format: FormatMarkdown, rows: simRows,
+			opts: []RowOption{WithoutHeader()}},
+		{name: "empty.md", format: FormatMarkdown},
+		{name: "sim.sarif", format: FormatSARIF, rows: simRows},
+		{name: "sim_artifact.sarif", format: FormatSARIF, rows: simRows[:1],
+			opts: []RowOption{WithArtifact("testdata/real-world/awsconfig.jsonl")}},
+		{name: "empty.sarif", format: FormatSARIF},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			checkGolden(t, tc.name, writeRows(t, tc.format, simHeaders, tc.rows, tc.opts...))
+		})
+	}
+}
+
+func TestRowWriter_SARIFWithoutResult(t *testing.T) {
+	defer func(old string) { Version = old }(Version)
+	Version = "test"
+
+	// without a result column, every row is an allowed access
+	headers := []string{"Principal", "Action", "Resource"}
+	rows := [][]string{
+		{"arn:aws:iam::111111111111:role/admin", "s3:GetObject", "arn:aws:s3:::logs"},
+	}
+	checkGolden(t, "noresult.sarif", writeRows(t, FormatSARIF, headers, rows))
+}
+
+func TestRowWriter_SARIFErrors(t *testing.T) {
+	_, err := NewRowWriter(&bytes.Buffer{}, FormatSARIF, []string{"Account", "Name"})
+	if err == nil {
+		t.Error("expected error for headers without principal, action and resource")
+	}
+
+	w, err := NewRowWriter(&bytes.Buffer{}, FormatSARIF, simHeaders)
+	if err != nil {
+		t.Fatalf("NewRowWriter() error = %v", err)
+	}
+	err = w.Write([]string{"arn:aws:iam::111111111111:role/admin", "s3:GetObject"})
+	if err == nil {
+		t.Error("expected error for incomplete row")
+	}
+}
@@ -0,0 +1,295 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	json "github.com/bytedance/sonic"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+
+	// RuleAccessAllowed is reported for each access which is allowed, to be reviewed for being
+	// over-permissive
+	RuleAccessAllowed = "yams/access-allowed"
+
+	// RuleAccessDenied is reported for each simulated access which is denied
+	RuleAccessDenied = "yams/access-denied"
+)
+
+// sarifRules describes the rules which findings may be reported against
+var sarifRules = []sarifRule{
+	{
+		ID:   RuleAccessAllowed,
+		Name: "AccessAllowed",
+		ShortDescription: sarifMessage{
+			Text: "A principal is allowed to perform an action on a resource",
+		},
+		FullDescription: sarifMessage{
+			Text: "The principal is allowed to perform the action on the resource. Review " +
+				"whether this access is intended; unintended access indicates an over-permissive " +
+				"policy.",
+		},
+		DefaultConfiguration: sarifConfiguration{Level: "warning"},
+	},
+	{
+		ID:   RuleAccessDenied,
+		Name: "AccessDenied",
+		ShortDescription: sarifMessage{
+			Text: "A principal is denied an action on a resource",
+		},
+		FullDescription: sarifMessage{
+			Text: "The simulated request was denied. If the principal is expected to have this " +
+				"access, a policy is missing a grant or is blocked by a deny, boundary or SCP.",
+		},
+		DefaultConfiguration: sarifConfiguration{Level: "error"},
+	},
+}
+
+// -------------------------------------------------------------------------------------------------
+// SARIF log format
+// -------------------------------------------------------------------------------------------------
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          sarifProperties   `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+type sarifProperties struct {
+	Principal string `json:"principal"`
+	Action    string `json:"action"`
+	Resource  string `json:"resource,omitempty"`
+}
+
+// -------------------------------------------------------------------------------------------------
+// Row writer
+// -------------------------------------------------------------------------------------------------
+
+// sarifResultsKey precedes the results array in the encoded log, which is split there so that
+// results can be written as they are produced
+const sarifResultsKey = `"results": `
+
+// sarifResultIndent is the indentation of each result within the encoded log
+const sarifResultIndent = "        "
+
+// sarifRows converts rows of access results into SARIF findings, streaming them into the results
+// array of the log. The log up to the results is written with the first result, and the remainder
+// on Close
+type sarifRows struct {
+	w        *bufio.Writer
+	artifact string
+	count    int
+
+	// head and tail are the encoded log before and after the contents of its results array
+	head, tail []byte
+
+	// indices of the relevant columns; result is -1 if there is no result column, in which case
+	// every row is an allowed access
+	principal, action, resource, result int
+}
+
+func newSARIFRows(w io.Writer, headers []string, artifact string) (RowWriter, error) {
+	s := &sarifRows{
+		w:         bufio.NewWriter(w),
+		artifact:  artifact,
+		principal: -1,
+		action:    -1,
+		resource:  -1,
+		result:    -1,
+	}
+	for i, h := range headers {
+		switch strings.ToLower(h) {
+		case "principal":
+			s.principal = i
+		case "action":
+			s.action = i
+		case "resource":
+			s.resource = i
+		case "result":
+			s.result = i
+		}
+	}
+
+	if s.principal < 0 || s.action < 0 || s.resource < 0 {
+		return nil, errors.New("sarif output is only supported for simulation and audit results")
+	}
+
+	var err error
+	s.head, s.tail, err = sarifEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// sarifEnvelope encodes a log without any results, split around the contents of its results array
+func sarifEnvelope() (head, tail []byte, err error) {
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "yams",
+						Version:        Version,
+						InformationURI: "https://github.com/nsiow/yams",
+						Rules:          sarifRules,
+					},
+				},
+				Results: []sarifResult{},
+			},
+		},
+	}
+
+	b, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error encoding sarif output: %w", err)
+	}
+
+	i := bytes.LastIndex(b, []byte(sarifResultsKey+"[]"))
+	if i < 0 {
+		return nil, nil, errors.New("error encoding sarif output: missing results")
+	}
+	split := i + len(sarifResultsKey) + 1
+	return b[:split], append(b[split:], '\n'), nil
+}
+
+func (s *sarifRows) Write(row []string) error {
+	if len(row) <= max(s.principal, s.action, s.resource, s.result) {
+		return fmt.Errorf("incomplete row for sarif output: %v", row)
+	}
+	principal, action, resource := row[s.principal], row[s.action], row[s.resource]
+
+	rule, level, verb := RuleAccessAllowed, "warning", "is allowed to perform"
+	if s.result >= 0 && strings.EqualFold(row[s.result], "DENY") {
+		rule, level, verb = RuleAccessDenied, "error", "is denied"
+	}
+
+	text := fmt.Sprintf("%s %s %s", principal, verb, action)
+	if resource != "" {
+		text += " on " + resource
+	}
+
+	location := sarifLocation{
+		LogicalLocations: []sarifLogicalLocation{
+			{Name: principal, FullyQualifiedName: principal, Kind: "principal"},
+		},
+	}
+	if resource != "" {
+		location.LogicalLocations = append(location.LogicalLocations,
+			sarifLogicalLocation{Name: resource, FullyQualifiedName: resource, Kind: "resource"})
+	}
+	if s.artifact != "" {
+		location.PhysicalLocation = &sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: s.artifact},
+		}
+	}
+
+	tuple := strings.Join([]string{rule, principal, action, resource}, "\x00")
+	fingerprint := sha256.Sum256([]byte(tuple))
+	fingerprints := map[string]string{"accessFingerprint/v1": hex.EncodeToString(fingerprint[:])}
+	properties := sarifProperties{Principal: principal, Action: action, Resource: resource}
+	b, err := json.MarshalIndent(sarifResult{
+		RuleID:              rule,
+		Level:               level,
+		Message:             sarifMessage{Text: text},
+		Locations:           []sarifLocation{location},
+		PartialFingerprints: fingerprints,
+		Properties:          properties,
+	}, sarifResultIndent, "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding sarif result: %w", err)
+	}
+
+	if s.count == 0 {
+		s.w.Write(s.head)
+	} else {
+		s.w.WriteByte(',')
+	}
+	s.count++
+
+	s.w.WriteString("\n" + sarifResultIndent)
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *sarifRows) Close() error {
+	if s.count == 0 {
+		s.w.Write(s.head)
+	} else {
+		// the closing bracket of the results array is indented one level less than its results
+		s.w.WriteString("\n" + sarifResultIndent[2:])
+	}
+	s.w.Write(s.tail)
+	return s.w.Flush()
+}
@@ -0,0 +1,315 @@
+package complete
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/cmd/yams/local"
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/aws/sar"
+	"github.com/nsiow/yams/pkg/client"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// limit is the maximum number of completions offered
+const limit = 100
+
+// cacheTTL is how long search results are reused for
+const cacheTTL = 5 * time.Minute
+
+// defaultTimeout is how long to wait for search results, unless overridden by
+// $YAMS_COMPLETION_TIMEOUT; completions are skipped rather than blocking the shell for longer
+const defaultTimeout = 2 * time.Second
+
+// Logic for the hidden "__complete" subcommand, which is run by the completion scripts as
+//
+//	yams __complete <kind> <word> <command line before the word...>
+//
+// printing a completion of the word per line. Nothing is printed if no completions could be found
+// in time
+func Run(args []string) {
+	if len(args) < 2 {
+		return
+	}
+	kind, word, line := args[0], args[1], args[2:]
+
+	var completions []string
+	var err error
+	switch kind {
+	case cli.COMPLETE_ACTION:
+		completions = actions(word)
+	case cli.COMPLETE_PRINCIPAL, cli.COMPLETE_RESOURCE:
+		completions, err = entities(kind, word, line)
+	default:
+		err = fmt.Errorf("unknown completion kind: %s", kind)
+	}
+	if err != nil {
+		slog.Debug("unable to complete", "kind", kind, "word", word, "error", err)
+		return
+	}
+
+	for _, c := range completions {
+		fmt.Println(c)
+	}
+}
+
+// actions completes action names, which are always available without a server
+func actions(word string) []string {
+	var names []string
+	for _, action := range sar.NewQuery().WithSearch(word).Results() {
+		if len(names) >= limit {
+			break
+		}
+		names = append(names, action.ShortName())
+	}
+	return names
+}
+
+// entities completes the ARNs of principals or resources using the server or sources of the
+// command line being completed, in the same way that partial ARNs are matched in simulations
+func entities(kind, word string, line []string) ([]string, error) {
+	// the flag whose value is being completed is dropped, leaving a command line which parses
+	if n := len(line); n > 0 && strings.HasPrefix(line[n-1], "-") {
+		line = line[:n-1]
+	}
+	opts, err := cli.ParseArgs(line)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := local.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &searcher{
+		kind: client.KindPrincipals,
+		opts: opts,
+		dir:  filepath.Join(dir, "completion"),
+	}
+	if kind == cli.COMPLETE_RESOURCE {
+		s.kind = client.KindResources
+		s.action = opts.Action
+	}
+
+	var results []string
+	if len(opts.Sources) > 0 {
+		results, err = s.index()
+	} else {
+		results, err = s.search(word)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sim.FuzzyMatchArns(results, word, limit), nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Search
+// -------------------------------------------------------------------------------------------------
+
+// newClient creates the in-process client used to index local sources
+var newClient = local.NewClient
+
+// searcher finds the entities of a kind, caching the results on disk. Entities of a server are
+// searched for each word, while those of local sources are indexed once per version of the sources,
+// since loading them is far slower than filtering the index
+type searcher struct {
+	kind client.Kind
+	opts *cli.Flags
+
+	// dir is the directory in which results are cached
+	dir string
+
+	// action, if set, limits resources to those which the action can target
+	action string
+}
+
+// cacheEntry holds the results of a search
+type cacheEntry struct {
+	Created time.Time `json:"created"`
+	Results []string  `json:"results"`
+}
+
+// search returns every entity of the server containing the word. Results of earlier searches for a
+// prefix of the word are reused, since they include every match of the word itself
+func (s *searcher) search(word string) ([]string, error) {
+	target := "server\x00" + s.opts.Server
+	for i := len(word); i >= 0; i-- {
+		key := cacheKey("search", target, string(s.kind), s.action, word[:i])
+		if entry, ok := s.cached(key, cacheTTL); ok {
+			return entry.Results, nil
+		}
+	}
+
+	results, err := withTimeout(func(ctx context.Context) ([]string, error) {
+		c := cli.NewClient(s.opts.Server, s.opts.Token)
+
+		// an empty search would request an empty path segment, so every entity is listed instead
+		if word == "" {
+			keys, err := c.Keys(ctx, s.kind)
+			return s.targetable(keys), err
+		}
+		if s.kind == client.KindResources {
+			return c.SearchResources(ctx, word, s.action)
+		}
+		return c.Search(ctx, s.kind, word)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	key := cacheKey("search", target, string(s.kind), s.action, word)
+	if err := s.save(key, results); err != nil {
+		slog.Debug("unable to cache completions", "error", err)
+	}
+	return results, nil
+}
+
+// index returns every entity of the local sources. The index is cached by the fingerprint of the
+// sources, so that they are only loaded again once they change; sources without a version are
+// indexed again after the usual cache TTL
+func (s *searcher) index() ([]string, error) {
+	target, versioned := fingerprint(s.opts.Sources)
+	key := cacheKey("index", target, string(s.kind))
+
+	ttl := cacheTTL
+	if versioned {
+		ttl = 0
+	}
+	if entry, ok := s.cached(key, ttl); ok {
+		return s.targetable(entry.Results), nil
+	}
+
+	keys, err := withTimeout(func(ctx context.Context) ([]string, error) {
+		c, err := newClient(s.opts)
+		if err != nil {
+			return nil, err
+		}
+		return c.Keys(ctx, s.kind)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.save(key, keys); err != nil {
+		slog.Debug("unable to cache completions", "error", err)
+	}
+	return s.targetable(keys), nil
+}
+
+// targetable filters resources to those which the searcher's action can target, in the same way
+// as searches of the server
+func (s *searcher) targetable(keys []string) []string {
+	if s.kind != client.KindResources || s.action == "" {
+		return keys
+	}
+	action, ok := sar.LookupString(s.action)
+	if !ok {
+		return keys
+	}
+
+	var filtered []string
+	for _, key := range keys {
+		if action.Targets(key) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered
+}
+
+// fingerprint identifies the sources and their current versions (S3 ETag or file mtime and size).
+// Returns false if the version of any source could not be determined
+func fingerprint(sources []string) (string, bool) {
+	parts := []string{"sources"}
+	versioned := true
+	for _, src := range sources {
+		version, err := (&smartrw.Reader{Source: src}).Version()
+		if err != nil {
+			slog.Debug("unable to determine version of source", "source", src, "error", err)
+			versioned = false
+		}
+		parts = append(parts, src, version)
+	}
+	return strings.Join(parts, "\x00"), versioned
+}
+
+// withTimeout runs the query, giving up after the completion timeout
+func withTimeout(query func(context.Context) ([]string, error)) ([]string, error) {
+	timeout := defaultTimeout
+	if env := os.Getenv("YAMS_COMPLETION_TIMEOUT"); env != "" {
+		d, err := time.ParseDuration(env)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAMS_COMPLETION_TIMEOUT: %w", err)
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type result struct {
+		results []string
+		err     error
+	}
+	done := make(chan result, 1)
+
+	// Loading local sources cannot be cancelled, so the query runs separately from the timeout
+	go func() {
+		results, err := query(ctx)
+		done <- result{results, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.results, r.err
+	case <-ctx.Done():
+		return nil, errors.New("timed out searching for completions")
+	}
+}
+
+// cacheKey returns the name of the cache file for the provided parts
+func cacheKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:]) + ".json"
+}
+
+// cached returns the cached results for a key, unless they are older than the TTL; a TTL of zero
+// never expires
+func (s *searcher) cached(key string, ttl time.Duration) (*cacheEntry, bool) {
+	data, err := os.ReadFile(filepath.Join(s.dir, key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if ttl > 0 && time.Since(entry.Created) > ttl {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// save caches results under a key
+func (s *searcher) save(key string, results []string) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cacheEntry{Created: time.Now(), Results: results})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, key), data, 0o600)
+}
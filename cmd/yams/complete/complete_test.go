@@ -0,0 +1,400 @@
+package complete
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/cmd/yams/local"
+	"github.com/nsiow/yams/pkg/client"
+)
+
+// fakeServer serves a fixed set of principals and resources, counting the requests made to each
+// path
+type fakeServer struct {
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+// principalArns and resourceArns are the entities known to the fake server
+var (
+	principalArns = []string{
+		"arn:aws:iam::111111111111:role/admin",
+		"arn:aws:iam::111111111111:role/reader",
+		"arn:aws:iam::222222222222:user/alice",
+	}
+	resourceArns = []string{
+		"arn:aws:s3:::logs/*",
+		"arn:aws:sqs:us-east-1:111111111111:logs-queue",
+	}
+)
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	f.hits[req.URL.Path]++
+	f.mu.Unlock()
+
+	var out []string
+	switch path := req.URL.Path; {
+	case path == "/api/v1/principals":
+		out = principalArns
+	case path == "/api/v1/resources":
+		out = resourceArns
+	case strings.HasPrefix(path, "/api/v1/principals/search/"):
+		out = containing(principalArns, strings.TrimPrefix(path, "/api/v1/principals/search/"))
+	case strings.HasPrefix(path, "/api/v1/resources/search/"):
+		out = containing(resourceArns, strings.TrimPrefix(path, "/api/v1/resources/search/"))
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	b, _ := json.Marshal(out)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// searches returns the number of requests made to search endpoints
+func (f *fakeServer) searches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for path, hits := range f.hits {
+		if strings.Contains(path, "/search/") {
+			n += hits
+		}
+	}
+	return n
+}
+
+// containing returns the ARNs which contain the search string
+func containing(arns []string, search string) []string {
+	matches := []string{}
+	for _, arn := range arns {
+		if strings.Contains(arn, search) {
+			matches = append(matches, arn)
+		}
+	}
+	return matches
+}
+
+// isolate points the configuration and cache directories of the test at a temporary directory
+func isolate(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("YAMS_CACHE_DIR", filepath.Join(dir, "cache"))
+	t.Setenv("YAMS_COMPLETION_TIMEOUT", "30s")
+	for _, env := range []string{"YAMS_SERVER_ADDRESS", "YAMS_TOKEN", "YAMS_PROFILE"} {
+		t.Setenv(env, "")
+	}
+}
+
+// newFakeServer starts a fake server for the duration of the test
+func newFakeServer(t *testing.T) (*fakeServer, string) {
+	t.Helper()
+
+	fake := &fakeServer{hits: map[string]int{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server.URL
+}
+
+// complete runs the completion command, returning the completions it printed
+func complete(t *testing.T, args ...string) []string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	Run(args)
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return strings.Fields(string(out))
+}
+
+// countLoads replaces the client used to index local sources with one which counts its loads
+func countLoads(t *testing.T) *int {
+	t.Helper()
+
+	loads := 0
+	t.Cleanup(func() { newClient = local.NewClient })
+	newClient = func(opts *cli.Flags) (*client.Client, error) {
+		loads++
+		return local.NewClient(opts)
+	}
+	return &loads
+}
+
+// copySource copies the real-world source into a temporary directory, without the lines containing
+// any of the provided strings
+func copySource(t *testing.T, dest string, without ...string) {
+	t.Helper()
+
+	data, err := os.ReadFile("../../../testdata/real-world/awsconfig.jsonl")
+	if err != nil {
+		t.Fatalf("failed to read source: %v", err)
+	}
+
+	var kept []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !slices.ContainsFunc(without, func(s string) bool { return strings.Contains(line, s) }) {
+			kept = append(kept, line)
+		}
+	}
+	if err := os.WriteFile(dest, []byte(strings.Join(kept, "\n")), 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tests
+// -------------------------------------------------------------------------------------------------
+
+// TestRun_Shells completes command lines in the forms passed by the bash, zsh and fish scripts
+func TestRun_Shells(t *testing.T) {
+	isolate(t)
+	_, addr := newFakeServer(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			// bash passes ${words[@]:0:${cword}}, which keeps ARN colons with -n :
+			name: "bash",
+			args: []string{"principal", "arn:aws:iam::111111111111:role/re",
+				"yams", "sim", "--server", addr, "-p"},
+			want: []string{"arn:aws:iam::111111111111:role/reader"},
+		},
+		{
+			// zsh passes yams followed by ${words[1,CURRENT-1]}
+			name: "zsh",
+			args: []string{"principal", "111111111111",
+				"yams", "sim", "--server", addr, "--principal"},
+			want: []string{
+				"arn:aws:iam::111111111111:role/admin",
+				"arn:aws:iam::111111111111:role/reader",
+			},
+		},
+		{
+			// fish passes (commandline -opc), with an empty current token
+			name: "fish",
+			args: []string{"principal", "", "yams", "sim", "--server", addr, "-principal"},
+			want: principalArns,
+		},
+		{
+			name: "resource",
+			args: []string{"resource", "logs", "yams", "sim", "--server", addr, "-r"},
+			want: resourceArns,
+		},
+		{
+			name: "resource_targeted_by_action",
+			args: []string{"resource", "", "yams", "sim", "--server", addr,
+				"-a", "s3:GetObject", "-r"},
+			want: []string{"arn:aws:s3:::logs/*"},
+		},
+		{
+			name: "action",
+			args: []string{"action", "s3:getobjecta", "yams", "sim", "-a"},
+			want: []string{"s3:GetObjectAcl", "s3:GetObjectAttributes"},
+		},
+		{
+			name: "no_matches",
+			args: []string{"principal", "nobody", "yams", "sim", "--server", addr, "-p"},
+		},
+		{
+			name: "unknown_kind",
+			args: []string{"policy", "", "yams", "sim", "--server", addr},
+		},
+		{
+			name: "missing_word",
+			args: []string{"principal"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := complete(t, tc.args...)
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("wanted completions %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRun_ServerSearchCache(t *testing.T) {
+	isolate(t)
+	fake, addr := newFakeServer(t)
+	line := []string{"yams", "sim", "--server", addr, "-p"}
+
+	// an empty word lists every principal, rather than searching with an empty path segment
+	complete(t, append([]string{"principal", ""}, line...)...)
+	if fake.searches() != 0 || fake.hits["/api/v1/principals"] != 1 {
+		t.Fatalf("expected a single listing and no searches, got %v", fake.hits)
+	}
+
+	// the listing includes every match of longer words
+	got := complete(t, append([]string{"principal", "alice"}, line...)...)
+	if want := []string{"arn:aws:iam::222222222222:user/alice"}; !slices.Equal(got, want) {
+		t.Fatalf("wanted completions %v, got %v", want, got)
+	}
+	if len(fake.hits) != 1 {
+		t.Fatalf("expected cached listing to be reused, got %v", fake.hits)
+	}
+
+	// searches are cached by kind, so resources are searched separately
+	complete(t, append([]string{"resource", "logs"}, line...)...)
+	complete(t, append([]string{"resource", "logs-"}, line...)...)
+	if fake.hits["/api/v1/resources/search/logs"] != 1 || fake.searches() != 1 {
+		t.Fatalf("expected a single search of resources, got %v", fake.hits)
+	}
+
+	// and by server
+	other, otherAddr := newFakeServer(t)
+	complete(t, "principal", "", "yams", "sim", "--server", otherAddr, "-p")
+	if other.hits["/api/v1/principals"] != 1 {
+		t.Fatalf("expected other server to be listed, got %v", other.hits)
+	}
+}
+
+func TestRun_SourceIndex(t *testing.T) {
+	isolate(t)
+	loads := countLoads(t)
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "awsconfig.jsonl")
+	copySource(t, source)
+	line := []string{"yams", "sim", "-s", source, "-p"}
+
+	const mouse = "arn:aws:iam::213308312933:role/MouseRole"
+	const panda = "arn:aws:iam::213308312933:role/PandaRole"
+
+	// the first completion indexes the sources, which later completions reuse
+	got := complete(t, append([]string{"principal", "MouseRo"}, line...)...)
+	if !slices.Equal(got, []string{mouse}) {
+		t.Fatalf("wanted completions %v, got %v", []string{mouse}, got)
+	}
+	got = complete(t, append([]string{"principal", "PandaRole"}, line...)...)
+	if !slices.Equal(got, []string{panda}) {
+		t.Fatalf("wanted completions %v, got %v", []string{panda}, got)
+	}
+	if *loads != 1 {
+		t.Fatalf("expected sources to be loaded once, got %d loads", *loads)
+	}
+
+	// resources are indexed separately from principals
+	got = complete(t, append([]string{"resource", "yams-bear"}, line...)...)
+	if !slices.Contains(got, "arn:aws:s3:::yams-bear") {
+		t.Fatalf("expected yams-bear to be completed, got %v", got)
+	}
+	complete(t, append([]string{"resource", ""}, line...)...)
+	if *loads != 2 {
+		t.Fatalf("expected resources to be indexed once, got %d loads", *loads)
+	}
+
+	// changing a source indexes it again
+	copySource(t, source, `"resourceName":"MouseRole"`)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(source, later, later); err != nil {
+		t.Fatalf("failed to touch source: %v", err)
+	}
+	if got := complete(t, append([]string{"principal", "MouseRo"}, line...)...); len(got) != 0 {
+		t.Fatalf("expected deleted principal not to be completed, got %v", got)
+	}
+	if *loads != 3 {
+		t.Fatalf("expected changed source to be indexed again, got %d loads", *loads)
+	}
+
+	// as does changing the set of sources
+	other := filepath.Join(dir, "other.jsonl")
+	copySource(t, other)
+	got = complete(t, "principal", "MouseRo", "yams", "sim", "-s", source, "-s", other, "-p")
+	if !slices.Equal(got, []string{mouse}) {
+		t.Fatalf("wanted completions %v, got %v", []string{mouse}, got)
+	}
+	if *loads != 4 {
+		t.Fatalf("expected new set of sources to be indexed, got %d loads", *loads)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.jsonl")
+	b := filepath.Join(dir, "b.jsonl")
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+			t.Fatalf("failed to write source: %v", err)
+		}
+	}
+
+	ab, versioned := fingerprint([]string{a, b})
+	if !versioned {
+		t.Fatalf("expected local files to be versioned")
+	}
+	if again, _ := fingerprint([]string{a, b}); again != ab {
+		t.Errorf("expected a stable fingerprint, got %q and %q", ab, again)
+	}
+	if ba, _ := fingerprint([]string{b, a}); ba == ab {
+		t.Errorf("expected the order of sources to change the fingerprint")
+	}
+	if only, _ := fingerprint([]string{a}); only == ab {
+		t.Errorf("expected the set of sources to change the fingerprint")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(b, later, later); err != nil {
+		t.Fatalf("failed to touch source: %v", err)
+	}
+	if touched, _ := fingerprint([]string{a, b}); touched == ab {
+		t.Errorf("expected a modified source to change the fingerprint")
+	}
+
+	if _, versioned := fingerprint([]string{a, filepath.Join(dir, "missing.jsonl")}); versioned {
+		t.Errorf("expected a missing source not to be versioned")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	keys := map[string]bool{}
+	for _, parts := range [][]string{
+		{"search", "server\x00a", "principals", "", "role"},
+		{"search", "server\x00a", "resources", "", "role"},
+		{"search", "server\x00a", "resources", "s3:GetObject", "role"},
+		{"search", "server\x00b", "principals", "", "role"},
+		{"search", "server\x00a", "principals", "", "rol"},
+		{"index", "server\x00a", "principals"},
+	} {
+		key := cacheKey(parts...)
+		if keys[key] {
+			t.Fatalf("duplicate cache key for %q", parts)
+		}
+		keys[key] = true
+	}
+
+	if cacheKey("a", "b") != cacheKey("a", "b") {
+		t.Fatalf("expected cache keys to be stable")
+	}
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/cmd/yams/cli"
+)
+
+// showJSON encodes profiles with sorted keys, matching the layout of the saved config file
+var showJSON = json.Config{SortMapKeys: true}.Froze()
+
+// redacted replaces tokens when displaying profiles
+const redacted = "********"
+
+// Logic for the "config" subcommand, which views and edits the profiles in the config file
+func Run(opts *cli.Flags) {
+	cfg, path, err := cli.ReadConfig()
+	if err != nil {
+		cli.Fail("%v", err)
+	}
+
+	switch opts.ConfigCommand {
+	case cli.CONFIG_COMMAND_LIST:
+		list(cfg, path)
+		return
+	case cli.CONFIG_COMMAND_SHOW:
+		show(cfg, opts.Profile)
+		return
+	}
+
+	// the remaining commands edit the named profile
+	if opts.Profile == "" {
+		cli.Fail("missing profile name: yams config %s <profile>", opts.ConfigCommand)
+	}
+
+	switch opts.ConfigCommand {
+	case cli.CONFIG_COMMAND_SET:
+		set(cfg, opts)
+	case cli.CONFIG_COMMAND_USE:
+		if _, err := cfg.GetProfile(opts.Profile); err != nil {
+			cli.Fail("%v", err)
+		}
+		cfg.Profile = opts.Profile
+	case cli.CONFIG_COMMAND_DELETE:
+		if _, err := cfg.GetProfile(opts.Profile); err != nil {
+			cli.Fail("%v", err)
+		}
+		delete(cfg.Profiles, opts.Profile)
+		if cfg.Profile == opts.Profile {
+			cfg.Profile = ""
+		}
+	default:
+		cli.Fail("unknown config command: %s", opts.ConfigCommand)
+	}
+
+	if err := cfg.Save(path); err != nil {
+		cli.Fail("%v", err)
+	}
+	fmt.Fprintf(os.Stderr, "updated %s\n", path)
+}
+
+// list prints a summary of each profile, marking the default
+func list(cfg *cli.Config, path string) {
+	fmt.Fprintf(os.Stderr, "config file: %s\n", path)
+
+	t := cli.NewTableWriter("Default", "Name", "Server", "Sources", "Overlays", "Context Keys")
+	for _, name := range cfg.ProfileNames() {
+		p := cfg.Profiles[name]
+
+		def := ""
+		if name == cfg.Profile {
+			def = "*"
+		}
+		t.AddRow(
+			def,
+			name,
+			p.Server,
+			strings.Join(p.Sources, ","),
+			strings.Join(p.OverlayIDs, ","),
+			strings.Join(slices.Sorted(maps.Keys(p.Context)), ","),
+		)
+	}
+	t.Render()
+}
+
+// show prints the named profile, or the entire config file if no name was provided, with tokens
+// redacted
+func show(cfg *cli.Config, name string) {
+	var obj any = redact(cfg)
+	if name != "" {
+		profile, err := cfg.GetProfile(name)
+		if err != nil {
+			cli.Fail("%v", err)
+		}
+		obj = redactProfile(profile)
+	}
+
+	b, err := showJSON.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		cli.Fail("error encoding output: %v", err)
+	}
+	os.Stdout.Write(append(b, '\n'))
+}
+
+// set creates or updates the named profile; the options which were provided replace the profile's
+// existing values, while the rest are left unchanged
+func set(cfg *cli.Config, opts *cli.Flags) {
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*cli.Profile{}
+	}
+	p, ok := cfg.Profiles[opts.Profile]
+	if !ok {
+		p = &cli.Profile{}
+		cfg.Profiles[opts.Profile] = p
+	}
+
+	if opts.Server != "" {
+		p.Server = opts.Server
+	}
+	if opts.Token != "" {
+		p.Token = opts.Token
+	}
+	if opts.Format != "" {
+		p.Format = opts.Format
+	}
+	if len(opts.Sources) > 0 {
+		p.Sources = []string(opts.Sources)
+	}
+	if len(opts.OverlayIDs) > 0 {
+		p.OverlayIDs = []string(opts.OverlayIDs)
+	}
+	if len(opts.Context) > 0 {
+		p.Context = map[string]string(opts.Context)
+	}
+}
+
+func redact(cfg *cli.Config) *cli.Config {
+	out := *cfg
+	out.Profiles = map[string]*cli.Profile{}
+	for name, p := range cfg.Profiles {
+		out.Profiles[name] = redactProfile(p)
+	}
+	return &out
+}
+
+func redactProfile(p *cli.Profile) *cli.Profile {
+	out := *p
+	if out.Token != "" {
+		out.Token = redacted
+	}
+	return &out
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/testlib"
+)
+
+// isolate points config file lookups and environment overrides at an empty directory, returning
+// the path at which the config file is saved
+func isolate(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	for _, env := range []string{"YAMS_SERVER_ADDRESS", "YAMS_TOKEN", "YAMS_PROFILE"} {
+		t.Setenv(env, "")
+	}
+	return filepath.Join(dir, "yams", "config.json")
+}
+
+// parseArgs parses the command line of a config command
+func parseArgs(t *testing.T, args ...string) *cli.Flags {
+	t.Helper()
+
+	opts, err := cli.ParseArgs(append([]string{"yams", "config"}, args...))
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	return opts
+}
+
+// run runs a config command, returning what it printed to stdout
+func run(t *testing.T, args ...string) string {
+	t.Helper()
+
+	opts := parseArgs(t, args...)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	Run(opts)
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return string(out)
+}
+
+// readConfig decodes the saved config file
+func readConfig(t *testing.T, path string) cli.Config {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config file: %v", err)
+	}
+	var cfg cli.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("failed to decode config file: %v", err)
+	}
+	return cfg
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tests
+// -------------------------------------------------------------------------------------------------
+
+func TestRun_Edit(t *testing.T) {
+	path := isolate(t)
+
+	run(t, "set", "dev", "--server", "http://dev:8888", "--token", "secret",
+		"-s", "a.jsonl", "-s", "b.jsonl", "-c", "aws:SourceVpc=vpc-1")
+	run(t, "set", "prod", "--server", "http://prod:8888", "-i", "overlay-1")
+	run(t, "use", "prod")
+
+	// options which are not provided keep their existing values
+	run(t, "set", "dev", "--format", "csv", "-s", "c.jsonl")
+
+	want := cli.Config{
+		Profile: "prod",
+		Profiles: map[string]*cli.Profile{
+			"dev": {
+				Server:  "http://dev:8888",
+				Token:   "secret",
+				Format:  "csv",
+				Sources: []string{"c.jsonl"},
+				Context: map[string]string{"aws:SourceVpc": "vpc-1"},
+			},
+			"prod": {
+				Server:     "http://prod:8888",
+				OverlayIDs: []string{"overlay-1"},
+			},
+		},
+	}
+	if got := readConfig(t, path); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted config %+v, got %+v", want, got)
+	}
+
+	// deleting the default profile clears the default
+	run(t, "delete", "prod")
+	got := readConfig(t, path)
+	if got.Profile != "" {
+		t.Errorf("expected default to be cleared, got %q", got.Profile)
+	}
+	if _, ok := got.Profiles["prod"]; ok || len(got.Profiles) != 1 {
+		t.Errorf("expected only the dev profile to remain, got %v", got.Profiles)
+	}
+}
+
+func TestRun_Show(t *testing.T) {
+	path := isolate(t)
+	run(t, "set", "dev", "--server", "http://dev:8888", "--token", "secret")
+	run(t, "set", "prod", "--server", "http://prod:8888")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "profile",
+			args: []string{"show", "dev"},
+			want: `{
+  "server": "http://dev:8888",
+  "token": "********"
+}
+`,
+		},
+		{
+			name: "config_file",
+			args: []string{"show"},
+			want: `{
+  "profiles": {
+    "dev": {
+      "server": "http://dev:8888",
+      "token": "********"
+    },
+    "prod": {
+      "server": "http://prod:8888"
+    }
+  }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(t, tt.args...); got != tt.want {
+				t.Errorf("wanted:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+
+	// tokens are only redacted for display
+	if token := readConfig(t, path).Profiles["dev"].Token; token != "secret" {
+		t.Errorf("expected saved token to be unchanged, got %q", token)
+	}
+}
+
+func TestRun_List(t *testing.T) {
+	isolate(t)
+	run(t, "set", "dev", "--server", "http://dev:8888", "-s", "a.jsonl", "-c", "k=v")
+	run(t, "set", "prod", "--server", "http://prod:8888", "-i", "overlay-1")
+	run(t, "use", "dev")
+
+	lines := strings.Split(strings.TrimSpace(run(t, "list")), "\n")
+	var rows [][]string
+	for _, line := range lines {
+		rows = append(rows, strings.Fields(line))
+	}
+
+	want := [][]string{
+		{"*", "dev", "http://dev:8888", "a.jsonl", "k"},
+		{"prod", "http://prod:8888", "overlay-1"},
+	}
+	if len(rows) < len(want) || !reflect.DeepEqual(rows[len(rows)-len(want):], want) {
+		t.Errorf("wanted rows ending with %v, got:\n%s", want, strings.Join(lines, "\n"))
+	}
+}
+
+func TestParseArgs_Errors(t *testing.T) {
+	isolate(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "missing_command", args: []string{}},
+		{name: "unknown_command", args: []string{"rename", "dev"}},
+		{name: "extra_argument", args: []string{"use", "dev", "prod"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argv := append([]string{"yams", "config"}, tt.args...)
+			if _, err := cli.ParseArgs(argv); err == nil {
+				t.Errorf("expected error parsing %v", tt.args)
+			}
+		})
+	}
+
+	// only set accepts flags, and unknown flags exit after printing usage
+	t.Run("flags_of_set", func(t *testing.T) {
+		argv := []string{"yams", "config", "use", "dev", "--server", "http://dev:8888"}
+		testlib.AssertExit(t, 2, `flag provided but not defined: -server`, func() {
+			cli.ParseArgs(argv)
+		})
+	})
+}
+
+func TestRun_Errors(t *testing.T) {
+	path := isolate(t)
+	run(t, "set", "dev", "--server", "http://dev:8888")
+
+	tests := []struct {
+		name    string
+		args    []string
+		pattern string
+	}{
+		{
+			name:    "set_without_profile",
+			args:    []string{"set", "--server", "http://dev:8888"},
+			pattern: `missing profile name: yams config set <profile>`,
+		},
+		{
+			name:    "use_unknown_profile",
+			args:    []string{"use", "prod"},
+			pattern: `profile 'prod' not found, must be one of: dev`,
+		},
+		{
+			name:    "delete_unknown_profile",
+			args:    []string{"delete", "prod"},
+			pattern: `profile 'prod' not found, must be one of: dev`,
+		},
+		{
+			name:    "show_unknown_profile",
+			args:    []string{"show", "prod"},
+			pattern: `profile 'prod' not found, must be one of: dev`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := parseArgs(t, tt.args...)
+			testlib.AssertExit(t, 2, tt.pattern, func() { Run(opts) })
+		})
+	}
+
+	t.Run("malformed_config_file", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		opts := parseArgs(t, "list")
+		testlib.AssertExit(t, 2, `error reading config file`, func() { Run(opts) })
+	})
+}
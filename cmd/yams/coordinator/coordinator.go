@@ -0,0 +1,33 @@
+package coordinator
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/pkg/distributed"
+)
+
+// Logic for the "coordinator" subcommand
+func Run(opts *cli.Flags) {
+	if opts.PartitionSize < 1 {
+		cli.Fail("error: -partition-size must be at least 1")
+	}
+	if opts.LeaseTimeout < 1 {
+		cli.Fail("error: -lease-timeout must be at least 1")
+	}
+
+	coordinator := distributed.NewCoordinator()
+	coordinator.PartitionSize = opts.PartitionSize
+	coordinator.LeaseTimeout = time.Second * time.Duration(opts.LeaseTimeout)
+
+	slog.Info("coordinator started",
+		"addr", opts.Addr,
+		"partition_size", coordinator.PartitionSize,
+		"lease_timeout", coordinator.LeaseTimeout)
+	err := http.ListenAndServe(opts.Addr, coordinator)
+	if err != nil {
+		cli.Fail("error from coordinator: %v", err)
+	}
+}
@@ -1,131 +1,128 @@
 package inventory
 
 import (
-	"io"
-	"log/slog"
-	"net/http"
+	"context"
 
-	json "github.com/bytedance/sonic"
 	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/cmd/yams/local"
+	"github.com/nsiow/yams/pkg/client"
 )
 
-// inventoryItem represents a generic inventory entity for table display
-type inventoryItem struct {
-	Arn       string `json:"Arn"`
-	Name      string `json:"Name"`
-	Type      string `json:"Type"`
-	AccountId string `json:"AccountId"`
-	Region    string `json:"Region"`
-}
-
 // Logic for the various entity-centric subcommands (accounts, resources, principals, etc)
 func Run(entity string, opts *cli.Flags) {
-	cli.RequireServer(opts.Server)
+	c := local.Connect(opts)
+	ctx := context.Background()
+	kind := client.Kind(entity)
 
-	var url string
+	// single entities are always rendered as JSON
 	if opts.Key != "" {
-		url = cli.ApiUrl(opts.Server, entity, opts.Key)
-	} else if opts.Query != "" {
-		url = cli.ApiUrl(opts.Server, entity, "search", opts.Query)
-	} else {
-		url = cli.ApiUrl(opts.Server, entity)
-	}
-
-	if opts.Freeze && entity != "actions" {
-		url += "/freeze"
-	}
-
-	if opts.Format == cli.FormatTable {
-		getAndRenderTable(url, entity)
-	} else {
-		cli.GetReq(url)
-	}
-}
-
-func getAndRenderTable(url, entity string) {
-	slog.Debug("making request", "url", url)
-	resp, err := http.Get(url)
-	if err != nil {
-		cli.Fail("error retrieving URL '%s': %v", url, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		cli.Fail("received non-200 status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		cli.Fail("error reading body of URL '%s': %v", url, err)
+		obj, err := Lookup(ctx, c, kind, opts.Key, opts.Freeze)
+		if err != nil {
+			cli.Fail("error retrieving %s '%s': %v", entity, opts.Key, err)
+		}
+		cli.PrintJSON(obj)
+		return
 	}
 
-	// Try to parse as array
-	var items []inventoryItem
-	if err := json.Unmarshal(body, &items); err != nil {
-		// Fall back to JSON output if parsing fails
-		cli.OutputJSON(body)
+	if opts.Query != "" {
+		keys, err := c.Search(ctx, kind, opts.Query)
+		if err != nil {
+			cli.Fail("error searching %s: %v", entity, err)
+		}
+		render(opts, keys, keysTable(kind, keys))
 		return
 	}
 
-	// Render as table based on entity type
-	switch entity {
-	case "principals":
-		renderPrincipalsTable(items)
-	case "resources":
-		renderResourcesTable(items)
-	case "accounts":
-		renderAccountsTable(items)
-	case "policies":
-		renderPoliciesTable(items)
-	case "actions":
-		renderActionsTable(body)
+	switch kind {
+	case client.KindAccounts:
+		accounts, err := c.ListAccounts(ctx)
+		if err != nil {
+			cli.Fail("error listing %s: %v", entity, err)
+		}
+		t := cli.NewTableWriter("AccountId", "Name")
+		for _, a := range accounts {
+			t.AddRow(a.Id, a.Name)
+		}
+		render(opts, accounts, t)
+	case client.KindPolicies:
+		policies, err := c.ListPolicies(ctx)
+		if err != nil {
+			cli.Fail("error listing %s: %v", entity, err)
+		}
+		t := cli.NewTableWriter("Name", "Arn")
+		for _, p := range policies {
+			arn := p.Arn
+			if opts.Format == cli.FormatTable {
+				arn = cli.Truncate(arn, 80)
+			}
+			t.AddRow(p.Name, arn)
+		}
+		render(opts, policies, t)
 	default:
-		cli.OutputJSON(body)
-	}
-}
-
-func renderPrincipalsTable(items []inventoryItem) {
-	t := cli.NewTableWriter("Type", "Name", "Account", "Arn")
-	for _, item := range items {
-		t.AddRow(item.Type, item.Name, item.AccountId, cli.Truncate(item.Arn, 60))
+		keys, err := c.Keys(ctx, kind)
+		if err != nil {
+			cli.Fail("error listing %s: %v", entity, err)
+		}
+		render(opts, keys, keysTable(kind, keys))
 	}
-	t.Render()
 }
 
-func renderResourcesTable(items []inventoryItem) {
-	t := cli.NewTableWriter("Type", "Name", "Account", "Region", "Arn")
-	for _, item := range items {
-		t.AddRow(item.Type, item.Name, item.AccountId, item.Region, cli.Truncate(item.Arn, 50))
+// Lookup retrieves a single entity of the provided kind, optionally in its frozen form
+func Lookup(ctx context.Context, c *client.Client, kind client.Kind, key string, freeze bool) (any, error) {
+	switch kind {
+	case client.KindAccounts:
+		if freeze {
+			return c.FreezeAccount(ctx, key)
+		}
+		return c.GetAccount(ctx, key)
+	case client.KindGroups:
+		if freeze {
+			return c.FreezeGroup(ctx, key)
+		}
+		return c.GetGroup(ctx, key)
+	case client.KindPrincipals:
+		if freeze {
+			return c.FreezePrincipal(ctx, key)
+		}
+		return c.GetPrincipal(ctx, key)
+	case client.KindResources:
+		if freeze {
+			return c.FreezeResource(ctx, key)
+		}
+		return c.GetResource(ctx, key)
+	case client.KindPolicies:
+		// managed policies have no separate frozen form
+		return c.GetPolicy(ctx, key)
+	default:
+		return c.GetAction(ctx, key)
 	}
-	t.Render()
 }
 
-func renderAccountsTable(items []inventoryItem) {
-	t := cli.NewTableWriter("AccountId", "Name", "Arn")
-	for _, item := range items {
-		t.AddRow(item.AccountId, item.Name, item.Arn)
+// render outputs the object as JSON, or its table in any other requested format
+func render(opts *cli.Flags, obj any, table *cli.TableWriter) {
+	if opts.Format == "" || opts.Format == cli.FormatJSON {
+		cli.PrintJSON(obj)
+		return
 	}
-	t.Render()
-}
 
-func renderPoliciesTable(items []inventoryItem) {
-	t := cli.NewTableWriter("Type", "Name", "Account", "Arn")
-	for _, item := range items {
-		t.AddRow(item.Type, item.Name, item.AccountId, cli.Truncate(item.Arn, 60))
+	if err := table.RenderFormat(opts.Format); err != nil {
+		cli.Fail("error writing output: %v", err)
 	}
-	t.Render()
 }
 
-func renderActionsTable(body []byte) {
-	var actions []string
-	if err := json.Unmarshal(body, &actions); err != nil {
-		cli.OutputJSON(body)
-		return
+// keysTable builds a single-column table of entity keys
+func keysTable(kind client.Kind, keys []string) *cli.TableWriter {
+	header := "Arn"
+	switch kind {
+	case client.KindAccounts:
+		header = "AccountId"
+	case client.KindActions:
+		header = "Action"
 	}
 
-	t := cli.NewTableWriter("Action")
-	for _, action := range actions {
-		t.AddRow(action)
+	t := cli.NewTableWriter(header)
+	for _, key := range keys {
+		t.AddRow(key)
 	}
-	t.Render()
+	return t
 }
@@ -0,0 +1,140 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/client"
+	"github.com/nsiow/yams/pkg/server"
+)
+
+// Connect returns a client for the commands which query a yams server. If sources were provided
+// with -s/-source, a server is instead run in-process over them so that no separate server is
+// needed; otherwise the configured server must be reachable
+func Connect(opts *cli.Flags) *client.Client {
+	if len(opts.Sources) == 0 {
+		cli.RequireServer(opts.Server, opts.Token)
+		return cli.NewClient(opts.Server, opts.Token)
+	}
+
+	c, err := NewClient(opts)
+	if err != nil {
+		cli.Fail("%v", err)
+	}
+	return c
+}
+
+// NewClient returns a client for a server run in-process over the sources provided by -s/-source
+func NewClient(opts *cli.Flags) (*client.Client, error) {
+	srv, err := NewServer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.New("local", client.WithHTTPClient(&http.Client{
+		Transport: handlerTransport{srv.Handler},
+	})), nil
+}
+
+// NewServer creates a server which is not listening on any address, with the sources provided by
+// -s/-source loaded (and, if -cache was provided, cached on disk between invocations)
+func NewServer(opts *cli.Flags) (*server.Server, error) {
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		return nil, fmt.Errorf("error creating local server: %w", err)
+	}
+
+	var cacheDir string
+	if opts.Cache {
+		cacheDir, err = CacheDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, src := range opts.Sources {
+		reader, err := smartrw.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("error when initializing reader for source '%s': %w", src, err)
+		}
+
+		err = srv.AddSource(&server.Source{Reader: *reader, CacheDir: cacheDir})
+		if err != nil {
+			return nil, fmt.Errorf("error attempting to load source '%s': %w", src, err)
+		}
+	}
+
+	return srv, nil
+}
+
+// CacheDir returns the directory in which loaded sources are cached: $YAMS_CACHE_DIR if set, or
+// else a 'yams' directory within the user's cache directory
+func CacheDir() (string, error) {
+	if dir := os.Getenv("YAMS_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine cache directory, set YAMS_CACHE_DIR: %w", err)
+	}
+	return filepath.Join(dir, "yams", "sources"), nil
+}
+
+// handlerTransport serves requests directly from an http.Handler, without a network connection
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+
+	w := &responseWriter{header: make(http.Header)}
+	t.handler.ServeHTTP(w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          io.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// responseWriter buffers the response written by a handler
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
@@ -2,20 +2,35 @@ package main
 
 import (
 	"log/slog"
+	"os"
 
 	"github.com/nsiow/yams/cmd/yams/audit"
 	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/cmd/yams/complete"
+	"github.com/nsiow/yams/cmd/yams/config"
+	"github.com/nsiow/yams/cmd/yams/coordinator"
 	"github.com/nsiow/yams/cmd/yams/dump"
 	"github.com/nsiow/yams/cmd/yams/inventory"
+	"github.com/nsiow/yams/cmd/yams/overlay"
 	"github.com/nsiow/yams/cmd/yams/server"
+	"github.com/nsiow/yams/cmd/yams/shell"
 	"github.com/nsiow/yams/cmd/yams/sim"
 	"github.com/nsiow/yams/cmd/yams/status"
+	"github.com/nsiow/yams/cmd/yams/watch"
+	"github.com/nsiow/yams/cmd/yams/worker"
 )
 
 func main() {
 	// Set up CLI logging
 	cli.InitLogging()
 
+	// Dynamic completions are requested by the completion scripts, with partial command lines which
+	// are not parsed as usual
+	if len(os.Args) >= 2 && os.Args[1] == cli.COMPLETE_COMMAND {
+		complete.Run(os.Args[2:])
+		return
+	}
+
 	// Parse CLI arguments
 	flags, err := cli.Parse()
 	if err != nil {
@@ -42,6 +57,18 @@ func main() {
 		sim.Run(flags)
 	case cli.RUN_MODE_AUDIT:
 		audit.Run(flags)
+	case cli.RUN_MODE_OVERLAY:
+		overlay.Run(flags)
+	case cli.RUN_MODE_SHELL:
+		shell.Run(flags)
+	case cli.RUN_MODE_CONFIG:
+		config.Run(flags)
+	case cli.RUN_MODE_COORDINATOR:
+		coordinator.Run(flags)
+	case cli.RUN_MODE_WORKER:
+		worker.Run(flags)
+	case cli.RUN_MODE_WATCH:
+		watch.Run(flags)
 	default:
 		cli.Fail("unknown mode: %s", flags.Mode)
 	}
@@ -0,0 +1,135 @@
+package overlay
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/loaders/awsconfig"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
+)
+
+// diffJSON encodes generated overlays with sorted keys, so that output is stable between runs
+var diffJSON = json.Config{SortMapKeys: true}.Froze()
+
+// Diff generates an overlay containing the entities which changed between two sets of sources,
+// plus tombstones for those which were removed. The overlay is written as a request body for
+// creating a stored overlay, which can also be passed to `yams sim -overlay`
+func Diff(opts *cli.Flags) {
+	if len(opts.BaseSources) == 0 {
+		cli.Fail("missing required flag: -b/--base")
+	}
+	if len(opts.NewSources) == 0 {
+		cli.Fail("missing required flag: -n/--new")
+	}
+
+	base, err := loadUniverse(opts.BaseSources)
+	if err != nil {
+		cli.Fail("error loading base sources: %v", err)
+	}
+	next, err := loadUniverse(opts.NewSources)
+	if err != nil {
+		cli.Fail("error loading new sources: %v", err)
+	}
+
+	name := opts.OverlayName
+	if name == "" {
+		name = fmt.Sprintf("diff %s..%s", sourceNames(opts.BaseSources), sourceNames(opts.NewSources))
+	}
+
+	data := (&entities.Overlay{Universe: entities.DiffUniverses(base, next)}).ToData()
+	sortData(&data)
+	message := fmt.Sprintf("Changes from %s to %s",
+		strings.Join(opts.BaseSources, ", "), strings.Join(opts.NewSources, ", "))
+
+	input := v1.CreateOverlayInput{
+		Name:       name,
+		Message:    message,
+		Accounts:   data.Accounts,
+		Groups:     data.Groups,
+		Policies:   data.Policies,
+		Principals: data.Principals,
+		Resources:  data.Resources,
+		Tombstones: data.Tombstones,
+	}
+	slog.Info("generated overlay",
+		"accounts", len(input.Accounts),
+		"groups", len(input.Groups),
+		"policies", len(input.Policies),
+		"principals", len(input.Principals),
+		"resources", len(input.Resources),
+		"tombstones", len(input.Tombstones))
+
+	out, err := diffJSON.MarshalIndent(input, "", "  ")
+	if err != nil {
+		cli.Fail("error encoding overlay: %v", err)
+	}
+
+	writer, err := smartrw.NewWriter(opts.Out)
+	if err != nil {
+		cli.Fail("invalid destination '%s': %v", opts.Out, err)
+	}
+
+	_, err = writer.Write(append(out, '\n'))
+	if err != nil {
+		cli.Fail("error writing to destination '%s': %v", opts.Out, err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		cli.Fail("error closing/flushing to destination '%s': %v", opts.Out, err)
+	}
+}
+
+// loadUniverse loads and merges the universes of the provided sources
+func loadUniverse(sources []string) (*entities.Universe, error) {
+	uv := entities.NewUniverse()
+	for _, src := range sources {
+		reader, err := smartrw.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open source '%s': %w", src, err)
+		}
+
+		loaded, err := awsconfig.LoadSource(reader.Source, reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load source '%s': %w", src, err)
+		}
+
+		uv.Merge(loaded)
+		slog.Info("loaded source", "source", src, "size", uv.Size())
+	}
+
+	return uv, nil
+}
+
+// sortData sorts the entities of an overlay by their keys
+func sortData(data *entities.OverlayData) {
+	slices.SortFunc(data.Accounts, func(a, b entities.Account) int { return cmp.Compare(a.Id, b.Id) })
+	slices.SortFunc(data.Groups, func(a, b entities.Group) int { return cmp.Compare(a.Arn, b.Arn) })
+	slices.SortFunc(data.Policies, func(a, b entities.ManagedPolicy) int {
+		return cmp.Compare(a.Arn, b.Arn)
+	})
+	slices.SortFunc(data.Principals, func(a, b entities.Principal) int {
+		return cmp.Compare(a.Arn, b.Arn)
+	})
+	slices.SortFunc(data.Resources, func(a, b entities.Resource) int {
+		return cmp.Compare(a.Arn, b.Arn)
+	})
+}
+
+// sourceNames summarizes a list of sources by their base names
+func sourceNames(sources []string) string {
+	names := make([]string, len(sources))
+	for i, src := range sources {
+		names[i] = path.Base(src)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,173 @@
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/internal/testlib"
+	"github.com/nsiow/yams/pkg/entities"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
+)
+
+// Sources of the real-world test data; the old snapshot predates several principals, policies and
+// resources of the current one
+const (
+	oldSource     = "../../../testdata/real-world/awsconfig.old.jsonl.gz"
+	currentSource = "../../../testdata/real-world/awsconfig.jsonl"
+	orgSource     = "../../../testdata/real-world/org.jsonl"
+)
+
+// diff runs the diff command, returning its raw and decoded output
+func diff(t *testing.T, args ...string) ([]byte, v1.CreateOverlayInput) {
+	t.Helper()
+
+	out := filepath.Join(t.TempDir(), "overlay.json")
+	Run(parseArgs(t, append([]string{"diff", "-o", out}, args...)...))
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	var input v1.CreateOverlayInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+	return data, input
+}
+
+// counts summarizes the number of each kind of entity in a generated overlay
+func counts(in v1.CreateOverlayInput) map[string]int {
+	return map[string]int{
+		"accounts":   len(in.Accounts),
+		"groups":     len(in.Groups),
+		"policies":   len(in.Policies),
+		"principals": len(in.Principals),
+		"resources":  len(in.Resources),
+		"tombstones": len(in.Tombstones),
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tests
+// -------------------------------------------------------------------------------------------------
+
+func TestDiff(t *testing.T) {
+	isolate(t)
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantName   string
+		wantCounts map[string]int
+	}{
+		{
+			name:     "added",
+			args:     []string{"-b", oldSource, "-n", currentSource},
+			wantName: "diff awsconfig.old.jsonl.gz..awsconfig.jsonl",
+			wantCounts: map[string]int{
+				"accounts": 0, "groups": 0, "policies": 5,
+				"principals": 10, "resources": 25, "tombstones": 0,
+			},
+		},
+		{
+			name:     "removed",
+			args:     []string{"--base", currentSource, "--new", oldSource, "--name", "rollback"},
+			wantName: "rollback",
+			wantCounts: map[string]int{
+				"accounts": 0, "groups": 0, "policies": 0,
+				"principals": 2, "resources": 0, "tombstones": 25,
+			},
+		},
+		{
+			name:     "multiple_sources",
+			args:     []string{"-b", currentSource, "-n", currentSource, "-n", orgSource},
+			wantName: "diff awsconfig.jsonl..awsconfig.jsonl,org.jsonl",
+			wantCounts: map[string]int{
+				"accounts": 4, "groups": 0, "policies": 3,
+				"principals": 0, "resources": 3, "tombstones": 0,
+			},
+		},
+		{
+			name:     "unchanged",
+			args:     []string{"-b", currentSource, "-n", currentSource},
+			wantName: "diff awsconfig.jsonl..awsconfig.jsonl",
+			wantCounts: map[string]int{
+				"accounts": 0, "groups": 0, "policies": 0,
+				"principals": 0, "resources": 0, "tombstones": 0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, got := diff(t, tt.args...)
+			if got.Name != tt.wantName {
+				t.Errorf("wanted name %q, got %q", tt.wantName, got.Name)
+			}
+			if !strings.HasPrefix(got.Message, "Changes from ") {
+				t.Errorf("expected message describing the sources, got %q", got.Message)
+			}
+			if c := counts(got); !reflect.DeepEqual(c, tt.wantCounts) {
+				t.Errorf("wanted counts %v, got %v", tt.wantCounts, c)
+			}
+
+			// entities are sorted, so that output is identical between runs
+			if !slices.IsSortedFunc(got.Principals, func(a, b entities.Principal) int {
+				return strings.Compare(string(a.Arn), string(b.Arn))
+			}) {
+				t.Errorf("expected principals to be sorted by ARN")
+			}
+			if !slices.IsSortedFunc(got.Resources, func(a, b entities.Resource) int {
+				return strings.Compare(string(a.Arn), string(b.Arn))
+			}) {
+				t.Errorf("expected resources to be sorted by ARN")
+			}
+			if again, _ := diff(t, tt.args...); string(again) != string(data) {
+				t.Errorf("expected identical output between runs")
+			}
+		})
+	}
+}
+
+func TestDiff_Errors(t *testing.T) {
+	isolate(t)
+
+	tests := []struct {
+		name    string
+		args    []string
+		pattern string
+	}{
+		{
+			name:    "missing_base",
+			args:    []string{"-n", currentSource},
+			pattern: `missing required flag: -b/--base`,
+		},
+		{
+			name:    "missing_new",
+			args:    []string{"-b", oldSource},
+			pattern: `missing required flag: -n/--new`,
+		},
+		{
+			name:    "unreadable_base",
+			args:    []string{"-b", "missing.jsonl", "-n", currentSource},
+			pattern: `error loading base sources: unable to open source 'missing.jsonl'`,
+		},
+		{
+			name:    "unreadable_new",
+			args:    []string{"-b", oldSource, "-n", "missing.jsonl"},
+			pattern: `error loading new sources: unable to open source 'missing.jsonl'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := parseArgs(t, append([]string{"diff"}, tt.args...)...)
+			testlib.AssertExit(t, 2, tt.pattern, func() { Run(opts) })
+		})
+	}
+}
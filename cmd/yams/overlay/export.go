@@ -0,0 +1,38 @@
+package overlay
+
+import (
+	"context"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/smartrw"
+)
+
+// Export renders a stored overlay as infrastructure code, writing it to the requested destination
+func Export(opts *cli.Flags) {
+	if opts.OverlayID == "" {
+		cli.Fail("missing required flag: -i/--id")
+	}
+
+	cli.RequireServer(opts.Server, opts.Token)
+
+	out, err := cli.NewClient(opts.Server, opts.Token).ExportOverlay(
+		context.Background(), opts.OverlayID, opts.ExportFormat, opts.Revision)
+	if err != nil {
+		cli.Fail("error exporting overlay '%s': %v", opts.OverlayID, err)
+	}
+
+	writer, err := smartrw.NewWriter(opts.Out)
+	if err != nil {
+		cli.Fail("invalid destination '%s': %v", opts.Out, err)
+	}
+
+	_, err = writer.Write(out)
+	if err != nil {
+		cli.Fail("error writing to destination '%s': %v", opts.Out, err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		cli.Fail("error closing/flushing to destination '%s': %v", opts.Out, err)
+	}
+}
@@ -0,0 +1,150 @@
+package overlay
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/testlib"
+)
+
+// exportedID is the ID of the only overlay known to the fake server
+const exportedID = "11111111-1111-1111-1111-111111111111"
+
+// newExportServer starts a fake server which exports the overlay with exportedID, describing the
+// requested format and revision in place of the exported code
+func newExportServer(t *testing.T) string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{}`)
+	})
+	export := func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != exportedID {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": "overlay not found"}`)
+			return
+		}
+		query := r.URL.Query()
+		fmt.Fprintf(w, "# format=%s revision=%s\n", query.Get("format"), query.Get("revision"))
+	}
+	mux.HandleFunc("GET /api/v1/overlays/{id}/export", export)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// isolate points config file lookups and environment overrides at an empty directory
+func isolate(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	for _, env := range []string{"YAMS_SERVER_ADDRESS", "YAMS_TOKEN", "YAMS_PROFILE"} {
+		t.Setenv(env, "")
+	}
+}
+
+// parseArgs parses the command line of an overlay command
+func parseArgs(t *testing.T, args ...string) *cli.Flags {
+	t.Helper()
+
+	opts, err := cli.ParseArgs(append([]string{"yams", "overlay"}, args...))
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	return opts
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tests
+// -------------------------------------------------------------------------------------------------
+
+func TestExport(t *testing.T) {
+	isolate(t)
+	addr := newExportServer(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "defaults",
+			args: []string{"-i", exportedID},
+			want: "# format=terraform revision=\n",
+		},
+		{
+			name: "format_and_revision",
+			args: []string{"-i", exportedID, "-f", "cloudformation", "-r", "2"},
+			want: "# format=cloudformation revision=2\n",
+		},
+		{
+			name: "long_flags",
+			args: []string{"--id", exportedID, "--format", "awsconfig", "--revision", "3"},
+			want: "# format=awsconfig revision=3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "main.tf")
+			args := append([]string{"export", "--server", addr, "-o", out}, tt.args...)
+			Run(parseArgs(t, args...))
+
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("failed to read output: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("wanted %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExport_Errors(t *testing.T) {
+	isolate(t)
+	addr := newExportServer(t)
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name    string
+		args    []string
+		pattern string
+	}{
+		{
+			name:    "missing_id",
+			args:    []string{"--server", addr},
+			pattern: `missing required flag: -i/--id`,
+		},
+		{
+			name:    "unknown_overlay",
+			args:    []string{"--server", addr, "-i", "missing"},
+			pattern: `error exporting overlay 'missing': .*overlay not found`,
+		},
+		{
+			name:    "unreachable_server",
+			args:    []string{"--server", unreachable.URL, "-i", exportedID},
+			pattern: `cannot connect to yams server`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := parseArgs(t, append([]string{"export"}, tt.args...)...)
+			testlib.AssertExit(t, 2, tt.pattern, func() { Run(opts) })
+		})
+	}
+}
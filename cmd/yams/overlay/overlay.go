@@ -0,0 +1,17 @@
+package overlay
+
+import (
+	"github.com/nsiow/yams/cmd/yams/cli"
+)
+
+// Logic for the "overlay" subcommand, which dispatches to the requested overlay command
+func Run(opts *cli.Flags) {
+	switch opts.OverlayCommand {
+	case cli.OVERLAY_COMMAND_EXPORT:
+		Export(opts)
+	case cli.OVERLAY_COMMAND_DIFF:
+		Diff(opts)
+	default:
+		cli.Fail("unknown overlay command: %s", opts.OverlayCommand)
+	}
+}
@@ -1,11 +1,13 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"time"
 
 	"github.com/nsiow/yams/cmd/yams/cli"
 	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/overlay"
 	"github.com/nsiow/yams/pkg/server"
 )
 
@@ -23,8 +25,9 @@ func Run(opts *cli.Flags) {
 		}
 
 		source := server.Source{
-			Reader:  *reader,
-			Refresh: time.Second * time.Duration(opts.Refresh),
+			Reader:      *reader,
+			Refresh:     time.Second * time.Duration(opts.Refresh),
+			Conditional: opts.Conditional,
 		}
 
 		err = srv.AddSource(&source)
@@ -33,6 +36,31 @@ func Run(opts *cli.Flags) {
 		}
 	}
 
+	if opts.NotifyQueue != "" {
+		feed, err := server.NewSQSFeed(opts.NotifyQueue)
+		if err != nil {
+			cli.Fail("error creating change notification feed: %v", err)
+		}
+
+		slog.Info("watching for source changes", "queue", opts.NotifyQueue)
+		go srv.Watch(context.Background(), feed)
+	}
+
+	if opts.OverlaySweep > 0 {
+		interval := time.Second * time.Duration(opts.OverlaySweep)
+		go overlay.Sweep(context.Background(), srv.OverlayStore, interval)
+	}
+
+	if srv.GRPC != nil {
+		go func() {
+			slog.Info("gRPC server started", "addr", opts.GrpcAddr)
+			err := srv.ListenAndServeGRPC()
+			if err != nil {
+				cli.Fail("error from gRPC server: %v", err)
+			}
+		}()
+	}
+
 	slog.Info("server started", "addr", opts.Addr)
 	err = srv.ListenAndServe()
 	if err != nil {
@@ -0,0 +1,461 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/cmd/yams/inventory"
+	"github.com/nsiow/yams/cmd/yams/sim"
+	"github.com/nsiow/yams/pkg/client"
+	yamssim "github.com/nsiow/yams/pkg/sim"
+)
+
+// errExit is returned by commands which end the session
+var errExit = errors.New("exit")
+
+// command is a command available within the shell
+type command struct {
+	name        string
+	aliases     []string
+	usage       string
+	description string
+	run         func(s *session, args []string) error
+
+	// args completes each positional argument; the last completer is reused for any further
+	// arguments
+	args []completer
+}
+
+// commands lists the available commands, in the order they are shown by 'help'
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			name:        "sim",
+			usage:       "sim <principal> <action> [resource]",
+			description: "Simulate a request, or repeat the last simulation",
+			run:         simulate,
+			args:        []completer{principals, actions, resources},
+		},
+		{
+			name:        "explain",
+			usage:       "explain [<principal> <action> [resource]]",
+			description: "Simulate, explaining the decision; defaults to the last simulation",
+			run:         explain,
+			args:        []completer{principals, actions, resources},
+		},
+		{
+			name:        "trace",
+			usage:       "trace [<principal> <action> [resource]]",
+			description: "Simulate with a full evaluation trace; defaults to the last simulation",
+			run:         trace,
+			args:        []completer{principals, actions, resources},
+		},
+		{
+			name:        "which-principals",
+			usage:       "which-principals <action> <resource>",
+			description: "List the principals allowed to perform an action on a resource",
+			run:         whichPrincipals,
+			args:        []completer{actions, resources},
+		},
+		{
+			name:        "which-actions",
+			usage:       "which-actions <principal> <resource>",
+			description: "List the actions a principal is allowed to perform on a resource",
+			run:         whichActions,
+			args:        []completer{principals, resources},
+		},
+		{
+			name:        "which-resources",
+			usage:       "which-resources <principal> <action>",
+			description: "List the resources on which a principal is allowed to perform an action",
+			run:         whichResources,
+			args:        []completer{principals, actions},
+		},
+		{
+			name:        "show",
+			usage:       "show <kind> <key> | context | overlays",
+			description: "Show an entity, or the context and overlays used for simulations",
+			run:         show,
+			args:        []completer{showTargets, entityKeys},
+		},
+		{
+			name:        "freeze",
+			usage:       "freeze <principal|resource|group|account> <key>",
+			description: "Show an entity with all of its references resolved",
+			run:         freeze,
+			args:        []completer{frozenKinds, entityKeys},
+		},
+		{
+			name:        "search",
+			usage:       "search <kind> <query>",
+			description: "Search for entities whose keys contain the query",
+			run:         search,
+			args:        []completer{oneOf(kindNames()...), none},
+		},
+		{
+			name:        "set",
+			usage:       "set context <key=value>... | exact <on|off> | format <format>",
+			description: "Set request context, fuzzy matching of ARNs or the output format",
+			run:         set,
+			args:        []completer{oneOf("context", "exact", "format"), settings},
+		},
+		{
+			name:        "unset",
+			usage:       "unset context [key]... | overlays",
+			description: "Remove request context (all of it if no keys are given), or all overlays",
+			run:         unset,
+			args:        []completer{oneOf("context", "overlays"), settings},
+		},
+		{
+			name:        "use",
+			usage:       "use overlay <file|id|name>",
+			description: "Simulate against an overlay file or stored overlay, on top of any others",
+			run:         use,
+			args:        []completer{oneOf("overlay"), storedOverlays},
+		},
+		{
+			name:        "status",
+			usage:       "status",
+			description: "Show server status and loaded data sources",
+			run:         status,
+		},
+		{
+			name:        "help",
+			usage:       "help",
+			description: "Show this help message",
+			run:         help,
+		},
+		{
+			name:        "exit",
+			aliases:     []string{"quit"},
+			usage:       "exit",
+			description: "Leave the shell",
+			run:         func(*session, []string) error { return errExit },
+		},
+	}
+}
+
+// lookupCommand finds a command by name or alias
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name || slices.Contains(cmd.aliases, name) {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// usageError reports incorrect usage of a command
+func usageError(name string) error {
+	cmd, _ := lookupCommand(name)
+	return fmt.Errorf("usage: %s", cmd.usage)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Simulation
+// -------------------------------------------------------------------------------------------------
+
+func simulate(s *session, args []string) error {
+	return s.simulate("sim", args, false, false)
+}
+
+func explain(s *session, args []string) error {
+	return s.simulate("explain", args, true, false)
+}
+
+func trace(s *session, args []string) error {
+	return s.simulate("trace", args, false, true)
+}
+
+// simulate runs a simulation, remembering the principal, action and resource for later commands
+func (s *session) simulate(name string, args []string, explain, trace bool) error {
+	switch len(args) {
+	case 0:
+		if s.opts.Principal == "" || s.opts.Action == "" {
+			return errors.New("no previous simulation to repeat")
+		}
+	case 2:
+		s.opts.Principal, s.opts.Action, s.opts.Resource = args[0], args[1], ""
+	case 3:
+		s.opts.Principal, s.opts.Action, s.opts.Resource = args[0], args[1], args[2]
+	default:
+		return usageError(name)
+	}
+
+	s.opts.Explain, s.opts.Trace = explain, trace
+	return s.query()
+}
+
+func whichPrincipals(s *session, args []string) error {
+	if len(args) != 2 {
+		return usageError("which-principals")
+	}
+
+	s.opts.Principal, s.opts.Action, s.opts.Resource = "", args[0], args[1]
+	return s.query()
+}
+
+func whichActions(s *session, args []string) error {
+	if len(args) != 2 {
+		return usageError("which-actions")
+	}
+
+	s.opts.Principal, s.opts.Action, s.opts.Resource = args[0], "", args[1]
+	return s.query()
+}
+
+func whichResources(s *session, args []string) error {
+	if len(args) != 2 {
+		return usageError("which-resources")
+	}
+
+	s.opts.Principal, s.opts.Action, s.opts.Resource = args[0], args[1], ""
+	return s.query()
+}
+
+// query runs the query described by the session's options, exactly as `yams sim` would
+func (s *session) query() error {
+	out, err := sim.Query(s.client, s.opts)
+	if err != nil {
+		return err
+	}
+
+	return sim.Render(s.opts, out)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Entities
+// -------------------------------------------------------------------------------------------------
+
+// kinds maps the names accepted for entity kinds to the kind itself
+var kinds = map[string]client.Kind{
+	"principal": client.KindPrincipals,
+	"resource":  client.KindResources,
+	"policy":    client.KindPolicies,
+	"group":     client.KindGroups,
+	"account":   client.KindAccounts,
+	"action":    client.KindActions,
+}
+
+// showTargets completes the first argument of 'show'
+var showTargets = oneOf(append(kindNames(), "context", "overlays")...)
+
+// frozenKinds completes the kinds of entities which have a frozen form
+var frozenKinds = oneOf("principal", "resource", "group", "account")
+
+// kindNames returns the names of entity kinds, in the order they are listed in usage
+func kindNames() []string {
+	return []string{"principal", "resource", "policy", "group", "account", "action"}
+}
+
+// parseKind returns the entity kind with the provided singular or plural name
+func parseKind(name string) (client.Kind, bool) {
+	kind, ok := kinds[strings.TrimSuffix(strings.ToLower(name), "s")]
+	if !ok && strings.EqualFold(name, "policies") {
+		kind, ok = client.KindPolicies, true
+	}
+	return kind, ok
+}
+
+func show(s *session, args []string) error {
+	if len(args) == 1 {
+		switch args[0] {
+		case "context":
+			cli.PrintJSON(s.opts.Context)
+			return nil
+		case "overlays":
+			cli.PrintJSON(map[string][]string{
+				"files": s.opts.OverlayFiles,
+				"ids":   s.opts.OverlayIDs,
+			})
+			return nil
+		}
+	}
+
+	return s.lookup("show", args, false)
+}
+
+func freeze(s *session, args []string) error {
+	return s.lookup("freeze", args, true)
+}
+
+// lookup prints the entity with the provided kind and key, which is fuzzy-matched against known
+// keys unless exact matching is enabled
+func (s *session) lookup(name string, args []string, frozen bool) error {
+	if len(args) != 2 {
+		return usageError(name)
+	}
+
+	kind, ok := parseKind(args[0])
+	if !ok {
+		return fmt.Errorf("unknown entity kind '%s', must be one of: %s",
+			args[0], strings.Join(kindNames(), ", "))
+	}
+
+	key := args[1]
+	if !s.opts.Exact && kind != client.KindActions {
+		keys := s.entityKeys(kind)
+		if !slices.Contains(keys, key) {
+			matches := yamssim.FuzzyMatchArns(keys, key, 10)
+			if len(matches) == 1 {
+				key = matches[0]
+			} else if len(matches) > 1 {
+				return fmt.Errorf("too many matches for '%s': %v", key, matches)
+			}
+		}
+	}
+
+	obj, err := inventory.Lookup(s.ctx, s.client, kind, key, frozen)
+	if err != nil {
+		return fmt.Errorf("error retrieving %s '%s': %w", args[0], key, err)
+	}
+
+	cli.PrintJSON(obj)
+	return nil
+}
+
+func search(s *session, args []string) error {
+	if len(args) != 2 {
+		return usageError("search")
+	}
+
+	kind, ok := parseKind(args[0])
+	if !ok {
+		return fmt.Errorf("unknown entity kind '%s', must be one of: %s",
+			args[0], strings.Join(kindNames(), ", "))
+	}
+
+	keys, err := s.client.Search(s.ctx, kind, args[1])
+	if err != nil {
+		return fmt.Errorf("error searching %s: %w", kind, err)
+	}
+
+	cli.PrintJSON(keys)
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Session state
+// -------------------------------------------------------------------------------------------------
+
+func set(s *session, args []string) error {
+	if len(args) < 2 {
+		return usageError("set")
+	}
+
+	switch args[0] {
+	case "context":
+		for _, kv := range args[1:] {
+			if err := s.opts.Context.Set(kv); err != nil {
+				return err
+			}
+		}
+	case "exact":
+		switch args[1] {
+		case "on", "true":
+			s.opts.Exact = true
+		case "off", "false":
+			s.opts.Exact = false
+		default:
+			return usageError("set")
+		}
+	case "format":
+		if !slices.Contains(cli.Formats, args[1]) {
+			return fmt.Errorf("unsupported output format '%s', must be one of: %s",
+				args[1], strings.Join(cli.Formats, ", "))
+		}
+		s.opts.Format = args[1]
+	default:
+		return usageError("set")
+	}
+
+	return nil
+}
+
+func unset(s *session, args []string) error {
+	if len(args) == 0 {
+		return usageError("unset")
+	}
+
+	switch args[0] {
+	case "context":
+		if len(args) == 1 {
+			clear(s.opts.Context)
+		}
+		for _, key := range args[1:] {
+			delete(s.opts.Context, key)
+		}
+	case "overlays":
+		s.opts.OverlayFiles, s.opts.Overlays, s.opts.OverlayIDs = nil, nil, nil
+	default:
+		return usageError("unset")
+	}
+
+	return nil
+}
+
+// use adds an overlay to those simulated against. The overlay is read from a file if one exists
+// with the provided name, and otherwise must be the ID or name of a stored overlay
+func use(s *session, args []string) error {
+	if len(args) != 2 || args[0] != "overlay" {
+		return usageError("use")
+	}
+	ref := args[1]
+
+	if _, err := os.Stat(ref); err == nil {
+		overlays, err := cli.LoadOverlays([]string{ref})
+		if err != nil {
+			return err
+		}
+		s.opts.OverlayFiles = append(s.opts.OverlayFiles, ref)
+		s.opts.Overlays = append(s.opts.Overlays, overlays...)
+		return nil
+	}
+
+	summaries, err := s.client.ListOverlays(s.ctx, "")
+	if err != nil {
+		return fmt.Errorf("error listing overlays: %w", err)
+	}
+	for _, summary := range summaries {
+		if summary.ID == ref || summary.Name == ref {
+			s.opts.OverlayIDs = append(s.opts.OverlayIDs, summary.ID)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no overlay file or stored overlay with ID or name '%s'", ref)
+}
+
+func status(s *session, _ []string) error {
+	status, err := s.client.Status(s.ctx)
+	if err != nil {
+		return fmt.Errorf("error retrieving status: %w", err)
+	}
+
+	cli.PrintJSON(status)
+	return nil
+}
+
+func help(*session, []string) error {
+	width := 0
+	for _, cmd := range commands {
+		width = max(width, len(cmd.name))
+	}
+
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-*s  %s\n", width, cmd.name, cmd.description)
+		fmt.Fprintf(os.Stderr, "  %-*s  usage: %s\n", width, "", cmd.usage)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "Entity kinds: %s\n", strings.Join(kindNames(), ", "))
+	fmt.Fprintln(os.Stderr, "Press tab to complete commands, ARNs and actions.")
+	return nil
+}
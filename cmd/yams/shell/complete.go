@@ -0,0 +1,158 @@
+package shell
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/pkg/aws/sar"
+	"github.com/nsiow/yams/pkg/client"
+	yamssim "github.com/nsiow/yams/pkg/sim"
+)
+
+// completionLimit is the maximum number of completions offered for entities and actions
+const completionLimit = 50
+
+// completer returns the completions of the word being typed, given the arguments before it
+type completer func(s *session, prev []string, word string) []string
+
+// complete implements liner.WordCompleter, completing command names and then their arguments
+func (s *session) complete(line string, pos int) (head string, completions []string, tail string) {
+	head, tail = line[:pos], line[pos:]
+	start := strings.LastIndexAny(head, " \t") + 1
+	head, word := head[:start], head[start:]
+	fields := strings.Fields(head)
+
+	if len(fields) == 0 {
+		for _, cmd := range commands {
+			if strings.HasPrefix(cmd.name, word) {
+				completions = append(completions, cmd.name+" ")
+			}
+		}
+		return head, completions, tail
+	}
+
+	cmd, ok := lookupCommand(fields[0])
+	if !ok || len(cmd.args) == 0 {
+		return head, nil, tail
+	}
+
+	prev := fields[1:]
+	complete := cmd.args[min(len(prev), len(cmd.args)-1)]
+	for _, c := range complete(s, prev, word) {
+		completions = append(completions, c+" ")
+	}
+	return head, completions, tail
+}
+
+// entityKeys returns the keys of all entities of a kind, fetching them on first use
+func (s *session) entityKeys(kind client.Kind) []string {
+	keys, ok := s.keys[kind]
+	if !ok {
+		var err error
+		keys, err = s.client.Keys(s.ctx, kind)
+		if err != nil {
+			slog.Debug("unable to list keys for completion", "kind", kind, "error", err)
+		}
+		s.keys[kind] = keys
+	}
+	return keys
+}
+
+// fuzzyKeys matches the word against the keys of a kind, in the same way that partial ARNs are
+// resolved in simulations
+func (s *session) fuzzyKeys(kind client.Kind, word string) []string {
+	return yamssim.FuzzyMatchArns(s.entityKeys(kind), word, completionLimit)
+}
+
+func none(*session, []string, string) []string {
+	return nil
+}
+
+func principals(s *session, _ []string, word string) []string {
+	return s.fuzzyKeys(client.KindPrincipals, word)
+}
+
+func resources(s *session, _ []string, word string) []string {
+	return s.fuzzyKeys(client.KindResources, word)
+}
+
+func actions(_ *session, _ []string, word string) []string {
+	var names []string
+	for _, action := range sar.NewQuery().WithSearch(word).Results() {
+		if len(names) >= completionLimit {
+			break
+		}
+		names = append(names, action.ShortName())
+	}
+	return names
+}
+
+// entityKeys completes the keys of the entity kind named by the previous argument
+func entityKeys(s *session, prev []string, word string) []string {
+	if len(prev) != 1 {
+		return nil
+	}
+
+	kind, ok := parseKind(prev[0])
+	if !ok {
+		return nil
+	}
+	if kind == client.KindActions {
+		return actions(s, prev, word)
+	}
+	return s.fuzzyKeys(kind, word)
+}
+
+// settings completes the values of 'set' and 'unset'
+func settings(s *session, prev []string, word string) []string {
+	switch prev[0] {
+	case "exact":
+		if prev[len(prev)-1] == "exact" {
+			return oneOf("on", "off")(s, prev, word)
+		}
+	case "format":
+		if prev[len(prev)-1] == "format" {
+			return oneOf(cli.Formats...)(s, prev, word)
+		}
+	case "context":
+		var keys []string
+		for key := range s.opts.Context {
+			if strings.HasPrefix(key, word) {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		return keys
+	}
+	return nil
+}
+
+// storedOverlays completes the names of stored overlays
+func storedOverlays(s *session, _ []string, word string) []string {
+	summaries, err := s.client.ListOverlays(s.ctx, word)
+	if err != nil {
+		slog.Debug("unable to list overlays for completion", "error", err)
+		return nil
+	}
+
+	var names []string
+	for _, summary := range summaries {
+		names = append(names, summary.Name)
+	}
+	return names
+}
+
+// oneOf completes a fixed set of words
+func oneOf(words ...string) completer {
+	return func(_ *session, _ []string, word string) []string {
+		var matches []string
+		for _, w := range words {
+			if strings.HasPrefix(w, word) {
+				matches = append(matches, w)
+			}
+		}
+		return matches
+	}
+}
@@ -0,0 +1,160 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/cmd/yams/local"
+	"github.com/nsiow/yams/pkg/client"
+	"github.com/peterh/liner"
+)
+
+// prompt is displayed when waiting for a command
+const prompt = "yams> "
+
+// session holds the state of an interactive shell: the connection along with the context,
+// overlays and last query, which persist between commands
+type session struct {
+	client *client.Client
+	ctx    context.Context
+
+	// opts holds the simulation state shared with `yams sim`
+	opts *cli.Flags
+
+	// keys caches entity keys used for completion and fuzzy matching, by kind
+	keys map[client.Kind][]string
+}
+
+// Logic for the "shell" subcommand
+func Run(opts *cli.Flags) {
+	overlays, err := cli.LoadOverlays(opts.OverlayFiles)
+	if err != nil {
+		cli.Fail("error loading overlays: %v", err)
+	}
+	opts.Overlays = overlays
+	if opts.Context == nil {
+		opts.Context = cli.MapString{}
+	}
+
+	s := &session{
+		client: local.Connect(opts),
+		ctx:    context.Background(),
+		opts:   opts,
+		keys:   map[client.Kind][]string{},
+	}
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetWordCompleter(s.complete)
+
+	history := historyPath(opts.History)
+	readHistory(line, history)
+	defer writeHistory(line, history)
+
+	if cli.StdinIsTTY() {
+		fmt.Fprintln(os.Stderr, "Type 'help' for a list of commands, or 'exit' to quit.")
+	}
+
+	for {
+		input, err := line.Prompt(prompt)
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			cli.Fail("error reading input: %v", err)
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" || strings.HasPrefix(input, "#") {
+			continue
+		}
+		line.AppendHistory(input)
+
+		if err := s.exec(input); err != nil {
+			if errors.Is(err, errExit) {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+	}
+}
+
+// exec runs a single line of input
+func (s *session) exec(input string) error {
+	fields := strings.Fields(input)
+
+	cmd, ok := lookupCommand(fields[0])
+	if !ok {
+		return fmt.Errorf("unknown command '%s'; type 'help' for a list of commands", fields[0])
+	}
+	return cmd.run(s, fields[1:])
+}
+
+// historyPath returns the file in which command history is kept: the provided path if any, or
+// else a history file next to the config file
+func historyPath(path string) string {
+	if path != "" {
+		return path
+	}
+
+	paths := cli.ConfigPaths()
+	if len(paths) == 0 {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(paths[0]), "history")
+}
+
+// readHistory loads command history from the provided file, if it exists
+func readHistory(line *liner.State, path string) {
+	if path == "" {
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("unable to read history", "path", path, "error", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if _, err := line.ReadHistory(f); err != nil {
+		slog.Warn("unable to read history", "path", path, "error", err)
+	}
+}
+
+// writeHistory saves command history to the provided file
+func writeHistory(line *liner.State, path string) {
+	if path == "" {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		slog.Warn("unable to write history", "path", path, "error", err)
+		return
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		slog.Warn("unable to write history", "path", path, "error", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := line.WriteHistory(f); err != nil {
+		slog.Warn("unable to write history", "path", path, "error", err)
+	}
+}
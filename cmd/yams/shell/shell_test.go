@@ -0,0 +1,511 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/pkg/client"
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+// fakeServer serves a fixed set of principals, resources and overlays, counting the requests made
+// to each path
+type fakeServer struct {
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+// principalArns and resourceArns are the entities known to the fake server
+var (
+	principalArns = []string{
+		"arn:aws:iam::111111111111:role/admin",
+		"arn:aws:iam::111111111111:role/reader",
+		"arn:aws:iam::222222222222:user/alice",
+	}
+	resourceArns = []string{
+		"arn:aws:s3:::logs",
+		"arn:aws:s3:::reports",
+	}
+	overlaySummaries = []entities.OverlaySummary{
+		{ID: "11111111-1111-1111-1111-111111111111", Name: "staging"},
+		{ID: "22222222-2222-2222-2222-222222222222", Name: "prod-candidate"},
+	}
+)
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	f.hits[req.URL.Path]++
+	f.mu.Unlock()
+
+	var out any
+	switch path := req.URL.Path; {
+	case path == "/api/v1/principals":
+		out = principalArns
+	case path == "/api/v1/resources":
+		out = resourceArns
+	case strings.HasPrefix(path, "/api/v1/principals/search/"):
+		var matches []string
+		for _, arn := range principalArns {
+			if strings.Contains(arn, strings.TrimPrefix(path, "/api/v1/principals/search/")) {
+				matches = append(matches, arn)
+			}
+		}
+		out = matches
+	case path == "/api/v1/overlays":
+		var matches []entities.OverlaySummary
+		for _, summary := range overlaySummaries {
+			if strings.Contains(summary.Name, req.URL.Query().Get("q")) {
+				matches = append(matches, summary)
+			}
+		}
+		out = matches
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	b, _ := json.Marshal(out)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// requests returns the number of requests made to a path
+func (f *fakeServer) requests(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.hits[path]
+}
+
+// newTestSession returns a session connected to a fake server, discarding the output of commands
+func newTestSession(t *testing.T) (*session, *fakeServer) {
+	t.Helper()
+
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devnull, devnull
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		devnull.Close()
+	})
+
+	fake := &fakeServer{hits: map[string]int{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return &session{
+		client: client.New(server.URL),
+		ctx:    context.Background(),
+		opts:   &cli.Flags{Context: cli.MapString{}, Format: cli.FormatJSON},
+		keys:   map[client.Kind][]string{},
+	}, fake
+}
+
+func TestParseKind(t *testing.T) {
+	tests := []struct {
+		name string
+		want client.Kind
+		ok   bool
+	}{
+		{name: "principal", want: client.KindPrincipals, ok: true},
+		{name: "Principals", want: client.KindPrincipals, ok: true},
+		{name: "resources", want: client.KindResources, ok: true},
+		{name: "policy", want: client.KindPolicies, ok: true},
+		{name: "policies", want: client.KindPolicies, ok: true},
+		{name: "group", want: client.KindGroups, ok: true},
+		{name: "accounts", want: client.KindAccounts, ok: true},
+		{name: "action", want: client.KindActions, ok: true},
+		{name: "bucket", ok: false},
+		{name: "", ok: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseKind(tc.name)
+			if ok != tc.ok || got != tc.want {
+				t.Fatalf("parseKind(%q) = (%q, %v), want (%q, %v)",
+					tc.name, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestLookupCommand(t *testing.T) {
+	for _, cmd := range commands {
+		got, ok := lookupCommand(cmd.name)
+		if !ok || got.name != cmd.name {
+			t.Errorf("lookupCommand(%q) = (%q, %v)", cmd.name, got.name, ok)
+		}
+		for _, alias := range cmd.aliases {
+			if got, ok := lookupCommand(alias); !ok || got.name != cmd.name {
+				t.Errorf("lookupCommand(%q) = (%q, %v), want %q", alias, got.name, ok, cmd.name)
+			}
+		}
+	}
+
+	if _, ok := lookupCommand("bogus"); ok {
+		t.Error("expected unknown command not to be found")
+	}
+}
+
+func TestExec(t *testing.T) {
+	overlayFile := filepath.Join(t.TempDir(), "overlay.json")
+	role := `{"Type": "AWS::IAM::Role", "Arn": "arn:aws:iam::111111111111:role/new"}`
+	if err := os.WriteFile(overlayFile, []byte(role), 0644); err != nil {
+		t.Fatalf("failed to write overlay: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		inputs  []string
+		wantErr string
+		check   func(t *testing.T, s *session)
+	}{
+		{
+			name:    "unknown_command",
+			inputs:  []string{"frobnicate now"},
+			wantErr: "unknown command 'frobnicate'",
+		},
+		{
+			name:    "exit",
+			inputs:  []string{"exit"},
+			wantErr: errExit.Error(),
+		},
+		{
+			name:    "quit_alias",
+			inputs:  []string{"quit"},
+			wantErr: errExit.Error(),
+		},
+		{
+			name:   "help",
+			inputs: []string{"help"},
+		},
+		{
+			name:    "sim_without_previous",
+			inputs:  []string{"sim"},
+			wantErr: "no previous simulation",
+		},
+		{
+			name:    "sim_wrong_arity",
+			inputs:  []string{"sim admin"},
+			wantErr: "usage: sim <principal> <action> [resource]",
+		},
+		{
+			name:    "explain_wrong_arity",
+			inputs:  []string{"explain a b c d"},
+			wantErr: "usage: explain",
+		},
+		{
+			name:    "which_principals_wrong_arity",
+			inputs:  []string{"which-principals s3:getobject"},
+			wantErr: "usage: which-principals",
+		},
+		{
+			name:    "which_actions_wrong_arity",
+			inputs:  []string{"which-actions"},
+			wantErr: "usage: which-actions",
+		},
+		{
+			name:    "which_resources_wrong_arity",
+			inputs:  []string{"which-resources a b c"},
+			wantErr: "usage: which-resources",
+		},
+		{
+			name:    "show_wrong_arity",
+			inputs:  []string{"show principal"},
+			wantErr: "usage: show",
+		},
+		{
+			name:    "show_unknown_kind",
+			inputs:  []string{"show bucket logs"},
+			wantErr: "unknown entity kind 'bucket'",
+		},
+		{
+			name:   "show_context",
+			inputs: []string{"show context"},
+		},
+		{
+			name:    "show_ambiguous_key",
+			inputs:  []string{"show principal 111111111111"},
+			wantErr: "too many matches",
+		},
+		{
+			name:    "freeze_wrong_arity",
+			inputs:  []string{"freeze"},
+			wantErr: "usage: freeze",
+		},
+		{
+			name:   "search",
+			inputs: []string{"search principals alice"},
+		},
+		{
+			name:    "search_unknown_kind",
+			inputs:  []string{"search buckets logs"},
+			wantErr: "unknown entity kind 'buckets'",
+		},
+		{
+			name:   "set_context",
+			inputs: []string{"set context aws:SourceIp=10.0.0.1 aws:PrincipalTag/team=infra"},
+			check: func(t *testing.T, s *session) {
+				want := cli.MapString{"aws:SourceIp": "10.0.0.1", "aws:PrincipalTag/team": "infra"}
+				if !reflect.DeepEqual(s.opts.Context, want) {
+					t.Fatalf("wanted context %v, got %v", want, s.opts.Context)
+				}
+			},
+		},
+		{
+			name:    "set_context_invalid",
+			inputs:  []string{"set context novalue"},
+			wantErr: "novalue",
+		},
+		{
+			name:   "set_exact",
+			inputs: []string{"set exact on"},
+			check: func(t *testing.T, s *session) {
+				if !s.opts.Exact {
+					t.Fatal("expected exact matching to be enabled")
+				}
+			},
+		},
+		{
+			name:    "set_exact_invalid",
+			inputs:  []string{"set exact maybe"},
+			wantErr: "usage: set",
+		},
+		{
+			name:   "set_format",
+			inputs: []string{"set format table"},
+			check: func(t *testing.T, s *session) {
+				if s.opts.Format != cli.FormatTable {
+					t.Fatalf("wanted table format, got %s", s.opts.Format)
+				}
+			},
+		},
+		{
+			name:    "set_format_invalid",
+			inputs:  []string{"set format yaml"},
+			wantErr: "unsupported output format 'yaml'",
+		},
+		{
+			name:    "set_unknown",
+			inputs:  []string{"set colour blue"},
+			wantErr: "usage: set",
+		},
+		{
+			name:   "unset_context_key",
+			inputs: []string{"set context a=1 b=2", "unset context a"},
+			check: func(t *testing.T, s *session) {
+				if want := (cli.MapString{"b": "2"}); !reflect.DeepEqual(s.opts.Context, want) {
+					t.Fatalf("wanted context %v, got %v", want, s.opts.Context)
+				}
+			},
+		},
+		{
+			name:   "unset_context_all",
+			inputs: []string{"set context a=1 b=2", "unset context"},
+			check: func(t *testing.T, s *session) {
+				if len(s.opts.Context) != 0 {
+					t.Fatalf("expected empty context, got %v", s.opts.Context)
+				}
+			},
+		},
+		{
+			name:    "unset_without_target",
+			inputs:  []string{"unset"},
+			wantErr: "usage: unset",
+		},
+		{
+			name:   "use_overlay_file",
+			inputs: []string{"use overlay " + overlayFile},
+			check: func(t *testing.T, s *session) {
+				if len(s.opts.OverlayFiles) != 1 || len(s.opts.Overlays) != 1 {
+					t.Fatalf("expected overlay file to be loaded, got %v", s.opts.OverlayFiles)
+				}
+			},
+		},
+		{
+			name:   "use_stored_overlay",
+			inputs: []string{"use overlay staging"},
+			check: func(t *testing.T, s *session) {
+				want := []string{overlaySummaries[0].ID}
+				if !reflect.DeepEqual([]string(s.opts.OverlayIDs), want) {
+					t.Fatalf("wanted overlay IDs %v, got %v", want, s.opts.OverlayIDs)
+				}
+			},
+		},
+		{
+			name:    "use_unknown_overlay",
+			inputs:  []string{"use overlay missing"},
+			wantErr: "no overlay file or stored overlay",
+		},
+		{
+			name:   "unset_overlays",
+			inputs: []string{"use overlay staging", "use overlay " + overlayFile, "unset overlays"},
+			check: func(t *testing.T, s *session) {
+				opts := s.opts
+				if opts.OverlayIDs != nil || opts.OverlayFiles != nil || opts.Overlays != nil {
+					t.Fatalf("expected overlays to be cleared, got %+v", s.opts)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := newTestSession(t)
+
+			var err error
+			for _, input := range tc.inputs {
+				if err = s.exec(input); err != nil {
+					break
+				}
+			}
+
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+			if tc.wantErr == errExit.Error() && !errors.Is(err, errExit) {
+				t.Fatalf("expected errExit, got %v", err)
+			}
+			if tc.check != nil {
+				tc.check(t, s)
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		line     string
+		wantHead string
+		want     []string
+	}{
+		{line: "wh", want: []string{"which-principals ", "which-actions ", "which-resources "}},
+		{line: "ex", want: []string{"explain ", "exit "}},
+		{line: "zzz", want: nil},
+		{
+			line:     "sim rea",
+			wantHead: "sim ",
+			want:     []string{"arn:aws:iam::111111111111:role/reader "},
+		},
+		{
+			line:     "sim 2222",
+			wantHead: "sim ",
+			want:     []string{"arn:aws:iam::222222222222:user/alice "},
+		},
+		{
+			line:     "sim admin s3:getobject rep",
+			wantHead: "sim admin s3:getobject ",
+			want:     []string{"arn:aws:s3:::reports "},
+		},
+		{
+			line:     "which-actions admin lo",
+			wantHead: "which-actions admin ",
+			want:     []string{"arn:aws:s3:::logs "},
+		},
+		{
+			line:     "show ",
+			wantHead: "show ",
+			want: []string{
+				"principal ", "resource ", "policy ", "group ", "account ", "action ", "context ",
+				"overlays ",
+			},
+		},
+		{
+			line:     "show resources lo",
+			wantHead: "show resources ",
+			want:     []string{"arn:aws:s3:::logs "},
+		},
+		{line: "show buckets lo", wantHead: "show buckets ", want: nil},
+		{line: "freeze g", wantHead: "freeze ", want: []string{"group "}},
+		{line: "search principal ali", wantHead: "search principal ", want: nil},
+		{line: "set ", wantHead: "set ", want: []string{"context ", "exact ", "format "}},
+		{line: "set exact o", wantHead: "set exact ", want: []string{"on ", "off "}},
+		{line: "set format n", wantHead: "set format ", want: []string{"ndjson "}},
+		{line: "unset context a", wantHead: "unset context ", want: []string{"aws:SourceIp "}},
+		{line: "use overlay pro", wantHead: "use overlay ", want: []string{"prod-candidate "}},
+		{line: "status ", wantHead: "status ", want: nil},
+		{line: "bogus ", wantHead: "bogus ", want: nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.line, func(t *testing.T) {
+			s, _ := newTestSession(t)
+			s.opts.Context["aws:SourceIp"] = "10.0.0.1"
+
+			head, got, tail := s.complete(tc.line, len(tc.line))
+			if head != tc.wantHead {
+				t.Errorf("wanted head %q, got %q", tc.wantHead, head)
+			}
+			if tail != "" {
+				t.Errorf("wanted empty tail, got %q", tail)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wanted completions %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestComplete_Tail(t *testing.T) {
+	s, _ := newTestSession(t)
+
+	line := "sim rea s3:getobject"
+	head, got, tail := s.complete(line, len("sim rea"))
+	if head != "sim " || tail != " s3:getobject" {
+		t.Fatalf("wanted head %q and tail %q, got %q and %q", "sim ", " s3:getobject", head, tail)
+	}
+	if want := []string{"arn:aws:iam::111111111111:role/reader "}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted completions %q, got %q", want, got)
+	}
+}
+
+func TestComplete_Actions(t *testing.T) {
+	s, _ := newTestSession(t)
+
+	_, got, _ := s.complete("sim admin zz:nothing", len("sim admin zz:nothing"))
+	if len(got) != 0 {
+		t.Fatalf("expected no completions for unknown action, got %q", got)
+	}
+
+	_, got, _ = s.complete("sim admin s3:getobj", len("sim admin s3:getobj"))
+	if len(got) == 0 || len(got) > completionLimit {
+		t.Fatalf("expected between 1 and %d completions, got %d", completionLimit, len(got))
+	}
+	for _, c := range got {
+		if !strings.HasPrefix(strings.ToLower(c), "s3:getobj") {
+			t.Errorf("unexpected completion %q", c)
+		}
+	}
+}
+
+func TestComplete_CachesKeys(t *testing.T) {
+	s, fake := newTestSession(t)
+
+	for _, line := range []string{"sim a", "sim r", "which-actions admin l", "which-actions x r"} {
+		s.complete(line, len(line))
+	}
+
+	if n := fake.requests("/api/v1/principals"); n != 1 {
+		t.Errorf("expected principals to be fetched once, got %d requests", n)
+	}
+	if n := fake.requests("/api/v1/resources"); n != 1 {
+		t.Errorf("expected resources to be fetched once, got %d requests", n)
+	}
+}
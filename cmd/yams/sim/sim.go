@@ -1,99 +1,177 @@
 package sim
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
 	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/cmd/yams/local"
 	"github.com/nsiow/yams/pkg/aws/sar"
+	"github.com/nsiow/yams/pkg/client"
 	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 )
 
 // Logic for the "sim" subcommand
 func Run(opts *cli.Flags) {
-	cli.RequireServer(opts.Server)
-
-	havePrincipal := opts.Principal != ""
-	haveAction := opts.Action != ""
-	haveResource := opts.Resource != ""
+	c := local.Connect(opts)
 
-	overlay, err := cli.LoadOverlays(opts.OverlayFiles)
+	overlays, err := cli.LoadOverlays(opts.OverlayFiles)
 	if err != nil {
 		cli.Fail("error loading overlays: %v", err)
 	}
-	opts.Overlay = *overlay
+	opts.Overlays = overlays
+
+	out, err := Query(c, opts)
+	if err != nil {
+		cli.Fail("%v", err)
+	}
+
+	if err := Render(opts, out); err != nil {
+		cli.Fail("error writing output: %v", err)
+	}
+}
+
+// Query runs the simulation implied by which of the principal, action and resource are set: a
+// full simulation if all are set, or otherwise a search for the missing one
+func Query(c *client.Client, opts *cli.Flags) (any, error) {
+	havePrincipal := opts.Principal != ""
+	haveAction := opts.Action != ""
+	haveResource := opts.Resource != ""
 
 	if havePrincipal && haveAction && haveResource {
-		runSim(opts)
+		return runSim(c, opts)
 	} else if havePrincipal && haveAction {
 		action, ok := sar.LookupString(opts.Action)
 		if !ok {
-			cli.Fail("unknown action: %s", opts.Action)
+			return nil, fmt.Errorf("unknown action: %s", opts.Action)
 		}
 
 		if action.HasTargets() {
-			runWhichResources(opts)
-		} else {
-			runSim(opts)
+			return runWhichResources(c, opts)
 		}
+		return runSim(c, opts)
 	} else if havePrincipal && haveResource {
-		runWhichActions(opts)
+		return runWhichActions(c, opts)
 	} else if haveAction && haveResource {
-		runWhichPrincipals(opts)
-	} else {
-		cli.Fail("error: must provide at least two of -p/--principal | -a/--action | -r/--resource")
+		return runWhichPrincipals(c, opts)
 	}
+
+	return nil, errors.New(
+		"error: must provide at least two of -p/--principal | -a/--action | -r/--resource")
 }
 
-func runSim(opts *cli.Flags) {
-	cli.PostReq(
-		cli.ApiUrl(opts.Server, "sim"),
-		v1.SimInput{
-			Principal: opts.Principal,
-			Action:    opts.Action,
-			Resource:  opts.Resource,
-			Context:   opts.Context,
-			Fuzzy:     !opts.Exact,
-			Explain:   opts.Explain,
-			Trace:     opts.Trace,
-			Overlay:   opts.Overlay,
-		},
-	)
+// Render writes the output of Query to stdout in the requested format. Formats other than JSON
+// render one row per principal/action/resource, so explanations and traces are only in JSON
+func Render(opts *cli.Flags, out any) error {
+	if opts.Format == "" || opts.Format == cli.FormatJSON {
+		cli.PrintJSON(out)
+		return nil
+	}
+
+	var rowOpts []cli.RowOption
+	if len(opts.Sources) > 0 {
+		rowOpts = append(rowOpts, cli.WithArtifact(opts.Sources[0]))
+	}
+
+	headers := []string{"Result", "Principal", "Action", "Resource"}
+	rw, err := cli.NewRowWriter(os.Stdout, opts.Format, headers, rowOpts...)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	switch out := out.(type) {
+	case *v1.SimOutput:
+		rows = append(rows, []string{out.Result, out.Principal, out.Action, out.Resource})
+	case []string:
+		// the which-* queries list whichever of the principal, action or resource was omitted
+		for _, match := range out {
+			row := []string{"ALLOW", opts.Principal, opts.Action, opts.Resource}
+			switch {
+			case opts.Principal == "":
+				row[1] = match
+			case opts.Action == "":
+				row[2] = match
+			default:
+				row[3] = match
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	for _, row := range rows {
+		if err := rw.Write(row); err != nil {
+			return err
+		}
+	}
+	return rw.Close()
 }
 
-func runWhichPrincipals(opts *cli.Flags) {
-	cli.PostReq(
-		cli.ApiUrl(opts.Server, "sim", "whichPrincipals"),
-		v1.WhichPrincipalsInput{
-			Action:   opts.Action,
-			Resource: opts.Resource,
-			Context:  opts.Context,
-			Overlay:  opts.Overlay,
-			Fuzzy:    !opts.Exact,
-		},
-	)
+func runSim(c *client.Client, opts *cli.Flags) (any, error) {
+	out, err := c.Sim(context.Background(), v1.SimInput{
+		Principal:   opts.Principal,
+		Action:      opts.Action,
+		Resource:    opts.Resource,
+		Context:     opts.Context,
+		Fuzzy:       !opts.Exact,
+		Explain:     opts.Explain,
+		Trace:       opts.Trace,
+		Overlays:    opts.Overlays,
+		OverlayRefs: v1.OverlayRefs{OverlayIDs: opts.OverlayIDs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error running simulation: %w", err)
+	}
+
+	return out, nil
 }
 
-func runWhichActions(opts *cli.Flags) {
-	cli.PostReq(
-		cli.ApiUrl(opts.Server, "sim", "whichActions"),
-		v1.WhichActionsInput{
-			Principal: opts.Principal,
-			Resource:  opts.Resource,
-			Context:   opts.Context,
-			Overlay:   opts.Overlay,
-			Fuzzy:     !opts.Exact,
-		},
-	)
+func runWhichPrincipals(c *client.Client, opts *cli.Flags) (any, error) {
+	out, err := c.WhichPrincipals(context.Background(), v1.WhichPrincipalsInput{
+		Action:      opts.Action,
+		Resource:    opts.Resource,
+		Context:     opts.Context,
+		Overlays:    opts.Overlays,
+		OverlayRefs: v1.OverlayRefs{OverlayIDs: opts.OverlayIDs},
+		Fuzzy:       !opts.Exact,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error running simulation: %w", err)
+	}
+
+	return out, nil
 }
 
-func runWhichResources(opts *cli.Flags) {
-	cli.PostReq(
-		cli.ApiUrl(opts.Server, "sim", "whichResources"),
-		v1.WhichResourcesInput{
-			Principal: opts.Principal,
-			Action:    opts.Action,
-			Context:   opts.Context,
-			Overlay:   opts.Overlay,
-			Fuzzy:     !opts.Exact,
-		},
-	)
+func runWhichActions(c *client.Client, opts *cli.Flags) (any, error) {
+	out, err := c.WhichActions(context.Background(), v1.WhichActionsInput{
+		Principal:   opts.Principal,
+		Resource:    opts.Resource,
+		Context:     opts.Context,
+		Overlays:    opts.Overlays,
+		OverlayRefs: v1.OverlayRefs{OverlayIDs: opts.OverlayIDs},
+		Fuzzy:       !opts.Exact,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error running simulation: %w", err)
+	}
+
+	return out, nil
 }
 
+func runWhichResources(c *client.Client, opts *cli.Flags) (any, error) {
+	out, err := c.WhichResources(context.Background(), v1.WhichResourcesInput{
+		Principal:   opts.Principal,
+		Action:      opts.Action,
+		Context:     opts.Context,
+		Overlays:    opts.Overlays,
+		OverlayRefs: v1.OverlayRefs{OverlayIDs: opts.OverlayIDs},
+		Fuzzy:       !opts.Exact,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error running simulation: %w", err)
+	}
+
+	return out, nil
+}
@@ -1,94 +1,70 @@
 package status
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"log/slog"
-	"net/http"
 	"os"
+	"slices"
+	"time"
 
-	json "github.com/bytedance/sonic"
 	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/cmd/yams/local"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 )
 
-// statusResponse represents the server status response
-type statusResponse struct {
-	Server  serverInfo  `json:"server"`
-	Sources []sourceInfo `json:"sources"`
-}
-
-type serverInfo struct {
-	Started string            `json:"started"`
-	Env     map[string]string `json:"env"`
-}
-
-type sourceInfo struct {
-	Name       string `json:"name"`
-	Principals int    `json:"principals"`
-	Resources  int    `json:"resources"`
-	Policies   int    `json:"policies"`
-	Actions    int    `json:"actions"`
-	Accounts   int    `json:"accounts"`
-}
-
 // Logic for the "status" subcommand
 func Run(opts *cli.Flags) {
-	url := cli.ApiUrl(opts.Server, "status")
+	status, err := local.Connect(opts).Status(context.Background())
+	if err != nil {
+		cli.Fail("error retrieving status: %v", err)
+	}
 
 	if opts.Format == cli.FormatTable {
-		getAndRenderStatus(url)
+		renderStatus(status)
 	} else {
-		cli.GetReq(url)
+		cli.PrintJSON(status)
 	}
 }
 
-func getAndRenderStatus(url string) {
-	slog.Debug("making request", "url", url)
-	resp, err := http.Get(url)
-	if err != nil {
-		cli.Fail("error retrieving URL '%s': %v", url, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		cli.Fail("received non-200 status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		cli.Fail("error reading body of URL '%s': %v", url, err)
-	}
-
-	var status statusResponse
-	if err := json.Unmarshal(body, &status); err != nil {
-		cli.OutputJSON(body)
-		return
-	}
-
-	// Print server info
+func renderStatus(status *v1.StatusOutput) {
+	// Print universe info
 	fmt.Fprintln(os.Stdout, "Server Status")
 	fmt.Fprintln(os.Stdout, "-------------")
-	fmt.Fprintf(os.Stdout, "Started: %s\n", status.Server.Started)
+	fmt.Fprintf(os.Stdout, "Entities:   %d\n", status.Entities)
+	fmt.Fprintf(os.Stdout, "Accounts:   %d\n", status.Accounts)
+	fmt.Fprintf(os.Stdout, "Principals: %d\n", status.Principals)
+	fmt.Fprintf(os.Stdout, "Groups:     %d\n", status.Groups)
+	fmt.Fprintf(os.Stdout, "Policies:   %d\n", status.Policies)
+	fmt.Fprintf(os.Stdout, "Resources:  %d\n", status.Resources)
+	fmt.Fprintf(os.Stdout, "Actions:    %d\n", status.Actions)
 
-	if len(status.Server.Env) > 0 {
+	if len(status.Env) > 0 {
 		fmt.Fprintln(os.Stdout, "\nEnvironment:")
-		for k, v := range status.Server.Env {
-			fmt.Fprintf(os.Stdout, "  %s: %s\n", k, v)
+		keys := make([]string, 0, len(status.Env))
+		for k := range status.Env {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			fmt.Fprintf(os.Stdout, "  %s: %s\n", k, status.Env[k])
 		}
 	}
 
 	// Print sources
 	if len(status.Sources) > 0 {
 		fmt.Fprintln(os.Stdout, "\nData Sources:")
-		t := cli.NewTableWriter("Source", "Principals", "Resources", "Policies", "Actions", "Accounts")
+		t := cli.NewTableWriter("Source", "Updated", "Refresh", "Stale", "Error")
 		for _, src := range status.Sources {
+			stale := "no"
+			if src.Stale {
+				stale = "yes"
+			}
 			t.AddRow(
 				src.Name,
-				fmt.Sprintf("%d", src.Principals),
-				fmt.Sprintf("%d", src.Resources),
-				fmt.Sprintf("%d", src.Policies),
-				fmt.Sprintf("%d", src.Actions),
-				fmt.Sprintf("%d", src.Accounts),
+				src.Updated.Format(time.RFC3339),
+				src.Refresh,
+				stale,
+				cli.Truncate(src.LastError, 60),
 			)
 		}
 		t.Render()
@@ -0,0 +1,89 @@
+package watch
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/server"
+	"github.com/nsiow/yams/pkg/sim"
+	"github.com/nsiow/yams/pkg/watch"
+)
+
+// Logic for the "watch" subcommand
+func Run(opts *cli.Flags) {
+	if len(opts.Sources) == 0 {
+		cli.Fail("error: -s/-source is required")
+	}
+	if opts.Config == "" {
+		cli.Fail("error: -f/-config is required")
+	}
+	if opts.Refresh < 1 {
+		cli.Fail("error: -refresh must be at least 1")
+	}
+
+	checks, err := watch.LoadChecks(opts.Config)
+	if err != nil {
+		cli.Fail("error loading checks: %v", err)
+	}
+
+	sink, err := watch.NewSink(opts.Out)
+	if err != nil {
+		cli.Fail("error opening output: %v", err)
+	}
+
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		cli.Fail("error creating simulator: %v", err)
+	}
+	srv.Snapshots = true
+
+	// Checks are evaluated against a snapshot rebuilt from every source after each reload, rather
+	// than the server's merged universe, so that deleted principals and resources lose their access
+	simulator, err := sim.NewSimulator()
+	if err != nil {
+		cli.Fail("error creating simulator: %v", err)
+	}
+	watcher := watch.NewWatcher(simulator, checks, sink)
+	watcher.Context = opts.Context
+	watcher.Universe = srv.Snapshot
+
+	// Reloads are coalesced, so that sources refreshed together are evaluated once
+	reloaded := make(chan struct{}, 1)
+	srv.OnReload = func(*server.Source) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}
+
+	for _, src := range opts.Sources {
+		reader, err := smartrw.NewReader(src)
+		if err != nil {
+			cli.Fail("error when initializing reader for source '%s': %v", src, err)
+		}
+
+		source := server.Source{
+			Reader:      *reader,
+			Refresh:     time.Second * time.Duration(opts.Refresh),
+			Conditional: opts.Conditional,
+		}
+
+		err = srv.AddSource(&source)
+		if err != nil {
+			cli.Fail("error attempting to add source '%s': %v", src, err)
+		}
+	}
+
+	if err := watcher.Evaluate(); err != nil {
+		cli.Fail("error evaluating checks: %v", err)
+	}
+	slog.Info("watching for changes", "checks", len(checks), "refresh", opts.Refresh)
+
+	for range reloaded {
+		if err := watcher.Evaluate(); err != nil {
+			slog.Error("error evaluating checks; will retry after next refresh", "error", err)
+		}
+	}
+}
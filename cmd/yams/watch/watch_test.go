@@ -0,0 +1,217 @@
+package watch
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/internal/testlib"
+	"github.com/nsiow/yams/pkg/watch"
+)
+
+// Sources of the real-world test data; MouseRole and PandaRole can read from the yams-bear bucket
+// in the current snapshot, but not in the old one
+const (
+	oldSource     = "../../../testdata/real-world/awsconfig.old.jsonl.gz"
+	currentSource = "../../../testdata/real-world/awsconfig.jsonl"
+)
+
+// bearChecks expects that no principal of the bear account can read from the yams-bear bucket
+const bearChecks = `[{
+	"name": "bear",
+	"principals": ["arn:aws:iam::213308312933:*"],
+	"resources": ["arn:aws:s3:::yams-bear"],
+	"actions": ["s3:GetObject"],
+	"expect": "deny"
+}]`
+
+// isolate points config file lookups and environment overrides at an empty directory
+func isolate(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	for _, env := range []string{"YAMS_SERVER_ADDRESS", "YAMS_TOKEN", "YAMS_PROFILE"} {
+		t.Setenv(env, "")
+	}
+}
+
+// parseArgs parses the command line of the watch command
+func parseArgs(t *testing.T, args ...string) *cli.Flags {
+	t.Helper()
+
+	opts, err := cli.ParseArgs(append([]string{"yams", "watch"}, args...))
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	return opts
+}
+
+// copySource writes the decompressed contents of a source to the destination, with a modification
+// time which differs from that of any earlier copy
+func copySource(t *testing.T, src, dest string, modified time.Time) {
+	t.Helper()
+
+	reader, err := smartrw.NewReader(src)
+	if err != nil {
+		t.Fatalf("failed to open source: %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read source: %v", err)
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	if err := os.Chtimes(dest, modified, modified); err != nil {
+		t.Fatalf("failed to set modification time: %v", err)
+	}
+}
+
+// readEvents decodes the events written to the provided file so far
+func readEvents(t *testing.T, path string) []watch.Event {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("failed to read events: %v", err)
+	}
+
+	var events []watch.Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var event watch.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("failed to decode event %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tests
+// -------------------------------------------------------------------------------------------------
+
+// TestRun watches a source which gains access violating a check when it is next refreshed. Run
+// never returns, so it is left running in the background once the test is complete
+func TestRun(t *testing.T) {
+	isolate(t)
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "awsconfig.jsonl")
+	checks := filepath.Join(dir, "checks.json")
+	out := filepath.Join(dir, "events.ndjson")
+
+	copySource(t, oldSource, source, time.Now().Add(-time.Hour))
+	if err := os.WriteFile(checks, []byte(bearChecks), 0644); err != nil {
+		t.Fatalf("failed to write checks: %v", err)
+	}
+
+	opts := parseArgs(t, "-s", source, "-f", checks, "-o", out, "-r", "1", "--conditional")
+	go Run(opts)
+
+	// the first evaluation establishes a baseline without any events
+	time.Sleep(1500 * time.Millisecond)
+	if events := readEvents(t, out); len(events) != 0 {
+		t.Fatalf("expected no events before the source changed, got %v", events)
+	}
+
+	copySource(t, currentSource, source, time.Now())
+
+	var events []watch.Event
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+		if events = readEvents(t, out); len(events) > 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	var got []string
+	for _, e := range events {
+		if e.Check != "bear" || e.Type != "gained" || !e.Violation {
+			t.Errorf("expected violations of the bear check, got %+v", e)
+		}
+		got = append(got, e.Principal)
+	}
+	slices.Sort(got)
+
+	want := []string{
+		"arn:aws:iam::213308312933:role/MouseRole",
+		"arn:aws:iam::213308312933:role/PandaRole",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("wanted events for %v, got %v", want, got)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	isolate(t)
+
+	dir := t.TempDir()
+	checks := filepath.Join(dir, "checks.json")
+	if err := os.WriteFile(checks, []byte(bearChecks), 0644); err != nil {
+		t.Fatalf("failed to write checks: %v", err)
+	}
+	invalid := filepath.Join(dir, "invalid.json")
+	unwritable := filepath.Join(dir, "missing", "events.ndjson")
+	if err := os.WriteFile(invalid, []byte(`[{"name": "a"}]`), 0644); err != nil {
+		t.Fatalf("failed to write checks: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		pattern string
+	}{
+		{
+			name:    "missing_source",
+			args:    []string{"-f", checks},
+			pattern: `-s/-source is required`,
+		},
+		{
+			name:    "missing_config",
+			args:    []string{"-s", currentSource},
+			pattern: `-f/-config is required`,
+		},
+		{
+			name:    "invalid_refresh",
+			args:    []string{"-s", currentSource, "-f", checks, "-r", "0"},
+			pattern: `-refresh must be at least 1`,
+		},
+		{
+			name:    "invalid_checks",
+			args:    []string{"-s", currentSource, "-f", invalid},
+			pattern: `error loading checks`,
+		},
+		{
+			name:    "unwritable_output",
+			args:    []string{"-s", currentSource, "-f", checks, "-o", unwritable},
+			pattern: `error opening output`,
+		},
+		{
+			name:    "unreadable_source",
+			args:    []string{"-s", filepath.Join(dir, "missing.jsonl"), "-f", checks},
+			pattern: `error when initializing reader for source`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := parseArgs(t, tt.args...)
+			testlib.AssertExit(t, 2, tt.pattern, func() { Run(opts) })
+		})
+	}
+}
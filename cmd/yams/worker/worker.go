@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/pkg/distributed"
+)
+
+// Logic for the "worker" subcommand
+func Run(opts *cli.Flags) {
+	if opts.Coordinator == "" {
+		cli.Fail("error: -coordinator is required")
+	}
+	if len(opts.Sources) == 0 {
+		cli.Fail("error: -s/-source is required")
+	}
+
+	simulator, hash, err := distributed.LoadSources(opts.Sources)
+	if err != nil {
+		cli.Fail("error building simulator: %v", err)
+	}
+
+	// Stop leasing on interrupt; a partition in progress is abandoned and retried elsewhere
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = distributed.NewWorker(opts.Coordinator, simulator, hash).Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		cli.Fail("error from worker: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+// Package parquet writes tables of string columns as Parquet files. Every column is
+// dictionary-encoded and Snappy-compressed, which suits the highly repetitive values of audit
+// results, and files are written sequentially so that output can be streamed to any destination
+package parquet
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/encoding"
+)
+
+// Defaults for the layout of written files
+const (
+	DefaultRowGroupSize = 1 << 20
+	DefaultPageSize     = 1 << 20
+)
+
+// Writer writes rows of strings to a Parquet file. Rows are buffered until a row group is
+// complete, at which point the row group's column chunks are written out
+type Writer struct {
+	w       *parquet.Writer
+	columns int
+	closed  bool
+}
+
+// Option customizes the behavior of a Writer
+type Option func(*parquet.WriterConfig)
+
+// WithCreatedBy sets the application recorded as having written the file
+func WithCreatedBy(createdBy string) Option {
+	return func(c *parquet.WriterConfig) {
+		c.CreatedBy = createdBy
+	}
+}
+
+// WithRowGroupSize sets the number of rows buffered before a row group is written
+func WithRowGroupSize(rows int) Option {
+	return func(c *parquet.WriterConfig) {
+		c.MaxRowsPerRowGroup = int64(rows)
+	}
+}
+
+// WithPageSize sets the size in bytes of the values buffered before a data page is written
+func WithPageSize(bytes int) Option {
+	return func(c *parquet.WriterConfig) {
+		c.PageBufferSize = bytes
+	}
+}
+
+// NewWriter creates a Writer of a file with the provided columns to the underlying writer, which
+// is not closed by the Writer
+func NewWriter(w io.Writer, columns []string, opts ...Option) *Writer {
+	config := &parquet.WriterConfig{
+		CreatedBy:          "yams",
+		MaxRowsPerRowGroup: DefaultRowGroupSize,
+		PageBufferSize:     DefaultPageSize,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	fields := make(schemaNode, len(columns))
+	for i, name := range columns {
+		fields[i] = &column{Node: stringColumn, name: name}
+	}
+
+	return &Writer{
+		w:       parquet.NewWriter(w, parquet.NewSchema("schema", fields), config),
+		columns: len(columns),
+	}
+}
+
+// Write adds a row with one value per column; missing values are written as empty strings
+func (w *Writer) Write(row []string) error {
+	if w.closed {
+		return fmt.Errorf("write to closed parquet writer")
+	}
+	if len(row) > w.columns {
+		return fmt.Errorf("row has %d values but there are %d columns", len(row), w.columns)
+	}
+
+	values := make(parquet.Row, w.columns)
+	for i := range values {
+		var value string
+		if i < len(row) {
+			value = row[i]
+		}
+		values[i] = parquet.ByteArrayValue([]byte(value)).Level(0, 0, i)
+	}
+
+	_, err := w.w.WriteRows([]parquet.Row{values})
+	return err
+}
+
+// Close writes any buffered rows along with the file footer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.w.Close()
+}
+
+// -------------------------------------------------------------------------------------------------
+// Schema
+// -------------------------------------------------------------------------------------------------
+
+// stringColumn is the type of every column: a required, dictionary-encoded and Snappy-compressed
+// UTF-8 string
+var stringColumn = parquet.Compressed(
+	parquet.Encoded(parquet.String(), &parquet.RLEDictionary),
+	&parquet.Snappy,
+)
+
+// schemaNode is the root of a file's schema. It behaves as a parquet.Group, except that columns
+// keep the order in which they were provided rather than being sorted by name
+type schemaNode []parquet.Field
+
+func (s schemaNode) ID() int                     { return 0 }
+func (s schemaNode) Type() parquet.Type          { return parquet.Group{}.Type() }
+func (s schemaNode) Optional() bool              { return false }
+func (s schemaNode) Repeated() bool              { return false }
+func (s schemaNode) Required() bool              { return true }
+func (s schemaNode) Leaf() bool                  { return false }
+func (s schemaNode) Fields() []parquet.Field     { return s }
+func (s schemaNode) Encoding() encoding.Encoding { return nil }
+func (s schemaNode) Compression() compress.Codec { return nil }
+func (s schemaNode) GoType() reflect.Type        { return reflect.TypeFor[map[string]string]() }
+
+func (s schemaNode) String() string {
+	var b strings.Builder
+	parquet.PrintSchema(&b, "", s)
+	return b.String()
+}
+
+// column is a named field of the schema
+type column struct {
+	parquet.Node
+	name string
+}
+
+func (c *column) Name() string { return c.name }
+
+func (c *column) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(c.name))
+}
@@ -0,0 +1,233 @@
+package parquet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/nsiow/yams/internal/testlib"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// -------------------------------------------------------------------------------------------------
+// Reader
+// -------------------------------------------------------------------------------------------------
+
+// openFile opens a written file for reading
+func openFile(t *testing.T, data []byte) *parquet.File {
+	t.Helper()
+
+	f, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	return f
+}
+
+// readFile decodes the column names and rows of a written file
+func readFile(t *testing.T, data []byte) ([]string, [][]string) {
+	t.Helper()
+
+	f := openFile(t, data)
+	var columns []string
+	for _, field := range f.Schema().Fields() {
+		columns = append(columns, field.Name())
+	}
+
+	r := parquet.NewReader(f)
+	defer r.Close()
+
+	rows := [][]string{}
+	buf := make([]parquet.Row, 16)
+	for {
+		n, err := r.ReadRows(buf)
+		for _, row := range buf[:n] {
+			values := make([]string, len(row))
+			for _, v := range row {
+				values[v.Column()] = string(v.ByteArray())
+			}
+			rows = append(rows, values)
+		}
+		if errors.Is(err, io.EOF) {
+			return columns, rows
+		}
+		if err != nil {
+			t.Fatalf("failed to read rows: %v", err)
+		}
+	}
+}
+
+// repeatRows generates n rows with the provided function
+func repeatRows(n int, fn func(i int) []string) [][]string {
+	rows := make([][]string, n)
+	for i := range rows {
+		rows[i] = fn(i)
+	}
+	return rows
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tests
+// -------------------------------------------------------------------------------------------------
+
+func TestWriter(t *testing.T) {
+	type input struct {
+		columns []string
+		rows    [][]string
+		opts    []Option
+	}
+
+	// generated rows, with runs of repeated values of varying lengths
+	repeated := repeatRows(100, func(i int) []string { return []string{"x", strconv.Itoa(i % 3)} })
+	mixed := repeatRows(1000, func(i int) []string { return []string{strconv.Itoa(i / 10 % 40)} })
+	unique := repeatRows(250, func(i int) []string { return []string{strconv.Itoa(i), "y"} })
+
+	tests := []testlib.TestCase[input, [][]string]{
+		{
+			Name: "empty",
+			Input: input{
+				columns: []string{"a", "b"},
+			},
+			Want: [][]string{},
+		},
+		{
+			Name: "single_row",
+			Input: input{
+				columns: []string{"principal", "action", "resource"},
+				rows:    [][]string{{"arn:aws:iam::1:role/a", "s3:GetObject", "arn:aws:s3:::b"}},
+			},
+			Want: [][]string{{"arn:aws:iam::1:role/a", "s3:GetObject", "arn:aws:s3:::b"}},
+		},
+		{
+			Name: "short_row",
+			Input: input{
+				columns: []string{"a", "b"},
+				rows:    [][]string{{"x"}},
+			},
+			Want: [][]string{{"x", ""}},
+		},
+		{
+			Name: "repeated_values",
+			Input: input{
+				columns: []string{"a", "b"},
+				rows:    repeated,
+			},
+			Want: repeated,
+		},
+		{
+			Name: "mixed_runs",
+			Input: input{
+				columns: []string{"a"},
+				rows:    mixed,
+			},
+			Want: mixed,
+		},
+		{
+			Name: "row_groups_and_pages",
+			Input: input{
+				columns: []string{"a", "b"},
+				rows:    unique,
+				opts:    []Option{WithRowGroupSize(100), WithPageSize(64)},
+			},
+			Want: unique,
+		},
+		{
+			Name: "too_many_values",
+			Input: input{
+				columns: []string{"a"},
+				rows:    [][]string{{"x", "y"}},
+			},
+			ShouldErr: true,
+		},
+	}
+
+	testlib.RunTestSuite(t, tests, func(in input) ([][]string, error) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, in.columns, in.opts...)
+		for _, row := range in.rows {
+			if err := w.Write(row); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		columns, rows := readFile(t, buf.Bytes())
+		if !reflect.DeepEqual(columns, in.columns) {
+			return nil, fmt.Errorf("wanted columns %v, got %v", in.columns, columns)
+		}
+		return rows, nil
+	})
+}
+
+// TestWriterLayout checks the metadata of a written file: columns keep the order provided, rather
+// than being sorted by name, and are split into row groups of dictionary-encoded, Snappy-compressed
+// chunks
+func TestWriterLayout(t *testing.T) {
+	columns := []string{"resource", "action", "principal"}
+	rows := repeatRows(250, func(i int) []string {
+		return []string{"arn:aws:s3:::b", "s3:GetObject", "arn:aws:iam::1:role/" + strconv.Itoa(i)}
+	})
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, columns, WithCreatedBy("yams test"), WithRowGroupSize(100))
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	meta := openFile(t, buf.Bytes()).Metadata()
+	if meta.CreatedBy != "yams test" {
+		t.Errorf("wanted created by %q, got %q", "yams test", meta.CreatedBy)
+	}
+	if meta.NumRows != int64(len(rows)) {
+		t.Errorf("wanted %d rows, got %d", len(rows), meta.NumRows)
+	}
+	if len(meta.RowGroups) != 3 {
+		t.Fatalf("wanted 3 row groups, got %d", len(meta.RowGroups))
+	}
+
+	for _, rg := range meta.RowGroups {
+		if len(rg.Columns) != len(columns) {
+			t.Fatalf("wanted %d column chunks, got %d", len(columns), len(rg.Columns))
+		}
+		for i, chunk := range rg.Columns {
+			m := chunk.MetaData
+			if !slices.Equal(m.PathInSchema, []string{columns[i]}) {
+				t.Errorf("wanted column %d to be %q, got %v", i, columns[i], m.PathInSchema)
+			}
+			if m.Codec != format.Snappy {
+				t.Errorf("wanted column %q to be Snappy-compressed, got %v", columns[i], m.Codec)
+			}
+			if !slices.Contains(m.Encoding, format.RLEDictionary) {
+				t.Errorf("wanted column %q to be dictionary-encoded, got %v",
+					columns[i], m.Encoding)
+			}
+		}
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []string{"a"})
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error on second close: %v", err)
+	}
+	if err := w.Write([]string{"x"}); err == nil {
+		t.Fatalf("expected error writing to closed writer")
+	}
+}
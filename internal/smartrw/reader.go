@@ -28,6 +28,48 @@ func (r *Reader) Reset() error {
 	return nil
 }
 
+// Version returns an opaque identifier for the current content of the source, which changes
+// whenever the content does. This is the ETag for S3 objects and the modification time + size for
+// local files
+func (r *Reader) Version() (string, error) {
+	src := r.Source
+
+	switch {
+
+	case strings.HasPrefix(src, "file://") || !strings.Contains(src, "://"):
+		info, err := os.Stat(strings.TrimPrefix(src, "file://"))
+		if err != nil {
+			return "", fmt.Errorf("unable to stat file: %v", err)
+		}
+		return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()), nil
+
+	case strings.HasPrefix(src, "s3://"):
+		s3, ok := r.s3Reader()
+		if !ok {
+			var err error
+			s3, err = NewS3Reader(strings.TrimPrefix(src, "s3://"))
+			if err != nil {
+				return "", fmt.Errorf("unable to create s3 reader: %v", err)
+			}
+		}
+		return s3.ETag()
+
+	default:
+		return "", fmt.Errorf("unknown smartrw protocol: %s", src)
+	}
+}
+
+// s3Reader returns the S3Reader backing this reader, if any
+func (r *Reader) s3Reader() (*S3Reader, bool) {
+	rc := r.ReadCloser
+	if gz, ok := rc.(*GzipReadCloser); ok {
+		rc = gz.r
+	}
+
+	s3, ok := rc.(*S3Reader)
+	return s3, ok
+}
+
 func selectReader(src string) (io.ReadCloser, error) {
 	var r io.ReadCloser
 
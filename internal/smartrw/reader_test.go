@@ -1,8 +1,11 @@
 package smartrw
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/nsiow/yams/internal/testlib"
 )
@@ -62,3 +65,67 @@ func TestReader(t *testing.T) {
 		return typeOf, nil
 	})
 }
+
+func TestReaderVersion(t *testing.T) {
+	dir := t.TempDir()
+	fp := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(fp, []byte("[]"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	r, err := NewReader(fp)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	defer r.Close()
+
+	before, err := r.Version()
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+
+	again, err := r.Version()
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if before != again {
+		t.Errorf("Version() changed without file changing: %s != %s", before, again)
+	}
+
+	// Change the file contents + mtime
+	if err := os.WriteFile(fp, []byte("[{}]"), 0644); err != nil {
+		t.Fatalf("failed to rewrite test file: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fp, future, future); err != nil {
+		t.Fatalf("failed to update mtime: %v", err)
+	}
+
+	after, err := r.Version()
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if before == after {
+		t.Errorf("Version() did not change after file changed: %s", after)
+	}
+}
+
+func TestReaderVersion_Errors(t *testing.T) {
+	tests := []testlib.TestCase[string, string]{
+		{
+			Name:      "missing_file",
+			Input:     "file:///does/not/exist.json",
+			ShouldErr: true,
+		},
+		{
+			Name:      "bad_protocol",
+			Input:     "bad://some-data-source",
+			ShouldErr: true,
+		},
+	}
+
+	testlib.RunTestSuite(t, tests, func(in string) (string, error) {
+		r := Reader{Source: in}
+		return r.Version()
+	})
+}
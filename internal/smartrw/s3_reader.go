@@ -15,6 +15,7 @@ import (
 // Primarily useful for testing
 type S3Client interface {
 	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 }
 
 type S3Reader struct {
@@ -64,6 +65,22 @@ func (s *S3Reader) Open() error {
 	return nil
 }
 
+// ETag returns the entity tag of the S3 object, which changes whenever the object's content does
+func (s *S3Reader) ETag() (string, error) {
+	resp, err := s.S3.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: &s.Bucket,
+		Key:    &s.Key,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.ETag == nil {
+		return "", fmt.Errorf("no etag returned for s3 object: %s/%s", s.Bucket, s.Key)
+	}
+
+	return *resp.ETag, nil
+}
+
 func (s *S3Reader) Read(p []byte) (n int, err error) {
 	if s.Body == nil {
 		err := s.Open()
@@ -31,6 +31,23 @@ func (d *DummyS3Client) GetObject(
 	}, nil
 }
 
+func (d *DummyS3Client) HeadObject(
+	ctx context.Context,
+	input *s3.HeadObjectInput,
+	_ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	// Use the md5 of the "key" file as the etag, like S3 does for simple uploads
+	fp := "../../testdata/smartrw/" + *input.Key
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	etag := fmt.Sprintf("\"%x\"", md5.Sum(data))
+	return &s3.HeadObjectOutput{
+		ETag: &etag,
+	}, nil
+}
+
 func TestS3Reader(t *testing.T) {
 	tests := []testlib.TestCase[string, string]{
 		{
@@ -76,3 +93,30 @@ func TestBadS3Reader(t *testing.T) {
 		t.Fatalf("config loading should have failed, but somehow succeeded")
 	}
 }
+
+func TestS3Reader_ETag(t *testing.T) {
+	tests := []testlib.TestCase[string, string]{
+		{
+			Name:  "simple_file_1",
+			Input: "whateverbucket/test_file_1.json",
+			Want:  `"892d22932a0e8b5223f860c860238542"`,
+		},
+		{
+			Name:      "bad_file",
+			Input:     "whateverbucket/does_not_exist.json",
+			ShouldErr: true,
+		},
+	}
+
+	testlib.RunTestSuite(t, tests, func(fp string) (string, error) {
+		r, err := NewS3Reader(fp)
+		if err != nil {
+			return "", err
+		}
+
+		// replace S3 implementation with one for unit tests
+		r.S3 = &DummyS3Client{}
+
+		return r.ETag()
+	})
+}
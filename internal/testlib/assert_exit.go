@@ -0,0 +1,61 @@
+package testlib
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// assertExitEnv is set for the subprocess in which AssertExit runs its function, to the name of the
+// test which started it
+const assertExitEnv = "YAMS_TEST_ASSERT_EXIT"
+
+// AssertExit asserts that the provided function exits the process with the given code, writing
+// text matching the pattern to stderr; e.g. when failing with cli.Fail.
+//
+// The function is run by re-running the current test in a subprocess, so anything it depends on
+// must be set up by the test before calling AssertExit, including environment variables. It can
+// only be called once per test, so tables of cases should call it from a subtest for each
+func AssertExit(t *testing.T, code int, pattern string, fn func()) {
+	t.Helper()
+
+	if os.Getenv(assertExitEnv) == t.Name() {
+		fn()
+		os.Exit(0)
+	}
+
+	var run []string
+	for _, part := range strings.Split(t.Name(), "/") {
+		run = append(run, "^"+regexp.QuoteMeta(part)+"$")
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(os.Args[0], "-test.run="+strings.Join(run, "/"))
+	cmd.Env = append(os.Environ(), assertExitEnv+"="+t.Name())
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		t.Fatalf("expected exit with code %d but observed success", code)
+	case !errors.As(err, &exitErr):
+		t.Fatalf("unable to run subprocess: %v", err)
+	case exitErr.ExitCode() != code:
+		t.Fatalf("expected exit with code %d, got %d: %s",
+			code, exitErr.ExitCode(), stderr.String())
+	}
+
+	match, err := regexp.MatchString(pattern, stderr.String())
+	if err != nil {
+		t.Fatalf("unable to apply exit rgx: %s", pattern)
+	}
+	if !match {
+		t.Fatalf("observed different output (%s) than expected: %s", stderr.String(), pattern)
+	}
+	t.Logf("saw expected exit: %s", strings.Join(strings.Fields(stderr.String()), " "))
+}
@@ -0,0 +1,499 @@
+// Package client provides a typed Go client for the yams v1 API
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	urllib "net/url"
+	"strconv"
+	"strings"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/aws/sar/types"
+	"github.com/nsiow/yams/pkg/entities"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
+)
+
+// -------------------------------------------------------------------------------------------------
+// Client
+// -------------------------------------------------------------------------------------------------
+
+// Client is a typed client for the v1 API of a yams server
+type Client struct {
+	addr  string
+	http  *http.Client
+	token string
+}
+
+// Option customizes the behavior of a Client
+type Option func(*Client)
+
+// WithHTTPClient configures the client to use the provided HTTP client, e.g. to set timeouts or use
+// a custom transport
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithToken configures the client to authenticate each request with the provided bearer token,
+// e.g. for servers behind an authenticating proxy
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New creates a client for the server at the provided address. Addresses without a scheme are
+// assumed to be plain HTTP
+func New(addr string, opts ...Option) *Client {
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+
+	c := &Client{
+		addr: strings.TrimSuffix(addr, "/"),
+		http: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is returned when the server responds with an unexpected status code
+type APIError struct {
+	StatusCode int
+	Message    string
+
+	// Body is the raw body of the response
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("server returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// URL returns the full URL of the API path made up of the provided elements
+func (c *Client) URL(elem ...string) string {
+	url, err := urllib.JoinPath(c.addr+"/api/v1/", elem...)
+	if err != nil {
+		// addr was normalized at construction; only malformed addresses can fail here
+		return c.addr + "/api/v1/" + strings.Join(elem, "/")
+	}
+	return url
+}
+
+// Do performs a request against the API path made up of the provided elements, encoding `in` as
+// the request body if non-nil and decoding the response body into `out` if non-nil; a *[]byte
+// receives the raw body instead. Responses with any status other than `want` are returned as an
+// *APIError
+func (c *Client) Do(ctx context.Context, method string, want int, in, out any, elem ...string) error {
+	return c.do(ctx, method, c.URL(elem...), want, in, out)
+}
+
+// do is the implementation of Do, operating on a fully-formed URL
+func (c *Client) do(ctx context.Context, method, url string, want int, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling '%s': %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response from '%s': %w", url, err)
+	}
+
+	if resp.StatusCode != want {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: respBody}
+		var errOut v1.ErrorOutput
+		if json.Unmarshal(respBody, &errOut) == nil {
+			apiErr.Message = errOut.Error
+		}
+		return apiErr
+	}
+
+	if raw, ok := out.(*[]byte); ok {
+		*raw = respBody
+		return nil
+	}
+	if out != nil {
+		err = json.Unmarshal(respBody, out)
+		if err != nil {
+			return fmt.Errorf("error decoding response from '%s': %w", url, err)
+		}
+	}
+
+	return nil
+}
+
+// get performs a GET request expecting a 200 response, decoding the result
+func get[T any](ctx context.Context, c *Client, elem ...string) (T, error) {
+	var out T
+	err := c.Do(ctx, http.MethodGet, http.StatusOK, nil, &out, elem...)
+	return out, err
+}
+
+// post performs a POST request expecting a 200 response, decoding the result
+func post[T any](ctx context.Context, c *Client, in any, elem ...string) (T, error) {
+	var out T
+	err := c.Do(ctx, http.MethodPost, http.StatusOK, in, &out, elem...)
+	return out, err
+}
+
+// ref converts a decoded result into a pointer, returning nil if there was an error
+func ref[T any](out T, err error) (*T, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// withQuery appends the non-empty query parameters to the URL
+func withQuery(url string, params map[string]string) string {
+	values := urllib.Values{}
+	for k, v := range params {
+		if v != "" {
+			values.Set(k, v)
+		}
+	}
+	if len(values) == 0 {
+		return url
+	}
+	return url + "?" + values.Encode()
+}
+
+// -------------------------------------------------------------------------------------------------
+// Administration
+// -------------------------------------------------------------------------------------------------
+
+// Healthcheck reports whether the server is healthy. Unhealthy servers (with stale sources) are
+// reported via the output rather than an error
+func (c *Client) Healthcheck(ctx context.Context) (*v1.HealthcheckOutput, error) {
+	var out v1.HealthcheckOutput
+	err := c.Do(ctx, http.MethodGet, http.StatusOK, nil, &out, "healthcheck")
+	if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusServiceUnavailable {
+		err = json.Unmarshal(apiErr.Body, &out)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding healthcheck response: %w", err)
+		}
+		return &out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Status describes the contents of the server's universe and the state of its sources
+func (c *Client) Status(ctx context.Context) (*v1.StatusOutput, error) {
+	return ref(get[v1.StatusOutput](ctx, c, "status"))
+}
+
+// RefreshSource immediately reloads the named source
+func (c *Client) RefreshSource(ctx context.Context, name string) (*v1.SourceStatus, error) {
+	return ref(post[v1.SourceStatus](ctx, c, nil, "sources", name, "refresh"))
+}
+
+// OpenAPI retrieves the OpenAPI specification of the server's API
+func (c *Client) OpenAPI(ctx context.Context) (map[string]any, error) {
+	return get[map[string]any](ctx, c, "openapi.json")
+}
+
+// -------------------------------------------------------------------------------------------------
+// Entities
+// -------------------------------------------------------------------------------------------------
+
+// Kind identifies a collection of entities exposed by the API
+type Kind string
+
+const (
+	KindAccounts   Kind = "accounts"
+	KindActions    Kind = "actions"
+	KindGroups     Kind = "groups"
+	KindPolicies   Kind = "policies"
+	KindPrincipals Kind = "principals"
+	KindResources  Kind = "resources"
+)
+
+// Keys lists the keys (ARNs, IDs or names) of all entities of the provided kind
+func (c *Client) Keys(ctx context.Context, kind Kind) ([]string, error) {
+	switch kind {
+	case KindAccounts:
+		accounts, err := c.ListAccounts(ctx)
+		var keys []string
+		for _, a := range accounts {
+			keys = append(keys, a.Id)
+		}
+		return keys, err
+	case KindPolicies:
+		policies, err := c.ListPolicies(ctx)
+		var keys []string
+		for _, p := range policies {
+			keys = append(keys, p.Arn)
+		}
+		return keys, err
+	default:
+		return get[[]string](ctx, c, string(kind))
+	}
+}
+
+// Search lists the keys of all entities of the provided kind which contain the search string
+func (c *Client) Search(ctx context.Context, kind Kind, search string) ([]string, error) {
+	return get[[]string](ctx, c, string(kind), "search", search)
+}
+
+// SearchResources lists the ARNs of resources which contain the search string, optionally limited
+// to resources which can be targeted by the provided action
+func (c *Client) SearchResources(ctx context.Context, search, action string) ([]string, error) {
+	var out []string
+	url := withQuery(c.URL(string(KindResources), "search", search), map[string]string{"action": action})
+	err := c.do(ctx, http.MethodGet, url, http.StatusOK, nil, &out)
+	return out, err
+}
+
+// ListAccounts lists the IDs and names of all accounts
+func (c *Client) ListAccounts(ctx context.Context) ([]v1.AccountEntry, error) {
+	return get[[]v1.AccountEntry](ctx, c, string(KindAccounts))
+}
+
+// ListPolicies lists the ARNs and names of all managed policies
+func (c *Client) ListPolicies(ctx context.Context) ([]v1.PolicyEntry, error) {
+	return get[[]v1.PolicyEntry](ctx, c, string(KindPolicies))
+}
+
+// GetAccount retrieves the account with the provided ID
+func (c *Client) GetAccount(ctx context.Context, id string) (*entities.Account, error) {
+	return ref(get[entities.Account](ctx, c, string(KindAccounts), id))
+}
+
+// GetGroup retrieves the group with the provided ARN
+func (c *Client) GetGroup(ctx context.Context, arn string) (*entities.Group, error) {
+	return ref(get[entities.Group](ctx, c, string(KindGroups), arn))
+}
+
+// GetPolicy retrieves the managed policy with the provided ARN
+func (c *Client) GetPolicy(ctx context.Context, arn string) (*entities.ManagedPolicy, error) {
+	return ref(get[entities.ManagedPolicy](ctx, c, string(KindPolicies), arn))
+}
+
+// GetPrincipal retrieves the principal with the provided ARN
+func (c *Client) GetPrincipal(ctx context.Context, arn string) (*entities.Principal, error) {
+	return ref(get[entities.Principal](ctx, c, string(KindPrincipals), arn))
+}
+
+// GetResource retrieves the resource with the provided ARN
+func (c *Client) GetResource(ctx context.Context, arn string) (*entities.Resource, error) {
+	return ref(get[entities.Resource](ctx, c, string(KindResources), arn))
+}
+
+// GetAction retrieves the Service Authorization Reference definition of the named action
+func (c *Client) GetAction(ctx context.Context, name string) (*types.Action, error) {
+	return ref(get[types.Action](ctx, c, string(KindActions), name))
+}
+
+// FreezeAccount retrieves the fully-resolved representation of the account with the provided ID
+func (c *Client) FreezeAccount(ctx context.Context, id string) (*entities.FrozenAccount, error) {
+	return ref(get[entities.FrozenAccount](ctx, c, string(KindAccounts), id, "freeze"))
+}
+
+// FreezeGroup retrieves the fully-resolved representation of the group with the provided ARN
+func (c *Client) FreezeGroup(ctx context.Context, arn string) (*entities.FrozenGroup, error) {
+	return ref(get[entities.FrozenGroup](ctx, c, string(KindGroups), arn, "freeze"))
+}
+
+// FreezePrincipal retrieves the fully-resolved representation of the principal with the provided
+// ARN
+func (c *Client) FreezePrincipal(ctx context.Context, arn string) (*entities.FrozenPrincipal, error) {
+	return ref(get[entities.FrozenPrincipal](ctx, c, string(KindPrincipals), arn, "freeze"))
+}
+
+// FreezeResource retrieves the fully-resolved representation of the resource with the provided ARN
+func (c *Client) FreezeResource(ctx context.Context, arn string) (*entities.FrozenResource, error) {
+	return ref(get[entities.FrozenResource](ctx, c, string(KindResources), arn, "freeze"))
+}
+
+// -------------------------------------------------------------------------------------------------
+// Simulation
+// -------------------------------------------------------------------------------------------------
+
+// Sim simulates a single request
+func (c *Client) Sim(ctx context.Context, in v1.SimInput) (*v1.SimOutput, error) {
+	return ref(post[v1.SimOutput](ctx, c, in, "sim"))
+}
+
+// SimBatch simulates multiple requests at once
+func (c *Client) SimBatch(ctx context.Context, in v1.SimBatchInput) (*v1.SimBatchOutput, error) {
+	return ref(post[v1.SimBatchOutput](ctx, c, in, "sim", "batch"))
+}
+
+// WhichPrincipals determines which principals may perform an action
+func (c *Client) WhichPrincipals(ctx context.Context, in v1.WhichPrincipalsInput) (v1.WhichPrincipalsOutput, error) {
+	return post[v1.WhichPrincipalsOutput](ctx, c, in, "sim", "whichPrincipals")
+}
+
+// WhichActions determines which actions a principal may perform
+func (c *Client) WhichActions(ctx context.Context, in v1.WhichActionsInput) (v1.WhichActionsOutput, error) {
+	return post[v1.WhichActionsOutput](ctx, c, in, "sim", "whichActions")
+}
+
+// WhichResources determines which resources a principal may perform an action against
+func (c *Client) WhichResources(ctx context.Context, in v1.WhichResourcesInput) (v1.WhichResourcesOutput, error) {
+	return post[v1.WhichResourcesOutput](ctx, c, in, "sim", "whichResources")
+}
+
+// -------------------------------------------------------------------------------------------------
+// Utils
+// -------------------------------------------------------------------------------------------------
+
+// ResourceAccounts maps resources with global namespaces (e.g. S3 buckets) to their account IDs
+func (c *Client) ResourceAccounts(ctx context.Context) (map[string]string, error) {
+	return get[map[string]string](ctx, c, "utils", "resources", "accounts")
+}
+
+// ResourcelessActions lists actions which do not target any resource
+func (c *Client) ResourcelessActions(ctx context.Context) ([]string, error) {
+	return get[[]string](ctx, c, "utils", "actions", "resourceless")
+}
+
+// ActionAccessLevels maps actions to their access levels
+func (c *Client) ActionAccessLevels(ctx context.Context) (map[string]string, error) {
+	return get[map[string]string](ctx, c, "utils", "actions", "accesslevels")
+}
+
+// ActionTargeting describes which resources each action can target
+func (c *Client) ActionTargeting(ctx context.Context) ([]v1.ActionTargeting, error) {
+	return get[[]v1.ActionTargeting](ctx, c, "utils", "actions", "targeting")
+}
+
+// SharedContext retrieves the server's shared request context
+func (c *Client) SharedContext(ctx context.Context) (map[string]string, error) {
+	return get[map[string]string](ctx, c, "utils", "context")
+}
+
+// -------------------------------------------------------------------------------------------------
+// Overlays
+// -------------------------------------------------------------------------------------------------
+
+// ListOverlays lists summaries of all overlays, optionally filtered to names containing the query
+func (c *Client) ListOverlays(ctx context.Context, query string) ([]entities.OverlaySummary, error) {
+	var out []entities.OverlaySummary
+	url := withQuery(c.URL("overlays"), map[string]string{"q": query})
+	err := c.do(ctx, http.MethodGet, url, http.StatusOK, nil, &out)
+	return out, err
+}
+
+// GetOverlay retrieves the overlay with the provided ID
+func (c *Client) GetOverlay(ctx context.Context, id string) (*entities.OverlayData, error) {
+	return ref(get[entities.OverlayData](ctx, c, "overlays", id))
+}
+
+// CreateOverlay creates a new overlay, returning it along with any warnings found while linting it
+func (c *Client) CreateOverlay(ctx context.Context, in v1.CreateOverlayInput) (*v1.OverlayOutput, error) {
+	var out v1.OverlayOutput
+	err := c.Do(ctx, http.MethodPost, http.StatusCreated, in, &out, "overlays")
+	return ref(out, err)
+}
+
+// ValidateOverlay lints a new overlay without creating it. Overlays with errors are rejected with
+// an *APIError whose Body describes each issue
+func (c *Client) ValidateOverlay(ctx context.Context, in v1.CreateOverlayInput) (*v1.OverlayOutput, error) {
+	var out v1.OverlayOutput
+	url := withQuery(c.URL("overlays"), map[string]string{"dryRun": "true"})
+	err := c.do(ctx, http.MethodPost, url, http.StatusOK, in, &out)
+	return ref(out, err)
+}
+
+// UpdateOverlay replaces the contents of the overlay with the provided ID, returning it along with
+// any warnings found while linting it
+func (c *Client) UpdateOverlay(ctx context.Context, id string, in v1.UpdateOverlayInput) (*v1.OverlayOutput, error) {
+	var out v1.OverlayOutput
+	err := c.Do(ctx, http.MethodPut, http.StatusOK, in, &out, "overlays", id)
+	return ref(out, err)
+}
+
+// ValidateOverlayUpdate lints an update to the overlay with the provided ID without applying it
+func (c *Client) ValidateOverlayUpdate(ctx context.Context, id string, in v1.UpdateOverlayInput) (*v1.OverlayOutput, error) {
+	var out v1.OverlayOutput
+	url := withQuery(c.URL("overlays", id), map[string]string{"dryRun": "true"})
+	err := c.do(ctx, http.MethodPut, url, http.StatusOK, in, &out)
+	return ref(out, err)
+}
+
+// DeleteOverlay deletes the overlay with the provided ID
+func (c *Client) DeleteOverlay(ctx context.Context, id string) error {
+	return c.Do(ctx, http.MethodDelete, http.StatusNoContent, nil, nil, "overlays", id)
+}
+
+// ListOverlayRevisions lists the revisions of the overlay with the provided ID, oldest first
+func (c *Client) ListOverlayRevisions(ctx context.Context, id string) ([]entities.OverlayRevision, error) {
+	return get[[]entities.OverlayRevision](ctx, c, "overlays", id, "revisions")
+}
+
+// GetOverlayRevision retrieves a specific revision of the overlay with the provided ID
+func (c *Client) GetOverlayRevision(ctx context.Context, id string, revision int) (*entities.OverlayData, error) {
+	return ref(get[entities.OverlayData](ctx, c, "overlays", id, "revisions", strconv.Itoa(revision)))
+}
+
+// DiffOverlayRevisions describes the changes between two revisions of the overlay with the
+// provided ID; zero values select the server's defaults (the current revision and its predecessor)
+func (c *Client) DiffOverlayRevisions(ctx context.Context, id string, from, to int) (*entities.OverlayDiff, error) {
+	params := map[string]string{}
+	if from != 0 {
+		params["from"] = strconv.Itoa(from)
+	}
+	if to != 0 {
+		params["to"] = strconv.Itoa(to)
+	}
+
+	var out entities.OverlayDiff
+	url := withQuery(c.URL("overlays", id, "diff"), params)
+	err := c.do(ctx, http.MethodGet, url, http.StatusOK, nil, &out)
+	return ref(out, err)
+}
+
+// ExportOverlay renders the overlay with the provided ID as "terraform", "cloudformation" or
+// "awsconfig"; a zero revision selects the current revision
+func (c *Client) ExportOverlay(ctx context.Context, id, format string, revision int) ([]byte, error) {
+	params := map[string]string{"format": format}
+	if revision != 0 {
+		params["revision"] = strconv.Itoa(revision)
+	}
+
+	var out []byte
+	url := withQuery(c.URL("overlays", id, "export"), params)
+	err := c.do(ctx, http.MethodGet, url, http.StatusOK, nil, &out)
+	return out, err
+}
+
+// RollbackOverlay restores an earlier revision of the overlay with the provided ID
+func (c *Client) RollbackOverlay(ctx context.Context, id string, in v1.RollbackOverlayInput) (*entities.OverlayData, error) {
+	return ref(post[entities.OverlayData](ctx, c, in, "overlays", id, "rollback"))
+}
@@ -0,0 +1,424 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/overlay"
+	"github.com/nsiow/yams/pkg/policy"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// newTestServer starts a server exposing the v1 API handlers over a small universe
+func newTestServer(t *testing.T) *Client {
+	t.Helper()
+
+	simulator, err := sim.NewSimulator()
+	if err != nil {
+		t.Fatalf("failed to create simulator: %v", err)
+	}
+
+	uv := simulator.Universe
+	uv.PutAccount(entities.Account{Id: "123456789012", Name: "TestAccount"})
+	uv.PutPrincipal(entities.Principal{
+		Type:      "AWS::IAM::User",
+		Name:      "alice",
+		Arn:       "arn:aws:iam::123456789012:user/alice",
+		AccountId: "123456789012",
+		InlinePolicies: []policy.Policy{
+			{
+				Statement: []policy.Statement{
+					{
+						Effect:   policy.EFFECT_ALLOW,
+						Action:   []string{"s3:ListBucket"},
+						Resource: []string{"arn:aws:s3:::bucket"},
+					},
+				},
+			},
+		},
+	})
+	uv.PutResource(entities.Resource{
+		Type:      "AWS::S3::Bucket",
+		Arn:       "arn:aws:s3:::bucket",
+		AccountId: "123456789012",
+	})
+
+	api := &v1.API{Simulator: simulator}
+	overlayAPI := &v1.OverlayAPI{Store: overlay.NewMemoryStore(), Base: uv}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"entities":3,"sources":[{"name":"a.json","source":"/tmp/a.json","stale":true}]}`))
+	})
+	mux.HandleFunc("GET /api/v1/healthcheck", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"stale","stale":["a.json"]}`))
+	})
+	mux.HandleFunc("GET /api/v1/accounts", api.ListAccounts)
+	mux.HandleFunc("GET /api/v1/accounts/{key...}", api.GetAccount)
+	mux.HandleFunc("GET /api/v1/principals", api.ListPrincipals)
+	mux.HandleFunc("GET /api/v1/principals/{key...}", api.GetPrincipal)
+	mux.HandleFunc("GET /api/v1/resources/search/{search...}", api.SearchResources)
+	mux.HandleFunc("GET /api/v1/openapi.json", v1.ServeOpenAPI)
+	mux.HandleFunc("POST /api/v1/sim", api.SimRun)
+	mux.HandleFunc("POST /api/v1/sim/batch", api.SimBatch)
+	mux.HandleFunc("POST /api/v1/sim/whichActions", api.WhichActions)
+	mux.HandleFunc("POST /api/v1/sim/whichPrincipals", api.WhichPrincipals)
+	mux.HandleFunc("POST /api/v1/sim/whichResources", api.WhichResources)
+	mux.HandleFunc("GET /api/v1/overlays", overlayAPI.ListOverlays)
+	mux.HandleFunc("POST /api/v1/overlays", overlayAPI.CreateOverlay)
+	mux.HandleFunc("GET /api/v1/overlays/{id}", overlayAPI.GetOverlay)
+	mux.HandleFunc("PUT /api/v1/overlays/{id}", overlayAPI.UpdateOverlay)
+	mux.HandleFunc("DELETE /api/v1/overlays/{id}", overlayAPI.DeleteOverlay)
+	mux.HandleFunc("GET /api/v1/overlays/{id}/revisions", overlayAPI.ListOverlayRevisions)
+	mux.HandleFunc("GET /api/v1/overlays/{id}/revisions/{revision}", overlayAPI.GetOverlayRevision)
+	mux.HandleFunc("GET /api/v1/overlays/{id}/diff", overlayAPI.DiffOverlayRevisions)
+	mux.HandleFunc("GET /api/v1/overlays/{id}/export", overlayAPI.ExportOverlay)
+	mux.HandleFunc("POST /api/v1/overlays/{id}/rollback", overlayAPI.RollbackOverlay)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return New(server.URL)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "localhost:8888", want: "http://localhost:8888/api/v1/status"},
+		{addr: "http://localhost:8888/", want: "http://localhost:8888/api/v1/status"},
+		{addr: "https://yams.example.com", want: "https://yams.example.com/api/v1/status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got := New(tt.addr).URL("status")
+			if got != tt.want {
+				t.Errorf("URL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithToken(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = append(got, req.Header.Get("Authorization"))
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	if _, err := New(server.URL).Status(ctx); err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if _, err := New(server.URL, WithToken("s3cr3t")).Status(ctx); err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	want := []string{"", "Bearer s3cr3t"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Authorization headers = %q, want %q", got, want)
+	}
+}
+
+func TestClient_Status(t *testing.T) {
+	c := newTestServer(t)
+
+	status, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Entities != 3 || len(status.Sources) != 1 || !status.Sources[0].Stale {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	health, err := c.Healthcheck(context.Background())
+	if err != nil {
+		t.Fatalf("Healthcheck() error = %v", err)
+	}
+	if health.Status != "stale" || !reflect.DeepEqual(health.Stale, []string{"a.json"}) {
+		t.Errorf("Healthcheck() = %+v", health)
+	}
+}
+
+func TestClient_Entities(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	accounts, err := c.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("ListAccounts() error = %v", err)
+	}
+	if !reflect.DeepEqual(accounts, []v1.AccountEntry{{Id: "123456789012", Name: "TestAccount"}}) {
+		t.Errorf("ListAccounts() = %v", accounts)
+	}
+
+	keys, err := c.Keys(ctx, KindAccounts)
+	if err != nil || !reflect.DeepEqual(keys, []string{"123456789012"}) {
+		t.Errorf("Keys(accounts) = %v, %v", keys, err)
+	}
+
+	keys, err = c.Keys(ctx, KindPrincipals)
+	if err != nil || !reflect.DeepEqual(keys, []string{"arn:aws:iam::123456789012:user/alice"}) {
+		t.Errorf("Keys(principals) = %v, %v", keys, err)
+	}
+
+	principal, err := c.GetPrincipal(ctx, "arn:aws:iam::123456789012:user/alice")
+	if err != nil {
+		t.Fatalf("GetPrincipal() error = %v", err)
+	}
+	if principal.Name != "alice" || len(principal.InlinePolicies) != 1 {
+		t.Errorf("GetPrincipal() = %+v", principal)
+	}
+
+	frozen, err := c.FreezePrincipal(ctx, "arn:aws:iam::123456789012:user/alice")
+	if err != nil {
+		t.Fatalf("FreezePrincipal() error = %v", err)
+	}
+	if frozen.Arn != "arn:aws:iam::123456789012:user/alice" {
+		t.Errorf("FreezePrincipal() = %+v", frozen)
+	}
+
+	account, err := c.GetAccount(ctx, "123456789012")
+	if err != nil || account.Name != "TestAccount" {
+		t.Errorf("GetAccount() = %+v, %v", account, err)
+	}
+
+	resources, err := c.SearchResources(ctx, "bucket", "s3:ListBucket")
+	if err != nil || !reflect.DeepEqual(resources, []string{"arn:aws:s3:::bucket"}) {
+		t.Errorf("SearchResources() = %v, %v", resources, err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	c := newTestServer(t)
+
+	_, err := c.GetPrincipal(context.Background(), "arn:aws:iam::123456789012:user/missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, http.StatusNotFound)
+	}
+	if apiErr.Message == "" {
+		t.Error("expected error message to be decoded from response")
+	}
+
+	_, err = c.Sim(context.Background(), v1.SimInput{Principal: "p"})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid simulation, got %v", err)
+	}
+
+	// unreachable servers produce transport errors
+	_, err = New("http://127.0.0.1:1").Status(context.Background())
+	if err == nil || errors.As(err, &apiErr) {
+		t.Errorf("expected transport error, got %v", err)
+	}
+}
+
+func TestClient_Sim(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	out, err := c.Sim(ctx, v1.SimInput{
+		Principal: "arn:aws:iam::123456789012:user/alice",
+		Action:    "s3:ListBucket",
+		Resource:  "arn:aws:s3:::bucket",
+	})
+	if err != nil {
+		t.Fatalf("Sim() error = %v", err)
+	}
+	if out.Result != "ALLOW" {
+		t.Errorf("Sim() result = %s, want ALLOW", out.Result)
+	}
+
+	batch, err := c.SimBatch(ctx, v1.SimBatchInput{Simulations: []v1.SimInput{
+		{Principal: "arn:aws:iam::123456789012:user/alice", Action: "s3:ListBucket", Resource: "arn:aws:s3:::bucket"},
+		{Principal: "arn:aws:iam::123456789012:user/alice"},
+	}})
+	if err != nil {
+		t.Fatalf("SimBatch() error = %v", err)
+	}
+	if len(batch.Results) != 2 || batch.Results[0].Output.Result != "ALLOW" || batch.Results[1].Error == "" {
+		t.Errorf("SimBatch() = %+v", batch)
+	}
+
+	actions, err := c.WhichActions(ctx, v1.WhichActionsInput{
+		Principal: "arn:aws:iam::123456789012:user/alice",
+		Resource:  "arn:aws:s3:::bucket",
+	})
+	if err != nil || !reflect.DeepEqual(actions, v1.WhichActionsOutput{"s3:ListBucket"}) {
+		t.Errorf("WhichActions() = %v, %v", actions, err)
+	}
+
+	principals, err := c.WhichPrincipals(ctx, v1.WhichPrincipalsInput{
+		Action:   "s3:ListBucket",
+		Resource: "arn:aws:s3:::bucket",
+	})
+	if err != nil || !reflect.DeepEqual(principals, v1.WhichPrincipalsOutput{"arn:aws:iam::123456789012:user/alice"}) {
+		t.Errorf("WhichPrincipals() = %v, %v", principals, err)
+	}
+
+	resources, err := c.WhichResources(ctx, v1.WhichResourcesInput{
+		Principal: "arn:aws:iam::123456789012:user/alice",
+		Action:    "s3:ListBucket",
+	})
+	if err != nil || !reflect.DeepEqual(resources, v1.WhichResourcesOutput{"arn:aws:s3:::bucket"}) {
+		t.Errorf("WhichResources() = %v, %v", resources, err)
+	}
+}
+
+func TestClient_Overlays(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	created, err := c.CreateOverlay(ctx, v1.CreateOverlayInput{
+		Name:     "test",
+		Accounts: []entities.Account{{Id: "111111111111"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateOverlay() error = %v", err)
+	}
+	if created.ID != entities.GenerateOverlayID("test") || len(created.Accounts) != 1 {
+		t.Errorf("CreateOverlay() = %+v", created)
+	}
+
+	summaries, err := c.ListOverlays(ctx, "tes")
+	if err != nil || len(summaries) != 1 || summaries[0].NumAccounts != 1 {
+		t.Errorf("ListOverlays() = %+v, %v", summaries, err)
+	}
+
+	updated, err := c.UpdateOverlay(ctx, created.ID, v1.UpdateOverlayInput{
+		Principals: []entities.Principal{{Arn: "arn:aws:iam::111111111111:role/r"}},
+	})
+	if err != nil || len(updated.Principals) != 1 || len(updated.Accounts) != 0 {
+		t.Errorf("UpdateOverlay() = %+v, %v", updated, err)
+	}
+
+	got, err := c.GetOverlay(ctx, created.ID)
+	if err != nil || got.Name != "test" {
+		t.Errorf("GetOverlay() = %+v, %v", got, err)
+	}
+
+	revisions, err := c.ListOverlayRevisions(ctx, created.ID)
+	if err != nil || len(revisions) != 2 {
+		t.Errorf("ListOverlayRevisions() = %+v, %v", revisions, err)
+	}
+
+	first, err := c.GetOverlayRevision(ctx, created.ID, 1)
+	if err != nil || len(first.Accounts) != 1 {
+		t.Errorf("GetOverlayRevision() = %+v, %v", first, err)
+	}
+
+	diff, err := c.DiffOverlayRevisions(ctx, created.ID, 0, 0)
+	if err != nil || len(diff.Changes) != 2 {
+		t.Errorf("DiffOverlayRevisions() = %+v, %v", diff, err)
+	}
+
+	restored, err := c.RollbackOverlay(ctx, created.ID, v1.RollbackOverlayInput{Revision: 1})
+	if err != nil || restored.Revision != 3 || len(restored.Accounts) != 1 {
+		t.Errorf("RollbackOverlay() = %+v, %v", restored, err)
+	}
+
+	if err := c.DeleteOverlay(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteOverlay() error = %v", err)
+	}
+
+	_, err = c.GetOverlay(ctx, created.ID)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %v", err)
+	}
+}
+
+func TestClient_ValidateOverlay(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	principal := entities.Principal{
+		Type:             "AWS::IAM::Role",
+		AccountId:        "123456789012",
+		Arn:              "arn:aws:iam::123456789012:role/r",
+		AttachedPolicies: []entities.Arn{"arn:aws:iam::123456789012:policy/missing"},
+	}
+	out, err := c.ValidateOverlay(ctx, v1.CreateOverlayInput{
+		Name:       "test",
+		Principals: []entities.Principal{principal},
+	})
+	if err != nil || !out.DryRun || len(out.Warnings) != 1 {
+		t.Fatalf("ValidateOverlay() = %+v, %v", out, err)
+	}
+	if summaries, _ := c.ListOverlays(ctx, ""); len(summaries) != 0 {
+		t.Errorf("expected validated overlay not to be stored, got %+v", summaries)
+	}
+
+	created, err := c.CreateOverlay(ctx, v1.CreateOverlayInput{Name: "test"})
+	if err != nil {
+		t.Fatalf("CreateOverlay() error = %v", err)
+	}
+
+	principal.Arn = "arn:aws:iam::210987654321:role/r"
+	_, err = c.ValidateOverlayUpdate(ctx, created.ID, v1.UpdateOverlayInput{
+		Principals: []entities.Principal{principal},
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for overlay with errors, got %v", err)
+	}
+	var lintErr v1.OverlayLintError
+	if err := json.Unmarshal(apiErr.Body, &lintErr); err != nil || len(lintErr.Issues) != 2 {
+		t.Errorf("unexpected lint error body: %s", apiErr.Body)
+	}
+}
+
+func TestClient_ExportOverlay(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	created, err := c.CreateOverlay(ctx, v1.CreateOverlayInput{
+		Name: "test",
+		Principals: []entities.Principal{{
+			Type:      "AWS::IAM::User",
+			AccountId: "123456789012",
+			Arn:       "arn:aws:iam::123456789012:user/alice",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CreateOverlay() error = %v", err)
+	}
+
+	out, err := c.ExportOverlay(ctx, created.ID, "terraform", 0)
+	if err != nil || !strings.Contains(string(out), `resource "aws_iam_user" "alice" {`) {
+		t.Errorf("ExportOverlay() = %s, %v", out, err)
+	}
+
+	_, err = c.ExportOverlay(ctx, created.ID, "pulumi", 0)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for unsupported format, got %v", err)
+	}
+}
+
+func TestClient_OpenAPI(t *testing.T) {
+	c := newTestServer(t)
+
+	spec, err := c.OpenAPI(context.Background())
+	if err != nil {
+		t.Fatalf("OpenAPI() error = %v", err)
+	}
+	if spec["openapi"] != "3.0.3" {
+		t.Errorf("unexpected specification: %v", spec["openapi"])
+	}
+}
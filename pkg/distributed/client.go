@@ -0,0 +1,78 @@
+package distributed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	json "github.com/bytedance/sonic"
+)
+
+// Client submits product requests to a coordinator
+type Client struct {
+	addr string
+	http *http.Client
+}
+
+// NewClient creates a client for the coordinator at the provided address. Addresses without a
+// scheme are assumed to be plain HTTP
+func NewClient(addr string) *Client {
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+	return &Client{addr: strings.TrimSuffix(addr, "/"), http: http.DefaultClient}
+}
+
+// Product submits a product request and passes each result to the provided function as workers
+// complete the partitions of the product. It returns once every partition is complete, or with an
+// error if any partition failed on every attempt
+func (c *Client) Product(ctx context.Context, req ProductRequest, onResult func(Result)) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.addr+"/api/v1/product", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("unable to reach coordinator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return fmt.Errorf("invalid message from coordinator: %w", err)
+		}
+
+		switch {
+		case msg.Result != nil:
+			onResult(*msg.Result)
+		case msg.Error != "":
+			return errors.New(msg.Error)
+		case msg.Done:
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading results: %w", err)
+	}
+	return fmt.Errorf("coordinator closed the stream before the product was complete")
+}
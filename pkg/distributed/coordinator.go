@@ -0,0 +1,490 @@
+package distributed
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/server/httputil"
+)
+
+// Defaults for the behavior of a Coordinator
+const (
+	DefaultPartitionSize = 100
+	DefaultLeaseTimeout  = time.Minute
+	DefaultMaxAttempts   = 3
+	DefaultPollTimeout   = 20 * time.Second
+)
+
+// HeaderWorker identifies the worker making a request to the coordinator
+const HeaderWorker = "X-Yams-Worker"
+
+// Coordinator divides product requests into partitions and leases them to workers. It is an
+// http.Handler serving both the client and worker sides of the protocol:
+//
+//   - POST /api/v1/product: submit a [ProductRequest], streaming back [Message] lines
+//   - POST /api/v1/workers/lease: lease a [Partition], waiting for one to become available
+//   - POST /api/v1/workers/partitions/{id}: stream the [Message] lines of a leased partition
+type Coordinator struct {
+	// PartitionSize is the number of principals in each partition
+	PartitionSize int
+
+	// LeaseTimeout is how long a worker may hold a partition without sending a message before the
+	// partition is retried elsewhere
+	LeaseTimeout time.Duration
+
+	// MaxAttempts is the number of times a partition is attempted before its product fails
+	MaxAttempts int
+
+	// PollTimeout is how long a lease request waits for a partition to become available
+	PollTimeout time.Duration
+
+	mux *http.ServeMux
+
+	// mu guards the state below
+	mu sync.Mutex
+
+	// pending holds partitions waiting to be leased, in order of submission
+	pending []*partition
+
+	// leased holds partitions which are being simulated by a worker, keyed by ID
+	leased map[string]*partition
+
+	// workers holds the time at which each worker last made a request
+	workers map[string]time.Time
+
+	// hashes holds the sources hash of every product submitted so far
+	hashes map[string]bool
+
+	// changed is closed and replaced whenever partitions become available
+	changed chan struct{}
+}
+
+// product tracks the progress of a single product request
+type product struct {
+	req ProductRequest
+	ctx context.Context
+
+	// completed receives the results of each partition once complete
+	completed chan []Result
+
+	// failed is closed, with err set, if any partition exhausts its attempts
+	failed chan struct{}
+	err    error
+}
+
+// partition tracks a single partition of a product
+type partition struct {
+	Partition
+	product *product
+
+	// worker and deadline describe the current lease
+	worker   string
+	deadline time.Time
+
+	// failedOn holds the workers on which earlier attempts failed
+	failedOn []string
+}
+
+// NewCoordinator creates a Coordinator with the default settings
+func NewCoordinator() *Coordinator {
+	c := &Coordinator{
+		PartitionSize: DefaultPartitionSize,
+		LeaseTimeout:  DefaultLeaseTimeout,
+		MaxAttempts:   DefaultMaxAttempts,
+		PollTimeout:   DefaultPollTimeout,
+		mux:           http.NewServeMux(),
+		leased:        map[string]*partition{},
+		workers:       map[string]time.Time{},
+		hashes:        map[string]bool{},
+		changed:       make(chan struct{}),
+	}
+
+	c.mux.HandleFunc("POST /api/v1/product", c.Product)
+	c.mux.HandleFunc("POST /api/v1/workers/lease", c.Lease)
+	c.mux.HandleFunc("POST /api/v1/workers/partitions/{id}", c.Report)
+	return c
+}
+
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c.mux.ServeHTTP(w, req)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Clients
+// -------------------------------------------------------------------------------------------------
+
+// Product accepts a product request, divides it into partitions, and streams back the results of
+// each partition as it completes, followed by a final message reporting success or failure
+func (c *Coordinator) Product(w http.ResponseWriter, req *http.Request) {
+	var body ProductRequest
+	decoder := json.ConfigDefault.NewDecoder(req.Body)
+	if err := decoder.Decode(&body); err != nil {
+		httputil.ClientError(w, req, fmt.Errorf("unable to parse request: %w", err))
+		return
+	}
+	if body.SourcesHash == "" {
+		httputil.ClientError(w, req, fmt.Errorf("missing sourcesHash"))
+		return
+	}
+	if len(body.Principals) == 0 || len(body.Actions) == 0 {
+		httputil.ClientError(w, req, fmt.Errorf("missing principals or actions"))
+		return
+	}
+
+	p := &product{
+		req:       body,
+		ctx:       req.Context(),
+		completed: make(chan []Result),
+		failed:    make(chan struct{}),
+	}
+	partitions := c.submit(p)
+	slog.Info("product submitted",
+		"principals", len(body.Principals),
+		"actions", len(body.Actions),
+		"resources", len(body.Resources),
+		"partitions", partitions)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	rc.Flush()
+
+	enc := json.ConfigDefault.NewEncoder(w)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var count int
+	for remaining := partitions; remaining > 0; {
+		select {
+		case results := <-p.completed:
+			remaining--
+			for i := range results {
+				if err := enc.Encode(Message{Result: &results[i]}); err != nil {
+					return
+				}
+			}
+			count += len(results)
+			rc.Flush()
+			slog.Debug("partition results sent", "remaining", remaining, "results", count)
+		case <-p.failed:
+			c.cancel(p)
+			slog.Error("product failed", "error", p.err)
+			enc.Encode(Message{Error: p.err.Error()})
+			return
+		case <-ticker.C:
+			c.expire(time.Now())
+		case <-req.Context().Done():
+			c.cancel(p)
+			slog.Info("product cancelled by client")
+			return
+		}
+	}
+
+	enc.Encode(Message{Done: true, Count: count})
+	slog.Info("product complete", "results", count)
+}
+
+// submit queues the partitions of a product, returning the number of partitions
+func (c *Coordinator) submit(p *product) int {
+	prefix := rand.Text()[:8]
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hashes[p.req.SourcesHash] = true
+	var n int
+	for chunk := range slices.Chunk(p.req.Principals, max(c.PartitionSize, 1)) {
+		req := p.req
+		req.Principals = chunk
+		c.pending = append(c.pending, &partition{
+			Partition: Partition{ID: fmt.Sprintf("%s-%d", prefix, n), ProductRequest: req},
+			product:   p,
+		})
+		n++
+	}
+	c.notify()
+	return n
+}
+
+// cancel discards the remaining partitions of a product which has failed or been abandoned
+func (c *Coordinator) cancel(p *product) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending = slices.DeleteFunc(c.pending, func(part *partition) bool {
+		return part.product == p
+	})
+	for id, part := range c.leased {
+		if part.product == p {
+			delete(c.leased, id)
+		}
+	}
+}
+
+// notify wakes any lease requests waiting for partitions; the caller must hold mu
+func (c *Coordinator) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// -------------------------------------------------------------------------------------------------
+// Workers
+// -------------------------------------------------------------------------------------------------
+
+// LeaseRequest is the body of a worker's request for a partition
+type LeaseRequest struct {
+	// SourcesHash is the content hash of the worker's sources
+	SourcesHash string `json:"sourcesHash"`
+}
+
+// Lease assigns a pending partition to the requesting worker, waiting up to PollTimeout for one to
+// become available. It responds with no content if none did, including when partitions are only
+// available for other products' sources. A conflict is reserved for workers whose sources match no
+// product submitted so far, which are likely to have been started with the wrong sources
+func (c *Coordinator) Lease(w http.ResponseWriter, req *http.Request) {
+	worker := req.Header.Get(HeaderWorker)
+	if worker == "" {
+		httputil.ClientError(w, req, fmt.Errorf("missing %s header", HeaderWorker))
+		return
+	}
+
+	var body LeaseRequest
+	decoder := json.ConfigDefault.NewDecoder(req.Body)
+	if err := decoder.Decode(&body); err != nil {
+		httputil.ClientError(w, req, fmt.Errorf("unable to parse request: %w", err))
+		return
+	}
+
+	timer := time.NewTimer(c.PollTimeout)
+	defer timer.Stop()
+
+	for {
+		c.expire(time.Now())
+
+		c.mu.Lock()
+		part, mismatched := c.next(worker, body.SourcesHash, time.Now())
+		changed := c.changed
+		c.mu.Unlock()
+
+		switch {
+		case part != nil:
+			slog.Info("partition leased",
+				"partition", part.ID,
+				"attempt", part.Attempt,
+				"worker", worker)
+			httputil.WriteJsonResponse(w, req, part.Partition)
+			return
+		case mismatched:
+			httputil.Error(w, req, http.StatusConflict,
+				fmt.Errorf("worker sources do not match those of any product"))
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// next leases the first suitable pending partition to the worker; the caller must hold mu.
+// Partitions are not retried on a worker where they failed before, unless no other worker is
+// available to take them. If partitions are pending but none could be leased because the worker's
+// sources match no product submitted so far, mismatched is set
+func (c *Coordinator) next(worker, hash string, now time.Time) (part *partition, mismatched bool) {
+	c.workers[worker] = now
+
+	mismatched = len(c.pending) > 0 && !c.hashes[hash]
+	for i, p := range c.pending {
+		if p.SourcesHash != hash {
+			continue
+		}
+
+		if slices.Contains(p.failedOn, worker) && c.othersAvailable(p, worker, now) {
+			continue
+		}
+
+		c.pending = slices.Delete(c.pending, i, i+1)
+		p.Attempt++
+		p.worker = worker
+		p.deadline = now.Add(c.LeaseTimeout)
+		c.leased[p.ID] = p
+		return p, false
+	}
+	return nil, mismatched
+}
+
+// othersAvailable determines whether any worker other than the provided one, and those on which
+// the partition already failed, has been seen recently; the caller must hold mu
+func (c *Coordinator) othersAvailable(p *partition, worker string, now time.Time) bool {
+	for other, seen := range c.workers {
+		active := now.Sub(seen) < c.LeaseTimeout
+		if other != worker && !slices.Contains(p.failedOn, other) && active {
+			return true
+		}
+	}
+	return false
+}
+
+// Report receives the results of a leased partition from its worker, as a stream of messages
+// ending with either a done or an error message. Results are held until the stream ends
+// successfully, at which point they are forwarded to the client; otherwise the partition is retried
+func (c *Coordinator) Report(w http.ResponseWriter, req *http.Request) {
+	worker := req.Header.Get(HeaderWorker)
+	id := req.PathValue("id")
+
+	c.mu.Lock()
+	part, ok := c.leased[id]
+	if ok && part.worker != worker {
+		ok = false
+	}
+	c.mu.Unlock()
+	if !ok {
+		httputil.Error(w, req, http.StatusGone,
+			fmt.Errorf("partition '%s' is not leased to worker '%s'", id, worker))
+		return
+	}
+
+	var results []Result
+	scanner := bufio.NewScanner(req.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			c.fail(part, fmt.Errorf("invalid message from worker: %w", err))
+			httputil.ClientError(w, req, err)
+			return
+		}
+
+		// every message, including heartbeats, extends the lease
+		if !c.extend(part) {
+			httputil.Error(w, req, http.StatusGone,
+				fmt.Errorf("lease on partition '%s' has expired", id))
+			return
+		}
+
+		switch {
+		case msg.Result != nil:
+			results = append(results, *msg.Result)
+		case msg.Error != "":
+			c.fail(part, errors.New(msg.Error))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case msg.Done:
+			if msg.Count != len(results) {
+				err := fmt.Errorf("worker reported %d results but sent %d", msg.Count, len(results))
+				c.fail(part, err)
+				httputil.ClientError(w, req, err)
+				return
+			}
+			c.complete(part, results)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = fmt.Errorf("stream ended without a final message")
+	}
+	c.fail(part, fmt.Errorf("error reading results from worker: %w", err))
+	httputil.ClientError(w, req, err)
+}
+
+// extend renews the lease on a partition, returning false if it has been lost
+func (c *Coordinator) extend(part *partition) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.leased[part.ID] != part {
+		return false
+	}
+	part.deadline = time.Now().Add(c.LeaseTimeout)
+	c.workers[part.worker] = time.Now()
+	return true
+}
+
+// complete forwards the results of a partition to its client
+func (c *Coordinator) complete(part *partition, results []Result) {
+	c.mu.Lock()
+	if c.leased[part.ID] != part {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.leased, part.ID)
+	c.mu.Unlock()
+
+	slog.Info("partition complete",
+		"partition", part.ID,
+		"worker", part.worker,
+		"results", len(results))
+
+	select {
+	case part.product.completed <- results:
+	case <-part.product.ctx.Done():
+	}
+}
+
+// fail records a failed attempt at a partition, returning it to the queue to be retried on another
+// worker, or failing its product if it has no attempts remaining
+func (c *Coordinator) fail(part *partition, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.leased[part.ID] != part {
+		return
+	}
+	c.requeue(part, err)
+}
+
+// expire requeues partitions whose lease has passed its deadline
+func (c *Coordinator) expire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, part := range c.leased {
+		if now.After(part.deadline) {
+			c.requeue(part, fmt.Errorf("lease expired"))
+		}
+	}
+}
+
+// requeue is the shared implementation of fail and expire; the caller must hold mu
+func (c *Coordinator) requeue(part *partition, err error) {
+	delete(c.leased, part.ID)
+	part.failedOn = append(part.failedOn, part.worker)
+
+	slog.Warn("partition attempt failed",
+		"partition", part.ID,
+		"attempt", part.Attempt,
+		"worker", part.worker,
+		"error", err)
+
+	if part.Attempt >= c.MaxAttempts {
+		p := part.product
+		select {
+		case <-p.failed:
+		default:
+			p.err = fmt.Errorf("partition '%s' failed after %d attempts: %w",
+				part.ID, part.Attempt, err)
+			close(p.failed)
+		}
+		return
+	}
+
+	c.pending = append(c.pending, part)
+	c.notify()
+}
@@ -0,0 +1,140 @@
+// Package distributed spreads the simulation of large access products across multiple processes
+// or machines. A [Coordinator] accepts product requests from clients, divides them into partitions
+// of principals, and leases those partitions to [Worker] processes, which simulate them against
+// their own copy of the sources and stream the results back. Partitions whose worker fails or
+// disappears are retried on another worker, and each partition's results are forwarded to the
+// client only once complete, so that retries never produce duplicates.
+//
+// Workers must load the same sources as the client which requested the product, which is verified
+// by comparing the content hash returned by [LoadSources]
+package distributed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/loaders/awsconfig"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// -------------------------------------------------------------------------------------------------
+// Protocol
+// -------------------------------------------------------------------------------------------------
+
+// ProductRequest asks for the simulation of every combination of the provided principals, actions
+// and resources
+type ProductRequest struct {
+	// SourcesHash is the content hash of the sources which the principals and resources were drawn
+	// from; only workers with an identical hash are assigned the product's partitions
+	SourcesHash string `json:"sourcesHash"`
+
+	Principals []string          `json:"principals"`
+	Actions    []string          `json:"actions"`
+	Resources  []string          `json:"resources"`
+	Context    map[string]string `json:"context,omitempty"`
+
+	// Denied causes denied results to be returned as well as allowed ones
+	Denied bool `json:"denied,omitempty"`
+}
+
+// Partition is the portion of a product which is leased to a single worker
+type Partition struct {
+	// ID identifies the partition when reporting its results
+	ID string `json:"id"`
+
+	// Attempt counts the attempts to simulate the partition, starting from 1
+	Attempt int `json:"attempt"`
+
+	ProductRequest
+}
+
+// Result is the outcome of simulating a single principal, action and resource
+type Result struct {
+	Principal string `json:"principal"`
+	Action    string `json:"action"`
+	Resource  string `json:"resource"`
+	Allowed   bool   `json:"allowed"`
+}
+
+// Message is a single line of a newline-delimited stream of results. Each message holds either a
+// result or the final status of the stream; messages with neither are heartbeats, which show that
+// a long-running stream is still alive
+type Message struct {
+	Result *Result `json:"result,omitempty"`
+
+	// Done marks the successful end of a stream, along with the number of results it contained
+	Done  bool `json:"done,omitempty"`
+	Count int  `json:"count,omitempty"`
+
+	// Error marks the unsuccessful end of a stream
+	Error string `json:"error,omitempty"`
+}
+
+// AccessTuple converts the result to the form produced by local simulations
+func (r *Result) AccessTuple() sim.AccessTuple {
+	return sim.AccessTuple{
+		Principal: r.Principal,
+		Action:    r.Action,
+		Resource:  r.Resource,
+		Result: &sim.SimResult{
+			Principal: r.Principal,
+			Action:    r.Action,
+			Resource:  r.Resource,
+			IsAllowed: r.Allowed,
+		},
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// Sources
+// -------------------------------------------------------------------------------------------------
+
+// LoadSources creates a Simulator with data loaded from the specified sources, along with a hash
+// of their content. The hash covers the decompressed bytes of each source in order, so the same
+// data loaded from a different location produces the same hash
+func LoadSources(sources []string) (*sim.Simulator, string, error) {
+	simulator, err := sim.NewSimulator()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create simulator: %w", err)
+	}
+
+	combined := sha256.New()
+	for _, src := range sources {
+		reader, err := smartrw.NewReader(src)
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to open source '%s': %w", src, err)
+		}
+
+		h := sha256.New()
+		reader.ReadCloser = &hashingReadCloser{ReadCloser: reader.ReadCloser, hash: h}
+
+		uv, err := awsconfig.LoadSource(reader.Source, reader)
+		reader.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to load source '%s': %w", src, err)
+		}
+		combined.Write(h.Sum(nil))
+
+		simulator.Universe.Merge(uv)
+		slog.Info("loaded source", "source", src, "size", simulator.Universe.Size())
+	}
+
+	return simulator, hex.EncodeToString(combined.Sum(nil)), nil
+}
+
+// hashingReadCloser hashes everything read through it
+type hashingReadCloser struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+func (h *hashingReadCloser) Read(p []byte) (int, error) {
+	n, err := h.ReadCloser.Read(p)
+	h.hash.Write(p[:n])
+	return n, err
+}
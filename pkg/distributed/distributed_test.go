@@ -0,0 +1,440 @@
+package distributed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+const testSource = "../../testdata/real-world/awsconfig.jsonl"
+
+var testActions = []string{"s3:GetObject", "s3:PutObject", "sqs:SendMessage"}
+
+// testEnv holds a coordinator along with the simulator and hash of the test sources
+type testEnv struct {
+	coordinator *Coordinator
+	server      *httptest.Server
+	simulator   *sim.Simulator
+	hash        string
+	ctx         context.Context
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	simulator, hash, err := LoadSources([]string{testSource})
+	if err != nil {
+		t.Fatalf("unable to load sources: %v", err)
+	}
+
+	coordinator := NewCoordinator()
+	coordinator.PartitionSize = 4
+	coordinator.PollTimeout = 100 * time.Millisecond
+	server := httptest.NewServer(coordinator)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	return &testEnv{
+		coordinator: coordinator,
+		server:      server,
+		simulator:   simulator,
+		hash:        hash,
+		ctx:         ctx,
+	}
+}
+
+// startWorker runs a worker against the coordinator until the test ends
+func (e *testEnv) startWorker(t *testing.T, hash string) chan error {
+	t.Helper()
+
+	return e.startWorkerFor(t, e.simulator, hash)
+}
+
+// startWorkerFor runs a worker with its own simulator against the coordinator until the test ends
+func (e *testEnv) startWorkerFor(t *testing.T, simulator *sim.Simulator, hash string) chan error {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	t.Cleanup(cancel)
+
+	w := NewWorker(e.server.URL, simulator, hash)
+	w.Heartbeat = 50 * time.Millisecond
+	w.RetryBackoff = 10 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+	return done
+}
+
+// request builds a product request over every principal and resource of the test sources
+func (e *testEnv) request() ProductRequest {
+	principals := e.simulator.Universe.PrincipalArns()
+	slices.Sort(principals)
+	resources := e.simulator.Universe.ResourceArns()
+	slices.Sort(resources)
+
+	return ProductRequest{
+		SourcesHash: e.hash,
+		Principals:  principals,
+		Actions:     testActions,
+		Resources:   resources,
+	}
+}
+
+// want calculates the results of the request locally
+func (e *testEnv) want(t *testing.T, req ProductRequest) []string {
+	t.Helper()
+
+	opts := sim.NewOptions()
+	fps, err := e.simulator.FreezePrincipals(req.Principals, opts)
+	if err != nil {
+		t.Fatalf("unable to freeze principals: %v", err)
+	}
+	frs, err := e.simulator.FreezeResources(req.Resources, opts)
+	if err != nil {
+		t.Fatalf("unable to freeze resources: %v", err)
+	}
+
+	var want []string
+	err = e.simulator.ProductFrozenStreaming(fps, req.Actions, frs, opts, func(t sim.AccessTuple) {
+		want = append(want, resultKey(Result{
+			Principal: t.Principal,
+			Action:    t.Action,
+			Resource:  t.Resource,
+			Allowed:   t.Result.IsAllowed,
+		}))
+	})
+	if err != nil {
+		t.Fatalf("unable to simulate locally: %v", err)
+	}
+
+	slices.Sort(want)
+	return want
+}
+
+// product runs the request through the coordinator, returning its sorted results
+func (e *testEnv) product(req ProductRequest) ([]string, error) {
+	var got []string
+	err := NewClient(e.server.URL).Product(e.ctx, req, func(r Result) {
+		got = append(got, resultKey(r))
+	})
+	slices.Sort(got)
+	return got, err
+}
+
+func resultKey(r Result) string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// lease leases a partition directly, as a worker would
+func (e *testEnv) lease(t *testing.T, worker string) *Partition {
+	t.Helper()
+
+	for e.ctx.Err() == nil {
+		resp := e.post(t, worker, "/api/v1/workers/lease", `{"sourcesHash":"`+e.hash+`"}`)
+		if resp.StatusCode == http.StatusOK {
+			var part Partition
+			if err := json.ConfigDefault.NewDecoder(resp.Body).Decode(&part); err != nil {
+				t.Fatalf("unable to parse partition: %v", err)
+			}
+			return &part
+		}
+	}
+	t.Fatalf("timed out waiting for partition")
+	return nil
+}
+
+// waitPending waits for partitions to be submitted to the coordinator
+func (e *testEnv) waitPending(t *testing.T) {
+	t.Helper()
+
+	for e.ctx.Err() == nil {
+		e.coordinator.mu.Lock()
+		n := len(e.coordinator.pending)
+		e.coordinator.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for partitions")
+}
+
+func (e *testEnv) post(t *testing.T, worker, path, body string) *http.Response {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodPost, e.server.URL+path, strings.NewReader(body))
+	req.Header.Set(HeaderWorker, worker)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tests
+// -------------------------------------------------------------------------------------------------
+
+func TestLoadSources(t *testing.T) {
+	_, hash, err := LoadSources([]string{testSource})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the same content from a different, compressed location has the same hash
+	data, err := os.ReadFile(testSource)
+	if err != nil {
+		t.Fatalf("unable to read source: %v", err)
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+
+	copied := filepath.Join(t.TempDir(), "copy.jsonl.gz")
+	if err := os.WriteFile(copied, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("unable to write source: %v", err)
+	}
+	_, copiedHash, err := LoadSources([]string{copied})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if copiedHash != hash {
+		t.Fatalf("wanted hash %s for copied source, got %s", hash, copiedHash)
+	}
+
+	// different content has a different hash
+	_, orgHash, err := LoadSources([]string{"../../testdata/real-world/org.jsonl"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if orgHash == hash {
+		t.Fatalf("wanted different hashes for different sources")
+	}
+
+	if _, _, err := LoadSources([]string{"/does/not/exist.jsonl"}); err == nil {
+		t.Fatalf("expected error for missing source")
+	}
+}
+
+func TestProduct(t *testing.T) {
+	e := newTestEnv(t)
+	for range 3 {
+		e.startWorker(t, e.hash)
+	}
+
+	req := e.request()
+	got, err := e.product(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := e.want(t, req)
+	if len(want) == 0 {
+		t.Fatalf("expected test product to have results")
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("wanted %d results, got %d:\nwant: %v\ngot:  %v", len(want), len(got), want, got)
+	}
+}
+
+func TestProductRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		fail func(t *testing.T, e *testEnv, part *Partition)
+	}{
+		{
+			name: "error",
+			fail: func(t *testing.T, e *testEnv, part *Partition) {
+				e.post(t, "bad", "/api/v1/workers/partitions/"+part.ID, `{"error":"boom"}`)
+			},
+		},
+		{
+			name: "truncated",
+			fail: func(t *testing.T, e *testEnv, part *Partition) {
+				e.post(t, "bad", "/api/v1/workers/partitions/"+part.ID, `{}`)
+			},
+		},
+		{
+			name: "wrong_count",
+			fail: func(t *testing.T, e *testEnv, part *Partition) {
+				e.post(t, "bad", "/api/v1/workers/partitions/"+part.ID, `{"done":true,"count":1}`)
+			},
+		},
+		{
+			name: "expired",
+			fail: func(t *testing.T, e *testEnv, part *Partition) {
+				// the lease lapses without any report
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			e.coordinator.LeaseTimeout = 500 * time.Millisecond
+
+			req := e.request()
+			results := make(chan []string)
+			go func() {
+				got, err := e.product(req)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				results <- got
+			}()
+
+			// the first partition fails, and must be completed by the healthy worker instead
+			part := e.lease(t, "bad")
+			tc.fail(t, e, part)
+			e.startWorker(t, e.hash)
+
+			got := <-results
+			if want := e.want(t, req); !slices.Equal(got, want) {
+				t.Fatalf("wanted %d results, got %d", len(want), len(got))
+			}
+		})
+	}
+}
+
+func TestProductFailure(t *testing.T) {
+	e := newTestEnv(t)
+	e.coordinator.MaxAttempts = 2
+
+	// a single partition, so that every attempt is of the same one
+	e.coordinator.PartitionSize = 1000
+
+	errs := make(chan error)
+	go func() {
+		_, err := e.product(e.request())
+		errs <- err
+	}()
+
+	for range e.coordinator.MaxAttempts {
+		part := e.lease(t, "bad")
+		e.post(t, "bad", "/api/v1/workers/partitions/"+part.ID, `{"error":"boom"}`)
+	}
+
+	err := <-errs
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected error from failed partition, got: %v", err)
+	}
+}
+
+func TestSourcesMismatch(t *testing.T) {
+	e := newTestEnv(t)
+
+	go e.product(e.request())
+	e.waitPending(t)
+
+	// a worker whose sources match no product is told so, but keeps polling
+	resp := e.post(t, "other", "/api/v1/workers/lease", `{"sourcesHash":"different"}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("wanted status %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	done := e.startWorker(t, "different")
+	select {
+	case err := <-done:
+		t.Fatalf("expected worker to keep polling, but it stopped: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestProductsWithDifferentSources(t *testing.T) {
+	e := newTestEnv(t)
+
+	olderSimulator, olderHash, err := LoadSources(
+		[]string{"../../testdata/real-world/awsconfig.old.jsonl.gz"})
+	if err != nil {
+		t.Fatalf("unable to load sources: %v", err)
+	}
+	if olderHash == e.hash {
+		t.Fatalf("expected sources to have different hashes")
+	}
+	older := &testEnv{simulator: olderSimulator, hash: olderHash}
+
+	newerReq := e.request()
+	olderReq := older.request()
+
+	// only the worker for the newer sources is running, while both products are pending
+	newerDone := e.startWorker(t, e.hash)
+	newerResults := make(chan []string, 1)
+	go func() {
+		got, _ := e.product(newerReq)
+		newerResults <- got
+	}()
+	olderResults := make(chan []string, 1)
+	go func() {
+		got, err := e.product(olderReq)
+		if err != nil {
+			t.Errorf("unexpected error from older product: %v", err)
+		}
+		olderResults <- got
+	}()
+
+	if got, want := <-newerResults, e.want(t, newerReq); !slices.Equal(got, want) {
+		t.Fatalf("wanted %d results for newer product, got %d", len(want), len(got))
+	}
+
+	// with only the older product's partitions pending, a newer worker waits rather than stopping
+	resp := e.post(t, "newer", "/api/v1/workers/lease", `{"sourcesHash":"`+e.hash+`"}`)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("wanted status %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+
+	e.startWorkerFor(t, olderSimulator, olderHash)
+	if got, want := <-olderResults, older.want(t, olderReq); !slices.Equal(got, want) {
+		t.Fatalf("wanted %d results for older product, got %d", len(want), len(got))
+	}
+
+	select {
+	case err := <-newerDone:
+		t.Fatalf("expected newer worker to keep running, but it stopped: %v", err)
+	default:
+	}
+}
+
+func TestReportUnleased(t *testing.T) {
+	e := newTestEnv(t)
+
+	resp := e.post(t, "bad", "/api/v1/workers/partitions/missing", `{"done":true}`)
+	if resp.StatusCode != http.StatusGone {
+		t.Fatalf("wanted status %d, got %d", http.StatusGone, resp.StatusCode)
+	}
+}
+
+func TestProductInvalid(t *testing.T) {
+	e := newTestEnv(t)
+
+	bodies := []string{
+		`{`,
+		`{"principals":["a"],"actions":["s3:GetObject"]}`,
+		`{"sourcesHash":"x"}`,
+	}
+	for _, body := range bodies {
+		resp := e.post(t, "", "/api/v1/product", body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("wanted status %d for %s, got %d",
+				http.StatusBadRequest, body, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// Defaults for the behavior of a Worker
+const (
+	DefaultHeartbeat    = 10 * time.Second
+	DefaultRetryBackoff = 5 * time.Second
+)
+
+// ErrSourcesMismatch is returned when the coordinator has work, but no product submitted to it has
+// the same sources as the worker
+var ErrSourcesMismatch = errors.New("worker sources do not match those of any product")
+
+// Worker leases partitions from a coordinator, simulates them, and streams back the results
+type Worker struct {
+	// ID identifies the worker to the coordinator
+	ID string
+
+	// Simulator holds the sources from which partitions are simulated, whose content hash is
+	// SourcesHash
+	Simulator   *sim.Simulator
+	SourcesHash string
+
+	// Heartbeat is the interval at which heartbeats are sent while simulating, which must be less
+	// than the coordinator's lease timeout
+	Heartbeat time.Duration
+
+	// RetryBackoff is how long to wait after failing to reach the coordinator
+	RetryBackoff time.Duration
+
+	addr string
+	http *http.Client
+}
+
+// NewWorker creates a Worker which leases partitions from the coordinator at the provided address.
+// Addresses without a scheme are assumed to be plain HTTP
+func NewWorker(addr string, simulator *sim.Simulator, sourcesHash string) *Worker {
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+
+	hostname, _ := os.Hostname()
+	return &Worker{
+		ID:           fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), rand.Text()[:6]),
+		Simulator:    simulator,
+		SourcesHash:  sourcesHash,
+		Heartbeat:    DefaultHeartbeat,
+		RetryBackoff: DefaultRetryBackoff,
+		addr:         strings.TrimSuffix(addr, "/"),
+		http:         http.DefaultClient,
+	}
+}
+
+// Run leases and simulates partitions until the context is cancelled. Errors reaching the
+// coordinator are retried. Since the worker's audit may simply not have been submitted yet,
+// mismatched sources are logged and the worker keeps polling
+func (w *Worker) Run(ctx context.Context) error {
+	slog.Info("worker started", "worker", w.ID, "coordinator", w.addr, "sources", w.SourcesHash)
+
+	mismatched := false
+	for ctx.Err() == nil {
+		part, err := w.lease(ctx)
+		if errors.Is(err, ErrSourcesMismatch) != mismatched {
+			mismatched = !mismatched
+			if mismatched {
+				slog.Error("no product matches the sources of this worker; waiting for one",
+					"sources", w.SourcesHash)
+			}
+		}
+
+		switch {
+		case errors.Is(err, ErrSourcesMismatch):
+			w.sleep(ctx, w.RetryBackoff)
+			continue
+		case err != nil && ctx.Err() == nil:
+			slog.Warn("unable to lease partition; retrying",
+				"error", err,
+				"backoff", w.RetryBackoff)
+			w.sleep(ctx, w.RetryBackoff)
+			continue
+		case part == nil:
+			continue
+		}
+
+		if err := w.process(ctx, part); err != nil && ctx.Err() == nil {
+			slog.Warn("unable to report partition", "partition", part.ID, "error", err)
+		}
+	}
+	return ctx.Err()
+}
+
+// lease requests a partition, returning nil if none became available
+func (w *Worker) lease(ctx context.Context) (*Partition, error) {
+	body, err := json.Marshal(LeaseRequest{SourcesHash: w.SourcesHash})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := w.post(ctx, "/api/v1/workers/lease", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var part Partition
+		if err := json.ConfigDefault.NewDecoder(resp.Body).Decode(&part); err != nil {
+			return nil, fmt.Errorf("unable to parse partition: %w", err)
+		}
+		return &part, nil
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusConflict:
+		return nil, ErrSourcesMismatch
+	default:
+		return nil, responseError(resp)
+	}
+}
+
+// process simulates a partition, streaming its results to the coordinator as they are produced
+func (w *Worker) process(ctx context.Context, part *Partition) error {
+	slog.Info("simulating partition",
+		"partition", part.ID,
+		"attempt", part.Attempt,
+		"principals", len(part.Principals))
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(w.simulate(part, pw))
+	}()
+
+	resp, err := w.post(ctx, "/api/v1/workers/partitions/"+part.ID, pr)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// simulate writes the messages of a partition: its results, heartbeats while simulating, and a
+// final done or error message
+func (w *Worker) simulate(part *Partition, out io.Writer) error {
+	var mu sync.Mutex
+	enc := json.ConfigDefault.NewEncoder(out)
+	send := func(msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(msg)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(w.Heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				send(Message{})
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	count, err := w.product(part, func(r Result) error {
+		return send(Message{Result: &r})
+	})
+	if err != nil {
+		slog.Warn("partition failed", "partition", part.ID, "error", err)
+		return send(Message{Error: err.Error()})
+	}
+
+	slog.Info("partition complete", "partition", part.ID, "results", count)
+	return send(Message{Done: true, Count: count})
+}
+
+// product simulates the partition, passing each result to the provided function
+func (w *Worker) product(part *Partition, onResult func(Result) error) (int, error) {
+	opts := sim.NewOptions(sim.WithAdditionalProperties(part.Context))
+
+	principals, err := w.Simulator.FreezePrincipals(part.Principals, opts)
+	if err != nil {
+		return 0, err
+	}
+	resources, err := w.Simulator.FreezeResources(part.Resources, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	product := w.Simulator.ProductFrozenStreaming
+	if part.Denied {
+		product = w.Simulator.ProductFrozenStreamingAll
+	}
+
+	var count int
+	var sendErr error
+	err = product(principals, part.Actions, resources, opts, func(t sim.AccessTuple) {
+		if sendErr != nil {
+			return
+		}
+		sendErr = onResult(Result{
+			Principal: t.Principal,
+			Action:    t.Action,
+			Resource:  t.Resource,
+			Allowed:   t.Result.IsAllowed,
+		})
+		count++
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, sendErr
+}
+
+func (w *Worker) post(ctx context.Context, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.addr+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(HeaderWorker, w.ID)
+	req.Header.Set("Content-Type", "application/json")
+	return w.http.Do(req)
+}
+
+// sleep waits for the provided duration, or until the context is cancelled
+func (w *Worker) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// responseError describes an unexpected response from the coordinator
+func responseError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(b, &body) == nil && body.Error != "" {
+		return fmt.Errorf("coordinator returned status %d: %s", resp.StatusCode, body.Error)
+	}
+	return fmt.Errorf("coordinator returned status %d", resp.StatusCode)
+}
@@ -22,35 +22,43 @@ func SplitArn(arn string) []string {
 // TODO(nsiow) update these to not be a method and instead take a list of uvs
 // -------------------------------------------------------------------------------------------------
 
-func (u *Universe) FrozenPrincipals(strict bool, overlay *Universe) ([]FrozenPrincipal, error) {
+func (u *Universe) FrozenPrincipals(strict bool, overlays ...*Universe) ([]FrozenPrincipal, error) {
 	var fs []FrozenPrincipal
 
-	uvs := u.Overlay(overlay)
-	for _, uv := range uvs {
-		for p := range uv.Principals() {
-			f, err := p.FreezeWith(strict, uvs...)
-			if err != nil {
-				return nil, err
-			}
-			fs = append(fs, f)
+	// entities defined in multiple layers are only frozen from the highest-precedence one, and
+	// tombstoned entities are skipped entirely
+	uvs := u.Overlay(overlays...)
+	for _, arn := range VisiblePrincipalArns(uvs) {
+		p, _, err := LookupPrincipal(uvs, arn)
+		if err != nil {
+			return nil, err
+		}
+		f, err := p.FreezeWith(strict, uvs...)
+		if err != nil {
+			return nil, err
 		}
+		fs = append(fs, f)
 	}
 
 	return fs, nil
 }
 
-func (u *Universe) FrozenResources(strict bool, overlay *Universe) ([]FrozenResource, error) {
+func (u *Universe) FrozenResources(strict bool, overlays ...*Universe) ([]FrozenResource, error) {
 	var fs []FrozenResource
 
-	uvs := u.Overlay(overlay)
-	for _, uv := range uvs {
-		for r := range uv.Resources() {
-			f, err := r.FreezeWith(strict, uvs...)
-			if err != nil {
-				return nil, err
-			}
-			fs = append(fs, f)
+	// entities defined in multiple layers are only frozen from the highest-precedence one, and
+	// tombstoned entities are skipped entirely
+	uvs := u.Overlay(overlays...)
+	for _, arn := range VisibleResourceArns(uvs) {
+		r, _, err := LookupResource(uvs, arn)
+		if err != nil {
+			return nil, err
+		}
+		f, err := r.FreezeWith(strict, uvs...)
+		if err != nil {
+			return nil, err
 		}
+		fs = append(fs, f)
 	}
 
 	return fs, nil
@@ -117,19 +125,23 @@ func (n *OrgNode) FreezeWith(strict bool, uvs ...*Universe) (FrozenOrgNode, erro
 	}
 
 	for _, ref := range n.SCPs {
-		policies, err := freezePolicy(ref.Arn, strict, uvs...)
+		policies, ok, err := freezePolicy(ref.Arn, strict, uvs...)
 		if err != nil {
 			return FrozenOrgNode{}, err
 		}
-		frozen.SCPs = append(frozen.SCPs, policies)
+		if ok {
+			frozen.SCPs = append(frozen.SCPs, policies)
+		}
 	}
 
 	for _, ref := range n.RCPs {
-		policies, err := freezePolicy(ref.Arn, strict, uvs...)
+		policies, ok, err := freezePolicy(ref.Arn, strict, uvs...)
 		if err != nil {
 			return FrozenOrgNode{}, err
 		}
-		frozen.RCPs = append(frozen.RCPs, policies)
+		if ok {
+			frozen.RCPs = append(frozen.RCPs, policies)
+		}
 	}
 
 	return frozen, nil
@@ -212,14 +224,14 @@ func (p *Principal) FreezeWith(strict bool, uvs ...*Universe) (FrozenPrincipal,
 		InlinePolicies: p.InlinePolicies,
 	}
 
-	var err error
-
-	for _, uv := range uvs {
-		if account, ok := uv.Account(f.AccountId); ok {
-			f.Account, err = account.FreezeWith(strict, uvs...)
-			if err != nil {
-				return FrozenPrincipal{}, err
-			}
+	account, ok, err := LookupAccount(uvs, f.AccountId)
+	if err != nil {
+		return FrozenPrincipal{}, err
+	}
+	if ok {
+		f.Account, err = account.FreezeWith(strict, uvs...)
+		if err != nil {
+			return FrozenPrincipal{}, err
 		}
 	}
 
@@ -238,7 +250,8 @@ func (p *Principal) FreezeWith(strict bool, uvs ...*Universe) (FrozenPrincipal,
 	}
 
 	if len(p.PermissionsBoundary) > 0 {
-		f.PermissionBoundary, err = freezePolicy(p.PermissionsBoundary, strict, uvs...)
+		// a tombstoned boundary is left unset, as if the principal had none
+		f.PermissionBoundary, _, err = freezePolicy(p.PermissionsBoundary, strict, uvs...)
 		if err != nil {
 			return FrozenPrincipal{}, err
 		}
@@ -285,14 +298,14 @@ func (r *Resource) FreezeWith(strict bool, uvs ...*Universe) (FrozenResource, er
 		Policy:      r.Policy,
 	}
 
-	var err error
-
-	for _, uv := range uvs {
-		if account, ok := uv.Account(f.AccountId); ok {
-			f.Account, err = account.FreezeWith(strict, uvs...)
-			if err != nil {
-				return FrozenResource{}, err
-			}
+	account, ok, err := LookupAccount(uvs, f.AccountId)
+	if err != nil {
+		return FrozenResource{}, err
+	}
+	if ok {
+		f.Account, err = account.FreezeWith(strict, uvs...)
+		if err != nil {
+			return FrozenResource{}, err
 		}
 	}
 
@@ -320,65 +333,85 @@ func makeEmptyGroup(arn Arn) FrozenGroup {
 	}
 }
 
-func freezePolicy(arn Arn, strict bool, uvs ...*Universe) (ManagedPolicy, error) {
-	for _, uv := range uvs {
-		pol, ok := uv.Policy(arn)
-		if ok {
-			return *pol, nil
-		}
+// freezePolicy looks up the policy with the provided ARN, returning false if it has been
+// tombstoned. Tombstoned policies are deleted, so they are left out rather than treated as missing
+func freezePolicy(arn Arn, strict bool, uvs ...*Universe) (ManagedPolicy, bool, error) {
+	pol, deleted, err := find(uvs, func(uv *Universe) (*ManagedPolicy, bool) {
+		return uv.Policy(arn)
+	}, arn)
+	if err != nil {
+		return ManagedPolicy{}, false, err
+	}
+	if pol != nil {
+		return *pol, true, nil
+	}
+	if deleted {
+		return ManagedPolicy{}, false, nil
 	}
 
 	if strict {
-		return ManagedPolicy{}, fmt.Errorf("cannot find policy with arn: %s", arn)
+		return ManagedPolicy{}, false, fmt.Errorf("cannot find policy with arn: %s", arn)
 	} else {
-		return makeEmptyPolicy(arn), nil
+		return makeEmptyPolicy(arn), true, nil
 	}
 }
 
 func freezePolicies(arns []Arn, strict bool, uvs ...*Universe) ([]ManagedPolicy, error) {
-	policies := make([]ManagedPolicy, len(arns))
+	policies := make([]ManagedPolicy, 0, len(arns))
 
-	for i, arn := range arns {
-		pol, err := freezePolicy(arn, strict, uvs...)
+	for _, arn := range arns {
+		pol, ok, err := freezePolicy(arn, strict, uvs...)
 		if err != nil {
 			return nil, err
 		}
 
-		policies[i] = pol
+		if ok {
+			policies = append(policies, pol)
+		}
 	}
 
 	return policies, nil
 }
 
-func freezeGroupByArn(arn Arn, strict bool, uvs ...*Universe) (FrozenGroup, error) {
-	for _, uv := range uvs {
-		grp, ok := uv.Group(arn)
-		if ok {
-			frozen, err := grp.FreezeWith(strict, uvs...)
-			if err != nil {
-				return FrozenGroup{}, err
-			}
-			return frozen, nil
+// freezeGroupByArn looks up and freezes the group with the provided ARN, returning false if it has
+// been tombstoned
+func freezeGroupByArn(arn Arn, strict bool, uvs ...*Universe) (FrozenGroup, bool, error) {
+	grp, deleted, err := find(uvs, func(uv *Universe) (*Group, bool) {
+		return uv.Group(arn)
+	}, arn)
+	if err != nil {
+		return FrozenGroup{}, false, err
+	}
+	if grp != nil {
+		frozen, err := grp.FreezeWith(strict, uvs...)
+		if err != nil {
+			return FrozenGroup{}, false, err
 		}
+		return frozen, true, nil
+	}
+	if deleted {
+		return FrozenGroup{}, false, nil
 	}
 
 	if strict {
-		return FrozenGroup{}, fmt.Errorf("cannot find group with arn: %s", arn)
+		return FrozenGroup{}, false, fmt.Errorf("cannot find group with arn: %s", arn)
 	} else {
-		return makeEmptyGroup(arn), nil
+		return makeEmptyGroup(arn), true, nil
 	}
 }
 
 func freezeGroupsByArn(arns []Arn, strict bool, uvs ...*Universe) ([]FrozenGroup, error) {
-	groups := make([]FrozenGroup, len(arns))
+	groups := make([]FrozenGroup, 0, len(arns))
 
-	for i, arn := range arns {
-		grp, err := freezeGroupByArn(arn, strict, uvs...)
+	for _, arn := range arns {
+		grp, ok, err := freezeGroupByArn(arn, strict, uvs...)
 		if err != nil {
 			return nil, err
 		}
 
-		groups[i] = grp
+		if ok {
+			groups = append(groups, grp)
+		}
 	}
 
 	return groups, nil
@@ -485,3 +485,95 @@ func TestFreeze_NonStrictResources(t *testing.T) {
 		t.Fatalf("expected 1 frozen resource, got %d", len(frs))
 	}
 }
+
+func TestFreeze_OverlayLayers(t *testing.T) {
+	arn := "arn:aws:iam::88888:role/layered"
+	layer := func(tag string) *Universe {
+		return NewBuilder().
+			WithPrincipals(Principal{Arn: arn, Tags: []Tag{{Key: "layer", Value: tag}}}).
+			WithResources(Resource{Arn: "arn:aws:s3:::" + tag}).
+			Build()
+	}
+
+	base := layer("base")
+	fps, err := base.FrozenPrincipals(false, layer("first"), layer("second"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the principal is defined in every layer, but should only be frozen from the last one
+	if len(fps) != 1 {
+		t.Fatalf("expected 1 frozen principal, got %d", len(fps))
+	}
+	if got := fps[0].Tags[0].Value; got != "second" {
+		t.Fatalf("expected principal from layer 'second', got '%s'", got)
+	}
+
+	// resources are distinct across layers, so all of them should be frozen
+	frs, err := base.FrozenResources(false, layer("first"), layer("second"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(frs) != 3 {
+		t.Fatalf("expected 3 frozen resources, got %d", len(frs))
+	}
+}
+
+func TestFreeze_Tombstones(t *testing.T) {
+	base := NewBuilder().
+		WithPolicies(ManagedPolicy{
+			Arn:    "arn:aws:iam::88888:policy/legacy",
+			Policy: policy.Policy{Statement: []policy.Statement{{Effect: policy.EFFECT_ALLOW}}},
+		}).
+		WithGroups(Group{
+			Arn:              "arn:aws:iam::88888:group/admins",
+			AttachedPolicies: []Arn{"arn:aws:iam::88888:policy/legacy"},
+		}).
+		WithPrincipals(Principal{
+			Arn:              "arn:aws:iam::88888:role/app",
+			AttachedPolicies: []Arn{"arn:aws:iam::88888:policy/legacy"},
+			Groups:           []Arn{"arn:aws:iam::88888:group/admins"},
+		}).
+		Build()
+
+	overlay := NewBuilder().
+		WithTombstones("arn:aws:iam::88888:policy/legacy", "arn:aws:iam::88888:group/admins").
+		Build()
+
+	// tombstoned references are deleted, so they are left out rather than treated as missing, in
+	// both strict and non-strict mode
+	for _, strict := range []bool{false, true} {
+		fps, err := base.FrozenPrincipals(strict, overlay)
+		if err != nil {
+			t.Fatalf("unexpected error (strict = %v): %v", strict, err)
+		}
+		if len(fps) != 1 {
+			t.Fatalf("expected 1 frozen principal, got %d", len(fps))
+		}
+		if len(fps[0].AttachedPolicies) != 0 {
+			t.Errorf("expected tombstoned policy to be left out, got %v", fps[0].AttachedPolicies)
+		}
+		if len(fps[0].Groups) != 0 {
+			t.Errorf("expected tombstoned group to be left out, got %v", fps[0].Groups)
+		}
+	}
+
+	// strict freezing still fails on references which are missing rather than tombstoned
+	dangling := NewBuilder().
+		WithPrincipals(Principal{
+			Arn:              "arn:aws:iam::88888:role/other",
+			AttachedPolicies: []Arn{"arn:aws:iam::88888:policy/missing"},
+		}).
+		Build()
+	_, err := base.FrozenPrincipals(true, overlay, dangling)
+	if err == nil {
+		t.Fatalf("expected strict freeze to fail for missing policy")
+	}
+
+	// tombstoned principals are not frozen at all
+	deleted := NewBuilder().WithTombstones("arn:aws:iam::88888:role/app").Build()
+	fps, err := base.FrozenPrincipals(false, deleted)
+	if err != nil || len(fps) != 0 {
+		t.Fatalf("expected no frozen principals, got %v (err = %v)", fps, err)
+	}
+}
@@ -20,10 +20,47 @@ type Overlay struct {
 	// CreatedAt is the timestamp when the overlay was created
 	CreatedAt time.Time
 
+	// Revision is the revision number of the overlay's current contents, assigned by the Store.
+	// Zero means that the overlay has not been stored
+	Revision int
+
+	// UpdatedAt is the timestamp when the current revision was stored
+	UpdatedAt time.Time
+
+	// Author identifies who made the current revision, if provided
+	Author string
+
+	// Message describes the change made by the current revision, if provided
+	Message string
+
+	// ExpiresAt is the time after which the overlay is deleted from its Store. Zero means that the
+	// overlay never expires
+	ExpiresAt time.Time
+
 	// Universe contains the overlay's entity collections
 	Universe *Universe
 }
 
+// OverlayRevision describes a single revision in the history of a stored overlay
+type OverlayRevision struct {
+	Revision  int       `json:"revision"`
+	Name      string    `json:"name"`
+	Author    string    `json:"author,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RevisionInfo returns the metadata of the overlay's current revision
+func (o *Overlay) RevisionInfo() OverlayRevision {
+	return OverlayRevision{
+		Revision:  o.Revision,
+		Name:      o.Name,
+		Author:    o.Author,
+		Message:   o.Message,
+		CreatedAt: o.UpdatedAt,
+	}
+}
+
 // NewOverlay creates a new overlay with the given name. The ID is automatically
 // generated from the sha1 hash of the name.
 func NewOverlay(name string) *Overlay {
@@ -53,6 +90,11 @@ func GenerateOverlayID(name string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// Expired returns true if the overlay has an expiry which is at or before `now`
+func (o *Overlay) Expired(now time.Time) bool {
+	return !o.ExpiresAt.IsZero() && !o.ExpiresAt.After(now)
+}
+
 // Size returns the total number of entities in the overlay
 func (o *Overlay) Size() int {
 	if o.Universe == nil {
@@ -61,9 +103,9 @@ func (o *Overlay) Size() int {
 	return o.Universe.Size()
 }
 
-// IsEmpty returns true if the overlay contains no entities
+// IsEmpty returns true if the overlay contains no entities, tombstones or patches
 func (o *Overlay) IsEmpty() bool {
-	return o.Size() == 0
+	return o.Size() == 0 && o.NumTombstones() == 0 && o.NumPatches() == 0
 }
 
 // NumPrincipals returns the number of principals in the overlay
@@ -106,17 +148,40 @@ func (o *Overlay) NumGroups() int {
 	return o.Universe.NumGroups()
 }
 
+// NumTombstones returns the number of tombstones (deleted entities) in the overlay
+func (o *Overlay) NumTombstones() int {
+	if o.Universe == nil {
+		return 0
+	}
+	return o.Universe.NumTombstones()
+}
+
+// NumPatches returns the number of patches (partial entity modifications) in the overlay
+func (o *Overlay) NumPatches() int {
+	if o.Universe == nil {
+		return 0
+	}
+	return o.Universe.NumPatches()
+}
+
 // OverlayData is a JSON-serializable representation of an Overlay.
 // It contains all entity data in a flat, exportable format.
 type OverlayData struct {
 	Name       string          `json:"name"`
 	ID         string          `json:"id"`
 	CreatedAt  time.Time       `json:"createdAt"`
+	Revision   int             `json:"revision,omitempty"`
+	UpdatedAt  time.Time       `json:"updatedAt,omitzero"`
+	Author     string          `json:"author,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	ExpiresAt  time.Time       `json:"expiresAt,omitzero"`
 	Accounts   []Account       `json:"accounts,omitempty"`
 	Groups     []Group         `json:"groups,omitempty"`
 	Policies   []ManagedPolicy `json:"policies,omitempty"`
 	Principals []Principal     `json:"principals,omitempty"`
 	Resources  []Resource      `json:"resources,omitempty"`
+	Tombstones []Arn           `json:"tombstones,omitempty"`
+	Patches    []Patch         `json:"patches,omitempty"`
 }
 
 // ToData converts an Overlay to its serializable representation.
@@ -125,6 +190,11 @@ func (o *Overlay) ToData() OverlayData {
 		Name:      o.Name,
 		ID:        o.ID,
 		CreatedAt: o.CreatedAt,
+		Revision:  o.Revision,
+		UpdatedAt: o.UpdatedAt,
+		Author:    o.Author,
+		Message:   o.Message,
+		ExpiresAt: o.ExpiresAt,
 	}
 
 	if o.Universe == nil {
@@ -156,6 +226,14 @@ func (o *Overlay) ToData() OverlayData {
 		data.Resources = append(data.Resources, *r)
 	}
 
+	// Collect tombstones
+	if tombstones := o.Universe.Tombstones(); len(tombstones) > 0 {
+		data.Tombstones = tombstones
+	}
+
+	// Collect patches
+	data.Patches = o.Universe.Patches()
+
 	return data
 }
 
@@ -165,6 +243,11 @@ func FromData(data OverlayData) *Overlay {
 		Name:      data.Name,
 		ID:        data.ID,
 		CreatedAt: data.CreatedAt,
+		Revision:  data.Revision,
+		UpdatedAt: data.UpdatedAt,
+		Author:    data.Author,
+		Message:   data.Message,
+		ExpiresAt: data.ExpiresAt,
 		Universe:  NewUniverse(),
 	}
 
@@ -193,6 +276,16 @@ func FromData(data OverlayData) *Overlay {
 		o.Universe.PutResource(r)
 	}
 
+	// Load tombstones
+	for _, arn := range data.Tombstones {
+		o.Universe.PutTombstone(arn)
+	}
+
+	// Load patches
+	for _, p := range data.Patches {
+		o.Universe.PutPatch(p)
+	}
+
 	return o
 }
 
@@ -201,11 +294,16 @@ type OverlaySummary struct {
 	Name          string    `json:"name"`
 	ID            string    `json:"id"`
 	CreatedAt     time.Time `json:"createdAt"`
+	Revision      int       `json:"revision,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt,omitzero"`
+	ExpiresAt     time.Time `json:"expiresAt,omitzero"`
 	NumPrincipals int       `json:"numPrincipals"`
 	NumResources  int       `json:"numResources"`
 	NumPolicies   int       `json:"numPolicies"`
 	NumAccounts   int       `json:"numAccounts"`
 	NumGroups     int       `json:"numGroups"`
+	NumTombstones int       `json:"numTombstones"`
+	NumPatches    int       `json:"numPatches"`
 }
 
 // Summary returns a lightweight summary of the overlay.
@@ -214,11 +312,16 @@ func (o *Overlay) Summary() OverlaySummary {
 		Name:          o.Name,
 		ID:            o.ID,
 		CreatedAt:     o.CreatedAt,
+		Revision:      o.Revision,
+		UpdatedAt:     o.UpdatedAt,
+		ExpiresAt:     o.ExpiresAt,
 		NumPrincipals: o.NumPrincipals(),
 		NumResources:  o.NumResources(),
 		NumPolicies:   o.NumPolicies(),
 		NumAccounts:   o.NumAccounts(),
 		NumGroups:     o.NumGroups(),
+		NumTombstones: o.NumTombstones(),
+		NumPatches:    o.NumPatches(),
 	}
 }
 
@@ -243,6 +346,12 @@ func (o *Overlay) Clone(newName string) *Overlay {
 		for r := range o.Universe.Resources() {
 			clone.Universe.PutResource(*r)
 		}
+		for _, arn := range o.Universe.Tombstones() {
+			clone.Universe.PutTombstone(arn)
+		}
+		for _, p := range o.Universe.Patches() {
+			clone.Universe.PutPatch(p)
+		}
 	}
 
 	return clone
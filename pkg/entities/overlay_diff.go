@@ -0,0 +1,196 @@
+package entities
+
+import (
+	"bytes"
+	"cmp"
+	"maps"
+	"slices"
+
+	json "github.com/bytedance/sonic"
+)
+
+// Kinds of changes reported by [DiffOverlays]
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// OverlayChange describes a single entity, tombstone or set of patches which differs between two
+// versions of an overlay
+type OverlayChange struct {
+	// Kind is the type of the changed item: account, group, policy, principal, resource, tombstone or
+	// patch
+	Kind string `json:"kind"`
+
+	// Key identifies the changed item: an account ID, or an ARN for all other kinds
+	Key string `json:"key"`
+
+	// Change is one of "added", "removed" or "modified"
+	Change string `json:"change"`
+
+	// Before is the item in the older version, if present
+	Before any `json:"before,omitempty"`
+
+	// After is the item in the newer version, if present
+	After any `json:"after,omitempty"`
+}
+
+// OverlayDiff describes the differences between two versions of an overlay
+type OverlayDiff struct {
+	FromRevision int             `json:"fromRevision"`
+	ToRevision   int             `json:"toRevision"`
+	Renamed      bool            `json:"renamed,omitempty"`
+	Changes      []OverlayChange `json:"changes"`
+}
+
+// DiffOverlays compares two versions of an overlay, reporting every item which was added, removed
+// or modified in `to` relative to `from`. Changes are ordered by kind and then by key
+func DiffOverlays(from, to OverlayData) OverlayDiff {
+	diff := OverlayDiff{
+		FromRevision: from.Revision,
+		ToRevision:   to.Revision,
+		Renamed:      from.Name != to.Name,
+		Changes:      []OverlayChange{},
+	}
+
+	add := func(changes []OverlayChange) {
+		diff.Changes = append(diff.Changes, changes...)
+	}
+	add(diffItems("account", from.Accounts, to.Accounts, func(a Account) string { return a.Id }))
+	add(diffItems("group", from.Groups, to.Groups, func(g Group) string { return g.Arn }))
+	add(diffItems("policy", from.Policies, to.Policies, func(p ManagedPolicy) string { return p.Arn }))
+	add(diffItems("principal", from.Principals, to.Principals,
+		func(p Principal) string { return p.Arn }))
+	add(diffItems("resource", from.Resources, to.Resources, func(r Resource) string { return r.Arn }))
+	add(diffItems("tombstone", from.Tombstones, to.Tombstones, func(a Arn) string { return a }))
+	add(diffItems("patch", groupPatches(from.Patches), groupPatches(to.Patches),
+		func(ps []Patch) string { return ps[0].Arn }))
+
+	return diff
+}
+
+// diffItems compares two collections of items of the same kind, keyed by `key`. Items are compared
+// by their JSON representation
+func diffItems[T any](kind string, from, to []T, key func(T) string) []OverlayChange {
+	before := make(map[string]T, len(from))
+	for _, item := range from {
+		before[key(item)] = item
+	}
+	after := make(map[string]T, len(to))
+	for _, item := range to {
+		after[key(item)] = item
+	}
+
+	var changes []OverlayChange
+	for k, b := range before {
+		a, ok := after[k]
+		switch {
+		case !ok:
+			changes = append(changes, OverlayChange{Kind: kind, Key: k, Change: ChangeRemoved, Before: b})
+		case !jsonEqual(a, b):
+			changes = append(changes,
+				OverlayChange{Kind: kind, Key: k, Change: ChangeModified, Before: b, After: a})
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			changes = append(changes, OverlayChange{Kind: kind, Key: k, Change: ChangeAdded, After: a})
+		}
+	}
+
+	slices.SortFunc(changes, func(x, y OverlayChange) int { return cmp.Compare(x.Key, y.Key) })
+	return changes
+}
+
+// groupPatches groups patches by the entity they apply to, preserving their order
+func groupPatches(patches []Patch) [][]Patch {
+	grouped := make(map[string][]Patch)
+	for _, p := range patches {
+		grouped[p.Arn] = append(grouped[p.Arn], p)
+	}
+	return slices.Collect(maps.Values(grouped))
+}
+
+// jsonEqual compares two values by their JSON representation, with map keys sorted so that the
+// comparison is deterministic
+func jsonEqual(a, b any) bool {
+	x, errX := json.ConfigStd.Marshal(a)
+	y, errY := json.ConfigStd.Marshal(b)
+	return errX == nil && errY == nil && bytes.Equal(x, y)
+}
+
+// DiffUniverses returns an overlay universe containing the entities which were added or modified
+// in `next` relative to `base`, plus tombstones for those which were removed, such that layering it
+// on top of `base` reproduces `next`. Tombstones and patches within the universes themselves are
+// not compared
+//
+// Tombstones hide every kind of entity sharing a key, so when an entity is removed while another
+// kind with the same key (such as the principal of a role whose resource was removed) remains in
+// `next`, the survivors are redefined alongside the tombstone, which takes precedence over it
+func DiffUniverses(base, next *Universe) *Universe {
+	from := (&Overlay{Universe: base}).ToData()
+	to := (&Overlay{Universe: next}).ToData()
+
+	diff := NewUniverse()
+	removed := make(map[string]bool)
+	for _, change := range DiffOverlays(from, to).Changes {
+		if change.Change == ChangeRemoved {
+			if change.Kind != "tombstone" && change.Kind != "patch" {
+				diff.PutTombstone(change.Key)
+				removed[change.Key] = true
+			}
+			continue
+		}
+
+		switch entity := change.After.(type) {
+		case Account:
+			diff.PutAccount(entity)
+		case Group:
+			diff.PutGroup(entity)
+		case ManagedPolicy:
+			diff.PutPolicy(entity)
+		case Principal:
+			diff.PutPrincipal(entity)
+		case Resource:
+			diff.PutResource(entity)
+		}
+	}
+
+	putSurvivors(diff, to, removed)
+	return diff
+}
+
+// putSurvivors redefines the entities of `data` whose key was tombstoned in `diff`, so that the
+// tombstone only hides the kinds which were actually removed
+func putSurvivors(diff *Universe, data OverlayData, removed map[string]bool) {
+	if len(removed) == 0 {
+		return
+	}
+
+	for _, a := range data.Accounts {
+		if removed[a.Id] {
+			diff.PutAccount(a)
+		}
+	}
+	for _, g := range data.Groups {
+		if removed[g.Arn] {
+			diff.PutGroup(g)
+		}
+	}
+	for _, p := range data.Policies {
+		if removed[p.Arn] {
+			diff.PutPolicy(p)
+		}
+	}
+	for _, p := range data.Principals {
+		if removed[p.Arn] {
+			diff.PutPrincipal(p)
+		}
+	}
+	for _, r := range data.Resources {
+		if removed[r.Arn] {
+			diff.PutResource(r)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package entities
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestDiffOverlays(t *testing.T) {
+	from := NewBuilder().
+		WithAccounts(Account{Id: "111111111111", Name: "old"}).
+		WithPrincipals(
+			Principal{Arn: "arn:aws:iam::111111111111:role/kept"},
+			Principal{Arn: "arn:aws:iam::111111111111:role/removed"},
+		).
+		WithTombstones("arn:aws:s3:::deleted").
+		WithPatches(Patch{Arn: "arn:aws:s3:::patched", Merge: map[string]any{"Type": "a", "x": 1}}).
+		Build()
+	to := NewBuilder().
+		WithAccounts(Account{Id: "111111111111", Name: "new"}).
+		WithPrincipals(
+			Principal{Arn: "arn:aws:iam::111111111111:role/kept"},
+			Principal{Arn: "arn:aws:iam::111111111111:role/added"},
+		).
+		WithTombstones("arn:aws:s3:::deleted").
+		WithPatches(Patch{Arn: "arn:aws:s3:::patched", Merge: map[string]any{"x": 1, "Type": "b"}}).
+		Build()
+
+	diff := DiffOverlays(
+		OverlayData{Name: "o", Revision: 1, Accounts: []Account{{Id: "111111111111", Name: "old"}}},
+		OverlayData{Name: "o", Revision: 1, Accounts: []Account{{Id: "111111111111", Name: "old"}}},
+	)
+	if len(diff.Changes) != 0 || diff.Renamed {
+		t.Fatalf("expected no changes between identical overlays, got %v", diff)
+	}
+
+	fromData := (&Overlay{Name: "o", Revision: 1, Universe: from}).ToData()
+	toData := (&Overlay{Name: "renamed", Revision: 2, Universe: to}).ToData()
+	diff = DiffOverlays(fromData, toData)
+
+	if diff.FromRevision != 1 || diff.ToRevision != 2 || !diff.Renamed {
+		t.Errorf("unexpected diff metadata: %+v", diff)
+	}
+
+	type change struct{ kind, key, change string }
+	var got []change
+	for _, c := range diff.Changes {
+		got = append(got, change{c.Kind, c.Key, c.Change})
+	}
+	want := []change{
+		{"account", "111111111111", ChangeModified},
+		{"principal", "arn:aws:iam::111111111111:role/added", ChangeAdded},
+		{"principal", "arn:aws:iam::111111111111:role/removed", ChangeRemoved},
+		{"patch", "arn:aws:s3:::patched", ChangeModified},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes:\n got: %v\nwant: %v", got, want)
+	}
+
+	modified := diff.Changes[0]
+	if modified.Before.(Account).Name != "old" || modified.After.(Account).Name != "new" {
+		t.Errorf("unexpected before/after for modified account: %+v", modified)
+	}
+	if diff.Changes[1].Before != nil || diff.Changes[2].After != nil {
+		t.Errorf("expected added and removed items to only have one side")
+	}
+}
+
+func TestDiffUniverses(t *testing.T) {
+	base := NewBuilder().
+		WithAccounts(Account{Id: "111111111111", Name: "old"}, Account{Id: "222222222222"}).
+		WithPolicies(ManagedPolicy{Arn: "arn:aws:iam::111111111111:policy/kept"}).
+		WithPrincipals(
+			Principal{Arn: "arn:aws:iam::111111111111:role/kept"},
+			Principal{Arn: "arn:aws:iam::111111111111:role/modified"},
+			Principal{Arn: "arn:aws:iam::111111111111:role/removed"},
+		).
+		WithResources(Resource{Arn: "arn:aws:s3:::removed"}).
+		Build()
+	next := NewBuilder().
+		WithAccounts(Account{Id: "111111111111", Name: "new"}).
+		WithPolicies(ManagedPolicy{Arn: "arn:aws:iam::111111111111:policy/kept"}).
+		WithPrincipals(
+			Principal{Arn: "arn:aws:iam::111111111111:role/kept"},
+			Principal{
+				Arn:              "arn:aws:iam::111111111111:role/modified",
+				AttachedPolicies: []Arn{"arn:aws:iam::111111111111:policy/kept"},
+			},
+		).
+		WithGroups(Group{Arn: "arn:aws:iam::111111111111:group/added"}).
+		WithResources(Resource{Arn: "arn:aws:s3:::added"}).
+		Build()
+
+	diff := DiffUniverses(base, next)
+
+	data := (&Overlay{Universe: diff}).ToData()
+	if len(data.Accounts) != 1 || data.Accounts[0].Name != "new" {
+		t.Errorf("expected only the modified account, got %+v", data.Accounts)
+	}
+	if len(data.Policies) != 0 {
+		t.Errorf("expected no unchanged policies, got %+v", data.Policies)
+	}
+	if len(data.Principals) != 1 ||
+		data.Principals[0].Arn != "arn:aws:iam::111111111111:role/modified" {
+		t.Errorf("expected only the modified principal, got %+v", data.Principals)
+	}
+	if len(data.Groups) != 1 || len(data.Resources) != 1 {
+		t.Errorf("expected the added group and resource, got %+v, %+v", data.Groups, data.Resources)
+	}
+	wantTombstones := []Arn{
+		"222222222222",
+		"arn:aws:iam::111111111111:role/removed",
+		"arn:aws:s3:::removed",
+	}
+	if !reflect.DeepEqual(data.Tombstones, wantTombstones) {
+		t.Errorf("wanted tombstones %v, got %v", wantTombstones, data.Tombstones)
+	}
+
+	// layering the diff on the base reproduces the new universe
+	uvs := base.Overlay(diff)
+	for _, visible := range []func([]*Universe) []Arn{VisiblePrincipalArns, VisibleResourceArns} {
+		got, want := visible(uvs), visible([]*Universe{next})
+		slices.Sort(got)
+		slices.Sort(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected visible ARNs %v, got %v", want, got)
+		}
+	}
+	if a, ok, _ := LookupAccount(uvs, "222222222222"); ok {
+		t.Errorf("expected removed account to be hidden, got %+v", a)
+	}
+	p, _, _ := LookupPrincipal(uvs, "arn:aws:iam::111111111111:role/modified")
+	if len(p.AttachedPolicies) != 1 {
+		t.Errorf("expected modified principal to take precedence, got %+v", p)
+	}
+}
+
+func TestDiffUniverses_RemovedKindWithSurvivor(t *testing.T) {
+	role := "arn:aws:iam::111111111111:role/both"
+	base := NewBuilder().
+		WithPrincipals(Principal{Arn: role, AccountId: "111111111111"}).
+		WithResources(Resource{Arn: role, Type: "AWS::IAM::Role"}).
+		Build()
+	next := NewBuilder().
+		WithPrincipals(Principal{Arn: role, AccountId: "111111111111"}).
+		Build()
+
+	uvs := base.Overlay(DiffUniverses(base, next))
+
+	if _, ok, err := LookupPrincipal(uvs, role); !ok || err != nil {
+		t.Errorf("expected unchanged principal to remain visible, got ok=%v err=%v", ok, err)
+	}
+	if r, ok, _ := LookupResource(uvs, role); ok {
+		t.Errorf("expected removed resource to be hidden, got %+v", r)
+	}
+}
@@ -171,6 +171,12 @@ func TestOverlay_ToDataAndFromData(t *testing.T) {
 	o.Universe.PutResource(Resource{Arn: "arn:aws:s3:::test-bucket"})
 	o.Universe.PutPolicy(ManagedPolicy{Arn: "arn:aws:iam::123456789012:policy/test"})
 	o.Universe.PutGroup(Group{Arn: "arn:aws:iam::123456789012:group/test"})
+	o.Universe.PutTombstone("arn:aws:iam::123456789012:role/deleted")
+	o.Revision = 3
+	o.UpdatedAt = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	o.Author = "alice"
+	o.Message = "add test entities"
+	o.ExpiresAt = time.Date(2024, 2, 15, 10, 30, 0, 0, time.UTC)
 
 	// Convert to data
 	data := o.ToData()
@@ -196,6 +202,9 @@ func TestOverlay_ToDataAndFromData(t *testing.T) {
 	if len(data.Groups) != 1 {
 		t.Errorf("expected 1 group, got %d", len(data.Groups))
 	}
+	if len(data.Tombstones) != 1 {
+		t.Errorf("expected 1 tombstone, got %d", len(data.Tombstones))
+	}
 
 	// Convert back from data
 	restored := FromData(data)
@@ -221,6 +230,50 @@ func TestOverlay_ToDataAndFromData(t *testing.T) {
 	if restored.NumGroups() != 1 {
 		t.Errorf("expected 1 group, got %d", restored.NumGroups())
 	}
+	if !restored.Universe.IsTombstoned("arn:aws:iam::123456789012:role/deleted") {
+		t.Errorf("expected tombstone to be restored")
+	}
+	if restored.RevisionInfo() != o.RevisionInfo() {
+		t.Errorf("expected revision %v, got %v", o.RevisionInfo(), restored.RevisionInfo())
+	}
+	if !restored.ExpiresAt.Equal(o.ExpiresAt) {
+		t.Errorf("expected expiry %v, got %v", o.ExpiresAt, restored.ExpiresAt)
+	}
+}
+
+func TestOverlay_Expired(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	o := NewOverlayWithTime("expiring", now.Add(-time.Hour))
+
+	if o.Expired(now) {
+		t.Error("overlay without expiry should never expire")
+	}
+
+	o.ExpiresAt = now.Add(time.Minute)
+	if o.Expired(now) {
+		t.Error("overlay should not expire before its expiry")
+	}
+	if !o.Expired(o.ExpiresAt) {
+		t.Error("overlay should expire at its expiry")
+	}
+	if !o.Summary().ExpiresAt.Equal(o.ExpiresAt) {
+		t.Errorf("expected expiry in summary, got %v", o.Summary().ExpiresAt)
+	}
+}
+
+func TestOverlay_TombstonesOnly(t *testing.T) {
+	o := NewOverlay("decommission")
+	o.Universe.PutTombstone("arn:aws:iam::123456789012:policy/legacy")
+
+	if o.IsEmpty() {
+		t.Error("overlay with tombstones should not be empty")
+	}
+	if o.Summary().NumTombstones != 1 {
+		t.Errorf("expected 1 tombstone in summary, got %d", o.Summary().NumTombstones)
+	}
+	if !o.Clone("copy").Universe.IsTombstoned("arn:aws:iam::123456789012:policy/legacy") {
+		t.Error("expected clone to retain tombstones")
+	}
 }
 
 func TestOverlay_Summary(t *testing.T) {
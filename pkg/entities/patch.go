@@ -0,0 +1,172 @@
+package entities
+
+import (
+	"fmt"
+	"slices"
+
+	json "github.com/bytedance/sonic"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Patch describes a partial modification of an existing entity, such as attaching one more managed
+// policy or adding a statement to an inline policy. Patches are stored in overlays and applied at
+// freeze time to whichever definition of the entity would otherwise be used, so that they do not go
+// stale when the underlying entity is refreshed.
+//
+// Patches operate on the JSON form of the entity, which uses the Go field names (e.g.
+// "AttachedPolicies" or "InlinePolicies")
+type Patch struct {
+	// Arn identifies the entity to be patched (or the account ID, for accounts)
+	Arn Arn `json:"arn"`
+
+	// Merge is a JSON Merge Patch (RFC 7386) document, applied before any operations
+	Merge map[string]any `json:"merge,omitempty"`
+
+	// Operations is a JSON Patch (RFC 6902) document
+	Operations []PatchOperation `json:"operations,omitempty"`
+}
+
+// PatchOperation is a single JSON Patch (RFC 6902) operation
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value"`
+}
+
+// patchOps are the operations defined by RFC 6902
+var patchOps = []string{"add", "remove", "replace", "move", "copy", "test"}
+
+// Validate checks that the patch is well-formed. Whether or not it can be applied depends on the
+// entity it is eventually applied to
+func (p *Patch) Validate() error {
+	if p.Arn == "" {
+		return fmt.Errorf("patch is missing required field 'arn'")
+	}
+	if len(p.Merge) == 0 && len(p.Operations) == 0 {
+		return fmt.Errorf("patch for '%s' must contain 'merge' or 'operations'", p.Arn)
+	}
+
+	for i, op := range p.Operations {
+		if !slices.Contains(patchOps, op.Op) {
+			return fmt.Errorf("patch for '%s' has invalid op for operations[%d]: '%s'", p.Arn, i, op.Op)
+		}
+		if op.Path == "" {
+			return fmt.Errorf("patch for '%s' is missing path for operations[%d]", p.Arn, i)
+		}
+		if (op.Op == "move" || op.Op == "copy") && op.From == "" {
+			return fmt.Errorf("patch for '%s' is missing from for operations[%d]", p.Arn, i)
+		}
+	}
+
+	return nil
+}
+
+// compiledPatch is a [Patch] prepared for application, with its merge document encoded and its
+// operations decoded once up front rather than each time the patch is applied
+type compiledPatch struct {
+	Patch
+
+	merge []byte
+	ops   jsonpatch.Patch
+
+	// err records a failure to compile the patch, reported whenever it is applied
+	err error
+}
+
+// compilePatch prepares the provided patch for application
+func compilePatch(p Patch) compiledPatch {
+	c := compiledPatch{Patch: p}
+
+	if len(p.Merge) > 0 {
+		c.merge, c.err = json.Marshal(p.Merge)
+		if c.err != nil {
+			return c
+		}
+	}
+
+	if len(p.Operations) > 0 {
+		var ops []byte
+		ops, c.err = json.Marshal(p.Operations)
+		if c.err != nil {
+			return c
+		}
+
+		c.ops, c.err = jsonpatch.DecodePatch(ops)
+	}
+
+	return c
+}
+
+// apply applies the patch to the JSON form of an entity
+func (p *compiledPatch) apply(doc []byte) ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	var err error
+	if len(p.merge) > 0 {
+		doc, err = jsonpatch.MergePatch(doc, p.merge)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.ops) > 0 {
+		// allow e.g. appending to a list which is currently empty
+		options := jsonpatch.NewApplyOptions()
+		options.EnsurePathExistsOnAdd = true
+
+		doc, err = p.ops.ApplyWithOptions(doc, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// applyPatches returns a patched copy of the provided entity. Patches are grouped by layer, in
+// priority order as returned by [Universe.Overlay]; lower-precedence layers are applied first.
+//
+// The patched copy is detached from any universe, and must be frozen using FreezeWith
+func applyPatches[T any](item *T, layers [][]compiledPatch) (*T, error) {
+	if !slices.ContainsFunc(layers, func(ps []compiledPatch) bool { return len(ps) > 0 }) {
+		return item, nil
+	}
+
+	doc, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop null fields (e.g. empty lists), so that patches can add to them
+	var fields map[string]any
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range fields {
+		if v == nil {
+			delete(fields, k)
+		}
+	}
+	if doc, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		for _, patch := range layers[i] {
+			doc, err = patch.apply(doc)
+			if err != nil {
+				return nil, fmt.Errorf("unable to apply patch for '%s': %w", patch.Arn, err)
+			}
+		}
+	}
+
+	patched := new(T)
+	if err := json.Unmarshal(doc, patched); err != nil {
+		return nil, fmt.Errorf("invalid entity after applying patches: %w", err)
+	}
+
+	return patched, nil
+}
@@ -0,0 +1,317 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+func TestPatch_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   Patch
+		wantErr bool
+	}{
+		{
+			name:  "merge",
+			patch: Patch{Arn: "arn:aws:s3:::a", Merge: map[string]any{"Type": "x"}},
+		},
+		{
+			name: "operations",
+			patch: Patch{Arn: "arn:aws:s3:::a", Operations: []PatchOperation{
+				{Op: "add", Path: "/Tags/-", Value: map[string]any{"Key": "k"}},
+				{Op: "copy", Path: "/Tags/-", From: "/Tags/0"},
+			}},
+		},
+		{
+			name:    "missing_arn",
+			patch:   Patch{Merge: map[string]any{"Type": "x"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			patch:   Patch{Arn: "arn:aws:s3:::a"},
+			wantErr: true,
+		},
+		{
+			name: "invalid_op",
+			patch: Patch{Arn: "arn:aws:s3:::a", Operations: []PatchOperation{
+				{Op: "append", Path: "/Tags"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "missing_path",
+			patch: Patch{Arn: "arn:aws:s3:::a", Operations: []PatchOperation{
+				{Op: "remove"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "missing_from",
+			patch: Patch{Arn: "arn:aws:s3:::a", Operations: []PatchOperation{
+				{Op: "move", Path: "/Tags/0"},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.patch.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error = %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCompilePatch_NullValue(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  string
+		want string
+	}{
+		{
+			name: "add_null",
+			ops:  `[{"op": "add", "path": "/PermissionsBoundary", "value": null}]`,
+			want: `{"Arn": "arn:aws:iam::88888:role/app", "PermissionsBoundary": null}`,
+		},
+		{
+			name: "replace_with_null",
+			ops:  `[{"op": "replace", "path": "/Arn", "value": null}]`,
+			want: `{"Arn": null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []PatchOperation
+			if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+				t.Fatalf("failed to decode operations: %v", err)
+			}
+
+			c := compilePatch(Patch{Arn: "arn:aws:iam::88888:role/app", Operations: ops})
+			got, err := c.apply([]byte(`{"Arn": "arn:aws:iam::88888:role/app"}`))
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+
+			var gotDoc, wantDoc any
+			json.Unmarshal(got, &gotDoc)
+			json.Unmarshal([]byte(tt.want), &wantDoc)
+			if !reflect.DeepEqual(gotDoc, wantDoc) {
+				t.Errorf("wanted %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUniverse_Patches(t *testing.T) {
+	uv := NewUniverse()
+	if uv.NumPatches() != 0 || len(uv.Patches()) != 0 {
+		t.Fatalf("expected no patches in a new universe")
+	}
+
+	b := Patch{Arn: "arn:aws:s3:::b", Merge: map[string]any{"Type": "b"}}
+	a1 := Patch{Arn: "arn:aws:s3:::a", Merge: map[string]any{"Type": "a1"}}
+	a2 := Patch{Arn: "arn:aws:s3:::a", Merge: map[string]any{"Type": "a2"}}
+	uv.PutPatch(b)
+	uv.PutPatch(a1)
+	uv.PutPatch(a2)
+
+	if uv.NumPatches() != 3 {
+		t.Fatalf("expected 3 patches, got %d", uv.NumPatches())
+	}
+	if got := uv.Patches(); !reflect.DeepEqual(got, []Patch{a1, a2, b}) {
+		t.Fatalf("unexpected patches: %v", got)
+	}
+
+	uv.RemovePatches("arn:aws:s3:::a")
+	if got := uv.Patches(); !reflect.DeepEqual(got, []Patch{b}) {
+		t.Fatalf("expected patches for 'arn:aws:s3:::a' to be removed, got: %v", got)
+	}
+}
+
+func TestUniverse_LookupPatches(t *testing.T) {
+	arn := "arn:aws:iam::88888:role/app"
+	base := NewBuilder().
+		WithPrincipals(Principal{
+			Arn:              arn,
+			Type:             "AWS::IAM::Role",
+			AttachedPolicies: []Arn{"arn:aws:iam::88888:policy/a"},
+			Tags:             []Tag{{Key: "team", Value: "red"}, {Key: "env", Value: "dev"}},
+		}).
+		Build()
+
+	first := NewBuilder().
+		WithPatches(Patch{
+			Arn:   arn,
+			Merge: map[string]any{"PermissionsBoundary": "arn:aws:iam::88888:policy/boundary"},
+			Operations: []PatchOperation{
+				{Op: "add", Path: "/AttachedPolicies/-", Value: "arn:aws:iam::88888:policy/b"},
+				{Op: "remove", Path: "/Tags/1"},
+			},
+		}).
+		Build()
+
+	second := NewBuilder().
+		WithPatches(Patch{
+			Arn:   arn,
+			Merge: map[string]any{"PermissionsBoundary": nil},
+			Operations: []PatchOperation{
+				{Op: "replace", Path: "/Tags/0/Value", Value: "blue"},
+				{Op: "add", Path: "/InlinePolicies/-", Value: map[string]any{"Version": "2012-10-17"}},
+			},
+		}).
+		Build()
+
+	// patches from a single layer are applied on top of the base definition
+	p, ok, err := LookupPrincipal(base.Overlay(first), arn)
+	if err != nil || !ok {
+		t.Fatalf("expected patched principal, got ok = %v, err = %v", ok, err)
+	}
+	if p.PermissionsBoundary != "arn:aws:iam::88888:policy/boundary" {
+		t.Errorf("expected boundary to be set by merge patch, got %q", p.PermissionsBoundary)
+	}
+	want := []Arn{"arn:aws:iam::88888:policy/a", "arn:aws:iam::88888:policy/b"}
+	if !reflect.DeepEqual(p.AttachedPolicies, want) {
+		t.Errorf("unexpected attached policies: %v", p.AttachedPolicies)
+	}
+	if !reflect.DeepEqual(p.Tags, []Tag{{Key: "team", Value: "red"}}) {
+		t.Errorf("unexpected tags: %v", p.Tags)
+	}
+
+	// the base definition is unchanged
+	orig, _ := base.Principal(arn)
+	if len(orig.AttachedPolicies) != 1 || len(orig.Tags) != 2 {
+		t.Errorf("expected base principal to be unmodified, got %v", orig)
+	}
+
+	// later layers are applied after earlier ones, including to lists which were empty
+	p, _, err = LookupPrincipal(base.Overlay(first, second), arn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.PermissionsBoundary != "" {
+		t.Errorf("expected boundary to be removed by later layer, got %q", p.PermissionsBoundary)
+	}
+	if !reflect.DeepEqual(p.Tags, []Tag{{Key: "team", Value: "blue"}}) {
+		t.Errorf("unexpected tags: %v", p.Tags)
+	}
+	if len(p.InlinePolicies) != 1 || p.InlinePolicies[0].Version != "2012-10-17" {
+		t.Errorf("unexpected inline policies: %v", p.InlinePolicies)
+	}
+
+	// patches below a redefinition of the entity are ignored
+	redefined := NewBuilder().WithPrincipals(Principal{Arn: arn, Type: "redefined"}).Build()
+	p, _, err = LookupPrincipal(base.Overlay(first, redefined), arn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Type != "redefined" || p.PermissionsBoundary != "" || len(p.AttachedPolicies) != 0 {
+		t.Errorf("expected patches below redefinition to be ignored, got %v", p)
+	}
+
+	// patches for missing entities are ignored
+	missing := NewBuilder().
+		WithPatches(Patch{Arn: "arn:aws:iam::88888:role/missing", Merge: map[string]any{"Type": "x"}}).
+		Build()
+	_, ok, err = LookupPrincipal(base.Overlay(missing), "arn:aws:iam::88888:role/missing")
+	if ok || err != nil {
+		t.Errorf("expected missing principal to remain missing, got ok = %v, err = %v", ok, err)
+	}
+
+	// patches which cannot be applied result in an error
+	invalid := NewBuilder().
+		WithPatches(Patch{Arn: arn, Operations: []PatchOperation{{Op: "remove", Path: "/Tags/9"}}}).
+		Build()
+	if _, _, err := LookupPrincipal(base.Overlay(invalid), arn); err == nil {
+		t.Errorf("expected error applying invalid patch")
+	}
+}
+
+func TestUniverse_LookupPatchedSubresource(t *testing.T) {
+	base := NewBuilder().
+		WithResources(Resource{Arn: "arn:aws:s3:::bucket", Type: "AWS::S3::Bucket"}).
+		Build()
+
+	// patches are compiled once, when they are added to the universe
+	tagged := NewBuilder().
+		WithPatches(Patch{Arn: "arn:aws:s3:::bucket", Operations: []PatchOperation{
+			{Op: "add", Path: "/Region", Value: "us-west-2"},
+		}}).
+		Build()
+	if compiled := tagged.patchesFor("arn:aws:s3:::bucket"); len(compiled[0].ops) != 1 {
+		t.Fatalf("expected patch to be compiled when added, got %+v", compiled)
+	}
+
+	// sub-resources are derived from the patched parent
+	obj, ok, err := LookupResource(base.Overlay(tagged), "arn:aws:s3:::bucket/key")
+	if err != nil || !ok {
+		t.Fatalf("expected patched sub-resource, got ok = %v, err = %v", ok, err)
+	}
+	if obj.Region != "us-west-2" || obj.Type != "AWS::S3::Bucket::Object" {
+		t.Errorf("expected sub-resource of patched parent, got %+v", obj)
+	}
+
+	// a parent patched into something without sub-resources results in an error, rather than the
+	// sub-resource silently going missing
+	retyped := NewBuilder().
+		WithPatches(Patch{
+			Arn:   "arn:aws:s3:::bucket",
+			Merge: map[string]any{"Type": "AWS::SQS::Queue"},
+		}).
+		Build()
+	_, ok, err = LookupResource(base.Overlay(retyped), "arn:aws:s3:::bucket/key")
+	if err == nil || ok {
+		t.Errorf("expected error deriving sub-resource, got ok = %v, err = %v", ok, err)
+	}
+}
+
+func TestFreeze_Patches(t *testing.T) {
+	base := NewBuilder().
+		WithPolicies(ManagedPolicy{
+			Arn:    "arn:aws:iam::88888:policy/readonly",
+			Policy: policy.Policy{Statement: []policy.Statement{{Effect: policy.EFFECT_ALLOW}}},
+		}).
+		WithPrincipals(Principal{Arn: "arn:aws:iam::88888:role/app"}).
+		WithResources(Resource{Arn: "arn:aws:s3:::bucket", Type: "AWS::S3::Bucket"}).
+		Build()
+
+	overlay := NewBuilder().
+		WithPatches(
+			Patch{
+				Arn: "arn:aws:iam::88888:role/app",
+				Operations: []PatchOperation{
+					{Op: "add", Path: "/AttachedPolicies/-", Value: "arn:aws:iam::88888:policy/readonly"},
+				},
+			},
+			Patch{
+				Arn:   "arn:aws:s3:::bucket",
+				Merge: map[string]any{"Tags": []any{map[string]any{"Key": "k", "Value": "v"}}},
+			},
+		).
+		Build()
+
+	fps, err := base.FrozenPrincipals(true, overlay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fps) != 1 || len(fps[0].AttachedPolicies) != 1 {
+		t.Fatalf("expected patched policy attachment to be frozen, got %v", fps)
+	}
+	if len(fps[0].AttachedPolicies[0].Policy.Statement) != 1 {
+		t.Errorf("expected attached policy to be resolved from the base universe")
+	}
+
+	frs, err := base.FrozenResources(false, overlay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(frs) != 1 || !reflect.DeepEqual(frs[0].Tags, []Tag{{Key: "k", Value: "v"}}) {
+		t.Fatalf("expected patched tags to be frozen, got %v", frs)
+	}
+}
@@ -1,11 +1,14 @@
 package entities
 
 import (
+	"fmt"
 	"iter"
+	"maps"
 	"path"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/nsiow/yams/internal/assets"
 )
@@ -28,7 +31,21 @@ type Universe struct {
 	principals map[Arn]*Principal
 	resources  map[Arn]*Resource
 
+	// tombstones are the ARNs (or account IDs) of entities which have been deleted. They are only
+	// meaningful for overlays, where they hide definitions from lower-precedence universes. This map
+	// is only allocated once a tombstone is added
+	tombstones map[Arn]struct{}
+
+	// patches are partial modifications of entities, applied on top of their definition in this or
+	// lower-precedence universes. They are compiled when added, and this map is only allocated once
+	// a patch is added
+	patches map[Arn][]compiledPatch
+
 	hasLoadedBasePolicies bool
+
+	// generation is incremented on every mutation, allowing consumers to cheaply detect changes. It
+	// is only allocated once first observed, so that untracked universes remain comparable by value
+	generation atomic.Pointer[atomic.Uint64]
 }
 
 // NewUniverse creates and returns a new, empty universe
@@ -70,7 +87,10 @@ func (u *Universe) WithBulkWriter(fn func(*BulkWriter)) {
 // Merge
 // -------------------------------------------------------------------------------------------------
 
-// Merge adds all entries in `other` [Universe] to this one
+// Merge adds all entries in `other` [Universe] to this one, as if `other` were layered on top of
+// it (see [Universe.Overlay]). Definitions in `other` replace those in this universe along with
+// their patches, and tombstones in `other` remove the definitions they hide. Tombstones and patches
+// are kept, so that the merged universe still applies them to any universes layered beneath it
 func (u *Universe) Merge(other *Universe) {
 	other.mut.RLock()
 	defer other.mut.RUnlock()
@@ -78,29 +98,208 @@ func (u *Universe) Merge(other *Universe) {
 	u.mut.Lock()
 	defer u.mut.Unlock()
 
+	for key := range other.tombstones {
+		u.removeKey(key)
+		u.putTombstone(key)
+	}
+
+	defined := make(map[string]bool)
 	for _, item := range other.accounts {
 		u.putAccount(*item)
+		defined[item.Id] = true
 	}
 	for _, item := range other.groups {
 		u.putGroup(*item)
+		defined[item.Arn] = true
 	}
 	for _, item := range other.policies {
 		u.putPolicy(*item)
+		defined[item.Arn] = true
 	}
 	for _, item := range other.principals {
 		u.putPrincipal(*item)
+		defined[item.Arn] = true
 	}
 	for _, item := range other.resources {
 		u.putResource(*item)
+		defined[item.Arn] = true
+	}
+
+	// patches from this universe only apply to its own definitions, so they are dropped for
+	// entities which `other` redefines; otherwise, patches from `other` are applied after them
+	for key := range defined {
+		delete(u.patches, key)
+	}
+	for key, patches := range other.patches {
+		if u.patches == nil {
+			u.patches = make(map[Arn][]compiledPatch)
+		}
+		u.patches[key] = append(slices.Clone(u.patches[key]), patches...)
+	}
+}
+
+// removeKey removes every entity and patch stored under the provided key, which is the account ID,
+// normalized ARN or ARN of the entities; must be called with the write lock held
+func (u *Universe) removeKey(key string) {
+	u.touch()
+	delete(u.accounts, key)
+	delete(u.groups, key)
+	delete(u.policies, key)
+	delete(u.principals, key)
+	delete(u.resources, key)
+	delete(u.patches, key)
+}
+
+// Overlay returns a priority-order slice of this [Universe] combined with the provided overlays.
+//
+// Overlays are provided in order of increasing precedence, such that the last overlay is the first
+// [Universe] in the result and this one is the last. Nil overlays are skipped
+func (u *Universe) Overlay(overlays ...*Universe) []*Universe {
+	uvs := make([]*Universe, 0, len(overlays)+1)
+	for i := len(overlays) - 1; i >= 0; i-- {
+		if overlays[i] != nil {
+			uvs = append(uvs, overlays[i])
+		}
+	}
+
+	return append(uvs, u)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Layers
+// -------------------------------------------------------------------------------------------------
+
+// lookup walks a priority-ordered slice of universes (see [Universe.Overlay]) and returns the first
+// definition found by `get`, with any patches for the provided keys from that universe and those
+// above it applied.
+//
+// A tombstone for any of the provided keys hides definitions in all lower-precedence universes; a
+// definition in the same universe as a tombstone takes precedence
+func lookup[T any](
+	uvs []*Universe, get func(*Universe) (*T, bool), keys ...string) (*T, bool, error) {
+
+	item, _, err := find(uvs, get, keys...)
+	return item, item != nil, err
+}
+
+// find is like lookup, but additionally reports whether the entity was hidden by a tombstone rather
+// than simply not being defined in any universe
+func find[T any](
+	uvs []*Universe, get func(*Universe) (*T, bool), keys ...string) (*T, bool, error) {
+
+	var patches [][]compiledPatch
+	for _, uv := range uvs {
+		patches = append(patches, uv.patchesFor(keys...))
+		if item, ok := get(uv); ok {
+			patched, err := applyPatches(item, patches)
+			if err != nil {
+				return nil, false, err
+			}
+			return patched, false, nil
+		}
+		if uv.hasTombstone(keys...) {
+			return nil, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// visible walks a priority-ordered slice of universes and returns the ARNs listed by `arns` which
+// are not hidden by a tombstone in a higher-precedence universe, without duplicates
+func visible(uvs []*Universe, arns func(*Universe) []Arn) []Arn {
+	var out []Arn
+	hidden := make(map[Arn]bool)
+	for _, uv := range uvs {
+		layer := arns(uv)
+		for _, arn := range layer {
+			if !hidden[arn] {
+				out = append(out, arn)
+			}
+		}
+
+		for _, arn := range layer {
+			hidden[arn] = true
+		}
+		for _, arn := range uv.Tombstones() {
+			hidden[arn] = true
+		}
 	}
+
+	return out
 }
 
-// Overlay returns a priority-order slice of the two [Universe]s combined
-func (u *Universe) Overlay(other *Universe) []*Universe {
-	if other == nil {
-		return []*Universe{u}
-	} else {
-		return []*Universe{other, u}
+// LookupAccount retrieves the highest-precedence definition of an account from a priority-ordered
+// slice of universes, as returned by [Universe.Overlay]
+func LookupAccount(uvs []*Universe, id string) (*Account, bool, error) {
+	return lookup(uvs, func(uv *Universe) (*Account, bool) { return uv.Account(id) }, id)
+}
+
+// LookupGroup retrieves the highest-precedence definition of a group from a priority-ordered slice
+// of universes, as returned by [Universe.Overlay]
+func LookupGroup(uvs []*Universe, arn Arn) (*Group, bool, error) {
+	return lookup(uvs, func(uv *Universe) (*Group, bool) { return uv.Group(arn) }, arn)
+}
+
+// LookupPolicy retrieves the highest-precedence definition of a policy from a priority-ordered
+// slice of universes, as returned by [Universe.Overlay]
+func LookupPolicy(uvs []*Universe, arn Arn) (*ManagedPolicy, bool, error) {
+	return lookup(uvs, func(uv *Universe) (*ManagedPolicy, bool) { return uv.Policy(arn) }, arn)
+}
+
+// LookupPrincipal retrieves the highest-precedence definition of a principal from a
+// priority-ordered slice of universes, as returned by [Universe.Overlay]
+func LookupPrincipal(uvs []*Universe, arn Arn) (*Principal, bool, error) {
+	return lookup(uvs, func(uv *Universe) (*Principal, bool) { return uv.Principal(arn) }, arn)
+}
+
+// LookupResource retrieves the highest-precedence definition of a resource from a priority-ordered
+// slice of universes, as returned by [Universe.Overlay]. Sub-resources (e.g. S3 objects) are derived
+// from their parent resource, including any patches or tombstones for it
+func LookupResource(uvs []*Universe, arn Arn) (*Resource, bool, error) {
+	parent, path := splitSubresource(arn)
+	r, ok, err := lookup(uvs, func(uv *Universe) (*Resource, bool) { return uv.Resource(parent) },
+		parent)
+	if !ok || err != nil || len(path) == 0 {
+		return r, ok, err
+	}
+
+	// the parent may have been patched into something without sub-resources
+	sub, err := r.SubResource(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to derive sub-resource '%s': %w", arn, err)
+	}
+	return sub, true, nil
+}
+
+// VisiblePrincipalArns returns the ARNs of all principals defined across a priority-ordered slice
+// of universes, excluding any which have been tombstoned
+func VisiblePrincipalArns(uvs []*Universe) []Arn {
+	return visible(uvs, (*Universe).PrincipalArns)
+}
+
+// VisibleResourceArns returns the ARNs of all resources defined across a priority-ordered slice of
+// universes, excluding any which have been tombstoned
+func VisibleResourceArns(uvs []*Universe) []Arn {
+	return visible(uvs, (*Universe).ResourceArns)
+}
+
+// Generation returns a counter which changes whenever the contents of the universe change. It can
+// be used to invalidate anything derived from the universe, such as cached simulation results
+func (u *Universe) Generation() uint64 {
+	gen := u.generation.Load()
+	if gen == nil {
+		u.generation.CompareAndSwap(nil, new(atomic.Uint64))
+		gen = u.generation.Load()
+	}
+
+	return gen.Load()
+}
+
+// touch records that the universe has changed
+func (u *Universe) touch() {
+	if gen := u.generation.Load(); gen != nil {
+		gen.Add(1)
 	}
 }
 
@@ -125,6 +324,7 @@ func (u *Universe) Size() int {
 func (u *Universe) ResolveOrgPolicyNames() {
 	u.mut.Lock()
 	defer u.mut.Unlock()
+	u.touch()
 
 	for _, acct := range u.accounts {
 		for i := range acct.OrgNodes {
@@ -193,6 +393,7 @@ func (u *Universe) PutAccount(a Account) {
 
 // putAccount is the internal unlocked version of PutAccount
 func (u *Universe) putAccount(a Account) {
+	u.touch()
 	a.uv = u
 	u.accounts[a.Id] = &a
 }
@@ -201,6 +402,7 @@ func (u *Universe) putAccount(a Account) {
 func (u *Universe) RemoveAccount(id string) {
 	u.mut.Lock()
 	defer u.mut.Unlock()
+	u.touch()
 
 	delete(u.accounts, id)
 }
@@ -272,6 +474,7 @@ func (u *Universe) PutGroup(g Group) {
 
 // putGroup is the internal unlocked version of PutGroup
 func (u *Universe) putGroup(g Group) {
+	u.touch()
 	g.Arn = normalizeGroupArn(g.Arn)
 	g.uv = u
 	u.groups[g.Arn] = &g
@@ -283,6 +486,7 @@ func (u *Universe) RemoveGroup(arn Arn) {
 
 	u.mut.Lock()
 	defer u.mut.Unlock()
+	u.touch()
 
 	delete(u.groups, arn)
 }
@@ -370,6 +574,7 @@ func (u *Universe) PutPolicy(p ManagedPolicy) {
 
 // putPolicy is the internal unlocked version of PutPolicy
 func (u *Universe) putPolicy(p ManagedPolicy) {
+	u.touch()
 	u.policies[p.Arn] = &p
 }
 
@@ -377,6 +582,7 @@ func (u *Universe) putPolicy(p ManagedPolicy) {
 func (u *Universe) RemovePolicy(arn Arn) {
 	u.mut.Lock()
 	defer u.mut.Unlock()
+	u.touch()
 
 	delete(u.policies, arn)
 }
@@ -439,6 +645,7 @@ func (u *Universe) PutPrincipal(p Principal) {
 
 // putPrincipal is the internal unlocked version of PutPrincipal
 func (u *Universe) putPrincipal(p Principal) {
+	u.touch()
 	p.uv = u
 	u.principals[p.Arn] = &p
 	// TODO(nsiow) should this also update the resources where relevant (user/role)?
@@ -448,6 +655,7 @@ func (u *Universe) putPrincipal(p Principal) {
 func (u *Universe) RemovePrincipal(arn Arn) {
 	u.mut.Lock()
 	defer u.mut.Unlock()
+	u.touch()
 
 	delete(u.principals, arn)
 }
@@ -464,6 +672,12 @@ func (u *Universe) NumResources() int {
 }
 
 func (u *Universe) subresource(arn Arn) (string, string) {
+	return splitSubresource(arn)
+}
+
+// splitSubresource splits the ARN of a sub-resource (e.g. an S3 object) into the ARN of its parent
+// resource and its path within that resource
+func splitSubresource(arn Arn) (string, string) {
 	// handle S3 objects
 	if strings.HasPrefix(arn, "arn:aws:s3:::") && strings.Contains(arn, "/") {
 		components := strings.SplitN(arn, "/", 2)
@@ -532,6 +746,7 @@ func (u *Universe) PutResource(r Resource) {
 
 // putResource is the internal unlocked version of PutResource
 func (u *Universe) putResource(r Resource) {
+	u.touch()
 	r.uv = u
 	u.resources[r.Arn] = &r
 }
@@ -540,6 +755,150 @@ func (u *Universe) putResource(r Resource) {
 func (u *Universe) RemoveResource(arn Arn) {
 	u.mut.Lock()
 	defer u.mut.Unlock()
+	u.touch()
 
 	delete(u.resources, arn)
 }
+
+// -------------------------------------------------------------------------------------------------
+// Tombstones
+// -------------------------------------------------------------------------------------------------
+
+// NumTombstones returns the number of tombstones in the universe
+func (u *Universe) NumTombstones() int {
+	u.mut.RLock()
+	defer u.mut.RUnlock()
+	return len(u.tombstones)
+}
+
+// Tombstones returns a sorted slice containing the ARNs (or account IDs) of all tombstones
+func (u *Universe) Tombstones() []Arn {
+	u.mut.RLock()
+	defer u.mut.RUnlock()
+
+	arns := make([]Arn, 0, len(u.tombstones))
+	for arn := range u.tombstones {
+		arns = append(arns, arn)
+	}
+	slices.Sort(arns)
+	return arns
+}
+
+// IsTombstoned returns whether or not the specified ARN (or account ID) has been tombstoned
+func (u *Universe) IsTombstoned(arn Arn) bool {
+	return u.hasTombstone(arn)
+}
+
+// hasTombstone returns whether or not any of the provided keys have been tombstoned
+func (u *Universe) hasTombstone(keys ...string) bool {
+	u.mut.RLock()
+	defer u.mut.RUnlock()
+
+	for _, key := range keys {
+		if _, ok := u.tombstones[entityKey(key)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PutTombstone marks the entity with the provided ARN (or account ID) as deleted. When the universe
+// is used as an overlay, definitions of the entity in lower-precedence universes are ignored
+func (u *Universe) PutTombstone(arn Arn) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	u.putTombstone(arn)
+}
+
+// putTombstone is the internal unlocked version of PutTombstone
+func (u *Universe) putTombstone(arn Arn) {
+	u.touch()
+	if u.tombstones == nil {
+		u.tombstones = make(map[Arn]struct{})
+	}
+	u.tombstones[entityKey(arn)] = struct{}{}
+}
+
+// RemoveTombstone removes the tombstone for the provided ARN (or account ID)
+func (u *Universe) RemoveTombstone(arn Arn) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	u.touch()
+
+	delete(u.tombstones, entityKey(arn))
+}
+
+// entityKey returns the key under which tombstones and patches for the provided ARN are stored,
+// applying the same normalization as is used when storing groups
+func entityKey(arn Arn) string {
+	if strings.HasPrefix(arn, "arn:aws:iam::") && strings.Contains(arn, ":group/") {
+		return normalizeGroupArn(arn)
+	}
+	return arn
+}
+
+// -------------------------------------------------------------------------------------------------
+// Patches
+// -------------------------------------------------------------------------------------------------
+
+// NumPatches returns the number of patches in the universe
+func (u *Universe) NumPatches() int {
+	u.mut.RLock()
+	defer u.mut.RUnlock()
+
+	n := 0
+	for _, patches := range u.patches {
+		n += len(patches)
+	}
+	return n
+}
+
+// Patches returns all patches in the universe, ordered by ARN and then by the order in which they
+// were added
+func (u *Universe) Patches() []Patch {
+	u.mut.RLock()
+	defer u.mut.RUnlock()
+
+	var patches []Patch
+	for _, key := range slices.Sorted(maps.Keys(u.patches)) {
+		for _, p := range u.patches[key] {
+			patches = append(patches, p.Patch)
+		}
+	}
+	return patches
+}
+
+// patchesFor returns the patches for any of the provided keys, in the order in which they were added
+func (u *Universe) patchesFor(keys ...string) []compiledPatch {
+	u.mut.RLock()
+	defer u.mut.RUnlock()
+
+	var patches []compiledPatch
+	for _, key := range keys {
+		patches = append(patches, u.patches[entityKey(key)]...)
+	}
+	return patches
+}
+
+// PutPatch adds the provided patch to the universe. Multiple patches for the same entity are applied
+// in the order in which they were added
+func (u *Universe) PutPatch(p Patch) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	u.touch()
+
+	if u.patches == nil {
+		u.patches = make(map[Arn][]compiledPatch)
+	}
+	key := entityKey(p.Arn)
+	u.patches[key] = append(u.patches[key], compilePatch(p))
+}
+
+// RemovePatches removes all patches for the entity referenced by the provided ARN
+func (u *Universe) RemovePatches(arn Arn) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	u.touch()
+
+	delete(u.patches, entityKey(arn))
+}
@@ -51,6 +51,23 @@ func (b *UniverseBuilder) WithResources(resources ...Resource) *UniverseBuilder
 	return b
 }
 
+// WithTombstones adds tombstones for the provided ARNs (or account IDs) to the universe under
+// construction
+func (b *UniverseBuilder) WithTombstones(arns ...Arn) *UniverseBuilder {
+	for _, arn := range arns {
+		b.uv.PutTombstone(arn)
+	}
+	return b
+}
+
+// WithPatches adds the provided patches to the universe under construction
+func (b *UniverseBuilder) WithPatches(patches ...Patch) *UniverseBuilder {
+	for _, p := range patches {
+		b.uv.PutPatch(p)
+	}
+	return b
+}
+
 // Build returns the universe constructed from the With* invocations thus far
 func (b *UniverseBuilder) Build() *Universe {
 	return b.uv
@@ -369,6 +369,77 @@ func TestUniverse_Merge(t *testing.T) {
 	}
 }
 
+func TestUniverse_MergeLayers(t *testing.T) {
+	base := NewUniverse()
+	base.PutPolicy(ManagedPolicy{Arn: "arn:aws:iam::111111111111:policy/legacy"})
+	base.PutGroup(Group{Arn: "arn:aws:iam::111111111111:group/admins"})
+	base.PutPrincipal(Principal{Arn: "arn:aws:iam::111111111111:role/app"})
+	base.PutResource(Resource{Arn: "arn:aws:s3:::bucket"})
+
+	layer := NewUniverse()
+	layer.PutTombstone("arn:aws:iam::111111111111:policy/legacy")
+	layer.PutTombstone("arn:aws:iam::111111111111:group/path/admins")
+	layer.PutPatch(Patch{
+		Arn: "arn:aws:iam::111111111111:role/app",
+		Operations: []PatchOperation{
+			{Op: "add", Path: "/Tags", Value: []any{map[string]any{"Key": "k", "Value": "v"}}},
+		},
+	})
+
+	merged := NewUniverse()
+	merged.Merge(base)
+	merged.Merge(layer)
+
+	// tombstones remove the definitions they hide, and are kept for universes layered beneath
+	if merged.HasPolicy("arn:aws:iam::111111111111:policy/legacy") {
+		t.Errorf("expected tombstoned policy to be removed by merge")
+	}
+	if merged.HasGroup("arn:aws:iam::111111111111:group/admins") {
+		t.Errorf("expected tombstoned group to be removed by merge")
+	}
+	if !merged.IsTombstoned("arn:aws:iam::111111111111:policy/legacy") {
+		t.Errorf("expected tombstone to be kept by merge")
+	}
+
+	// patches are kept and applied to the merged definitions
+	if merged.NumPatches() != 1 {
+		t.Fatalf("expected 1 patch after merge, got %d", merged.NumPatches())
+	}
+	p, ok, err := LookupPrincipal(merged.Overlay(), "arn:aws:iam::111111111111:role/app")
+	if err != nil || !ok {
+		t.Fatalf("expected patched principal, got ok = %v, err = %v", ok, err)
+	}
+	if len(p.Tags) != 1 || p.Tags[0].Key != "k" {
+		t.Errorf("expected patch to be applied after merge, got tags %v", p.Tags)
+	}
+
+	// merging a layer is equivalent to overlaying it
+	lower := NewUniverse()
+	lower.PutPolicy(ManagedPolicy{Arn: "arn:aws:iam::111111111111:policy/legacy"})
+	_, ok, err = LookupPolicy(lower.Overlay(merged), "arn:aws:iam::111111111111:policy/legacy")
+	if err != nil || ok {
+		t.Errorf("expected merged tombstone to hide lower definition, got ok = %v", ok)
+	}
+
+	// redefining a patched entity replaces its patches along with the definition
+	redefined := NewUniverse()
+	redefined.PutPrincipal(Principal{Arn: "arn:aws:iam::111111111111:role/app"})
+	merged.Merge(redefined)
+	if merged.NumPatches() != 0 {
+		t.Errorf("expected patches to be replaced by redefinition, got %d", merged.NumPatches())
+	}
+
+	// a definition in the same layer as a tombstone survives the merge
+	both := NewUniverse()
+	both.PutTombstone("arn:aws:s3:::bucket")
+	both.PutResource(Resource{Arn: "arn:aws:s3:::bucket", Region: "us-west-2"})
+	merged.Merge(both)
+	r, ok := merged.Resource("arn:aws:s3:::bucket")
+	if !ok || r.Region != "us-west-2" {
+		t.Errorf("expected redefined resource to survive merge, got %v", r)
+	}
+}
+
 // -------------------------------------------------------------------------------------------------
 // Overlay
 // -------------------------------------------------------------------------------------------------
@@ -397,6 +468,16 @@ func TestUniverse_Overlay(t *testing.T) {
 	if result[0] != base {
 		t.Fatal("base should be the only element")
 	}
+
+	// Test with multiple overlays, which are provided in increasing precedence
+	second := NewUniverse()
+	result = base.Overlay(overlay, nil, second)
+	if len(result) != 3 {
+		t.Fatalf("expected 3 universes, got %d", len(result))
+	}
+	if result[0] != second || result[1] != overlay || result[2] != base {
+		t.Fatal("expected universes in order: second, overlay, base")
+	}
 }
 
 // -------------------------------------------------------------------------------------------------
@@ -421,6 +502,155 @@ func TestUniverse_Size(t *testing.T) {
 	}
 }
 
+func TestUniverse_Generation(t *testing.T) {
+	uv := NewUniverse()
+	last := uv.Generation()
+
+	mutations := map[string]func(){
+		"PutAccount":    func() { uv.PutAccount(Account{Id: "123456789012"}) },
+		"RemoveAccount": func() { uv.RemoveAccount("123456789012") },
+		"PutGroup":      func() { uv.PutGroup(Group{Arn: "arn:aws:iam::123456789012:group/g"}) },
+		"RemoveGroup":   func() { uv.RemoveGroup("arn:aws:iam::123456789012:group/g") },
+		"PutPolicy":     func() { uv.PutPolicy(ManagedPolicy{Arn: "arn:aws:iam::123456789012:policy/p"}) },
+		"RemovePolicy":  func() { uv.RemovePolicy("arn:aws:iam::123456789012:policy/p") },
+		"PutPrincipal":  func() { uv.PutPrincipal(Principal{Arn: "arn:aws:iam::123456789012:role/r"}) },
+		"RemovePrincipal": func() {
+			uv.RemovePrincipal("arn:aws:iam::123456789012:role/r")
+		},
+		"PutResource":    func() { uv.PutResource(Resource{Arn: "arn:aws:s3:::bucket"}) },
+		"RemoveResource": func() { uv.RemoveResource("arn:aws:s3:::bucket") },
+		"PutTombstone":   func() { uv.PutTombstone("arn:aws:s3:::bucket") },
+		"RemoveTombstone": func() {
+			uv.RemoveTombstone("arn:aws:s3:::bucket")
+		},
+		"Merge": func() {
+			other := NewUniverse()
+			other.PutAccount(Account{Id: "111111111111"})
+			uv.Merge(other)
+		},
+		"WithBulkWriter": func() {
+			uv.WithBulkWriter(func(b *BulkWriter) { b.PutAccount(Account{Id: "222222222222"}) })
+		},
+		"LoadBasePolicies": func() { uv.LoadBasePolicies() },
+	}
+
+	for name, mutate := range mutations {
+		mutate()
+		if uv.Generation() == last {
+			t.Fatalf("expected generation to change after %s", name)
+		}
+		last = uv.Generation()
+	}
+
+	// reads should not change the generation
+	_ = uv.Size()
+	_, _ = uv.Account("111111111111")
+	if uv.Generation() != last {
+		t.Fatalf("expected generation to be unchanged after reads")
+	}
+
+	// loading base policies a second time is a no-op
+	uv.LoadBasePolicies()
+	if uv.Generation() != last {
+		t.Fatalf("expected generation to be unchanged after repeated LoadBasePolicies")
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tombstones
+// -------------------------------------------------------------------------------------------------
+
+func TestUniverse_Tombstones(t *testing.T) {
+	uv := NewUniverse()
+	if uv.NumTombstones() != 0 || len(uv.Tombstones()) != 0 {
+		t.Fatalf("expected no tombstones in a new universe")
+	}
+
+	uv.PutTombstone("arn:aws:s3:::b")
+	uv.PutTombstone("arn:aws:s3:::a")
+	uv.PutTombstone("arn:aws:s3:::a")
+
+	if !uv.IsTombstoned("arn:aws:s3:::a") {
+		t.Fatalf("expected tombstone for 'arn:aws:s3:::a'")
+	}
+	if uv.IsTombstoned("arn:aws:s3:::c") {
+		t.Fatalf("unexpected tombstone for 'arn:aws:s3:::c'")
+	}
+	if got := uv.Tombstones(); !reflect.DeepEqual(got, []Arn{"arn:aws:s3:::a", "arn:aws:s3:::b"}) {
+		t.Fatalf("unexpected tombstones: %v", got)
+	}
+
+	uv.RemoveTombstone("arn:aws:s3:::a")
+	if uv.IsTombstoned("arn:aws:s3:::a") || uv.NumTombstones() != 1 {
+		t.Fatalf("expected tombstone for 'arn:aws:s3:::a' to be removed")
+	}
+}
+
+func TestUniverse_LookupTombstones(t *testing.T) {
+	base := NewBuilder().
+		WithAccounts(Account{Id: "123456789012"}).
+		WithGroups(Group{Arn: "arn:aws:iam::123456789012:group/g"}).
+		WithPolicies(ManagedPolicy{Arn: "arn:aws:iam::123456789012:policy/p"}).
+		WithPrincipals(
+			Principal{Arn: "arn:aws:iam::123456789012:role/r1"},
+			Principal{Arn: "arn:aws:iam::123456789012:role/r2"},
+		).
+		WithResources(Resource{Arn: "arn:aws:s3:::bucket", Type: "AWS::S3::Bucket"}).
+		Build()
+
+	deletes := NewBuilder().
+		WithTombstones(
+			"123456789012",
+			"arn:aws:iam::123456789012:group/path/g",
+			"arn:aws:iam::123456789012:policy/p",
+			"arn:aws:iam::123456789012:role/r1",
+			"arn:aws:s3:::bucket",
+		).
+		Build()
+
+	// a later layer can restore a tombstoned entity
+	restores := NewBuilder().
+		WithPrincipals(Principal{Arn: "arn:aws:iam::123456789012:role/r1", Type: "restored"}).
+		Build()
+
+	uvs := base.Overlay(deletes)
+	if _, ok, _ := LookupAccount(uvs, "123456789012"); ok {
+		t.Errorf("expected tombstoned account to be absent")
+	}
+	if _, ok, _ := LookupGroup(uvs, "arn:aws:iam::123456789012:group/g"); ok {
+		t.Errorf("expected tombstoned group to be absent")
+	}
+	if _, ok, _ := LookupPolicy(uvs, "arn:aws:iam::123456789012:policy/p"); ok {
+		t.Errorf("expected tombstoned policy to be absent")
+	}
+	if _, ok, _ := LookupPrincipal(uvs, "arn:aws:iam::123456789012:role/r1"); ok {
+		t.Errorf("expected tombstoned principal to be absent")
+	}
+	if _, ok, _ := LookupPrincipal(uvs, "arn:aws:iam::123456789012:role/r2"); !ok {
+		t.Errorf("expected principal without tombstone to be present")
+	}
+	if _, ok, _ := LookupResource(uvs, "arn:aws:s3:::bucket/key"); ok {
+		t.Errorf("expected object within tombstoned bucket to be absent")
+	}
+
+	arns := VisiblePrincipalArns(uvs)
+	if !reflect.DeepEqual(arns, []Arn{"arn:aws:iam::123456789012:role/r2"}) {
+		t.Errorf("unexpected visible principals: %v", arns)
+	}
+	if arns := VisibleResourceArns(uvs); len(arns) != 0 {
+		t.Errorf("unexpected visible resources: %v", arns)
+	}
+
+	uvs = base.Overlay(deletes, restores)
+	p, ok, _ := LookupPrincipal(uvs, "arn:aws:iam::123456789012:role/r1")
+	if !ok || p.Type != "restored" {
+		t.Errorf("expected principal to be restored by a later layer, got %v", p)
+	}
+	if arns := VisiblePrincipalArns(uvs); len(arns) != 2 {
+		t.Errorf("expected 2 visible principals, got %v", arns)
+	}
+}
+
 // -------------------------------------------------------------------------------------------------
 // Arns methods
 // -------------------------------------------------------------------------------------------------
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/bytedance/sonic"
 	json "github.com/bytedance/sonic"
@@ -25,6 +26,27 @@ func NewLoader() *Loader {
 	}
 }
 
+// LoadSource loads the entities of a single source, using the name of the source (such as its path
+// or URL) to choose between JSON and JSONL input
+func LoadSource(name string, reader io.Reader) (*entities.Universe, error) {
+	loader := NewLoader()
+	var err error
+
+	// TODO(nsiow) implement this more accurately, allow non-Config sources
+	if strings.Contains(name, ".jsonl") {
+		err = loader.LoadJsonl(reader)
+	} else if strings.Contains(name, ".json") {
+		err = loader.LoadJson(reader)
+	} else {
+		return nil, fmt.Errorf("unsure what loader to use for source: %s", name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return loader.Universe(), nil
+}
+
 // Universe returns an Universe containing the loaded Principals + Resources
 func (l *Loader) Universe() *entities.Universe {
 	return l.uv
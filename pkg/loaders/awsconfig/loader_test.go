@@ -778,3 +778,36 @@ func TestLoadJson_MalformedJSON(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestLoadSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		input   string
+		wantErr bool
+	}{
+		{name: "json", source: "s3://bucket/config.json", input: `[]`},
+		{name: "jsonl", source: "/tmp/config.jsonl", input: ``},
+		{name: "compressed_jsonl", source: "/tmp/config.jsonl.gz", input: ``},
+		{name: "bad_json", source: "config.json", input: `{`, wantErr: true},
+		{name: "unknown_extension", source: "config.csv", input: `[]`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			uv, err := LoadSource(tc.source, strings.NewReader(tc.input))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got universe of size %d", uv.Size())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if uv.Size() != 0 {
+				t.Fatalf("expected empty universe, got size %d", uv.Size())
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package overlay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+// DefaultCacheTTL is the default duration for which a Cache serves an overlay before re-reading it
+// from the underlying store
+const DefaultCacheTTL = time.Minute
+
+// Cache wraps a Store, caching the universes of stored overlays so that they can be used for
+// simulation without reading them from the store on every request.
+//
+// Updates and deletes made through the Cache invalidate the affected overlays immediately. Changes
+// made by other writers to a shared store (e.g. another server using the same DynamoDB table) are
+// picked up once the cached entry is older than the configured TTL
+type Cache struct {
+	Store
+
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	seq     uint64
+	entries map[string]*cacheEntry
+
+	// generations counts the invalidations of each overlay, so that a read from the store which
+	// began before an invalidation is not cached after it
+	generations map[string]uint64
+}
+
+// cacheEntry is the cached universe of a single overlay
+type cacheEntry struct {
+	universe  *entities.Universe
+	seq       uint64
+	fetched   time.Time
+	expiresAt time.Time
+}
+
+// NewCache creates a new Cache in front of the provided store, re-reading overlays which were last
+// read more than `ttl` ago
+func NewCache(store Store, ttl time.Duration) *Cache {
+	return &Cache{
+		Store:       store,
+		ttl:         ttl,
+		now:         time.Now,
+		entries:     make(map[string]*cacheEntry),
+		generations: make(map[string]uint64),
+	}
+}
+
+// Update replaces an existing overlay, invalidating its cached universe
+func (c *Cache) Update(ctx context.Context, overlay *entities.Overlay) error {
+	defer c.Invalidate(overlay.ID)
+	return c.Store.Update(ctx, overlay)
+}
+
+// Delete removes an overlay by ID, invalidating its cached universe
+func (c *Cache) Delete(ctx context.Context, id string) error {
+	defer c.Invalidate(id)
+	return c.Store.Delete(ctx, id)
+}
+
+// DeleteExpired removes every overlay which has expired as of `now`, invalidating their cached
+// universes
+func (c *Cache) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	deleted, err := c.Store.DeleteExpired(ctx, now)
+	for _, id := range deleted {
+		c.Invalidate(id)
+	}
+	return deleted, err
+}
+
+// Invalidate discards the cached universe of the specified overlay
+func (c *Cache) Invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+	c.generations[id]++
+}
+
+// Universes returns the universes of the specified overlays, in the order provided, for use as
+// simulation layers.
+//
+// Also returns a version string which changes whenever any of the overlays is re-read from the
+// store, suitable for keying anything derived from the universes. The returned universes are shared
+// and must not be modified
+func (c *Cache) Universes(ctx context.Context, ids []string) ([]*entities.Universe, string, error) {
+	if len(ids) == 0 {
+		return nil, "", nil
+	}
+
+	universes := make([]*entities.Universe, len(ids))
+	versions := make([]string, len(ids))
+	for i, id := range ids {
+		entry, err := c.entry(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+
+		universes[i] = entry.universe
+		versions[i] = id + "@" + strconv.FormatUint(entry.seq, 10)
+	}
+
+	return universes, strings.Join(versions, ","), nil
+}
+
+// entry retrieves the cached universe for the specified overlay, reading it from the underlying
+// store if it is missing or older than the TTL. Overlays which have expired since they were cached
+// are not found, as with the underlying store.
+//
+// An overlay which is invalidated while it is being read is returned to the caller, but not cached,
+// as the read may predate the change which caused the invalidation
+func (c *Cache) entry(ctx context.Context, id string) (*cacheEntry, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	generation := c.generations[id]
+	if ok && !entry.expiresAt.IsZero() && !entry.expiresAt.After(now) {
+		delete(c.entries, id)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("unable to load overlay '%s': %w", id, ErrNotFound)
+	}
+	c.mu.Unlock()
+	if ok && now.Sub(entry.fetched) < c.ttl {
+		return entry, nil
+	}
+
+	o, err := c.Store.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load overlay '%s': %w", id, err)
+	}
+
+	universe := o.Universe
+	if universe == nil {
+		universe = entities.NewUniverse()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	entry = &cacheEntry{universe: universe, seq: c.seq, fetched: c.now(), expiresAt: o.ExpiresAt}
+	if c.generations[id] == generation {
+		c.entries[id] = entry
+	}
+
+	return entry, nil
+}
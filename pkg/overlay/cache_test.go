@@ -0,0 +1,288 @@
+package overlay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+// countingStore wraps a Store, counting calls to Get
+type countingStore struct {
+	Store
+	gets int
+}
+
+func (s *countingStore) Get(ctx context.Context, id string) (*entities.Overlay, error) {
+	s.gets++
+	return s.Store.Get(ctx, id)
+}
+
+// blockingStore wraps a Store, pausing its first call to Get after reading the overlay until
+// released
+type blockingStore struct {
+	Store
+	once    sync.Once
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Get(ctx context.Context, id string) (*entities.Overlay, error) {
+	o, err := s.Store.Get(ctx, id)
+	s.once.Do(func() {
+		close(s.read)
+		<-s.release
+	})
+	return o, err
+}
+
+// newTestCache creates a cache over a store containing two overlays, which both define the same
+// principal with different tags
+func newTestCache(t *testing.T, ttl time.Duration) (*Cache, *countingStore, []string) {
+	t.Helper()
+	ctx := context.Background()
+	store := &countingStore{Store: NewMemoryStore()}
+
+	var ids []string
+	for _, name := range []string{"first", "second"} {
+		o := entities.NewOverlay(name)
+		o.Universe.PutPrincipal(entities.Principal{
+			Arn:  "arn:aws:iam::123456789012:role/shared",
+			Tags: []entities.Tag{{Key: "layer", Value: name}},
+		})
+		o.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/" + name})
+		if err := store.Create(ctx, o); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		ids = append(ids, o.ID)
+	}
+
+	return NewCache(store, ttl), store, ids
+}
+
+func TestCache_Universes(t *testing.T) {
+	cache, store, ids := newTestCache(t, time.Minute)
+	ctx := context.Background()
+
+	// no references produce no universes
+	uvs, version, err := cache.Universes(ctx, nil)
+	if uvs != nil || version != "" || err != nil {
+		t.Fatalf("expected empty result, got %v, %q, %v", uvs, version, err)
+	}
+
+	// overlays are served from the cache after the first read
+	first, version, err := cache.Universes(ctx, ids[:1])
+	if err != nil {
+		t.Fatalf("Universes failed: %v", err)
+	}
+	again, againVersion, _ := cache.Universes(ctx, ids[:1])
+	if first[0] != again[0] || version != againVersion {
+		t.Errorf("expected cached universe to be reused")
+	}
+	if store.gets != 1 {
+		t.Errorf("expected 1 read from store, got %d", store.gets)
+	}
+
+	// layers are returned in the order requested
+	tests := []struct {
+		name   string
+		ids    []string
+		layers []string
+	}{
+		{name: "first_then_second", ids: []string{ids[0], ids[1]}, layers: []string{"first", "second"}},
+		{name: "second_then_first", ids: []string{ids[1], ids[0]}, layers: []string{"second", "first"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uvs, version, err := cache.Universes(ctx, tt.ids)
+			if err != nil {
+				t.Fatalf("Universes failed: %v", err)
+			}
+			if len(uvs) != len(tt.layers) {
+				t.Fatalf("expected %d universes, got %d", len(tt.layers), len(uvs))
+			}
+			for i, layer := range tt.layers {
+				if !uvs[i].HasPrincipal(entities.Arn("arn:aws:iam::123456789012:role/" + layer)) {
+					t.Errorf("expected layer %d to be %q", i, layer)
+				}
+			}
+			if !strings.HasPrefix(version, tt.ids[0]+"@") {
+				t.Errorf("expected version to follow layer order, got %q", version)
+			}
+		})
+	}
+
+	if store.gets != 2 {
+		t.Errorf("expected 2 reads from store, got %d", store.gets)
+	}
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	cache, store, ids := newTestCache(t, time.Minute)
+	ctx := context.Background()
+
+	_, version, err := cache.Universes(ctx, ids)
+	if err != nil {
+		t.Fatalf("Universes failed: %v", err)
+	}
+
+	// updates through the cache take effect immediately
+	o, _ := cache.Get(ctx, ids[1])
+	o.Universe.RemovePrincipal("arn:aws:iam::123456789012:role/second")
+	if err := cache.Update(ctx, o); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	uvs, updatedVersion, err := cache.Universes(ctx, ids)
+	if err != nil {
+		t.Fatalf("Universes failed: %v", err)
+	}
+	if updatedVersion == version {
+		t.Errorf("expected version to change after update, got %q", version)
+	}
+	if uvs[1].NumPrincipals() != 1 {
+		t.Errorf("expected 1 principal after update, got %d", uvs[1].NumPrincipals())
+	}
+
+	// deletes through the cache take effect immediately
+	if err := cache.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	_, _, err = cache.Universes(ctx, ids)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+
+	// changes made directly to the store are picked up once the TTL expires
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	_, version, _ = cache.Universes(ctx, ids[1:])
+
+	o, _ = store.Get(ctx, ids[1])
+	o.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/new"})
+	if err := store.Update(ctx, o); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	stale, staleVersion, _ := cache.Universes(ctx, ids[1:])
+	if staleVersion != version || stale[0].NumPrincipals() != 1 {
+		t.Errorf("expected cached universe before TTL expiry")
+	}
+
+	now = now.Add(time.Minute)
+	fresh, freshVersion, _ := cache.Universes(ctx, ids[1:])
+	if freshVersion == version || fresh[0].NumPrincipals() != 2 {
+		t.Errorf("expected refreshed universe after TTL expiry, got %d principals", fresh[0].NumPrincipals())
+	}
+}
+
+func TestCache_ConcurrentInvalidation(t *testing.T) {
+	_, store, ids := newTestCache(t, time.Minute)
+	ctx := context.Background()
+
+	blocking := &blockingStore{
+		Store:   store,
+		read:    make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	cache := NewCache(blocking, time.Minute)
+
+	// a lookup reads the overlay, but has not yet cached it
+	done := make(chan error)
+	go func() {
+		_, _, err := cache.Universes(ctx, ids[:1])
+		done <- err
+	}()
+	<-blocking.read
+
+	// the overlay is updated and invalidated before the lookup caches what it read
+	o, _ := store.Get(ctx, ids[0])
+	o.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/new"})
+	if err := cache.Update(ctx, o); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	close(blocking.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Universes failed: %v", err)
+	}
+
+	uvs, _, err := cache.Universes(ctx, ids[:1])
+	if err != nil {
+		t.Fatalf("Universes failed: %v", err)
+	}
+	if uvs[0].NumPrincipals() != 3 {
+		t.Errorf("expected updated universe after concurrent lookup, got %d principals",
+			uvs[0].NumPrincipals())
+	}
+}
+
+func TestCache_DeleteExpired(t *testing.T) {
+	cache, store, ids := newTestCache(t, time.Minute)
+	ctx := context.Background()
+
+	if _, _, err := cache.Universes(ctx, ids); err != nil {
+		t.Fatalf("Universes failed: %v", err)
+	}
+
+	o, _ := store.Get(ctx, ids[0])
+	o.ExpiresAt = time.Now()
+	if err := store.Update(ctx, o); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// expired overlays stop being served as soon as they are deleted
+	if _, err := cache.DeleteExpired(ctx, time.Now()); err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+	if _, _, err := cache.Universes(ctx, ids); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after expiry, got %v", err)
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	cache, store, ids := newTestCache(t, time.Hour)
+	ctx := context.Background()
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	o, _ := store.Get(ctx, ids[0])
+	o.ExpiresAt = now.Add(time.Minute)
+	if err := store.Update(ctx, o); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, _, err := cache.Universes(ctx, ids); err != nil {
+		t.Fatalf("Universes failed: %v", err)
+	}
+
+	// cached overlays stop being served once they expire, even within the TTL and without a sweep
+	now = now.Add(time.Minute)
+	reads := store.gets
+	if _, _, err := cache.Universes(ctx, ids); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after expiry, got %v", err)
+	}
+	if store.gets != reads {
+		t.Errorf("expected expiry to be detected without reading from store")
+	}
+}
+
+func TestCache_NoTTL(t *testing.T) {
+	cache, store, ids := newTestCache(t, 0)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, _, err := cache.Universes(ctx, ids[:1]); err != nil {
+			t.Fatalf("Universes failed: %v", err)
+		}
+	}
+
+	if store.gets != 3 {
+		t.Errorf("expected every lookup to read from store, got %d reads", store.gets)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +22,10 @@ const (
 	attrName      = "name"
 	attrCreatedAt = "createdAt"
 	attrData      = "data"
+	attrRevision  = "revision"
+	attrRevisions = "revisions"
+	attrParent    = "revisionOf"
+	attrExpiresAt = "expiresAt"
 	nameIndexName = "name-index"
 )
 
@@ -39,12 +44,27 @@ type DynamoDBAdminClient interface {
 	DynamoDBClient
 	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
 	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
 }
 
 // DynamoDBStore is a DynamoDB-backed implementation of the Store interface.
+//
+// Each overlay is stored as a single item holding its current revision and the metadata of every
+// revision. Previous revisions are stored as separate items keyed by "<id>#<revision>", which have
+// no name (and so are excluded from the name index) and are skipped when listing overlays.
+//
+// Overlays with an expiry carry an "expiresAt" attribute (in epoch seconds), on which the store
+// enables DynamoDB's native TTL when it is opened. Native TTL deletes items some time after they
+// expire, so expired overlays are treated as deleted by every read in the meantime.
 type DynamoDBStore struct {
 	client    DynamoDBClient
 	tableName string
+
+	// ScanExpired causes DeleteExpired to scan the whole table, deleting expired overlays and the
+	// previous revisions left behind by native TTL. Otherwise, DeleteExpired does nothing, since
+	// scanning on every sweep is expensive for large tables
+	ScanExpired bool
 }
 
 // NewDynamoDBStore creates a new DynamoDB-backed overlay store.
@@ -85,6 +105,13 @@ func ensureTableExists(ctx context.Context, client DynamoDBAdminClient, tableNam
 	})
 	if err == nil {
 		slog.Info("dynamodb table exists", "table", tableName)
+
+		// Reads hide expired overlays either way, so a table without TTL only costs storage
+		if err := ensureTimeToLive(ctx, client, tableName); err != nil {
+			slog.Warn("unable to enable time to live; expired overlays will not be deleted",
+				"table", tableName,
+				"error", err)
+		}
 		return nil
 	}
 
@@ -122,7 +149,48 @@ func ensureTableExists(ctx context.Context, client DynamoDBAdminClient, tableNam
 
 	// Wait for table to be active
 	slog.Info("waiting for table to become active", "table", tableName)
-	return waitForTableActive(ctx, client, tableName)
+	if err := waitForTableActive(ctx, client, tableName); err != nil {
+		return err
+	}
+
+	return ensureTimeToLive(ctx, client, tableName)
+}
+
+// ensureTimeToLive enables native TTL on the expiresAt attribute of the table, if it is not already
+// enabled, so that expired overlays are deleted by DynamoDB even if no server is sweeping them.
+func ensureTimeToLive(ctx context.Context, client DynamoDBAdminClient, tableName string) error {
+	result, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: &tableName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe time to live: %w", err)
+	}
+
+	if desc := result.TimeToLiveDescription; desc != nil {
+		switch desc.TimeToLiveStatus {
+		case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+			if aws.ToString(desc.AttributeName) != attrExpiresAt {
+				return fmt.Errorf("time to live is enabled on attribute '%s' instead of '%s'",
+					aws.ToString(desc.AttributeName), attrExpiresAt)
+			}
+			return nil
+		case types.TimeToLiveStatusDisabling:
+			return fmt.Errorf("time to live is being disabled")
+		}
+	}
+
+	slog.Info("enabling dynamodb time to live", "table", tableName, "attribute", attrExpiresAt)
+	_, err = client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: &tableName,
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attrExpiresAt),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable time to live: %w", err)
+	}
+	return nil
 }
 
 // waitForTableActive polls until the table status is ACTIVE.
@@ -155,29 +223,36 @@ func waitForTableActive(ctx context.Context, client DynamoDBAdminClient, tableNa
 	return fmt.Errorf("timeout waiting for table %s to become active", tableName)
 }
 
-// Create stores a new overlay. Returns ErrAlreadyExists if an overlay
+// Create stores a new overlay as revision 1. Returns ErrAlreadyExists if an overlay
 // with the same ID already exists.
 func (s *DynamoDBStore) Create(ctx context.Context, overlay *entities.Overlay) error {
-	err := s.putOverlay(ctx, overlay, "attribute_not_exists(id)")
+	stored := *overlay
+	stored.Revision = 1
+	stored.UpdatedAt = overlay.CreatedAt
+
+	revisions := []entities.OverlayRevision{stored.RevisionInfo()}
+	err := s.putOverlay(ctx, &stored, revisions, "attribute_not_exists(id)", nil)
 	if isConditionalCheckFailed(err) {
 		return ErrAlreadyExists
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	overlay.Revision, overlay.UpdatedAt = stored.Revision, stored.UpdatedAt
+	return nil
 }
 
 // Get retrieves an overlay by ID. Returns ErrNotFound if not found.
 func (s *DynamoDBStore) Get(ctx context.Context, id string) (*entities.Overlay, error) {
-	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: &s.tableName,
-		Key:       s.primaryKey(id),
-	})
+	item, err := s.getItem(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get overlay: %w", err)
+		return nil, err
 	}
-	if result.Item == nil {
+	if item == nil {
 		return nil, ErrNotFound
 	}
-	return s.itemToOverlay(result.Item)
+	return unexpired(s.itemToOverlay(item))
 }
 
 // GetByName retrieves an overlay by name using the GSI. Returns ErrNotFound if not found.
@@ -200,24 +275,109 @@ func (s *DynamoDBStore) GetByName(ctx context.Context, name string) (*entities.O
 	if len(result.Items) == 0 {
 		return nil, ErrNotFound
 	}
-	return s.itemToOverlay(result.Items[0])
+	return unexpired(s.itemToOverlay(result.Items[0]))
 }
 
-// Update replaces an existing overlay. Returns ErrNotFound if not found.
+// Update stores a new revision of an existing overlay. Returns ErrNotFound if not found, or
+// ErrConflict if the overlay is not based on the current revision.
 func (s *DynamoDBStore) Update(ctx context.Context, overlay *entities.Overlay) error {
-	err := s.putOverlay(ctx, overlay, "attribute_exists(id)")
-	if isConditionalCheckFailed(err) {
+	item, err := s.getItem(ctx, overlay.ID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
 		return ErrNotFound
 	}
-	return err
+
+	existing, err := s.itemToOverlay(item)
+	if err != nil {
+		return err
+	}
+	revisions, err := s.itemToRevisions(item, existing)
+	if err != nil {
+		return err
+	}
+
+	revision, err := checkRevision(overlay.Revision, existing.Revision)
+	if err != nil {
+		return err
+	}
+
+	// Retain the current revision before replacing it; this is idempotent, so a failed or
+	// conflicting update leaves the history intact
+	if err := s.putRevision(ctx, existing); err != nil {
+		return err
+	}
+
+	stored := *overlay
+	stored.Revision = revision
+	stored.UpdatedAt = time.Now()
+	revisions = append(revisions, stored.RevisionInfo())
+
+	// Only replace the revision which was read above
+	condition, expected := "#rev = :rev", &existing.Revision
+	if _, ok := item[attrRevision]; !ok {
+		condition, expected = "attribute_exists(id) AND attribute_not_exists(#rev)", nil
+	}
+	err = s.putOverlay(ctx, &stored, revisions, condition, expected)
+	if isConditionalCheckFailed(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	overlay.Revision, overlay.UpdatedAt = stored.Revision, stored.UpdatedAt
+	return nil
+}
+
+// Revisions returns the metadata of every revision of an overlay, oldest first.
+func (s *DynamoDBStore) Revisions(ctx context.Context, id string) ([]entities.OverlayRevision, error) {
+	item, err := s.getItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrNotFound
+	}
+
+	current, err := unexpired(s.itemToOverlay(item))
+	if err != nil {
+		return nil, err
+	}
+	return s.itemToRevisions(item, current)
 }
 
-// Delete removes an overlay by ID. Returns ErrNotFound if not found.
+// GetRevision retrieves a specific revision of an overlay.
+func (s *DynamoDBStore) GetRevision(
+	ctx context.Context, id string, revision int) (*entities.Overlay, error) {
+
+	current, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if revision == current.Revision {
+		return current, nil
+	}
+
+	item, err := s.getItem(ctx, revisionKey(id, revision))
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return s.itemToOverlay(item)
+}
+
+// Delete removes an overlay by ID, including its previous revisions. Returns ErrNotFound if not
+// found.
 func (s *DynamoDBStore) Delete(ctx context.Context, id string) error {
-	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+	result, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName:           &s.tableName,
 		Key:                 s.primaryKey(id),
 		ConditionExpression: aws.String("attribute_exists(id)"),
+		ReturnValues:        types.ReturnValueAllOld,
 	})
 	if isConditionalCheckFailed(err) {
 		return ErrNotFound
@@ -225,6 +385,22 @@ func (s *DynamoDBStore) Delete(ctx context.Context, id string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete overlay: %w", err)
 	}
+
+	// Previous revisions are removed on a best-effort basis, since the overlay itself is gone
+	current, err := s.itemToOverlay(result.Attributes)
+	if err != nil {
+		return nil
+	}
+	for revision := 1; revision < current.Revision; revision++ {
+		_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: &s.tableName,
+			Key:       s.primaryKey(revisionKey(id, revision)),
+		})
+		if err != nil {
+			slog.Warn("failed to delete overlay revision", "id", id, "revision", revision,
+				"error", err)
+		}
+	}
 	return nil
 }
 
@@ -233,11 +409,13 @@ func (s *DynamoDBStore) List(ctx context.Context, query string) ([]entities.Over
 	query = strings.ToLower(query)
 	var summaries []entities.OverlaySummary
 	var lastKey map[string]types.AttributeValue
+	now := time.Now()
 
 	for {
 		result, err := s.client.Scan(ctx, &dynamodb.ScanInput{
 			TableName:         &s.tableName,
 			ExclusiveStartKey: lastKey,
+			FilterExpression:  aws.String("attribute_not_exists(" + attrParent + ")"),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan overlays: %w", err)
@@ -254,6 +432,9 @@ func (s *DynamoDBStore) List(ctx context.Context, query string) ([]entities.Over
 			if query != "" && !strings.Contains(strings.ToLower(overlay.Name), query) {
 				continue
 			}
+			if overlay.Expired(now) {
+				continue
+			}
 
 			summaries = append(summaries, overlay.Summary())
 		}
@@ -272,12 +453,104 @@ func (s *DynamoDBStore) Exists(ctx context.Context, id string) (bool, error) {
 	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName:            &s.tableName,
 		Key:                  s.primaryKey(id),
-		ProjectionExpression: aws.String(attrID),
+		ProjectionExpression: aws.String("#id, #exp"),
+		ExpressionAttributeNames: map[string]string{
+			"#id":  attrID,
+			"#exp": attrExpiresAt,
+		},
 	})
 	if err != nil {
 		return false, fmt.Errorf("failed to check overlay existence: %w", err)
 	}
-	return result.Item != nil, nil
+	return result.Item != nil && !itemExpired(result.Item, time.Now()), nil
+}
+
+// DeleteExpired removes every overlay which has expired as of `now`, along with any previous
+// revisions left behind by overlays which were deleted using native TTL. Does nothing unless
+// ScanExpired is set, leaving expired overlays to native TTL.
+func (s *DynamoDBStore) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	if !s.ScanExpired {
+		return nil, nil
+	}
+
+	current := make(map[string]bool)
+	var expired []string
+	orphans := make(map[string][]string) // overlay ID -> revision item IDs
+	var lastKey map[string]types.AttributeValue
+
+	for {
+		result, err := s.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:            &s.tableName,
+			ExclusiveStartKey:    lastKey,
+			ProjectionExpression: aws.String("#id, #parent, #exp"),
+			ExpressionAttributeNames: map[string]string{
+				"#id":     attrID,
+				"#parent": attrParent,
+				"#exp":    attrExpiresAt,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan overlays: %w", err)
+		}
+
+		for _, item := range result.Items {
+			id, ok := item[attrID].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			if parent, ok := item[attrParent].(*types.AttributeValueMemberS); ok {
+				orphans[parent.Value] = append(orphans[parent.Value], id.Value)
+				continue
+			}
+
+			current[id.Value] = true
+			if itemExpired(item, now) {
+				expired = append(expired, id.Value)
+			}
+		}
+
+		lastKey = result.LastEvaluatedKey
+		if lastKey == nil {
+			break
+		}
+	}
+
+	var deleted []string
+	for _, id := range expired {
+		err := s.Delete(ctx, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return deleted, err
+		}
+		deleted = append(deleted, id)
+		delete(orphans, id)
+	}
+
+	for parent, ids := range orphans {
+		if current[parent] {
+			continue
+		}
+		for _, id := range ids {
+			_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: &s.tableName,
+				Key:       s.primaryKey(id),
+			})
+			if err != nil {
+				return deleted, fmt.Errorf("failed to delete overlay revision: %w", err)
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// itemExpired returns whether the expiresAt attribute of an item has passed as of `now`.
+func itemExpired(item map[string]types.AttributeValue, now time.Time) bool {
+	expiresAt, ok := item[attrExpiresAt].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	seconds, err := strconv.ParseInt(expiresAt.Value, 10, 64)
+	return err == nil && seconds <= now.Unix()
 }
 
 // primaryKey returns the DynamoDB key for an overlay ID.
@@ -287,29 +560,97 @@ func (s *DynamoDBStore) primaryKey(id string) map[string]types.AttributeValue {
 	}
 }
 
-// putOverlay marshals and stores an overlay with the given condition expression.
-func (s *DynamoDBStore) putOverlay(ctx context.Context, overlay *entities.Overlay, condition string) error {
+// revisionKey returns the item ID under which a previous revision of an overlay is stored.
+func revisionKey(id string, revision int) string {
+	return id + "#" + strconv.Itoa(revision)
+}
+
+// getItem retrieves the item with the given ID, returning nil if it does not exist.
+func (s *DynamoDBStore) getItem(ctx context.Context, id string) (map[string]types.AttributeValue, error) {
+	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &s.tableName,
+		Key:       s.primaryKey(id),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overlay: %w", err)
+	}
+	return result.Item, nil
+}
+
+// putOverlay marshals and stores the current revision of an overlay with the given condition
+// expression. If expected is non-nil, it is bound to :rev in the condition.
+func (s *DynamoDBStore) putOverlay(
+	ctx context.Context,
+	overlay *entities.Overlay,
+	revisions []entities.OverlayRevision,
+	condition string,
+	expected *int) error {
+
 	dataJSON, err := json.Marshal(overlay.ToData())
 	if err != nil {
 		return fmt.Errorf("failed to marshal overlay: %w", err)
 	}
+	revisionsJSON, err := json.Marshal(revisions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal overlay revisions: %w", err)
+	}
 
-	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+	input := &dynamodb.PutItemInput{
 		TableName: &s.tableName,
 		Item: map[string]types.AttributeValue{
 			attrID:        &types.AttributeValueMemberS{Value: overlay.ID},
 			attrName:      &types.AttributeValueMemberS{Value: overlay.Name},
 			attrCreatedAt: &types.AttributeValueMemberS{Value: overlay.CreatedAt.Format(time.RFC3339Nano)},
 			attrData:      &types.AttributeValueMemberS{Value: string(dataJSON)},
+			attrRevision:  &types.AttributeValueMemberN{Value: strconv.Itoa(overlay.Revision)},
+			attrRevisions: &types.AttributeValueMemberS{Value: string(revisionsJSON)},
 		},
 		ConditionExpression: aws.String(condition),
-	})
+	}
+	if !overlay.ExpiresAt.IsZero() {
+		input.Item[attrExpiresAt] = &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(overlay.ExpiresAt.Unix(), 10),
+		}
+	}
+	if strings.Contains(condition, "#rev") {
+		input.ExpressionAttributeNames = map[string]string{"#rev": attrRevision}
+	}
+	if expected != nil {
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":rev": &types.AttributeValueMemberN{Value: strconv.Itoa(*expected)},
+		}
+	}
+
+	_, err = s.client.PutItem(ctx, input)
 	if err != nil && !isConditionalCheckFailed(err) {
 		return fmt.Errorf("failed to put overlay: %w", err)
 	}
 	return err
 }
 
+// putRevision stores a copy of the provided revision of an overlay, to be retained once it is
+// replaced by a later revision.
+func (s *DynamoDBStore) putRevision(ctx context.Context, overlay *entities.Overlay) error {
+	dataJSON, err := json.Marshal(overlay.ToData())
+	if err != nil {
+		return fmt.Errorf("failed to marshal overlay: %w", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &s.tableName,
+		Item: map[string]types.AttributeValue{
+			attrID:       &types.AttributeValueMemberS{Value: revisionKey(overlay.ID, overlay.Revision)},
+			attrParent:   &types.AttributeValueMemberS{Value: overlay.ID},
+			attrRevision: &types.AttributeValueMemberN{Value: strconv.Itoa(overlay.Revision)},
+			attrData:     &types.AttributeValueMemberS{Value: string(dataJSON)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put overlay revision: %w", err)
+	}
+	return nil
+}
+
 // itemToOverlay converts a DynamoDB item to an Overlay.
 func (s *DynamoDBStore) itemToOverlay(item map[string]types.AttributeValue) (*entities.Overlay, error) {
 	dataVal, exists := item[attrData]
@@ -326,9 +667,33 @@ func (s *DynamoDBStore) itemToOverlay(item map[string]types.AttributeValue) (*en
 		return nil, fmt.Errorf("failed to unmarshal overlay data: %w", err)
 	}
 
+	// Overlays stored before revisions were tracked are treated as revision 1
+	if data.Revision == 0 {
+		data.Revision = 1
+		data.UpdatedAt = data.CreatedAt
+	}
+
 	return entities.FromData(data), nil
 }
 
+// itemToRevisions returns the revision metadata stored alongside the current revision of an
+// overlay.
+func (s *DynamoDBStore) itemToRevisions(
+	item map[string]types.AttributeValue,
+	current *entities.Overlay) ([]entities.OverlayRevision, error) {
+
+	attr, ok := item[attrRevisions].(*types.AttributeValueMemberS)
+	if !ok {
+		return []entities.OverlayRevision{current.RevisionInfo()}, nil
+	}
+
+	var revisions []entities.OverlayRevision
+	if err := json.Unmarshal([]byte(attr.Value), &revisions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal overlay revisions: %w", err)
+	}
+	return revisions, nil
+}
+
 // isConditionalCheckFailed checks if the error is a conditional check failure.
 func isConditionalCheckFailed(err error) bool {
 	var ccf *types.ConditionalCheckFailedException
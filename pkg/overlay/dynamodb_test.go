@@ -2,7 +2,10 @@ package overlay
 
 import (
 	"context"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -37,6 +40,16 @@ func (m *mockDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutIt
 			if _, exists := m.items[id]; !exists {
 				return nil, &types.ConditionalCheckFailedException{Message: aws.String("condition failed")}
 			}
+		} else if expr == "#rev = :rev" {
+			existing, exists := m.items[id]
+			if !exists || !reflect.DeepEqual(existing[attrRevision], params.ExpressionAttributeValues[":rev"]) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("condition failed")}
+			}
+		} else if expr == "attribute_exists(id) AND attribute_not_exists(#rev)" {
+			existing, exists := m.items[id]
+			if !exists || existing[attrRevision] != nil {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("condition failed")}
+			}
 		}
 	}
 
@@ -67,8 +80,13 @@ func (m *mockDynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.De
 		}
 	}
 
+	output := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = m.items[id]
+	}
+
 	delete(m.items, id)
-	return &dynamodb.DeleteItemOutput{}, nil
+	return output, nil
 }
 
 func (m *mockDynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
@@ -77,8 +95,8 @@ func (m *mockDynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryIn
 
 	var items []map[string]types.AttributeValue
 	for _, item := range m.items {
-		nameAttr := item[attrName].(*types.AttributeValueMemberS)
-		if nameAttr.Value == nameValue {
+		nameAttr, ok := item[attrName].(*types.AttributeValueMemberS)
+		if ok && nameAttr.Value == nameValue {
 			items = append(items, item)
 			break
 		}
@@ -90,6 +108,10 @@ func (m *mockDynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryIn
 func (m *mockDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
 	var items []map[string]types.AttributeValue
 	for _, item := range m.items {
+		// Only the filter used to skip previous revisions is supported
+		if params.FilterExpression != nil && item[attrParent] != nil {
+			continue
+		}
 		items = append(items, item)
 	}
 	return &dynamodb.ScanOutput{Items: items}, nil
@@ -202,6 +224,218 @@ func TestDynamoDBStore_Update(t *testing.T) {
 	}
 }
 
+func TestDynamoDBStore_Revisions(t *testing.T) {
+	client := newMockClient()
+	testStoreRevisions(t, NewDynamoDBStoreWithClient(client, "test-table"))
+
+	// previous revisions are deleted along with the overlay
+	if len(client.items) != 0 {
+		t.Errorf("expected no items after delete, got %d", len(client.items))
+	}
+}
+
+func TestDynamoDBStore_LegacyItem(t *testing.T) {
+	client := newMockClient()
+	store := NewDynamoDBStoreWithClient(client, "test-table")
+	ctx := context.Background()
+
+	// items written before revisions were tracked have neither revision attributes nor a revision
+	// in their data
+	overlay := entities.NewOverlay("legacy")
+	dataJSON, _ := json.Marshal(overlay.ToData())
+	client.items[overlay.ID] = map[string]types.AttributeValue{
+		attrID:   &types.AttributeValueMemberS{Value: overlay.ID},
+		attrName: &types.AttributeValueMemberS{Value: overlay.Name},
+		attrData: &types.AttributeValueMemberS{Value: string(dataJSON)},
+	}
+
+	revisions, err := store.Revisions(ctx, overlay.ID)
+	if err != nil || len(revisions) != 1 || revisions[0].Revision != 1 {
+		t.Fatalf("expected a single revision for legacy item, got %v (err = %v)", revisions, err)
+	}
+
+	legacy, _ := store.Get(ctx, overlay.ID)
+	if err := store.Update(ctx, legacy); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if legacy.Revision != 2 {
+		t.Errorf("expected revision 2 after updating legacy item, got %d", legacy.Revision)
+	}
+	if _, err := store.GetRevision(ctx, overlay.ID, 1); err != nil {
+		t.Errorf("expected legacy revision to be retained, got %v", err)
+	}
+}
+
+func TestDynamoDBStore_Expiry(t *testing.T) {
+	client := newMockClient()
+	store := NewDynamoDBStoreWithClient(client, "test-table")
+	store.ScanExpired = true
+	ctx := context.Background()
+	now := time.Now()
+
+	// the expiry is stored in epoch seconds, for use by native TTL
+	overlay := entities.NewOverlay("expiring")
+	overlay.ExpiresAt = now.Add(-time.Minute)
+	_ = store.Create(ctx, overlay)
+	attr, ok := client.items[overlay.ID][attrExpiresAt].(*types.AttributeValueMemberN)
+	if !ok || attr.Value != strconv.FormatInt(overlay.ExpiresAt.Unix(), 10) {
+		t.Errorf("expected expiresAt attribute, got %v", client.items[overlay.ID][attrExpiresAt])
+	}
+
+	permanent := entities.NewOverlay("permanent")
+	_ = store.Create(ctx, permanent)
+	if _, ok := client.items[permanent.ID][attrExpiresAt]; ok {
+		t.Errorf("expected no expiresAt attribute for overlay without expiry")
+	}
+
+	// previous revisions left behind by native TTL are removed
+	orphan := entities.NewOverlay("orphan")
+	_ = store.Create(ctx, orphan)
+	_ = store.Update(ctx, orphan)
+	delete(client.items, orphan.ID)
+
+	deleted, err := store.DeleteExpired(ctx, now)
+	if err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != overlay.ID {
+		t.Errorf("expected %q to be deleted, got %v", overlay.ID, deleted)
+	}
+	if len(client.items) != 1 || client.items[permanent.ID] == nil {
+		t.Errorf("expected only the permanent overlay to remain, got %d items", len(client.items))
+	}
+}
+
+func TestDynamoDBStore_DeleteExpiredWithoutScan(t *testing.T) {
+	client := &scanCountingClient{mockDynamoDBClient: newMockClient()}
+	store := NewDynamoDBStoreWithClient(client, "test-table")
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("expired")
+	overlay.ExpiresAt = time.Now().Add(-time.Minute)
+	_ = store.Create(ctx, overlay)
+
+	// expired overlays are left to native TTL unless scanning is enabled
+	deleted, err := store.DeleteExpired(ctx, time.Now())
+	if err != nil || len(deleted) != 0 {
+		t.Fatalf("expected nothing to be deleted, got %v (%v)", deleted, err)
+	}
+	if client.scans != 0 {
+		t.Errorf("expected no scans, got %d", client.scans)
+	}
+	if _, err := store.Get(ctx, overlay.ID); err != ErrNotFound {
+		t.Errorf("expected expired overlay to be hidden, got %v", err)
+	}
+}
+
+// scanCountingClient wraps the mock client, counting calls to Scan
+type scanCountingClient struct {
+	*mockDynamoDBClient
+	scans int
+}
+
+func (c *scanCountingClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	c.scans++
+	return c.mockDynamoDBClient.Scan(ctx, params, optFns...)
+}
+
+// mockAdminClient implements DynamoDBAdminClient for an existing table, recording TTL updates
+type mockAdminClient struct {
+	*mockDynamoDBClient
+	ttl     *types.TimeToLiveDescription
+	updates []*types.TimeToLiveSpecification
+}
+
+func (m *mockAdminClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{
+		Table: &types.TableDescription{TableStatus: types.TableStatusActive},
+	}, nil
+}
+
+func (m *mockAdminClient) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (m *mockAdminClient) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: m.ttl}, nil
+}
+
+func (m *mockAdminClient) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	m.updates = append(m.updates, params.TimeToLiveSpecification)
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func TestEnsureTimeToLive(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     *types.TimeToLiveDescription
+		updated bool
+		wantErr bool
+	}{
+		{
+			name:    "missing",
+			updated: true,
+		},
+		{
+			name: "disabled",
+			ttl: &types.TimeToLiveDescription{
+				TimeToLiveStatus: types.TimeToLiveStatusDisabled,
+			},
+			updated: true,
+		},
+		{
+			name: "enabled",
+			ttl: &types.TimeToLiveDescription{
+				TimeToLiveStatus: types.TimeToLiveStatusEnabled,
+				AttributeName:    aws.String(attrExpiresAt),
+			},
+		},
+		{
+			name: "enabling",
+			ttl: &types.TimeToLiveDescription{
+				TimeToLiveStatus: types.TimeToLiveStatusEnabling,
+				AttributeName:    aws.String(attrExpiresAt),
+			},
+		},
+		{
+			name: "other_attribute",
+			ttl: &types.TimeToLiveDescription{
+				TimeToLiveStatus: types.TimeToLiveStatusEnabled,
+				AttributeName:    aws.String("ttl"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "disabling",
+			ttl: &types.TimeToLiveDescription{
+				TimeToLiveStatus: types.TimeToLiveStatusDisabling,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockAdminClient{mockDynamoDBClient: newMockClient(), ttl: tt.ttl}
+			err := ensureTimeToLive(context.Background(), client, "test-table")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error = %v, got %v", tt.wantErr, err)
+			}
+			if tt.updated != (len(client.updates) == 1) {
+				t.Fatalf("expected update = %v, got %v", tt.updated, client.updates)
+			}
+			if tt.updated && aws.ToString(client.updates[0].AttributeName) != attrExpiresAt {
+				t.Errorf("expected TTL on %q, got %v", attrExpiresAt, client.updates[0])
+			}
+
+			// existing tables are opened whether or not TTL could be enabled
+			if err := ensureTableExists(context.Background(), client, "test-table"); err != nil {
+				t.Errorf("expected existing table to be opened, got %v", err)
+			}
+		})
+	}
+}
+
 func TestDynamoDBStore_Delete(t *testing.T) {
 	client := newMockClient()
 	store := NewDynamoDBStoreWithClient(client, "test-table")
@@ -0,0 +1,208 @@
+package overlay
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	arnlib "github.com/nsiow/yams/pkg/arn"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+// Formats supported by Export
+const (
+	// ExportTerraform renders HCL for the Terraform AWS provider
+	ExportTerraform = "terraform"
+
+	// ExportCloudFormation renders a CloudFormation template in JSON
+	ExportCloudFormation = "cloudformation"
+
+	// ExportAWSConfig renders JSONL of AWS Config items, which can be loaded back as a yams source
+	ExportAWSConfig = "awsconfig"
+)
+
+// ExportFormats lists the formats supported by Export
+var ExportFormats = []string{ExportTerraform, ExportCloudFormation, ExportAWSConfig}
+
+// exportJSON encodes exported documents with sorted keys, so that output is stable between runs
+var exportJSON = sonic.Config{SortMapKeys: true}.Froze()
+
+// Export renders the groups, principals, managed policies and resource policies of an overlay in the
+// provided format, as a starting point for translating it back into infrastructure code.
+//
+// Entities which have no equivalent in the format, as well as tombstones and patches, are listed as
+// comments (terraform) or template metadata (cloudformation), and are omitted from awsconfig output
+func Export(data entities.OverlayData, format string) ([]byte, error) {
+	e := newExporter(data)
+	switch format {
+	case ExportTerraform:
+		return e.terraform()
+	case ExportCloudFormation:
+		return e.cloudFormation()
+	case ExportAWSConfig:
+		return e.awsConfig()
+	default:
+		return nil, fmt.Errorf("unsupported export format '%s', must be one of: %s",
+			format, strings.Join(ExportFormats, ", "))
+	}
+}
+
+// exporter holds the entities of an overlay in a stable order, along with the lookups shared by
+// each of the export formats
+type exporter struct {
+	data       entities.OverlayData
+	accounts   []entities.Account
+	groups     []entities.Group
+	policies   []entities.ManagedPolicy
+	principals []entities.Principal
+	resources  []entities.Resource
+
+	// trust maps role ARNs to their trust policies, which are represented as resource policies
+	trust map[entities.Arn]policy.Policy
+
+	// defined tracks the ARNs of the IAM entities defined by the overlay
+	defined map[entities.Arn]bool
+
+	// notes lists entities which could not be exported
+	notes []string
+}
+
+func newExporter(data entities.OverlayData) *exporter {
+	e := &exporter{
+		data:       data,
+		accounts:   slices.Clone(data.Accounts),
+		groups:     slices.Clone(data.Groups),
+		policies:   slices.Clone(data.Policies),
+		principals: slices.Clone(data.Principals),
+		resources:  slices.Clone(data.Resources),
+		trust:      map[entities.Arn]policy.Policy{},
+		defined:    map[entities.Arn]bool{},
+	}
+
+	slices.SortFunc(e.accounts, func(a, b entities.Account) int { return cmp.Compare(a.Id, b.Id) })
+	slices.SortFunc(e.groups, func(a, b entities.Group) int { return cmp.Compare(a.Arn, b.Arn) })
+	slices.SortFunc(e.policies, func(a, b entities.ManagedPolicy) int {
+		return cmp.Compare(a.Arn, b.Arn)
+	})
+	slices.SortFunc(e.principals, func(a, b entities.Principal) int {
+		return cmp.Compare(a.Arn, b.Arn)
+	})
+	slices.SortFunc(e.resources, func(a, b entities.Resource) int {
+		return cmp.Compare(a.Arn, b.Arn)
+	})
+
+	for _, g := range e.groups {
+		e.defined[g.Arn] = true
+	}
+	for _, p := range e.policies {
+		e.defined[p.Arn] = true
+	}
+	for _, p := range e.principals {
+		e.defined[p.Arn] = true
+	}
+	for _, r := range e.resources {
+		if r.Type == "AWS::IAM::Role" && !r.Policy.Empty() {
+			e.trust[r.Arn] = r.Policy
+		}
+	}
+
+	for _, arn := range data.Tombstones {
+		e.note("tombstone for %s cannot be exported; delete it separately", arn)
+	}
+	for _, p := range data.Patches {
+		e.note("patch for %s cannot be exported; apply it to the exported entity", p.Arn)
+	}
+
+	return e
+}
+
+// note records an entity which could not be exported
+func (e *exporter) note(format string, args ...any) {
+	e.notes = append(e.notes, fmt.Sprintf(format, args...))
+}
+
+// isIAMResource reports whether a resource mirrors an IAM entity, whose resource policy (if any) is
+// exported as part of the entity itself
+func isIAMResource(r entities.Resource) bool {
+	return strings.HasPrefix(r.Type, "AWS::IAM::")
+}
+
+// isOrgPolicy reports whether a managed policy is an SCP or RCP rather than an IAM policy
+func isOrgPolicy(p entities.ManagedPolicy) bool {
+	return strings.Contains(p.Type, "::Organizations::")
+}
+
+// orgPolicyType returns the AWS Organizations policy type of an SCP or RCP
+func orgPolicyType(p entities.ManagedPolicy) string {
+	if strings.HasSuffix(p.Type, "ResourceControlPolicy") {
+		return "RESOURCE_CONTROL_POLICY"
+	}
+	return "SERVICE_CONTROL_POLICY"
+}
+
+// iamName returns the name of an IAM entity, falling back to the last segment of its ARN
+func iamName(name string, arn entities.Arn) string {
+	if name != "" {
+		return name
+	}
+	segment := arnlib.ResourceSegment(arn)
+	return segment[strings.LastIndex(segment, "/")+1:]
+}
+
+// iamPath returns the path of an IAM entity from its ARN, e.g. "/service-role/"
+func iamPath(arn entities.Arn) string {
+	segments := strings.Split(arnlib.ResourceSegment(arn), "/")
+	if len(segments) <= 2 {
+		return "/"
+	}
+	return "/" + strings.Join(segments[1:len(segments)-1], "/") + "/"
+}
+
+// document returns the JSON representation of a policy document as used by AWS, without the
+// inline policy name stored alongside it
+func document(p policy.Policy) policy.Policy {
+	p.Name = ""
+	if p.Version == "" {
+		p.Version = "2012-10-17"
+	}
+	if p.Statement == nil {
+		p.Statement = []policy.Statement{}
+	}
+	return p
+}
+
+// queueURL derives the URL of an SQS queue from its ARN
+func queueURL(arn entities.Arn) string {
+	return fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s",
+		arnlib.Region(arn), arnlib.Account(arn), arnlib.ResourceSegment(arn))
+}
+
+// identifiers allocates unique identifiers for exported entities, as required by each format
+type identifiers struct {
+	invalid *regexp.Regexp
+	used    map[string]bool
+}
+
+func newIdentifiers(invalid string) *identifiers {
+	return &identifiers{invalid: regexp.MustCompile(invalid), used: map[string]bool{}}
+}
+
+// next returns an identifier derived from `name`, with invalid characters removed and a numeric
+// suffix added if it has already been used
+func (ids *identifiers) next(name string) string {
+	base := ids.invalid.ReplaceAllString(name, "")
+	if base == "" {
+		base = "entity"
+	}
+
+	id := base
+	for i := 2; ids.used[id]; i++ {
+		id = fmt.Sprintf("%s%d", base, i)
+	}
+	ids.used[id] = true
+	return id
+}
@@ -0,0 +1,248 @@
+package overlay
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+
+	"github.com/nsiow/yams/internal/common"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/loaders/awsconfig"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+// configItem is an AWS Config item, in the form read by the awsconfig loader
+type configItem struct {
+	Type                       string       `json:"resourceType"`
+	Name                       string       `json:"resourceName"`
+	AccountId                  string       `json:"accountId"`
+	Region                     string       `json:"awsRegion,omitempty"`
+	Arn                        entities.Arn `json:"arn"`
+	Tags                       []configTag  `json:"tags,omitempty"`
+	Configuration              any          `json:"configuration,omitempty"`
+	SupplementaryConfiguration any          `json:"supplementaryConfiguration,omitempty"`
+}
+
+type configTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type configPolicyRef struct {
+	Arn  string `json:"policyArn"`
+	Name string `json:"policyName"`
+}
+
+type configBoundary struct {
+	Arn  string `json:"permissionsBoundaryArn"`
+	Name string `json:"permissionsBoundaryName"`
+}
+
+type configInlinePolicy struct {
+	Name     string `json:"policyName"`
+	Document string `json:"policyDocument"`
+}
+
+// awsConfig renders the overlay as JSONL of AWS Config items
+func (e *exporter) awsConfig() ([]byte, error) {
+	var items []configItem
+	for _, a := range e.accounts {
+		items = append(items, accountItem(a))
+	}
+	for _, p := range e.policies {
+		items = append(items, policyItem(p))
+	}
+	for _, g := range e.groups {
+		items = append(items, groupItem(g))
+	}
+	for _, p := range e.principals {
+		items = append(items, principalItem(p, e.trust))
+	}
+	for _, r := range e.resources {
+		switch {
+		case r.Type == "AWS::IAM::Role" && !e.defined[r.Arn]:
+			// a trust policy for a role defined elsewhere
+			items = append(items, principalItem(entities.Principal{
+				Type:      r.Type,
+				AccountId: r.AccountId,
+				Name:      r.Name,
+				Arn:       r.Arn,
+				Tags:      r.Tags,
+			}, e.trust))
+		case !isIAMResource(r):
+			items = append(items, resourceItem(r))
+		}
+	}
+
+	var out bytes.Buffer
+	for _, item := range items {
+		b, err := exportJSON.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode item for %s: %w", item.Arn, err)
+		}
+		out.Write(b)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// encodePolicy URL-encodes a policy document, as AWS Config does for IAM policies
+func encodePolicy(p policy.Policy) string {
+	b, err := exportJSON.Marshal(document(p))
+	if err != nil {
+		// policies consist only of strings, so can always be encoded
+		return ""
+	}
+	return url.QueryEscape(string(b))
+}
+
+func configTags(tags []entities.Tag) []configTag {
+	return common.Map(tags, func(t entities.Tag) configTag {
+		return configTag{Key: t.Key, Value: t.Value}
+	})
+}
+
+func configPolicyRefs(arns []entities.Arn) []configPolicyRef {
+	out := make([]configPolicyRef, 0, len(arns))
+	for _, arn := range arns {
+		out = append(out, configPolicyRef{Arn: arn, Name: iamName("", arn)})
+	}
+	return out
+}
+
+func configInlinePolicies(policies []policy.Policy) []configInlinePolicy {
+	out := make([]configInlinePolicy, 0, len(policies))
+	for i, p := range policies {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("inline-%d", i+1)
+		}
+		out = append(out, configInlinePolicy{Name: name, Document: encodePolicy(p)})
+	}
+	return out
+}
+
+func accountItem(a entities.Account) configItem {
+	return configItem{
+		Type:      awsconfig.CONST_TYPE_YAMS_ORGANIZATIONS_ACCOUNT,
+		Name:      a.Name,
+		AccountId: a.Id,
+		Configuration: awsconfig.AccountConfiguration{
+			Name:     a.Name,
+			OrgId:    a.OrgId,
+			OrgPaths: a.OrgPaths,
+			OrgNodes: common.Map(a.OrgNodes, func(n entities.OrgNode) awsconfig.OrgNode {
+				return awsconfig.OrgNode{
+					Id:   n.Id,
+					Type: n.Type,
+					Arn:  n.Arn,
+					Name: n.Name,
+					SCPs: common.Map(n.SCPs, func(ref entities.OrgPolicyRef) string { return ref.Arn }),
+					RCPs: common.Map(n.RCPs, func(ref entities.OrgPolicyRef) string { return ref.Arn }),
+				}
+			}),
+		},
+	}
+}
+
+func policyItem(p entities.ManagedPolicy) configItem {
+	item := configItem{
+		Type:      p.Type,
+		Name:      iamName(p.Name, p.Arn),
+		AccountId: p.AccountId,
+		Region:    "global",
+		Arn:       p.Arn,
+	}
+	if isOrgPolicy(p) {
+		item.Configuration = map[string]any{"document": encodePolicy(p.Policy)}
+		return item
+	}
+
+	item.Type = awsconfig.CONST_TYPE_AWS_IAM_POLICY
+	item.Configuration = map[string]any{
+		"policyVersionList": []map[string]any{{
+			"versionId":        "v1",
+			"isDefaultVersion": true,
+			"document":         encodePolicy(p.Policy),
+		}},
+	}
+	return item
+}
+
+func groupItem(g entities.Group) configItem {
+	return configItem{
+		Type:      awsconfig.CONST_TYPE_AWS_IAM_GROUP,
+		Name:      iamName(g.Name, g.Arn),
+		AccountId: g.AccountId,
+		Region:    "global",
+		Arn:       g.Arn,
+		Configuration: map[string]any{
+			"attachedManagedPolicies": configPolicyRefs(g.AttachedPolicies),
+			"groupPolicyList":         configInlinePolicies(g.InlinePolicies),
+		},
+	}
+}
+
+func principalItem(p entities.Principal, trust map[entities.Arn]policy.Policy) configItem {
+	configuration := map[string]any{
+		"attachedManagedPolicies": configPolicyRefs(p.AttachedPolicies),
+	}
+	if p.PermissionsBoundary != "" {
+		configuration["permissionsBoundary"] = configBoundary{
+			Arn:  p.PermissionsBoundary,
+			Name: iamName("", p.PermissionsBoundary),
+		}
+	}
+
+	switch p.Type {
+	case awsconfig.CONST_TYPE_AWS_IAM_USER:
+		configuration["userPolicyList"] = configInlinePolicies(p.InlinePolicies)
+		groups := make([]string, 0, len(p.Groups))
+		for _, arn := range p.Groups {
+			groups = append(groups, iamName("", arn))
+		}
+		configuration["groupList"] = groups
+	default:
+		configuration["rolePolicyList"] = configInlinePolicies(p.InlinePolicies)
+		if doc, ok := trust[p.Arn]; ok {
+			configuration["assumeRolePolicyDocument"] = encodePolicy(doc)
+		}
+	}
+
+	return configItem{
+		Type:          p.Type,
+		Name:          iamName(p.Name, p.Arn),
+		AccountId:     p.AccountId,
+		Region:        "global",
+		Arn:           p.Arn,
+		Tags:          configTags(p.Tags),
+		Configuration: configuration,
+	}
+}
+
+func resourceItem(r entities.Resource) configItem {
+	item := configItem{
+		Type:      r.Type,
+		Name:      r.Name,
+		AccountId: r.AccountId,
+		Region:    r.Region,
+		Arn:       r.Arn,
+		Tags:      configTags(r.Tags),
+	}
+	if r.Policy.Empty() {
+		return item
+	}
+
+	doc := encodePolicy(r.Policy)
+	switch r.Type {
+	case awsconfig.CONST_TYPE_AWS_S3_BUCKET:
+		item.SupplementaryConfiguration = map[string]any{
+			"BucketPolicy": map[string]any{"policyText": doc},
+		}
+	case awsconfig.CONST_TYPE_AWS_SNS_TOPIC, awsconfig.CONST_TYPE_AWS_SQS_QUEUE:
+		item.Configuration = map[string]any{"Policy": doc}
+	default:
+		item.SupplementaryConfiguration = map[string]any{"Policy": doc}
+	}
+	return item
+}
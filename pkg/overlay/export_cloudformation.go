@@ -0,0 +1,229 @@
+package overlay
+
+import (
+	"fmt"
+
+	arnlib "github.com/nsiow/yams/pkg/arn"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+// cfnResource is a resource within a CloudFormation template
+type cfnResource struct {
+	Type       string         `json:"Type"`
+	Properties map[string]any `json:"Properties"`
+}
+
+// cloudFormation renders the overlay as a CloudFormation template
+func (e *exporter) cloudFormation() ([]byte, error) {
+	cfn := cfnWriter{
+		ids:       newIdentifiers(`[^A-Za-z0-9]`),
+		resources: map[string]cfnResource{},
+		refs:      map[entities.Arn]any{},
+	}
+
+	for _, p := range e.policies {
+		cfn.policy(p)
+	}
+	for _, g := range e.groups {
+		cfn.group(g)
+	}
+	for _, p := range e.principals {
+		switch p.Type {
+		case "AWS::IAM::Role":
+			trust, ok := e.trust[p.Arn]
+			if !ok {
+				e.note("the overlay does not define a trust policy for role %s", p.Arn)
+			}
+			cfn.role(p, trust)
+		case "AWS::IAM::User":
+			cfn.user(p)
+		default:
+			e.note("principal %s of type '%s' cannot be exported", p.Arn, p.Type)
+		}
+	}
+	for _, r := range e.resources {
+		if isIAMResource(r) || r.Policy.Empty() {
+			continue
+		}
+		if !cfn.resourcePolicy(r) {
+			e.note("resource policy of %s (%s) cannot be exported", r.Arn, r.Type)
+		}
+	}
+
+	description := fmt.Sprintf("Generated by yams from overlay '%s' (%s)", e.data.Name, e.data.ID)
+	template := map[string]any{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              description,
+		"Resources":                cfn.resources,
+	}
+	if len(e.notes) > 0 {
+		template["Metadata"] = map[string]any{"Yams::Notes": e.notes}
+	}
+
+	b, err := exportJSON.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode template: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// cfnWriter accumulates the resources making up a CloudFormation template
+type cfnWriter struct {
+	ids       *identifiers
+	resources map[string]cfnResource
+
+	// refs maps the ARNs of exported entities to values referencing them
+	refs map[entities.Arn]any
+}
+
+// resource adds a resource, returning its logical ID
+func (cfn *cfnWriter) resource(kind, name string, properties map[string]any) string {
+	id := cfn.ids.next(name)
+	cfn.resources[id] = cfnResource{Type: kind, Properties: properties}
+	return id
+}
+
+// ref returns a value referring to the ARN of a policy, using the exported policy if any
+func (cfn *cfnWriter) ref(arn entities.Arn) any {
+	if ref, ok := cfn.refs[arn]; ok {
+		return ref
+	}
+	return arn
+}
+
+func (cfn *cfnWriter) policy(p entities.ManagedPolicy) {
+	name := iamName(p.Name, p.Arn)
+	if isOrgPolicy(p) {
+		id := cfn.resource("AWS::Organizations::Policy", name+"Policy", map[string]any{
+			"Name":    name,
+			"Type":    orgPolicyType(p),
+			"Content": document(p.Policy),
+		})
+		cfn.refs[p.Arn] = map[string]any{"Fn::GetAtt": []string{id, "Arn"}}
+		return
+	}
+
+	id := cfn.resource("AWS::IAM::ManagedPolicy", name+"Policy", map[string]any{
+		"ManagedPolicyName": name,
+		"Path":              iamPath(p.Arn),
+		"PolicyDocument":    document(p.Policy),
+	})
+	cfn.refs[p.Arn] = map[string]any{"Ref": id}
+}
+
+func (cfn *cfnWriter) group(g entities.Group) {
+	name := iamName(g.Name, g.Arn)
+	properties := map[string]any{
+		"GroupName": name,
+		"Path":      iamPath(g.Arn),
+	}
+	cfn.attachments(properties, g.InlinePolicies, g.AttachedPolicies)
+
+	id := cfn.resource("AWS::IAM::Group", name+"Group", properties)
+	cfn.refs[g.Arn] = map[string]any{"Ref": id}
+}
+
+func (cfn *cfnWriter) role(p entities.Principal, trust policy.Policy) {
+	name := iamName(p.Name, p.Arn)
+	properties := map[string]any{
+		"RoleName":                 name,
+		"Path":                     iamPath(p.Arn),
+		"AssumeRolePolicyDocument": document(trust),
+	}
+	cfn.principal(properties, p)
+
+	cfn.resource("AWS::IAM::Role", name+"Role", properties)
+}
+
+func (cfn *cfnWriter) user(p entities.Principal) {
+	name := iamName(p.Name, p.Arn)
+	properties := map[string]any{
+		"UserName": name,
+		"Path":     iamPath(p.Arn),
+	}
+	cfn.principal(properties, p)
+
+	if len(p.Groups) > 0 {
+		groups := make([]any, len(p.Groups))
+		for i, arn := range p.Groups {
+			if ref, ok := cfn.refs[arn]; ok {
+				groups[i] = ref
+			} else {
+				groups[i] = iamName("", arn)
+			}
+		}
+		properties["Groups"] = groups
+	}
+
+	cfn.resource("AWS::IAM::User", name+"User", properties)
+}
+
+// principal adds the properties shared by roles and users
+func (cfn *cfnWriter) principal(properties map[string]any, p entities.Principal) {
+	cfn.attachments(properties, p.InlinePolicies, p.AttachedPolicies)
+
+	if p.PermissionsBoundary != "" {
+		properties["PermissionsBoundary"] = cfn.ref(p.PermissionsBoundary)
+	}
+	if len(p.Tags) > 0 {
+		tags := make([]map[string]string, len(p.Tags))
+		for i, tag := range p.Tags {
+			tags[i] = map[string]string{"Key": tag.Key, "Value": tag.Value}
+		}
+		properties["Tags"] = tags
+	}
+}
+
+// attachments adds the inline policies and managed policy ARNs of a role, user or group
+func (cfn *cfnWriter) attachments(
+	properties map[string]any, inline []policy.Policy, attached []entities.Arn) {
+
+	if len(inline) > 0 {
+		policies := make([]map[string]any, len(inline))
+		for i, p := range inline {
+			name := p.Name
+			if name == "" {
+				name = fmt.Sprintf("inline-%d", i+1)
+			}
+			policies[i] = map[string]any{"PolicyName": name, "PolicyDocument": document(p)}
+		}
+		properties["Policies"] = policies
+	}
+
+	if len(attached) > 0 {
+		arns := make([]any, len(attached))
+		for i, arn := range attached {
+			arns[i] = cfn.ref(arn)
+		}
+		properties["ManagedPolicyArns"] = arns
+	}
+}
+
+// resourcePolicy adds the resource policy of a resource, returning false if the resource type has
+// no standalone policy resource in CloudFormation
+func (cfn *cfnWriter) resourcePolicy(r entities.Resource) bool {
+	name := iamName(r.Name, r.Arn)
+
+	switch r.Type {
+	case "AWS::S3::Bucket":
+		cfn.resource("AWS::S3::BucketPolicy", name+"BucketPolicy", map[string]any{
+			"Bucket":         arnlib.ResourceSegment(r.Arn),
+			"PolicyDocument": document(r.Policy),
+		})
+	case "AWS::SQS::Queue":
+		cfn.resource("AWS::SQS::QueuePolicy", name+"QueuePolicy", map[string]any{
+			"Queues":         []string{queueURL(r.Arn)},
+			"PolicyDocument": document(r.Policy),
+		})
+	case "AWS::SNS::Topic":
+		cfn.resource("AWS::SNS::TopicPolicy", name+"TopicPolicy", map[string]any{
+			"Topics":         []string{r.Arn},
+			"PolicyDocument": document(r.Policy),
+		})
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,334 @@
+package overlay
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	arnlib "github.com/nsiow/yams/pkg/arn"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+// terraform renders the overlay as HCL for the Terraform AWS provider
+func (e *exporter) terraform() ([]byte, error) {
+	tf := terraformWriter{
+		ids:  newIdentifiers(`[^A-Za-z0-9_-]`),
+		refs: map[entities.Arn]string{},
+	}
+
+	for _, p := range e.policies {
+		tf.policy(p)
+	}
+	for _, g := range e.groups {
+		tf.group(g)
+	}
+	for _, p := range e.principals {
+		switch p.Type {
+		case "AWS::IAM::Role":
+			tf.role(p, e.trust[p.Arn])
+		case "AWS::IAM::User":
+			tf.user(p)
+		default:
+			e.note("principal %s of type '%s' cannot be exported", p.Arn, p.Type)
+		}
+	}
+	for _, r := range e.resources {
+		if isIAMResource(r) || r.Policy.Empty() {
+			continue
+		}
+		if !tf.resourcePolicy(r) {
+			e.note("resource policy of %s (%s) cannot be exported", r.Arn, r.Type)
+		}
+	}
+
+	if tf.err != nil {
+		return nil, tf.err
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Generated by yams from overlay '%s' (%s)", e.data.Name, e.data.ID)
+	if e.data.Revision != 0 {
+		fmt.Fprintf(&out, ", revision %d", e.data.Revision)
+	}
+	out.WriteString("\n")
+	for _, note := range e.notes {
+		fmt.Fprintf(&out, "#\n# NOTE: %s\n", note)
+	}
+	for _, block := range tf.blocks {
+		out.WriteString("\n")
+		block.write(&out)
+	}
+	return []byte(out.String()), nil
+}
+
+// terraformWriter accumulates the HCL blocks making up a Terraform export
+type terraformWriter struct {
+	ids    *identifiers
+	blocks []*hclBlock
+
+	// refs maps the ARNs of exported entities to expressions referencing them
+	refs map[entities.Arn]string
+
+	// err is the first error encountered while rendering
+	err error
+}
+
+// resource adds a resource block, returning its address
+func (tf *terraformWriter) resource(kind, name string) (*hclBlock, string) {
+	id := tf.ids.next(name)
+	if id[0] >= '0' && id[0] <= '9' {
+		id = "_" + id
+	}
+
+	block := &hclBlock{labels: []string{"resource", hclString(kind), hclString(id)}}
+	tf.blocks = append(tf.blocks, block)
+	return block, kind + "." + id
+}
+
+// ref returns an expression referring to the ARN of a policy, using the exported policy if any
+func (tf *terraformWriter) ref(arn entities.Arn) string {
+	if ref, ok := tf.refs[arn]; ok {
+		return ref
+	}
+	return hclString(arn)
+}
+
+func (tf *terraformWriter) policy(p entities.ManagedPolicy) {
+	name := iamName(p.Name, p.Arn)
+	if isOrgPolicy(p) {
+		block, addr := tf.resource("aws_organizations_policy", name)
+		block.attr("name", hclString(name))
+		block.attr("type", hclString(orgPolicyType(p)))
+		block.attr("content", tf.document(p.Policy))
+		tf.refs[p.Arn] = addr + ".arn"
+		return
+	}
+
+	block, addr := tf.resource("aws_iam_policy", name)
+	block.attr("name", hclString(name))
+	block.attr("path", hclString(iamPath(p.Arn)))
+	block.attr("policy", tf.document(p.Policy))
+	tf.refs[p.Arn] = addr + ".arn"
+}
+
+func (tf *terraformWriter) group(g entities.Group) {
+	name := iamName(g.Name, g.Arn)
+	block, addr := tf.resource("aws_iam_group", name)
+	block.attr("name", hclString(name))
+	block.attr("path", hclString(iamPath(g.Arn)))
+	tf.refs[g.Arn] = addr + ".name"
+
+	tf.attachments("group", name, addr+".name", g.InlinePolicies, g.AttachedPolicies)
+}
+
+func (tf *terraformWriter) role(p entities.Principal, trust policy.Policy) {
+	name := iamName(p.Name, p.Arn)
+	block, addr := tf.resource("aws_iam_role", name)
+	block.attr("name", hclString(name))
+	block.attr("path", hclString(iamPath(p.Arn)))
+	if trust.Empty() {
+		// assume_role_policy is required, so leave it out rather than applying an invalid document
+		block.comment("the overlay does not define a trust policy for this role; " +
+			"set assume_role_policy before applying")
+	} else {
+		block.attr("assume_role_policy", tf.document(trust))
+	}
+	tf.principal(block, p)
+
+	tf.attachments("role", name, addr+".name", p.InlinePolicies, p.AttachedPolicies)
+}
+
+func (tf *terraformWriter) user(p entities.Principal) {
+	name := iamName(p.Name, p.Arn)
+	block, addr := tf.resource("aws_iam_user", name)
+	block.attr("name", hclString(name))
+	block.attr("path", hclString(iamPath(p.Arn)))
+	tf.principal(block, p)
+
+	tf.attachments("user", name, addr+".name", p.InlinePolicies, p.AttachedPolicies)
+
+	if len(p.Groups) > 0 {
+		membership, _ := tf.resource("aws_iam_user_group_membership", name+"_groups")
+		membership.attr("user", addr+".name")
+
+		groups := make([]string, len(p.Groups))
+		for i, arn := range p.Groups {
+			if ref, ok := tf.refs[arn]; ok {
+				groups[i] = ref
+			} else {
+				groups[i] = hclString(iamName("", arn))
+			}
+		}
+		membership.attr("groups", hclList(groups))
+	}
+}
+
+// principal adds the attributes shared by roles and users
+func (tf *terraformWriter) principal(block *hclBlock, p entities.Principal) {
+	if p.PermissionsBoundary != "" {
+		block.attr("permissions_boundary", tf.ref(p.PermissionsBoundary))
+	}
+	if len(p.Tags) > 0 {
+		tags := make(map[string]string, len(p.Tags))
+		for _, tag := range p.Tags {
+			tags[tag.Key] = tag.Value
+		}
+		block.attr("tags", hclMap(tags))
+	}
+}
+
+// attachments adds the inline policies and managed policy attachments of a role, user or group
+func (tf *terraformWriter) attachments(
+	kind, name, ref string, inline []policy.Policy, attached []entities.Arn) {
+
+	for i, p := range inline {
+		policyName := p.Name
+		if policyName == "" {
+			policyName = fmt.Sprintf("%s-inline-%d", name, i+1)
+		}
+		block, _ := tf.resource("aws_iam_"+kind+"_policy", name+"_"+policyName)
+		block.attr("name", hclString(policyName))
+		block.attr(kind, ref)
+		block.attr("policy", tf.document(p))
+	}
+
+	for _, arn := range attached {
+		block, _ := tf.resource("aws_iam_"+kind+"_policy_attachment", name+"_"+iamName("", arn))
+		block.attr(kind, ref)
+		block.attr("policy_arn", tf.ref(arn))
+	}
+}
+
+// resourcePolicy adds the resource policy of a resource, returning false if the resource type has
+// no standalone policy resource in the AWS provider
+func (tf *terraformWriter) resourcePolicy(r entities.Resource) bool {
+	name := iamName(r.Name, r.Arn)
+
+	var block *hclBlock
+	switch r.Type {
+	case "AWS::S3::Bucket":
+		block, _ = tf.resource("aws_s3_bucket_policy", name)
+		block.attr("bucket", hclString(arnlib.ResourceSegment(r.Arn)))
+	case "AWS::SQS::Queue":
+		block, _ = tf.resource("aws_sqs_queue_policy", name)
+		block.attr("queue_url", hclString(queueURL(r.Arn)))
+	case "AWS::SNS::Topic":
+		block, _ = tf.resource("aws_sns_topic_policy", name)
+		block.attr("arn", hclString(r.Arn))
+	case "AWS::KMS::Key":
+		block, _ = tf.resource("aws_kms_key_policy", name)
+		block.attr("key_id", hclString(r.Arn))
+	case "AWS::DynamoDB::Table":
+		block, _ = tf.resource("aws_dynamodb_resource_policy", name)
+		block.attr("resource_arn", hclString(r.Arn))
+	default:
+		return false
+	}
+
+	block.attr("policy", tf.document(r.Policy))
+	return true
+}
+
+// -------------------------------------------------------------------------------------------------
+// HCL
+// -------------------------------------------------------------------------------------------------
+
+// hclBlock is a block of HCL attributes, such as a resource
+type hclBlock struct {
+	labels   []string
+	comments []string
+	attrs    [][2]string
+}
+
+// attr adds an attribute with an already-rendered value
+func (b *hclBlock) attr(key, value string) {
+	b.attrs = append(b.attrs, [2]string{key, value})
+}
+
+// comment adds a comment to the top of the block
+func (b *hclBlock) comment(text string) {
+	b.comments = append(b.comments, text)
+}
+
+// write renders the block, aligning its attributes as `terraform fmt` does
+func (b *hclBlock) write(out *strings.Builder) {
+	width := 0
+	for _, attr := range b.attrs {
+		width = max(width, len(attr[0]))
+	}
+
+	fmt.Fprintf(out, "%s {\n", strings.Join(b.labels, " "))
+	for _, text := range b.comments {
+		fmt.Fprintf(out, "  # %s\n", text)
+	}
+	for _, attr := range b.attrs {
+		fmt.Fprintf(out, "  %-*s = %s\n", width, attr[0], attr[1])
+	}
+	out.WriteString("}\n")
+}
+
+// hclEscaper escapes the template sequences which HCL would otherwise interpolate, such as the
+// ${aws:username} policy variable
+var hclEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+// hclString renders a quoted HCL string. Only the escapes defined by HCL are used, so other control
+// characters are written as \uNNNN rather than as Go escapes such as \x00 or \a
+func hclString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			switch {
+			case unicode.IsPrint(r):
+				out.WriteRune(r)
+			case r > 0xFFFF:
+				fmt.Fprintf(&out, `\U%08X`, r)
+			default:
+				fmt.Fprintf(&out, `\u%04X`, r)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return hclEscaper.Replace(out.String())
+}
+
+// hclList renders a list of already-rendered values
+func hclList(values []string) string {
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// hclMap renders a map of strings, sorted by key
+func hclMap(m map[string]string) string {
+	var out strings.Builder
+	out.WriteString("{\n")
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		fmt.Fprintf(&out, "    %s = %s\n", hclString(k), hclString(m[k]))
+	}
+	out.WriteString("  }")
+	return out.String()
+}
+
+// document renders a policy document as an indented heredoc
+func (tf *terraformWriter) document(p policy.Policy) string {
+	b, err := exportJSON.MarshalIndent(document(p), "    ", "  ")
+	if err != nil {
+		tf.err = cmp.Or(tf.err, fmt.Errorf("unable to encode policy: %w", err))
+		return hclString("")
+	}
+	return "<<-EOT\n    " + hclEscaper.Replace(string(b)) + "\n  EOT"
+}
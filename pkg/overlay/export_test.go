@@ -0,0 +1,323 @@
+package overlay
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/loaders/awsconfig"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+// trustPolicy allows EC2 to assume a role
+var trustPolicy = policy.Policy{
+	Version: "2012-10-17",
+	Statement: []policy.Statement{
+		{
+			Effect:    policy.EFFECT_ALLOW,
+			Principal: policy.Principal{Service: []string{"ec2.amazonaws.com"}},
+			Action:    []string{"sts:AssumeRole"},
+		},
+	},
+}
+
+// usernamePolicy uses a policy variable, which must be escaped in HCL
+var usernamePolicy = policy.Policy{
+	Version: "2012-10-17",
+	Name:    "home",
+	Statement: []policy.Statement{
+		{
+			Effect:   policy.EFFECT_ALLOW,
+			Action:   []string{"s3:GetObject"},
+			Resource: []string{"arn:aws:s3:::home/${aws:username}/*"},
+		},
+	},
+}
+
+// exportData is an overlay exercising each kind of exported entity
+func exportData() entities.OverlayData {
+	allow := validPolicy
+	allow.Version = "2012-10-17"
+
+	return entities.OverlayData{
+		Name:     "export",
+		ID:       "ov-1",
+		Revision: 2,
+		Accounts: []entities.Account{{Id: "111111111111", Name: "dev", OrgId: "o-123"}},
+		Groups: []entities.Group{
+			{
+				Type:             "AWS::IAM::Group",
+				AccountId:        "111111111111",
+				Arn:              "arn:aws:iam::111111111111:group/devs",
+				AttachedPolicies: []entities.Arn{"arn:aws:iam::111111111111:policy/reader"},
+			},
+		},
+		Policies: []entities.ManagedPolicy{
+			{
+				Type:      "AWS::IAM::Policy",
+				AccountId: "111111111111",
+				Arn:       "arn:aws:iam::111111111111:policy/reader",
+				Policy:    allow,
+			},
+		},
+		Principals: []entities.Principal{
+			{
+				Type:             "AWS::IAM::Role",
+				AccountId:        "111111111111",
+				Arn:              "arn:aws:iam::111111111111:role/service-role/app",
+				Tags:             []entities.Tag{{Key: "team", Value: "platform"}},
+				AttachedPolicies: []entities.Arn{"arn:aws:iam::111111111111:policy/reader"},
+			},
+			{
+				Type:           "AWS::IAM::User",
+				AccountId:      "111111111111",
+				Arn:            "arn:aws:iam::111111111111:user/alice",
+				InlinePolicies: []policy.Policy{usernamePolicy},
+				Groups:         []entities.Arn{"arn:aws:iam::111111111111:group/devs"},
+			},
+		},
+		Resources: []entities.Resource{
+			{
+				Type:      "AWS::IAM::Role",
+				AccountId: "111111111111",
+				Arn:       "arn:aws:iam::111111111111:role/service-role/app",
+				Policy:    trustPolicy,
+			},
+			{
+				Type:      "AWS::S3::Bucket",
+				AccountId: "111111111111",
+				Region:    "us-east-1",
+				Arn:       "arn:aws:s3:::home",
+				Policy:    allow,
+			},
+			{
+				Type:      "AWS::SQS::Queue",
+				AccountId: "111111111111",
+				Region:    "us-east-1",
+				Arn:       "arn:aws:sqs:us-east-1:111111111111:jobs",
+				Policy:    allow,
+			},
+			{
+				Type:      "AWS::EC2::Instance",
+				AccountId: "111111111111",
+				Region:    "us-east-1",
+				Arn:       "arn:aws:ec2:us-east-1:111111111111:instance/i-123",
+				Policy:    allow,
+			},
+		},
+		Tombstones: []entities.Arn{"arn:aws:iam::111111111111:role/old"},
+	}
+}
+
+func TestExport_Terraform(t *testing.T) {
+	out, err := Export(exportData(), ExportTerraform)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := string(out)
+
+	for _, want := range []string{
+		"# Generated by yams from overlay 'export' (ov-1), revision 2\n",
+		"# NOTE: tombstone for arn:aws:iam::111111111111:role/old cannot be exported",
+		"# NOTE: resource policy of arn:aws:ec2:us-east-1:111111111111:instance/i-123",
+		`resource "aws_iam_policy" "reader" {`,
+		`resource "aws_iam_role" "app" {`,
+		`  path               = "/service-role/"`,
+		`  assume_role_policy = <<-EOT`,
+		`"Service": "ec2.amazonaws.com"`,
+		`    "team" = "platform"`,
+		`resource "aws_iam_role_policy_attachment" "app_reader" {`,
+		`  policy_arn = aws_iam_policy.reader.arn`,
+		`resource "aws_iam_user_policy" "alice_home" {`,
+		`arn:aws:s3:::home/$${aws:username}/*`,
+		`  groups = [aws_iam_group.devs.name]`,
+		`  bucket = "home"`,
+		`  queue_url = "https://sqs.us-east-1.amazonaws.com/111111111111/jobs"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestExport_TerraformRoleWithoutTrust(t *testing.T) {
+	data := entities.OverlayData{
+		Name: "untrusted",
+		Principals: []entities.Principal{
+			{
+				Type:      "AWS::IAM::Role",
+				AccountId: "111111111111",
+				Arn:       "arn:aws:iam::111111111111:role/untrusted",
+			},
+		},
+	}
+
+	out, err := Export(data, ExportTerraform)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := string(out)
+
+	// an empty document is not a valid trust policy, so the attribute is left for the user to set
+	if strings.Contains(got, "assume_role_policy =") {
+		t.Errorf("expected assume_role_policy to be omitted, got:\n%s", got)
+	}
+	if !strings.Contains(got, "set assume_role_policy before applying") {
+		t.Errorf("expected comment explaining the missing trust policy, got:\n%s", got)
+	}
+}
+
+func TestHclString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: `""`},
+		{in: "plain", want: `"plain"`},
+		{in: `say "hi"`, want: `"say \"hi\""`},
+		{in: `back\slash`, want: `"back\\slash"`},
+		{in: "line\nbreak\ttab\rreturn", want: `"line\nbreak\ttab\rreturn"`},
+		{in: "bell\a nul\x00", want: `"bell\u0007 nul\u0000"`},
+		{in: "\U000E0001", want: `"\U000E0001"`},
+		{in: "café ☕", want: `"café ☕"`},
+		{in: "home/${aws:username}", want: `"home/$${aws:username}"`},
+		{in: "%{if x}", want: `"%%{if x}"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := hclString(tt.in); got != tt.want {
+				t.Errorf("hclString(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExport_CloudFormation(t *testing.T) {
+	out, err := Export(exportData(), ExportCloudFormation)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var template struct {
+		Resources map[string]cfnResource `json:"Resources"`
+		Metadata  map[string][]string    `json:"Metadata"`
+	}
+	if err := json.Unmarshal(out, &template); err != nil {
+		t.Fatalf("unable to decode template: %v\n%s", err, out)
+	}
+
+	types := map[string]string{}
+	for id, r := range template.Resources {
+		types[id] = r.Type
+	}
+	want := map[string]string{
+		"readerPolicy":     "AWS::IAM::ManagedPolicy",
+		"devsGroup":        "AWS::IAM::Group",
+		"appRole":          "AWS::IAM::Role",
+		"aliceUser":        "AWS::IAM::User",
+		"homeBucketPolicy": "AWS::S3::BucketPolicy",
+		"jobsQueuePolicy":  "AWS::SQS::QueuePolicy",
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("wanted resources %v, got %v", want, types)
+	}
+
+	role := template.Resources["appRole"].Properties
+	if got := role["ManagedPolicyArns"]; !reflect.DeepEqual(got,
+		[]any{map[string]any{"Ref": "readerPolicy"}}) {
+		t.Errorf("expected role to reference exported policy, got %v", got)
+	}
+	if got := role["AssumeRolePolicyDocument"]; got == nil {
+		t.Errorf("expected role to have a trust policy")
+	}
+
+	if notes := template.Metadata["Yams::Notes"]; len(notes) != 2 {
+		t.Errorf("expected 2 notes, got %v", notes)
+	}
+}
+
+func TestExport_AWSConfig(t *testing.T) {
+	data := exportData()
+	out, err := Export(data, ExportAWSConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loader := awsconfig.NewLoader()
+	if err := loader.LoadJsonl(bytes.NewReader(out)); err != nil {
+		t.Fatalf("unable to load exported items: %v\n%s", err, out)
+	}
+	uv := loader.Universe()
+
+	if a, ok := uv.Account("111111111111"); !ok || a.Name != "dev" || a.OrgId != "o-123" {
+		t.Errorf("account did not round-trip: %+v", a)
+	}
+	if p, ok := uv.Policy("arn:aws:iam::111111111111:policy/reader"); !ok ||
+		len(p.Policy.Statement) != 1 {
+		t.Errorf("policy did not round-trip: %+v", p)
+	}
+	if g, ok := uv.Group("arn:aws:iam::111111111111:group/devs"); !ok ||
+		!reflect.DeepEqual(g.AttachedPolicies, data.Groups[0].AttachedPolicies) {
+		t.Errorf("group did not round-trip: %+v", g)
+	}
+
+	role, ok := uv.Principal("arn:aws:iam::111111111111:role/service-role/app")
+	if !ok {
+		t.Fatalf("role did not round-trip")
+	}
+	if !reflect.DeepEqual(role.Tags, data.Principals[0].Tags) {
+		t.Errorf("wanted role tags %v, got %v", data.Principals[0].Tags, role.Tags)
+	}
+	trust, ok := uv.Resource("arn:aws:iam::111111111111:role/service-role/app")
+	if !ok || !reflect.DeepEqual(trust.Policy.Statement, trustPolicy.Statement) {
+		t.Errorf("trust policy did not round-trip: %+v", trust)
+	}
+
+	user, ok := uv.Principal("arn:aws:iam::111111111111:user/alice")
+	if !ok {
+		t.Fatalf("user did not round-trip")
+	}
+	if !reflect.DeepEqual(user.Groups, data.Principals[1].Groups) {
+		t.Errorf("wanted user groups %v, got %v", data.Principals[1].Groups, user.Groups)
+	}
+	if len(user.InlinePolicies) != 1 ||
+		!reflect.DeepEqual(user.InlinePolicies[0].Statement, usernamePolicy.Statement) {
+		t.Errorf("user inline policy did not round-trip: %+v", user.InlinePolicies)
+	}
+
+	for _, arn := range []string{"arn:aws:s3:::home", "arn:aws:sqs:us-east-1:111111111111:jobs"} {
+		r, ok := uv.Resource(arn)
+		if !ok || len(r.Policy.Statement) != 1 {
+			t.Errorf("resource policy of %s did not round-trip: %+v", arn, r)
+		}
+	}
+	if !uv.HasResource("arn:aws:ec2:us-east-1:111111111111:instance/i-123") {
+		t.Errorf("expected generic resource to be exported")
+	}
+}
+
+func TestExport_Stable(t *testing.T) {
+	for _, format := range ExportFormats {
+		first, err := Export(exportData(), format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		for range 5 {
+			again, _ := Export(exportData(), format)
+			if !bytes.Equal(first, again) {
+				t.Fatalf("%s: expected stable output, got:\n%s\nthen:\n%s", format, first, again)
+			}
+		}
+	}
+}
+
+func TestExport_UnsupportedFormat(t *testing.T) {
+	_, err := Export(exportData(), "pulumi")
+	if err == nil || !strings.Contains(err.Error(), "unsupported export format 'pulumi'") {
+		t.Fatalf("expected unsupported format error, got: %v", err)
+	}
+}
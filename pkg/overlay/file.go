@@ -0,0 +1,447 @@
+package overlay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+// FileStore is a file-system implementation of the Store interface, for environments without
+// access to DynamoDB. Each overlay is stored as a single JSON document named "<id>.json" within the
+// store's directory, and its previous revisions as "<id>.revisions/<revision>.json".
+//
+// Documents are replaced atomically, and every operation holds a lock on the directory, so the
+// same directory may be shared by several processes on one host.
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// fileDocument is the on-disk representation of an overlay.
+type fileDocument struct {
+	Overlay   entities.OverlayData       `json:"overlay"`
+	Revisions []entities.OverlayRevision `json:"revisions"`
+}
+
+// fileLockName is the name of the lock file within the store's directory.
+const fileLockName = ".lock"
+
+// NewFileStore creates a new file-system overlay store in the provided directory, creating the
+// directory if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create overlay directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Create stores a new overlay as revision 1.
+func (s *FileStore) Create(ctx context.Context, overlay *entities.Overlay) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path, err := s.path(overlay.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return ErrAlreadyExists
+	}
+
+	stored := *overlay
+	stored.Revision = 1
+	stored.UpdatedAt = overlay.CreatedAt
+
+	doc := fileDocument{
+		Overlay:   stored.ToData(),
+		Revisions: []entities.OverlayRevision{stored.RevisionInfo()},
+	}
+	if err := writeJSON(path, doc); err != nil {
+		return err
+	}
+
+	overlay.Revision, overlay.UpdatedAt = stored.Revision, stored.UpdatedAt
+	return nil
+}
+
+// Get retrieves an overlay by ID.
+func (s *FileStore) Get(ctx context.Context, id string) (*entities.Overlay, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	doc, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	return unexpired(entities.FromData(doc.Overlay), nil)
+}
+
+// GetByName retrieves an overlay by name.
+func (s *FileStore) GetByName(ctx context.Context, name string) (*entities.Overlay, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	docs, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if doc.Overlay.Name == name {
+			return unexpired(entities.FromData(doc.Overlay), nil)
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Update stores a new revision of an existing overlay.
+func (s *FileStore) Update(ctx context.Context, overlay *entities.Overlay) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	doc, err := s.read(overlay.ID)
+	if err != nil {
+		return err
+	}
+
+	revision, err := checkRevision(overlay.Revision, doc.Overlay.Revision)
+	if err != nil {
+		return err
+	}
+
+	// Retain the current revision before replacing it
+	dir := s.revisionsDir(overlay.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create overlay revisions directory: %w", err)
+	}
+	err = writeJSON(filepath.Join(dir, strconv.Itoa(doc.Overlay.Revision)+".json"), doc.Overlay)
+	if err != nil {
+		return err
+	}
+
+	stored := *overlay
+	stored.Revision = revision
+	stored.UpdatedAt = time.Now()
+
+	path, _ := s.path(overlay.ID)
+	updated := fileDocument{
+		Overlay:   stored.ToData(),
+		Revisions: append(doc.Revisions, stored.RevisionInfo()),
+	}
+	if err := writeJSON(path, updated); err != nil {
+		return err
+	}
+
+	overlay.Revision, overlay.UpdatedAt = stored.Revision, stored.UpdatedAt
+	return nil
+}
+
+// Revisions returns the metadata of every revision of an overlay, oldest first.
+func (s *FileStore) Revisions(ctx context.Context, id string) ([]entities.OverlayRevision, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	doc, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := unexpired(entities.FromData(doc.Overlay), nil); err != nil {
+		return nil, err
+	}
+	return doc.Revisions, nil
+}
+
+// GetRevision retrieves a specific revision of an overlay.
+func (s *FileStore) GetRevision(
+	ctx context.Context, id string, revision int) (*entities.Overlay, error) {
+
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	doc, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := unexpired(entities.FromData(doc.Overlay), nil)
+	if err != nil {
+		return nil, err
+	}
+	if revision == current.Revision {
+		return current, nil
+	}
+
+	var data entities.OverlayData
+	err = readJSON(filepath.Join(s.revisionsDir(id), strconv.Itoa(revision)+".json"), &data)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entities.FromData(data), nil
+}
+
+// Delete removes an overlay by ID, including its previous revisions.
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete overlay: %w", err)
+	}
+
+	if err := os.RemoveAll(s.revisionsDir(id)); err != nil {
+		return fmt.Errorf("failed to delete overlay revisions: %w", err)
+	}
+	return nil
+}
+
+// List returns summaries of all overlays, optionally filtered by query.
+func (s *FileStore) List(ctx context.Context, query string) ([]entities.OverlaySummary, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	docs, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	summaries := make([]entities.OverlaySummary, 0, len(docs))
+	now := time.Now()
+	for _, doc := range docs {
+		// Filter by query if provided
+		if query != "" && !strings.Contains(strings.ToLower(doc.Overlay.Name), query) {
+			continue
+		}
+		overlay := entities.FromData(doc.Overlay)
+		if overlay.Expired(now) {
+			continue
+		}
+		summaries = append(summaries, overlay.Summary())
+	}
+
+	return summaries, nil
+}
+
+// Exists checks if an overlay with the given ID exists.
+func (s *FileStore) Exists(ctx context.Context, id string) (bool, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	doc, err := s.read(id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !entities.FromData(doc.Overlay).Expired(time.Now()), nil
+}
+
+// DeleteExpired removes every overlay which has expired as of `now`.
+func (s *FileStore) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	docs, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for _, doc := range docs {
+		if !entities.FromData(doc.Overlay).Expired(now) {
+			continue
+		}
+
+		id := doc.Overlay.ID
+		path, err := s.path(id)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return deleted, fmt.Errorf("failed to delete overlay: %w", err)
+		}
+		if err := os.RemoveAll(s.revisionsDir(id)); err != nil {
+			return deleted, fmt.Errorf("failed to delete overlay revisions: %w", err)
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, nil
+}
+
+// lock acquires the store's lock, shared between processes using the lock file, and returns a
+// function which releases it.
+func (s *FileStore) lock(exclusive bool) (func(), error) {
+	if exclusive {
+		s.mu.Lock()
+	} else {
+		s.mu.RLock()
+	}
+	release := func() {
+		if exclusive {
+			s.mu.Unlock()
+		} else {
+			s.mu.RUnlock()
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, fileLockName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to open overlay lock file: %w", err)
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		release()
+		return nil, fmt.Errorf("failed to lock overlay directory: %w", err)
+	}
+
+	return func() {
+		_ = unlockFile(f)
+		f.Close()
+		release()
+	}, nil
+}
+
+// path returns the path of the document for the overlay with the provided ID, rejecting IDs which
+// would refer to a file outside of the store's directory.
+func (s *FileStore) path(id string) (string, error) {
+	if id == "" || strings.HasPrefix(id, ".") || filepath.Base(id) != id {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// revisionsDir returns the directory holding the previous revisions of an overlay.
+func (s *FileStore) revisionsDir(id string) string {
+	return filepath.Join(s.dir, id+".revisions")
+}
+
+// read reads the document for the overlay with the provided ID.
+func (s *FileStore) read(id string) (*fileDocument, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc fileDocument
+	err = readJSON(path, &doc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// readAll reads the documents of all overlays in the store.
+func (s *FileStore) readAll() ([]*fileDocument, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list overlays: %w", err)
+	}
+
+	var docs []*fileDocument
+	for _, path := range paths {
+		var doc fileDocument
+		if err := readJSON(path, &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, &doc)
+	}
+	return docs, nil
+}
+
+// readJSON reads and unmarshals the JSON file at the provided path.
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSON atomically replaces the file at the provided path with the JSON representation of v,
+// by writing to a temporary file in the same directory and renaming it.
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal overlay: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write overlay: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write overlay: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write overlay: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write overlay: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write overlay: %w", err)
+	}
+	return nil
+}
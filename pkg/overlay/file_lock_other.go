@@ -0,0 +1,18 @@
+//go:build !unix
+
+package overlay
+
+import (
+	"os"
+)
+
+// lockFile is a no-op on platforms without flock; the FileStore is then only safe for use by a
+// single process
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without flock
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package overlay
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile acquires an advisory lock on the provided file, blocking until it is available
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+// unlockFile releases the advisory lock on the provided file
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,91 @@
+package overlay
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+func TestFileStore_Layout(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("test-overlay")
+	_ = store.Create(ctx, overlay)
+	_ = store.Update(ctx, overlay)
+
+	// the current revision is a single document, and previous revisions are kept alongside it
+	for _, path := range []string{
+		overlay.ID + ".json",
+		filepath.Join(overlay.ID+".revisions", "1.json"),
+	} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("expected %s to exist: %v", path, err)
+		}
+	}
+
+	// no temporary files are left behind
+	temps, _ := filepath.Glob(filepath.Join(dir, ".tmp-*"))
+	if len(temps) != 0 {
+		t.Errorf("expected no temporary files, got %v", temps)
+	}
+}
+
+func TestFileStore_SharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	first, _ := NewFileStore(dir)
+	second, _ := NewFileStore(dir)
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("test-overlay")
+	if err := first.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// overlays written by one store are visible to another using the same directory
+	retrieved, err := second.Get(ctx, overlay.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if err := second.Update(ctx, retrieved); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// and revision checks apply across stores
+	if err := first.Update(ctx, overlay); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestFileStore_InvalidID(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(filepath.Join(dir, "overlays"))
+	ctx := context.Background()
+
+	// IDs which would escape the store's directory are never found
+	if err := os.WriteFile(filepath.Join(dir, "outside.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	for _, id := range []string{"", "../outside", ".lock", "a/b"} {
+		if _, err := store.Get(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): expected ErrNotFound, got %v", id, err)
+		}
+		if exists, err := store.Exists(ctx, id); exists || err != nil {
+			t.Errorf("Exists(%q) = %v, %v", id, exists, err)
+		}
+	}
+
+	overlay := entities.NewOverlay("test-overlay")
+	overlay.ID = "../escaped"
+	if err := store.Create(ctx, overlay); err == nil {
+		t.Errorf("expected Create to reject ID %q", overlay.ID)
+	}
+}
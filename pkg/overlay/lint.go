@@ -0,0 +1,312 @@
+package overlay
+
+import (
+	"fmt"
+	"strings"
+
+	arnlib "github.com/nsiow/yams/pkg/arn"
+	"github.com/nsiow/yams/pkg/aws/managedpolicies"
+	"github.com/nsiow/yams/pkg/aws/sar"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+// Severities of the issues reported by Lint
+const (
+	// SeverityError marks an issue which makes the overlay unusable; overlays with errors are
+	// rejected on write
+	SeverityError = "error"
+
+	// SeverityWarning marks a likely mistake, such as a reference which cannot be resolved, which
+	// does not prevent the overlay from being stored
+	SeverityWarning = "warning"
+)
+
+// Codes identifying the kinds of issues reported by Lint
+const (
+	IssueInvalidArn          = "invalid-arn"
+	IssueInvalidPolicy       = "invalid-policy"
+	IssueAccountMismatch     = "account-mismatch"
+	IssueUnresolvedPolicy    = "unresolved-policy"
+	IssueUnresolvedGroup     = "unresolved-group"
+	IssueUnknownAccount      = "unknown-account"
+	IssueUnsupportedType     = "unsupported-type"
+	IssueUnknownResourceType = "unknown-resource-type"
+)
+
+// Issue describes a problem found in an overlay by Lint
+type Issue struct {
+	// Severity is either SeverityError or SeverityWarning
+	Severity string `json:"severity"`
+
+	// Code identifies the kind of issue, e.g. IssueUnresolvedPolicy
+	Code string `json:"code"`
+
+	// Kind is the kind of entity containing the issue, e.g. "principal"
+	Kind string `json:"kind"`
+
+	// Key is the ARN of the entity containing the issue
+	Key string `json:"key"`
+
+	// Field is the field of the entity containing the issue, e.g. "AttachedPolicies[0]"
+	Field string `json:"field,omitempty"`
+
+	// Message is a human-readable description of the issue
+	Message string `json:"message"`
+}
+
+// String returns a one-line description of the issue
+func (i Issue) String() string {
+	location := i.Key
+	if i.Field != "" {
+		location += " (" + i.Field + ")"
+	}
+	return fmt.Sprintf("%s %s: %s", i.Kind, location, i.Message)
+}
+
+// HasErrors reports whether any of the provided issues is an error
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns the issues which are errors
+func Errors(issues []Issue) []Issue {
+	return filterIssues(issues, SeverityError)
+}
+
+// Warnings returns the issues which are warnings
+func Warnings(issues []Issue) []Issue {
+	return filterIssues(issues, SeverityWarning)
+}
+
+// LintError is returned by Check for overlays containing errors
+type LintError struct {
+	// Issues are all of the issues found in the overlay, including warnings
+	Issues []Issue
+}
+
+// Error describes the first error found, and how many others there are
+func (e *LintError) Error() string {
+	errs := Errors(e.Issues)
+	switch len(errs) {
+	case 0:
+		return "overlay is invalid"
+	case 1:
+		return fmt.Sprintf("overlay is invalid: %s", errs[0])
+	default:
+		return fmt.Sprintf("overlay is invalid: %s (and %d more errors)", errs[0], len(errs)-1)
+	}
+}
+
+// Check lints an overlay as described by Lint, returning its warnings, or a *LintError if any
+// errors are found
+func Check(o *entities.Overlay, base *entities.Universe) ([]Issue, error) {
+	issues := Lint(o, base)
+	if HasErrors(issues) {
+		return nil, &LintError{Issues: issues}
+	}
+	return issues, nil
+}
+
+// filterIssues returns the issues with the provided severity
+func filterIssues(issues []Issue, severity string) []Issue {
+	var out []Issue
+	for _, i := range issues {
+		if i.Severity == severity {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Lint checks the entities of an overlay for mistakes which would cause simulations against it to
+// behave unexpectedly. It reports:
+//
+//   - errors for malformed ARNs, invalid policy grammar, and ARNs whose account does not match the
+//     entity's AccountId
+//   - warnings for attached policies, permissions boundaries and groups which do not resolve in
+//     the base universe or the overlay, unknown accounts, principal types the simulator does not
+//     support, and resource types it does not understand
+//
+// References are resolved against `base` with the overlay applied on top; if `base` is nil, only
+// the overlay itself and AWS managed policies are considered. Issues are returned in a stable order
+func Lint(o *entities.Overlay, base *entities.Universe) []Issue {
+	l := linter{uvs: []*entities.Universe{o.Universe}}
+	if base != nil {
+		l.uvs = base.Overlay(o.Universe)
+	}
+
+	uv := o.Universe
+	for _, arn := range uv.PolicyArns() {
+		p, _ := uv.Policy(arn)
+		l.lintPolicy(p)
+	}
+	for _, arn := range uv.GroupArns() {
+		g, _ := uv.Group(arn)
+		l.lintGroup(g)
+	}
+	for _, arn := range uv.PrincipalArns() {
+		p, _ := uv.Principal(arn)
+		l.lintPrincipal(p)
+	}
+	for _, arn := range uv.ResourceArns() {
+		r, _ := uv.Resource(arn)
+		l.lintResource(r)
+	}
+
+	return l.issues
+}
+
+// linter accumulates the issues found by Lint
+type linter struct {
+	uvs    []*entities.Universe
+	issues []Issue
+}
+
+// report records an issue
+func (l *linter) report(severity, code, kind, key, field, format string, args ...any) {
+	l.issues = append(l.issues, Issue{
+		Severity: severity,
+		Code:     code,
+		Kind:     kind,
+		Key:      key,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) lintPolicy(p *entities.ManagedPolicy) {
+	if !l.lintArn("policy", p.Arn, p.AccountId) {
+		return
+	}
+	l.lintDocument("policy", p.Arn, "Policy", &p.Policy)
+}
+
+func (l *linter) lintGroup(g *entities.Group) {
+	if !l.lintArn("group", g.Arn, g.AccountId) {
+		return
+	}
+	l.lintAccount("group", g.Arn, g.AccountId)
+	for i := range g.InlinePolicies {
+		l.lintDocument("group", g.Arn, fmt.Sprintf("InlinePolicies[%d]", i), &g.InlinePolicies[i])
+	}
+	for i, arn := range g.AttachedPolicies {
+		l.lintPolicyRef("group", g.Arn, fmt.Sprintf("AttachedPolicies[%d]", i), arn)
+	}
+}
+
+func (l *linter) lintPrincipal(p *entities.Principal) {
+	if !l.lintArn("principal", p.Arn, p.AccountId) {
+		return
+	}
+	l.lintAccount("principal", p.Arn, p.AccountId)
+
+	switch p.Type {
+	case "AWS::IAM::Role", "AWS::IAM::User":
+	default:
+		l.report(SeverityWarning, IssueUnsupportedType, "principal", p.Arn, "Type",
+			"principal type '%s' is not supported by the simulator", p.Type)
+	}
+
+	for i := range p.InlinePolicies {
+		l.lintDocument("principal", p.Arn, fmt.Sprintf("InlinePolicies[%d]", i),
+			&p.InlinePolicies[i])
+	}
+	for i, arn := range p.AttachedPolicies {
+		l.lintPolicyRef("principal", p.Arn, fmt.Sprintf("AttachedPolicies[%d]", i), arn)
+	}
+	if p.PermissionsBoundary != "" {
+		l.lintPolicyRef("principal", p.Arn, "PermissionsBoundary", p.PermissionsBoundary)
+	}
+	for i, arn := range p.Groups {
+		field := fmt.Sprintf("Groups[%d]", i)
+		if _, ok, _ := entities.LookupGroup(l.uvs, arn); !ok {
+			l.report(SeverityWarning, IssueUnresolvedGroup, "principal", p.Arn, field,
+				"group '%s' is not defined in the overlay or the base universe", arn)
+		}
+	}
+}
+
+func (l *linter) lintResource(r *entities.Resource) {
+	if !l.lintArn("resource", r.Arn, r.AccountId) {
+		return
+	}
+	if r.AccountId != "" {
+		l.lintAccount("resource", r.Arn, r.AccountId)
+	}
+	if !knownResourceType(r) {
+		l.report(SeverityWarning, IssueUnknownResourceType, "resource", r.Arn, "Type",
+			"resource type '%s' is not understood by the simulator", r.Type)
+	}
+	if !r.Policy.Empty() {
+		l.lintDocument("resource", r.Arn, "Policy", &r.Policy)
+	}
+}
+
+// lintArn checks that an entity's ARN is well-formed and that its account segment, if any,
+// matches the entity's AccountId, returning false if the ARN is unusable
+func (l *linter) lintArn(kind, arn, accountId string) bool {
+	components := arnlib.Components(arn)
+	if len(components) != 6 || components[0] != "arn" || components[2] == "" {
+		l.report(SeverityError, IssueInvalidArn, kind, arn, "Arn", "malformed ARN '%s'", arn)
+		return false
+	}
+
+	// AWS managed policies are in the pseudo-account "aws", and S3 ARNs omit the account entirely
+	account := components[4]
+	if account != "" && account != "aws" && accountId != "" && account != accountId {
+		l.report(SeverityError, IssueAccountMismatch, kind, arn, "AccountId",
+			"ARN is in account '%s' but AccountId is '%s'", account, accountId)
+	}
+	return true
+}
+
+// lintAccount checks that the account containing an entity is known
+func (l *linter) lintAccount(kind, arn, accountId string) {
+	if accountId == "" {
+		l.report(SeverityWarning, IssueUnknownAccount, kind, arn, "AccountId", "missing AccountId")
+		return
+	}
+	if _, ok, _ := entities.LookupAccount(l.uvs, accountId); !ok {
+		l.report(SeverityWarning, IssueUnknownAccount, kind, arn, "AccountId",
+			"account '%s' is not defined in the overlay or the base universe", accountId)
+	}
+}
+
+// lintDocument checks the grammar of a policy document
+func (l *linter) lintDocument(kind, arn, field string, p *policy.Policy) {
+	if err := p.Validate(); err != nil {
+		l.report(SeverityError, IssueInvalidPolicy, kind, arn, field, "invalid policy: %v", err)
+	}
+}
+
+// lintPolicyRef checks that a referenced managed policy resolves
+func (l *linter) lintPolicyRef(kind, arn, field string, ref entities.Arn) {
+	if _, ok, _ := entities.LookupPolicy(l.uvs, ref); ok {
+		return
+	}
+	if _, ok := managedpolicies.Get(ref); ok {
+		return
+	}
+	l.report(SeverityWarning, IssueUnresolvedPolicy, kind, arn, field,
+		"policy '%s' is not defined in the overlay or the base universe", ref)
+}
+
+// knownResourceType reports whether the simulator understands a resource's type, i.e. whether it is
+// of the form AWS::<Service>::<Type> for a service with known actions
+func knownResourceType(r *entities.Resource) bool {
+	components := strings.Split(r.Type, "::")
+	if len(components) < 3 || components[0] != "AWS" {
+		return false
+	}
+
+	// CloudFormation service names don't always match IAM service prefixes (e.g. the
+	// ElasticLoadBalancingV2 type vs. the elasticloadbalancing prefix), so accept either
+	return len(sar.ActionsByService(components[1])) > 0 ||
+		len(sar.ActionsByService(arnlib.Service(r.Arn))) > 0
+}
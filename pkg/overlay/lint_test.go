@@ -0,0 +1,286 @@
+package overlay
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+// validPolicy is a policy document which passes validation
+var validPolicy = policy.Policy{
+	Statement: []policy.Statement{
+		{
+			Effect:   policy.EFFECT_ALLOW,
+			Action:   []string{"s3:GetObject"},
+			Resource: []string{"*"},
+		},
+	},
+}
+
+// invalidPolicy is a policy document which is missing a resource
+var invalidPolicy = policy.Policy{
+	Statement: []policy.Statement{
+		{
+			Effect: policy.EFFECT_ALLOW,
+			Action: []string{"s3:GetObject"},
+		},
+	},
+}
+
+// codes summarizes issues as "<severity>:<code>:<field>" for comparison
+func codes(issues []Issue) []string {
+	var out []string
+	for _, i := range issues {
+		out = append(out, i.Severity+":"+i.Code+":"+i.Field)
+	}
+	return out
+}
+
+func TestLint(t *testing.T) {
+	base := entities.NewUniverse()
+	base.PutAccount(entities.Account{Id: "111111111111"})
+	base.PutGroup(entities.Group{
+		Type:      "AWS::IAM::Group",
+		AccountId: "111111111111",
+		Arn:       "arn:aws:iam::111111111111:group/base",
+	})
+	base.PutPolicy(entities.ManagedPolicy{
+		Type:      "AWS::IAM::Policy",
+		AccountId: "111111111111",
+		Arn:       "arn:aws:iam::111111111111:policy/base",
+		Policy:    validPolicy,
+	})
+
+	tests := []struct {
+		name  string
+		setup func(uv *entities.Universe)
+		base  *entities.Universe
+		want  []string
+	}{
+		{
+			name: "clean",
+			setup: func(uv *entities.Universe) {
+				uv.PutPolicy(entities.ManagedPolicy{
+					AccountId: "111111111111",
+					Arn:       "arn:aws:iam::111111111111:policy/new",
+					Policy:    validPolicy,
+				})
+				uv.PutPrincipal(entities.Principal{
+					Type:           "AWS::IAM::Role",
+					AccountId:      "111111111111",
+					Arn:            "arn:aws:iam::111111111111:role/clean",
+					InlinePolicies: []policy.Policy{validPolicy},
+					AttachedPolicies: []entities.Arn{
+						"arn:aws:iam::111111111111:policy/base",
+						"arn:aws:iam::111111111111:policy/new",
+						"arn:aws:iam::aws:policy/ReadOnlyAccess",
+					},
+					Groups: []entities.Arn{"arn:aws:iam::111111111111:group/base"},
+				})
+				uv.PutResource(entities.Resource{
+					Type:   "AWS::S3::Bucket",
+					Arn:    "arn:aws:s3:::bucket",
+					Policy: validPolicy,
+				})
+			},
+			base: base,
+		},
+		{
+			name: "invalid_policy",
+			setup: func(uv *entities.Universe) {
+				uv.PutPolicy(entities.ManagedPolicy{
+					AccountId: "111111111111",
+					Arn:       "arn:aws:iam::111111111111:policy/bad",
+					Policy:    invalidPolicy,
+				})
+				uv.PutResource(entities.Resource{
+					Type:   "AWS::S3::Bucket",
+					Arn:    "arn:aws:s3:::bucket",
+					Policy: invalidPolicy,
+				})
+			},
+			base: base,
+			want: []string{
+				"error:invalid-policy:Policy",
+				"error:invalid-policy:Policy",
+			},
+		},
+		{
+			name: "unresolved_references",
+			setup: func(uv *entities.Universe) {
+				uv.PutPrincipal(entities.Principal{
+					Type:                "AWS::IAM::User",
+					AccountId:           "111111111111",
+					Arn:                 "arn:aws:iam::111111111111:user/typo",
+					InlinePolicies:      []policy.Policy{validPolicy, invalidPolicy},
+					AttachedPolicies:    []entities.Arn{"arn:aws:iam::111111111111:policy/bsae"},
+					PermissionsBoundary: "arn:aws:iam::111111111111:policy/missing",
+					Groups:              []entities.Arn{"arn:aws:iam::111111111111:group/bsae"},
+				})
+			},
+			base: base,
+			want: []string{
+				"error:invalid-policy:InlinePolicies[1]",
+				"warning:unresolved-policy:AttachedPolicies[0]",
+				"warning:unresolved-policy:PermissionsBoundary",
+				"warning:unresolved-group:Groups[0]",
+			},
+		},
+		{
+			name: "unknown_account",
+			setup: func(uv *entities.Universe) {
+				uv.PutPrincipal(entities.Principal{
+					Type:      "AWS::IAM::Role",
+					AccountId: "222222222222",
+					Arn:       "arn:aws:iam::222222222222:role/elsewhere",
+				})
+				uv.PutGroup(entities.Group{
+					Arn: "arn:aws:iam::111111111111:group/noaccount",
+				})
+			},
+			base: base,
+			want: []string{
+				"warning:unknown-account:AccountId",
+				"warning:unknown-account:AccountId",
+			},
+		},
+		{
+			name: "account_in_overlay",
+			setup: func(uv *entities.Universe) {
+				uv.PutAccount(entities.Account{Id: "222222222222"})
+				uv.PutPrincipal(entities.Principal{
+					Type:      "AWS::IAM::Role",
+					AccountId: "222222222222",
+					Arn:       "arn:aws:iam::222222222222:role/elsewhere",
+				})
+			},
+			base: base,
+		},
+		{
+			name: "account_mismatch",
+			setup: func(uv *entities.Universe) {
+				uv.PutPrincipal(entities.Principal{
+					Type:      "AWS::IAM::Role",
+					AccountId: "111111111111",
+					Arn:       "arn:aws:iam::111111111112:role/typo",
+				})
+			},
+			base: base,
+			want: []string{"error:account-mismatch:AccountId"},
+		},
+		{
+			name: "unsupported_types",
+			setup: func(uv *entities.Universe) {
+				uv.PutPrincipal(entities.Principal{
+					Type:      "AWS::IAM::Rol",
+					AccountId: "111111111111",
+					Arn:       "arn:aws:iam::111111111111:role/typo",
+				})
+				uv.PutResource(entities.Resource{
+					Type: "AWS::Nonexistent::Thing",
+					Arn:  "arn:aws:nonexistent:us-east-1:111111111111:thing/x",
+				})
+				uv.PutResource(entities.Resource{
+					Type: "S3 Bucket",
+					Arn:  "arn:aws:s3:::bucket",
+				})
+				uv.PutResource(entities.Resource{
+					Type: "AWS::ElasticLoadBalancingV2::LoadBalancer",
+					Arn:  "arn:aws:elasticloadbalancing:us-east-1:111111111111:loadbalancer/x",
+				})
+			},
+			base: base,
+			want: []string{
+				"warning:unsupported-type:Type",
+				"warning:unknown-resource-type:Type",
+				"warning:unknown-resource-type:Type",
+			},
+		},
+		{
+			name: "malformed_arn",
+			setup: func(uv *entities.Universe) {
+				uv.PutPrincipal(entities.Principal{
+					Type:             "AWS::IAM::Role",
+					Arn:              "role/typo",
+					AttachedPolicies: []entities.Arn{"arn:aws:iam::111111111111:policy/missing"},
+				})
+			},
+			base: base,
+			want: []string{"error:invalid-arn:Arn"},
+		},
+		{
+			name: "no_base",
+			setup: func(uv *entities.Universe) {
+				uv.PutPrincipal(entities.Principal{
+					Type:      "AWS::IAM::Role",
+					AccountId: "111111111111",
+					Arn:       "arn:aws:iam::111111111111:role/x",
+					AttachedPolicies: []entities.Arn{
+						"arn:aws:iam::aws:policy/ReadOnlyAccess",
+						"arn:aws:iam::111111111111:policy/base",
+					},
+				})
+			},
+			want: []string{
+				"warning:unknown-account:AccountId",
+				"warning:unresolved-policy:AttachedPolicies[1]",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := entities.NewOverlay(tc.name)
+			tc.setup(o.Universe)
+
+			got := codes(Lint(o, tc.base))
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("expected issues %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLint_TombstonedReference(t *testing.T) {
+	base := entities.NewUniverse()
+	base.PutAccount(entities.Account{Id: "111111111111"})
+	base.PutPolicy(entities.ManagedPolicy{Arn: "arn:aws:iam::111111111111:policy/deleted"})
+
+	o := entities.NewOverlay("tombstone")
+	o.Universe.PutTombstone("arn:aws:iam::111111111111:policy/deleted")
+	o.Universe.PutPrincipal(entities.Principal{
+		Type:             "AWS::IAM::Role",
+		AccountId:        "111111111111",
+		Arn:              "arn:aws:iam::111111111111:role/x",
+		AttachedPolicies: []entities.Arn{"arn:aws:iam::111111111111:policy/deleted"},
+	})
+
+	got := codes(Lint(o, base))
+	want := []string{"warning:unresolved-policy:AttachedPolicies[0]"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected issues %v, got %v", want, got)
+	}
+}
+
+func TestIssues(t *testing.T) {
+	issues := []Issue{
+		{Severity: SeverityWarning, Kind: "principal", Key: "arn", Message: "first"},
+		{Severity: SeverityError, Kind: "policy", Key: "arn", Field: "Policy", Message: "second"},
+	}
+
+	if !HasErrors(issues) || HasErrors(issues[:1]) || HasErrors(nil) {
+		t.Fatalf("unexpected result from HasErrors")
+	}
+	if errs := Errors(issues); len(errs) != 1 || errs[0].Message != "second" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if warnings := Warnings(issues); len(warnings) != 1 || warnings[0].Message != "first" {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+
+	if got, want := issues[1].String(), "policy arn (Policy): second"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nsiow/yams/pkg/entities"
 )
@@ -12,8 +13,9 @@ import (
 // It is safe for concurrent use.
 type MemoryStore struct {
 	mu       sync.RWMutex
-	overlays map[string]*entities.Overlay // keyed by ID
-	byName   map[string]string            // name -> ID mapping
+	overlays map[string]*entities.Overlay   // keyed by ID
+	byName   map[string]string              // name -> ID mapping
+	history  map[string][]*entities.Overlay // ID -> previous revisions, oldest first
 }
 
 // NewMemoryStore creates a new in-memory overlay store.
@@ -21,6 +23,7 @@ func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		overlays: make(map[string]*entities.Overlay),
 		byName:   make(map[string]string),
+		history:  make(map[string][]*entities.Overlay),
 	}
 }
 
@@ -33,6 +36,9 @@ func (s *MemoryStore) Create(ctx context.Context, overlay *entities.Overlay) err
 		return ErrAlreadyExists
 	}
 
+	overlay.Revision = 1
+	overlay.UpdatedAt = overlay.CreatedAt
+
 	// Store a copy to prevent external mutations
 	s.overlays[overlay.ID] = s.clone(overlay)
 	s.byName[overlay.Name] = overlay.ID
@@ -46,7 +52,7 @@ func (s *MemoryStore) Get(ctx context.Context, id string) (*entities.Overlay, er
 	defer s.mu.RUnlock()
 
 	overlay, exists := s.overlays[id]
-	if !exists {
+	if !exists || overlay.Expired(time.Now()) {
 		return nil, ErrNotFound
 	}
 
@@ -65,14 +71,14 @@ func (s *MemoryStore) GetByName(ctx context.Context, name string) (*entities.Ove
 	}
 
 	overlay, exists := s.overlays[id]
-	if !exists {
+	if !exists || overlay.Expired(time.Now()) {
 		return nil, ErrNotFound
 	}
 
 	return s.clone(overlay), nil
 }
 
-// Update replaces an existing overlay.
+// Update stores a new revision of an existing overlay.
 func (s *MemoryStore) Update(ctx context.Context, overlay *entities.Overlay) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -82,16 +88,64 @@ func (s *MemoryStore) Update(ctx context.Context, overlay *entities.Overlay) err
 		return ErrNotFound
 	}
 
+	revision, err := checkRevision(overlay.Revision, existing.Revision)
+	if err != nil {
+		return err
+	}
+	overlay.Revision = revision
+	overlay.UpdatedAt = time.Now()
+
 	// Remove old name mapping if name changed
 	if existing.Name != overlay.Name {
 		delete(s.byName, existing.Name)
 		s.byName[overlay.Name] = overlay.ID
 	}
 
+	s.history[overlay.ID] = append(s.history[overlay.ID], existing)
 	s.overlays[overlay.ID] = s.clone(overlay)
 	return nil
 }
 
+// Revisions returns the metadata of every revision of an overlay, oldest first.
+func (s *MemoryStore) Revisions(ctx context.Context, id string) ([]entities.OverlayRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	current, exists := s.overlays[id]
+	if !exists || current.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	var revisions []entities.OverlayRevision
+	for _, o := range s.history[id] {
+		revisions = append(revisions, o.RevisionInfo())
+	}
+	return append(revisions, current.RevisionInfo()), nil
+}
+
+// GetRevision retrieves a specific revision of an overlay.
+func (s *MemoryStore) GetRevision(
+	ctx context.Context, id string, revision int) (*entities.Overlay, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	current, exists := s.overlays[id]
+	if !exists || current.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	if revision == current.Revision {
+		return s.clone(current), nil
+	}
+
+	for _, o := range s.history[id] {
+		if o.Revision == revision {
+			return s.clone(o), nil
+		}
+	}
+	return nil, ErrRevisionNotFound
+}
+
 // Delete removes an overlay by ID.
 func (s *MemoryStore) Delete(ctx context.Context, id string) error {
 	s.mu.Lock()
@@ -104,6 +158,7 @@ func (s *MemoryStore) Delete(ctx context.Context, id string) error {
 
 	delete(s.byName, overlay.Name)
 	delete(s.overlays, id)
+	delete(s.history, id)
 	return nil
 }
 
@@ -114,12 +169,16 @@ func (s *MemoryStore) List(ctx context.Context, query string) ([]entities.Overla
 
 	query = strings.ToLower(query)
 	summaries := make([]entities.OverlaySummary, 0, len(s.overlays))
+	now := time.Now()
 
 	for _, overlay := range s.overlays {
 		// Filter by query if provided
 		if query != "" && !strings.Contains(strings.ToLower(overlay.Name), query) {
 			continue
 		}
+		if overlay.Expired(now) {
+			continue
+		}
 		summaries = append(summaries, overlay.Summary())
 	}
 
@@ -131,8 +190,27 @@ func (s *MemoryStore) Exists(ctx context.Context, id string) (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	_, exists := s.overlays[id]
-	return exists, nil
+	overlay, exists := s.overlays[id]
+	return exists && !overlay.Expired(time.Now()), nil
+}
+
+// DeleteExpired removes every overlay which has expired as of `now`.
+func (s *MemoryStore) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted []string
+	for id, overlay := range s.overlays {
+		if !overlay.Expired(now) {
+			continue
+		}
+
+		delete(s.byName, overlay.Name)
+		delete(s.overlays, id)
+		delete(s.history, id)
+		deleted = append(deleted, id)
+	}
+	return deleted, nil
 }
 
 // clone creates a deep copy of an overlay using the serialization methods.
@@ -0,0 +1,384 @@
+package overlay
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/entities"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables used by the SQLiteStore. Every revision of an overlay, including
+// the current one, is stored in overlay_revisions.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS overlays (
+	id       TEXT PRIMARY KEY,
+	name     TEXT NOT NULL,
+	revision INTEGER NOT NULL,
+	data     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS overlays_name ON overlays (name);
+CREATE TABLE IF NOT EXISTS overlay_revisions (
+	id         TEXT NOT NULL,
+	revision   INTEGER NOT NULL,
+	name       TEXT NOT NULL,
+	author     TEXT NOT NULL,
+	message    TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	PRIMARY KEY (id, revision)
+);
+`
+
+// SQLiteStore is a SQLite-backed implementation of the Store interface, for persistent storage
+// on a single host without access to DynamoDB.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (creating if necessary) the SQLite database at the provided path and
+// returns an overlay store backed by it.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	// Transactions take the write lock immediately, so that concurrent updates are serialized
+	// rather than failing when upgrading from a read lock
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=busy_timeout(5000)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Create stores a new overlay as revision 1.
+func (s *SQLiteStore) Create(ctx context.Context, overlay *entities.Overlay) error {
+	stored := *overlay
+	stored.Revision = 1
+	stored.UpdatedAt = overlay.CreatedAt
+
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM overlays WHERE id = ?)", overlay.ID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrAlreadyExists
+		}
+
+		data, err := json.Marshal(stored.ToData())
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO overlays (id, name, revision, data) VALUES (?, ?, ?, ?)",
+			stored.ID, stored.Name, stored.Revision, string(data))
+		if err != nil {
+			return err
+		}
+		return insertRevision(ctx, tx, &stored, data)
+	})
+	if err != nil {
+		return sqliteError("create", err)
+	}
+
+	overlay.Revision, overlay.UpdatedAt = stored.Revision, stored.UpdatedAt
+	return nil
+}
+
+// Get retrieves an overlay by ID.
+func (s *SQLiteStore) Get(ctx context.Context, id string) (*entities.Overlay, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT data FROM overlays WHERE id = ?", id)
+	return unexpired(scanOverlay(row, ErrNotFound))
+}
+
+// GetByName retrieves an overlay by name.
+func (s *SQLiteStore) GetByName(ctx context.Context, name string) (*entities.Overlay, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT data FROM overlays WHERE name = ? LIMIT 1", name)
+	return unexpired(scanOverlay(row, ErrNotFound))
+}
+
+// Update stores a new revision of an existing overlay.
+func (s *SQLiteStore) Update(ctx context.Context, overlay *entities.Overlay) error {
+	stored := *overlay
+	stored.UpdatedAt = time.Now()
+
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		var current int
+		err := tx.QueryRowContext(ctx,
+			"SELECT revision FROM overlays WHERE id = ?", overlay.ID).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if stored.Revision, err = checkRevision(overlay.Revision, current); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(stored.ToData())
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE overlays SET name = ?, revision = ?, data = ? WHERE id = ?",
+			stored.Name, stored.Revision, string(data), stored.ID)
+		if err != nil {
+			return err
+		}
+		return insertRevision(ctx, tx, &stored, data)
+	})
+	if err != nil {
+		return sqliteError("update", err)
+	}
+
+	overlay.Revision, overlay.UpdatedAt = stored.Revision, stored.UpdatedAt
+	return nil
+}
+
+// Revisions returns the metadata of every revision of an overlay, oldest first.
+func (s *SQLiteStore) Revisions(ctx context.Context, id string) ([]entities.OverlayRevision, error) {
+	if exists, err := s.Exists(ctx, id); err != nil || !exists {
+		return nil, cmpErr(err, ErrNotFound)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT revision, name, author, message, created_at FROM overlay_revisions
+		WHERE id = ? ORDER BY revision`, id)
+	if err != nil {
+		return nil, sqliteError("list revisions of", err)
+	}
+	defer rows.Close()
+
+	var revisions []entities.OverlayRevision
+	for rows.Next() {
+		var r entities.OverlayRevision
+		var createdAt string
+		if err := rows.Scan(&r.Revision, &r.Name, &r.Author, &r.Message, &createdAt); err != nil {
+			return nil, sqliteError("list revisions of", err)
+		}
+		if r.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return nil, sqliteError("list revisions of", err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, sqliteError("list revisions of", err)
+	}
+
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+	return revisions, nil
+}
+
+// GetRevision retrieves a specific revision of an overlay.
+func (s *SQLiteStore) GetRevision(
+	ctx context.Context, id string, revision int) (*entities.Overlay, error) {
+
+	if exists, err := s.Exists(ctx, id); err != nil || !exists {
+		return nil, cmpErr(err, ErrNotFound)
+	}
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT data FROM overlay_revisions WHERE id = ? AND revision = ?", id, revision)
+	return scanOverlay(row, ErrRevisionNotFound)
+}
+
+// Delete removes an overlay by ID, including its previous revisions.
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM overlays WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return cmpErr(err, ErrNotFound)
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM overlay_revisions WHERE id = ?", id)
+		return err
+	})
+	return sqliteError("delete", err)
+}
+
+// List returns summaries of all overlays, optionally filtered by query.
+func (s *SQLiteStore) List(ctx context.Context, query string) ([]entities.OverlaySummary, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, data FROM overlays")
+	if err != nil {
+		return nil, sqliteError("list", err)
+	}
+	defer rows.Close()
+
+	query = strings.ToLower(query)
+	summaries := []entities.OverlaySummary{}
+	now := time.Now()
+	for rows.Next() {
+		var name, data string
+		if err := rows.Scan(&name, &data); err != nil {
+			return nil, sqliteError("list", err)
+		}
+
+		// Filter by query if provided
+		if query != "" && !strings.Contains(strings.ToLower(name), query) {
+			continue
+		}
+
+		o, err := unmarshalOverlay(data)
+		if err != nil {
+			return nil, err
+		}
+		if o.Expired(now) {
+			continue
+		}
+		summaries = append(summaries, o.Summary())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, sqliteError("list", err)
+	}
+
+	return summaries, nil
+}
+
+// Exists checks if an overlay with the given ID exists.
+func (s *SQLiteStore) Exists(ctx context.Context, id string) (bool, error) {
+	_, err := s.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteExpired removes every overlay which has expired as of `now`.
+func (s *SQLiteStore) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	var deleted []string
+	err := s.transact(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT data FROM overlays")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var data string
+			if err := rows.Scan(&data); err != nil {
+				return err
+			}
+			o, err := unmarshalOverlay(data)
+			if err != nil {
+				return err
+			}
+			if o.Expired(now) {
+				deleted = append(deleted, o.ID)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range deleted {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM overlays WHERE id = ?", id); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM overlay_revisions WHERE id = ?", id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, sqliteError("delete expired", err)
+	}
+	return deleted, nil
+}
+
+// transact runs the provided function within a transaction, committing it if the function
+// succeeds and rolling it back otherwise.
+func (s *SQLiteStore) transact(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertRevision records a revision of an overlay in the overlay_revisions table.
+func insertRevision(ctx context.Context, tx *sql.Tx, o *entities.Overlay, data []byte) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO overlay_revisions (id, revision, name, author, message, created_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		o.ID, o.Revision, o.Name, o.Author, o.Message,
+		o.UpdatedAt.Format(time.RFC3339Nano), string(data))
+	return err
+}
+
+// scanOverlay unmarshals an overlay from a row containing its data, returning `missing` if there
+// is no such row.
+func scanOverlay(row *sql.Row, missing error) (*entities.Overlay, error) {
+	var data string
+	err := row.Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, missing
+	}
+	if err != nil {
+		return nil, sqliteError("get", err)
+	}
+	return unmarshalOverlay(data)
+}
+
+// unmarshalOverlay converts the stored JSON representation of an overlay to an Overlay.
+func unmarshalOverlay(data string) (*entities.Overlay, error) {
+	var od entities.OverlayData
+	if err := json.Unmarshal([]byte(data), &od); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal overlay data: %w", err)
+	}
+	return entities.FromData(od), nil
+}
+
+// cmpErr returns err if it is non-nil, and fallback otherwise.
+func cmpErr(err, fallback error) error {
+	if err != nil {
+		return err
+	}
+	return fallback
+}
+
+// sqliteError wraps unexpected database errors, passing through the errors defined by Store.
+func sqliteError(op string, err error) error {
+	if err == nil ||
+		errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrAlreadyExists) ||
+		errors.Is(err, ErrConflict) ||
+		errors.Is(err, ErrRevisionNotFound) {
+		return err
+	}
+	return fmt.Errorf("failed to %s overlay: %w", op, err)
+}
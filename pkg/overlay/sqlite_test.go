@@ -0,0 +1,50 @@
+package overlay
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+func TestSQLiteStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overlays.db")
+	ctx := context.Background()
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	overlay := entities.NewOverlay("test-overlay")
+	overlay.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/test"})
+	_ = store.Create(ctx, overlay)
+	_ = store.Update(ctx, overlay)
+	store.Close()
+
+	// overlays and their history survive reopening the database
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer store.Close()
+
+	retrieved, err := store.Get(ctx, overlay.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if retrieved.Revision != 2 || retrieved.NumPrincipals() != 1 {
+		t.Errorf("unexpected overlay after reopen: %+v", retrieved.Summary())
+	}
+
+	revisions, err := store.Revisions(ctx, overlay.ID)
+	if err != nil || len(revisions) != 2 {
+		t.Errorf("expected 2 revisions after reopen, got %v (%v)", revisions, err)
+	}
+}
+
+func TestSQLiteStore_InvalidPath(t *testing.T) {
+	if _, err := NewSQLiteStore(filepath.Join(t.TempDir(), "missing", "overlays.db")); err == nil {
+		t.Error("expected error for database in missing directory")
+	}
+}
@@ -4,22 +4,33 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/nsiow/yams/pkg/entities"
 )
 
 // Common errors returned by OverlayStore implementations.
 var (
-	ErrNotFound      = errors.New("overlay not found")
-	ErrAlreadyExists = errors.New("overlay already exists")
+	ErrNotFound         = errors.New("overlay not found")
+	ErrAlreadyExists    = errors.New("overlay already exists")
+	ErrConflict         = errors.New("overlay was modified by another update")
+	ErrRevisionNotFound = errors.New("overlay revision not found")
 )
 
 // Store defines the interface for overlay storage backends.
 // All operations are context-aware to support timeouts and cancellation.
+//
+// Stores keep the history of each overlay: Create stores revision 1, and every Update stores the
+// next revision while retaining the previous ones until the overlay is deleted.
+//
+// Expired overlays are treated as deleted by every read, whether or not DeleteExpired has removed
+// them yet.
 type Store interface {
-	// Create stores a new overlay. Returns ErrAlreadyExists if an overlay
-	// with the same ID already exists.
+	// Create stores a new overlay as revision 1. Returns ErrAlreadyExists if an overlay
+	// with the same ID already exists. On success, the overlay's Revision and UpdatedAt
+	// are set to those of the stored revision.
 	Create(ctx context.Context, overlay *entities.Overlay) error
 
 	// Get retrieves an overlay by ID. Returns ErrNotFound if not found.
@@ -28,9 +39,20 @@ type Store interface {
 	// GetByName retrieves an overlay by name. Returns ErrNotFound if not found.
 	GetByName(ctx context.Context, name string) (*entities.Overlay, error)
 
-	// Update replaces an existing overlay. Returns ErrNotFound if not found.
+	// Update stores a new revision of an existing overlay. Returns ErrNotFound if not found.
+	// If the overlay's Revision is non-zero, it must be the current revision of the stored
+	// overlay, otherwise ErrConflict is returned. On success, the overlay's Revision and
+	// UpdatedAt are set to those of the new revision.
 	Update(ctx context.Context, overlay *entities.Overlay) error
 
+	// Revisions returns the metadata of every revision of an overlay, oldest first.
+	// Returns ErrNotFound if not found.
+	Revisions(ctx context.Context, id string) ([]entities.OverlayRevision, error)
+
+	// GetRevision retrieves a specific revision of an overlay. Returns ErrNotFound if the
+	// overlay is not found, or ErrRevisionNotFound if the revision does not exist.
+	GetRevision(ctx context.Context, id string, revision int) (*entities.Overlay, error)
+
 	// Delete removes an overlay by ID. Returns ErrNotFound if not found.
 	Delete(ctx context.Context, id string) error
 
@@ -40,23 +62,76 @@ type Store interface {
 
 	// Exists checks if an overlay with the given ID exists.
 	Exists(ctx context.Context, id string) (bool, error)
+
+	// DeleteExpired removes every overlay which has expired as of `now`, including its previous
+	// revisions, and returns the IDs of the removed overlays.
+	DeleteExpired(ctx context.Context, now time.Time) ([]string, error)
+}
+
+// checkRevision checks that an update based on revision `expected` (or on any revision, if zero)
+// may replace the stored revision `current`, returning the number of the new revision
+func checkRevision(expected, current int) (int, error) {
+	if expected != 0 && expected != current {
+		return 0, ErrConflict
+	}
+	return current + 1, nil
+}
+
+// unexpired returns the provided overlay, or ErrNotFound if it has expired.
+func unexpired(overlay *entities.Overlay, err error) (*entities.Overlay, error) {
+	if err != nil {
+		return nil, err
+	}
+	if overlay.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return overlay, nil
 }
 
 // NewStore creates a Store based on the provided spec string.
 // Supported formats:
 //   - "" or "memory": in-memory store
-//   - "ddb://<table-name>": DynamoDB store using the specified table
+//   - "ddb://<table-name>[?sweep=true]": DynamoDB store using the specified table; see
+//     [DynamoDBStore.ScanExpired] for the sweep option
+//   - "file://<directory>": file-system store in the specified directory
+//   - "sqlite://<path>": SQLite store using the specified database file
 func NewStore(spec string) (Store, error) {
 	if spec == "" || spec == "memory" {
 		return NewMemoryStore(), nil
 	}
 
 	if strings.HasPrefix(spec, "ddb://") {
-		table := strings.TrimPrefix(spec, "ddb://")
+		table, query, _ := strings.Cut(strings.TrimPrefix(spec, "ddb://"), "?")
 		if table == "" {
 			return nil, fmt.Errorf("invalid overlay spec: table name required for ddb://")
 		}
-		return NewDynamoDBStore(table)
+		params, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid overlay spec: %w", err)
+		}
+
+		store, err := NewDynamoDBStore(table)
+		if err != nil {
+			return nil, err
+		}
+		store.ScanExpired = params.Get("sweep") == "true"
+		return store, nil
+	}
+
+	if strings.HasPrefix(spec, "file://") {
+		dir := strings.TrimPrefix(spec, "file://")
+		if dir == "" {
+			return nil, fmt.Errorf("invalid overlay spec: directory required for file://")
+		}
+		return NewFileStore(dir)
+	}
+
+	if strings.HasPrefix(spec, "sqlite://") {
+		path := strings.TrimPrefix(spec, "sqlite://")
+		if path == "" {
+			return nil, fmt.Errorf("invalid overlay spec: database path required for sqlite://")
+		}
+		return NewSQLiteStore(path)
 	}
 
 	return nil, fmt.Errorf("unknown overlay store spec: %s", spec)
@@ -0,0 +1,531 @@
+package overlay
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+// storeBackends constructs an empty instance of every Store implementation, for use by the
+// conformance tests
+var storeBackends = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store {
+		return NewMemoryStore()
+	},
+	"dynamodb": func(t *testing.T) Store {
+		store := NewDynamoDBStoreWithClient(newMockClient(), "test-table")
+		store.ScanExpired = true
+		return store
+	},
+	"file": func(t *testing.T) Store {
+		store, err := NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewFileStore failed: %v", err)
+		}
+		return store
+	},
+	"sqlite": func(t *testing.T) Store {
+		store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "overlays.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteStore failed: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	},
+}
+
+// TestStore_Conformance runs every Store implementation through the same set of checks
+func TestStore_Conformance(t *testing.T) {
+	tests := map[string]func(t *testing.T, store Store){
+		"create":    testStoreCreate,
+		"get":       testStoreGet,
+		"get_name":  testStoreGetByName,
+		"update":    testStoreUpdate,
+		"delete":    testStoreDelete,
+		"list":      testStoreList,
+		"exists":    testStoreExists,
+		"isolation": testStoreIsolation,
+		"revisions": testStoreRevisions,
+		"expiry":    testStoreExpiry,
+		"expired":   testStoreExpiredUnswept,
+	}
+
+	for backend, newStore := range storeBackends {
+		t.Run(backend, func(t *testing.T) {
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					test(t, newStore(t))
+				})
+			}
+		})
+	}
+}
+
+// testStoreCreate checks that overlays can be created once per ID
+func testStoreCreate(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("test-overlay")
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if overlay.Revision != 1 || !overlay.UpdatedAt.Equal(overlay.CreatedAt) {
+		t.Errorf("unexpected revision after create: %d (%v)", overlay.Revision, overlay.UpdatedAt)
+	}
+
+	if err := store.Create(ctx, overlay); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+}
+
+// testStoreGet checks that overlays are retrieved by ID with all of their contents
+func testStoreGet(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("test-overlay")
+	overlay.Author = "alice"
+	overlay.Message = "initial"
+	overlay.Universe.PutAccount(entities.Account{Id: "123456789012", OrgId: "o-123"})
+	overlay.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/test"})
+	overlay.Universe.PutResource(entities.Resource{Arn: "arn:aws:s3:::bucket"})
+	overlay.Universe.PutTombstone("arn:aws:s3:::deleted")
+	overlay.Universe.PutPatch(entities.Patch{
+		Arn:   "arn:aws:iam::123456789012:role/test",
+		Merge: map[string]any{"PermissionsBoundary": "arn:aws:iam::123456789012:policy/b"},
+	})
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	retrieved, err := store.Get(ctx, overlay.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if retrieved.ID != overlay.ID || retrieved.Name != overlay.Name {
+		t.Errorf("expected %s/%s, got %s/%s", overlay.ID, overlay.Name, retrieved.ID, retrieved.Name)
+	}
+	if !retrieved.CreatedAt.Equal(overlay.CreatedAt) {
+		t.Errorf("expected createdAt %v, got %v", overlay.CreatedAt, retrieved.CreatedAt)
+	}
+	if retrieved.Revision != 1 || retrieved.Author != "alice" || retrieved.Message != "initial" {
+		t.Errorf("unexpected revision metadata: %d/%s/%s",
+			retrieved.Revision, retrieved.Author, retrieved.Message)
+	}
+	if retrieved.NumAccounts() != 1 || retrieved.NumPrincipals() != 1 ||
+		retrieved.NumResources() != 1 || retrieved.NumTombstones() != 1 ||
+		retrieved.NumPatches() != 1 {
+		t.Errorf("unexpected overlay contents: %+v", retrieved.Summary())
+	}
+
+	if _, err := store.Get(ctx, "non-existent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// testStoreGetByName checks that overlays are retrieved by name
+func testStoreGetByName(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("test-overlay")
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	retrieved, err := store.GetByName(ctx, "test-overlay")
+	if err != nil {
+		t.Fatalf("GetByName failed: %v", err)
+	}
+	if retrieved.ID != overlay.ID {
+		t.Errorf("expected ID %q, got %q", overlay.ID, retrieved.ID)
+	}
+
+	if _, err := store.GetByName(ctx, "non-existent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// testStoreUpdate checks that updates replace the contents and name of an existing overlay
+func testStoreUpdate(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("old-name")
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	overlay.Name = "new-name"
+	overlay.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/test"})
+	if err := store.Update(ctx, overlay); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	retrieved, err := store.GetByName(ctx, "new-name")
+	if err != nil {
+		t.Fatalf("GetByName failed: %v", err)
+	}
+	if retrieved.ID != overlay.ID || retrieved.NumPrincipals() != 1 {
+		t.Errorf("unexpected overlay after update: %+v", retrieved.Summary())
+	}
+	if _, err := store.GetByName(ctx, "old-name"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected old name to be gone, got %v", err)
+	}
+
+	missing := entities.NewOverlay("missing")
+	if err := store.Update(ctx, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// testStoreDelete checks that deleted overlays can no longer be retrieved
+func testStoreDelete(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("test-overlay")
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := store.Delete(ctx, overlay.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, overlay.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, overlay.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// the ID may be reused once the overlay is deleted
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create after delete failed: %v", err)
+	}
+}
+
+// testStoreList checks that summaries are listed and filtered by name
+func testStoreList(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	summaries, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(summaries) != 0 {
+		t.Errorf("expected empty list, got %v", summaries)
+	}
+
+	for _, name := range []string{"prod-access", "dev-access", "Prod-Deny"} {
+		overlay := entities.NewOverlay(name)
+		overlay.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/x"})
+		if err := store.Create(ctx, overlay); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	summaries, err = store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(summaries) != 3 {
+		t.Fatalf("expected 3 summaries, got %d", len(summaries))
+	}
+	for _, s := range summaries {
+		if s.NumPrincipals != 1 || s.Revision != 1 {
+			t.Errorf("unexpected summary: %+v", s)
+		}
+	}
+
+	summaries, err = store.List(ctx, "prod")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Errorf("expected case-insensitive query to match 2 overlays, got %v", summaries)
+	}
+}
+
+// testStoreExists checks existence of created and missing overlays
+func testStoreExists(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("test-overlay")
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	for id, want := range map[string]bool{overlay.ID: true, "non-existent": false} {
+		exists, err := store.Exists(ctx, id)
+		if err != nil {
+			t.Fatalf("Exists failed: %v", err)
+		}
+		if exists != want {
+			t.Errorf("Exists(%q) = %v, expected %v", id, exists, want)
+		}
+	}
+}
+
+// testStoreIsolation checks that stored overlays are not affected by mutations of the values
+// passed to or returned from the store
+func testStoreIsolation(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("test-overlay")
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	overlay.Universe.PutResource(entities.Resource{Arn: "arn:aws:s3:::created"})
+
+	retrieved, err := store.Get(ctx, overlay.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	retrieved.Universe.PutResource(entities.Resource{Arn: "arn:aws:s3:::retrieved"})
+
+	retrieved, err = store.Get(ctx, overlay.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if retrieved.NumResources() != 0 {
+		t.Errorf("expected store to be isolated from external mutations")
+	}
+}
+
+// testStoreExpiry checks that expiry is stored, and that only expired overlays are deleted along
+// with their history
+func testStoreExpiry(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	expired := entities.NewOverlay("expired")
+	expired.ExpiresAt = now.Add(-time.Minute)
+	expiring := entities.NewOverlay("expiring")
+	expiring.ExpiresAt = now.Add(time.Hour)
+	permanent := entities.NewOverlay("permanent")
+	for _, o := range []*entities.Overlay{expired, expiring, permanent} {
+		if err := store.Create(ctx, o); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	if err := store.Update(ctx, expired); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	retrieved, err := store.Get(ctx, expiring.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !retrieved.ExpiresAt.Equal(expiring.ExpiresAt) {
+		t.Errorf("expected expiry %v, got %v", expiring.ExpiresAt, retrieved.ExpiresAt)
+	}
+
+	deleted, err := store.DeleteExpired(ctx, now)
+	if err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+	if !slices.Equal(deleted, []string{expired.ID}) {
+		t.Errorf("expected only %q to be deleted, got %v", expired.ID, deleted)
+	}
+	if _, err := store.Get(ctx, expired.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for expired overlay, got %v", err)
+	}
+	if _, err := store.Revisions(ctx, expired.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for revisions of expired overlay, got %v", err)
+	}
+
+	summaries, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 remaining overlays, got %v", summaries)
+	}
+	for _, s := range summaries {
+		if s.ID == expiring.ID && !s.ExpiresAt.Equal(expiring.ExpiresAt) {
+			t.Errorf("expected expiry in summary, got %v", s.ExpiresAt)
+		}
+		if s.ID == permanent.ID && !s.ExpiresAt.IsZero() {
+			t.Errorf("expected no expiry in summary, got %v", s.ExpiresAt)
+		}
+	}
+
+	// nothing else has expired yet
+	if deleted, err := store.DeleteExpired(ctx, now); err != nil || len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v (%v)", deleted, err)
+	}
+}
+
+// testStoreExpiredUnswept checks that expired overlays are treated as deleted by every read, even
+// though DeleteExpired has never been called to remove them
+func testStoreExpiredUnswept(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	expired := entities.NewOverlay("expired")
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	live := entities.NewOverlay("live")
+	live.ExpiresAt = time.Now().Add(time.Hour)
+	for _, o := range []*entities.Overlay{expired, live} {
+		if err := store.Create(ctx, o); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	if _, err := store.Get(ctx, expired.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from Get, got %v", err)
+	}
+	if _, err := store.GetByName(ctx, expired.Name); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from GetByName, got %v", err)
+	}
+	if _, err := store.Revisions(ctx, expired.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from Revisions, got %v", err)
+	}
+	if _, err := store.GetRevision(ctx, expired.ID, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from GetRevision, got %v", err)
+	}
+	if exists, err := store.Exists(ctx, expired.ID); exists || err != nil {
+		t.Errorf("expected expired overlay not to exist, got %v (%v)", exists, err)
+	}
+	if _, err := store.Get(ctx, live.ID); err != nil {
+		t.Errorf("expected unexpired overlay to be found, got %v", err)
+	}
+
+	summaries, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].ID != live.ID {
+		t.Errorf("expected only the unexpired overlay to be listed, got %v", summaries)
+	}
+}
+
+// testStoreRevisions checks that a store keeps the history of an overlay across updates, and
+// rejects updates based on an outdated revision
+func testStoreRevisions(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	overlay := entities.NewOverlay("versioned")
+	overlay.Author = "alice"
+	if err := store.Create(ctx, overlay); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if overlay.Revision != 1 {
+		t.Fatalf("expected revision 1 after create, got %d", overlay.Revision)
+	}
+
+	// two editors start from the same revision
+	first, _ := store.Get(ctx, overlay.ID)
+	second, _ := store.Get(ctx, overlay.ID)
+
+	first.Author = "bob"
+	first.Message = "grant access"
+	first.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/first"})
+	if err := store.Update(ctx, first); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if first.Revision != 2 || first.UpdatedAt.IsZero() {
+		t.Fatalf("expected revision 2 after update, got %d (%v)", first.Revision, first.UpdatedAt)
+	}
+
+	// the second editor's update is based on an outdated revision
+	second.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/second"})
+	if err := store.Update(ctx, second); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if second.Revision != 1 {
+		t.Fatalf("expected failed update to leave revision unchanged, got %d", second.Revision)
+	}
+
+	// unconditional updates always create a new revision
+	unconditional, _ := store.Get(ctx, overlay.ID)
+	unconditional.Revision = 0
+	unconditional.Name = "renamed"
+	if err := store.Update(ctx, unconditional); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	revisions, err := store.Revisions(ctx, overlay.ID)
+	if err != nil {
+		t.Fatalf("Revisions failed: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %v", revisions)
+	}
+	for i, r := range revisions {
+		if r.Revision != i+1 {
+			t.Errorf("expected revision %d at index %d, got %d", i+1, i, r.Revision)
+		}
+	}
+	if revisions[0].Author != "alice" || revisions[1].Message != "grant access" {
+		t.Errorf("unexpected revision metadata: %v", revisions)
+	}
+	if revisions[1].Name != "versioned" || revisions[2].Name != "renamed" {
+		t.Errorf("unexpected revision names: %v", revisions)
+	}
+
+	// every revision can be retrieved
+	for revision, principals := range map[int]int{1: 0, 2: 1, 3: 1} {
+		o, err := store.GetRevision(ctx, overlay.ID, revision)
+		if err != nil {
+			t.Fatalf("GetRevision(%d) failed: %v", revision, err)
+		}
+		if o.Revision != revision || o.NumPrincipals() != principals {
+			t.Errorf("GetRevision(%d) = revision %d with %d principals",
+				revision, o.Revision, o.NumPrincipals())
+		}
+	}
+	if _, err := store.GetRevision(ctx, overlay.ID, 4); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("expected ErrRevisionNotFound, got %v", err)
+	}
+
+	// history is removed along with the overlay
+	if err := store.Delete(ctx, overlay.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Revisions(ctx, overlay.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for revisions of deleted overlay, got %v", err)
+	}
+	if _, err := store.GetRevision(ctx, overlay.ID, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for revision of deleted overlay, got %v", err)
+	}
+}
+
+func TestNewStore_Persistent(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewStore("file://" + filepath.Join(dir, "overlays"))
+	if err != nil {
+		t.Fatalf("NewStore('file://...') failed: %v", err)
+	}
+	if _, ok := store.(*FileStore); !ok {
+		t.Errorf("expected FileStore for 'file://' spec, got %T", store)
+	}
+
+	store, err = NewStore("sqlite://" + filepath.Join(dir, "overlays.db"))
+	if err != nil {
+		t.Fatalf("NewStore('sqlite://...') failed: %v", err)
+	}
+	if _, ok := store.(*SQLiteStore); !ok {
+		t.Errorf("expected SQLiteStore for 'sqlite://' spec, got %T", store)
+	}
+	store.(*SQLiteStore).Close()
+
+	for _, spec := range []string{"file://", "sqlite://"} {
+		if _, err := NewStore(spec); err == nil {
+			t.Errorf("expected error for %q with empty path", spec)
+		}
+	}
+}
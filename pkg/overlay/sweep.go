@@ -0,0 +1,33 @@
+package overlay
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultSweepInterval is the default interval at which expired overlays are deleted
+const DefaultSweepInterval = time.Minute
+
+// Sweep deletes expired overlays from the store every `interval`, until the context is cancelled.
+// Errors are logged and retried at the next interval
+func Sweep(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := store.DeleteExpired(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			slog.Error("error deleting expired overlays", "error", err)
+		}
+		if len(deleted) > 0 {
+			slog.Info("deleted expired overlays", "count", len(deleted), "ids", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package overlay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+func TestSweep(t *testing.T) {
+	store := NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	expired := entities.NewOverlay("expired")
+	expired.ExpiresAt = time.Now()
+	_ = store.Create(ctx, expired)
+	_ = store.Create(ctx, entities.NewOverlay("permanent"))
+
+	done := make(chan struct{})
+	go func() {
+		Sweep(ctx, store, time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for store.Size() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if store.Size() != 1 {
+		t.Errorf("expected expired overlay to be swept, got %d overlays", store.Size())
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Sweep to return after cancellation")
+	}
+}
@@ -0,0 +1,241 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/overlay"
+	"github.com/nsiow/yams/pkg/server/api/rpc/yamspb"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// -------------------------------------------------------------------------------------------------
+// Entities
+// -------------------------------------------------------------------------------------------------
+
+// fromEntities decodes a collection of JSON-encoded entities into the v1 overlay representation
+func fromEntities(in *yamspb.Entities) (v1.Overlay, error) {
+	out := v1.Overlay{}
+	if in == nil {
+		return out, nil
+	}
+
+	var err error
+	if out.Accounts, err = decodeAll[entities.Account]("accounts", in.Accounts); err != nil {
+		return out, err
+	}
+	if out.Groups, err = decodeAll[entities.Group]("groups", in.Groups); err != nil {
+		return out, err
+	}
+	if out.Policies, err = decodeAll[entities.ManagedPolicy]("policies", in.Policies); err != nil {
+		return out, err
+	}
+	if out.Principals, err = decodeAll[entities.Principal]("principals", in.Principals); err != nil {
+		return out, err
+	}
+	if out.Resources, err = decodeAll[entities.Resource]("resources", in.Resources); err != nil {
+		return out, err
+	}
+	out.Tombstones = in.Tombstones
+	if out.Patches, err = decodeAll[entities.Patch]("patches", in.Patches); err != nil {
+		return out, err
+	}
+
+	return out, nil
+}
+
+// fromEntityLayers decodes a list of overlay layers, preserving their order
+func fromEntityLayers(in []*yamspb.Entities) ([]v1.Overlay, error) {
+	var out []v1.Overlay
+	for i, layer := range in {
+		overlay, err := fromEntities(layer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid overlay layer %d: %w", i, err)
+		}
+		out = append(out, overlay)
+	}
+
+	return out, nil
+}
+
+// toEntities encodes the entities of an overlay as JSON documents
+func toEntities(data entities.OverlayData) (*yamspb.Entities, error) {
+	out := &yamspb.Entities{}
+
+	var err error
+	if out.Accounts, err = encodeAll(data.Accounts); err != nil {
+		return nil, err
+	}
+	if out.Groups, err = encodeAll(data.Groups); err != nil {
+		return nil, err
+	}
+	if out.Policies, err = encodeAll(data.Policies); err != nil {
+		return nil, err
+	}
+	if out.Principals, err = encodeAll(data.Principals); err != nil {
+		return nil, err
+	}
+	if out.Resources, err = encodeAll(data.Resources); err != nil {
+		return nil, err
+	}
+	out.Tombstones = data.Tombstones
+	if out.Patches, err = encodeAll(data.Patches); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// decodeAll decodes each of the provided JSON documents into an entity of type T
+func decodeAll[T any](kind string, docs [][]byte) ([]T, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	out := make([]T, len(docs))
+	for i, doc := range docs {
+		err := json.Unmarshal(doc, &out[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON for %s[%d]: %v", kind, i, err)
+		}
+	}
+
+	return out, nil
+}
+
+// encodeAll encodes each of the provided entities as a JSON document
+func encodeAll[T any](items []T) ([][]byte, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	out := make([][]byte, len(items))
+	for i := range items {
+		b, err := json.Marshal(&items[i])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = b
+	}
+
+	return out, nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Simulation
+// -------------------------------------------------------------------------------------------------
+
+func fromSimulateRequest(req *yamspb.SimulateRequest) (v1.SimInput, error) {
+	overlay, err := fromEntities(req.Overlay)
+	if err != nil {
+		return v1.SimInput{}, err
+	}
+	layers, err := fromEntityLayers(req.Overlays)
+	if err != nil {
+		return v1.SimInput{}, err
+	}
+
+	return v1.SimInput{
+		Principal: req.Principal,
+		Action:    req.Action,
+		Resource:  req.Resource,
+		Context:   req.Context,
+		Fuzzy:     req.Fuzzy,
+		Explain:   req.Explain,
+		Trace:     req.Trace,
+		Overlay:   overlay,
+		Overlays:  layers,
+		OverlayRefs: v1.OverlayRefs{
+			OverlayIDs: req.OverlayIds,
+		},
+	}, nil
+}
+
+func toSimulateResponse(out v1.SimOutput) *yamspb.SimulateResponse {
+	return &yamspb.SimulateResponse{
+		Result:    out.Result,
+		Principal: out.Principal,
+		Action:    out.Action,
+		Resource:  out.Resource,
+		Explain:   out.Explain,
+		Trace:     out.Trace,
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// Overlays
+// -------------------------------------------------------------------------------------------------
+
+func toOverlay(o *entities.Overlay) (*yamspb.Overlay, error) {
+	data := o.ToData()
+	ents, err := toEntities(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &yamspb.Overlay{
+		Id:        data.ID,
+		Name:      data.Name,
+		CreatedAt: timestamppb.New(data.CreatedAt),
+		Entities:  ents,
+		Revision:  int64(data.Revision),
+		UpdatedAt: timestamppb.New(data.UpdatedAt),
+		Author:    data.Author,
+		Message:   data.Message,
+		ExpiresAt: toExpiry(data.ExpiresAt),
+	}, nil
+}
+
+func toOverlayIssues(issues []overlay.Issue) []*yamspb.OverlayIssue {
+	var out []*yamspb.OverlayIssue
+	for _, i := range issues {
+		out = append(out, &yamspb.OverlayIssue{
+			Severity: i.Severity,
+			Code:     i.Code,
+			Kind:     i.Kind,
+			Key:      i.Key,
+			Field:    i.Field,
+			Message:  i.Message,
+		})
+	}
+	return out
+}
+
+func toOverlaySummary(s entities.OverlaySummary) *yamspb.OverlaySummary {
+	return &yamspb.OverlaySummary{
+		Id:            s.ID,
+		Name:          s.Name,
+		CreatedAt:     timestamppb.New(s.CreatedAt),
+		NumPrincipals: int64(s.NumPrincipals),
+		NumResources:  int64(s.NumResources),
+		NumPolicies:   int64(s.NumPolicies),
+		NumAccounts:   int64(s.NumAccounts),
+		NumGroups:     int64(s.NumGroups),
+		NumTombstones: int64(s.NumTombstones),
+		NumPatches:    int64(s.NumPatches),
+		Revision:      int64(s.Revision),
+		UpdatedAt:     timestamppb.New(s.UpdatedAt),
+		ExpiresAt:     toExpiry(s.ExpiresAt),
+	}
+}
+
+// toExpiry converts the expiry of an overlay, which is left unset if the overlay never expires
+func toExpiry(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// fromExpiry converts the expiry requested when creating or updating an overlay
+func fromExpiry(expiresAt *timestamppb.Timestamp, ttl string) v1.ExpiryInput {
+	input := v1.ExpiryInput{TTL: ttl}
+	if expiresAt != nil {
+		t := expiresAt.AsTime()
+		input.ExpiresAt = &t
+	}
+	return input
+}
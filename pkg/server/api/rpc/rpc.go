@@ -0,0 +1,417 @@
+// Package rpc implements the yams gRPC API, which exposes the simulation and overlay operations of
+// the v1 HTTP API to callers requiring higher throughput
+package rpc
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/overlay"
+	"github.com/nsiow/yams/pkg/server/api/rpc/yamspb"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Service implements the yams gRPC service on top of the same API instances which back the v1 HTTP
+// handlers, so that both share a simulator, overlay store and result cache
+type Service struct {
+	yamspb.UnimplementedYamsServer
+
+	API      *v1.API
+	Overlays *v1.OverlayAPI
+}
+
+// NewServer creates a gRPC server with the yams service (and server reflection) registered
+func NewServer(api *v1.API, overlays *v1.OverlayAPI, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	yamspb.RegisterYamsServer(server, &Service{API: api, Overlays: overlays})
+	reflection.Register(server)
+	return server
+}
+
+// -------------------------------------------------------------------------------------------------
+// Simulation
+// -------------------------------------------------------------------------------------------------
+
+func (s *Service) Simulate(
+	ctx context.Context, req *yamspb.SimulateRequest) (*yamspb.SimulateResponse, error) {
+
+	out, hit, err := s.simulate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("simulation result",
+		"principal", out.Principal,
+		"action", out.Action,
+		"resource", out.Resource,
+		"context", req.Context,
+		"result", out.Result,
+		"cached", hit)
+	return toSimulateResponse(out), nil
+}
+
+func (s *Service) SimulateBatch(stream yamspb.Yams_SimulateBatchServer) error {
+	var count, hits uint64
+	defer func() {
+		slog.Info("batch simulation results",
+			"count", count,
+			"cached", hits)
+	}()
+
+	// individual failures are reported inline rather than terminating the stream
+	for ; ; count++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resp := &yamspb.SimulateBatchResponse{Index: count}
+		out, hit, err := s.simulate(stream.Context(), req)
+		if err != nil {
+			resp.Outcome = &yamspb.SimulateBatchResponse_Error{Error: status.Convert(err).Message()}
+		} else {
+			resp.Outcome = &yamspb.SimulateBatchResponse_Output{Output: toSimulateResponse(out)}
+		}
+		if hit {
+			hits++
+		}
+
+		err = stream.Send(resp)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// simulate validates and runs a single simulation request
+func (s *Service) simulate(
+	ctx context.Context, req *yamspb.SimulateRequest) (v1.SimOutput, bool, error) {
+
+	input, err := fromSimulateRequest(req)
+	if err != nil {
+		return v1.SimOutput{}, false, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = input.Validate()
+	if err != nil {
+		return v1.SimOutput{}, false, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	out, hit, err := s.API.Simulate(ctx, input)
+	if err != nil {
+		return v1.SimOutput{}, false, simulationError(err)
+	}
+
+	return out, hit, nil
+}
+
+func (s *Service) WhichPrincipals(
+	ctx context.Context, req *yamspb.WhichPrincipalsRequest) (*yamspb.WhichResponse, error) {
+
+	inline, err := fromEntities(req.Overlay)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	layers, err := fromEntityLayers(req.Overlays)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	input := v1.WhichPrincipalsInput{
+		Action:   req.Action,
+		Resource: req.Resource,
+		Context:  req.Context,
+		Overlay:  inline,
+		Overlays: layers,
+		Fuzzy:    req.Fuzzy,
+
+		OverlayRefs: v1.OverlayRefs{OverlayIDs: req.OverlayIds},
+	}
+	err = input.Validate()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, _, err := s.API.WhichPrincipalsFor(ctx, input)
+	return whichResponse(results, err)
+}
+
+func (s *Service) WhichActions(
+	ctx context.Context, req *yamspb.WhichActionsRequest) (*yamspb.WhichResponse, error) {
+
+	inline, err := fromEntities(req.Overlay)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	layers, err := fromEntityLayers(req.Overlays)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	input := v1.WhichActionsInput{
+		Principal: req.Principal,
+		Resource:  req.Resource,
+		Context:   req.Context,
+		Overlay:   inline,
+		Overlays:  layers,
+		Fuzzy:     req.Fuzzy,
+
+		OverlayRefs: v1.OverlayRefs{OverlayIDs: req.OverlayIds},
+	}
+	err = input.Validate()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, _, err := s.API.WhichActionsFor(ctx, input)
+	return whichResponse(results, err)
+}
+
+func (s *Service) WhichResources(
+	ctx context.Context, req *yamspb.WhichResourcesRequest) (*yamspb.WhichResponse, error) {
+
+	inline, err := fromEntities(req.Overlay)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	layers, err := fromEntityLayers(req.Overlays)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	input := v1.WhichResourcesInput{
+		Principal: req.Principal,
+		Action:    req.Action,
+		Context:   req.Context,
+		Overlay:   inline,
+		Overlays:  layers,
+		Fuzzy:     req.Fuzzy,
+
+		OverlayRefs: v1.OverlayRefs{OverlayIDs: req.OverlayIds},
+	}
+	err = input.Validate()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, _, err := s.API.WhichResourcesFor(ctx, input)
+	return whichResponse(results, err)
+}
+
+// whichResponse converts the result of a which* simulation into its gRPC response
+func whichResponse(results []string, err error) (*yamspb.WhichResponse, error) {
+	if err != nil {
+		return nil, simulationError(err)
+	}
+
+	return &yamspb.WhichResponse{Results: results}, nil
+}
+
+// simulationError maps errors from a simulation onto the corresponding gRPC status
+func simulationError(err error) error {
+	if errors.Is(err, overlay.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	return status.Errorf(codes.Internal, "simulation error: %v", err)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Overlays
+// -------------------------------------------------------------------------------------------------
+
+func (s *Service) ListOverlays(
+	ctx context.Context, req *yamspb.ListOverlaysRequest) (*yamspb.ListOverlaysResponse, error) {
+
+	summaries, err := s.Overlays.Store.List(ctx, req.Query)
+	if err != nil {
+		return nil, storeError("failed to list overlays", err)
+	}
+
+	out := &yamspb.ListOverlaysResponse{}
+	for _, summary := range summaries {
+		out.Overlays = append(out.Overlays, toOverlaySummary(summary))
+	}
+
+	return out, nil
+}
+
+func (s *Service) GetOverlay(
+	ctx context.Context, req *yamspb.GetOverlayRequest) (*yamspb.Overlay, error) {
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing overlay ID")
+	}
+
+	o, err := s.Overlays.Store.Get(ctx, req.Id)
+	if err != nil {
+		return nil, storeError("failed to get overlay", err)
+	}
+
+	return overlayResponse(toOverlay(o))
+}
+
+func (s *Service) CreateOverlay(
+	ctx context.Context, req *yamspb.CreateOverlayRequest) (*yamspb.Overlay, error) {
+
+	ents, err := fromEntities(req.Entities)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	input := v1.CreateOverlayInput{
+		Name:        req.Name,
+		Author:      req.Author,
+		Message:     req.Message,
+		ExpiryInput: fromExpiry(req.ExpiresAt, req.Ttl),
+		Accounts:    ents.Accounts,
+		Groups:      ents.Groups,
+		Policies:    ents.Policies,
+		Principals:  ents.Principals,
+		Resources:   ents.Resources,
+		Tombstones:  ents.Tombstones,
+		Patches:     ents.Patches,
+	}
+	err = input.Validate()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	o := input.Overlay()
+	warnings, err := overlay.Check(o, s.Overlays.Base)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.DryRun {
+		exists, err := s.Overlays.Store.Exists(ctx, o.ID)
+		if err != nil || exists {
+			return nil, storeError("failed to create overlay", cmp.Or(err, overlay.ErrAlreadyExists))
+		}
+	} else {
+		err = s.Overlays.Store.Create(ctx, o)
+		if err != nil {
+			return nil, storeError("failed to create overlay", err)
+		}
+	}
+
+	return lintedResponse(o, warnings)
+}
+
+func (s *Service) UpdateOverlay(
+	ctx context.Context, req *yamspb.UpdateOverlayRequest) (*yamspb.Overlay, error) {
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing overlay ID")
+	}
+
+	ents, err := fromEntities(req.Entities)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	existing, err := s.Overlays.Store.Get(ctx, req.Id)
+	if err != nil {
+		return nil, storeError("failed to get overlay", err)
+	}
+
+	input := v1.UpdateOverlayInput{
+		Name:        req.Name,
+		Revision:    int(req.Revision),
+		Author:      req.Author,
+		Message:     req.Message,
+		ExpiryInput: fromExpiry(req.ExpiresAt, req.Ttl),
+		Accounts:    ents.Accounts,
+		Groups:      ents.Groups,
+		Policies:    ents.Policies,
+		Principals:  ents.Principals,
+		Resources:   ents.Resources,
+		Tombstones:  ents.Tombstones,
+		Patches:     ents.Patches,
+	}
+	err = input.Validate()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if input.Revision != 0 && input.Revision != existing.Revision {
+		return nil, storeError("failed to update overlay", overlay.ErrConflict)
+	}
+	input.Apply(existing)
+
+	warnings, err := overlay.Check(existing, s.Overlays.Base)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !req.DryRun {
+		err = s.Overlays.Store.Update(ctx, existing)
+		if err != nil {
+			return nil, storeError("failed to update overlay", err)
+		}
+	}
+
+	return lintedResponse(existing, warnings)
+}
+
+func (s *Service) DeleteOverlay(
+	ctx context.Context, req *yamspb.DeleteOverlayRequest) (*yamspb.DeleteOverlayResponse, error) {
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing overlay ID")
+	}
+
+	err := s.Overlays.Store.Delete(ctx, req.Id)
+	if err != nil {
+		return nil, storeError("failed to delete overlay", err)
+	}
+
+	return &yamspb.DeleteOverlayResponse{}, nil
+}
+
+// overlayResponse converts the result of encoding an overlay into its gRPC response
+func overlayResponse(o *yamspb.Overlay, err error) (*yamspb.Overlay, error) {
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode overlay: %v", err)
+	}
+
+	return o, nil
+}
+
+// lintedResponse converts a created or updated overlay into its gRPC response, including the
+// warnings found while linting it
+func lintedResponse(o *entities.Overlay, warnings []overlay.Issue) (*yamspb.Overlay, error) {
+	out, err := overlayResponse(toOverlay(o))
+	if err != nil {
+		return nil, err
+	}
+
+	out.Warnings = toOverlayIssues(warnings)
+	return out, nil
+}
+
+// storeError maps errors from the overlay store onto the corresponding gRPC status
+func storeError(msg string, err error) error {
+	switch {
+	case errors.Is(err, overlay.ErrNotFound), errors.Is(err, overlay.ErrRevisionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, overlay.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, overlay.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, "overlay with this name already exists")
+	default:
+		return status.Error(codes.Internal, fmt.Sprintf("%s: %v", msg, err))
+	}
+}
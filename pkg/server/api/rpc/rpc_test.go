@@ -0,0 +1,535 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/overlay"
+	"github.com/nsiow/yams/pkg/policy"
+	"github.com/nsiow/yams/pkg/server/api/rpc/yamspb"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
+	"github.com/nsiow/yams/pkg/sim"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testPrincipal = "arn:aws:iam::123456789012:user/alice"
+	testResource  = "arn:aws:s3:::bucket"
+)
+
+// newTestClient serves the gRPC API over an in-memory listener and returns a client connected to it
+func newTestClient(t *testing.T) (yamspb.YamsClient, *v1.API) {
+	t.Helper()
+
+	simulator, err := sim.NewSimulator()
+	if err != nil {
+		t.Fatalf("failed to create simulator: %v", err)
+	}
+
+	uv := simulator.Universe
+	uv.PutAccount(entities.Account{Id: "123456789012", Name: "TestAccount"})
+	uv.PutPrincipal(entities.Principal{
+		Type:      "AWS::IAM::User",
+		Name:      "alice",
+		Arn:       testPrincipal,
+		AccountId: "123456789012",
+		InlinePolicies: []policy.Policy{
+			{
+				Statement: []policy.Statement{
+					{
+						Effect:   policy.EFFECT_ALLOW,
+						Action:   []string{"s3:ListBucket"},
+						Resource: []string{testResource},
+					},
+				},
+			},
+		},
+	})
+	uv.PutResource(entities.Resource{
+		Type:      "AWS::S3::Bucket",
+		Arn:       testResource,
+		AccountId: "123456789012",
+	})
+
+	store := overlay.NewCache(overlay.NewMemoryStore(), time.Minute)
+	api := &v1.API{
+		Simulator: simulator,
+		Cache:     v1.NewResultCache(100, uv.Generation),
+		Overlays:  store,
+	}
+	server := NewServer(api, &v1.OverlayAPI{Store: store, Base: uv})
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return yamspb.NewYamsClient(conn), api
+}
+
+func TestService_Simulate(t *testing.T) {
+	client, api := newTestClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		req     *yamspb.SimulateRequest
+		want    string
+		wantErr codes.Code
+	}{
+		{
+			name: "allow",
+			req:  &yamspb.SimulateRequest{Principal: testPrincipal, Action: "s3:ListBucket", Resource: testResource},
+			want: "ALLOW",
+		},
+		{
+			name: "deny",
+			req:  &yamspb.SimulateRequest{Principal: testPrincipal, Action: "s3:DeleteBucket", Resource: testResource},
+			want: "DENY",
+		},
+		{
+			name: "allow_via_overlay",
+			req: &yamspb.SimulateRequest{
+				Principal: "arn:aws:iam::123456789012:user/bob",
+				Action:    "s3:ListBucket",
+				Resource:  testResource,
+				Overlay: &yamspb.Entities{
+					Principals: [][]byte{[]byte(`{
+						"Type": "AWS::IAM::User",
+						"Arn": "arn:aws:iam::123456789012:user/bob",
+						"AccountId": "123456789012",
+						"InlinePolicies": [{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}]
+					}`)},
+				},
+			},
+			want: "ALLOW",
+		},
+		{
+			name:    "missing_action",
+			req:     &yamspb.SimulateRequest{Principal: testPrincipal},
+			wantErr: codes.InvalidArgument,
+		},
+		{
+			name: "invalid_overlay",
+			req: &yamspb.SimulateRequest{
+				Principal: testPrincipal,
+				Action:    "s3:ListBucket",
+				Overlay:   &yamspb.Entities{Principals: [][]byte{[]byte(`{`)}},
+			},
+			wantErr: codes.InvalidArgument,
+		},
+		{
+			name: "unknown_principal",
+			req: &yamspb.SimulateRequest{
+				Principal: "arn:aws:iam::123456789012:user/nobody",
+				Action:    "s3:ListBucket",
+			},
+			wantErr: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.Simulate(ctx, tt.req)
+			if status.Code(err) != tt.wantErr {
+				t.Fatalf("Simulate() error = %v, want code %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Result != tt.want {
+				t.Errorf("Simulate() result = %s, want %s", got.Result, tt.want)
+			}
+		})
+	}
+
+	// results are shared with the HTTP API via the same result cache
+	_, err := client.Simulate(ctx, tests[0].req)
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	if stats := api.Cache.Stats(); stats.Hits == 0 {
+		t.Errorf("expected cache hits, got %+v", stats)
+	}
+}
+
+func TestService_SimulateBatch(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	stream, err := client.SimulateBatch(context.Background())
+	if err != nil {
+		t.Fatalf("SimulateBatch() error = %v", err)
+	}
+
+	reqs := []*yamspb.SimulateRequest{
+		{Principal: testPrincipal, Action: "s3:ListBucket", Resource: testResource},
+		{Principal: testPrincipal},
+		{Principal: testPrincipal, Action: "s3:DeleteBucket", Resource: testResource},
+	}
+
+	// interleave sends and receives to exercise the bidirectional stream
+	var got []*yamspb.SimulateBatchResponse
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		got = append(got, resp)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend() error = %v", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expected EOF after CloseSend, got %v", err)
+	}
+
+	for i, resp := range got {
+		if resp.Index != uint64(i) {
+			t.Errorf("response %d has index %d", i, resp.Index)
+		}
+	}
+	if got[0].GetOutput().GetResult() != "ALLOW" {
+		t.Errorf("expected first result to be ALLOW, got %v", got[0])
+	}
+	if got[1].GetError() == "" {
+		t.Errorf("expected second result to be an error, got %v", got[1])
+	}
+	if got[2].GetOutput().GetResult() != "DENY" {
+		t.Errorf("expected third result to be DENY, got %v", got[2])
+	}
+}
+
+func TestService_Which(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	principals, err := client.WhichPrincipals(ctx, &yamspb.WhichPrincipalsRequest{
+		Action:   "s3:ListBucket",
+		Resource: testResource,
+	})
+	if err != nil || !slices.Equal(principals.Results, []string{testPrincipal}) {
+		t.Errorf("WhichPrincipals() = %v, %v", principals, err)
+	}
+
+	actions, err := client.WhichActions(ctx, &yamspb.WhichActionsRequest{
+		Principal: testPrincipal,
+		Resource:  testResource,
+	})
+	if err != nil || !slices.Equal(actions.Results, []string{"s3:ListBucket"}) {
+		t.Errorf("WhichActions() = %v, %v", actions, err)
+	}
+
+	resources, err := client.WhichResources(ctx, &yamspb.WhichResourcesRequest{
+		Principal: testPrincipal,
+		Action:    "s3:ListBucket",
+	})
+	if err != nil || !slices.Equal(resources.Results, []string{testResource}) {
+		t.Errorf("WhichResources() = %v, %v", resources, err)
+	}
+
+	_, err = client.WhichPrincipals(ctx, &yamspb.WhichPrincipalsRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for missing action, got %v", err)
+	}
+
+	_, err = client.WhichResources(ctx, &yamspb.WhichResourcesRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for missing principal, got %v", err)
+	}
+}
+
+func TestService_Which_Cancelled(t *testing.T) {
+	_, api := newTestClient(t)
+	service := &Service{API: api}
+
+	// simulations stop with the request context, rather than running to completion
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := service.WhichPrincipals(ctx, &yamspb.WhichPrincipalsRequest{
+		Action:   "s3:ListBucket",
+		Resource: testResource,
+	})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("WhichPrincipals() error = %v, want Canceled", err)
+	}
+
+	_, err = service.WhichActions(ctx, &yamspb.WhichActionsRequest{
+		Principal: testPrincipal,
+		Resource:  testResource,
+	})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("WhichActions() error = %v, want Canceled", err)
+	}
+
+	_, err = service.WhichResources(ctx, &yamspb.WhichResourcesRequest{
+		Principal: testPrincipal,
+		Action:    "s3:ListBucket",
+	})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("WhichResources() error = %v, want Canceled", err)
+	}
+}
+
+func TestService_Overlays(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	created, err := client.CreateOverlay(ctx, &yamspb.CreateOverlayRequest{
+		Name: "test",
+		Entities: &yamspb.Entities{
+			Accounts: [][]byte{[]byte(`{"Id": "111111111111", "Name": "Other"}`)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateOverlay() error = %v", err)
+	}
+	if created.Id != entities.GenerateOverlayID("test") || len(created.Entities.Accounts) != 1 {
+		t.Errorf("CreateOverlay() = %v", created)
+	}
+	if created.ExpiresAt != nil {
+		t.Errorf("CreateOverlay() expiresAt = %v, want unset", created.ExpiresAt)
+	}
+
+	_, err = client.CreateOverlay(ctx, &yamspb.CreateOverlayRequest{Name: "test"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected AlreadyExists for duplicate overlay, got %v", err)
+	}
+
+	_, err = client.CreateOverlay(ctx, &yamspb.CreateOverlayRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for missing name, got %v", err)
+	}
+
+	list, err := client.ListOverlays(ctx, &yamspb.ListOverlaysRequest{Query: "tes"})
+	if err != nil || len(list.Overlays) != 1 || list.Overlays[0].NumAccounts != 1 {
+		t.Errorf("ListOverlays() = %v, %v", list, err)
+	}
+
+	updated, err := client.UpdateOverlay(ctx, &yamspb.UpdateOverlayRequest{
+		Id:   created.Id,
+		Name: "renamed",
+		Entities: &yamspb.Entities{
+			Principals: [][]byte{[]byte(`{"Arn": "arn:aws:iam::111111111111:role/r"}`)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("UpdateOverlay() error = %v", err)
+	}
+	if updated.Name != "renamed" ||
+		len(updated.Entities.Principals) != 1 ||
+		len(updated.Entities.Accounts) != 0 {
+		t.Errorf("UpdateOverlay() = %v", updated)
+	}
+	if updated.Revision != 2 {
+		t.Errorf("UpdateOverlay() revision = %d, want 2", updated.Revision)
+	}
+
+	expiring, err := client.UpdateOverlay(ctx, &yamspb.UpdateOverlayRequest{Id: created.Id, Ttl: "1h"})
+	if err != nil || expiring.ExpiresAt == nil {
+		t.Errorf("UpdateOverlay() with ttl = %v, %v", expiring, err)
+	}
+	list, err = client.ListOverlays(ctx, &yamspb.ListOverlaysRequest{})
+	if err != nil || len(list.Overlays) != 1 || list.Overlays[0].ExpiresAt == nil {
+		t.Errorf("expected expiry in ListOverlays(), got %v, %v", list, err)
+	}
+
+	_, err = client.UpdateOverlay(ctx, &yamspb.UpdateOverlayRequest{Id: created.Id, Ttl: "soon"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for invalid ttl, got %v", err)
+	}
+
+	_, err = client.UpdateOverlay(ctx, &yamspb.UpdateOverlayRequest{Id: created.Id, Revision: 2})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected Aborted for update of outdated revision, got %v", err)
+	}
+
+	got, err := client.GetOverlay(ctx, &yamspb.GetOverlayRequest{Id: created.Id})
+	if err != nil || got.Name != "renamed" || !got.CreatedAt.AsTime().Equal(created.CreatedAt.AsTime()) {
+		t.Errorf("GetOverlay() = %v, %v", got, err)
+	}
+
+	_, err = client.DeleteOverlay(ctx, &yamspb.DeleteOverlayRequest{Id: created.Id})
+	if err != nil {
+		t.Fatalf("DeleteOverlay() error = %v", err)
+	}
+
+	_, err = client.GetOverlay(ctx, &yamspb.GetOverlayRequest{Id: created.Id})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound after delete, got %v", err)
+	}
+
+	_, err = client.UpdateOverlay(ctx, &yamspb.UpdateOverlayRequest{Id: created.Id})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for update of deleted overlay, got %v", err)
+	}
+
+	_, err = client.DeleteOverlay(ctx, &yamspb.DeleteOverlayRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for missing ID, got %v", err)
+	}
+}
+
+func TestService_OverlayLint(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.CreateOverlay(ctx, &yamspb.CreateOverlayRequest{
+		Name: "broken",
+		Entities: &yamspb.Entities{
+			Principals: [][]byte{[]byte(`{"Arn": "role/typo"}`)},
+		},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for malformed ARN, got %v", err)
+	}
+
+	principal := []byte(`{
+		"Type": "AWS::IAM::Role",
+		"AccountId": "123456789012",
+		"Arn": "arn:aws:iam::123456789012:role/r",
+		"Groups": ["arn:aws:iam::123456789012:group/missing"]
+	}`)
+	dryRun, err := client.CreateOverlay(ctx, &yamspb.CreateOverlayRequest{
+		Name:     "linted",
+		Entities: &yamspb.Entities{Principals: [][]byte{principal}},
+		DryRun:   true,
+	})
+	if err != nil || len(dryRun.Warnings) != 1 || dryRun.Warnings[0].Code != overlay.IssueUnresolvedGroup {
+		t.Fatalf("CreateOverlay() dry run = %v, %v", dryRun, err)
+	}
+	_, err = client.GetOverlay(ctx, &yamspb.GetOverlayRequest{Id: dryRun.Id})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for dry-run overlay, got %v", err)
+	}
+
+	created, err := client.CreateOverlay(ctx, &yamspb.CreateOverlayRequest{Name: "linted"})
+	if err != nil || len(created.Warnings) != 0 {
+		t.Fatalf("CreateOverlay() = %v, %v", created, err)
+	}
+
+	_, err = client.CreateOverlay(ctx, &yamspb.CreateOverlayRequest{Name: "linted", DryRun: true})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected AlreadyExists for duplicate dry run, got %v", err)
+	}
+
+	updated, err := client.UpdateOverlay(ctx, &yamspb.UpdateOverlayRequest{
+		Id:       created.Id,
+		Entities: &yamspb.Entities{Principals: [][]byte{principal}},
+		DryRun:   true,
+	})
+	if err != nil || len(updated.Warnings) != 1 || len(updated.Entities.Principals) != 1 {
+		t.Fatalf("UpdateOverlay() dry run = %v, %v", updated, err)
+	}
+	got, err := client.GetOverlay(ctx, &yamspb.GetOverlayRequest{Id: created.Id})
+	if err != nil || got.Revision != 1 || len(got.Entities.Principals) != 0 {
+		t.Errorf("expected dry-run update not to be stored, got %v, %v", got, err)
+	}
+}
+
+func TestService_OverlayRefs(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	created, err := client.CreateOverlay(ctx, &yamspb.CreateOverlayRequest{
+		Name: "grant",
+		Entities: &yamspb.Entities{
+			Principals: [][]byte{[]byte(`{
+				"Type": "AWS::IAM::User",
+				"Arn": "arn:aws:iam::123456789012:user/alice",
+				"AccountId": "123456789012",
+				"InlinePolicies": [{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}]
+			}`)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateOverlay() error = %v", err)
+	}
+
+	req := &yamspb.SimulateRequest{
+		Principal:  testPrincipal,
+		Action:     "s3:DeleteBucket",
+		Resource:   testResource,
+		OverlayIds: []string{created.Id},
+	}
+	out, err := client.Simulate(ctx, &yamspb.SimulateRequest{
+		Principal: testPrincipal,
+		Action:    "s3:DeleteBucket",
+		Resource:  testResource,
+	})
+	if err != nil || out.Result != "DENY" {
+		t.Fatalf("Simulate() without overlay = %v, %v", out, err)
+	}
+
+	out, err = client.Simulate(ctx, req)
+	if err != nil || out.Result != "ALLOW" {
+		t.Fatalf("Simulate() = %v, %v", out, err)
+	}
+
+	// inline layers take precedence over stored overlays
+	revoke := &yamspb.Entities{
+		Principals: [][]byte{[]byte(`{
+			"Type": "AWS::IAM::User",
+			"Arn": "arn:aws:iam::123456789012:user/alice",
+			"AccountId": "123456789012"
+		}`)},
+	}
+	out, err = client.Simulate(ctx, &yamspb.SimulateRequest{
+		Principal:  testPrincipal,
+		Action:     "s3:DeleteBucket",
+		Resource:   testResource,
+		OverlayIds: []string{created.Id},
+		Overlays:   []*yamspb.Entities{revoke},
+	})
+	if err != nil || out.Result != "DENY" {
+		t.Fatalf("Simulate() with overlay layers = %v, %v", out, err)
+	}
+
+	principals, err := client.WhichPrincipals(ctx, &yamspb.WhichPrincipalsRequest{
+		Action:     "s3:DeleteBucket",
+		Resource:   testResource,
+		OverlayIds: []string{created.Id},
+	})
+	if err != nil || !slices.Equal(principals.Results, []string{testPrincipal}) {
+		t.Errorf("WhichPrincipals() = %v, %v", principals, err)
+	}
+
+	// deleting the overlay makes references to it fail
+	_, err = client.DeleteOverlay(ctx, &yamspb.DeleteOverlayRequest{Id: created.Id})
+	if err != nil {
+		t.Fatalf("DeleteOverlay() error = %v", err)
+	}
+	_, err = client.Simulate(ctx, req)
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for deleted overlay, got %v", err)
+	}
+
+	_, err = client.WhichActions(ctx, &yamspb.WhichActionsRequest{
+		Principal:  testPrincipal,
+		OverlayIds: []string{""},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for empty overlay ID, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+// Package yamspb contains the protocol buffer messages and service definitions for the yams gRPC
+// API, generated from yams.proto
+package yamspb
+
+// The compiler and plugins are pinned by buf.gen.yaml and run with `go run`, so regenerating needs
+// nothing beyond the Go toolchain. The generated files must never be edited by hand
+//go:generate go run github.com/bufbuild/buf/cmd/buf@v1.50.0 generate
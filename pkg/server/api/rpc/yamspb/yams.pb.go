@@ -0,0 +1,1785 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: yams.proto
+
+package yamspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Entities is a collection of entities, each encoded as a JSON document using the same schema as
+// the HTTP API (e.g. the elements of "principals" in a v1 overlay)
+type Entities struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Accounts   [][]byte               `protobuf:"bytes,1,rep,name=accounts,proto3" json:"accounts,omitempty"`
+	Groups     [][]byte               `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	Policies   [][]byte               `protobuf:"bytes,3,rep,name=policies,proto3" json:"policies,omitempty"`
+	Principals [][]byte               `protobuf:"bytes,4,rep,name=principals,proto3" json:"principals,omitempty"`
+	Resources  [][]byte               `protobuf:"bytes,5,rep,name=resources,proto3" json:"resources,omitempty"`
+	// tombstones are the ARNs (or account IDs) of entities which should be treated as deleted
+	Tombstones []string `protobuf:"bytes,6,rep,name=tombstones,proto3" json:"tombstones,omitempty"`
+	// patches are partial modifications of existing entities, each encoded as a JSON document using
+	// the same schema as the "patches" of a v1 overlay
+	Patches       [][]byte `protobuf:"bytes,7,rep,name=patches,proto3" json:"patches,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Entities) Reset() {
+	*x = Entities{}
+	mi := &file_yams_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Entities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entities) ProtoMessage() {}
+
+func (x *Entities) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entities.ProtoReflect.Descriptor instead.
+func (*Entities) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Entities) GetAccounts() [][]byte {
+	if x != nil {
+		return x.Accounts
+	}
+	return nil
+}
+
+func (x *Entities) GetGroups() [][]byte {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *Entities) GetPolicies() [][]byte {
+	if x != nil {
+		return x.Policies
+	}
+	return nil
+}
+
+func (x *Entities) GetPrincipals() [][]byte {
+	if x != nil {
+		return x.Principals
+	}
+	return nil
+}
+
+func (x *Entities) GetResources() [][]byte {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+func (x *Entities) GetTombstones() []string {
+	if x != nil {
+		return x.Tombstones
+	}
+	return nil
+}
+
+func (x *Entities) GetPatches() [][]byte {
+	if x != nil {
+		return x.Patches
+	}
+	return nil
+}
+
+type SimulateRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Principal string                 `protobuf:"bytes,1,opt,name=principal,proto3" json:"principal,omitempty"`
+	Action    string                 `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	Resource  string                 `protobuf:"bytes,3,opt,name=resource,proto3" json:"resource,omitempty"`
+	Context   map[string]string      `protobuf:"bytes,4,rep,name=context,proto3" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Fuzzy     bool                   `protobuf:"varint,5,opt,name=fuzzy,proto3" json:"fuzzy,omitempty"`
+	Explain   bool                   `protobuf:"varint,6,opt,name=explain,proto3" json:"explain,omitempty"`
+	Trace     bool                   `protobuf:"varint,7,opt,name=trace,proto3" json:"trace,omitempty"`
+	Overlay   *Entities              `protobuf:"bytes,8,opt,name=overlay,proto3" json:"overlay,omitempty"`
+	// overlay_ids references overlays saved in the overlay store, which are stacked (in order) beneath
+	// the inline overlays
+	OverlayIds []string `protobuf:"bytes,9,rep,name=overlay_ids,json=overlayIds,proto3" json:"overlay_ids,omitempty"`
+	// overlays are inline overlay layers, stacked (in order) above the stored overlays and beneath
+	// the single inline overlay; later layers take precedence over earlier ones
+	Overlays      []*Entities `protobuf:"bytes,10,rep,name=overlays,proto3" json:"overlays,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SimulateRequest) Reset() {
+	*x = SimulateRequest{}
+	mi := &file_yams_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SimulateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SimulateRequest) ProtoMessage() {}
+
+func (x *SimulateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SimulateRequest.ProtoReflect.Descriptor instead.
+func (*SimulateRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SimulateRequest) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *SimulateRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *SimulateRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *SimulateRequest) GetContext() map[string]string {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *SimulateRequest) GetFuzzy() bool {
+	if x != nil {
+		return x.Fuzzy
+	}
+	return false
+}
+
+func (x *SimulateRequest) GetExplain() bool {
+	if x != nil {
+		return x.Explain
+	}
+	return false
+}
+
+func (x *SimulateRequest) GetTrace() bool {
+	if x != nil {
+		return x.Trace
+	}
+	return false
+}
+
+func (x *SimulateRequest) GetOverlay() *Entities {
+	if x != nil {
+		return x.Overlay
+	}
+	return nil
+}
+
+func (x *SimulateRequest) GetOverlayIds() []string {
+	if x != nil {
+		return x.OverlayIds
+	}
+	return nil
+}
+
+func (x *SimulateRequest) GetOverlays() []*Entities {
+	if x != nil {
+		return x.Overlays
+	}
+	return nil
+}
+
+type SimulateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// result is either ALLOW or DENY
+	Result        string   `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	Principal     string   `protobuf:"bytes,2,opt,name=principal,proto3" json:"principal,omitempty"`
+	Action        string   `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	Resource      string   `protobuf:"bytes,4,opt,name=resource,proto3" json:"resource,omitempty"`
+	Explain       []string `protobuf:"bytes,5,rep,name=explain,proto3" json:"explain,omitempty"`
+	Trace         []string `protobuf:"bytes,6,rep,name=trace,proto3" json:"trace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SimulateResponse) Reset() {
+	*x = SimulateResponse{}
+	mi := &file_yams_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SimulateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SimulateResponse) ProtoMessage() {}
+
+func (x *SimulateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SimulateResponse.ProtoReflect.Descriptor instead.
+func (*SimulateResponse) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SimulateResponse) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *SimulateResponse) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *SimulateResponse) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *SimulateResponse) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *SimulateResponse) GetExplain() []string {
+	if x != nil {
+		return x.Explain
+	}
+	return nil
+}
+
+func (x *SimulateResponse) GetTrace() []string {
+	if x != nil {
+		return x.Trace
+	}
+	return nil
+}
+
+type SimulateBatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// index is the position of the corresponding request within the stream, starting at zero
+	Index uint64 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// Types that are valid to be assigned to Outcome:
+	//
+	//	*SimulateBatchResponse_Output
+	//	*SimulateBatchResponse_Error
+	Outcome       isSimulateBatchResponse_Outcome `protobuf_oneof:"outcome"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SimulateBatchResponse) Reset() {
+	*x = SimulateBatchResponse{}
+	mi := &file_yams_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SimulateBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SimulateBatchResponse) ProtoMessage() {}
+
+func (x *SimulateBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SimulateBatchResponse.ProtoReflect.Descriptor instead.
+func (*SimulateBatchResponse) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SimulateBatchResponse) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *SimulateBatchResponse) GetOutcome() isSimulateBatchResponse_Outcome {
+	if x != nil {
+		return x.Outcome
+	}
+	return nil
+}
+
+func (x *SimulateBatchResponse) GetOutput() *SimulateResponse {
+	if x != nil {
+		if x, ok := x.Outcome.(*SimulateBatchResponse_Output); ok {
+			return x.Output
+		}
+	}
+	return nil
+}
+
+func (x *SimulateBatchResponse) GetError() string {
+	if x != nil {
+		if x, ok := x.Outcome.(*SimulateBatchResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return ""
+}
+
+type isSimulateBatchResponse_Outcome interface {
+	isSimulateBatchResponse_Outcome()
+}
+
+type SimulateBatchResponse_Output struct {
+	Output *SimulateResponse `protobuf:"bytes,2,opt,name=output,proto3,oneof"`
+}
+
+type SimulateBatchResponse_Error struct {
+	Error string `protobuf:"bytes,3,opt,name=error,proto3,oneof"`
+}
+
+func (*SimulateBatchResponse_Output) isSimulateBatchResponse_Outcome() {}
+
+func (*SimulateBatchResponse_Error) isSimulateBatchResponse_Outcome() {}
+
+type WhichPrincipalsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Action        string                 `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	Resource      string                 `protobuf:"bytes,2,opt,name=resource,proto3" json:"resource,omitempty"`
+	Context       map[string]string      `protobuf:"bytes,3,rep,name=context,proto3" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Fuzzy         bool                   `protobuf:"varint,4,opt,name=fuzzy,proto3" json:"fuzzy,omitempty"`
+	Overlay       *Entities              `protobuf:"bytes,5,opt,name=overlay,proto3" json:"overlay,omitempty"`
+	OverlayIds    []string               `protobuf:"bytes,6,rep,name=overlay_ids,json=overlayIds,proto3" json:"overlay_ids,omitempty"`
+	Overlays      []*Entities            `protobuf:"bytes,7,rep,name=overlays,proto3" json:"overlays,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WhichPrincipalsRequest) Reset() {
+	*x = WhichPrincipalsRequest{}
+	mi := &file_yams_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WhichPrincipalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WhichPrincipalsRequest) ProtoMessage() {}
+
+func (x *WhichPrincipalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WhichPrincipalsRequest.ProtoReflect.Descriptor instead.
+func (*WhichPrincipalsRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WhichPrincipalsRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *WhichPrincipalsRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *WhichPrincipalsRequest) GetContext() map[string]string {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *WhichPrincipalsRequest) GetFuzzy() bool {
+	if x != nil {
+		return x.Fuzzy
+	}
+	return false
+}
+
+func (x *WhichPrincipalsRequest) GetOverlay() *Entities {
+	if x != nil {
+		return x.Overlay
+	}
+	return nil
+}
+
+func (x *WhichPrincipalsRequest) GetOverlayIds() []string {
+	if x != nil {
+		return x.OverlayIds
+	}
+	return nil
+}
+
+func (x *WhichPrincipalsRequest) GetOverlays() []*Entities {
+	if x != nil {
+		return x.Overlays
+	}
+	return nil
+}
+
+type WhichActionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Principal     string                 `protobuf:"bytes,1,opt,name=principal,proto3" json:"principal,omitempty"`
+	Resource      string                 `protobuf:"bytes,2,opt,name=resource,proto3" json:"resource,omitempty"`
+	Context       map[string]string      `protobuf:"bytes,3,rep,name=context,proto3" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Fuzzy         bool                   `protobuf:"varint,4,opt,name=fuzzy,proto3" json:"fuzzy,omitempty"`
+	Overlay       *Entities              `protobuf:"bytes,5,opt,name=overlay,proto3" json:"overlay,omitempty"`
+	OverlayIds    []string               `protobuf:"bytes,6,rep,name=overlay_ids,json=overlayIds,proto3" json:"overlay_ids,omitempty"`
+	Overlays      []*Entities            `protobuf:"bytes,7,rep,name=overlays,proto3" json:"overlays,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WhichActionsRequest) Reset() {
+	*x = WhichActionsRequest{}
+	mi := &file_yams_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WhichActionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WhichActionsRequest) ProtoMessage() {}
+
+func (x *WhichActionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WhichActionsRequest.ProtoReflect.Descriptor instead.
+func (*WhichActionsRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WhichActionsRequest) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *WhichActionsRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *WhichActionsRequest) GetContext() map[string]string {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *WhichActionsRequest) GetFuzzy() bool {
+	if x != nil {
+		return x.Fuzzy
+	}
+	return false
+}
+
+func (x *WhichActionsRequest) GetOverlay() *Entities {
+	if x != nil {
+		return x.Overlay
+	}
+	return nil
+}
+
+func (x *WhichActionsRequest) GetOverlayIds() []string {
+	if x != nil {
+		return x.OverlayIds
+	}
+	return nil
+}
+
+func (x *WhichActionsRequest) GetOverlays() []*Entities {
+	if x != nil {
+		return x.Overlays
+	}
+	return nil
+}
+
+type WhichResourcesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Principal     string                 `protobuf:"bytes,1,opt,name=principal,proto3" json:"principal,omitempty"`
+	Action        string                 `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	Context       map[string]string      `protobuf:"bytes,3,rep,name=context,proto3" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Fuzzy         bool                   `protobuf:"varint,4,opt,name=fuzzy,proto3" json:"fuzzy,omitempty"`
+	Overlay       *Entities              `protobuf:"bytes,5,opt,name=overlay,proto3" json:"overlay,omitempty"`
+	OverlayIds    []string               `protobuf:"bytes,6,rep,name=overlay_ids,json=overlayIds,proto3" json:"overlay_ids,omitempty"`
+	Overlays      []*Entities            `protobuf:"bytes,7,rep,name=overlays,proto3" json:"overlays,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WhichResourcesRequest) Reset() {
+	*x = WhichResourcesRequest{}
+	mi := &file_yams_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WhichResourcesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WhichResourcesRequest) ProtoMessage() {}
+
+func (x *WhichResourcesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WhichResourcesRequest.ProtoReflect.Descriptor instead.
+func (*WhichResourcesRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WhichResourcesRequest) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *WhichResourcesRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *WhichResourcesRequest) GetContext() map[string]string {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *WhichResourcesRequest) GetFuzzy() bool {
+	if x != nil {
+		return x.Fuzzy
+	}
+	return false
+}
+
+func (x *WhichResourcesRequest) GetOverlay() *Entities {
+	if x != nil {
+		return x.Overlay
+	}
+	return nil
+}
+
+func (x *WhichResourcesRequest) GetOverlayIds() []string {
+	if x != nil {
+		return x.OverlayIds
+	}
+	return nil
+}
+
+func (x *WhichResourcesRequest) GetOverlays() []*Entities {
+	if x != nil {
+		return x.Overlays
+	}
+	return nil
+}
+
+type WhichResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []string               `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WhichResponse) Reset() {
+	*x = WhichResponse{}
+	mi := &file_yams_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WhichResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WhichResponse) ProtoMessage() {}
+
+func (x *WhichResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WhichResponse.ProtoReflect.Descriptor instead.
+func (*WhichResponse) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WhichResponse) GetResults() []string {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type Overlay struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name      string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Entities  *Entities              `protobuf:"bytes,4,opt,name=entities,proto3" json:"entities,omitempty"`
+	Revision  int64                  `protobuf:"varint,5,opt,name=revision,proto3" json:"revision,omitempty"`
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Author    string                 `protobuf:"bytes,7,opt,name=author,proto3" json:"author,omitempty"`
+	Message   string                 `protobuf:"bytes,8,opt,name=message,proto3" json:"message,omitempty"`
+	// expires_at is unset for overlays which never expire
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// warnings found while linting the overlay; only set in responses to CreateOverlay and
+	// UpdateOverlay, which fail with INVALID_ARGUMENT if errors are found
+	Warnings      []*OverlayIssue `protobuf:"bytes,10,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Overlay) Reset() {
+	*x = Overlay{}
+	mi := &file_yams_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Overlay) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Overlay) ProtoMessage() {}
+
+func (x *Overlay) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Overlay.ProtoReflect.Descriptor instead.
+func (*Overlay) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Overlay) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Overlay) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Overlay) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Overlay) GetEntities() *Entities {
+	if x != nil {
+		return x.Entities
+	}
+	return nil
+}
+
+func (x *Overlay) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *Overlay) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Overlay) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *Overlay) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Overlay) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Overlay) GetWarnings() []*OverlayIssue {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type OverlayIssue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Severity      string                 `protobuf:"bytes,1,opt,name=severity,proto3" json:"severity,omitempty"`
+	Code          string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Kind          string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	Key           string                 `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
+	Field         string                 `protobuf:"bytes,5,opt,name=field,proto3" json:"field,omitempty"`
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OverlayIssue) Reset() {
+	*x = OverlayIssue{}
+	mi := &file_yams_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OverlayIssue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OverlayIssue) ProtoMessage() {}
+
+func (x *OverlayIssue) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OverlayIssue.ProtoReflect.Descriptor instead.
+func (*OverlayIssue) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *OverlayIssue) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *OverlayIssue) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *OverlayIssue) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *OverlayIssue) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *OverlayIssue) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *OverlayIssue) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type OverlaySummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	NumPrincipals int64                  `protobuf:"varint,4,opt,name=num_principals,json=numPrincipals,proto3" json:"num_principals,omitempty"`
+	NumResources  int64                  `protobuf:"varint,5,opt,name=num_resources,json=numResources,proto3" json:"num_resources,omitempty"`
+	NumPolicies   int64                  `protobuf:"varint,6,opt,name=num_policies,json=numPolicies,proto3" json:"num_policies,omitempty"`
+	NumAccounts   int64                  `protobuf:"varint,7,opt,name=num_accounts,json=numAccounts,proto3" json:"num_accounts,omitempty"`
+	NumGroups     int64                  `protobuf:"varint,8,opt,name=num_groups,json=numGroups,proto3" json:"num_groups,omitempty"`
+	NumTombstones int64                  `protobuf:"varint,9,opt,name=num_tombstones,json=numTombstones,proto3" json:"num_tombstones,omitempty"`
+	NumPatches    int64                  `protobuf:"varint,10,opt,name=num_patches,json=numPatches,proto3" json:"num_patches,omitempty"`
+	Revision      int64                  `protobuf:"varint,11,opt,name=revision,proto3" json:"revision,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OverlaySummary) Reset() {
+	*x = OverlaySummary{}
+	mi := &file_yams_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OverlaySummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OverlaySummary) ProtoMessage() {}
+
+func (x *OverlaySummary) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OverlaySummary.ProtoReflect.Descriptor instead.
+func (*OverlaySummary) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *OverlaySummary) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *OverlaySummary) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *OverlaySummary) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *OverlaySummary) GetNumPrincipals() int64 {
+	if x != nil {
+		return x.NumPrincipals
+	}
+	return 0
+}
+
+func (x *OverlaySummary) GetNumResources() int64 {
+	if x != nil {
+		return x.NumResources
+	}
+	return 0
+}
+
+func (x *OverlaySummary) GetNumPolicies() int64 {
+	if x != nil {
+		return x.NumPolicies
+	}
+	return 0
+}
+
+func (x *OverlaySummary) GetNumAccounts() int64 {
+	if x != nil {
+		return x.NumAccounts
+	}
+	return 0
+}
+
+func (x *OverlaySummary) GetNumGroups() int64 {
+	if x != nil {
+		return x.NumGroups
+	}
+	return 0
+}
+
+func (x *OverlaySummary) GetNumTombstones() int64 {
+	if x != nil {
+		return x.NumTombstones
+	}
+	return 0
+}
+
+func (x *OverlaySummary) GetNumPatches() int64 {
+	if x != nil {
+		return x.NumPatches
+	}
+	return 0
+}
+
+func (x *OverlaySummary) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *OverlaySummary) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *OverlaySummary) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+type ListOverlaysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListOverlaysRequest) Reset() {
+	*x = ListOverlaysRequest{}
+	mi := &file_yams_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListOverlaysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListOverlaysRequest) ProtoMessage() {}
+
+func (x *ListOverlaysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListOverlaysRequest.ProtoReflect.Descriptor instead.
+func (*ListOverlaysRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListOverlaysRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+type ListOverlaysResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Overlays      []*OverlaySummary      `protobuf:"bytes,1,rep,name=overlays,proto3" json:"overlays,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListOverlaysResponse) Reset() {
+	*x = ListOverlaysResponse{}
+	mi := &file_yams_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListOverlaysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListOverlaysResponse) ProtoMessage() {}
+
+func (x *ListOverlaysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListOverlaysResponse.ProtoReflect.Descriptor instead.
+func (*ListOverlaysResponse) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListOverlaysResponse) GetOverlays() []*OverlaySummary {
+	if x != nil {
+		return x.Overlays
+	}
+	return nil
+}
+
+type GetOverlayRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOverlayRequest) Reset() {
+	*x = GetOverlayRequest{}
+	mi := &file_yams_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOverlayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOverlayRequest) ProtoMessage() {}
+
+func (x *GetOverlayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOverlayRequest.ProtoReflect.Descriptor instead.
+func (*GetOverlayRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetOverlayRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CreateOverlayRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Name     string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Entities *Entities              `protobuf:"bytes,2,opt,name=entities,proto3" json:"entities,omitempty"`
+	Author   string                 `protobuf:"bytes,3,opt,name=author,proto3" json:"author,omitempty"`
+	Message  string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	// at most one of expires_at and ttl (a duration such as "72h") may be set
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	Ttl       string                 `protobuf:"bytes,6,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	// dry_run lints the overlay and returns it without storing it
+	DryRun        bool `protobuf:"varint,7,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateOverlayRequest) Reset() {
+	*x = CreateOverlayRequest{}
+	mi := &file_yams_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateOverlayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateOverlayRequest) ProtoMessage() {}
+
+func (x *CreateOverlayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateOverlayRequest.ProtoReflect.Descriptor instead.
+func (*CreateOverlayRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CreateOverlayRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateOverlayRequest) GetEntities() *Entities {
+	if x != nil {
+		return x.Entities
+	}
+	return nil
+}
+
+func (x *CreateOverlayRequest) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *CreateOverlayRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *CreateOverlayRequest) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *CreateOverlayRequest) GetTtl() string {
+	if x != nil {
+		return x.Ttl
+	}
+	return ""
+}
+
+func (x *CreateOverlayRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type UpdateOverlayRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id       string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name     string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Entities *Entities              `protobuf:"bytes,3,opt,name=entities,proto3" json:"entities,omitempty"`
+	// revision, if set, must be the current revision of the overlay or the update fails with ABORTED
+	Revision int64  `protobuf:"varint,4,opt,name=revision,proto3" json:"revision,omitempty"`
+	Author   string `protobuf:"bytes,5,opt,name=author,proto3" json:"author,omitempty"`
+	Message  string `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	// if neither expires_at nor ttl is set, the overlay keeps its current expiry; a ttl of "0"
+	// removes it
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	Ttl       string                 `protobuf:"bytes,8,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	// dry_run lints the updated overlay and returns it without storing it
+	DryRun        bool `protobuf:"varint,9,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateOverlayRequest) Reset() {
+	*x = UpdateOverlayRequest{}
+	mi := &file_yams_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateOverlayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateOverlayRequest) ProtoMessage() {}
+
+func (x *UpdateOverlayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateOverlayRequest.ProtoReflect.Descriptor instead.
+func (*UpdateOverlayRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UpdateOverlayRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateOverlayRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UpdateOverlayRequest) GetEntities() *Entities {
+	if x != nil {
+		return x.Entities
+	}
+	return nil
+}
+
+func (x *UpdateOverlayRequest) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *UpdateOverlayRequest) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *UpdateOverlayRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *UpdateOverlayRequest) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *UpdateOverlayRequest) GetTtl() string {
+	if x != nil {
+		return x.Ttl
+	}
+	return ""
+}
+
+func (x *UpdateOverlayRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type DeleteOverlayRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteOverlayRequest) Reset() {
+	*x = DeleteOverlayRequest{}
+	mi := &file_yams_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteOverlayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteOverlayRequest) ProtoMessage() {}
+
+func (x *DeleteOverlayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteOverlayRequest.ProtoReflect.Descriptor instead.
+func (*DeleteOverlayRequest) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DeleteOverlayRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteOverlayResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteOverlayResponse) Reset() {
+	*x = DeleteOverlayResponse{}
+	mi := &file_yams_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteOverlayResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteOverlayResponse) ProtoMessage() {}
+
+func (x *DeleteOverlayResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_yams_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteOverlayResponse.ProtoReflect.Descriptor instead.
+func (*DeleteOverlayResponse) Descriptor() ([]byte, []int) {
+	return file_yams_proto_rawDescGZIP(), []int{17}
+}
+
+var File_yams_proto protoreflect.FileDescriptor
+
+const file_yams_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"yams.proto\x12\ayams.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd2\x01\n" +
+	"\bEntities\x12\x1a\n" +
+	"\baccounts\x18\x01 \x03(\fR\baccounts\x12\x16\n" +
+	"\x06groups\x18\x02 \x03(\fR\x06groups\x12\x1a\n" +
+	"\bpolicies\x18\x03 \x03(\fR\bpolicies\x12\x1e\n" +
+	"\n" +
+	"principals\x18\x04 \x03(\fR\n" +
+	"principals\x12\x1c\n" +
+	"\tresources\x18\x05 \x03(\fR\tresources\x12\x1e\n" +
+	"\n" +
+	"tombstones\x18\x06 \x03(\tR\n" +
+	"tombstones\x12\x18\n" +
+	"\apatches\x18\a \x03(\fR\apatches\"\xa3\x03\n" +
+	"\x0fSimulateRequest\x12\x1c\n" +
+	"\tprincipal\x18\x01 \x01(\tR\tprincipal\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\tR\x06action\x12\x1a\n" +
+	"\bresource\x18\x03 \x01(\tR\bresource\x12?\n" +
+	"\acontext\x18\x04 \x03(\v2%.yams.v1.SimulateRequest.ContextEntryR\acontext\x12\x14\n" +
+	"\x05fuzzy\x18\x05 \x01(\bR\x05fuzzy\x12\x18\n" +
+	"\aexplain\x18\x06 \x01(\bR\aexplain\x12\x14\n" +
+	"\x05trace\x18\a \x01(\bR\x05trace\x12+\n" +
+	"\aoverlay\x18\b \x01(\v2\x11.yams.v1.EntitiesR\aoverlay\x12\x1f\n" +
+	"\voverlay_ids\x18\t \x03(\tR\n" +
+	"overlayIds\x12-\n" +
+	"\boverlays\x18\n" +
+	" \x03(\v2\x11.yams.v1.EntitiesR\boverlays\x1a:\n" +
+	"\fContextEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xac\x01\n" +
+	"\x10SimulateResponse\x12\x16\n" +
+	"\x06result\x18\x01 \x01(\tR\x06result\x12\x1c\n" +
+	"\tprincipal\x18\x02 \x01(\tR\tprincipal\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x1a\n" +
+	"\bresource\x18\x04 \x01(\tR\bresource\x12\x18\n" +
+	"\aexplain\x18\x05 \x03(\tR\aexplain\x12\x14\n" +
+	"\x05trace\x18\x06 \x03(\tR\x05trace\"\x85\x01\n" +
+	"\x15SimulateBatchResponse\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x04R\x05index\x123\n" +
+	"\x06output\x18\x02 \x01(\v2\x19.yams.v1.SimulateResponseH\x00R\x06output\x12\x16\n" +
+	"\x05error\x18\x03 \x01(\tH\x00R\x05errorB\t\n" +
+	"\aoutcome\"\xe3\x02\n" +
+	"\x16WhichPrincipalsRequest\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\x12\x1a\n" +
+	"\bresource\x18\x02 \x01(\tR\bresource\x12F\n" +
+	"\acontext\x18\x03 \x03(\v2,.yams.v1.WhichPrincipalsRequest.ContextEntryR\acontext\x12\x14\n" +
+	"\x05fuzzy\x18\x04 \x01(\bR\x05fuzzy\x12+\n" +
+	"\aoverlay\x18\x05 \x01(\v2\x11.yams.v1.EntitiesR\aoverlay\x12\x1f\n" +
+	"\voverlay_ids\x18\x06 \x03(\tR\n" +
+	"overlayIds\x12-\n" +
+	"\boverlays\x18\a \x03(\v2\x11.yams.v1.EntitiesR\boverlays\x1a:\n" +
+	"\fContextEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xe3\x02\n" +
+	"\x13WhichActionsRequest\x12\x1c\n" +
+	"\tprincipal\x18\x01 \x01(\tR\tprincipal\x12\x1a\n" +
+	"\bresource\x18\x02 \x01(\tR\bresource\x12C\n" +
+	"\acontext\x18\x03 \x03(\v2).yams.v1.WhichActionsRequest.ContextEntryR\acontext\x12\x14\n" +
+	"\x05fuzzy\x18\x04 \x01(\bR\x05fuzzy\x12+\n" +
+	"\aoverlay\x18\x05 \x01(\v2\x11.yams.v1.EntitiesR\aoverlay\x12\x1f\n" +
+	"\voverlay_ids\x18\x06 \x03(\tR\n" +
+	"overlayIds\x12-\n" +
+	"\boverlays\x18\a \x03(\v2\x11.yams.v1.EntitiesR\boverlays\x1a:\n" +
+	"\fContextEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xe3\x02\n" +
+	"\x15WhichResourcesRequest\x12\x1c\n" +
+	"\tprincipal\x18\x01 \x01(\tR\tprincipal\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\tR\x06action\x12E\n" +
+	"\acontext\x18\x03 \x03(\v2+.yams.v1.WhichResourcesRequest.ContextEntryR\acontext\x12\x14\n" +
+	"\x05fuzzy\x18\x04 \x01(\bR\x05fuzzy\x12+\n" +
+	"\aoverlay\x18\x05 \x01(\v2\x11.yams.v1.EntitiesR\aoverlay\x12\x1f\n" +
+	"\voverlay_ids\x18\x06 \x03(\tR\n" +
+	"overlayIds\x12-\n" +
+	"\boverlays\x18\a \x03(\v2\x11.yams.v1.EntitiesR\boverlays\x1a:\n" +
+	"\fContextEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\")\n" +
+	"\rWhichResponse\x12\x18\n" +
+	"\aresults\x18\x01 \x03(\tR\aresults\"\x8e\x03\n" +
+	"\aOverlay\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x129\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12-\n" +
+	"\bentities\x18\x04 \x01(\v2\x11.yams.v1.EntitiesR\bentities\x12\x1a\n" +
+	"\brevision\x18\x05 \x01(\x03R\brevision\x129\n" +
+	"\n" +
+	"updated_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x16\n" +
+	"\x06author\x18\a \x01(\tR\x06author\x12\x18\n" +
+	"\amessage\x18\b \x01(\tR\amessage\x129\n" +
+	"\n" +
+	"expires_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x121\n" +
+	"\bwarnings\x18\n" +
+	" \x03(\v2\x15.yams.v1.OverlayIssueR\bwarnings\"\x94\x01\n" +
+	"\fOverlayIssue\x12\x1a\n" +
+	"\bseverity\x18\x01 \x01(\tR\bseverity\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\x12\x10\n" +
+	"\x03key\x18\x04 \x01(\tR\x03key\x12\x14\n" +
+	"\x05field\x18\x05 \x01(\tR\x05field\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\"\xfa\x03\n" +
+	"\x0eOverlaySummary\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x129\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12%\n" +
+	"\x0enum_principals\x18\x04 \x01(\x03R\rnumPrincipals\x12#\n" +
+	"\rnum_resources\x18\x05 \x01(\x03R\fnumResources\x12!\n" +
+	"\fnum_policies\x18\x06 \x01(\x03R\vnumPolicies\x12!\n" +
+	"\fnum_accounts\x18\a \x01(\x03R\vnumAccounts\x12\x1d\n" +
+	"\n" +
+	"num_groups\x18\b \x01(\x03R\tnumGroups\x12%\n" +
+	"\x0enum_tombstones\x18\t \x01(\x03R\rnumTombstones\x12\x1f\n" +
+	"\vnum_patches\x18\n" +
+	" \x01(\x03R\n" +
+	"numPatches\x12\x1a\n" +
+	"\brevision\x18\v \x01(\x03R\brevision\x129\n" +
+	"\n" +
+	"updated_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x129\n" +
+	"\n" +
+	"expires_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"+\n" +
+	"\x13ListOverlaysRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\"K\n" +
+	"\x14ListOverlaysResponse\x123\n" +
+	"\boverlays\x18\x01 \x03(\v2\x17.yams.v1.OverlaySummaryR\boverlays\"#\n" +
+	"\x11GetOverlayRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xf1\x01\n" +
+	"\x14CreateOverlayRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12-\n" +
+	"\bentities\x18\x02 \x01(\v2\x11.yams.v1.EntitiesR\bentities\x12\x16\n" +
+	"\x06author\x18\x03 \x01(\tR\x06author\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x129\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x10\n" +
+	"\x03ttl\x18\x06 \x01(\tR\x03ttl\x12\x17\n" +
+	"\adry_run\x18\a \x01(\bR\x06dryRun\"\x9d\x02\n" +
+	"\x14UpdateOverlayRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12-\n" +
+	"\bentities\x18\x03 \x01(\v2\x11.yams.v1.EntitiesR\bentities\x12\x1a\n" +
+	"\brevision\x18\x04 \x01(\x03R\brevision\x12\x16\n" +
+	"\x06author\x18\x05 \x01(\tR\x06author\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x129\n" +
+	"\n" +
+	"expires_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x10\n" +
+	"\x03ttl\x18\b \x01(\tR\x03ttl\x12\x17\n" +
+	"\adry_run\x18\t \x01(\bR\x06dryRun\"&\n" +
+	"\x14DeleteOverlayRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x17\n" +
+	"\x15DeleteOverlayResponse2\xcf\x05\n" +
+	"\x04Yams\x12?\n" +
+	"\bSimulate\x12\x18.yams.v1.SimulateRequest\x1a\x19.yams.v1.SimulateResponse\x12M\n" +
+	"\rSimulateBatch\x12\x18.yams.v1.SimulateRequest\x1a\x1e.yams.v1.SimulateBatchResponse(\x010\x01\x12J\n" +
+	"\x0fWhichPrincipals\x12\x1f.yams.v1.WhichPrincipalsRequest\x1a\x16.yams.v1.WhichResponse\x12D\n" +
+	"\fWhichActions\x12\x1c.yams.v1.WhichActionsRequest\x1a\x16.yams.v1.WhichResponse\x12H\n" +
+	"\x0eWhichResources\x12\x1e.yams.v1.WhichResourcesRequest\x1a\x16.yams.v1.WhichResponse\x12K\n" +
+	"\fListOverlays\x12\x1c.yams.v1.ListOverlaysRequest\x1a\x1d.yams.v1.ListOverlaysResponse\x12:\n" +
+	"\n" +
+	"GetOverlay\x12\x1a.yams.v1.GetOverlayRequest\x1a\x10.yams.v1.Overlay\x12@\n" +
+	"\rCreateOverlay\x12\x1d.yams.v1.CreateOverlayRequest\x1a\x10.yams.v1.Overlay\x12@\n" +
+	"\rUpdateOverlay\x12\x1d.yams.v1.UpdateOverlayRequest\x1a\x10.yams.v1.Overlay\x12N\n" +
+	"\rDeleteOverlay\x12\x1d.yams.v1.DeleteOverlayRequest\x1a\x1e.yams.v1.DeleteOverlayResponseB1Z/github.com/nsiow/yams/pkg/server/api/rpc/yamspbb\x06proto3"
+
+var (
+	file_yams_proto_rawDescOnce sync.Once
+	file_yams_proto_rawDescData []byte
+)
+
+func file_yams_proto_rawDescGZIP() []byte {
+	file_yams_proto_rawDescOnce.Do(func() {
+		file_yams_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_yams_proto_rawDesc), len(file_yams_proto_rawDesc)))
+	})
+	return file_yams_proto_rawDescData
+}
+
+var file_yams_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
+var file_yams_proto_goTypes = []any{
+	(*Entities)(nil),               // 0: yams.v1.Entities
+	(*SimulateRequest)(nil),        // 1: yams.v1.SimulateRequest
+	(*SimulateResponse)(nil),       // 2: yams.v1.SimulateResponse
+	(*SimulateBatchResponse)(nil),  // 3: yams.v1.SimulateBatchResponse
+	(*WhichPrincipalsRequest)(nil), // 4: yams.v1.WhichPrincipalsRequest
+	(*WhichActionsRequest)(nil),    // 5: yams.v1.WhichActionsRequest
+	(*WhichResourcesRequest)(nil),  // 6: yams.v1.WhichResourcesRequest
+	(*WhichResponse)(nil),          // 7: yams.v1.WhichResponse
+	(*Overlay)(nil),                // 8: yams.v1.Overlay
+	(*OverlayIssue)(nil),           // 9: yams.v1.OverlayIssue
+	(*OverlaySummary)(nil),         // 10: yams.v1.OverlaySummary
+	(*ListOverlaysRequest)(nil),    // 11: yams.v1.ListOverlaysRequest
+	(*ListOverlaysResponse)(nil),   // 12: yams.v1.ListOverlaysResponse
+	(*GetOverlayRequest)(nil),      // 13: yams.v1.GetOverlayRequest
+	(*CreateOverlayRequest)(nil),   // 14: yams.v1.CreateOverlayRequest
+	(*UpdateOverlayRequest)(nil),   // 15: yams.v1.UpdateOverlayRequest
+	(*DeleteOverlayRequest)(nil),   // 16: yams.v1.DeleteOverlayRequest
+	(*DeleteOverlayResponse)(nil),  // 17: yams.v1.DeleteOverlayResponse
+	nil,                            // 18: yams.v1.SimulateRequest.ContextEntry
+	nil,                            // 19: yams.v1.WhichPrincipalsRequest.ContextEntry
+	nil,                            // 20: yams.v1.WhichActionsRequest.ContextEntry
+	nil,                            // 21: yams.v1.WhichResourcesRequest.ContextEntry
+	(*timestamppb.Timestamp)(nil),  // 22: google.protobuf.Timestamp
+}
+var file_yams_proto_depIdxs = []int32{
+	18, // 0: yams.v1.SimulateRequest.context:type_name -> yams.v1.SimulateRequest.ContextEntry
+	0,  // 1: yams.v1.SimulateRequest.overlay:type_name -> yams.v1.Entities
+	0,  // 2: yams.v1.SimulateRequest.overlays:type_name -> yams.v1.Entities
+	2,  // 3: yams.v1.SimulateBatchResponse.output:type_name -> yams.v1.SimulateResponse
+	19, // 4: yams.v1.WhichPrincipalsRequest.context:type_name -> yams.v1.WhichPrincipalsRequest.ContextEntry
+	0,  // 5: yams.v1.WhichPrincipalsRequest.overlay:type_name -> yams.v1.Entities
+	0,  // 6: yams.v1.WhichPrincipalsRequest.overlays:type_name -> yams.v1.Entities
+	20, // 7: yams.v1.WhichActionsRequest.context:type_name -> yams.v1.WhichActionsRequest.ContextEntry
+	0,  // 8: yams.v1.WhichActionsRequest.overlay:type_name -> yams.v1.Entities
+	0,  // 9: yams.v1.WhichActionsRequest.overlays:type_name -> yams.v1.Entities
+	21, // 10: yams.v1.WhichResourcesRequest.context:type_name -> yams.v1.WhichResourcesRequest.ContextEntry
+	0,  // 11: yams.v1.WhichResourcesRequest.overlay:type_name -> yams.v1.Entities
+	0,  // 12: yams.v1.WhichResourcesRequest.overlays:type_name -> yams.v1.Entities
+	22, // 13: yams.v1.Overlay.created_at:type_name -> google.protobuf.Timestamp
+	0,  // 14: yams.v1.Overlay.entities:type_name -> yams.v1.Entities
+	22, // 15: yams.v1.Overlay.updated_at:type_name -> google.protobuf.Timestamp
+	22, // 16: yams.v1.Overlay.expires_at:type_name -> google.protobuf.Timestamp
+	9,  // 17: yams.v1.Overlay.warnings:type_name -> yams.v1.OverlayIssue
+	22, // 18: yams.v1.OverlaySummary.created_at:type_name -> google.protobuf.Timestamp
+	22, // 19: yams.v1.OverlaySummary.updated_at:type_name -> google.protobuf.Timestamp
+	22, // 20: yams.v1.OverlaySummary.expires_at:type_name -> google.protobuf.Timestamp
+	10, // 21: yams.v1.ListOverlaysResponse.overlays:type_name -> yams.v1.OverlaySummary
+	0,  // 22: yams.v1.CreateOverlayRequest.entities:type_name -> yams.v1.Entities
+	22, // 23: yams.v1.CreateOverlayRequest.expires_at:type_name -> google.protobuf.Timestamp
+	0,  // 24: yams.v1.UpdateOverlayRequest.entities:type_name -> yams.v1.Entities
+	22, // 25: yams.v1.UpdateOverlayRequest.expires_at:type_name -> google.protobuf.Timestamp
+	1,  // 26: yams.v1.Yams.Simulate:input_type -> yams.v1.SimulateRequest
+	1,  // 27: yams.v1.Yams.SimulateBatch:input_type -> yams.v1.SimulateRequest
+	4,  // 28: yams.v1.Yams.WhichPrincipals:input_type -> yams.v1.WhichPrincipalsRequest
+	5,  // 29: yams.v1.Yams.WhichActions:input_type -> yams.v1.WhichActionsRequest
+	6,  // 30: yams.v1.Yams.WhichResources:input_type -> yams.v1.WhichResourcesRequest
+	11, // 31: yams.v1.Yams.ListOverlays:input_type -> yams.v1.ListOverlaysRequest
+	13, // 32: yams.v1.Yams.GetOverlay:input_type -> yams.v1.GetOverlayRequest
+	14, // 33: yams.v1.Yams.CreateOverlay:input_type -> yams.v1.CreateOverlayRequest
+	15, // 34: yams.v1.Yams.UpdateOverlay:input_type -> yams.v1.UpdateOverlayRequest
+	16, // 35: yams.v1.Yams.DeleteOverlay:input_type -> yams.v1.DeleteOverlayRequest
+	2,  // 36: yams.v1.Yams.Simulate:output_type -> yams.v1.SimulateResponse
+	3,  // 37: yams.v1.Yams.SimulateBatch:output_type -> yams.v1.SimulateBatchResponse
+	7,  // 38: yams.v1.Yams.WhichPrincipals:output_type -> yams.v1.WhichResponse
+	7,  // 39: yams.v1.Yams.WhichActions:output_type -> yams.v1.WhichResponse
+	7,  // 40: yams.v1.Yams.WhichResources:output_type -> yams.v1.WhichResponse
+	12, // 41: yams.v1.Yams.ListOverlays:output_type -> yams.v1.ListOverlaysResponse
+	8,  // 42: yams.v1.Yams.GetOverlay:output_type -> yams.v1.Overlay
+	8,  // 43: yams.v1.Yams.CreateOverlay:output_type -> yams.v1.Overlay
+	8,  // 44: yams.v1.Yams.UpdateOverlay:output_type -> yams.v1.Overlay
+	17, // 45: yams.v1.Yams.DeleteOverlay:output_type -> yams.v1.DeleteOverlayResponse
+	36, // [36:46] is the sub-list for method output_type
+	26, // [26:36] is the sub-list for method input_type
+	26, // [26:26] is the sub-list for extension type_name
+	26, // [26:26] is the sub-list for extension extendee
+	0,  // [0:26] is the sub-list for field type_name
+}
+
+func init() { file_yams_proto_init() }
+func file_yams_proto_init() {
+	if File_yams_proto != nil {
+		return
+	}
+	file_yams_proto_msgTypes[3].OneofWrappers = []any{
+		(*SimulateBatchResponse_Output)(nil),
+		(*SimulateBatchResponse_Error)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_yams_proto_rawDesc), len(file_yams_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   22,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_yams_proto_goTypes,
+		DependencyIndexes: file_yams_proto_depIdxs,
+		MessageInfos:      file_yams_proto_msgTypes,
+	}.Build()
+	File_yams_proto = out.File
+	file_yams_proto_goTypes = nil
+	file_yams_proto_depIdxs = nil
+}
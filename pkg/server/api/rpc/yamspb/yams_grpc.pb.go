@@ -0,0 +1,486 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: yams.proto
+
+package yamspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Yams_Simulate_FullMethodName        = "/yams.v1.Yams/Simulate"
+	Yams_SimulateBatch_FullMethodName   = "/yams.v1.Yams/SimulateBatch"
+	Yams_WhichPrincipals_FullMethodName = "/yams.v1.Yams/WhichPrincipals"
+	Yams_WhichActions_FullMethodName    = "/yams.v1.Yams/WhichActions"
+	Yams_WhichResources_FullMethodName  = "/yams.v1.Yams/WhichResources"
+	Yams_ListOverlays_FullMethodName    = "/yams.v1.Yams/ListOverlays"
+	Yams_GetOverlay_FullMethodName      = "/yams.v1.Yams/GetOverlay"
+	Yams_CreateOverlay_FullMethodName   = "/yams.v1.Yams/CreateOverlay"
+	Yams_UpdateOverlay_FullMethodName   = "/yams.v1.Yams/UpdateOverlay"
+	Yams_DeleteOverlay_FullMethodName   = "/yams.v1.Yams/DeleteOverlay"
+)
+
+// YamsClient is the client API for Yams service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Yams exposes the simulation and overlay operations of the v1 HTTP API over gRPC, for callers
+// which need to perform large numbers of simulations with low overhead
+type YamsClient interface {
+	// Simulate determines whether a principal is allowed to perform an action on a resource
+	Simulate(ctx context.Context, in *SimulateRequest, opts ...grpc.CallOption) (*SimulateResponse, error)
+	// SimulateBatch performs a simulation for each request received on the stream, sending back one
+	// response per request in the order the requests were received
+	SimulateBatch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SimulateRequest, SimulateBatchResponse], error)
+	// WhichPrincipals determines which principals are allowed to perform an action on a resource
+	WhichPrincipals(ctx context.Context, in *WhichPrincipalsRequest, opts ...grpc.CallOption) (*WhichResponse, error)
+	// WhichActions determines which actions a principal is allowed to perform on a resource
+	WhichActions(ctx context.Context, in *WhichActionsRequest, opts ...grpc.CallOption) (*WhichResponse, error)
+	// WhichResources determines which resources a principal is allowed to perform an action on
+	WhichResources(ctx context.Context, in *WhichResourcesRequest, opts ...grpc.CallOption) (*WhichResponse, error)
+	// ListOverlays returns summaries of all stored overlays, optionally filtered by a search query
+	ListOverlays(ctx context.Context, in *ListOverlaysRequest, opts ...grpc.CallOption) (*ListOverlaysResponse, error)
+	// GetOverlay retrieves a stored overlay by ID
+	GetOverlay(ctx context.Context, in *GetOverlayRequest, opts ...grpc.CallOption) (*Overlay, error)
+	// CreateOverlay stores a new overlay
+	CreateOverlay(ctx context.Context, in *CreateOverlayRequest, opts ...grpc.CallOption) (*Overlay, error)
+	// UpdateOverlay replaces the name and entities of a stored overlay
+	UpdateOverlay(ctx context.Context, in *UpdateOverlayRequest, opts ...grpc.CallOption) (*Overlay, error)
+	// DeleteOverlay removes a stored overlay by ID
+	DeleteOverlay(ctx context.Context, in *DeleteOverlayRequest, opts ...grpc.CallOption) (*DeleteOverlayResponse, error)
+}
+
+type yamsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewYamsClient(cc grpc.ClientConnInterface) YamsClient {
+	return &yamsClient{cc}
+}
+
+func (c *yamsClient) Simulate(ctx context.Context, in *SimulateRequest, opts ...grpc.CallOption) (*SimulateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SimulateResponse)
+	err := c.cc.Invoke(ctx, Yams_Simulate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yamsClient) SimulateBatch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SimulateRequest, SimulateBatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Yams_ServiceDesc.Streams[0], Yams_SimulateBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SimulateRequest, SimulateBatchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Yams_SimulateBatchClient = grpc.BidiStreamingClient[SimulateRequest, SimulateBatchResponse]
+
+func (c *yamsClient) WhichPrincipals(ctx context.Context, in *WhichPrincipalsRequest, opts ...grpc.CallOption) (*WhichResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WhichResponse)
+	err := c.cc.Invoke(ctx, Yams_WhichPrincipals_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yamsClient) WhichActions(ctx context.Context, in *WhichActionsRequest, opts ...grpc.CallOption) (*WhichResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WhichResponse)
+	err := c.cc.Invoke(ctx, Yams_WhichActions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yamsClient) WhichResources(ctx context.Context, in *WhichResourcesRequest, opts ...grpc.CallOption) (*WhichResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WhichResponse)
+	err := c.cc.Invoke(ctx, Yams_WhichResources_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yamsClient) ListOverlays(ctx context.Context, in *ListOverlaysRequest, opts ...grpc.CallOption) (*ListOverlaysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListOverlaysResponse)
+	err := c.cc.Invoke(ctx, Yams_ListOverlays_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yamsClient) GetOverlay(ctx context.Context, in *GetOverlayRequest, opts ...grpc.CallOption) (*Overlay, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Overlay)
+	err := c.cc.Invoke(ctx, Yams_GetOverlay_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yamsClient) CreateOverlay(ctx context.Context, in *CreateOverlayRequest, opts ...grpc.CallOption) (*Overlay, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Overlay)
+	err := c.cc.Invoke(ctx, Yams_CreateOverlay_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yamsClient) UpdateOverlay(ctx context.Context, in *UpdateOverlayRequest, opts ...grpc.CallOption) (*Overlay, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Overlay)
+	err := c.cc.Invoke(ctx, Yams_UpdateOverlay_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yamsClient) DeleteOverlay(ctx context.Context, in *DeleteOverlayRequest, opts ...grpc.CallOption) (*DeleteOverlayResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteOverlayResponse)
+	err := c.cc.Invoke(ctx, Yams_DeleteOverlay_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// YamsServer is the server API for Yams service.
+// All implementations must embed UnimplementedYamsServer
+// for forward compatibility.
+//
+// Yams exposes the simulation and overlay operations of the v1 HTTP API over gRPC, for callers
+// which need to perform large numbers of simulations with low overhead
+type YamsServer interface {
+	// Simulate determines whether a principal is allowed to perform an action on a resource
+	Simulate(context.Context, *SimulateRequest) (*SimulateResponse, error)
+	// SimulateBatch performs a simulation for each request received on the stream, sending back one
+	// response per request in the order the requests were received
+	SimulateBatch(grpc.BidiStreamingServer[SimulateRequest, SimulateBatchResponse]) error
+	// WhichPrincipals determines which principals are allowed to perform an action on a resource
+	WhichPrincipals(context.Context, *WhichPrincipalsRequest) (*WhichResponse, error)
+	// WhichActions determines which actions a principal is allowed to perform on a resource
+	WhichActions(context.Context, *WhichActionsRequest) (*WhichResponse, error)
+	// WhichResources determines which resources a principal is allowed to perform an action on
+	WhichResources(context.Context, *WhichResourcesRequest) (*WhichResponse, error)
+	// ListOverlays returns summaries of all stored overlays, optionally filtered by a search query
+	ListOverlays(context.Context, *ListOverlaysRequest) (*ListOverlaysResponse, error)
+	// GetOverlay retrieves a stored overlay by ID
+	GetOverlay(context.Context, *GetOverlayRequest) (*Overlay, error)
+	// CreateOverlay stores a new overlay
+	CreateOverlay(context.Context, *CreateOverlayRequest) (*Overlay, error)
+	// UpdateOverlay replaces the name and entities of a stored overlay
+	UpdateOverlay(context.Context, *UpdateOverlayRequest) (*Overlay, error)
+	// DeleteOverlay removes a stored overlay by ID
+	DeleteOverlay(context.Context, *DeleteOverlayRequest) (*DeleteOverlayResponse, error)
+	mustEmbedUnimplementedYamsServer()
+}
+
+// UnimplementedYamsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedYamsServer struct{}
+
+func (UnimplementedYamsServer) Simulate(context.Context, *SimulateRequest) (*SimulateResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Simulate not implemented")
+}
+func (UnimplementedYamsServer) SimulateBatch(grpc.BidiStreamingServer[SimulateRequest, SimulateBatchResponse]) error {
+	return status.Error(codes.Unimplemented, "method SimulateBatch not implemented")
+}
+func (UnimplementedYamsServer) WhichPrincipals(context.Context, *WhichPrincipalsRequest) (*WhichResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method WhichPrincipals not implemented")
+}
+func (UnimplementedYamsServer) WhichActions(context.Context, *WhichActionsRequest) (*WhichResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method WhichActions not implemented")
+}
+func (UnimplementedYamsServer) WhichResources(context.Context, *WhichResourcesRequest) (*WhichResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method WhichResources not implemented")
+}
+func (UnimplementedYamsServer) ListOverlays(context.Context, *ListOverlaysRequest) (*ListOverlaysResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListOverlays not implemented")
+}
+func (UnimplementedYamsServer) GetOverlay(context.Context, *GetOverlayRequest) (*Overlay, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetOverlay not implemented")
+}
+func (UnimplementedYamsServer) CreateOverlay(context.Context, *CreateOverlayRequest) (*Overlay, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateOverlay not implemented")
+}
+func (UnimplementedYamsServer) UpdateOverlay(context.Context, *UpdateOverlayRequest) (*Overlay, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateOverlay not implemented")
+}
+func (UnimplementedYamsServer) DeleteOverlay(context.Context, *DeleteOverlayRequest) (*DeleteOverlayResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteOverlay not implemented")
+}
+func (UnimplementedYamsServer) mustEmbedUnimplementedYamsServer() {}
+func (UnimplementedYamsServer) testEmbeddedByValue()              {}
+
+// UnsafeYamsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to YamsServer will
+// result in compilation errors.
+type UnsafeYamsServer interface {
+	mustEmbedUnimplementedYamsServer()
+}
+
+func RegisterYamsServer(s grpc.ServiceRegistrar, srv YamsServer) {
+	// If the following call panics, it indicates UnimplementedYamsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Yams_ServiceDesc, srv)
+}
+
+func _Yams_Simulate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SimulateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).Simulate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_Simulate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).Simulate(ctx, req.(*SimulateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yams_SimulateBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(YamsServer).SimulateBatch(&grpc.GenericServerStream[SimulateRequest, SimulateBatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Yams_SimulateBatchServer = grpc.BidiStreamingServer[SimulateRequest, SimulateBatchResponse]
+
+func _Yams_WhichPrincipals_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WhichPrincipalsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).WhichPrincipals(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_WhichPrincipals_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).WhichPrincipals(ctx, req.(*WhichPrincipalsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yams_WhichActions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WhichActionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).WhichActions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_WhichActions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).WhichActions(ctx, req.(*WhichActionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yams_WhichResources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WhichResourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).WhichResources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_WhichResources_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).WhichResources(ctx, req.(*WhichResourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yams_ListOverlays_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOverlaysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).ListOverlays(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_ListOverlays_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).ListOverlays(ctx, req.(*ListOverlaysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yams_GetOverlay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOverlayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).GetOverlay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_GetOverlay_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).GetOverlay(ctx, req.(*GetOverlayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yams_CreateOverlay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateOverlayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).CreateOverlay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_CreateOverlay_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).CreateOverlay(ctx, req.(*CreateOverlayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yams_UpdateOverlay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateOverlayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).UpdateOverlay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_UpdateOverlay_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).UpdateOverlay(ctx, req.(*UpdateOverlayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Yams_DeleteOverlay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteOverlayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YamsServer).DeleteOverlay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Yams_DeleteOverlay_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YamsServer).DeleteOverlay(ctx, req.(*DeleteOverlayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Yams_ServiceDesc is the grpc.ServiceDesc for Yams service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Yams_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "yams.v1.Yams",
+	HandlerType: (*YamsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Simulate",
+			Handler:    _Yams_Simulate_Handler,
+		},
+		{
+			MethodName: "WhichPrincipals",
+			Handler:    _Yams_WhichPrincipals_Handler,
+		},
+		{
+			MethodName: "WhichActions",
+			Handler:    _Yams_WhichActions_Handler,
+		},
+		{
+			MethodName: "WhichResources",
+			Handler:    _Yams_WhichResources_Handler,
+		},
+		{
+			MethodName: "ListOverlays",
+			Handler:    _Yams_ListOverlays_Handler,
+		},
+		{
+			MethodName: "GetOverlay",
+			Handler:    _Yams_GetOverlay_Handler,
+		},
+		{
+			MethodName: "CreateOverlay",
+			Handler:    _Yams_CreateOverlay_Handler,
+		},
+		{
+			MethodName: "UpdateOverlay",
+			Handler:    _Yams_UpdateOverlay_Handler,
+		},
+		{
+			MethodName: "DeleteOverlay",
+			Handler:    _Yams_DeleteOverlay_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SimulateBatch",
+			Handler:       _Yams_SimulateBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "yams.proto",
+}
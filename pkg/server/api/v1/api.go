@@ -10,6 +10,7 @@ import (
 	"github.com/nsiow/yams/internal/common"
 	"github.com/nsiow/yams/pkg/aws/sar"
 	"github.com/nsiow/yams/pkg/aws/sar/types"
+	"github.com/nsiow/yams/pkg/overlay"
 	"github.com/nsiow/yams/pkg/server/httputil"
 	"github.com/nsiow/yams/pkg/sim"
 )
@@ -21,6 +22,12 @@ import (
 type API struct {
 	Simulator     *sim.Simulator
 	SharedContext map[string]string
+
+	// Cache optionally caches simulation results; nil disables caching
+	Cache *ResultCache
+
+	// Overlays resolves stored overlays referenced by simulations; nil disables overlay references
+	Overlays *overlay.Cache
 }
 
 // -------------------------------------------------------------------------------------------------
@@ -51,17 +58,24 @@ func (api *API) GetResource(w http.ResponseWriter, req *http.Request) {
 // List
 // -------------------------------------------------------------------------------------------------
 
-func (api *API) ListAccounts(w http.ResponseWriter, req *http.Request) {
-	type entry struct {
-		Id   string `json:"id"`
-		Name string `json:"name"`
-	}
+// AccountEntry is the summary of an account returned when listing accounts
+type AccountEntry struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
 
-	var items []entry
+// PolicyEntry is the summary of a managed policy returned when listing policies
+type PolicyEntry struct {
+	Arn  string `json:"arn"`
+	Name string `json:"name"`
+}
+
+func (api *API) ListAccounts(w http.ResponseWriter, req *http.Request) {
+	var items []AccountEntry
 	for a := range api.Simulator.Universe.Accounts() {
-		items = append(items, entry{Id: a.Id, Name: a.Name})
+		items = append(items, AccountEntry{Id: a.Id, Name: a.Name})
 	}
-	slices.SortFunc(items, func(a, b entry) int {
+	slices.SortFunc(items, func(a, b AccountEntry) int {
 		return strings.Compare(a.Id, b.Id)
 	})
 	httputil.WriteJsonResponse(w, req, items)
@@ -72,16 +86,11 @@ func (api *API) ListGroups(w http.ResponseWriter, req *http.Request) {
 }
 
 func (api *API) ListPolicies(w http.ResponseWriter, req *http.Request) {
-	type entry struct {
-		Arn  string `json:"arn"`
-		Name string `json:"name"`
-	}
-
-	var items []entry
+	var items []PolicyEntry
 	for p := range api.Simulator.Universe.Policies() {
-		items = append(items, entry{Arn: p.Arn, Name: p.Name})
+		items = append(items, PolicyEntry{Arn: p.Arn, Name: p.Name})
 	}
-	slices.SortFunc(items, func(a, b entry) int {
+	slices.SortFunc(items, func(a, b PolicyEntry) int {
 		return strings.Compare(a.Arn, b.Arn)
 	})
 	httputil.WriteJsonResponse(w, req, items)
@@ -0,0 +1,206 @@
+package v1
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sync"
+
+	json "github.com/bytedance/sonic"
+)
+
+// -------------------------------------------------------------------------------------------------
+// ResultCache
+// -------------------------------------------------------------------------------------------------
+
+// ResultCache is an in-process LRU cache of simulation results.
+//
+// Entries are only valid for the universe generation in which they were computed; as soon as the
+// universe changes (e.g. due to a source refresh) the entire cache is discarded. A nil cache is
+// valid and caches nothing
+type ResultCache struct {
+	mu         sync.Mutex
+	size       int
+	generation func() uint64
+
+	gen     uint64
+	entries map[string]*list.Element
+	order   *list.List
+
+	hits   uint64
+	misses uint64
+}
+
+// cacheEntry is a single cached result, stored in the LRU list
+type cacheEntry struct {
+	key   string
+	value any
+}
+
+// CacheStats summarizes the usage of a ResultCache
+type CacheStats struct {
+	Size       int    `json:"size"`
+	Capacity   int    `json:"capacity"`
+	Generation uint64 `json:"generation"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+}
+
+// NewResultCache creates a new cache holding up to `size` results, which are invalidated whenever
+// the value returned by `generation` changes
+func NewResultCache(size int, generation func() uint64) *ResultCache {
+	return &ResultCache{
+		size:       size,
+		generation: generation,
+		gen:        generation(),
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get retrieves the cached result for the provided key, if present and still valid
+func (c *ResultCache) Get(key string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sync()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).value, true
+}
+
+// Put saves the result for the provided key, evicting the least-recently-used result if the cache
+// is full. The result is associated with the universe generation observed before it was computed,
+// so that results racing with a universe change are never served afterwards
+func (c *ResultCache) Put(key string, gen uint64, value any) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sync()
+	if gen != c.gen {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Generation returns the current universe generation, to be passed to Put once a result has been
+// computed
+func (c *ResultCache) Generation() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	return c.generation()
+}
+
+// Stats returns a summary of the cache's usage
+func (c *ResultCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sync()
+	return CacheStats{
+		Size:       c.order.Len(),
+		Capacity:   c.size,
+		Generation: c.gen,
+		Hits:       c.hits,
+		Misses:     c.misses,
+	}
+}
+
+// sync discards all cached results if the universe has changed since they were computed; must be
+// called with the lock held
+func (c *ResultCache) sync() {
+	gen := c.generation()
+	if gen == c.gen {
+		return
+	}
+
+	c.gen = gen
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// cacheKey derives a cache key from the kind of simulation and its full input, which includes the
+// principal/action/resource, request context, overlay and any flags affecting the output
+func cacheKey(kind string, input any) (string, error) {
+	// ConfigStd sorts map keys, so that equivalent request contexts produce the same key
+	b, err := json.ConfigStd.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(b)
+	return kind + ":" + hex.EncodeToString(hash[:]), nil
+}
+
+// cached returns the cached result of a simulation if available, otherwise computing and caching it.
+// Also returns whether or not the result came from the cache. Callers always receive a copy made by
+// clone, so that modifying a result can never change what later requests are served
+func cached[T any](
+	c *ResultCache,
+	kind string,
+	input any,
+	compute func() (T, error),
+	clone func(T) T,
+) (T, bool, error) {
+	if c == nil {
+		out, err := compute()
+		return out, false, err
+	}
+
+	key, err := cacheKey(kind, input)
+	if err != nil {
+		out, err := compute()
+		return out, false, err
+	}
+
+	if value, ok := c.Get(key); ok {
+		return clone(value.(T)), true, nil
+	}
+
+	gen := c.Generation()
+	out, err := compute()
+	if err != nil {
+		return out, false, err
+	}
+
+	c.Put(key, gen, out)
+	return clone(out), false, nil
+}
+
+// cloneStrings is the clone function for results which are lists of strings
+func cloneStrings(s []string) []string {
+	return slices.Clone(s)
+}
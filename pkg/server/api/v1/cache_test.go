@@ -0,0 +1,225 @@
+package v1
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nsiow/yams/pkg/entities"
+)
+
+func TestResultCache_GetPut(t *testing.T) {
+	uv := entities.NewUniverse()
+	c := NewResultCache(2, uv.Generation)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+
+	c.Put("a", c.Generation(), 1)
+	c.Put("b", c.Generation(), 2)
+
+	got, ok := c.Get("a")
+	if !ok || got.(int) != 1 {
+		t.Fatalf("expected hit for 'a' with value 1, got %v (ok=%v)", got, ok)
+	}
+
+	// 'b' is now the least recently used entry, so should be evicted
+	c.Put("c", c.Generation(), 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected 'b' to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected 'a' to survive eviction")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("expected 'c' to be present")
+	}
+
+	// overwriting an existing key should not grow the cache
+	c.Put("c", c.Generation(), 4)
+	got, _ = c.Get("c")
+	if got.(int) != 4 {
+		t.Errorf("expected updated value 4, got %v", got)
+	}
+
+	stats := c.Stats()
+	if stats.Size != 2 || stats.Capacity != 2 {
+		t.Errorf("unexpected size/capacity: %+v", stats)
+	}
+	if stats.Hits != 4 || stats.Misses != 2 {
+		t.Errorf("unexpected hits/misses: %+v", stats)
+	}
+}
+
+func TestResultCache_Invalidation(t *testing.T) {
+	uv := entities.NewUniverse()
+	c := NewResultCache(10, uv.Generation)
+
+	c.Put("a", c.Generation(), 1)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected hit before universe change")
+	}
+
+	uv.PutAccount(entities.Account{Id: "123456789012"})
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected miss after universe change")
+	}
+	if c.Stats().Size != 0 {
+		t.Errorf("expected cache to be emptied after universe change")
+	}
+
+	// results computed against an older generation must not be stored
+	old := c.Generation()
+	uv.RemoveAccount("123456789012")
+	c.Put("b", old, 2)
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected result from previous generation to be discarded")
+	}
+}
+
+func TestResultCache_Nil(t *testing.T) {
+	var c *ResultCache
+
+	c.Put("a", c.Generation(), 1)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected nil cache to never hit")
+	}
+	if c.Stats() != (CacheStats{}) {
+		t.Error("expected empty stats for nil cache")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	a := SimInput{
+		Principal: "p",
+		Action:    "a",
+		Context:   map[string]string{"x": "1", "y": "2", "z": "3"},
+	}
+	b := SimInput{
+		Principal: "p",
+		Action:    "a",
+		Context:   map[string]string{"z": "3", "y": "2", "x": "1"},
+	}
+
+	keyA, err := cacheKey("sim", a)
+	if err != nil {
+		t.Fatalf("cacheKey() error = %v", err)
+	}
+	keyB, _ := cacheKey("sim", b)
+	if keyA != keyB {
+		t.Error("expected equivalent inputs to produce the same key")
+	}
+
+	keyKind, _ := cacheKey("whichActions", a)
+	if keyA == keyKind {
+		t.Error("expected different kinds to produce different keys")
+	}
+
+	b.Overlay.Principals = []entities.Principal{{Arn: "arn:aws:iam::123456789012:role/r"}}
+	keyOverlay, _ := cacheKey("sim", b)
+	if keyA == keyOverlay {
+		t.Error("expected different overlays to produce different keys")
+	}
+
+	b.Overlay = Overlay{}
+	b.Explain = true
+	keyExplain, _ := cacheKey("sim", b)
+	if keyA == keyExplain {
+		t.Error("expected different flags to produce different keys")
+	}
+}
+
+// identity is a clone function for immutable results
+func identity(s string) string {
+	return s
+}
+
+func TestCached(t *testing.T) {
+	uv := entities.NewUniverse()
+	c := NewResultCache(10, uv.Generation)
+
+	calls := 0
+	compute := func() (string, error) {
+		calls++
+		return "result", nil
+	}
+
+	for i, wantHit := range []bool{false, true, true} {
+		out, hit, err := cached(c, "test", "input", compute, identity)
+		if err != nil || out != "result" {
+			t.Fatalf("cached() = %v, %v", out, err)
+		}
+		if hit != wantHit {
+			t.Errorf("call %d: hit = %v, want %v", i, hit, wantHit)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 computation, got %d", calls)
+	}
+
+	// errors are not cached
+	failing := func() (string, error) {
+		calls++
+		return "", errors.New("boom")
+	}
+	for range 2 {
+		if _, _, err := cached(c, "test", "failing", failing, identity); err == nil {
+			t.Fatal("expected error from cached()")
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected errors to be recomputed, got %d computations", calls)
+	}
+
+	// nil caches always compute
+	calls = 0
+	for range 2 {
+		_, hit, _ := cached(nil, "test", "input", compute, identity)
+		if hit {
+			t.Error("expected nil cache to never hit")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 computations with nil cache, got %d", calls)
+	}
+}
+
+func TestCached_Copies(t *testing.T) {
+	uv := entities.NewUniverse()
+	c := NewResultCache(10, uv.Generation)
+
+	compute := func() ([]string, error) {
+		return []string{"a", "b"}, nil
+	}
+
+	// results returned on a miss or a hit may be modified without affecting later requests
+	for i, wantHit := range []bool{false, true, true} {
+		out, hit, err := cached(c, "test", "input", compute, cloneStrings)
+		if err != nil {
+			t.Fatalf("cached() error = %v", err)
+		}
+		if hit != wantHit {
+			t.Errorf("call %d: hit = %v, want %v", i, hit, wantHit)
+		}
+		if !slices.Equal(out, []string{"a", "b"}) {
+			t.Fatalf("call %d: cached() = %v, want [a b]", i, out)
+		}
+		out[0] = "modified"
+	}
+
+	sim := func() (SimOutput, error) {
+		return SimOutput{Result: "ALLOW", Explain: []string{"x"}, Trace: []string{"y"}}, nil
+	}
+	for i := range 2 {
+		out, _, err := cached(c, "sim", "input", sim, SimOutput.clone)
+		if err != nil {
+			t.Fatalf("cached() error = %v", err)
+		}
+		if out.Explain[0] != "x" || out.Trace[0] != "y" {
+			t.Fatalf("call %d: cached() = %+v, want unmodified output", i, out)
+		}
+		out.Explain[0] = "modified"
+		out.Trace[0] = "modified"
+	}
+}
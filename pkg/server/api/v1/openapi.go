@@ -0,0 +1,490 @@
+package v1
+
+import (
+	"encoding"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsiow/yams/pkg/aws/sar/types"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/server/httputil"
+)
+
+// -------------------------------------------------------------------------------------------------
+// Routes
+// -------------------------------------------------------------------------------------------------
+
+// Route describes a single endpoint of the v1 API. The route table is the source of truth for the
+// OpenAPI specification, and is checked against the routes actually registered by the server
+type Route struct {
+	// Method is the HTTP method of the route, e.g. GET
+	Method string
+
+	// Pattern is the path of the route as registered with http.ServeMux, e.g.
+	// /api/v1/principals/{key...}
+	Pattern string
+
+	// Name is a unique identifier for the route, used as the OpenAPI operationId
+	Name string
+
+	// Summary is a short human-readable description of the route
+	Summary string
+
+	// Query lists the supported query parameters, mapped to their descriptions
+	Query map[string]string
+
+	// Input is a zero value of the request body type, or nil if the route takes no body
+	Input any
+
+	// Output is a zero value of the response body type, or nil if the route returns no body
+	Output any
+
+	// Status is the status code returned on success; defaults to 200
+	Status int
+}
+
+// String returns the route in the form registered with http.ServeMux, e.g. "GET /api/v1/status"
+func (r Route) String() string {
+	return r.Method + " " + r.Pattern
+}
+
+// Routes lists every endpoint of the v1 API
+var Routes = []Route{
+	// administration
+	{Method: "GET", Pattern: "/api/v1/healthcheck", Name: "Healthcheck",
+		Summary: "Report whether the server is healthy; responds with 503 if any source is stale",
+		Output:  HealthcheckOutput{}},
+	{Method: "GET", Pattern: "/api/v1/status", Name: "Status",
+		Summary: "Describe the contents of the universe and the state of each source",
+		Output:  StatusOutput{}},
+	{Method: "POST", Pattern: "/api/v1/sources/{name}/refresh", Name: "RefreshSource",
+		Summary: "Immediately reload the named source",
+		Output:  SourceStatus{}},
+	{Method: "GET", Pattern: "/api/v1/openapi.json", Name: "OpenAPI",
+		Summary: "Retrieve the OpenAPI specification of this API",
+		Output:  map[string]any{}},
+
+	// accounts
+	{Method: "GET", Pattern: "/api/v1/accounts", Name: "ListAccounts",
+		Summary: "List all accounts",
+		Output:  []AccountEntry{}},
+	{Method: "GET", Pattern: "/api/v1/accounts/{key...}", Name: "GetAccount",
+		Summary: "Retrieve an account by ID; append /freeze for its fully-resolved representation",
+		Output:  entities.Account{}},
+	{Method: "GET", Pattern: "/api/v1/accounts/search/{search...}", Name: "SearchAccounts",
+		Summary: "Search for accounts by ID",
+		Output:  []string{}},
+
+	// groups
+	{Method: "GET", Pattern: "/api/v1/groups", Name: "ListGroups",
+		Summary: "List the ARNs of all groups",
+		Output:  []string{}},
+	{Method: "GET", Pattern: "/api/v1/groups/{key...}", Name: "GetGroup",
+		Summary: "Retrieve a group by ARN; append /freeze for its fully-resolved representation",
+		Output:  entities.Group{}},
+	{Method: "GET", Pattern: "/api/v1/groups/search/{search...}", Name: "SearchGroups",
+		Summary: "Search for groups by ARN",
+		Output:  []string{}},
+
+	// policies
+	{Method: "GET", Pattern: "/api/v1/policies", Name: "ListPolicies",
+		Summary: "List all managed policies",
+		Output:  []PolicyEntry{}},
+	{Method: "GET", Pattern: "/api/v1/policies/{key...}", Name: "GetPolicy",
+		Summary: "Retrieve a managed policy by ARN",
+		Output:  entities.ManagedPolicy{}},
+	{Method: "GET", Pattern: "/api/v1/policies/search/{search...}", Name: "SearchPolicies",
+		Summary: "Search for managed policies by ARN",
+		Output:  []string{}},
+
+	// principals
+	{Method: "GET", Pattern: "/api/v1/principals", Name: "ListPrincipals",
+		Summary: "List the ARNs of all principals",
+		Output:  []string{}},
+	{Method: "GET", Pattern: "/api/v1/principals/{key...}", Name: "GetPrincipal",
+		Summary: "Retrieve a principal by ARN; append /freeze for its fully-resolved representation",
+		Output:  entities.Principal{}},
+	{Method: "GET", Pattern: "/api/v1/principals/search/{search...}", Name: "SearchPrincipals",
+		Summary: "Search for principals by ARN",
+		Output:  []string{}},
+
+	// resources
+	{Method: "GET", Pattern: "/api/v1/resources", Name: "ListResources",
+		Summary: "List the ARNs of all resources",
+		Output:  []string{}},
+	{Method: "GET", Pattern: "/api/v1/resources/{key...}", Name: "GetResource",
+		Summary: "Retrieve a resource by ARN; append /freeze for its fully-resolved representation",
+		Output:  entities.Resource{}},
+	{Method: "GET", Pattern: "/api/v1/resources/search/{search...}", Name: "SearchResources",
+		Summary: "Search for resources by ARN",
+		Query:   map[string]string{"action": "only return resources which can be targeted by this action"},
+		Output:  []string{}},
+
+	// actions
+	{Method: "GET", Pattern: "/api/v1/actions", Name: "ListActions",
+		Summary: "List all known actions",
+		Output:  []string{}},
+	{Method: "GET", Pattern: "/api/v1/actions/{key...}", Name: "GetAction",
+		Summary: "Retrieve the Service Authorization Reference definition of an action",
+		Output:  types.Action{}},
+	{Method: "GET", Pattern: "/api/v1/actions/search/{search...}", Name: "SearchActions",
+		Summary: "Search for actions by name",
+		Output:  []string{}},
+
+	// simulation
+	{Method: "POST", Pattern: "/api/v1/sim", Name: "SimRun",
+		Summary: "Simulate a single request",
+		Input:   SimInput{},
+		Output:  SimOutput{}},
+	{Method: "POST", Pattern: "/api/v1/sim/batch", Name: "SimBatch",
+		Summary: "Simulate multiple requests at once",
+		Input:   SimBatchInput{},
+		Output:  SimBatchOutput{}},
+	{Method: "POST", Pattern: "/api/v1/sim/whichPrincipals", Name: "WhichPrincipals",
+		Summary: "Determine which principals may perform an action",
+		Input:   WhichPrincipalsInput{},
+		Output:  WhichPrincipalsOutput{}},
+	{Method: "POST", Pattern: "/api/v1/sim/whichActions", Name: "WhichActions",
+		Summary: "Determine which actions a principal may perform",
+		Input:   WhichActionsInput{},
+		Output:  WhichActionsOutput{}},
+	{Method: "POST", Pattern: "/api/v1/sim/whichResources", Name: "WhichResources",
+		Summary: "Determine which resources a principal may perform an action against",
+		Input:   WhichResourcesInput{},
+		Output:  WhichResourcesOutput{}},
+
+	// utils
+	{Method: "GET", Pattern: "/api/v1/utils/resources/accounts", Name: "UtilResourceAccounts",
+		Summary: "Map resources with global namespaces to their account IDs",
+		Output:  map[string]string{}},
+	{Method: "GET", Pattern: "/api/v1/utils/actions/resourceless", Name: "UtilResourcelessActions",
+		Summary: "List actions which do not target any resource",
+		Output:  []string{}},
+	{Method: "GET", Pattern: "/api/v1/utils/actions/accesslevels", Name: "UtilActionAccessLevels",
+		Summary: "Map actions to their access levels",
+		Output:  map[string]string{}},
+	{Method: "GET", Pattern: "/api/v1/utils/actions/targeting", Name: "UtilActionTargeting",
+		Summary: "Describe which resources each action can target",
+		Output:  []ActionTargeting{}},
+	{Method: "GET", Pattern: "/api/v1/utils/context", Name: "UtilSharedContext",
+		Summary: "Retrieve the server's shared request context",
+		Output:  map[string]string{}},
+
+	// overlays
+	{Method: "GET", Pattern: "/api/v1/overlays", Name: "ListOverlays",
+		Summary: "List summaries of all overlays",
+		Query:   map[string]string{"q": "only return overlays whose name contains this value"},
+		Output:  []entities.OverlaySummary{}},
+	{Method: "POST", Pattern: "/api/v1/overlays", Name: "CreateOverlay",
+		Summary: "Create a new overlay",
+		Query:   map[string]string{"dryRun": dryRunDescription},
+		Input:   CreateOverlayInput{},
+		Output:  OverlayOutput{},
+		Status:  http.StatusCreated},
+	{Method: "GET", Pattern: "/api/v1/overlays/{id}", Name: "GetOverlay",
+		Summary: "Retrieve an overlay by ID",
+		Output:  entities.OverlayData{}},
+	{Method: "PUT", Pattern: "/api/v1/overlays/{id}", Name: "UpdateOverlay",
+		Summary: "Replace the contents of an overlay",
+		Query:   map[string]string{"dryRun": dryRunDescription},
+		Input:   UpdateOverlayInput{},
+		Output:  OverlayOutput{}},
+	{Method: "DELETE", Pattern: "/api/v1/overlays/{id}", Name: "DeleteOverlay",
+		Summary: "Delete an overlay",
+		Status:  http.StatusNoContent},
+	{Method: "GET", Pattern: "/api/v1/overlays/{id}/revisions", Name: "ListOverlayRevisions",
+		Summary: "List the revisions of an overlay, oldest first",
+		Output:  []entities.OverlayRevision{}},
+	{Method: "GET", Pattern: "/api/v1/overlays/{id}/revisions/{revision}", Name: "GetOverlayRevision",
+		Summary: "Retrieve a specific revision of an overlay",
+		Output:  entities.OverlayData{}},
+	{Method: "GET", Pattern: "/api/v1/overlays/{id}/diff", Name: "DiffOverlayRevisions",
+		Summary: "Describe the changes between two revisions of an overlay",
+		Query: map[string]string{
+			"from": "the older revision; defaults to the revision before 'to'",
+			"to":   "the newer revision; defaults to the current revision",
+		},
+		Output: entities.OverlayDiff{}},
+	{Method: "GET", Pattern: "/api/v1/overlays/{id}/export", Name: "ExportOverlay",
+		Summary: "Render an overlay as Terraform, CloudFormation or AWS Config items",
+		Query: map[string]string{
+			"format":   "one of 'terraform', 'cloudformation' or 'awsconfig'",
+			"revision": "the revision to export; defaults to the current revision",
+		}},
+	{Method: "POST", Pattern: "/api/v1/overlays/{id}/rollback", Name: "RollbackOverlay",
+		Summary: "Restore an earlier revision of an overlay as a new revision",
+		Input:   RollbackOverlayInput{},
+		Output:  entities.OverlayData{}},
+}
+
+// dryRunDescription describes the `dryRun` parameter of routes which write overlays
+const dryRunDescription = "if true, lint the overlay and return it with status 200 without storing it"
+
+// ErrorOutput is the body of all error responses
+type ErrorOutput struct {
+	Error string `json:"error"`
+}
+
+// -------------------------------------------------------------------------------------------------
+// Specification
+// -------------------------------------------------------------------------------------------------
+
+// openAPISpec lazily builds the specification, which never changes for the life of the process
+var openAPISpec = sync.OnceValue(func() map[string]any {
+	return OpenAPI(Routes)
+})
+
+// ServeOpenAPI serves the OpenAPI specification of the v1 API
+// GET /api/v1/openapi.json
+func ServeOpenAPI(w http.ResponseWriter, req *http.Request) {
+	httputil.WriteJsonResponse(w, req, openAPISpec())
+}
+
+// OpenAPI generates an OpenAPI 3 specification describing the provided routes. Request and response
+// schemas are derived via reflection from the same types used by the handlers, so that the
+// specification cannot drift from the implementation
+func OpenAPI(routes []Route) map[string]any {
+	gen := schemaGenerator{components: map[string]any{}}
+	errorSchema := gen.schema(reflect.TypeFor[ErrorOutput]())
+
+	paths := map[string]any{}
+	for _, route := range routes {
+		op := map[string]any{
+			"operationId": route.Name,
+			"summary":     route.Summary,
+		}
+
+		var params []any
+		for _, name := range pathParams(route.Pattern) {
+			params = append(params, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		for name, desc := range route.Query {
+			params = append(params, map[string]any{
+				"name":        name,
+				"in":          "query",
+				"description": desc,
+				"schema":      map[string]any{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if route.Input != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": gen.schema(reflect.TypeOf(route.Input)),
+					},
+				},
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		if route.Output != nil {
+			success["content"] = map[string]any{
+				"application/json": map[string]any{
+					"schema": gen.schema(reflect.TypeOf(route.Output)),
+				},
+			}
+		}
+		op["responses"] = map[string]any{
+			strconv.Itoa(status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": errorSchema},
+				},
+			},
+		}
+
+		template := openAPIPath(route.Pattern)
+		item, ok := paths[template].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[template] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "yams",
+			"version": "v1",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": gen.components,
+		},
+	}
+}
+
+// patternParam matches path wildcards in http.ServeMux patterns, e.g. {key} or {key...}
+var patternParam = regexp.MustCompile(`\{([A-Za-z0-9_]+)(\.\.\.)?\}`)
+
+// pathParams returns the names of the path wildcards in the provided pattern
+func pathParams(pattern string) []string {
+	var names []string
+	for _, match := range patternParam.FindAllStringSubmatch(pattern, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// openAPIPath converts an http.ServeMux pattern into an OpenAPI path template
+func openAPIPath(pattern string) string {
+	return patternParam.ReplaceAllString(pattern, "{$1}")
+}
+
+// -------------------------------------------------------------------------------------------------
+// Schema generation
+// -------------------------------------------------------------------------------------------------
+
+var (
+	timeType        = reflect.TypeFor[time.Time]()
+	marshalerType   = reflect.TypeFor[interface{ MarshalJSON() ([]byte, error) }]()
+	unmarshalerType = reflect.TypeFor[interface{ UnmarshalJSON([]byte) error }]()
+	textType        = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// schemaGenerator derives JSON schemas from Go types, collecting named structs as components
+type schemaGenerator struct {
+	components map[string]any
+}
+
+// schema returns the schema of the provided type, following the same rules as JSON encoding
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(textType) || reflect.PointerTo(t).Implements(textType):
+		return map[string]any{"type": "string"}
+	case customJSON(t) && !isScalar(t.Kind()):
+		// types with custom encodings (such as policy values, which may be strings or lists) cannot
+		// be described by their Go structure
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+
+		name := componentName(t)
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = nil // reserve the name, in case the type is recursive
+			g.components[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema returns the schema of a struct type's JSON object representation
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	g.addFields(t, properties)
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// addFields adds the JSON-visible fields of the struct type to the provided properties, flattening
+// embedded structs
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]any) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(ft, properties)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+	}
+}
+
+// customJSON returns whether the type overrides its JSON encoding
+func customJSON(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return t.Implements(marshalerType) || pt.Implements(marshalerType) ||
+		t.Implements(unmarshalerType) || pt.Implements(unmarshalerType)
+}
+
+// isScalar returns whether values of the kind are encoded as JSON scalars
+func isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// invalidComponentChars matches characters which may not appear in OpenAPI component names
+var invalidComponentChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// componentName returns a unique name for the named type, qualified by its package
+func componentName(t reflect.Type) string {
+	return invalidComponentChars.ReplaceAllString(path.Base(t.PkgPath())+"."+t.Name(), "_")
+}
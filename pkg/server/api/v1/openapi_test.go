@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+func TestOpenAPI_Routes(t *testing.T) {
+	spec := OpenAPI(Routes)
+	paths := spec["paths"].(map[string]any)
+
+	names := map[string]bool{}
+	for _, route := range Routes {
+		if names[route.Name] {
+			t.Errorf("duplicate route name: %s", route.Name)
+		}
+		names[route.Name] = true
+
+		item, ok := paths[openAPIPath(route.Pattern)].(map[string]any)
+		if !ok {
+			t.Errorf("missing path for route %s", route)
+			continue
+		}
+		op, ok := item[map[string]string{
+			"GET":    "get",
+			"POST":   "post",
+			"PUT":    "put",
+			"DELETE": "delete",
+		}[route.Method]].(map[string]any)
+		if !ok {
+			t.Errorf("missing operation for route %s", route)
+			continue
+		}
+		if op["operationId"] != route.Name {
+			t.Errorf("route %s has operationId %v, want %s", route, op["operationId"], route.Name)
+		}
+
+		_, hasBody := op["requestBody"]
+		if hasBody != (route.Input != nil) {
+			t.Errorf("route %s requestBody presence = %v, want %v", route, hasBody, route.Input != nil)
+		}
+	}
+}
+
+func TestOpenAPI_Params(t *testing.T) {
+	spec := OpenAPI([]Route{
+		{
+			Method:  "GET",
+			Pattern: "/api/v1/things/{id}/parts/{key...}",
+			Name:    "GetPart",
+			Query:   map[string]string{"q": "filter"},
+			Output:  []string{},
+		},
+	})
+
+	paths := spec["paths"].(map[string]any)
+	item, ok := paths["/api/v1/things/{id}/parts/{key}"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected wildcards to be converted to path templates, got %v", paths)
+	}
+
+	params := item["get"].(map[string]any)["parameters"].([]any)
+	var got []string
+	for _, p := range params {
+		param := p.(map[string]any)
+		got = append(got, param["in"].(string)+":"+param["name"].(string))
+	}
+
+	want := []string{"path:id", "path:key", "query:q"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parameters = %v, want %v", got, want)
+	}
+}
+
+func TestSchemaGenerator(t *testing.T) {
+	type inner struct {
+		Value string `json:"value"`
+	}
+	type Embedded struct {
+		Flattened int `json:"flattened"`
+	}
+	type sample struct {
+		Embedded
+		Name     string `json:"name"`
+		Untagged bool
+		Skipped  string            `json:"-"`
+		Renamed  float64           `json:",omitzero"`
+		List     []inner           `json:"list"`
+		Map      map[string]int    `json:"map"`
+		Bytes    []byte            `json:"bytes"`
+		Ptr      *SimOutput        `json:"ptr"`
+		Custom   policy.Value      `json:"custom"`
+		Any      any               `json:"any"`
+		Labels   map[string]string `json:"labels,omitempty"`
+		private  string
+	}
+
+	gen := schemaGenerator{components: map[string]any{}}
+	got := gen.schema(reflect.TypeFor[sample]())
+
+	if got["$ref"] != "#/components/schemas/v1.sample" {
+		t.Fatalf("expected named struct to be a component reference, got %v", got)
+	}
+
+	props := gen.components["v1.sample"].(map[string]any)["properties"].(map[string]any)
+	want := map[string]any{
+		"flattened": map[string]any{"type": "integer"},
+		"name":      map[string]any{"type": "string"},
+		"Untagged":  map[string]any{"type": "boolean"},
+		"Renamed":   map[string]any{"type": "number"},
+		"list": map[string]any{
+			"type":  "array",
+			"items": map[string]any{"$ref": "#/components/schemas/v1.inner"},
+		},
+		"map":    map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "integer"}},
+		"bytes":  map[string]any{"type": "string", "format": "byte"},
+		"ptr":    map[string]any{"$ref": "#/components/schemas/v1.SimOutput"},
+		"custom": map[string]any{},
+		"any":    map[string]any{},
+		"labels": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+	}
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("unexpected properties:\n got: %v\nwant: %v", props, want)
+	}
+
+	if _, ok := gen.components["v1.SimOutput"]; !ok {
+		t.Error("expected referenced struct to be added as a component")
+	}
+}
+
+func TestSchemaGenerator_MatchesHandlerTypes(t *testing.T) {
+	spec := OpenAPI(Routes)
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+
+	// every JSON field of the handler schemas must appear in the specification
+	for _, typ := range []reflect.Type{
+		reflect.TypeFor[SimInput](),
+		reflect.TypeFor[SimOutput](),
+		reflect.TypeFor[Overlay](),
+		reflect.TypeFor[StatusOutput](),
+		reflect.TypeFor[SourceStatus](),
+	} {
+		schema, ok := schemas[componentName(typ)].(map[string]any)
+		if !ok {
+			t.Errorf("missing component for %s", typ)
+			continue
+		}
+
+		encoded, err := json.Marshal(reflect.New(typ).Interface())
+		if err != nil {
+			t.Fatalf("failed to encode %s: %v", typ, err)
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(encoded, &fields); err != nil {
+			t.Fatalf("failed to decode %s: %v", typ, err)
+		}
+
+		props := schema["properties"].(map[string]any)
+		for field := range fields {
+			if _, ok := props[field]; !ok {
+				t.Errorf("%s field %q is missing from the specification", typ, field)
+			}
+		}
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/openapi.json", nil)
+	ServeOpenAPI(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("ServeOpenAPI() status = %d", w.Code)
+	}
+
+	var spec map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("ServeOpenAPI() invalid JSON: %v", err)
+	}
+	if spec["openapi"] != "3.0.3" {
+		t.Errorf("unexpected openapi version: %v", spec["openapi"])
+	}
+	if _, ok := spec["paths"].(map[string]any)["/api/v1/sim"]; !ok {
+		t.Error("expected /api/v1/sim in specification")
+	}
+}
@@ -1,6 +1,16 @@
 package v1
 
-import "github.com/nsiow/yams/pkg/entities"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/overlay"
+	"github.com/nsiow/yams/pkg/server/httputil"
+)
 
 type Overlay struct {
 	Accounts   []entities.Account       `json:"accounts"`
@@ -8,6 +18,8 @@ type Overlay struct {
 	Policies   []entities.ManagedPolicy `json:"policies"`
 	Principals []entities.Principal     `json:"principals"`
 	Resources  []entities.Resource      `json:"resources"`
+	Tombstones []entities.Arn           `json:"tombstones,omitzero"`
+	Patches    []entities.Patch         `json:"patches,omitzero"`
 }
 
 func (s *Overlay) Universe() *entities.Universe {
@@ -17,5 +29,103 @@ func (s *Overlay) Universe() *entities.Universe {
 		WithPolicies(s.Policies...).
 		WithPrincipals(s.Principals...).
 		WithResources(s.Resources...).
+		WithTombstones(s.Tombstones...).
+		WithPatches(s.Patches...).
 		Build()
 }
+
+// OverlayRefs references overlays saved in the server's overlay store, which are stacked (in order)
+// beneath any inline overlays provided alongside them
+type OverlayRefs struct {
+	OverlayID  string   `json:"overlayId,omitzero"`
+	OverlayIDs []string `json:"overlayIds,omitzero"`
+}
+
+// IDs returns the referenced overlay IDs, in stacking order
+func (r *OverlayRefs) IDs() []string {
+	if r.OverlayID != "" {
+		return []string{r.OverlayID}
+	}
+	return r.OverlayIDs
+}
+
+// Validate checks that the overlay references are well-formed
+func (r *OverlayRefs) Validate() error {
+	if r.OverlayID != "" && len(r.OverlayIDs) > 0 {
+		return fmt.Errorf("only one of 'overlayId' and 'overlayIds' may be provided")
+	}
+	if slices.Contains(r.OverlayIDs, "") {
+		return fmt.Errorf("invalid empty ID in 'overlayIds'")
+	}
+
+	return nil
+}
+
+// resolvedOverlay is the ordered set of overlay layers for a simulation
+type resolvedOverlay struct {
+	// layers are ordered by increasing precedence, as expected by [sim.Options]
+	layers []*entities.Universe
+
+	// version identifies the contents of the referenced overlays, for use in cache keys
+	version string
+}
+
+// resolveOverlay builds the overlay layers for a simulation. In order of increasing precedence,
+// these are: the referenced stored overlays, the inline `overlays` and finally the inline `overlay`
+func (api *API) resolveOverlay(
+	ctx context.Context, refs OverlayRefs, layers []Overlay, inline Overlay) (resolvedOverlay, error) {
+
+	var out resolvedOverlay
+	if ids := refs.IDs(); len(ids) > 0 {
+		if api.Overlays == nil {
+			return out, fmt.Errorf("overlay references are not supported by this server")
+		}
+
+		stored, version, err := api.Overlays.Universes(ctx, ids)
+		if err != nil {
+			return out, err
+		}
+		out.layers = stored
+		out.version = version
+	}
+
+	for _, layer := range layers {
+		out.layers = append(out.layers, layer.Universe())
+	}
+	if !inline.IsEmpty() {
+		out.layers = append(out.layers, inline.Universe())
+	}
+
+	return out, nil
+}
+
+// IsEmpty returns true if the overlay contains no entities, tombstones or patches
+func (s *Overlay) IsEmpty() bool {
+	return len(s.Accounts) == 0 &&
+		len(s.Groups) == 0 &&
+		len(s.Policies) == 0 &&
+		len(s.Principals) == 0 &&
+		len(s.Resources) == 0 &&
+		len(s.Tombstones) == 0 &&
+		len(s.Patches) == 0
+}
+
+// key pairs a simulation input with the version of the stored overlays it references, so that
+// cached results are not served once those overlays change
+func (o resolvedOverlay) key(input any) any {
+	return struct {
+		Input    any    `json:"input"`
+		Overlays string `json:"overlays,omitzero"`
+	}{input, o.version}
+}
+
+// simulationError reports an error encountered while running a simulation, distinguishing missing
+// overlay references from failures of the simulation itself
+func simulationError(w http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, overlay.ErrNotFound) {
+		httputil.Error(w, req, http.StatusNotFound, err)
+		return
+	}
+
+	httputil.ServerError(w, req, fmt.Errorf("simulation error: %v", err))
+}
@@ -1,8 +1,15 @@
 package v1
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	json "github.com/bytedance/sonic"
 	"github.com/nsiow/yams/pkg/entities"
@@ -13,26 +20,75 @@ import (
 // OverlayAPI handles overlay storage operations.
 type OverlayAPI struct {
 	Store overlay.Store
+
+	// Base is the universe against which references in overlays are resolved when linting them;
+	// if nil, only references within the overlay itself and to AWS managed policies resolve
+	Base *entities.Universe
+}
+
+// OverlayOutput is the response body for creating or updating an overlay, including any warnings
+// found while linting it. DryRun is set if the overlay was only linted and not stored
+type OverlayOutput struct {
+	entities.OverlayData
+
+	Warnings []overlay.Issue `json:"warnings,omitempty"`
+	DryRun   bool            `json:"dryRun,omitempty"`
+}
+
+// OverlayLintError is the response body for an overlay rejected due to errors found while linting
+// it, listing every issue found
+type OverlayLintError struct {
+	ErrorOutput
+
+	Issues []overlay.Issue `json:"issues"`
 }
 
 // CreateOverlayInput is the request body for creating an overlay.
 type CreateOverlayInput struct {
 	Name       string                   `json:"name"`
+	Author     string                   `json:"author,omitempty"`
+	Message    string                   `json:"message,omitempty"`
 	Accounts   []entities.Account       `json:"accounts,omitempty"`
 	Groups     []entities.Group         `json:"groups,omitempty"`
 	Policies   []entities.ManagedPolicy `json:"policies,omitempty"`
 	Principals []entities.Principal     `json:"principals,omitempty"`
 	Resources  []entities.Resource      `json:"resources,omitempty"`
+	Tombstones []entities.Arn           `json:"tombstones,omitempty"`
+	Patches    []entities.Patch         `json:"patches,omitempty"`
+
+	ExpiryInput
 }
 
 // UpdateOverlayInput is the request body for updating an overlay.
+//
+// If Revision is provided, the update is rejected with 409 Conflict unless it is the current
+// revision of the overlay, so that concurrent editors cannot overwrite each other's changes.
+//
+// If no expiry is provided, the overlay keeps its current expiry; a `ttl` of "0" removes it.
 type UpdateOverlayInput struct {
 	Name       string                   `json:"name,omitempty"`
+	Revision   int                      `json:"revision,omitempty"`
+	Author     string                   `json:"author,omitempty"`
+	Message    string                   `json:"message,omitempty"`
 	Accounts   []entities.Account       `json:"accounts,omitempty"`
 	Groups     []entities.Group         `json:"groups,omitempty"`
 	Policies   []entities.ManagedPolicy `json:"policies,omitempty"`
 	Principals []entities.Principal     `json:"principals,omitempty"`
 	Resources  []entities.Resource      `json:"resources,omitempty"`
+	Tombstones []entities.Arn           `json:"tombstones,omitempty"`
+	Patches    []entities.Patch         `json:"patches,omitempty"`
+
+	ExpiryInput
+}
+
+// ExpiryInput describes when an overlay expires, after which it is deleted from the store. At most
+// one of its fields may be provided.
+type ExpiryInput struct {
+	// ExpiresAt is the time at which the overlay expires
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// TTL is how long after the request the overlay expires, as a duration such as "72h"
+	TTL string `json:"ttl,omitempty"`
 }
 
 // ListOverlays returns summaries of all overlays, optionally filtered by query.
@@ -71,9 +127,15 @@ func (api *OverlayAPI) GetOverlay(w http.ResponseWriter, req *http.Request) {
 	httputil.WriteJsonResponse(w, req, o.ToData())
 }
 
-// CreateOverlay creates a new overlay.
-// POST /api/v1/overlays
+// CreateOverlay creates a new overlay, after linting it; see [overlay.Lint].
+// POST /api/v1/overlays?dryRun=true
 func (api *OverlayAPI) CreateOverlay(w http.ResponseWriter, req *http.Request) {
+	dryRun, err := parseDryRun(req)
+	if err != nil {
+		httputil.ClientError(w, req, err)
+		return
+	}
+
 	var input CreateOverlayInput
 	decoder := json.ConfigDefault.NewDecoder(req.Body)
 	if err := decoder.Decode(&input); err != nil {
@@ -81,28 +143,25 @@ func (api *OverlayAPI) CreateOverlay(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if input.Name == "" {
-		httputil.ClientError(w, req, fmt.Errorf("missing required field 'name'"))
+	if err := input.Validate(); err != nil {
+		httputil.ClientError(w, req, err)
 		return
 	}
 
-	o := entities.NewOverlay(input.Name)
+	o := input.Overlay()
 
-	// Populate entities
-	for _, a := range input.Accounts {
-		o.Universe.PutAccount(a)
-	}
-	for _, g := range input.Groups {
-		o.Universe.PutGroup(g)
-	}
-	for _, p := range input.Policies {
-		o.Universe.PutPolicy(p)
-	}
-	for _, p := range input.Principals {
-		o.Universe.PutPrincipal(p)
+	warnings, ok := api.lint(w, req, o)
+	if !ok {
+		return
 	}
-	for _, r := range input.Resources {
-		o.Universe.PutResource(r)
+	if dryRun {
+		// report the conflict which the create would have failed with
+		if exists, err := api.Store.Exists(req.Context(), o.ID); err != nil || exists {
+			writeStoreError(w, req, o.ID, cmp.Or(err, overlay.ErrAlreadyExists))
+			return
+		}
+		httputil.WriteJsonResponse(w, req, OverlayOutput{o.ToData(), warnings, true})
+		return
 	}
 
 	if err := api.Store.Create(req.Context(), o); err != nil {
@@ -114,12 +173,12 @@ func (api *OverlayAPI) CreateOverlay(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	httputil.WriteJsonResponse(w, req, o.ToData())
+	httputil.WriteJsonResponseWithStatus(w, req, http.StatusCreated,
+		OverlayOutput{o.ToData(), warnings, false})
 }
 
-// UpdateOverlay updates an existing overlay.
-// PUT /api/v1/overlays/{id}
+// UpdateOverlay updates an existing overlay, after linting it; see [overlay.Lint].
+// PUT /api/v1/overlays/{id}?dryRun=true
 func (api *OverlayAPI) UpdateOverlay(w http.ResponseWriter, req *http.Request) {
 	id := req.PathValue("id")
 	if id == "" {
@@ -127,6 +186,12 @@ func (api *OverlayAPI) UpdateOverlay(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	dryRun, err := parseDryRun(req)
+	if err != nil {
+		httputil.ClientError(w, req, err)
+		return
+	}
+
 	// Get existing overlay
 	existing, err := api.Store.Get(req.Context(), id)
 	if err == overlay.ErrNotFound {
@@ -145,35 +210,74 @@ func (api *OverlayAPI) UpdateOverlay(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Update name if provided
-	if input.Name != "" {
-		existing.Name = input.Name
+	if err := input.Validate(); err != nil {
+		httputil.ClientError(w, req, err)
+		return
 	}
 
-	// Replace universe with new entities
-	existing.Universe = entities.NewUniverse()
-	for _, a := range input.Accounts {
-		existing.Universe.PutAccount(a)
-	}
-	for _, g := range input.Groups {
-		existing.Universe.PutGroup(g)
-	}
-	for _, p := range input.Policies {
-		existing.Universe.PutPolicy(p)
+	if input.Revision != 0 && input.Revision != existing.Revision {
+		httputil.Error(w, req, http.StatusConflict, conflictError(id, input.Revision, existing.Revision))
+		return
 	}
-	for _, p := range input.Principals {
-		existing.Universe.PutPrincipal(p)
+
+	input.Apply(existing)
+
+	warnings, ok := api.lint(w, req, existing)
+	if !ok {
+		return
 	}
-	for _, r := range input.Resources {
-		existing.Universe.PutResource(r)
+	if dryRun {
+		httputil.WriteJsonResponse(w, req, OverlayOutput{existing.ToData(), warnings, true})
+		return
 	}
 
 	if err := api.Store.Update(req.Context(), existing); err != nil {
+		if errors.Is(err, overlay.ErrConflict) {
+			writeStoreError(w, req, id, err)
+			return
+		}
 		httputil.ServerError(w, req, fmt.Errorf("failed to update overlay: %v", err))
 		return
 	}
 
-	httputil.WriteJsonResponse(w, req, existing.ToData())
+	httputil.WriteJsonResponse(w, req, OverlayOutput{existing.ToData(), warnings, false})
+}
+
+// lint lints an overlay against the base universe, returning its warnings. If any errors are
+// found, it writes a 400 response listing every issue and returns false
+func (api *OverlayAPI) lint(
+	w http.ResponseWriter, req *http.Request, o *entities.Overlay) ([]overlay.Issue, bool) {
+
+	warnings, err := overlay.Check(o, api.Base)
+	var lintErr *overlay.LintError
+	if errors.As(err, &lintErr) {
+		httputil.WriteJsonResponseWithStatus(w, req, http.StatusBadRequest, OverlayLintError{
+			ErrorOutput: ErrorOutput{Error: lintErr.Error()},
+			Issues:      lintErr.Issues,
+		})
+		return nil, false
+	}
+	return warnings, true
+}
+
+// parseDryRun parses the optional `dryRun` query parameter
+func parseDryRun(req *http.Request) (bool, error) {
+	value := req.URL.Query().Get("dryRun")
+	if value == "" {
+		return false, nil
+	}
+
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for 'dryRun': '%s'", value)
+	}
+	return dryRun, nil
+}
+
+// conflictError describes an update which was based on an outdated revision of an overlay
+func conflictError(id string, revision, current int) error {
+	return fmt.Errorf("overlay %s is at revision %d, not %d; fetch the latest revision and retry",
+		id, current, revision)
 }
 
 // DeleteOverlay deletes an overlay by ID.
@@ -197,3 +301,350 @@ func (api *OverlayAPI) DeleteOverlay(w http.ResponseWriter, req *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// -------------------------------------------------------------------------------------------------
+// Revisions
+// -------------------------------------------------------------------------------------------------
+
+// RollbackOverlayInput is the request body for rolling back an overlay.
+type RollbackOverlayInput struct {
+	// Revision is the earlier revision whose contents should be restored
+	Revision int `json:"revision"`
+
+	// CurrentRevision, if provided, must be the current revision of the overlay
+	CurrentRevision int `json:"currentRevision,omitempty"`
+
+	Author  string `json:"author,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ListOverlayRevisions returns the metadata of every revision of an overlay, oldest first.
+// GET /api/v1/overlays/{id}/revisions
+func (api *OverlayAPI) ListOverlayRevisions(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	revisions, err := api.Store.Revisions(req.Context(), id)
+	if err != nil {
+		writeStoreError(w, req, id, err)
+		return
+	}
+
+	httputil.WriteJsonResponse(w, req, revisions)
+}
+
+// GetOverlayRevision retrieves a specific revision of an overlay.
+// GET /api/v1/overlays/{id}/revisions/{revision}
+func (api *OverlayAPI) GetOverlayRevision(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	revision, err := parseRevision("revision", req.PathValue("revision"))
+	if err != nil {
+		httputil.ClientError(w, req, err)
+		return
+	}
+
+	o, err := api.Store.GetRevision(req.Context(), id, revision)
+	if err != nil {
+		writeStoreError(w, req, id, err)
+		return
+	}
+
+	httputil.WriteJsonResponse(w, req, o.ToData())
+}
+
+// DiffOverlayRevisions describes the changes between two revisions of an overlay. `to` defaults
+// to the current revision, and `from` to the revision before `to`.
+// GET /api/v1/overlays/{id}/diff?from=1&to=2
+func (api *OverlayAPI) DiffOverlayRevisions(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	query := req.URL.Query()
+
+	to, err := api.Store.Get(req.Context(), id)
+	if err != nil {
+		writeStoreError(w, req, id, err)
+		return
+	}
+	if query.Has("to") {
+		revision, err := parseRevision("to", query.Get("to"))
+		if err != nil {
+			httputil.ClientError(w, req, err)
+			return
+		}
+		if to, err = api.Store.GetRevision(req.Context(), id, revision); err != nil {
+			writeStoreError(w, req, id, err)
+			return
+		}
+	}
+
+	fromRevision := to.Revision - 1
+	if query.Has("from") {
+		if fromRevision, err = parseRevision("from", query.Get("from")); err != nil {
+			httputil.ClientError(w, req, err)
+			return
+		}
+	}
+
+	// the first revision is compared against an empty overlay
+	from := &entities.Overlay{Name: to.Name, ID: to.ID}
+	if fromRevision > 0 {
+		if from, err = api.Store.GetRevision(req.Context(), id, fromRevision); err != nil {
+			writeStoreError(w, req, id, err)
+			return
+		}
+	}
+
+	httputil.WriteJsonResponse(w, req, entities.DiffOverlays(from.ToData(), to.ToData()))
+}
+
+// RollbackOverlay restores the contents of an earlier revision of an overlay, storing them as a
+// new revision so that no history is lost.
+// POST /api/v1/overlays/{id}/rollback
+func (api *OverlayAPI) RollbackOverlay(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	var input RollbackOverlayInput
+	decoder := json.ConfigDefault.NewDecoder(req.Body)
+	if err := decoder.Decode(&input); err != nil {
+		httputil.ClientError(w, req, fmt.Errorf("invalid JSON: %v", err))
+		return
+	}
+	if input.Revision <= 0 {
+		httputil.ClientError(w, req, fmt.Errorf("missing required field 'revision'"))
+		return
+	}
+
+	current, err := api.Store.Get(req.Context(), id)
+	if err != nil {
+		writeStoreError(w, req, id, err)
+		return
+	}
+	if input.CurrentRevision != 0 && input.CurrentRevision != current.Revision {
+		httputil.Error(w, req, http.StatusConflict,
+			conflictError(id, input.CurrentRevision, current.Revision))
+		return
+	}
+
+	o, err := api.Store.GetRevision(req.Context(), id, input.Revision)
+	if err != nil {
+		writeStoreError(w, req, id, err)
+		return
+	}
+
+	o.CreatedAt = current.CreatedAt
+	o.ExpiresAt = current.ExpiresAt
+	o.Revision = current.Revision
+	o.Author = input.Author
+	o.Message = input.Message
+	if o.Message == "" {
+		o.Message = fmt.Sprintf("Roll back to revision %d", input.Revision)
+	}
+
+	if err := api.Store.Update(req.Context(), o); err != nil {
+		writeStoreError(w, req, id, err)
+		return
+	}
+
+	httputil.WriteJsonResponse(w, req, o.ToData())
+}
+
+// exportContentTypes maps each export format to the content type of its output
+var exportContentTypes = map[string]string{
+	overlay.ExportTerraform:      "text/plain; charset=utf-8",
+	overlay.ExportCloudFormation: "application/json; charset=utf-8",
+	overlay.ExportAWSConfig:      "application/x-ndjson; charset=utf-8",
+}
+
+// ExportOverlay renders an overlay, or one of its revisions, as infrastructure code.
+// GET /api/v1/overlays/{id}/export?format=terraform|cloudformation|awsconfig
+func (api *OverlayAPI) ExportOverlay(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	query := req.URL.Query()
+
+	format := query.Get("format")
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		httputil.ClientError(w, req, fmt.Errorf("invalid value for 'format': '%s', must be one of: %s",
+			format, strings.Join(overlay.ExportFormats, ", ")))
+		return
+	}
+
+	o, err := api.Store.Get(req.Context(), id)
+	if err != nil {
+		writeStoreError(w, req, id, err)
+		return
+	}
+	if query.Has("revision") {
+		revision, err := parseRevision("revision", query.Get("revision"))
+		if err != nil {
+			httputil.ClientError(w, req, err)
+			return
+		}
+		if o, err = api.Store.GetRevision(req.Context(), id, revision); err != nil {
+			writeStoreError(w, req, id, err)
+			return
+		}
+	}
+
+	out, err := overlay.Export(o.ToData(), format)
+	if err != nil {
+		httputil.ServerError(w, req, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(out); err != nil {
+		slog.Error("error writing export response", "error", err)
+	}
+}
+
+// parseRevision parses a revision number provided in the path or query
+func parseRevision(name, value string) (int, error) {
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 1 {
+		return 0, fmt.Errorf("invalid value for '%s': '%s'", name, value)
+	}
+	return revision, nil
+}
+
+// writeStoreError writes the response for an error returned by the overlay store
+func writeStoreError(w http.ResponseWriter, req *http.Request, id string, err error) {
+	switch {
+	case errors.Is(err, overlay.ErrNotFound):
+		httputil.Error(w, req, http.StatusNotFound, fmt.Errorf("overlay not found: %s", id))
+	case errors.Is(err, overlay.ErrRevisionNotFound):
+		httputil.Error(w, req, http.StatusNotFound, fmt.Errorf("overlay revision not found: %s", id))
+	case errors.Is(err, overlay.ErrConflict):
+		httputil.Error(w, req, http.StatusConflict,
+			fmt.Errorf("overlay was modified concurrently: %s", id))
+	case errors.Is(err, overlay.ErrAlreadyExists):
+		httputil.Error(w, req, http.StatusConflict,
+			fmt.Errorf("overlay with this name already exists"))
+	default:
+		httputil.ServerError(w, req, fmt.Errorf("overlay store error: %v", err))
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// Validation
+// -------------------------------------------------------------------------------------------------
+
+// Validate checks that the input contains the fields required to create an overlay
+func (input *CreateOverlayInput) Validate() error {
+	if input.Name == "" {
+		return fmt.Errorf("missing required field 'name'")
+	}
+	if _, _, err := input.Expiry(time.Now()); err != nil {
+		return err
+	}
+
+	return validateOverlay(input.Tombstones, input.Patches)
+}
+
+// Validate checks that the input describes a valid replacement for an existing overlay
+func (input *UpdateOverlayInput) Validate() error {
+	if _, _, err := input.Expiry(time.Now()); err != nil {
+		return err
+	}
+
+	return validateOverlay(input.Tombstones, input.Patches)
+}
+
+// Expiry returns the expiry described by the input relative to `now`, and whether one was
+// provided. A zero expiry means that the overlay should not expire
+func (input *ExpiryInput) Expiry(now time.Time) (time.Time, bool, error) {
+	switch {
+	case input.ExpiresAt != nil && input.TTL != "":
+		return time.Time{}, false, fmt.Errorf("only one of 'expiresAt' and 'ttl' may be provided")
+	case input.ExpiresAt != nil:
+		if !input.ExpiresAt.After(now) {
+			return time.Time{}, false, fmt.Errorf("'expiresAt' must be in the future")
+		}
+		return *input.ExpiresAt, true, nil
+	case input.TTL != "":
+		ttl, err := time.ParseDuration(input.TTL)
+		if err != nil || ttl < 0 {
+			return time.Time{}, false, fmt.Errorf("invalid value for 'ttl': '%s'", input.TTL)
+		}
+		if ttl == 0 {
+			return time.Time{}, true, nil
+		}
+		return now.Add(ttl), true, nil
+	default:
+		return time.Time{}, false, nil
+	}
+}
+
+// validateOverlay checks that each tombstone identifies an entity and that each patch is well-formed
+func validateOverlay(tombstones []entities.Arn, patches []entities.Patch) error {
+	if slices.Contains(tombstones, "") {
+		return fmt.Errorf("invalid empty ARN in 'tombstones'")
+	}
+	for _, p := range patches {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Overlay builds a new overlay containing the entities described by the input
+func (input *CreateOverlayInput) Overlay() *entities.Overlay {
+	o := entities.NewOverlay(input.Name)
+	o.Author = input.Author
+	o.Message = input.Message
+	o.ExpiresAt, _, _ = input.Expiry(o.CreatedAt)
+	populateOverlay(o.Universe, input.Accounts, input.Groups, input.Policies, input.Principals,
+		input.Resources, input.Tombstones, input.Patches)
+	return o
+}
+
+// Apply updates the existing overlay in place, renaming it and changing its expiry if provided and
+// replacing its entities and revision metadata with those described by the input
+func (input *UpdateOverlayInput) Apply(o *entities.Overlay) {
+	if input.Name != "" {
+		o.Name = input.Name
+	}
+	if expiresAt, ok, _ := input.Expiry(time.Now()); ok {
+		o.ExpiresAt = expiresAt
+	}
+	o.Author = input.Author
+	o.Message = input.Message
+
+	o.Universe = entities.NewUniverse()
+	populateOverlay(o.Universe, input.Accounts, input.Groups, input.Policies, input.Principals,
+		input.Resources, input.Tombstones, input.Patches)
+}
+
+// populateOverlay adds the provided entities, tombstones and patches to an overlay's universe
+func populateOverlay(
+	uv *entities.Universe,
+	accounts []entities.Account,
+	groups []entities.Group,
+	policies []entities.ManagedPolicy,
+	principals []entities.Principal,
+	resources []entities.Resource,
+	tombstones []entities.Arn,
+	patches []entities.Patch) {
+
+	for _, a := range accounts {
+		uv.PutAccount(a)
+	}
+	for _, g := range groups {
+		uv.PutGroup(g)
+	}
+	for _, p := range policies {
+		uv.PutPolicy(p)
+	}
+	for _, p := range principals {
+		uv.PutPrincipal(p)
+	}
+	for _, r := range resources {
+		uv.PutResource(r)
+	}
+	for _, arn := range tombstones {
+		uv.PutTombstone(arn)
+	}
+	for _, p := range patches {
+		uv.PutPatch(p)
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	json "github.com/bytedance/sonic"
 	"github.com/nsiow/yams/pkg/entities"
 	"github.com/nsiow/yams/pkg/overlay"
+	"github.com/nsiow/yams/pkg/policy"
 )
 
 func newTestOverlayAPI(t *testing.T) *OverlayAPI {
@@ -64,6 +67,91 @@ func TestOverlayAPI_CreateOverlay_MissingName(t *testing.T) {
 	}
 }
 
+func TestOverlayAPI_CreateOverlay_Tombstones(t *testing.T) {
+	api := newTestOverlayAPI(t)
+
+	input := CreateOverlayInput{
+		Name:       "decommission",
+		Tombstones: []entities.Arn{"arn:aws:iam::123456789012:policy/legacy"},
+	}
+	body, _ := json.Marshal(input)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/overlays", bytes.NewReader(body))
+
+	api.CreateOverlay(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateOverlay() status = %d, want %d, body = %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	var data entities.OverlayData
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatalf("CreateOverlay() invalid JSON: %v", err)
+	}
+	if len(data.Tombstones) != 1 || data.Tombstones[0] != input.Tombstones[0] {
+		t.Errorf("CreateOverlay() tombstones = %v, want %v", data.Tombstones, input.Tombstones)
+	}
+
+	// empty tombstones are rejected
+	input = CreateOverlayInput{Name: "invalid", Tombstones: []entities.Arn{""}}
+	body, _ = json.Marshal(input)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/v1/overlays", bytes.NewReader(body))
+
+	api.CreateOverlay(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("CreateOverlay() empty tombstone status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOverlayAPI_CreateOverlay_Patches(t *testing.T) {
+	api := newTestOverlayAPI(t)
+
+	input := CreateOverlayInput{
+		Name: "boundary",
+		Patches: []entities.Patch{{
+			Arn:   "arn:aws:iam::123456789012:role/app",
+			Merge: map[string]any{"PermissionsBoundary": "arn:aws:iam::123456789012:policy/b"},
+		}},
+	}
+	body, _ := json.Marshal(input)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/overlays", bytes.NewReader(body))
+
+	api.CreateOverlay(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateOverlay() status = %d, want %d, body = %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	var data entities.OverlayData
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatalf("CreateOverlay() invalid JSON: %v", err)
+	}
+	if len(data.Patches) != 1 || data.Patches[0].Arn != input.Patches[0].Arn {
+		t.Errorf("CreateOverlay() patches = %v, want %v", data.Patches, input.Patches)
+	}
+
+	// malformed patches are rejected
+	input = CreateOverlayInput{
+		Name: "invalid",
+		Patches: []entities.Patch{{
+			Arn:        "arn:aws:iam::123456789012:role/app",
+			Operations: []entities.PatchOperation{{Op: "append", Path: "/Tags"}},
+		}},
+	}
+	body, _ = json.Marshal(input)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/v1/overlays", bytes.NewReader(body))
+
+	api.CreateOverlay(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("CreateOverlay() invalid patch status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
 func TestOverlayAPI_CreateOverlay_InvalidJSON(t *testing.T) {
 	api := newTestOverlayAPI(t)
 
@@ -192,6 +280,398 @@ func TestOverlayAPI_UpdateOverlay(t *testing.T) {
 	}
 }
 
+func TestOverlayAPI_UpdateOverlay_Conflict(t *testing.T) {
+	api := newTestOverlayAPI(t)
+
+	o := entities.NewOverlay("test-overlay")
+	_ = api.Store.Create(context.Background(), o)
+
+	// both updates are based on revision 1, so only the first succeeds
+	for i, want := range []int{http.StatusOK, http.StatusConflict} {
+		input := UpdateOverlayInput{Revision: 1, Author: "alice", Message: "change"}
+		body, _ := json.Marshal(input)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/api/v1/overlays/"+o.ID, bytes.NewReader(body))
+		req.SetPathValue("id", o.ID)
+
+		api.UpdateOverlay(w, req)
+
+		if w.Code != want {
+			t.Fatalf("UpdateOverlay() #%d status = %d, want %d, body = %s", i, w.Code, want, w.Body.String())
+		}
+	}
+
+	current, _ := api.Store.Get(context.Background(), o.ID)
+	if current.Revision != 2 || current.Author != "alice" || current.Message != "change" {
+		t.Errorf("unexpected current revision: %v", current.RevisionInfo())
+	}
+}
+
+func TestOverlayAPI_Expiry(t *testing.T) {
+	api := newTestOverlayAPI(t)
+	past := time.Now().Add(-time.Hour)
+
+	// invalid expiries are rejected
+	for _, input := range []CreateOverlayInput{
+		{Name: "both", ExpiryInput: ExpiryInput{ExpiresAt: &past, TTL: "1h"}},
+		{Name: "past", ExpiryInput: ExpiryInput{ExpiresAt: &past}},
+		{Name: "negative", ExpiryInput: ExpiryInput{TTL: "-1h"}},
+		{Name: "invalid", ExpiryInput: ExpiryInput{TTL: "tomorrow"}},
+	} {
+		body, _ := json.Marshal(input)
+		w := httptest.NewRecorder()
+		api.CreateOverlay(w, httptest.NewRequest("POST", "/api/v1/overlays", bytes.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("CreateOverlay(%s) status = %d, want %d", input.Name, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	body := []byte(`{"name": "temporary", "ttl": "24h"}`)
+	w := httptest.NewRecorder()
+	api.CreateOverlay(w, httptest.NewRequest("POST", "/api/v1/overlays", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateOverlay() status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var data entities.OverlayData
+	_ = json.Unmarshal(w.Body.Bytes(), &data)
+	if want := data.CreatedAt.Add(24 * time.Hour); !data.ExpiresAt.Equal(want) {
+		t.Fatalf("CreateOverlay() expiresAt = %v, want %v", data.ExpiresAt, want)
+	}
+
+	// updates keep the current expiry unless a new one is provided
+	update := func(body string) entities.OverlayData {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/api/v1/overlays/"+data.ID, bytes.NewReader([]byte(body)))
+		req.SetPathValue("id", data.ID)
+		api.UpdateOverlay(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("UpdateOverlay() status = %d, body = %s", w.Code, w.Body.String())
+		}
+		var out entities.OverlayData
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return out
+	}
+
+	if updated := update(`{"message": "keep"}`); !updated.ExpiresAt.Equal(data.ExpiresAt) {
+		t.Errorf("expected expiry to be kept, got %v", updated.ExpiresAt)
+	}
+	if updated := update(`{"expiresAt": "2999-01-01T00:00:00Z"}`); updated.ExpiresAt.Year() != 2999 {
+		t.Errorf("expected expiry to be replaced, got %v", updated.ExpiresAt)
+	}
+	if updated := update(`{"ttl": "0"}`); !updated.ExpiresAt.IsZero() {
+		t.Errorf("expected expiry to be removed, got %v", updated.ExpiresAt)
+	}
+}
+
+func TestOverlayAPI_Lint(t *testing.T) {
+	api := newTestOverlayAPI(t)
+	api.Base = entities.NewUniverse()
+	api.Base.PutAccount(entities.Account{Id: "123456789012"})
+
+	create := func(query string, input CreateOverlayInput) *httptest.ResponseRecorder {
+		t.Helper()
+		body, _ := json.Marshal(input)
+		w := httptest.NewRecorder()
+		api.CreateOverlay(w, httptest.NewRequest("POST", "/api/v1/overlays"+query, bytes.NewReader(body)))
+		return w
+	}
+
+	// errors are rejected, listing every issue
+	w := create("", CreateOverlayInput{
+		Name: "broken",
+		Principals: []entities.Principal{{
+			Type:             "AWS::IAM::Role",
+			AccountId:        "123456789012",
+			Arn:              "arn:aws:iam::123456789013:role/typo",
+			AttachedPolicies: []entities.Arn{"arn:aws:iam::123456789012:policy/missing"},
+		}},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("CreateOverlay() status = %d, want %d, body = %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+	var lintErr OverlayLintError
+	if err := json.Unmarshal(w.Body.Bytes(), &lintErr); err != nil {
+		t.Fatalf("CreateOverlay() invalid JSON: %v", err)
+	}
+	if lintErr.Error == "" || len(lintErr.Issues) != 2 || lintErr.Issues[0].Code != overlay.IssueAccountMismatch {
+		t.Fatalf("CreateOverlay() unexpected lint error: %+v", lintErr)
+	}
+	if exists, _ := api.Store.Exists(context.Background(), entities.GenerateOverlayID("broken")); exists {
+		t.Fatalf("expected overlay with errors not to be stored")
+	}
+
+	// warnings are returned alongside the overlay
+	input := CreateOverlayInput{
+		Name: "warned",
+		Principals: []entities.Principal{{
+			Type:             "AWS::IAM::Role",
+			AccountId:        "123456789012",
+			Arn:              "arn:aws:iam::123456789012:role/warned",
+			AttachedPolicies: []entities.Arn{"arn:aws:iam::123456789012:policy/missing"},
+		}},
+	}
+	for _, query := range []string{"?dryRun=true", ""} {
+		w = create(query, input)
+		var out OverlayOutput
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		if len(out.Warnings) != 1 || out.Warnings[0].Code != overlay.IssueUnresolvedPolicy {
+			t.Fatalf("CreateOverlay(%q) unexpected warnings: %+v", query, out.Warnings)
+		}
+
+		// dry runs do not store the overlay
+		exists, _ := api.Store.Exists(context.Background(), out.ID)
+		switch {
+		case query != "" && (w.Code != http.StatusOK || !out.DryRun || exists):
+			t.Fatalf("CreateOverlay(%q) status = %d, dryRun = %v, exists = %v", query, w.Code, out.DryRun, exists)
+		case query == "" && (w.Code != http.StatusCreated || out.DryRun || !exists):
+			t.Fatalf("CreateOverlay(%q) status = %d, dryRun = %v, exists = %v", query, w.Code, out.DryRun, exists)
+		}
+	}
+
+	// dry runs report conflicts with existing overlays
+	w = create("?dryRun=true", input)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("CreateOverlay() duplicate dry run status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	w = create("?dryRun=maybe", input)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("CreateOverlay() invalid dryRun status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOverlayAPI_Lint_Update(t *testing.T) {
+	api := newTestOverlayAPI(t)
+
+	o := entities.NewOverlay("test-overlay")
+	_ = api.Store.Create(context.Background(), o)
+
+	update := func(query string, input UpdateOverlayInput) *httptest.ResponseRecorder {
+		t.Helper()
+		body, _ := json.Marshal(input)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/api/v1/overlays/"+o.ID+query, bytes.NewReader(body))
+		req.SetPathValue("id", o.ID)
+		api.UpdateOverlay(w, req)
+		return w
+	}
+
+	w := update("", UpdateOverlayInput{
+		Policies: []entities.ManagedPolicy{{Arn: "arn:aws:iam::123456789012:policy/empty-statement",
+			Policy: policy.Policy{Statement: []policy.Statement{{Effect: policy.EFFECT_ALLOW}}}}},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("UpdateOverlay() status = %d, want %d, body = %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+
+	w = update("?dryRun=1", UpdateOverlayInput{Name: "renamed"})
+	var out OverlayOutput
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	if w.Code != http.StatusOK || !out.DryRun || out.Name != "renamed" || out.Revision != 1 {
+		t.Fatalf("UpdateOverlay() status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	current, _ := api.Store.Get(context.Background(), o.ID)
+	if current.Revision != 1 || current.Name != "test-overlay" {
+		t.Fatalf("expected failed and dry-run updates not to be stored, got %v", current.RevisionInfo())
+	}
+}
+
+func TestOverlayAPI_Revisions(t *testing.T) {
+	api := newTestOverlayAPI(t)
+	ctx := context.Background()
+
+	o := entities.NewOverlay("test-overlay")
+	o.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/original"})
+	_ = api.Store.Create(ctx, o)
+
+	o.Universe = entities.NewUniverse()
+	o.Universe.PutPrincipal(entities.Principal{Arn: "arn:aws:iam::123456789012:role/replacement"})
+	_ = api.Store.Update(ctx, o)
+
+	do := func(method, target string, body any, handler http.HandlerFunc, path ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, bytes.NewReader(b))
+		req.SetPathValue("id", o.ID)
+		for i := 0; i+1 < len(path); i += 2 {
+			req.SetPathValue(path[i], path[i+1])
+		}
+		handler(w, req)
+		return w
+	}
+
+	// list revisions
+	w := do("GET", "/api/v1/overlays/"+o.ID+"/revisions", nil, api.ListOverlayRevisions)
+	var revisions []entities.OverlayRevision
+	if err := json.Unmarshal(w.Body.Bytes(), &revisions); err != nil || len(revisions) != 2 {
+		t.Fatalf("ListOverlayRevisions() = %s (status %d)", w.Body.String(), w.Code)
+	}
+
+	// fetch a specific revision
+	w = do("GET", "/api/v1/overlays/"+o.ID+"/revisions/1", nil, api.GetOverlayRevision,
+		"revision", "1")
+	var data entities.OverlayData
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil || data.Revision != 1 ||
+		data.Principals[0].Arn != "arn:aws:iam::123456789012:role/original" {
+		t.Fatalf("GetOverlayRevision() = %s (status %d)", w.Body.String(), w.Code)
+	}
+	for revision, want := range map[string]int{"9": http.StatusNotFound, "x": http.StatusBadRequest} {
+		w = do("GET", "/api/v1/overlays/"+o.ID+"/revisions/"+revision, nil, api.GetOverlayRevision,
+			"revision", revision)
+		if w.Code != want {
+			t.Errorf("GetOverlayRevision(%s) status = %d, want %d", revision, w.Code, want)
+		}
+	}
+
+	// diff the current revision against the previous one
+	w = do("GET", "/api/v1/overlays/"+o.ID+"/diff", nil, api.DiffOverlayRevisions)
+	var diff entities.OverlayDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil ||
+		diff.FromRevision != 1 || diff.ToRevision != 2 || len(diff.Changes) != 2 {
+		t.Fatalf("DiffOverlayRevisions() = %s (status %d)", w.Body.String(), w.Code)
+	}
+
+	// the first revision is compared against an empty overlay
+	w = do("GET", "/api/v1/overlays/"+o.ID+"/diff?to=1", nil, api.DiffOverlayRevisions)
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil ||
+		len(diff.Changes) != 1 || diff.Changes[0].Change != entities.ChangeAdded {
+		t.Fatalf("DiffOverlayRevisions(to=1) = %s (status %d)", w.Body.String(), w.Code)
+	}
+
+	// roll back based on an outdated revision
+	w = do("POST", "/api/v1/overlays/"+o.ID+"/rollback",
+		RollbackOverlayInput{Revision: 1, CurrentRevision: 1}, api.RollbackOverlay)
+	if w.Code != http.StatusConflict {
+		t.Errorf("RollbackOverlay() outdated status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	// roll back to the first revision, which creates a new revision
+	w = do("POST", "/api/v1/overlays/"+o.ID+"/rollback",
+		RollbackOverlayInput{Revision: 1, CurrentRevision: 2, Author: "bob"}, api.RollbackOverlay)
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil || data.Revision != 3 ||
+		data.Author != "bob" || data.Message != "Roll back to revision 1" ||
+		data.Principals[0].Arn != "arn:aws:iam::123456789012:role/original" {
+		t.Fatalf("RollbackOverlay() = %s (status %d)", w.Body.String(), w.Code)
+	}
+
+	w = do("POST", "/api/v1/overlays/"+o.ID+"/rollback", RollbackOverlayInput{}, api.RollbackOverlay)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("RollbackOverlay() missing revision status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOverlayAPI_ExportOverlay(t *testing.T) {
+	api := newTestOverlayAPI(t)
+	ctx := context.Background()
+
+	o := entities.NewOverlay("test-overlay")
+	o.Universe.PutPrincipal(entities.Principal{
+		Type:      "AWS::IAM::Role",
+		AccountId: "123456789012",
+		Arn:       "arn:aws:iam::123456789012:role/original",
+	})
+	_ = api.Store.Create(ctx, o)
+
+	o.Universe = entities.NewUniverse()
+	o.Universe.PutPrincipal(entities.Principal{
+		Type:      "AWS::IAM::Role",
+		AccountId: "123456789012",
+		Arn:       "arn:aws:iam::123456789012:role/replacement",
+	})
+	_ = api.Store.Update(ctx, o)
+
+	tests := []struct {
+		name        string
+		id          string
+		query       string
+		wantStatus  int
+		wantType    string
+		wantContent string
+	}{
+		{
+			name:        "terraform",
+			id:          o.ID,
+			query:       "format=terraform",
+			wantStatus:  http.StatusOK,
+			wantType:    "text/plain; charset=utf-8",
+			wantContent: `resource "aws_iam_role" "replacement" {`,
+		},
+		{
+			name:        "cloudformation",
+			id:          o.ID,
+			query:       "format=cloudformation",
+			wantStatus:  http.StatusOK,
+			wantType:    "application/json; charset=utf-8",
+			wantContent: `"Type": "AWS::IAM::Role"`,
+		},
+		{
+			name:        "awsconfig_revision",
+			id:          o.ID,
+			query:       "format=awsconfig&revision=1",
+			wantStatus:  http.StatusOK,
+			wantType:    "application/x-ndjson; charset=utf-8",
+			wantContent: `"arn":"arn:aws:iam::123456789012:role/original"`,
+		},
+		{
+			name:       "missing_format",
+			id:         o.ID,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unsupported_format",
+			id:         o.ID,
+			query:      "format=pulumi",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid_revision",
+			id:         o.ID,
+			query:      "format=terraform&revision=x",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing_revision",
+			id:         o.ID,
+			query:      "format=terraform&revision=9",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "not_found",
+			id:         "missing",
+			query:      "format=terraform",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v1/overlays/"+tt.id+"/export?"+tt.query, nil)
+			req.SetPathValue("id", tt.id)
+
+			api.ExportOverlay(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("ExportOverlay() status = %d, want %d, body = %s",
+					w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantType != "" && w.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("ExportOverlay() content type = %q, want %q",
+					w.Header().Get("Content-Type"), tt.wantType)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantContent) {
+				t.Errorf("ExportOverlay() body = %s, want to contain %q", w.Body.String(), tt.wantContent)
+			}
+		})
+	}
+}
+
 func TestOverlayAPI_UpdateOverlay_NotFound(t *testing.T) {
 	api := newTestOverlayAPI(t)
 
@@ -1,9 +1,11 @@
 package v1
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 
 	json "github.com/bytedance/sonic"
 	"github.com/nsiow/yams/pkg/server/httputil"
@@ -20,10 +22,12 @@ type SimInput struct {
 	Resource  string            `json:"resource"`
 	Context   map[string]string `json:"context"`
 
-	Fuzzy   bool    `json:"fuzzy"`
-	Explain bool    `json:"explain"`
-	Trace   bool    `json:"trace"`
-	Overlay Overlay `json:"overlay"`
+	Fuzzy    bool      `json:"fuzzy"`
+	Explain  bool      `json:"explain"`
+	Trace    bool      `json:"trace"`
+	Overlay  Overlay   `json:"overlay"`
+	Overlays []Overlay `json:"overlays,omitzero"`
+	OverlayRefs
 }
 
 type SimOutput struct {
@@ -35,6 +39,31 @@ type SimOutput struct {
 	Trace     []string `json:"trace,omitzero"`
 }
 
+// clone returns a copy of the output which shares no slices with the original
+func (out SimOutput) clone() SimOutput {
+	out.Explain = slices.Clone(out.Explain)
+	out.Trace = slices.Clone(out.Trace)
+	return out
+}
+
+type SimBatchInput struct {
+	Simulations []SimInput `json:"simulations"`
+}
+
+type SimBatchOutput struct {
+	Results []SimBatchResult `json:"results"`
+}
+
+// SimBatchResult contains either the output of a single simulation within a batch, or the error
+// which prevented it from completing
+type SimBatchResult struct {
+	Output *SimOutput `json:"output,omitzero"`
+	Error  string     `json:"error,omitzero"`
+}
+
+// maxSimBatchSize limits the number of simulations that may be requested in a single batch
+const maxSimBatchSize = 1000
+
 // -------------------------------------------------------------------------------------------------
 // Handlers
 // -------------------------------------------------------------------------------------------------
@@ -50,54 +79,127 @@ func (api *API) SimRun(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// validate
-	if len(input.Principal) == 0 {
-		httputil.ClientError(w, req, fmt.Errorf("missing required input 'principal'"))
-		return
-	}
-	if len(input.Action) == 0 {
-		httputil.ClientError(w, req, fmt.Errorf("missing required input 'action'"))
+	err = input.Validate()
+	if err != nil {
+		httputil.ClientError(w, req, err)
 		return
 	}
 
-	// construct options
-	opts := sim.NewOptions(sim.WithAdditionalProperties(input.Context))
-	opts.EnableTracing = input.Explain || input.Trace
-	opts.Overlay = input.Overlay.Universe()
-	opts.EnableFuzzyMatchArn = input.Fuzzy
-
 	// simulate
-	result, err := api.Simulator.SimulateByArnWithOptions(
-		input.Principal,
-		input.Action,
-		input.Resource,
-		opts)
+	out, hit, err := api.Simulate(req.Context(), input)
 	if err != nil {
-		httputil.ServerError(w, req, fmt.Errorf("simulation error: %v", err))
+		simulationError(w, req, err)
 		return
 	}
 
-	// construct response
-	out := SimOutput{}
-	out.Principal = result.Principal
-	out.Action = result.Action
-	out.Resource = result.Resource
-	if result.IsAllowed {
-		out.Result = "ALLOW"
-	} else {
-		out.Result = "DENY"
-	}
-	if input.Explain || input.Trace {
-		out.Explain = result.Trace.Explain()
-	}
-	if input.Trace {
-		out.Trace = result.Trace.Trace()
-	}
-
 	slog.Info("simulation result",
 		"principal", input.Principal,
 		"action", input.Action,
 		"resource", input.Resource,
 		"context", input.Context,
-		"result", out.Result)
+		"result", out.Result,
+		"cached", hit)
+	httputil.WriteJsonResponse(w, req, out)
+}
+
+func (api *API) SimBatch(w http.ResponseWriter, req *http.Request) {
+	// read input
+	input := SimBatchInput{}
+	decoder := json.ConfigDefault.NewDecoder(req.Body)
+	err := decoder.Decode(&input)
+	if err != nil {
+		httputil.ClientError(w, req, fmt.Errorf("invalid JSON: %v", err))
+		return
+	}
+
+	// validate
+	if len(input.Simulations) > maxSimBatchSize {
+		httputil.ClientError(w, req,
+			fmt.Errorf("too many simulations in batch: %d > %d", len(input.Simulations), maxSimBatchSize))
+		return
+	}
+
+	// simulate; individual failures are reported inline rather than failing the whole batch
+	out := SimBatchOutput{Results: make([]SimBatchResult, len(input.Simulations))}
+	hits := 0
+	for i, simInput := range input.Simulations {
+		err := simInput.Validate()
+		if err != nil {
+			out.Results[i].Error = err.Error()
+			continue
+		}
+
+		result, hit, err := api.Simulate(req.Context(), simInput)
+		if err != nil {
+			out.Results[i].Error = fmt.Sprintf("simulation error: %v", err)
+			continue
+		}
+		if hit {
+			hits++
+		}
+		out.Results[i].Output = &result
+	}
+
+	slog.Info("batch simulation results",
+		"count", len(input.Simulations),
+		"cached", hits)
 	httputil.WriteJsonResponse(w, req, out)
 }
+
+// Validate checks that the input contains the fields required for simulation
+func (input *SimInput) Validate() error {
+	if len(input.Principal) == 0 {
+		return fmt.Errorf("missing required input 'principal'")
+	}
+	if len(input.Action) == 0 {
+		return fmt.Errorf("missing required input 'action'")
+	}
+
+	return input.OverlayRefs.Validate()
+}
+
+// Simulate runs the simulation described by the input, serving it from the result cache if
+// possible. Also returns whether or not the result came from the cache
+func (api *API) Simulate(ctx context.Context, input SimInput) (SimOutput, bool, error) {
+	overlay, err := api.resolveOverlay(ctx, input.OverlayRefs, input.Overlays, input.Overlay)
+	if err != nil {
+		return SimOutput{}, false, err
+	}
+
+	return cached(api.Cache, "sim", overlay.key(input), func() (SimOutput, error) {
+		// construct options
+		opts := sim.NewOptions(sim.WithAdditionalProperties(input.Context))
+		opts.EnableTracing = input.Explain || input.Trace
+		opts.Overlays = overlay.layers
+		opts.EnableFuzzyMatchArn = input.Fuzzy
+
+		// simulate
+		result, err := api.Simulator.SimulateByArnWithOptions(
+			input.Principal,
+			input.Action,
+			input.Resource,
+			opts)
+		if err != nil {
+			return SimOutput{}, err
+		}
+
+		// construct response
+		out := SimOutput{}
+		out.Principal = result.Principal
+		out.Action = result.Action
+		out.Resource = result.Resource
+		if result.IsAllowed {
+			out.Result = "ALLOW"
+		} else {
+			out.Result = "DENY"
+		}
+		if input.Explain || input.Trace {
+			out.Explain = result.Trace.Explain()
+		}
+		if input.Trace {
+			out.Trace = result.Trace.Trace()
+		}
+
+		return out, nil
+	}, SimOutput.clone)
+}
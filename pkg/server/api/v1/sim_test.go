@@ -0,0 +1,420 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/overlay"
+	"github.com/nsiow/yams/pkg/policy"
+)
+
+// newTestAPIWithCache creates an API with an allowed principal/resource pair and a result cache
+func newTestAPIWithCache(t *testing.T) *API {
+	t.Helper()
+	api := newTestAPIWithData(t)
+	api.Cache = NewResultCache(100, api.Simulator.Universe.Generation)
+
+	api.Simulator.Universe.PutPrincipal(entities.Principal{
+		Arn:       "arn:aws:iam::123456789012:user/adminuser",
+		AccountId: "123456789012",
+		InlinePolicies: []policy.Policy{
+			{
+				Statement: []policy.Statement{
+					{
+						Effect:   policy.EFFECT_ALLOW,
+						Action:   []string{"s3:ListBucket"},
+						Resource: []string{"arn:aws:s3:::allowbucket"},
+					},
+				},
+			},
+		},
+	})
+	api.Simulator.Universe.PutResource(entities.Resource{
+		Arn:       "arn:aws:s3:::allowbucket",
+		Type:      "AWS::S3::Bucket",
+		AccountId: "123456789012",
+	})
+
+	return api
+}
+
+func TestSimRun_Cached(t *testing.T) {
+	api := newTestAPIWithCache(t)
+
+	input := SimInput{
+		Principal: "arn:aws:iam::123456789012:user/adminuser",
+		Action:    "s3:ListBucket",
+		Resource:  "arn:aws:s3:::allowbucket",
+	}
+	body, _ := json.Marshal(input)
+
+	run := func() SimOutput {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/v1/sim", bytes.NewReader(body))
+		api.SimRun(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("SimRun() status = %d, body = %s", w.Code, w.Body.String())
+		}
+
+		var out SimOutput
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("SimRun() invalid JSON: %v", err)
+		}
+		return out
+	}
+
+	if out := run(); out.Result != "ALLOW" {
+		t.Fatalf("SimRun() result = %s, want ALLOW", out.Result)
+	}
+	if out := run(); out.Result != "ALLOW" {
+		t.Fatalf("cached SimRun() result = %s, want ALLOW", out.Result)
+	}
+	if stats := api.Cache.Stats(); stats.Hits != 1 {
+		t.Fatalf("expected 1 cache hit, got %+v", stats)
+	}
+
+	// changing the universe must invalidate the cached result
+	api.Simulator.Universe.RemovePrincipal("arn:aws:iam::123456789012:user/adminuser")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/sim", bytes.NewReader(body))
+	api.SimRun(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("SimRun() after universe change status = %d, want %d",
+			w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestWhich_Cached(t *testing.T) {
+	api := newTestAPIWithCache(t)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{
+			name:    "whichPrincipals",
+			handler: api.WhichPrincipals,
+			body:    `{"action":"s3:ListBucket","resource":"arn:aws:s3:::allowbucket"}`,
+		},
+		{
+			name:    "whichActions",
+			handler: api.WhichActions,
+			body:    `{"principal":"arn:aws:iam::123456789012:user/adminuser","resource":"arn:aws:s3:::allowbucket"}`,
+		},
+		{
+			name:    "whichResources",
+			handler: api.WhichResources,
+			body:    `{"principal":"arn:aws:iam::123456789012:user/adminuser","action":"s3:ListBucket"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := api.Cache.Stats().Hits
+
+			var bodies []string
+			for range 2 {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest("POST", "/api/v1/sim/"+tt.name, strings.NewReader(tt.body))
+				tt.handler(w, req)
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+				}
+				bodies = append(bodies, w.Body.String())
+			}
+
+			if bodies[0] != bodies[1] {
+				t.Errorf("cached response differs: %s != %s", bodies[0], bodies[1])
+			}
+			if hits := api.Cache.Stats().Hits - before; hits != 1 {
+				t.Errorf("expected 1 cache hit, got %d", hits)
+			}
+		})
+	}
+}
+
+func TestSimBatch(t *testing.T) {
+	api := newTestAPIWithCache(t)
+
+	input := SimBatchInput{
+		Simulations: []SimInput{
+			{
+				Principal: "arn:aws:iam::123456789012:user/adminuser",
+				Action:    "s3:ListBucket",
+				Resource:  "arn:aws:s3:::allowbucket",
+			},
+			{
+				Principal: "arn:aws:iam::123456789012:user/testuser",
+				Action:    "s3:ListBucket",
+				Resource:  "arn:aws:s3:::allowbucket",
+			},
+			{
+				Principal: "arn:aws:iam::123456789012:user/adminuser",
+			},
+			{
+				Principal: "arn:aws:iam::123456789012:user/nonexistent",
+				Action:    "s3:ListBucket",
+			},
+			{
+				Principal: "arn:aws:iam::123456789012:user/adminuser",
+				Action:    "s3:ListBucket",
+				Resource:  "arn:aws:s3:::allowbucket",
+			},
+		},
+	}
+	body, _ := json.Marshal(input)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/sim/batch", bytes.NewReader(body))
+	api.SimBatch(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("SimBatch() status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var out SimBatchOutput
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("SimBatch() invalid JSON: %v", err)
+	}
+	if len(out.Results) != len(input.Simulations) {
+		t.Fatalf("expected %d results, got %d", len(input.Simulations), len(out.Results))
+	}
+
+	want := []struct {
+		result string
+		error  bool
+	}{
+		{result: "ALLOW"},
+		{result: "DENY"},
+		{error: true},
+		{error: true},
+		{result: "ALLOW"},
+	}
+	for i, w := range want {
+		got := out.Results[i]
+		if w.error {
+			if got.Error == "" || got.Output != nil {
+				t.Errorf("result %d: expected error, got %+v", i, got)
+			}
+			continue
+		}
+		if got.Error != "" || got.Output == nil || got.Output.Result != w.result {
+			t.Errorf("result %d: expected %s, got %+v", i, w.result, got)
+		}
+	}
+
+	// the duplicate simulation should have been served from the cache
+	if hits := api.Cache.Stats().Hits; hits != 1 {
+		t.Errorf("expected 1 cache hit, got %d", hits)
+	}
+}
+
+func TestSimBatch_Errors(t *testing.T) {
+	api := newTestAPI(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "invalid_json", body: `{not json`},
+		{
+			name: "too_many",
+			body: `{"simulations":[` +
+				strings.Repeat(`{"principal":"p","action":"a"},`, maxSimBatchSize) +
+				`{"principal":"p","action":"a"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/sim/batch", strings.NewReader(tt.body))
+			api.SimBatch(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("SimBatch() status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestSim_OverlayRefs(t *testing.T) {
+	api := newTestAPIWithCache(t)
+	store := overlay.NewCache(overlay.NewMemoryStore(), time.Minute)
+	api.Overlays = store
+	overlays := &OverlayAPI{Store: store}
+
+	// grant testuser access to the bucket via a stored overlay
+	grant := func(action string) []entities.Principal {
+		return []entities.Principal{
+			{
+				Arn:       "arn:aws:iam::123456789012:user/testuser",
+				AccountId: "123456789012",
+				InlinePolicies: []policy.Policy{
+					{
+						Statement: []policy.Statement{
+							{
+								Effect:   policy.EFFECT_ALLOW,
+								Action:   []string{action},
+								Resource: []string{"arn:aws:s3:::allowbucket"},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	o := (&CreateOverlayInput{Name: "grant", Principals: grant("s3:ListBucket")}).Overlay()
+	if err := store.Create(context.Background(), o); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	empty := (&CreateOverlayInput{Name: "empty"}).Overlay()
+	if err := store.Create(context.Background(), empty); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	simulate := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/v1/sim", strings.NewReader(body))
+		api.SimRun(w, req)
+		return w
+	}
+	const base = `"principal":"arn:aws:iam::123456789012:user/testuser",` +
+		`"action":"s3:ListBucket","resource":"arn:aws:s3:::allowbucket"`
+
+	// inline overlay layers which grant and revoke testuser's access, respectively
+	grantJSON, err := json.Marshal(Overlay{Principals: grant("s3:ListBucket")})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	granted := string(grantJSON)
+	const revoked = `{"principals":[` +
+		`{"Arn":"arn:aws:iam::123456789012:user/testuser","AccountId":"123456789012"}]}`
+
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		result string
+	}{
+		{name: "no_overlay", body: `{` + base + `}`, code: 200, result: "DENY"},
+		{name: "overlay_id", body: `{` + base + `,"overlayId":"` + o.ID + `"}`, code: 200, result: "ALLOW"},
+		{
+			name:   "overlay_ids",
+			body:   `{` + base + `,"overlayIds":["` + empty.ID + `","` + o.ID + `"]}`,
+			code:   200,
+			result: "ALLOW",
+		},
+		{
+			name: "inline_overrides_stored",
+			body: `{` + base + `,"overlayId":"` + o.ID + `","overlay":{"principals":[` +
+				`{"Arn":"arn:aws:iam::123456789012:user/testuser","AccountId":"123456789012"}]}}`,
+			code:   200,
+			result: "DENY",
+		},
+		{
+			name:   "layers_later_grants",
+			body:   `{` + base + `,"overlays":[` + revoked + `,` + granted + `]}`,
+			code:   200,
+			result: "ALLOW",
+		},
+		{
+			name:   "layers_later_revokes",
+			body:   `{` + base + `,"overlays":[` + granted + `,` + revoked + `]}`,
+			code:   200,
+			result: "DENY",
+		},
+		{
+			name:   "layers_override_stored",
+			body:   `{` + base + `,"overlayId":"` + o.ID + `","overlays":[` + revoked + `]}`,
+			code:   200,
+			result: "DENY",
+		},
+		{
+			name:   "inline_overrides_layers",
+			body:   `{` + base + `,"overlays":[` + revoked + `],"overlay":` + granted + `}`,
+			code:   200,
+			result: "ALLOW",
+		},
+		{name: "unknown_overlay", body: `{` + base + `,"overlayId":"nonexistent"}`, code: 404},
+		{
+			name: "both_fields",
+			body: `{` + base + `,"overlayId":"` + o.ID + `","overlayIds":["` + o.ID + `"]}`,
+			code: 400,
+		},
+		{name: "empty_id", body: `{` + base + `,"overlayIds":[""]}`, code: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := simulate(tt.body)
+			if w.Code != tt.code {
+				t.Fatalf("SimRun() status = %d, want %d, body = %s", w.Code, tt.code, w.Body.String())
+			}
+			if tt.result == "" {
+				return
+			}
+
+			var out SimOutput
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("SimRun() invalid JSON: %v", err)
+			}
+			if out.Result != tt.result {
+				t.Errorf("SimRun() result = %s, want %s", out.Result, tt.result)
+			}
+		})
+	}
+
+	// updating the stored overlay must invalidate cached results which referenced it
+	body, _ := json.Marshal(UpdateOverlayInput{Principals: grant("s3:DeleteBucket")})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/api/v1/overlays/"+o.ID, bytes.NewReader(body))
+	req.SetPathValue("id", o.ID)
+	overlays.UpdateOverlay(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("UpdateOverlay() status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	w = simulate(`{` + base + `,"overlayId":"` + o.ID + `"}`)
+	if !strings.Contains(w.Body.String(), `"DENY"`) {
+		t.Errorf("expected DENY after overlay update, got %s", w.Body.String())
+	}
+
+	// which* simulations accept the same references
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/v1/sim/whichActions", strings.NewReader(
+		`{"principal":"arn:aws:iam::123456789012:user/testuser",`+
+			`"resource":"arn:aws:s3:::allowbucket","overlayId":"`+o.ID+`"}`))
+	api.WhichActions(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "s3:DeleteBucket") {
+		t.Errorf("WhichActions() = %d, %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/v1/sim/whichPrincipals",
+		strings.NewReader(`{"action":"s3:ListBucket","overlayId":"nonexistent"}`))
+	api.WhichPrincipals(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("WhichPrincipals() status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestSim_OverlayRefsUnsupported(t *testing.T) {
+	api := newTestAPIWithData(t)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/sim", strings.NewReader(
+		`{"principal":"arn:aws:iam::123456789012:user/testuser","action":"s3:ListBucket","overlayId":"x"}`))
+	api.SimRun(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("SimRun() status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,48 @@
+package v1
+
+import "time"
+
+// -------------------------------------------------------------------------------------------------
+// Schemas
+// -------------------------------------------------------------------------------------------------
+
+// StatusOutput describes the contents of the server's universe and the state of its sources
+type StatusOutput struct {
+	Entities   int `json:"entities"`
+	Accounts   int `json:"accounts"`
+	Principals int `json:"principals"`
+	Groups     int `json:"groups"`
+	Policies   int `json:"policies"`
+	Resources  int `json:"resources"`
+	Actions    int `json:"actions"`
+
+	Sources []SourceStatus    `json:"sources"`
+	Cache   *CacheStats       `json:"cache,omitzero"`
+	Env     map[string]string `json:"env,omitzero"`
+}
+
+// SourceStatus describes the load/refresh state of a single source
+type SourceStatus struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+
+	// Updated is when the source was last loaded, and LastChecked when a conditional source was
+	// last found unchanged; a source is only Stale once both are older than two refresh intervals
+	Updated time.Time `json:"updated"`
+	Stale   bool      `json:"stale"`
+	Refresh string    `json:"refresh,omitzero"`
+
+	Conditional bool      `json:"conditional,omitzero"`
+	Version     string    `json:"version,omitzero"`
+	LastChecked time.Time `json:"lastChecked,omitzero"`
+
+	LastAttempt time.Time `json:"lastAttempt,omitzero"`
+	LastError   string    `json:"lastError,omitzero"`
+	Failures    int       `json:"failures,omitzero"`
+}
+
+// HealthcheckOutput reports whether the server is healthy, listing any stale sources if not
+type HealthcheckOutput struct {
+	Status string   `json:"status"`
+	Stale  []string `json:"stale,omitzero"`
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -18,7 +19,9 @@ type WhichActionsInput struct {
 	Resource  string            `json:"resource"`
 	Context   map[string]string `json:"context"`
 
-	Overlay Overlay `json:"overlay"`
+	Overlay  Overlay   `json:"overlay"`
+	Overlays []Overlay `json:"overlays,omitzero"`
+	OverlayRefs
 
 	Fuzzy bool `json:"fuzzy"`
 }
@@ -38,16 +41,42 @@ func (api *API) WhichActions(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	opts := sim.NewOptions(sim.WithAdditionalProperties(input.Context))
-	opts.Overlay = input.Overlay.Universe()
-	opts.EnableFuzzyMatchArn = input.Fuzzy
+	err = input.Validate()
+	if err != nil {
+		httputil.ClientError(w, req, err)
+		return
+	}
 
-	resources, err := api.Simulator.WhichActions(input.Principal, input.Resource, opts)
+	results, _, err := api.WhichActionsFor(req.Context(), input)
 	if err != nil {
-		httputil.ServerError(w, req, fmt.Errorf("simulation error: %v", err))
+		simulationError(w, req, err)
 		return
 	}
 
-	var out []string = resources
+	var out []string = results
 	httputil.WriteJsonResponse(w, req, out)
 }
+
+// Validate checks that the input is well-formed
+func (input *WhichActionsInput) Validate() error {
+	return input.OverlayRefs.Validate()
+}
+
+// WhichActionsFor determines which actions satisfy the input, serving the result from the
+// cache if possible. Also returns whether or not the result came from the cache
+func (api *API) WhichActionsFor(
+	ctx context.Context, input WhichActionsInput) ([]string, bool, error) {
+
+	overlay, err := api.resolveOverlay(ctx, input.OverlayRefs, input.Overlays, input.Overlay)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return cached(api.Cache, "whichActions", overlay.key(input), func() ([]string, error) {
+		opts := sim.NewOptions(sim.WithAdditionalProperties(input.Context))
+		opts.Overlays = overlay.layers
+		opts.EnableFuzzyMatchArn = input.Fuzzy
+
+		return api.Simulator.WhichActionsContext(ctx, input.Principal, input.Resource, opts)
+	}, cloneStrings)
+}
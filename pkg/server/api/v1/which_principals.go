@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -18,7 +19,9 @@ type WhichPrincipalsInput struct {
 	Resource string            `json:"resource"`
 	Context  map[string]string `json:"context"`
 
-	Overlay Overlay `json:"overlay"`
+	Overlay  Overlay   `json:"overlay"`
+	Overlays []Overlay `json:"overlays,omitzero"`
+	OverlayRefs
 
 	Fuzzy bool `json:"fuzzy"`
 }
@@ -38,21 +41,46 @@ func (api *API) WhichPrincipals(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if len(input.Action) == 0 {
-		httputil.ClientError(w, req, fmt.Errorf("missing required field: action"))
+	err = input.Validate()
+	if err != nil {
+		httputil.ClientError(w, req, err)
 		return
 	}
 
-	opts := sim.NewOptions(sim.WithAdditionalProperties(input.Context))
-	opts.Overlay = input.Overlay.Universe()
-	opts.EnableFuzzyMatchArn = input.Fuzzy
-
-	principals, err := api.Simulator.WhichPrincipals(input.Action, input.Resource, opts)
+	results, _, err := api.WhichPrincipalsFor(req.Context(), input)
 	if err != nil {
-		httputil.ServerError(w, req, fmt.Errorf("simulation error: %v", err))
+		simulationError(w, req, err)
 		return
 	}
 
-	var out []string = principals
+	var out []string = results
 	httputil.WriteJsonResponse(w, req, out)
 }
+
+// Validate checks that the input contains the fields required for simulation
+func (input *WhichPrincipalsInput) Validate() error {
+	if len(input.Action) == 0 {
+		return fmt.Errorf("missing required field: action")
+	}
+
+	return input.OverlayRefs.Validate()
+}
+
+// WhichPrincipalsFor determines which principals satisfy the input, serving the result from the
+// cache if possible. Also returns whether or not the result came from the cache
+func (api *API) WhichPrincipalsFor(
+	ctx context.Context, input WhichPrincipalsInput) ([]string, bool, error) {
+
+	overlay, err := api.resolveOverlay(ctx, input.OverlayRefs, input.Overlays, input.Overlay)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return cached(api.Cache, "whichPrincipals", overlay.key(input), func() ([]string, error) {
+		opts := sim.NewOptions(sim.WithAdditionalProperties(input.Context))
+		opts.Overlays = overlay.layers
+		opts.EnableFuzzyMatchArn = input.Fuzzy
+
+		return api.Simulator.WhichPrincipalsContext(ctx, input.Action, input.Resource, opts)
+	}, cloneStrings)
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -18,7 +19,9 @@ type WhichResourcesInput struct {
 	Action    string            `json:"action"`
 	Context   map[string]string `json:"context"`
 
-	Overlay Overlay `json:"overlay"`
+	Overlay  Overlay   `json:"overlay"`
+	Overlays []Overlay `json:"overlays,omitzero"`
+	OverlayRefs
 
 	Fuzzy bool `json:"fuzzy"`
 }
@@ -38,21 +41,46 @@ func (api *API) WhichResources(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if len(input.Principal) == 0 {
-		httputil.ClientError(w, req, fmt.Errorf("missing required field: principal"))
+	err = input.Validate()
+	if err != nil {
+		httputil.ClientError(w, req, err)
 		return
 	}
 
-	opts := sim.NewOptions(sim.WithAdditionalProperties(input.Context))
-	opts.Overlay = input.Overlay.Universe()
-	opts.EnableFuzzyMatchArn = input.Fuzzy
-
-	resources, err := api.Simulator.WhichResources(input.Principal, input.Action, opts)
+	results, _, err := api.WhichResourcesFor(req.Context(), input)
 	if err != nil {
-		httputil.ServerError(w, req, fmt.Errorf("simulation error: %v", err))
+		simulationError(w, req, err)
 		return
 	}
 
-	var out []string = resources
+	var out []string = results
 	httputil.WriteJsonResponse(w, req, out)
 }
+
+// Validate checks that the input contains the fields required for simulation
+func (input *WhichResourcesInput) Validate() error {
+	if len(input.Principal) == 0 {
+		return fmt.Errorf("missing required field: principal")
+	}
+
+	return input.OverlayRefs.Validate()
+}
+
+// WhichResourcesFor determines which resources satisfy the input, serving the result from the
+// cache if possible. Also returns whether or not the result came from the cache
+func (api *API) WhichResourcesFor(
+	ctx context.Context, input WhichResourcesInput) ([]string, bool, error) {
+
+	overlay, err := api.resolveOverlay(ctx, input.OverlayRefs, input.Overlays, input.Overlay)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return cached(api.Cache, "whichResources", overlay.key(input), func() ([]string, error) {
+		opts := sim.NewOptions(sim.WithAdditionalProperties(input.Context))
+		opts.Overlays = overlay.layers
+		opts.EnableFuzzyMatchArn = input.Fuzzy
+
+		return api.Simulator.WhichResourcesContext(ctx, input.Principal, input.Action, opts)
+	}, cloneStrings)
+}
@@ -2,10 +2,29 @@ package server
 
 import (
 	"net/http"
+	"time"
 
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 	"github.com/nsiow/yams/pkg/server/httputil"
 )
 
 func (s *Server) Healthcheck(w http.ResponseWriter, req *http.Request) {
-	httputil.WriteJsonResponse(w, req, map[string]string{"status": "ok"})
+	now := time.Now()
+
+	var stale []string
+	for _, src := range s.Sources {
+		if src.Stale(now) {
+			stale = append(stale, src.Name)
+		}
+	}
+
+	if len(stale) > 0 {
+		httputil.WriteJsonResponseWithStatus(w, req, http.StatusServiceUnavailable, v1.HealthcheckOutput{
+			Status: "stale",
+			Stale:  stale,
+		})
+		return
+	}
+
+	httputil.WriteJsonResponse(w, req, v1.HealthcheckOutput{Status: "ok"})
 }
@@ -39,6 +39,11 @@ func ServerError(w http.ResponseWriter, req *http.Request, err error) {
 
 // TODO(nsiow) implement per-request logging
 func WriteJsonResponse(w http.ResponseWriter, req *http.Request, obj any) {
+	WriteJsonResponseWithStatus(w, req, http.StatusOK, obj)
+}
+
+// WriteJsonResponseWithStatus writes the provided object as JSON using a non-default status code
+func WriteJsonResponseWithStatus(w http.ResponseWriter, req *http.Request, statusCode int, obj any) {
 	jsonBytes, err := json.MarshalIndent(obj, "", "  ")
 	if err != nil {
 		slog.Error("error json-ifying object",
@@ -55,7 +60,7 @@ func WriteJsonResponse(w http.ResponseWriter, req *http.Request, obj any) {
 
 	// Set content type and status before writing body
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(statusCode)
 	_, err = w.Write(append(jsonBytes, '\n'))
 	if err != nil {
 		// Don't try to send an error response - the writer is broken.
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	json "github.com/bytedance/sonic"
+)
+
+// maxFeedBackoff caps the delay between retries when a feed is failing
+const maxFeedBackoff = time.Minute
+
+// Feed delivers notifications about changes to the objects backing sources, allowing sources to be
+// reloaded as soon as they change rather than on a fixed interval
+type Feed interface {
+	// Receive blocks until at least one notification is available (or the feed decides to return
+	// early)
+	Receive(ctx context.Context) ([]Notification, error)
+}
+
+// Notification reports changes to the objects backing sources
+type Notification struct {
+	// Sources lists the changed objects, as smartrw source strings such as s3://bucket/key
+	Sources []string
+
+	// Ack, if set, acknowledges that the changes have been handled so that the notification is not
+	// delivered again. Notifications which are never acknowledged may be redelivered by the feed
+	Ack func(ctx context.Context) error
+}
+
+// Watch reloads any sources named in notifications from the provided feed, until the context is
+// cancelled. Errors receiving from the feed are retried with backoff
+func (serv *Server) Watch(ctx context.Context, feed Feed) {
+	failures := 0
+
+	for ctx.Err() == nil {
+		notifications, err := feed.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			failures++
+			delay := backoff(failures, maxFeedBackoff)
+			slog.Error("error receiving change notifications; will retry",
+				"failures", failures,
+				"retryIn", delay,
+				"error", err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			continue
+		}
+		failures = 0
+
+		// each changed source is reloaded once per batch, and notifications are only acknowledged
+		// once all of their sources have reloaded, so that failed reloads are retried on redelivery
+		results := map[string]error{}
+		for _, notification := range notifications {
+			ok := true
+			for _, changed := range notification.Sources {
+				err, seen := results[changed]
+				if !seen {
+					err = serv.reloadChanged(changed)
+					results[changed] = err
+				}
+				ok = ok && err == nil
+			}
+
+			if !ok || notification.Ack == nil {
+				continue
+			}
+			if err := notification.Ack(ctx); err != nil {
+				slog.Warn("failed to acknowledge change notification",
+					"error", err)
+			}
+		}
+	}
+}
+
+// reloadChanged reloads every source backed by the changed object
+func (serv *Server) reloadChanged(changed string) error {
+	var errs []error
+	for _, src := range serv.Sources {
+		if src.Reader.Source != changed {
+			continue
+		}
+
+		slog.Info("reloading source after change notification",
+			"source", src.Reader.Source)
+		err := serv.Reload(src)
+		if err != nil {
+			slog.Error("error reloading source after change notification; will retry on redelivery",
+				"source", src.Reader.Source,
+				"error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// -------------------------------------------------------------------------------------------------
+// SQS
+// -------------------------------------------------------------------------------------------------
+
+// SQSClient defines the SQS operations used by the feed.
+// This interface enables testing with mocks or SQS-compatible stand-ins.
+type SQSClient interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// SQSFeed is a Feed backed by an SQS (or SQS-compatible) queue receiving S3 event notifications,
+// either directly or wrapped in SNS notifications
+type SQSFeed struct {
+	client   SQSClient
+	queueURL string
+}
+
+// NewSQSFeed creates a new feed reading from the provided queue URL. Queues hosted outside of AWS
+// (such as local SQS-compatible services) are reached via the host of the queue URL
+func NewSQSFeed(queueURL string) (*SQSFeed, error) {
+	parsed, err := url.Parse(queueURL)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid queue url: %s", queueURL)
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if !strings.HasSuffix(parsed.Hostname(), ".amazonaws.com") {
+			o.BaseEndpoint = aws.String(parsed.Scheme + "://" + parsed.Host)
+		}
+	})
+
+	return NewSQSFeedWithClient(client, queueURL), nil
+}
+
+// NewSQSFeedWithClient creates an SQS feed with a custom client.
+// Useful for testing with mocks.
+func NewSQSFeedWithClient(client SQSClient, queueURL string) *SQSFeed {
+	return &SQSFeed{
+		client:   client,
+		queueURL: queueURL,
+	}
+}
+
+// Receive long-polls the queue for S3 event notifications, returning the changed objects. Messages
+// are deleted once acknowledged, leaving unacknowledged ones to be redelivered by SQS after their
+// visibility timeout. Messages which could not be understood are deleted immediately
+func (f *SQSFeed) Receive(ctx context.Context) ([]Notification, error) {
+	result, err := f.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            &f.queueURL,
+		MaxNumberOfMessages: 10,
+		WaitTimeSeconds:     20,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive messages: %w", err)
+	}
+
+	var notifications []Notification
+	for _, msg := range result.Messages {
+		var objects []string
+		if msg.Body != nil {
+			objects, err = parseS3Event(*msg.Body)
+			if err != nil {
+				slog.Warn("ignoring unparseable change notification",
+					"messageId", aws.ToString(msg.MessageId),
+					"error", err)
+				if err := f.delete(ctx, msg); err != nil {
+					slog.Warn("failed to delete change notification",
+						"messageId", aws.ToString(msg.MessageId),
+						"error", err)
+				}
+				continue
+			}
+		}
+
+		notifications = append(notifications, Notification{
+			Sources: objects,
+			Ack: func(ctx context.Context) error {
+				return f.delete(ctx, msg)
+			},
+		})
+	}
+
+	return notifications, nil
+}
+
+// delete removes a message from the queue
+func (f *SQSFeed) delete(ctx context.Context, msg sqstypes.Message) error {
+	_, err := f.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      &f.queueURL,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete message %s: %w", aws.ToString(msg.MessageId), err)
+	}
+	return nil
+}
+
+// s3Event is the subset of the S3 event notification format needed to identify changed objects
+type s3Event struct {
+	// Message is set when the S3 event was delivered via SNS, and contains the S3 event itself
+	Message string `json:"Message"`
+
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// parseS3Event extracts the changed objects from an S3 event notification, in s3://bucket/key form
+func parseS3Event(body string) ([]string, error) {
+	var event s3Event
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return nil, fmt.Errorf("invalid S3 event: %w", err)
+	}
+
+	// unwrap SNS envelopes
+	if event.Message != "" && len(event.Records) == 0 {
+		return parseS3Event(event.Message)
+	}
+
+	var objects []string
+	for _, record := range event.Records {
+		if record.S3.Bucket.Name == "" || record.S3.Object.Key == "" {
+			continue
+		}
+
+		// object keys are URL-encoded in S3 event notifications
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object key in S3 event: %s", record.S3.Object.Key)
+		}
+
+		objects = append(objects, "s3://"+record.S3.Bucket.Name+"/"+key)
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/smartrw"
+)
+
+// mockSQSClient implements SQSClient for testing
+type mockSQSClient struct {
+	messages   []sqstypes.Message
+	receiveErr error
+	deleted    []string
+}
+
+func (m *mockSQSClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if m.receiveErr != nil {
+		return nil, m.receiveErr
+	}
+
+	out := &sqs.ReceiveMessageOutput{Messages: m.messages}
+	m.messages = nil
+	return out, nil
+}
+
+func (m *mockSQSClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	m.deleted = append(m.deleted, aws.ToString(params.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+// chanFeed implements Feed, delivering notifications sent on a channel
+type chanFeed struct {
+	ch  chan []Notification
+	err error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *chanFeed) Receive(ctx context.Context) ([]Notification, error) {
+	f.mu.Lock()
+	f.calls++
+	err := f.err
+	f.err = nil
+	f.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case notifications := <-f.ch:
+		return notifications, nil
+	}
+}
+
+// ackCounter creates notifications for changed sources, counting how many are acknowledged
+type ackCounter struct {
+	mu    sync.Mutex
+	acked map[string]int
+}
+
+func (a *ackCounter) notify(sources ...string) Notification {
+	return Notification{
+		Sources: sources,
+		Ack: func(ctx context.Context) error {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if a.acked == nil {
+				a.acked = map[string]int{}
+			}
+			for _, source := range sources {
+				a.acked[source]++
+			}
+			return nil
+		},
+	}
+}
+
+func (a *ackCounter) count(source string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.acked[source]
+}
+
+func TestParseS3Event(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "direct",
+			body: `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"bucket"},"object":{"key":"path/to/data.jsonl"}}}]}`,
+			want: []string{"s3://bucket/path/to/data.jsonl"},
+		},
+		{
+			name: "multiple",
+			body: `{"Records":[
+				{"s3":{"bucket":{"name":"a"},"object":{"key":"one.json"}}},
+				{"s3":{"bucket":{"name":"b"},"object":{"key":"two.json"}}}
+			]}`,
+			want: []string{"s3://a/one.json", "s3://b/two.json"},
+		},
+		{
+			name: "url_encoded_key",
+			body: `{"Records":[{"s3":{"bucket":{"name":"bucket"},"object":{"key":"my+data%3D1.jsonl"}}}]}`,
+			want: []string{"s3://bucket/my data=1.jsonl"},
+		},
+		{
+			name: "sns_wrapped",
+			body: `{"Type":"Notification","Message":"{\"Records\":[{\"s3\":{\"bucket\":{\"name\":\"bucket\"},\"object\":{\"key\":\"data.json\"}}}]}"}`,
+			want: []string{"s3://bucket/data.json"},
+		},
+		{
+			name: "test_event",
+			body: `{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"bucket"}`,
+			want: nil,
+		},
+		{
+			name: "missing_key",
+			body: `{"Records":[{"s3":{"bucket":{"name":"bucket"},"object":{}}}]}`,
+			want: nil,
+		},
+		{
+			name:    "invalid_json",
+			body:    `not json`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid_key_encoding",
+			body:    `{"Records":[{"s3":{"bucket":{"name":"bucket"},"object":{"key":"bad%zz"}}}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseS3Event(tc.body)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseS3Event() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseS3Event() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSQSFeed_Receive(t *testing.T) {
+	client := &mockSQSClient{
+		messages: []sqstypes.Message{
+			{
+				MessageId:     aws.String("1"),
+				ReceiptHandle: aws.String("r1"),
+				Body:          aws.String(`{"Records":[{"s3":{"bucket":{"name":"bucket"},"object":{"key":"data.json"}}}]}`),
+			},
+			{
+				MessageId:     aws.String("2"),
+				ReceiptHandle: aws.String("r2"),
+				Body:          aws.String(`garbage`),
+			},
+		},
+	}
+
+	feed := NewSQSFeedWithClient(client, "https://sqs.us-east-1.amazonaws.com/123456789012/queue")
+	notifications, err := feed.Receive(context.Background())
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+
+	if len(notifications) != 1 {
+		t.Fatalf("Receive() returned %d notifications, want 1", len(notifications))
+	}
+	want := []string{"s3://bucket/data.json"}
+	if !reflect.DeepEqual(notifications[0].Sources, want) {
+		t.Errorf("Receive() = %v, want %v", notifications[0].Sources, want)
+	}
+
+	// only the unparseable message is deleted before being handled
+	if !reflect.DeepEqual(client.deleted, []string{"r2"}) {
+		t.Errorf("expected only unparseable messages to be deleted, got %v", client.deleted)
+	}
+
+	if err := notifications[0].Ack(context.Background()); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if !reflect.DeepEqual(client.deleted, []string{"r2", "r1"}) {
+		t.Errorf("expected acknowledged message to be deleted, got %v", client.deleted)
+	}
+}
+
+func TestSQSFeed_Receive_Error(t *testing.T) {
+	client := &mockSQSClient{receiveErr: errors.New("boom")}
+	feed := NewSQSFeedWithClient(client, "queue")
+
+	_, err := feed.Receive(context.Background())
+	if err == nil {
+		t.Fatal("expected error from Receive()")
+	}
+}
+
+func TestNewSQSFeed_InvalidURL(t *testing.T) {
+	_, err := NewSQSFeed("not a url")
+	if err == nil {
+		t.Fatal("expected error for invalid queue url")
+	}
+}
+
+func TestServer_Watch(t *testing.T) {
+	oldBase := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = oldBase }()
+
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	testdataPath := filepath.Join(wd, "..", "..", "testdata", "config-loading", "account_valid.json")
+
+	reader, err := smartrw.NewReader(testdataPath)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	src := &Source{Reader: *reader}
+	err = server.AddSource(src)
+	if err != nil {
+		t.Fatalf("AddSource() error = %v", err)
+	}
+
+	src.mu.Lock()
+	initial := src.lastAttempt
+	src.mu.Unlock()
+
+	// first receive fails, to exercise the retry path
+	feed := &chanFeed{ch: make(chan []Notification), err: errors.New("transient")}
+	acks := &ackCounter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.Watch(ctx, feed)
+		close(done)
+	}()
+
+	feed.ch <- []Notification{acks.notify("s3://unrelated/object.json")}
+	feed.ch <- []Notification{acks.notify(testdataPath), acks.notify(testdataPath)}
+
+	// the next send only completes once the previous notification has been handled
+	feed.ch <- nil
+
+	src.mu.Lock()
+	reloaded := src.lastAttempt.After(initial)
+	src.mu.Unlock()
+	if !reloaded {
+		t.Error("expected source to be reloaded after notification")
+	}
+	if got := acks.count(testdataPath); got != 2 {
+		t.Errorf("expected both notifications to be acknowledged, got %d", got)
+	}
+	if got := acks.count("s3://unrelated/object.json"); got != 1 {
+		t.Errorf("expected notification for unrelated object to be acknowledged, got %d", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch() did not return after context was cancelled")
+	}
+
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	if feed.calls < 4 {
+		t.Errorf("expected feed to be retried after error, got %d calls", feed.calls)
+	}
+}
+
+func TestServer_Watch_ReloadError(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(wd, "..", "..", "testdata", "config-loading",
+		"account_valid.json"))
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "account.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	reader, err := smartrw.NewReader(path)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	if err := server.AddSource(&Source{Reader: *reader}); err != nil {
+		t.Fatalf("AddSource() error = %v", err)
+	}
+
+	feed := &chanFeed{ch: make(chan []Notification)}
+	acks := &ackCounter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Watch(ctx, feed)
+
+	// a failed reload leaves the notification to be redelivered
+	if err := os.WriteFile(path, []byte("invalid json"), 0o644); err != nil {
+		t.Fatalf("failed to corrupt source: %v", err)
+	}
+	feed.ch <- []Notification{acks.notify(path)}
+	feed.ch <- nil
+	if got := acks.count(path); got != 0 {
+		t.Errorf("expected failed reload not to be acknowledged, got %d", got)
+	}
+
+	// once the reload succeeds, the redelivered notification is acknowledged
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to restore source: %v", err)
+	}
+	feed.ch <- []Notification{acks.notify(path)}
+	feed.ch <- nil
+	if got := acks.count(path); got != 1 {
+		t.Errorf("expected successful reload to be acknowledged, got %d", got)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nsiow/yams/pkg/server/httputil"
+)
+
+// RefreshSource immediately reloads the named source, regardless of its refresh interval
+// POST /api/v1/sources/{name}/refresh
+func (s *Server) RefreshSource(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+	if name == "" {
+		httputil.ClientError(w, req, fmt.Errorf("missing source name"))
+		return
+	}
+
+	src, ok := s.Source(name)
+	if !ok {
+		httputil.Error(w, req, http.StatusNotFound, fmt.Errorf("unknown source: '%s'", name))
+		return
+	}
+
+	err := s.Reload(src)
+	if err != nil {
+		httputil.ServerError(w, req, fmt.Errorf("error refreshing source '%s': %v", name, err))
+		return
+	}
+
+	httputil.WriteJsonResponse(w, req, src.status(time.Now()))
+}
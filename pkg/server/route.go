@@ -1,61 +1,78 @@
 package server
 
 import (
+	"net/http"
+
 	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 )
 
 func (s *Server) addV1Routes(api *v1.API, overlayAPI *v1.OverlayAPI) {
 	// administration
-	s.mux.HandleFunc("GET /api/v1/healthcheck", s.Healthcheck)
-	s.mux.HandleFunc("GET /api/v1/status", s.Status)
+	s.handle("GET /api/v1/healthcheck", s.Healthcheck)
+	s.handle("GET /api/v1/status", s.Status)
+	s.handle("POST /api/v1/sources/{name}/refresh", s.RefreshSource)
+	s.handle("GET /api/v1/openapi.json", v1.ServeOpenAPI)
 
 	// accounts
-	s.mux.HandleFunc("GET /api/v1/accounts", api.ListAccounts)
-	s.mux.HandleFunc("GET /api/v1/accounts/{key...}", api.GetAccount)
-	s.mux.HandleFunc("GET /api/v1/accounts/search/{search...}", api.SearchAccounts)
+	s.handle("GET /api/v1/accounts", api.ListAccounts)
+	s.handle("GET /api/v1/accounts/{key...}", api.GetAccount)
+	s.handle("GET /api/v1/accounts/search/{search...}", api.SearchAccounts)
 
 	// groups
-	s.mux.HandleFunc("GET /api/v1/groups", api.ListGroups)
-	s.mux.HandleFunc("GET /api/v1/groups/{key...}", api.GetGroup)
-	s.mux.HandleFunc("GET /api/v1/groups/search/{search...}", api.SearchGroups)
+	s.handle("GET /api/v1/groups", api.ListGroups)
+	s.handle("GET /api/v1/groups/{key...}", api.GetGroup)
+	s.handle("GET /api/v1/groups/search/{search...}", api.SearchGroups)
 
 	// policies
-	s.mux.HandleFunc("GET /api/v1/policies", api.ListPolicies)
-	s.mux.HandleFunc("GET /api/v1/policies/{key...}", api.GetPolicy)
-	s.mux.HandleFunc("GET /api/v1/policies/search/{search...}", api.SearchPolicies)
+	s.handle("GET /api/v1/policies", api.ListPolicies)
+	s.handle("GET /api/v1/policies/{key...}", api.GetPolicy)
+	s.handle("GET /api/v1/policies/search/{search...}", api.SearchPolicies)
 
 	// principals
-	s.mux.HandleFunc("GET /api/v1/principals", api.ListPrincipals)
-	s.mux.HandleFunc("GET /api/v1/principals/{key...}", api.GetPrincipal)
-	s.mux.HandleFunc("GET /api/v1/principals/search/{search...}", api.SearchPrincipals)
+	s.handle("GET /api/v1/principals", api.ListPrincipals)
+	s.handle("GET /api/v1/principals/{key...}", api.GetPrincipal)
+	s.handle("GET /api/v1/principals/search/{search...}", api.SearchPrincipals)
 
 	// resources
-	s.mux.HandleFunc("GET /api/v1/resources", api.ListResources)
-	s.mux.HandleFunc("GET /api/v1/resources/{key...}", api.GetResource)
-	s.mux.HandleFunc("GET /api/v1/resources/search/{search...}", api.SearchResources)
+	s.handle("GET /api/v1/resources", api.ListResources)
+	s.handle("GET /api/v1/resources/{key...}", api.GetResource)
+	s.handle("GET /api/v1/resources/search/{search...}", api.SearchResources)
 
 	// actions
-	s.mux.HandleFunc("GET /api/v1/actions", api.ListActions)
-	s.mux.HandleFunc("GET /api/v1/actions/{key...}", api.GetAction)
-	s.mux.HandleFunc("GET /api/v1/actions/search/{search...}", api.SearchActions)
+	s.handle("GET /api/v1/actions", api.ListActions)
+	s.handle("GET /api/v1/actions/{key...}", api.GetAction)
+	s.handle("GET /api/v1/actions/search/{search...}", api.SearchActions)
 
 	// simulation
-	s.mux.HandleFunc("POST /api/v1/sim", api.SimRun)
-	s.mux.HandleFunc("POST /api/v1/sim/whichPrincipals", api.WhichPrincipals)
-	s.mux.HandleFunc("POST /api/v1/sim/whichActions", api.WhichActions)
-	s.mux.HandleFunc("POST /api/v1/sim/whichResources", api.WhichResources)
+	s.handle("POST /api/v1/sim", api.SimRun)
+	s.handle("POST /api/v1/sim/batch", api.SimBatch)
+	s.handle("POST /api/v1/sim/whichPrincipals", api.WhichPrincipals)
+	s.handle("POST /api/v1/sim/whichActions", api.WhichActions)
+	s.handle("POST /api/v1/sim/whichResources", api.WhichResources)
 
 	// utils
-	s.mux.HandleFunc("GET /api/v1/utils/resources/accounts", api.UtilResourceAccounts)
-	s.mux.HandleFunc("GET /api/v1/utils/actions/resourceless", api.UtilResourcelessActions)
-	s.mux.HandleFunc("GET /api/v1/utils/actions/accesslevels", api.UtilActionAccessLevels)
-	s.mux.HandleFunc("GET /api/v1/utils/actions/targeting", api.UtilActionTargeting)
-	s.mux.HandleFunc("GET /api/v1/utils/context", api.UtilSharedContext)
+	s.handle("GET /api/v1/utils/resources/accounts", api.UtilResourceAccounts)
+	s.handle("GET /api/v1/utils/actions/resourceless", api.UtilResourcelessActions)
+	s.handle("GET /api/v1/utils/actions/accesslevels", api.UtilActionAccessLevels)
+	s.handle("GET /api/v1/utils/actions/targeting", api.UtilActionTargeting)
+	s.handle("GET /api/v1/utils/context", api.UtilSharedContext)
 
 	// overlays
-	s.mux.HandleFunc("GET /api/v1/overlays", overlayAPI.ListOverlays)
-	s.mux.HandleFunc("POST /api/v1/overlays", overlayAPI.CreateOverlay)
-	s.mux.HandleFunc("GET /api/v1/overlays/{id}", overlayAPI.GetOverlay)
-	s.mux.HandleFunc("PUT /api/v1/overlays/{id}", overlayAPI.UpdateOverlay)
-	s.mux.HandleFunc("DELETE /api/v1/overlays/{id}", overlayAPI.DeleteOverlay)
+	s.handle("GET /api/v1/overlays", overlayAPI.ListOverlays)
+	s.handle("POST /api/v1/overlays", overlayAPI.CreateOverlay)
+	s.handle("GET /api/v1/overlays/{id}", overlayAPI.GetOverlay)
+	s.handle("PUT /api/v1/overlays/{id}", overlayAPI.UpdateOverlay)
+	s.handle("DELETE /api/v1/overlays/{id}", overlayAPI.DeleteOverlay)
+	s.handle("GET /api/v1/overlays/{id}/revisions", overlayAPI.ListOverlayRevisions)
+	s.handle("GET /api/v1/overlays/{id}/revisions/{revision}", overlayAPI.GetOverlayRevision)
+	s.handle("GET /api/v1/overlays/{id}/diff", overlayAPI.DiffOverlayRevisions)
+	s.handle("GET /api/v1/overlays/{id}/export", overlayAPI.ExportOverlay)
+	s.handle("POST /api/v1/overlays/{id}/rollback", overlayAPI.RollbackOverlay)
+}
+
+// handle registers the handler for the API route, recording it so that the set of registered
+// routes can be checked against the API specification
+func (s *Server) handle(pattern string, handler http.HandlerFunc) {
+	s.routes = append(s.routes, pattern)
+	s.mux.HandleFunc(pattern, handler)
 }
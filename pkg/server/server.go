@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
@@ -9,18 +10,34 @@ import (
 	"github.com/nsiow/yams/internal/middleware"
 	ui "github.com/nsiow/yams/internal/ui"
 	"github.com/nsiow/yams/pkg/overlay"
+	"github.com/nsiow/yams/pkg/server/api/rpc"
 	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 	"github.com/nsiow/yams/pkg/sim"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
 	*http.Server
-	mux *http.ServeMux
+	mux    *http.ServeMux
+	routes []string
+
+	// GRPC is the optional gRPC server, sharing the simulator and overlay store of the HTTP API;
+	// nil unless a gRPC address was configured
+	GRPC *grpc.Server
 
 	Sources      []*Source
 	Simulator    *sim.Simulator
 	OverlayStore overlay.Store
+	ResultCache  *v1.ResultCache
 	Opts         *cli.Flags
+
+	// OnReload, if set, is called after each successful reload of a source, whether scheduled or
+	// manual. It may be called concurrently for different sources
+	OnReload func(*Source)
+
+	// Snapshots causes each source to retain the universe of its last successful load, so that
+	// [Server.Snapshot] can rebuild the universe from scratch. Must be set before adding sources
+	Snapshots bool
 }
 
 func NewServer(opts *cli.Flags) (*Server, error) {
@@ -51,24 +68,51 @@ func NewServer(opts *cli.Flags) (*Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to create overlay store: %w", err)
 	}
-	server.OverlayStore = overlayStore
+	overlayCache := overlay.NewCache(overlayStore, time.Duration(opts.OverlayTTL)*time.Second)
+	server.OverlayStore = overlayCache
+
+	// Create simulation result cache
+	if opts.CacheSize > 0 {
+		server.ResultCache = v1.NewResultCache(opts.CacheSize, server.Simulator.Universe.Generation)
+	}
+
+	api := &v1.API{
+		Simulator:     server.Simulator,
+		SharedContext: map[string]string(opts.SharedContext),
+		Cache:         server.ResultCache,
+		Overlays:      overlayCache,
+	}
+	overlayAPI := &v1.OverlayAPI{Store: server.OverlayStore, Base: server.Simulator.Universe}
 
 	// routes routes routes
-	server.addV1Routes(
-		&v1.API{
-			Simulator:     server.Simulator,
-			SharedContext: map[string]string(opts.SharedContext),
-		},
-		&v1.OverlayAPI{Store: server.OverlayStore},
-	)
+	server.addV1Routes(api, overlayAPI)
 	mux.Handle("/ui/", ui.Handler())
 	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/ui/", http.StatusFound)
 	})
 
+	// Create gRPC server, if requested
+	if opts.GrpcAddr != "" {
+		server.GRPC = rpc.NewServer(api, overlayAPI)
+	}
+
 	return &server, nil
 }
 
+// ListenAndServeGRPC listens on the configured gRPC address and serves the gRPC API
+func (s *Server) ListenAndServeGRPC() error {
+	if s.GRPC == nil {
+		return fmt.Errorf("gRPC server is not configured")
+	}
+
+	lis, err := net.Listen("tcp", s.Opts.GrpcAddr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s: %w", s.Opts.GrpcAddr, err)
+	}
+
+	return s.GRPC.Serve(lis)
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
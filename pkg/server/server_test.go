@@ -4,12 +4,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
 	json "github.com/bytedance/sonic"
 	"github.com/nsiow/yams/cmd/yams/cli"
 	"github.com/nsiow/yams/internal/smartrw"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 )
 
 func TestNewServer(t *testing.T) {
@@ -39,6 +42,38 @@ func TestNewServer(t *testing.T) {
 	}
 }
 
+func TestNewServer_GRPC(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	if server.GRPC != nil {
+		t.Fatal("expected gRPC server to be disabled by default")
+	}
+	if err := server.ListenAndServeGRPC(); err == nil {
+		t.Fatal("expected error serving gRPC without an address")
+	}
+
+	server, err = NewServer(&cli.Flags{Addr: ":8080", GrpcAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	if server.GRPC == nil {
+		t.Fatal("expected gRPC server to be created")
+	}
+	if _, ok := server.GRPC.GetServiceInfo()["yams.v1.Yams"]; !ok {
+		t.Fatalf("expected yams service to be registered, got %v", server.GRPC.GetServiceInfo())
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServeGRPC() }()
+	time.Sleep(50 * time.Millisecond)
+	server.GRPC.Stop()
+	if err := <-errs; err != nil {
+		t.Fatalf("ListenAndServeGRPC() error = %v", err)
+	}
+}
+
 func TestHealthcheck(t *testing.T) {
 	server, err := NewServer(&cli.Flags{Addr: ":8080"})
 	if err != nil {
@@ -143,6 +178,7 @@ func TestServer_IntegrationRoutes(t *testing.T) {
 		{"GET", "/api/v1/principals", http.StatusOK},
 		{"GET", "/api/v1/resources", http.StatusOK},
 		{"GET", "/api/v1/actions", http.StatusOK},
+		{"GET", "/api/v1/openapi.json", http.StatusOK},
 	}
 
 	for _, tt := range tests {
@@ -284,3 +320,143 @@ func TestStatus_WithEmptyEnvVar(t *testing.T) {
 		t.Error("Status() should not include env field when all env vars are empty")
 	}
 }
+
+func TestHealthcheck_Stale(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	server.Sources = append(server.Sources, &Source{
+		Name:    "stale.json",
+		Reader:  smartrw.Reader{Source: "stale.json"},
+		Refresh: time.Minute,
+		Updated: time.Now().Add(-time.Hour),
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/healthcheck", nil)
+
+	server.Healthcheck(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Healthcheck() status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Healthcheck() produced invalid JSON: %v", err)
+	}
+	if body["status"] != "stale" {
+		t.Errorf("Healthcheck() status field = %v, want 'stale'", body["status"])
+	}
+}
+
+func TestStatus_StaleSource(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	src := &Source{
+		Name:    "stale.json",
+		Reader:  smartrw.Reader{Source: "stale.json"},
+		Refresh: time.Minute,
+		Updated: time.Now().Add(-time.Hour),
+	}
+	src.record(time.Now(), os.ErrNotExist)
+	server.Sources = append(server.Sources, src)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/status", nil)
+
+	server.Status(w, req)
+
+	var status map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Status() produced invalid JSON: %v", err)
+	}
+
+	sources := status["sources"].([]any)
+	got := sources[0].(map[string]any)
+	if got["stale"] != true {
+		t.Errorf("Status() source stale = %v, want true", got["stale"])
+	}
+	if got["lastError"] != os.ErrNotExist.Error() {
+		t.Errorf("Status() source lastError = %v, want %q", got["lastError"], os.ErrNotExist.Error())
+	}
+}
+
+func TestRefreshSource(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	tempFile := filepath.Join(t.TempDir(), "accounts.json")
+	validJSON := `[{"resourceType": "Yams::Organizations::Account", "accountId": "123456789012", "arn": "arn:aws:::account/123456789012"}]`
+	if err := os.WriteFile(tempFile, []byte(validJSON), 0644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	reader, err := smartrw.NewReader(tempFile)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	if err := server.AddSource(&Source{Reader: *reader}); err != nil {
+		t.Fatalf("AddSource() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		corrupt bool
+		want    int
+	}{
+		{"success", "accounts.json", false, http.StatusOK},
+		{"unknown_source", "missing.json", false, http.StatusNotFound},
+		{"load_error", "accounts.json", true, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.corrupt {
+				if err := os.WriteFile(tempFile, []byte("invalid json"), 0644); err != nil {
+					t.Fatalf("failed to corrupt test file: %v", err)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/sources/"+tt.source+"/refresh", nil)
+
+			server.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("RefreshSource() status = %d, want %d, body = %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_RoutesMatchSpec(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	var documented []string
+	for _, route := range v1.Routes {
+		documented = append(documented, route.String())
+	}
+
+	for _, route := range server.routes {
+		if !slices.Contains(documented, route) {
+			t.Errorf("route %q is registered but missing from the API specification", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(server.routes, route) {
+			t.Errorf("route %q is in the API specification but not registered", route)
+		}
+	}
+}
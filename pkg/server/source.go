@@ -3,7 +3,9 @@ package server
 import (
 	"fmt"
 	"log/slog"
-	"strings"
+	"math/rand/v2"
+	"path"
+	"sync"
 	"time"
 
 	"github.com/nsiow/yams/internal/smartrw"
@@ -11,25 +13,59 @@ import (
 	"github.com/nsiow/yams/pkg/loaders/awsconfig"
 )
 
+// retryBaseDelay is the initial delay used when retrying a failed refresh; subsequent failures
+// back off exponentially from here, capped at the source's refresh interval
+var retryBaseDelay = time.Second
+
+// staleAfterIntervals is the number of refresh intervals that may pass without a successful load or
+// check before a source is considered stale
+const staleAfterIntervals = 2
+
 type Source struct {
+	// Name is the short identifier of the source, used for manual refreshes. Defaults to the base
+	// name of the reader's source, suffixed with a counter when another source already uses it
+	Name    string
 	Reader  smartrw.Reader
 	Refresh time.Duration
+
+	// Updated is the time of the last successful load of the source
 	Updated time.Time
+
+	// Conditional causes scheduled refreshes to first check the version (S3 ETag or file mtime) of
+	// the source, only reloading when it has changed since the last successful load
+	Conditional bool
+
+	// CacheDir, if set, is a directory in which the universe loaded from the source is cached
+	// between processes, keyed by the source and its version; see [Source.Universe]
+	CacheDir string
+
+	// mu guards the refresh state below, as well as Updated. lastChecked is the time of the last
+	// version check which found a conditional source unchanged, without reloading it; universe is
+	// the last universe loaded from the source, if retained for snapshots
+	mu          sync.Mutex
+	lastAttempt time.Time
+	lastChecked time.Time
+	lastError   error
+	failures    int
+	version     string
+	universe    *entities.Universe
+
+	// reloadMu serializes reloads of the same source, since the reader is not safe for concurrent use
+	reloadMu sync.Mutex
 }
 
+// Universe loads the entities of the source. If the source has a CacheDir and its version can be
+// determined, a cached copy of the universe for that version is used instead where available
 func (s *Source) Universe() (*entities.Universe, error) {
-	loader := awsconfig.NewLoader()
-	var err error
-
-	// TODO(nsiow) implement this more accurately, allow non-Config sources
-	if strings.Contains(s.Reader.Source, ".jsonl") {
-		err = loader.LoadJsonl(s.Reader)
-	} else if strings.Contains(s.Reader.Source, ".json") {
-		err = loader.LoadJson(s.Reader)
-	} else {
-		return nil, fmt.Errorf("unsure what loader to use for source: %s", s.Reader.Source)
+	if s.CacheDir != "" {
+		return s.cachedUniverse()
 	}
+	return s.load()
+}
 
+// load reads and decodes the entities of the source
+func (s *Source) load() (*entities.Universe, error) {
+	uv, err := awsconfig.LoadSource(s.Reader.Source, s.Reader)
 	if err != nil {
 		return nil, err
 	}
@@ -39,17 +75,147 @@ func (s *Source) Universe() (*entities.Universe, error) {
 		return nil, err
 	}
 
-	return loader.Universe(), nil
+	return uv, nil
+}
+
+// Stale returns whether the source has gone too long without a successful load or a check finding
+// it unchanged, relative to its refresh interval. Sources without a refresh interval are never
+// stale
+func (s *Source) Stale(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stale(now)
+}
+
+// stale is the internal unlocked version of Stale
+func (s *Source) stale(now time.Time) bool {
+	if s.Refresh <= 0 {
+		return false
+	}
+
+	current := s.Updated
+	if s.lastChecked.After(current) {
+		current = s.lastChecked
+	}
+	return now.Sub(current) > staleAfterIntervals*s.Refresh
+}
+
+// record saves the outcome of a load attempt
+func (s *Source) record(attempt time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastAttempt = attempt
+	s.lastError = err
+	if err != nil {
+		s.failures++
+	} else {
+		s.failures = 0
+		s.Updated = attempt
+	}
+}
+
+// checked records that the source's version was checked and found unchanged. Updated is left as
+// the time of the last load, since nothing was reloaded
+func (s *Source) checked(at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastChecked = at
+	s.lastError = nil
+	s.failures = 0
+}
+
+// Version returns the version of the source as of its last successful load, if known
+func (s *Source) Version() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.version
+}
+
+// setVersion saves the version of the source corresponding to a successful load
+func (s *Source) setVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.version = version
+}
+
+// retain saves the universe of a successful load, for use by snapshots
+func (s *Source) retain(uv *entities.Universe) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.universe = uv
+}
+
+// retained returns the universe of the last successful load, if retained
+func (s *Source) retained() *entities.Universe {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.universe
+}
+
+// Source looks up a previously-added source by its name
+func (serv *Server) Source(name string) (*Source, bool) {
+	for _, src := range serv.Sources {
+		if src.Name == name {
+			return src, true
+		}
+	}
+
+	return nil, false
+}
+
+// sourceName picks a name for the source which no other source uses. Explicit names must already be
+// unique, while default names are suffixed with -2, -3, ... until they are
+func (serv *Server) sourceName(src *Source) (string, error) {
+	if src.Name != "" {
+		if _, taken := serv.Source(src.Name); taken {
+			return "", fmt.Errorf("duplicate source name: '%s'", src.Name)
+		}
+		return src.Name, nil
+	}
+
+	base := path.Base(src.Reader.Source)
+	name := base
+	for i := 2; ; i++ {
+		if _, taken := serv.Source(name); !taken {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
 }
 
 func (serv *Server) AddSource(src *Source) error {
+	name, err := serv.sourceName(src)
+	if err != nil {
+		return err
+	}
+	src.Name = name
+
 	slog.Info("initial loading of source",
 		"source", src.Reader.Source)
 
-	err := serv.Load(src)
+	// capture the version before loading, so that changes made during the load are not missed
+	var version string
+	if src.Conditional {
+		version, err = src.Reader.Version()
+		if err != nil {
+			slog.Warn("unable to determine version of source; will reload unconditionally",
+				"source", src.Reader.Source,
+				"error", err)
+		}
+	}
+
+	err = serv.Load(src)
 	if err != nil {
 		return err
 	}
+	src.setVersion(version)
 
 	serv.Sources = append(serv.Sources, src)
 
@@ -67,11 +233,13 @@ func (serv *Server) Load(src *Source) error {
 	slog.Info("loading source",
 		"source", src.Reader.Source)
 
+	attempt := time.Now()
 	uv, err := src.Universe()
 	if err != nil {
 		slog.Error("error loading source",
-			"source", src,
-			"universe", uv)
+			"source", src.Reader.Source,
+			"error", err)
+		src.record(attempt, err)
 		return err
 	}
 
@@ -79,7 +247,10 @@ func (serv *Server) Load(src *Source) error {
 		"numLoaded", uv.Size())
 
 	serv.Simulator.Universe.Merge(uv)
-	src.Updated = time.Now()
+	if serv.Snapshots {
+		src.retain(uv)
+	}
+	src.record(attempt, nil)
 
 	slog.Info("universe after loading",
 		"size", serv.Simulator.Universe.Size())
@@ -87,31 +258,115 @@ func (serv *Server) Load(src *Source) error {
 	return nil
 }
 
-func (serv *Server) Refresh(src *Source) {
-	ticker := time.NewTicker(src.Refresh)
-	defer ticker.Stop()
+// Snapshot builds a new universe from the last successful load of every source, in the order they
+// were added. Since loads are merged into the simulator's universe, entities deleted from a source
+// remain there after a reload; they are absent from a snapshot. Requires Snapshots to be set
+func (serv *Server) Snapshot() *entities.Universe {
+	uv := entities.NewUniverse()
+	uv.LoadBasePolicies()
+	for _, src := range serv.Sources {
+		if retained := src.retained(); retained != nil {
+			uv.Merge(retained)
+		}
+	}
 
-	for tick := range ticker.C {
-		slog.Info("refreshing source",
-			"tick", tick,
-			"source", src.Reader.Source)
+	return uv
+}
+
+// Reload resets the source's reader and loads it again
+func (serv *Server) Reload(src *Source) error {
+	_, err := serv.reload(src, true)
+	return err
+}
 
-		err := src.Reader.Reset()
+// reload resets the source's reader and loads it again. Unless forced, conditional sources are only
+// reloaded if their version has changed since the last successful load. Returns whether or not the
+// source was actually reloaded
+func (serv *Server) reload(src *Source, force bool) (bool, error) {
+	src.reloadMu.Lock()
+	defer src.reloadMu.Unlock()
+
+	var version string
+	if src.Conditional {
+		var err error
+		version, err = src.Reader.Version()
 		if err != nil {
-			slog.Error("error resetting source",
-				"tick", tick,
-				"source", src.Reader.Source,
-				"error", err)
-			return
+			src.record(time.Now(), err)
+			return false, fmt.Errorf("error checking source version: %w", err)
 		}
 
-		err = serv.Load(src)
-		if err != nil {
-			slog.Error("error refreshing source",
-				"tick", tick,
+		if !force && version != "" && version == src.Version() {
+			slog.Debug("source unchanged; skipping reload",
 				"source", src.Reader.Source,
-				"error", err)
-			return
+				"version", version)
+			src.checked(time.Now())
+			return false, nil
+		}
+	}
+
+	err := src.Reader.Reset()
+	if err != nil {
+		src.record(time.Now(), err)
+		return false, fmt.Errorf("error resetting source: %w", err)
+	}
+
+	err = serv.Load(src)
+	if err != nil {
+		return false, err
+	}
+
+	src.setVersion(version)
+	if serv.OnReload != nil {
+		serv.OnReload(src)
+	}
+	return true, nil
+}
+
+// Refresh reloads the source forever on its refresh interval. Failed reloads are retried with
+// exponential backoff and jitter, so that transient errors do not stop future refreshes.
+// Conditional sources are only reloaded when their content has changed
+func (serv *Server) Refresh(src *Source) {
+	delay := src.Refresh
+	failures := 0
+
+	for {
+		time.Sleep(delay)
+
+		slog.Info("refreshing source",
+			"source", src.Reader.Source)
+
+		_, err := serv.reload(src, false)
+		if err == nil {
+			failures = 0
+			delay = src.Refresh
+			continue
 		}
+
+		failures++
+		delay = backoff(failures, src.Refresh)
+		slog.Error("error refreshing source; will retry",
+			"source", src.Reader.Source,
+			"failures", failures,
+			"retryIn", delay,
+			"error", err)
+	}
+}
+
+// backoff computes the delay before retry number `attempt` (starting at 1), doubling from
+// retryBaseDelay up to `max`, with jitter applied to spread out retries
+func backoff(attempt int, max time.Duration) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	// equal jitter: wait somewhere between half and all of the computed delay
+	half := delay / 2
+	if half <= 0 {
+		return delay
 	}
+	return half + rand.N(delay-half+1)
 }
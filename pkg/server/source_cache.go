@@ -0,0 +1,201 @@
+package server
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/loaders/awsconfig"
+)
+
+// sourceCacheFormat is included in cache keys, so that changes to the cached representation
+// invalidate existing entries
+const sourceCacheFormat = "1"
+
+// sourceCacheMaxBytes is the total size to which a cache directory is trimmed after each write, by
+// evicting its least recently used entries
+var sourceCacheMaxBytes int64 = 1 << 30
+
+// cachedUniverse returns the universe of the source from its cache directory, loading and caching
+// it if no entry exists for the current version of the source
+func (s *Source) cachedUniverse() (*entities.Universe, error) {
+	version, err := s.Reader.Version()
+	if err != nil || version == "" {
+		slog.Warn("unable to determine version of source; not caching",
+			"source", s.Reader.Source,
+			"error", err)
+		return s.load()
+	}
+
+	path := filepath.Join(s.CacheDir, s.cacheKey(version)+".json")
+	uv, err := readCachedUniverse(path)
+	switch {
+	case err == nil:
+		slog.Info("loaded source from cache",
+			"source", s.Reader.Source,
+			"cache", path)
+
+		// mark the entry as recently used, so that it is evicted last
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return uv, s.Reader.Close()
+	case !errors.Is(err, fs.ErrNotExist):
+		slog.Warn("ignoring unreadable cache entry",
+			"cache", path,
+			"error", err)
+	}
+
+	uv, err = s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeCachedUniverse(path, uv); err != nil {
+		slog.Warn("unable to cache source",
+			"source", s.Reader.Source,
+			"cache", path,
+			"error", err)
+		return uv, nil
+	}
+
+	if err := pruneCache(s.CacheDir, path, s.cachePrefix()); err != nil {
+		slog.Warn("unable to prune source cache",
+			"cache", s.CacheDir,
+			"error", err)
+	}
+	return uv, nil
+}
+
+// cacheKey identifies the cache entry for a version of the source. Keys of every version of the
+// same source share the prefix returned by cachePrefix, so that superseded versions can be found
+func (s *Source) cacheKey(version string) string {
+	return s.cachePrefix() + "-" + hashParts(version)
+}
+
+// cachePrefix identifies the source within cache keys. Local paths are made absolute so that the
+// same file is shared between working directories, and the org prefix is included since it
+// changes how sources are loaded
+func (s *Source) cachePrefix() string {
+	src := s.Reader.Source
+	if !strings.Contains(src, "://") {
+		if abs, err := filepath.Abs(src); err == nil {
+			src = abs
+		}
+	}
+
+	return hashParts(sourceCacheFormat, src, awsconfig.OrgPrefix)
+}
+
+// hashParts returns a hex-encoded hash of the provided strings
+func hashParts(parts ...string) string {
+	h := sha1.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// pruneCache removes the entries of a cache directory which belong to superseded versions of the
+// source with the provided prefix, then evicts the least recently used entries other than `keep`
+// until the directory fits within sourceCacheMaxBytes
+func pruneCache(dir, keep, prefix string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		path string
+		size int64
+		used time.Time
+	}
+
+	var entries []entry
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if path != keep && strings.HasPrefix(filepath.Base(path), prefix+"-") {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+
+		entries = append(entries, entry{path: path, size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+	}
+
+	// oldest first
+	slices.SortFunc(entries, func(a, b entry) int { return a.used.Compare(b.used) })
+	for _, e := range entries {
+		if total <= sourceCacheMaxBytes {
+			break
+		}
+		if e.path == keep {
+			continue
+		}
+
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		total -= e.size
+	}
+	return nil
+}
+
+// readCachedUniverse decodes a universe previously written by writeCachedUniverse
+func readCachedUniverse(path string) (*entities.Universe, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data entities.OverlayData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("invalid cache entry: %w", err)
+	}
+	return entities.FromData(data).Universe, nil
+}
+
+// writeCachedUniverse encodes a universe to the provided path, replacing it atomically so that
+// concurrent processes never read a partial entry
+func writeCachedUniverse(path string, uv *entities.Universe) error {
+	b, err := json.Marshal((&entities.Overlay{Universe: uv}).ToData())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
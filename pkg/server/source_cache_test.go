@@ -0,0 +1,143 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nsiow/yams/internal/smartrw"
+)
+
+// cachedSource returns a source for the provided file, cached in the provided directory
+func cachedSource(t *testing.T, path, cacheDir string) *Source {
+	t.Helper()
+
+	reader, err := smartrw.NewReader(path)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	return &Source{Reader: *reader, CacheDir: cacheDir}
+}
+
+// cacheEntries returns the entries written to a cache directory
+func cacheEntries(t *testing.T, cacheDir string) []string {
+	t.Helper()
+
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if err != nil {
+		t.Fatalf("failed to list cache entries: %v", err)
+	}
+	return entries
+}
+
+func TestSource_Universe_Cache(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	b, err := os.ReadFile(
+		filepath.Join(wd, "..", "..", "testdata", "config-loading", "account_valid.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+
+	// copy the source, so that its version can be changed
+	path := filepath.Join(t.TempDir(), "source.jsonl")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	want, err := cachedSource(t, path, "").Universe()
+	if err != nil {
+		t.Fatalf("Universe() error = %v", err)
+	}
+
+	// first load populates the cache
+	uv, err := cachedSource(t, path, cacheDir).Universe()
+	if err != nil {
+		t.Fatalf("Universe() error = %v", err)
+	}
+	if uv.Size() != want.Size() {
+		t.Fatalf("wanted %d entities, got %d", want.Size(), uv.Size())
+	}
+	entries := cacheEntries(t, cacheDir)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 cache entry, got %v", entries)
+	}
+
+	// second load is served from the cache
+	uv, err = cachedSource(t, path, cacheDir).Universe()
+	if err != nil {
+		t.Fatalf("Universe() error = %v", err)
+	}
+	if uv.Size() != want.Size() {
+		t.Fatalf("wanted %d cached entities, got %d", want.Size(), uv.Size())
+	}
+	for a := range want.Accounts() {
+		if !uv.HasAccount(a.Id) {
+			t.Errorf("expected cached universe to contain account %s", a.Id)
+		}
+	}
+
+	// unreadable entries are ignored and replaced
+	if err := os.WriteFile(entries[0], []byte("{not json"), 0644); err != nil {
+		t.Fatalf("failed to corrupt cache entry: %v", err)
+	}
+	uv, err = cachedSource(t, path, cacheDir).Universe()
+	if err != nil {
+		t.Fatalf("Universe() with corrupt cache error = %v", err)
+	}
+	if uv.Size() != want.Size() {
+		t.Fatalf("wanted %d entities after corrupt cache, got %d", want.Size(), uv.Size())
+	}
+
+	// a new version of the source replaces the entry of the old one
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("failed to touch source: %v", err)
+	}
+	if _, err := cachedSource(t, path, cacheDir).Universe(); err != nil {
+		t.Fatalf("Universe() error = %v", err)
+	}
+	newEntries := cacheEntries(t, cacheDir)
+	if len(newEntries) != 1 || newEntries[0] == entries[0] {
+		t.Fatalf("expected old cache entry %s to be replaced, got %v", entries[0], newEntries)
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	defer func(old int64) { sourceCacheMaxBytes = old }(sourceCacheMaxBytes)
+	sourceCacheMaxBytes = 30
+
+	// each entry is 10 bytes, with later entries more recently used
+	dir := t.TempDir()
+	names := []string{"a-1.json", "a-2.json", "b-1.json", "c-1.json", "d-1.json"}
+	base := time.Now().Add(-time.Hour)
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+			t.Fatalf("failed to write entry: %v", err)
+		}
+		used := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, used, used); err != nil {
+			t.Fatalf("failed to touch entry: %v", err)
+		}
+	}
+
+	// a-1 is superseded by a-2, and b-1 is the least recently used of those remaining
+	if err := pruneCache(dir, filepath.Join(dir, "a-2.json"), "a"); err != nil {
+		t.Fatalf("pruneCache() error = %v", err)
+	}
+
+	var got []string
+	for _, path := range cacheEntries(t, dir) {
+		got = append(got, filepath.Base(path))
+	}
+	want := []string{"a-2.json", "c-1.json", "d-1.json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted remaining entries %v, got %v", want, got)
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -260,6 +261,65 @@ func TestServer_AddSource_Invalid(t *testing.T) {
 	}
 }
 
+func TestServer_AddSource_UniqueNames(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "config-loading",
+		"account_valid.json"))
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+
+	// the same base name in different directories
+	var paths []string
+	for _, dir := range []string{"a", "b", "c"} {
+		path := filepath.Join(t.TempDir(), dir, "data.json")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("failed to write source: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	add := func(path, name string) error {
+		reader, err := smartrw.NewReader(path)
+		if err != nil {
+			t.Fatalf("failed to create reader: %v", err)
+		}
+		return server.AddSource(&Source{Name: name, Reader: *reader})
+	}
+
+	for _, path := range paths {
+		if err := add(path, ""); err != nil {
+			t.Fatalf("AddSource() error = %v", err)
+		}
+	}
+
+	want := []string{"data.json", "data.json-2", "data.json-3"}
+	for i, src := range server.Sources {
+		if src.Name != want[i] {
+			t.Errorf("source %d name = %q, want %q", i, src.Name, want[i])
+		}
+		if got, _ := server.Source(want[i]); got != src {
+			t.Errorf("Source(%q) did not return source %d", want[i], i)
+		}
+	}
+
+	// explicit names are never renamed
+	if err := add(paths[0], "data.json-2"); err == nil {
+		t.Error("AddSource() with a duplicate explicit name should return error")
+	}
+	if len(server.Sources) != 3 {
+		t.Errorf("AddSource() with duplicate name added a source, got %d sources",
+			len(server.Sources))
+	}
+}
+
 func TestServer_Refresh_Success(t *testing.T) {
 	server, err := NewServer(&cli.Flags{Addr: ":8080"})
 	if err != nil {
@@ -297,8 +357,8 @@ func TestServer_Refresh_Success(t *testing.T) {
 	// Wait for at least one refresh cycle
 	time.Sleep(100 * time.Millisecond)
 
-	// Check that refresh updated the timestamp
-	if !src.Updated.After(initialUpdate) {
+	// Check that refresh updated the timestamp, reading it under the lock held by the refresh
+	if !src.status(time.Now()).Updated.After(initialUpdate) {
 		t.Error("Refresh() did not update source timestamp")
 	}
 }
@@ -336,14 +396,31 @@ func TestServer_Refresh_ResetError(t *testing.T) {
 	// Delete the file to cause Reset() to fail
 	os.Remove(tempFile)
 
-	// Start refresh - should exit after first failed reset
+	// Start refresh - should keep retrying after the failed reset
 	go server.Refresh(src)
 
 	// Wait for the refresh to attempt and fail
 	time.Sleep(100 * time.Millisecond)
 
-	// The goroutine should have exited due to reset error
-	// (no way to verify directly, but coverage will show the path was taken)
+	status := src.status(time.Now())
+	if status.LastError == "" {
+		t.Fatal("Refresh() did not record reset error")
+	}
+
+	// Restore the file; the next retry should succeed
+	if err := os.WriteFile(tempFile, []byte(validJSON), 0644); err != nil {
+		t.Fatalf("failed to restore test file: %v", err)
+	}
+	lastFailure := time.Now()
+	time.Sleep(150 * time.Millisecond)
+
+	status = src.status(time.Now())
+	if status.LastError != "" {
+		t.Errorf("Refresh() did not recover after reset error: %v", status.LastError)
+	}
+	if !status.Updated.After(lastFailure) {
+		t.Error("Refresh() did not update source timestamp after recovering")
+	}
 }
 
 func TestServer_Refresh_LoadError(t *testing.T) {
@@ -381,13 +458,30 @@ func TestServer_Refresh_LoadError(t *testing.T) {
 		t.Fatalf("failed to corrupt test file: %v", err)
 	}
 
-	// Start refresh - should exit after first failed load
+	// Start refresh - should keep retrying after the failed load
 	go server.Refresh(src)
 
 	// Wait for the refresh to attempt and fail
 	time.Sleep(100 * time.Millisecond)
 
-	// The goroutine should have exited due to load error
+	status := src.status(time.Now())
+	if status.LastError == "" {
+		t.Fatal("Refresh() did not record load error")
+	}
+	if status.Failures < 1 {
+		t.Errorf("Refresh() failures = %v, want >= 1", status.Failures)
+	}
+
+	// Fix the file; the next retry should succeed
+	if err := os.WriteFile(tempFile, []byte(validJSON), 0644); err != nil {
+		t.Fatalf("failed to restore test file: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	status = src.status(time.Now())
+	if status.LastError != "" {
+		t.Errorf("Refresh() did not recover after load error: %v", status.LastError)
+	}
 }
 
 func TestSource_Universe_LoadJsonlError(t *testing.T) {
@@ -413,3 +507,284 @@ func TestSource_Universe_LoadJsonlError(t *testing.T) {
 		t.Error("Universe() with invalid jsonl should return error")
 	}
 }
+
+func TestSource_Stale(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		refresh time.Duration
+		updated time.Time
+		checked time.Time
+		want    bool
+	}{
+		{"no_refresh", 0, now.Add(-24 * time.Hour), time.Time{}, false},
+		{"fresh", time.Minute, now.Add(-30 * time.Second), time.Time{}, false},
+		{"missed_one_refresh", time.Minute, now.Add(-90 * time.Second), time.Time{}, false},
+		{"missed_two_refreshes", time.Minute, now.Add(-3 * time.Minute), time.Time{}, true},
+		{"checked_unchanged", time.Minute, now.Add(-time.Hour), now.Add(-30 * time.Second), false},
+		{"checked_long_ago", time.Minute, now.Add(-time.Hour), now.Add(-3 * time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &Source{Refresh: tt.refresh, Updated: tt.updated, lastChecked: tt.checked}
+			if got := src.Stale(now); got != tt.want {
+				t.Errorf("Stale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	max := 10 * time.Second
+
+	tests := []struct {
+		attempt int
+		low     time.Duration
+		high    time.Duration
+	}{
+		{1, retryBaseDelay / 2, retryBaseDelay},
+		{2, retryBaseDelay, 2 * retryBaseDelay},
+		{3, 2 * retryBaseDelay, 4 * retryBaseDelay},
+		{10, max / 2, max},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			got := backoff(tt.attempt, max)
+			if got < tt.low || got > tt.high {
+				t.Errorf("backoff(%d) = %v, want in [%v, %v]", tt.attempt, got, tt.low, tt.high)
+			}
+		}
+	}
+}
+
+func TestServer_Source(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	testdataPath := filepath.Join(wd, "..", "..", "testdata", "config-loading", "account_valid.json")
+
+	reader, err := smartrw.NewReader(testdataPath)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	err = server.AddSource(&Source{Reader: *reader})
+	if err != nil {
+		t.Fatalf("AddSource() error = %v", err)
+	}
+
+	src, ok := server.Source("account_valid.json")
+	if !ok {
+		t.Fatal("Source() did not find source by default name")
+	}
+	if src.Reader.Source != testdataPath {
+		t.Errorf("Source() returned wrong source: %s", src.Reader.Source)
+	}
+
+	if _, ok := server.Source("missing.json"); ok {
+		t.Error("Source() found source that does not exist")
+	}
+}
+
+func TestServer_Reload_Conditional(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	original, err := os.ReadFile(filepath.Join(wd, "..", "..", "testdata", "config-loading", "account_valid.json"))
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "account.json")
+	if err := os.WriteFile(path, original, 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	reader, err := smartrw.NewReader(path)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	src := &Source{Reader: *reader, Conditional: true}
+	err = server.AddSource(src)
+	if err != nil {
+		t.Fatalf("AddSource() error = %v", err)
+	}
+
+	initial := src.Version()
+	if initial == "" {
+		t.Fatal("expected version to be captured on initial load")
+	}
+
+	// unchanged source should be skipped, recording the check but not an update
+	updated := src.Updated
+	reloaded, err := server.reload(src, false)
+	if err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if reloaded {
+		t.Error("expected unchanged source to be skipped")
+	}
+	status := src.status(time.Now())
+	if !status.Updated.Equal(updated) {
+		t.Errorf("expected skipped reload to keep updated = %v, got %v", updated, status.Updated)
+	}
+	if !status.LastChecked.After(updated) {
+		t.Errorf("expected skipped reload to set lastChecked, got %v", status.LastChecked)
+	}
+
+	// forced reloads always happen
+	reloaded, err = server.reload(src, true)
+	if err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if !reloaded {
+		t.Error("expected forced reload to happen")
+	}
+
+	// changed source should be reloaded
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("failed to touch source: %v", err)
+	}
+
+	reloaded, err = server.reload(src, false)
+	if err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if !reloaded {
+		t.Error("expected changed source to be reloaded")
+	}
+	if src.Version() == initial {
+		t.Error("expected version to be updated after reload")
+	}
+
+	// version errors are recorded as failures
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove source: %v", err)
+	}
+	_, err = server.reload(src, false)
+	if err == nil {
+		t.Fatal("expected error checking version of missing source")
+	}
+	if src.failures != 1 {
+		t.Errorf("expected 1 failure, got %d", src.failures)
+	}
+}
+
+func TestServer_Reload_OnReload(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	var reloads []string
+	server.OnReload = func(src *Source) {
+		reloads = append(reloads, src.Name)
+	}
+
+	reader, err := smartrw.NewReader("../../testdata/config-loading/account_valid.json")
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	src := &Source{Reader: *reader, Conditional: true}
+	err = server.AddSource(src)
+	if err != nil {
+		t.Fatalf("AddSource() error = %v", err)
+	}
+	if len(reloads) != 0 {
+		t.Fatalf("expected no reloads after initial load, got %v", reloads)
+	}
+
+	// skipped reloads are not reported
+	if _, err := server.reload(src, false); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if len(reloads) != 0 {
+		t.Fatalf("expected no reloads after skipped reload, got %v", reloads)
+	}
+
+	if err := server.Reload(src); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if len(reloads) != 1 || reloads[0] != "account_valid.json" {
+		t.Fatalf("expected a single reload of account_valid.json, got %v", reloads)
+	}
+}
+
+func TestServer_Snapshot(t *testing.T) {
+	server, err := NewServer(&cli.Flags{Addr: ":8080"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	server.Snapshots = true
+
+	data, err := os.ReadFile("../../testdata/real-world/awsconfig.jsonl")
+	if err != nil {
+		t.Fatalf("failed to read source: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "awsconfig.jsonl")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	reader, err := smartrw.NewReader(path)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	src := &Source{Reader: *reader}
+	if err := server.AddSource(src); err != nil {
+		t.Fatalf("AddSource() error = %v", err)
+	}
+
+	const deleted = "arn:aws:iam::213308312933:role/MouseRole"
+	snapshot := server.Snapshot()
+	if !snapshot.HasPrincipal(deleted) {
+		t.Fatalf("expected snapshot to contain %s", deleted)
+	}
+	if !snapshot.HasPolicy("arn:aws:iam::aws:policy/ReadOnlyAccess") {
+		t.Fatalf("expected snapshot to contain base policies")
+	}
+
+	// delete the principal from the source and reload it
+	var kept []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.Contains(line, `"resourceName":"MouseRole"`) {
+			kept = append(kept, line)
+		}
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(kept, "\n")), 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	if err := server.Reload(src); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	if server.Snapshot().HasPrincipal(deleted) {
+		t.Fatalf("expected snapshot after reload not to contain %s", deleted)
+	}
+	if !server.Snapshot().HasPrincipal("arn:aws:iam::213308312933:role/PandaRole") {
+		t.Fatalf("expected snapshot after reload to contain remaining principals")
+	}
+
+	// the merged universe of the simulator still contains the deleted principal
+	if !server.Simulator.Universe.HasPrincipal(deleted) {
+		t.Fatalf("expected merged universe to retain %s", deleted)
+	}
+}
@@ -3,29 +3,34 @@ package server
 import (
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/nsiow/yams/internal/common"
 	"github.com/nsiow/yams/pkg/aws/sar"
+	v1 "github.com/nsiow/yams/pkg/server/api/v1"
 	"github.com/nsiow/yams/pkg/server/httputil"
 )
 
 func (s *Server) Status(w http.ResponseWriter, req *http.Request) {
-	status := map[string]any{
-		"entities":   s.Simulator.Universe.Size(),
-		"accounts":   s.Simulator.Universe.NumAccounts(),
-		"principals": s.Simulator.Universe.NumPrincipals(),
-		"groups":     s.Simulator.Universe.NumGroups(),
-		"policies":   s.Simulator.Universe.NumPolicies(),
-		"resources":  s.Simulator.Universe.NumResources(),
-		"actions":    len(sar.AllActions()),
-		"sources": common.Map(s.Sources, func(src *Source) map[string]any {
-			return map[string]any{
-				"source":  src.Reader.Source,
-				"updated": src.Updated,
-			}
+	now := time.Now()
+	status := v1.StatusOutput{
+		Entities:   s.Simulator.Universe.Size(),
+		Accounts:   s.Simulator.Universe.NumAccounts(),
+		Principals: s.Simulator.Universe.NumPrincipals(),
+		Groups:     s.Simulator.Universe.NumGroups(),
+		Policies:   s.Simulator.Universe.NumPolicies(),
+		Resources:  s.Simulator.Universe.NumResources(),
+		Actions:    len(sar.AllActions()),
+		Sources: common.Map(s.Sources, func(src *Source) v1.SourceStatus {
+			return src.status(now)
 		}),
 	}
 
+	if s.ResultCache != nil {
+		stats := s.ResultCache.Stats()
+		status.Cache = &stats
+	}
+
 	env := make(map[string]string)
 	for _, key := range s.Opts.Env {
 		val := os.Getenv(key)
@@ -35,8 +40,35 @@ func (s *Server) Status(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if len(env) > 0 {
-		status["env"] = env
+		status.Env = env
 	}
 
 	httputil.WriteJsonResponse(w, req, status)
 }
+
+// status summarizes the load/refresh state of the source for reporting
+func (src *Source) status(now time.Time) v1.SourceStatus {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	status := v1.SourceStatus{
+		Name:        src.Name,
+		Source:      src.Reader.Source,
+		Updated:     src.Updated,
+		Stale:       src.stale(now),
+		Conditional: src.Conditional,
+		Version:     src.version,
+		LastChecked: src.lastChecked,
+		LastAttempt: src.lastAttempt,
+	}
+
+	if src.Refresh > 0 {
+		status.Refresh = src.Refresh.String()
+	}
+	if src.lastError != nil {
+		status.LastError = src.lastError.Error()
+		status.Failures = src.failures
+	}
+
+	return status
+}
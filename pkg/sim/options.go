@@ -19,9 +19,10 @@ type Options struct {
 	// context
 	Context Bag[string]
 
-	// Overlays allows one to specify a special "overlay" Universe in which entity lookup takes place
-	// over the primary simulation Universe
-	Overlay *entities.Universe
+	// Overlays allows one to specify special "overlay" Universes in which entity lookup takes place
+	// over the primary simulation Universe. They are ordered by increasing precedence; entities in
+	// later overlays take precedence over those in earlier overlays and the primary Universe
+	Overlays []*entities.Universe
 
 	// DefaultS3Key specifies which S3 object key should be used to expand S3 bucket ARNs by default.
 	// In other words, it enables simulation against S3 object-level calls for operations where
@@ -71,10 +72,11 @@ func WithTracing() OptionF {
 	}
 }
 
-// WithOverlay adds the provided "overlay" universe to our options
-func WithOverlay(overlays *entities.Universe) OptionF {
+// WithOverlay adds the provided "overlay" universe to our options, taking precedence over any
+// previously added overlays
+func WithOverlay(overlay *entities.Universe) OptionF {
 	return func(opt *Options) {
-		opt.Overlay = overlays
+		opt.Overlays = append(opt.Overlays, overlay)
 	}
 }
 
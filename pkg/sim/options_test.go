@@ -4,9 +4,12 @@ import (
 	"testing"
 
 	"github.com/nsiow/yams/internal/testlib"
+	"github.com/nsiow/yams/pkg/entities"
 )
 
 func TestOptions(t *testing.T) {
+	other := entities.NewUniverse()
+
 	tests := []testlib.TestCase[[]OptionF, Options]{
 		{
 			Input: []OptionF{},
@@ -38,7 +41,17 @@ func TestOptions(t *testing.T) {
 			},
 			Want: Options{
 				DefaultS3Key: "*",
-				Overlay:      SimpleTestUniverse_1,
+				Overlays:     []*entities.Universe{SimpleTestUniverse_1},
+			},
+		},
+		{
+			Input: []OptionF{
+				WithOverlay(SimpleTestUniverse_1),
+				WithOverlay(other),
+			},
+			Want: Options{
+				DefaultS3Key: "*",
+				Overlays:     []*entities.Universe{SimpleTestUniverse_1, other},
 			},
 		},
 		{
@@ -66,29 +66,21 @@ func NewSimulator() (*Simulator, error) {
 
 // resolvePrincipal finds and freezes a Principal through all overlays, indirections, etc
 func (s *Simulator) resolvePrincipal(arn string, opts Options) (*entities.FrozenPrincipal, error) {
-	uvs := s.Universe.Overlay(opts.Overlay)
+	uvs := s.Universe.Overlay(opts.Overlays...)
 
 	// first try exact match
-	for _, uv := range uvs {
-		principal, ok := uv.Principal(arn)
-		if ok {
-			fp, err := principal.FreezeWith(opts.Strict, uvs...)
-			return &fp, err
-		}
+	principal, ok, err := entities.LookupPrincipal(uvs, arn)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		fp, err := principal.FreezeWith(opts.Strict, uvs...)
+		return &fp, err
 	}
 
 	// then try fuzzy finding if enabled
 	if opts.EnableFuzzyMatchArn {
-		var matches []string
-		for _, uv := range uvs {
-			for _, principalArn := range uv.PrincipalArns() {
-				if strings.Contains(strings.ToLower(principalArn), strings.ToLower(arn)) {
-					if len(matches) < 10 {
-						matches = append(matches, principalArn)
-					}
-				}
-			}
-		}
+		matches := FuzzyMatchArns(entities.VisiblePrincipalArns(uvs), arn, 10)
 
 		if len(matches) == 1 {
 			return s.resolvePrincipal(matches[0], opts)
@@ -102,29 +94,21 @@ func (s *Simulator) resolvePrincipal(arn string, opts Options) (*entities.Frozen
 
 // resolveResource finds and freezes a Resource through all overlays, indirections, etc
 func (s *Simulator) resolveResource(arn string, opts Options) (*entities.FrozenResource, error) {
-	uvs := s.Universe.Overlay(opts.Overlay)
+	uvs := s.Universe.Overlay(opts.Overlays...)
 
 	// first try exact match
-	for _, uv := range uvs {
-		resource, ok := uv.Resource(arn)
-		if ok {
-			fr, err := resource.FreezeWith(opts.Strict, uvs...)
-			return &fr, err
-		}
+	resource, ok, err := entities.LookupResource(uvs, arn)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		fr, err := resource.FreezeWith(opts.Strict, uvs...)
+		return &fr, err
 	}
 
 	// then try fuzzy finding if enabled
 	if opts.EnableFuzzyMatchArn {
-		var matches []string
-		for _, uv := range uvs {
-			for _, resourceArn := range uv.ResourceArns() {
-				if strings.Contains(strings.ToLower(resourceArn), strings.ToLower(arn)) {
-					if len(matches) < 10 {
-						matches = append(matches, resourceArn)
-					}
-				}
-			}
-		}
+		matches := FuzzyMatchArns(entities.VisibleResourceArns(uvs), arn, 10)
 
 		if len(matches) == 1 {
 			return s.resolveResource(matches[0], opts)
@@ -136,6 +120,32 @@ func (s *Simulator) resolveResource(arn string, opts Options) (*entities.FrozenR
 	return nil, fmt.Errorf("no resource with arn: %s", arn)
 }
 
+// FuzzyMatchArns returns up to limit of the provided ARNs which contain the search term,
+// ignoring case; this is the matching used to resolve partial ARNs when fuzzy matching is enabled
+func FuzzyMatchArns(arns []string, search string, limit int) []string {
+	var matches []string
+	search = strings.ToLower(search)
+	for _, arn := range arns {
+		if len(matches) >= limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(arn), search) {
+			matches = append(matches, arn)
+		}
+	}
+	return matches
+}
+
+// principalArns returns the ARNs of all principals visible through the configured overlays
+func (s *Simulator) principalArns(opts Options) []string {
+	return entities.VisiblePrincipalArns(s.Universe.Overlay(opts.Overlays...))
+}
+
+// resourceArns returns the ARNs of all resources visible through the configured overlays
+func (s *Simulator) resourceArns(opts Options) []string {
+	return entities.VisibleResourceArns(s.Universe.Overlay(opts.Overlays...))
+}
+
 // ExpandResources takes the provided list of Resource ARNs and performs any required expansion of
 // Resources into Sub-resources (e.g. S3 bucket → object)
 func (s *Simulator) ExpandResources(arns []string, opts Options) ([]string, error) {
@@ -156,7 +166,10 @@ func (s *Simulator) expandResources(arns []string, opts Options) ([]string, erro
 		if opts.DefaultS3Key != "" &&
 			strings.HasPrefix(arn, "arn:aws:s3:::") &&
 			!strings.Contains(arn, "/") {
-			resource, ok := s.Universe.Resource(arn)
+			resource, ok, err := entities.LookupResource(s.Universe.Overlay(opts.Overlays...), arn)
+			if err != nil {
+				return nil, err
+			}
 			if !ok {
 				return nil, fmt.Errorf("unable to locate resource for expansion: '%s'", arn)
 			}
@@ -233,7 +246,10 @@ func (s *Simulator) SimulateByArnWithOptions(
 
 	// Locate Resource (if needed)
 	if ac.Action.HasTargets() {
-		_, ok := s.Universe.Resource(resourceArn)
+		_, ok, err := entities.LookupResource(s.Universe.Overlay(opts.Overlays...), resourceArn)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving resource for simulation: %w", err)
+		}
 		if !ok && isCreateAction(ac.Action) {
 			ac.Resource = newPlaceholderResource(resourceArn)
 		} else {
@@ -249,8 +265,16 @@ func (s *Simulator) SimulateByArnWithOptions(
 }
 
 func (s *Simulator) WhichPrincipals(action, resource string, opts Options) ([]string, error) {
-	matrix, err := s.Product(
-		s.Universe.PrincipalArns(),
+	return s.WhichPrincipalsContext(context.Background(), action, resource, opts)
+}
+
+// WhichPrincipalsContext is like WhichPrincipals, but stops simulating once the context is done
+func (s *Simulator) WhichPrincipalsContext(
+	ctx context.Context, action, resource string, opts Options) ([]string, error) {
+
+	matrix, err := s.ProductContext(
+		ctx,
+		s.principalArns(opts),
 		[]string{action},
 		[]string{resource},
 		opts,
@@ -269,10 +293,18 @@ func (s *Simulator) WhichPrincipals(action, resource string, opts Options) ([]st
 }
 
 func (s *Simulator) WhichActions(principal, resource string, opts Options) ([]string, error) {
+	return s.WhichActionsContext(context.Background(), principal, resource, opts)
+}
+
+// WhichActionsContext is like WhichActions, but stops simulating once the context is done
+func (s *Simulator) WhichActionsContext(
+	ctx context.Context, principal, resource string, opts Options) ([]string, error) {
+
 	svc := arn.Service(resource)
 	actions := sar.ActionsByService(svc)
 
-	matrix, err := s.Product(
+	matrix, err := s.ProductContext(
+		ctx,
 		[]string{principal},
 		common.Map(actions, func(a types.Action) string { return a.ShortName() }),
 		[]string{resource},
@@ -292,13 +324,20 @@ func (s *Simulator) WhichActions(principal, resource string, opts Options) ([]st
 }
 
 func (s *Simulator) WhichResources(principal, action string, opts Options) ([]string, error) {
-	expandedResources := s.Universe.ResourceArns()
-	expandedResources, err := s.expandResources(expandedResources, opts)
+	return s.WhichResourcesContext(context.Background(), principal, action, opts)
+}
+
+// WhichResourcesContext is like WhichResources, but stops simulating once the context is done
+func (s *Simulator) WhichResourcesContext(
+	ctx context.Context, principal, action string, opts Options) ([]string, error) {
+
+	expandedResources, err := s.expandResources(s.resourceArns(opts), opts)
 	if err != nil {
 		return nil, fmt.Errorf("unable to expand provided resource list: %w", err)
 	}
 
-	matrix, err := s.Product(
+	matrix, err := s.ProductContext(
+		ctx,
 		[]string{principal},
 		[]string{action},
 		expandedResources,
@@ -318,14 +357,13 @@ func (s *Simulator) WhichResources(principal, action string, opts Options) ([]st
 }
 
 func (s *Simulator) AccessSummary(actions []string, opts Options) (map[string]int, error) {
-	resourceArns := s.Universe.ResourceArns()
-	resourceArns, err := s.expandResources(resourceArns, opts)
+	resourceArns, err := s.expandResources(s.resourceArns(opts), opts)
 	if err != nil {
 		return nil, fmt.Errorf("unable to expand provided resource list: %w", err)
 	}
 
 	matrix, err := s.Product(
-		s.Universe.PrincipalArns(),
+		s.principalArns(opts),
 		actions,
 		resourceArns,
 		opts)
@@ -345,7 +383,7 @@ func (s *Simulator) AccessSummary(actions []string, opts Options) (map[string]in
 	}
 
 	summary := make(map[string]int)
-	for _, arn := range s.Universe.ResourceArns() {
+	for _, arn := range s.resourceArns(opts) {
 		summary[arn] = 0
 	}
 	for resource, principals := range access {
@@ -365,6 +403,14 @@ type AccessTuple struct {
 // product of the provided simulation identifiers, while also filtering out any combinations that
 // are not allowed.
 func (s *Simulator) Product(ps, as, rs []string, opts Options) ([]AccessTuple, error) {
+	return s.ProductContext(context.Background(), ps, as, rs, opts)
+}
+
+// ProductContext is like Product, but stops simulating and returns the context's error once the
+// context is done
+func (s *Simulator) ProductContext(
+	ctx context.Context, ps, as, rs []string, opts Options) ([]AccessTuple, error) {
+
 	simId := rand.Text()
 	slog.Debug("calculating product",
 		"sim_id", simId)
@@ -391,7 +437,7 @@ func (s *Simulator) Product(ps, as, rs []string, opts Options) ([]AccessTuple, e
 	slog.Debug("froze entities",
 		"sim_id", simId)
 
-	return s.runProduct(fps, fas, frs, opts)
+	return s.runProduct(ctx, fps, fas, frs, opts)
 }
 
 // FreezePrincipals resolves and freezes all the provided principal ARNs. This allows callers to
@@ -439,6 +485,31 @@ func (s *Simulator) ProductFrozenStreaming(
 	frs []*entities.FrozenResource,
 	opts Options,
 	onResult func(AccessTuple),
+) error {
+	return s.productFrozenStreaming(fps, actions, frs, opts, false, onResult)
+}
+
+// ProductFrozenStreamingAll is like ProductFrozenStreaming, but streams denied results as well as
+// allowed ones. With tracing enabled, each result also carries the trace of its evaluation
+func (s *Simulator) ProductFrozenStreamingAll(
+	fps []*entities.FrozenPrincipal,
+	actions []string,
+	frs []*entities.FrozenResource,
+	opts Options,
+	onResult func(AccessTuple),
+) error {
+	return s.productFrozenStreaming(fps, actions, frs, opts, true, onResult)
+}
+
+// productFrozenStreaming is the implementation of ProductFrozenStreaming and
+// ProductFrozenStreamingAll
+func (s *Simulator) productFrozenStreaming(
+	fps []*entities.FrozenPrincipal,
+	actions []string,
+	frs []*entities.FrozenResource,
+	opts Options,
+	denied bool,
+	onResult func(AccessTuple),
 ) error {
 	var fas []*types.Action
 	for _, a := range actions {
@@ -450,7 +521,7 @@ func (s *Simulator) ProductFrozenStreaming(
 	}
 
 	var simErr error
-	s.streamProduct(fps, fas, frs, opts, onResult, func(err error) {
+	s.streamProduct(context.Background(), fps, fas, frs, opts, denied, onResult, func(err error) {
 		simErr = err
 	})
 	return simErr
@@ -458,6 +529,7 @@ func (s *Simulator) ProductFrozenStreaming(
 
 // runProduct submits simulation work to the pool and collects allowed results
 func (s *Simulator) runProduct(
+	ctx context.Context,
 	fps []*entities.FrozenPrincipal,
 	fas []*types.Action,
 	frs []*entities.FrozenResource,
@@ -466,7 +538,7 @@ func (s *Simulator) runProduct(
 	var matrix []AccessTuple
 	var collectErr error
 
-	s.streamProduct(fps, fas, frs, opts, func(t AccessTuple) {
+	s.streamProduct(ctx, fps, fas, frs, opts, false, func(t AccessTuple) {
 		matrix = append(matrix, t)
 	}, func(err error) {
 		collectErr = err
@@ -501,16 +573,20 @@ func precomputeTargets(fas []*types.Action, frs []*entities.FrozenResource) []ac
 }
 
 // streamProduct is the core simulation engine. It partitions principals across multiple submission
-// goroutines to keep workers saturated. A context is used to cancel in-flight work on error.
+// goroutines to keep workers saturated. A context derived from the provided one is used to cancel
+// in-flight work on error, and the provided context's error is reported if it is done before every
+// result was received. Only allowed results are reported, unless denied is set
 func (s *Simulator) streamProduct(
+	parent context.Context,
 	fps []*entities.FrozenPrincipal,
 	fas []*types.Action,
 	frs []*entities.FrozenResource,
 	opts Options,
+	denied bool,
 	onResult func(AccessTuple),
 	onError func(error),
 ) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
 	finished := make(chan simOut, s.Pool.NumWorkers()*s.Pool.BatchSize())
@@ -540,7 +616,7 @@ func (s *Simulator) streamProduct(
 
 			go func(principals []*entities.FrozenPrincipal) {
 				defer submitters.Done()
-				s.submitPartition(principals, filtered, opts, finished, &wg, ctx)
+				s.submitPartition(principals, filtered, opts, denied, finished, &wg, ctx)
 			}(fps[start:end])
 		}
 
@@ -558,6 +634,10 @@ func (s *Simulator) streamProduct(
 		select {
 		case job, ok := <-finished:
 			if !ok {
+				// workers stop early once the parent is done, leaving results missing
+				if err := parent.Err(); err != nil {
+					onError(err)
+				}
 				return
 			}
 			received++
@@ -565,7 +645,7 @@ func (s *Simulator) streamProduct(
 				onError(fmt.Errorf("simulation error: %w", job.Error))
 				return
 			}
-			if job.Result.IsAllowed {
+			if job.Result.IsAllowed || denied {
 				result := &job.Result
 				onResult(AccessTuple{
 					Principal: result.Principal,
@@ -586,6 +666,7 @@ func (s *Simulator) submitPartition(
 	fps []*entities.FrozenPrincipal,
 	filtered []actionResources,
 	opts Options,
+	denied bool,
 	finished chan simOut,
 	wg *sync.WaitGroup,
 	ctx context.Context,
@@ -595,9 +676,14 @@ func (s *Simulator) submitPartition(
 		Finished: finished,
 		Wg:       wg,
 		Ctx:      ctx,
+		Denied:   denied,
 	}
 
 	for _, p := range fps {
+		if ctx.Err() != nil {
+			return
+		}
+
 		for _, ar := range filtered {
 			for _, r := range ar.resources {
 				batch.Jobs = append(batch.Jobs, simIn{
@@ -618,6 +704,7 @@ func (s *Simulator) submitPartition(
 						Finished: finished,
 						Wg:       wg,
 						Ctx:      ctx,
+						Denied:   denied,
 					}
 				}
 			}
@@ -1,8 +1,11 @@
 package sim
 
 import (
+	"context"
+	"errors"
 	"os"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/nsiow/yams/internal/testlib"
@@ -800,6 +803,210 @@ func TestSimulateByArn_FuzzyMatch(t *testing.T) {
 	})
 }
 
+func TestFuzzyMatchArns(t *testing.T) {
+	type input struct {
+		search string
+		limit  int
+	}
+
+	arns := []string{
+		"arn:aws:iam::88888:role/Role1",
+		"arn:aws:iam::88888:role/role2",
+		"arn:aws:s3:::bucket1",
+	}
+
+	tests := []testlib.TestCase[input, []string]{
+		{
+			Name:  "case_insensitive",
+			Input: input{search: "ROLE", limit: 10},
+			Want:  []string{"arn:aws:iam::88888:role/Role1", "arn:aws:iam::88888:role/role2"},
+		},
+		{
+			Name:  "limited",
+			Input: input{search: "88888", limit: 1},
+			Want:  []string{"arn:aws:iam::88888:role/Role1"},
+		},
+		{
+			Name:  "no_match",
+			Input: input{search: "queue", limit: 10},
+			Want:  nil,
+		},
+	}
+
+	testlib.RunTestSuite(t, tests, func(i input) ([]string, error) {
+		return FuzzyMatchArns(arns, i.search, i.limit), nil
+	})
+}
+
+func TestSimulateByArn_OverlayLayers(t *testing.T) {
+	// grants role2 access to bucket1, which it lacks in the base universe
+	grant := entities.NewBuilder().
+		WithPrincipals(
+			entities.Principal{
+				Arn:       "arn:aws:iam::88888:role/role2",
+				Type:      "AWS::IAM::Role",
+				AccountId: "88888",
+				InlinePolicies: []policy.Policy{
+					{
+						Statement: []policy.Statement{
+							{
+								Effect:   policy.EFFECT_ALLOW,
+								Action:   []string{"s3:listbucket"},
+								Resource: []string{"arn:aws:s3:::bucket1"},
+							},
+						},
+					},
+				},
+			},
+		).
+		Build()
+
+	// strips role2 of all of its policies
+	revoke := entities.NewBuilder().
+		WithPrincipals(
+			entities.Principal{
+				Arn:       "arn:aws:iam::88888:role/role2",
+				Type:      "AWS::IAM::Role",
+				AccountId: "88888",
+			},
+		).
+		Build()
+
+	tests := []testlib.TestCase[[]*entities.Universe, bool]{
+		{
+			Name:  "no_overlays",
+			Input: nil,
+			Want:  false,
+		},
+		{
+			Name:  "single_layer",
+			Input: []*entities.Universe{grant},
+			Want:  true,
+		},
+		{
+			Name:  "later_layer_revokes",
+			Input: []*entities.Universe{grant, revoke},
+			Want:  false,
+		},
+		{
+			Name:  "later_layer_grants",
+			Input: []*entities.Universe{revoke, grant},
+			Want:  true,
+		},
+		{
+			Name:  "nil_layer_skipped",
+			Input: []*entities.Universe{grant, nil},
+			Want:  true,
+		},
+	}
+
+	testlib.RunTestSuite(t, tests, func(overlays []*entities.Universe) (bool, error) {
+		sim, _ := NewSimulator()
+		sim.Universe = SimpleTestUniverse_1
+		res, err := sim.SimulateByArnWithOptions(
+			"arn:aws:iam::88888:role/role2",
+			"s3:listbucket",
+			"arn:aws:s3:::bucket1",
+			Options{Overlays: overlays, DefaultS3Key: "*"},
+		)
+		if err != nil {
+			return false, err
+		}
+
+		return res.IsAllowed, nil
+	})
+}
+
+func TestSimulate_Tombstones(t *testing.T) {
+	sim, _ := NewSimulator()
+	sim.Universe = SimpleTestUniverse_1
+
+	deleted := entities.NewBuilder().
+		WithTombstones("arn:aws:iam::88888:role/role1", "arn:aws:s3:::bucket2").
+		Build()
+	opts := Options{Overlays: []*entities.Universe{deleted}, DefaultS3Key: "*"}
+
+	// tombstoned principals are not considered
+	before, err := sim.WhichPrincipals("s3:listbucket", "arn:aws:s3:::bucket1", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Contains(before, "arn:aws:iam::88888:role/role1") {
+		t.Fatalf("expected role1 to have access without overlay, got %v", before)
+	}
+
+	after, err := sim.WhichPrincipals("s3:listbucket", "arn:aws:s3:::bucket1", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if slices.Contains(after, "arn:aws:iam::88888:role/role1") {
+		t.Fatalf("expected tombstoned role1 to be excluded, got %v", after)
+	}
+
+	// tombstoned principals and resources cannot be resolved
+	_, err = sim.SimulateByArnWithOptions(
+		"arn:aws:iam::88888:role/role1", "s3:listbucket", "arn:aws:s3:::bucket1", opts)
+	if err == nil {
+		t.Fatalf("expected error simulating tombstoned principal")
+	}
+	_, err = sim.SimulateByArnWithOptions(
+		"arn:aws:iam::88888:role/role2", "s3:listbucket", "arn:aws:s3:::bucket2", opts)
+	if err == nil {
+		t.Fatalf("expected error simulating tombstoned resource")
+	}
+}
+
+func TestSimulate_Patches(t *testing.T) {
+	sim, _ := NewSimulator()
+	sim.Universe = entities.NewBuilder().
+		WithPrincipals(entities.Principal{
+			Arn:       "arn:aws:iam::88888:role/app",
+			Type:      "AWS::IAM::Role",
+			AccountId: "88888",
+		}).
+		WithResources(entities.Resource{
+			Arn:       "arn:aws:s3:::bucket",
+			Type:      "AWS::S3::Bucket",
+			AccountId: "88888",
+		}).
+		Build()
+
+	allow := policy.Policy{
+		Statement: []policy.Statement{{
+			Effect:   policy.EFFECT_ALLOW,
+			Action:   []string{"s3:listbucket"},
+			Resource: []string{"arn:aws:s3:::bucket"},
+		}},
+	}
+	patches := entities.NewBuilder().
+		WithPatches(entities.Patch{
+			Arn: "arn:aws:iam::88888:role/app",
+			Operations: []entities.PatchOperation{
+				{Op: "add", Path: "/InlinePolicies/-", Value: allow},
+			},
+		}).
+		Build()
+
+	before, err := sim.SimulateByArnWithOptions(
+		"arn:aws:iam::88888:role/app", "s3:listbucket", "arn:aws:s3:::bucket", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if before.IsAllowed {
+		t.Fatalf("expected access to be denied without patches")
+	}
+
+	after, err := sim.SimulateByArnWithOptions(
+		"arn:aws:iam::88888:role/app", "s3:listbucket", "arn:aws:s3:::bucket",
+		Options{Overlays: []*entities.Universe{patches}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !after.IsAllowed {
+		t.Fatalf("expected patched inline policy to allow access")
+	}
+}
+
 func TestSimulateByArn_CreateAction(t *testing.T) {
 	// Test case where action is Create* and resource doesn't exist
 	sim, _ := NewSimulator()
@@ -1010,6 +1217,38 @@ func TestProduct_BatchSubmission(t *testing.T) {
 	}
 }
 
+func TestWhichContext_Cancelled(t *testing.T) {
+	sim, err := NewSimulator()
+	if err != nil {
+		t.Fatalf("error creating simulator: %v", err)
+	}
+	sim.Universe = SimpleTestUniverse_1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = sim.WhichPrincipalsContext(ctx, "s3:listbucket", "arn:aws:s3:::bucket1",
+		TestingSimulationOptions)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("WhichPrincipalsContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	_, err = sim.ProductContext(ctx,
+		sim.Universe.PrincipalArns(),
+		[]string{"s3:listbucket"},
+		[]string{"arn:aws:s3:::bucket1"},
+		TestingSimulationOptions)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ProductContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	// the background context of the non-context variants never ends the simulation early
+	if _, err := sim.WhichPrincipals("s3:listbucket", "arn:aws:s3:::bucket1",
+		TestingSimulationOptions); err != nil {
+		t.Errorf("WhichPrincipals() error = %v", err)
+	}
+}
+
 // Test public wrappers that delegate to unexported methods
 func TestExpandResources_PublicWrapper(t *testing.T) {
 	sim, err := NewSimulator()
@@ -1087,3 +1326,66 @@ func TestProductFrozenStreaming(t *testing.T) {
 		t.Fatal("expected error for unknown action")
 	}
 }
+
+func TestProductFrozenStreamingAll(t *testing.T) {
+	sim, err := NewSimulator()
+	if err != nil {
+		t.Fatalf("error creating simulator: %v", err)
+	}
+	sim.Universe = SimpleTestUniverse_1
+
+	pArns := []string{}
+	for p := range sim.Universe.Principals() {
+		pArns = append(pArns, p.Arn)
+	}
+	principals, err := sim.FreezePrincipals(pArns, TestingSimulationOptions)
+	if err != nil {
+		t.Fatalf("error freezing principals: %v", err)
+	}
+
+	resources, err := sim.FreezeResources(
+		[]string{"arn:aws:s3:::bucket1"}, TestingSimulationOptions)
+	if err != nil {
+		t.Fatalf("error freezing resources: %v", err)
+	}
+
+	allowed := 0
+	err = sim.ProductFrozenStreaming(principals, []string{"s3:listbucket"}, resources,
+		TestingSimulationOptions, func(r AccessTuple) {
+			allowed++
+		})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var results []AccessTuple
+	err = sim.ProductFrozenStreamingAll(principals, []string{"s3:listbucket"}, resources,
+		TestingSimulationOptions, func(r AccessTuple) {
+			results = append(results, r)
+		})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Every principal should be reported, with the allowed ones matching ProductFrozenStreaming
+	if len(results) != len(principals) {
+		t.Fatalf("expected %d results, got %d", len(principals), len(results))
+	}
+	var gotAllowed, gotDenied int
+	for _, r := range results {
+		if r.Result.IsAllowed {
+			gotAllowed++
+		} else {
+			gotDenied++
+		}
+		if r.Result.Trace == nil || len(r.Result.Trace.Explain()) == 0 {
+			t.Errorf("expected explanation for traced result: %+v", r)
+		}
+	}
+	if gotAllowed != allowed {
+		t.Errorf("expected %d allowed results, got %d", allowed, gotAllowed)
+	}
+	if gotDenied == 0 {
+		t.Error("expected denied results")
+	}
+}
@@ -30,6 +30,9 @@ type simBatch struct {
 	Finished chan<- simOut
 	Wg       *sync.WaitGroup
 	Ctx      context.Context
+
+	// Denied causes denied results to be reported in addition to allowed ones
+	Denied bool
 }
 
 type Pool struct {
@@ -152,10 +155,16 @@ func (p *Pool) handleBatch(b simBatch) {
 		// Fast path: skip Validate (submitter already filtered via Targets) and avoid heap
 		// allocation by using stack-local subject and value-type SimResult
 		subj := subject{auth: item.AuthContext, opts: item.Options}
+		if item.Options.EnableTracing {
+			subj.trc.Enable()
+		}
 		result := evalOverallAccess(&subj)
-		if !result.IsAllowed {
+		if !result.IsAllowed && !b.Denied {
 			continue
 		}
+		if item.Options.EnableTracing {
+			result.Trace = &subj.trc
+		}
 
 		result.Principal = item.AuthContext.Principal.Arn
 		result.Action = item.AuthContext.Action.ShortName()
@@ -0,0 +1,107 @@
+package watch
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/bytedance/sonic"
+)
+
+// DefaultWebhookTimeout is how long a webhook may take to accept events
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Sink receives the events produced by each evaluation
+type Sink interface {
+	Send(events []Event) error
+}
+
+// NewSink creates a sink for the provided destination: stdout for an empty destination or '-', a
+// webhook for http:// and https:// URLs, or otherwise a file to which events are appended
+func NewSink(dest string) (Sink, error) {
+	switch {
+	case dest == "" || dest == "-":
+		return NewWriterSink(os.Stdout), nil
+	case strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://"):
+		return NewWebhookSink(dest), nil
+	default:
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open event file: %w", err)
+		}
+		return NewWriterSink(f), nil
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// Writer
+// -------------------------------------------------------------------------------------------------
+
+// WriterSink writes events as newline-delimited JSON
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink writing to the provided writer
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Send(events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// encoded in full first, so that a failed write never leaves partial events behind
+	var buf bytes.Buffer
+	enc := json.ConfigDefault.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// -------------------------------------------------------------------------------------------------
+// Webhook
+// -------------------------------------------------------------------------------------------------
+
+// WebhookSink posts the events of each evaluation to a URL as a JSON array
+type WebhookSink struct {
+	URL  string
+	http *http.Client
+}
+
+// NewWebhookSink creates a sink posting to the provided URL
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		URL:  url,
+		http: &http.Client{Timeout: DefaultWebhookTimeout},
+	}
+}
+
+func (s *WebhookSink) Send(events []Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.http.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to reach webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
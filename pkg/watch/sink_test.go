@@ -0,0 +1,110 @@
+package watch
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	json "github.com/bytedance/sonic"
+)
+
+var testEvents = []Event{
+	{Check: "a", Type: EventGained, Message: "first"},
+	{Check: "a", Type: EventLost, Message: "second"},
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriterSink(&buf).Send(testEvents); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(testEvents) {
+		t.Fatalf("wanted %d lines, got %d: %s", len(testEvents), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		if event.Message != testEvents[i].Message {
+			t.Fatalf("wanted message %q, got %q", testEvents[i].Message, event.Message)
+		}
+	}
+}
+
+func TestNewSink_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	// events are appended across sinks, such as after a restart
+	for range 2 {
+		sink, err := NewSink(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := sink.Send(testEvents); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read events: %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 2*len(testEvents) {
+		t.Fatalf("wanted %d lines, got %d", 2*len(testEvents), n)
+	}
+
+	if _, err := NewSink(filepath.Join(path, "not-a-dir", "events")); err == nil {
+		t.Fatalf("expected error for invalid path")
+	}
+}
+
+func TestNewSink_Webhook(t *testing.T) {
+	var received []Event
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(server.URL + "/hook")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := sink.(*WebhookSink); !ok {
+		t.Fatalf("expected webhook sink, got %T", sink)
+	}
+
+	if err := sink.Send(testEvents); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != len(testEvents) {
+		t.Fatalf("wanted %d events, got %d", len(testEvents), len(received))
+	}
+
+	status = http.StatusInternalServerError
+	if err := sink.Send(testEvents); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("expected error for failed webhook, got: %v", err)
+	}
+}
+
+func TestNewSink_Stdout(t *testing.T) {
+	for _, dest := range []string{"", "-"} {
+		sink, err := NewSink(dest)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := sink.(*WriterSink); !ok {
+			t.Fatalf("expected writer sink for %q, got %T", dest, sink)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package watch continuously evaluates saved access checks, such as "who can read prod-data", and
+// reports only the access which was gained or lost since the previous evaluation. Combined with
+// sources which are refreshed on an interval, this turns point-in-time simulations into drift
+// detection for sensitive resources
+package watch
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/entities"
+	"github.com/nsiow/yams/pkg/sim"
+	"github.com/nsiow/yams/pkg/sim/wildcard"
+)
+
+// Values of Check.Expect
+const (
+	ExpectAllow = "allow"
+	ExpectDeny  = "deny"
+)
+
+// Values of Event.Type
+const (
+	EventGained = "gained"
+	EventLost   = "lost"
+)
+
+// -------------------------------------------------------------------------------------------------
+// Checks
+// -------------------------------------------------------------------------------------------------
+
+// Check is a saved query for the access that a set of principals has to a set of resources. Checks
+// with an expectation are assertions, whose violations are flagged in the events they produce
+type Check struct {
+	// Name identifies the check in events
+	Name string `json:"name"`
+
+	// Principals and Resources list ARN patterns, which may contain * and ? wildcards, selecting
+	// the entities to check; if empty, every principal or resource is checked
+	Principals []string `json:"principals"`
+	Resources  []string `json:"resources"`
+
+	Actions []string          `json:"actions"`
+	Context map[string]string `json:"context"`
+
+	// Expect asserts that every selected access is either allowed or denied, if set
+	Expect string `json:"expect"`
+}
+
+// LoadChecks reads and validates a JSON array of checks
+func LoadChecks(path string) ([]Check, error) {
+	reader, err := smartrw.NewReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open checks: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read checks: %w", err)
+	}
+
+	var checks []Check
+	if err := json.Unmarshal(data, &checks); err != nil {
+		return nil, fmt.Errorf("unable to parse checks: %w", err)
+	}
+
+	names := map[string]bool{}
+	for i, check := range checks {
+		switch {
+		case check.Name == "":
+			return nil, fmt.Errorf("check %d: missing name", i)
+		case names[check.Name]:
+			return nil, fmt.Errorf("check %d: duplicate name '%s'", i, check.Name)
+		case len(check.Actions) == 0:
+			return nil, fmt.Errorf("check %d (%s): missing actions", i, check.Name)
+		case !slices.Contains([]string{"", ExpectAllow, ExpectDeny}, check.Expect):
+			return nil, fmt.Errorf("check %d (%s): expect must be '%s' or '%s', not '%s'",
+				i, check.Name, ExpectAllow, ExpectDeny, check.Expect)
+		}
+		names[check.Name] = true
+	}
+
+	return checks, nil
+}
+
+// violates determines whether the access being gained or lost violates the check's expectation
+func (c *Check) violates(eventType string) bool {
+	return (eventType == EventGained && c.Expect == ExpectDeny) ||
+		(eventType == EventLost && c.Expect == ExpectAllow)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Events
+// -------------------------------------------------------------------------------------------------
+
+// Event reports that a principal gained or lost access matching a check
+type Event struct {
+	Time      time.Time `json:"time"`
+	Check     string    `json:"check"`
+	Type      string    `json:"type"`
+	Principal string    `json:"principal"`
+	Action    string    `json:"action"`
+	Resource  string    `json:"resource"`
+
+	// Violation marks changes which break the expectation of the check
+	Violation bool `json:"violation,omitempty"`
+
+	// Message describes the change, such as "<role ARN> gained s3:GetObject on <bucket ARN>"
+	Message string `json:"message"`
+}
+
+// access is a single allowed combination of principal, action and resource
+type access struct {
+	principal, action, resource string
+}
+
+// -------------------------------------------------------------------------------------------------
+// Watcher
+// -------------------------------------------------------------------------------------------------
+
+// Watcher evaluates checks against a simulator, sending events for changes in their results to a
+// sink. The first evaluation establishes the results which later ones are compared against
+type Watcher struct {
+	Simulator *sim.Simulator
+	Checks    []Check
+	Sink      Sink
+
+	// Context is request context for every check, beneath that of the check itself
+	Context map[string]string
+
+	// Universe, if set, provides the universe for each evaluation, replacing that of the
+	// simulator. Deleted entities must be absent from it, so that their access is reported as lost
+	Universe func() *entities.Universe
+
+	// mu serializes evaluations; results holds the allowed access of each check, by name, as of
+	// its last successful evaluation
+	mu      sync.Mutex
+	results map[string]map[access]struct{}
+}
+
+// NewWatcher creates a Watcher for the provided checks
+func NewWatcher(simulator *sim.Simulator, checks []Check, sink Sink) *Watcher {
+	return &Watcher{
+		Simulator: simulator,
+		Checks:    checks,
+		Sink:      sink,
+		results:   map[string]map[access]struct{}{},
+	}
+}
+
+// Evaluate evaluates every check, sending events for access gained or lost since the previous
+// evaluation. Checks which fail to evaluate keep their previous results until the next evaluation
+func (w *Watcher) Evaluate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.Universe != nil {
+		w.Simulator.Universe = w.Universe()
+	}
+
+	now := time.Now().UTC()
+	updated := map[string]map[access]struct{}{}
+	var events []Event
+	var errs []error
+
+	for _, check := range w.Checks {
+		current, err := w.evaluate(check)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to evaluate check '%s': %w", check.Name, err))
+			continue
+		}
+		updated[check.Name] = current
+
+		previous, seen := w.results[check.Name]
+		if !seen {
+			if check.Expect == ExpectDeny && len(current) > 0 {
+				slog.Warn("check is violated at start",
+					"check", check.Name,
+					"allowed", len(current))
+			} else {
+				slog.Info("evaluated check", "check", check.Name, "allowed", len(current))
+			}
+			continue
+		}
+
+		events = append(events, diff(now, check, previous, current)...)
+	}
+
+	// Results are only updated once their events are sent, so that unsent changes are sent again
+	// by the next evaluation
+	if len(events) > 0 {
+		slog.Info("access changed", "events", len(events))
+		if err := w.Sink.Send(events); err != nil {
+			return errors.Join(append(errs, fmt.Errorf("unable to send events: %w", err))...)
+		}
+	}
+	maps.Copy(w.results, updated)
+
+	return errors.Join(errs...)
+}
+
+// evaluate calculates the access allowed by a check
+func (w *Watcher) evaluate(check Check) (map[access]struct{}, error) {
+	context := maps.Clone(w.Context)
+	if context == nil {
+		context = map[string]string{}
+	}
+	maps.Copy(context, check.Context)
+	opts := sim.NewOptions(sim.WithAdditionalProperties(context))
+
+	principals := matching(w.Simulator.Universe.PrincipalArns(), check.Principals)
+	resources := matching(w.Simulator.Universe.ResourceArns(), check.Resources)
+	if len(principals) == 0 || len(resources) == 0 {
+		return map[access]struct{}{}, nil
+	}
+
+	resources, err := w.Simulator.ExpandResources(resources, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to expand resources: %w", err)
+	}
+
+	tuples, err := w.Simulator.Product(principals, check.Actions, resources, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[access]struct{}, len(tuples))
+	for _, t := range tuples {
+		allowed[access{t.Principal, t.Action, t.Resource}] = struct{}{}
+	}
+	return allowed, nil
+}
+
+// matching returns the ARNs which match any of the patterns, or every ARN if there are none
+func matching(arns []string, patterns []string) []string {
+	if len(patterns) == 0 {
+		return arns
+	}
+
+	return slices.DeleteFunc(arns, func(arn string) bool {
+		return !slices.ContainsFunc(patterns, func(pattern string) bool {
+			return wildcard.MatchString(pattern, arn)
+		})
+	})
+}
+
+// diff creates events for the access of a check which was gained or lost, in a stable order
+func diff(now time.Time, check Check, previous, current map[access]struct{}) []Event {
+	var events []Event
+	add := func(eventType string, from, to map[access]struct{}) {
+		var changed []access
+		for a := range to {
+			if _, ok := from[a]; !ok {
+				changed = append(changed, a)
+			}
+		}
+		slices.SortFunc(changed, func(x, y access) int {
+			return cmp.Or(
+				cmp.Compare(x.principal, y.principal),
+				cmp.Compare(x.action, y.action),
+				cmp.Compare(x.resource, y.resource))
+		})
+
+		for _, a := range changed {
+			events = append(events, Event{
+				Time:      now,
+				Check:     check.Name,
+				Type:      eventType,
+				Principal: a.principal,
+				Action:    a.action,
+				Resource:  a.resource,
+				Violation: check.violates(eventType),
+				Message: fmt.Sprintf("%s %s %s on %s",
+					a.principal, eventType, a.action, a.resource),
+			})
+		}
+	}
+
+	add(EventGained, previous, current)
+	add(EventLost, current, previous)
+	return events
+}
@@ -0,0 +1,321 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nsiow/yams/cmd/yams/cli"
+	"github.com/nsiow/yams/internal/smartrw"
+	"github.com/nsiow/yams/pkg/distributed"
+	"github.com/nsiow/yams/pkg/server"
+	"github.com/nsiow/yams/pkg/sim"
+)
+
+// recordingSink records the events sent to it, failing instead if err is set
+type recordingSink struct {
+	events [][]Event
+	err    error
+}
+
+func (s *recordingSink) Send(events []Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, events)
+	return nil
+}
+
+func loadSimulator(t *testing.T, source string) *sim.Simulator {
+	t.Helper()
+
+	simulator, _, err := distributed.LoadSources([]string{source})
+	if err != nil {
+		t.Fatalf("unable to load source: %v", err)
+	}
+	return simulator
+}
+
+// summarize reduces events to a comparable form
+func summarize(events []Event) []string {
+	var summary []string
+	for _, e := range events {
+		s := e.Check + ": " + e.Message
+		if e.Violation {
+			s += " (violation)"
+		}
+		summary = append(summary, s)
+	}
+	return summary
+}
+
+var bearCheck = Check{
+	Name:       "bear",
+	Principals: []string{"arn:aws:iam::213308312933:*"},
+	Resources:  []string{"arn:aws:s3:::yams-bear"},
+	Actions:    []string{"s3:GetObject"},
+	Expect:     ExpectDeny,
+}
+
+// -------------------------------------------------------------------------------------------------
+// Tests
+// -------------------------------------------------------------------------------------------------
+
+func TestLoadChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr string
+	}{
+		{
+			name: "valid",
+			input: `[{"name":"a","actions":["s3:GetObject"]},` +
+				`{"name":"b","actions":["s3:*"],"expect":"deny"}]`,
+			want: 2,
+		},
+		{
+			name:    "invalid_json",
+			input:   `[{`,
+			wantErr: "unable to parse checks",
+		},
+		{
+			name:    "missing_name",
+			input:   `[{"actions":["s3:GetObject"]}]`,
+			wantErr: "missing name",
+		},
+		{
+			name: "duplicate_name",
+			input: `[{"name":"a","actions":["s3:GetObject"]},` +
+				`{"name":"a","actions":["s3:GetObject"]}]`,
+			wantErr: "duplicate name",
+		},
+		{
+			name:    "missing_actions",
+			input:   `[{"name":"a"}]`,
+			wantErr: "missing actions",
+		},
+		{
+			name:    "invalid_expect",
+			input:   `[{"name":"a","actions":["s3:GetObject"],"expect":"maybe"}]`,
+			wantErr: "expect must be",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checks.json")
+			if err := os.WriteFile(path, []byte(tc.input), 0644); err != nil {
+				t.Fatalf("unable to write checks: %v", err)
+			}
+
+			checks, err := LoadChecks(path)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(checks) != tc.want {
+				t.Fatalf("wanted %d checks, got %d", tc.want, len(checks))
+			}
+		})
+	}
+
+	if _, err := LoadChecks("/does/not/exist.json"); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	older := loadSimulator(t, "../../testdata/real-world/awsconfig.old.jsonl.gz")
+	newer := loadSimulator(t, "../../testdata/real-world/awsconfig.jsonl")
+
+	readers := bearCheck
+	readers.Name = "readers"
+	readers.Expect = ExpectAllow
+
+	sink := &recordingSink{}
+	w := NewWatcher(older, []Check{bearCheck, readers}, sink)
+
+	steps := []struct {
+		name      string
+		simulator *sim.Simulator
+		want      []string
+	}{
+		{
+			name:      "baseline",
+			simulator: older,
+		},
+		{
+			name:      "unchanged",
+			simulator: older,
+		},
+		{
+			name:      "gained",
+			simulator: newer,
+			want: []string{
+				"bear: arn:aws:iam::213308312933:role/MouseRole gained s3:GetObject on " +
+					"arn:aws:s3:::yams-bear/* (violation)",
+				"bear: arn:aws:iam::213308312933:role/PandaRole gained s3:GetObject on " +
+					"arn:aws:s3:::yams-bear/* (violation)",
+				"readers: arn:aws:iam::213308312933:role/MouseRole gained s3:GetObject on " +
+					"arn:aws:s3:::yams-bear/*",
+				"readers: arn:aws:iam::213308312933:role/PandaRole gained s3:GetObject on " +
+					"arn:aws:s3:::yams-bear/*",
+			},
+		},
+		{
+			name:      "lost",
+			simulator: older,
+			want: []string{
+				"bear: arn:aws:iam::213308312933:role/MouseRole lost s3:GetObject on " +
+					"arn:aws:s3:::yams-bear/*",
+				"bear: arn:aws:iam::213308312933:role/PandaRole lost s3:GetObject on " +
+					"arn:aws:s3:::yams-bear/*",
+				"readers: arn:aws:iam::213308312933:role/MouseRole lost s3:GetObject on " +
+					"arn:aws:s3:::yams-bear/* (violation)",
+				"readers: arn:aws:iam::213308312933:role/PandaRole lost s3:GetObject on " +
+					"arn:aws:s3:::yams-bear/* (violation)",
+			},
+		},
+	}
+
+	for _, step := range steps {
+		sink.events = nil
+		w.Simulator = step.simulator
+		if err := w.Evaluate(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+
+		if step.want == nil {
+			if len(sink.events) != 0 {
+				t.Fatalf("%s: expected no events, got: %v", step.name, sink.events)
+			}
+			continue
+		}
+
+		if len(sink.events) != 1 {
+			t.Fatalf("%s: expected a single batch of events, got %d", step.name, len(sink.events))
+		}
+		if got := summarize(sink.events[0]); !slices.Equal(got, step.want) {
+			t.Fatalf("%s: wanted events:\n%s\ngot:\n%s",
+				step.name, strings.Join(step.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestEvaluateDeletedPrincipal(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/real-world/awsconfig.jsonl")
+	if err != nil {
+		t.Fatalf("unable to read source: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "awsconfig.jsonl")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("unable to write source: %v", err)
+	}
+
+	srv, err := server.NewServer(&cli.Flags{})
+	if err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	srv.Snapshots = true
+
+	reader, err := smartrw.NewReader(path)
+	if err != nil {
+		t.Fatalf("unable to open source: %v", err)
+	}
+	src := &server.Source{Reader: *reader}
+	if err := srv.AddSource(src); err != nil {
+		t.Fatalf("unable to add source: %v", err)
+	}
+
+	simulator, err := sim.NewSimulator()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %v", err)
+	}
+	sink := &recordingSink{}
+	w := NewWatcher(simulator, []Check{bearCheck}, sink)
+	w.Universe = srv.Snapshot
+	if err := w.Evaluate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// delete MouseRole from the source, as if it had been deleted from the account
+	var kept []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.Contains(line, `"resourceName":"MouseRole"`) {
+			kept = append(kept, line)
+		}
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(kept, "\n")), 0644); err != nil {
+		t.Fatalf("unable to write source: %v", err)
+	}
+	if err := srv.Reload(src); err != nil {
+		t.Fatalf("unable to reload source: %v", err)
+	}
+	if err := w.Evaluate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"bear: arn:aws:iam::213308312933:role/MouseRole lost s3:GetObject on " +
+			"arn:aws:s3:::yams-bear/*",
+	}
+	if len(sink.events) != 1 {
+		t.Fatalf("expected a single batch of events, got %d", len(sink.events))
+	}
+	if got := summarize(sink.events[0]); !slices.Equal(got, want) {
+		t.Fatalf("wanted events:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestEvaluateSendFailure(t *testing.T) {
+	older := loadSimulator(t, "../../testdata/real-world/awsconfig.old.jsonl.gz")
+	newer := loadSimulator(t, "../../testdata/real-world/awsconfig.jsonl")
+
+	sink := &recordingSink{}
+	w := NewWatcher(older, []Check{bearCheck}, sink)
+	if err := w.Evaluate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// events which could not be sent are sent by the next evaluation
+	w.Simulator = newer
+	sink.err = errors.New("unavailable")
+	if err := w.Evaluate(); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Fatalf("expected send error, got: %v", err)
+	}
+
+	sink.err = nil
+	if err := w.Evaluate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sink.events) != 1 || len(sink.events[0]) != 2 {
+		t.Fatalf("expected unsent events to be sent again, got: %v", sink.events)
+	}
+}
+
+func TestEvaluateError(t *testing.T) {
+	simulator := loadSimulator(t, "../../testdata/real-world/awsconfig.jsonl")
+
+	invalid := Check{Name: "invalid", Actions: []string{"s3:NotAnAction"}}
+	sink := &recordingSink{}
+	w := NewWatcher(simulator, []Check{invalid, bearCheck}, sink)
+
+	err := w.Evaluate()
+	if err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Fatalf("expected error evaluating invalid check, got: %v", err)
+	}
+
+	// other checks are still evaluated
+	if _, ok := w.results[bearCheck.Name]; !ok {
+		t.Fatalf("expected valid check to be evaluated")
+	}
+}